@@ -0,0 +1,260 @@
+// Package config loads quantum-vpn settings from a config file and command
+// line flags.
+//
+// Settings start from Default, are replaced by any values in the file named
+// by --config, and finally by flags given explicitly on the command line.
+// Files use a small subset of TOML:
+//
+//	mode = "server"
+//	address = ":8443"
+//	cipher = "aes-gcm"
+//	tracing = "none"
+//
+//	[log]
+//	level = "info"
+//	format = "json"
+//
+//	[observability]
+//	address = ":9090"
+//
+//	[rate_limit]
+//	max_connections_per_ip = 10
+//	handshake_rate = 5.0
+//	handshake_burst = 10
+//
+//	[rekey]
+//	max_bytes = 1_073_741_824
+//	max_packets = 268_435_456
+//	max_duration = "1h"
+//	disabled = false
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+// Config holds the effective quantum-vpn settings.
+type Config struct {
+	// Mode is "server" or "client".
+	Mode string `toml:"mode"`
+
+	// Address is the address to listen on or connect to.
+	Address string `toml:"address"`
+
+	// Cipher is the cipher suite a client offers: "aes-gcm" (AES-256-GCM),
+	// "aes128-gcm" or "chacha20".
+	Cipher string `toml:"cipher"`
+
+	// Tracing is "none", "simple" or "otel".
+	Tracing string `toml:"tracing"`
+
+	Log           LogConfig           `toml:"log"`
+	Observability ObservabilityConfig `toml:"observability"`
+	RateLimit     RateLimitConfig     `toml:"rate_limit"`
+	Rekey         RekeyConfig         `toml:"rekey"`
+}
+
+// LogConfig holds logging settings.
+type LogConfig struct {
+	// Level is "debug", "info", "warn", "error" or "silent".
+	Level string `toml:"level"`
+
+	// Format is "text" or "json".
+	Format string `toml:"format"`
+}
+
+// ObservabilityConfig holds the metrics and health server settings.
+type ObservabilityConfig struct {
+	// Address is the server's listen address (server mode). Empty disables it.
+	Address string `toml:"address"`
+}
+
+// RateLimitConfig mirrors tunnel.RateLimitConfig. Zero values disable the
+// corresponding limit.
+type RateLimitConfig struct {
+	MaxConnectionsPerIP int     `toml:"max_connections_per_ip"`
+	HandshakeRate       float64 `toml:"handshake_rate"`
+	HandshakeBurst      int     `toml:"handshake_burst"`
+}
+
+// RekeyConfig mirrors tunnel.RekeyPolicy.
+type RekeyConfig struct {
+	MaxBytes    uint64        `toml:"max_bytes"`
+	MaxPackets  uint64        `toml:"max_packets"`
+	MaxDuration time.Duration `toml:"max_duration"`
+	Disabled    bool          `toml:"disabled"`
+}
+
+// Default returns the settings used when neither a file nor a flag sets them.
+func Default() Config {
+	policy := tunnel.DefaultRekeyPolicy()
+	return Config{
+		Mode:    "server",
+		Address: "localhost:8443",
+		Cipher:  "aes-gcm",
+		Tracing: "none",
+		Log: LogConfig{
+			Level:  "warn",
+			Format: "text",
+		},
+		Observability: ObservabilityConfig{
+			Address: ":9090",
+		},
+		Rekey: RekeyConfig{
+			MaxBytes:    policy.MaxBytes,
+			MaxPackets:  policy.MaxPackets,
+			MaxDuration: policy.MaxDuration,
+		},
+	}
+}
+
+// Load reads the config file at path on top of Default.
+func Load(path string) (Config, error) {
+	cfg := Default()
+	f, err := os.Open(path)
+	if err != nil {
+		return cfg, err
+	}
+	defer func() { _ = f.Close() }()
+
+	if err := decode(f, &cfg); err != nil {
+		return cfg, fmt.Errorf("config %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse registers the config flags on fs, parses args and returns the
+// merged configuration: Default, then the file given by --config, then any
+// flags set in args. The result is validated.
+func Parse(fs *flag.FlagSet, args []string) (Config, error) {
+	cfg := Default()
+	path := fs.String("config", "", "Config file (TOML); flags override its values")
+	cfg.bindFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return cfg, err
+	}
+
+	if *path != "" {
+		// Remember the explicit flags, load the file, then reapply them
+		set := make(map[string]string)
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+
+		loaded, err := Load(*path)
+		if err != nil {
+			return cfg, err
+		}
+		cfg = loaded
+		for name, value := range set {
+			if err := fs.Set(name, value); err != nil {
+				return cfg, err
+			}
+		}
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// bindFlags registers a flag for each setting, writing into c.
+func (c *Config) bindFlags(fs *flag.FlagSet) {
+	fs.StringVar(&c.Mode, "mode", c.Mode, "Mode: server or client")
+	fs.StringVar(&c.Address, "addr", c.Address, "Address to listen/connect")
+	fs.StringVar(&c.Cipher, "cipher", c.Cipher, "Cipher suite offered by clients: aes-gcm, aes128-gcm or chacha20")
+	fs.StringVar(&c.Tracing, "tracing", c.Tracing, "Tracing mode: none, simple, otel (requires -tags otel)")
+	fs.StringVar(&c.Log.Level, "log-level", c.Log.Level, "Log level: debug, info, warn, error, silent")
+	fs.StringVar(&c.Log.Format, "log-format", c.Log.Format, "Log format: text or json")
+	fs.StringVar(&c.Observability.Address, "obs-addr", c.Observability.Address, "Observability server address (server mode). Empty disables")
+	fs.IntVar(&c.RateLimit.MaxConnectionsPerIP, "max-conns-per-ip", c.RateLimit.MaxConnectionsPerIP, "Concurrent connections allowed per IP (0 = unlimited)")
+	fs.Float64Var(&c.RateLimit.HandshakeRate, "handshake-rate", c.RateLimit.HandshakeRate, "Handshakes allowed per second (0 = unlimited)")
+	fs.IntVar(&c.RateLimit.HandshakeBurst, "handshake-burst", c.RateLimit.HandshakeBurst, "Handshake burst size")
+	fs.Uint64Var(&c.Rekey.MaxBytes, "rekey-bytes", c.Rekey.MaxBytes, "Bytes sent before rekeying")
+	fs.Uint64Var(&c.Rekey.MaxPackets, "rekey-packets", c.Rekey.MaxPackets, "Records sent before rekeying")
+	fs.DurationVar(&c.Rekey.MaxDuration, "rekey-interval", c.Rekey.MaxDuration, "Time before rekeying")
+	fs.BoolVar(&c.Rekey.Disabled, "rekey-disabled", c.Rekey.Disabled, "Disable automatic rekey thresholds")
+}
+
+// Validate checks that every setting has an accepted value.
+func (c *Config) Validate() error {
+	switch c.Mode {
+	case "server", "client":
+	default:
+		return fmt.Errorf("invalid mode: %s (use server or client)", c.Mode)
+	}
+	suite, err := c.CipherSuite()
+	if err != nil {
+		return err
+	}
+	switch strings.ToLower(c.Tracing) {
+	case "none", "simple", "otel":
+	default:
+		return fmt.Errorf("invalid tracing mode: %s (use none, simple, or otel)", c.Tracing)
+	}
+	switch strings.ToLower(c.Log.Level) {
+	case "debug", "info", "warn", "warning", "error", "silent", "off", "none":
+	default:
+		return fmt.Errorf("invalid log level: %s (use debug, info, warn, error, silent)", c.Log.Level)
+	}
+	switch strings.ToLower(c.Log.Format) {
+	case "text", "json":
+	default:
+		return fmt.Errorf("invalid log format: %s (use text or json)", c.Log.Format)
+	}
+	if c.RateLimit.MaxConnectionsPerIP < 0 || c.RateLimit.HandshakeRate < 0 || c.RateLimit.HandshakeBurst < 0 {
+		return fmt.Errorf("rate limits must not be negative")
+	}
+	if err := c.RekeyPolicy().Validate(suite); err != nil {
+		return fmt.Errorf("invalid rekey policy: %w", err)
+	}
+	return nil
+}
+
+// CipherSuite returns the cipher suite named by Cipher.
+func (c *Config) CipherSuite() (constants.CipherSuite, error) {
+	switch strings.ToLower(c.Cipher) {
+	case "aes-gcm", "aes256-gcm":
+		return constants.CipherSuiteAES256GCM, nil
+	case "aes128-gcm":
+		return constants.CipherSuiteAES128GCM, nil
+	case "chacha20", "chacha20-poly1305":
+		return constants.CipherSuiteChaCha20Poly1305, nil
+	default:
+		return 0, fmt.Errorf("invalid cipher: %s (use aes-gcm, aes128-gcm or chacha20)", c.Cipher)
+	}
+}
+
+// RekeyPolicy returns the configured rekey policy.
+func (c *Config) RekeyPolicy() tunnel.RekeyPolicy {
+	return tunnel.RekeyPolicy{
+		MaxBytes:    c.Rekey.MaxBytes,
+		MaxPackets:  c.Rekey.MaxPackets,
+		MaxDuration: c.Rekey.MaxDuration,
+		Disabled:    c.Rekey.Disabled,
+	}
+}
+
+// ApplyTransport sets the rate limits, offered cipher suite and rekey policy
+// on a transport configuration. Cipher only narrows a client's offer: a
+// server keeps accepting every suite in tc, whatever Cipher names.
+func (c *Config) ApplyTransport(tc *tunnel.TransportConfig) error {
+	suite, err := c.CipherSuite()
+	if err != nil {
+		return err
+	}
+
+	tc.RateLimit = tunnel.RateLimitConfig{
+		MaxConnectionsPerIP: c.RateLimit.MaxConnectionsPerIP,
+		HandshakeRateLimit:  c.RateLimit.HandshakeRate,
+		HandshakeBurst:      c.RateLimit.HandshakeBurst,
+	}
+	if c.Mode == "client" {
+		tc.Session.CipherSuites = []constants.CipherSuite{suite}
+	}
+	tc.Session.RekeyPolicy = c.RekeyPolicy()
+	return nil
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+func newFlagSet() *flag.FlagSet {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+func TestLoadSample(t *testing.T) {
+	cfg, err := Load("testdata/sample.toml")
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+
+	want := Config{
+		Mode:    "client",
+		Address: "vpn.example.com:8443",
+		Cipher:  "chacha20",
+		Tracing: "simple",
+		Log:     LogConfig{Level: "info", Format: "json"},
+		RateLimit: RateLimitConfig{
+			MaxConnectionsPerIP: 10,
+			HandshakeRate:       5.5,
+			HandshakeBurst:      20,
+		},
+		Rekey: RekeyConfig{
+			MaxBytes:    512 << 20,
+			MaxPackets:  1_000_000,
+			MaxDuration: 30 * time.Minute,
+		},
+	}
+	if cfg != want {
+		t.Errorf("Load = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestParseFlagOverridesFile(t *testing.T) {
+	cfg, err := Parse(newFlagSet(), []string{
+		"--log-level", "debug",
+		"--config", "testdata/sample.toml",
+		"--rekey-interval", "5m",
+	})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	// Explicit flags win over the file...
+	if cfg.Log.Level != "debug" {
+		t.Errorf("log level = %q, want flag value %q", cfg.Log.Level, "debug")
+	}
+	if cfg.Rekey.MaxDuration != 5*time.Minute {
+		t.Errorf("rekey interval = %v, want flag value 5m", cfg.Rekey.MaxDuration)
+	}
+
+	// ...the file wins over defaults...
+	if cfg.Mode != "client" || cfg.Log.Format != "json" || cfg.Observability.Address != "" {
+		t.Errorf("file values not applied: %+v", cfg)
+	}
+
+	// ...and unset values keep their defaults
+	if cfg.Rekey.Disabled {
+		t.Error("rekey should stay enabled")
+	}
+
+	var tc tunnel.TransportConfig
+	if err := cfg.ApplyTransport(&tc); err != nil {
+		t.Fatalf("ApplyTransport failed: %v", err)
+	}
+	if len(tc.Session.CipherSuites) != 1 || tc.Session.CipherSuites[0] != constants.CipherSuiteChaCha20Poly1305 {
+		t.Errorf("cipher suites = %v, want ChaCha20-Poly1305", tc.Session.CipherSuites)
+	}
+	if tc.RateLimit.MaxConnectionsPerIP != 10 || tc.RateLimit.HandshakeRateLimit != 5.5 || tc.RateLimit.HandshakeBurst != 20 {
+		t.Errorf("rate limits = %+v", tc.RateLimit)
+	}
+	if tc.Session.RekeyPolicy.MaxDuration != 5*time.Minute || tc.Session.RekeyPolicy.MaxBytes != 512<<20 {
+		t.Errorf("rekey policy = %+v", tc.Session.RekeyPolicy)
+	}
+}
+
+func TestApplyTransportServerAcceptsAllSuites(t *testing.T) {
+	cfg := Default()
+	cfg.Cipher = "chacha20"
+
+	tc := tunnel.DefaultTransportConfig()
+	want := slices.Clone(tc.Session.CipherSuites)
+	if err := cfg.ApplyTransport(&tc); err != nil {
+		t.Fatalf("ApplyTransport failed: %v", err)
+	}
+	if !slices.Equal(tc.Session.CipherSuites, want) {
+		t.Errorf("server cipher suites = %v, want the defaults %v", tc.Session.CipherSuites, want)
+	}
+}
+
+func TestParseWithoutFile(t *testing.T) {
+	cfg, err := Parse(newFlagSet(), []string{"--mode", "client"})
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	want := Default()
+	want.Mode = "client"
+	if cfg != want {
+		t.Errorf("Parse = %+v, want %+v", cfg, want)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		args []string
+		want string
+	}{
+		{"mode flag", "", []string{"--mode", "relay"}, "invalid mode"},
+		{"cipher flag", "", []string{"--cipher", "rot13"}, "invalid cipher"},
+		{"unknown key", `adress = ":1"`, nil, `unknown key "adress"`},
+		{"unknown table key", "[log]\nlevel = \"info\"\ncolour = true", nil, `unknown key "log.colour"`},
+		{"unknown table", "[metrics]", nil, "unknown table"},
+		{"bad duration", "[rekey]\nmax_duration = \"soon\"", nil, "line 2"},
+		{"unquoted string", "mode = server", nil, "quoted string"},
+		{"log level in file", "[log]\nlevel = \"loud\"", nil, "invalid log level"},
+		{"missing file", "", []string{"--config", "testdata/missing.toml"}, "missing.toml"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				path := filepath.Join(t.TempDir(), "config.toml")
+				if err := os.WriteFile(path, []byte(tt.file), 0o600); err != nil {
+					t.Fatalf("WriteFile failed: %v", err)
+				}
+				args = append([]string{"--config", path}, args...)
+			}
+
+			_, err := Parse(newFlagSet(), args)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Parse error = %v, want it to contain %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestDecodeComments(t *testing.T) {
+	var cfg Config
+	input := "address = \"host#1:80\" # the '#' inside quotes is kept\n# full-line comment\n"
+	if err := decode(strings.NewReader(input), &cfg); err != nil {
+		t.Fatalf("decode failed: %v", err)
+	}
+	if cfg.Address != "host#1:80" {
+		t.Errorf("address = %q, want %q", cfg.Address, "host#1:80")
+	}
+}
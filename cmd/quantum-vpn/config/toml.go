@@ -0,0 +1,163 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// decode parses the TOML subset used by config files into v, which must be
+// a pointer to a struct whose fields carry `toml` tags:
+//
+//	# comment
+//	key = "string"        # strings may also use 'single quotes'
+//	count = 10_000        # integers, floats and true/false
+//
+//	[table]               # nested struct field tagged "table"
+//	interval = "30s"      # time.Duration fields take Go duration strings
+//
+// Arrays, inline tables and multi-line strings are not supported. Unknown
+// keys and tables are errors so that typos do not pass silently.
+func decode(r io.Reader, v any) error {
+	root := reflect.ValueOf(v).Elem()
+	table := root
+	tableName := ""
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(stripComment(scanner.Text()))
+		if text == "" {
+			continue
+		}
+
+		if strings.HasPrefix(text, "[") {
+			if !strings.HasSuffix(text, "]") {
+				return fmt.Errorf("line %d: malformed table header %q", line, text)
+			}
+			tableName = strings.TrimSpace(text[1 : len(text)-1])
+			field, ok := lookupField(root, tableName)
+			if !ok || field.Kind() != reflect.Struct {
+				return fmt.Errorf("line %d: unknown table [%s]", line, tableName)
+			}
+			table = field
+			continue
+		}
+
+		key, value, ok := strings.Cut(text, "=")
+		if !ok {
+			return fmt.Errorf("line %d: expected key = value", line)
+		}
+		key = strings.TrimSpace(key)
+		field, ok := lookupField(table, key)
+		if !ok || field.Kind() == reflect.Struct {
+			if tableName != "" {
+				key = tableName + "." + key
+			}
+			return fmt.Errorf("line %d: unknown key %q", line, key)
+		}
+		if err := setValue(field, strings.TrimSpace(value)); err != nil {
+			return fmt.Errorf("line %d: %s: %w", line, key, err)
+		}
+	}
+	return scanner.Err()
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// lookupField returns the field of struct v tagged name.
+func lookupField(v reflect.Value, name string) (reflect.Value, bool) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("toml") == name {
+			return v.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// setValue parses a TOML value into field.
+func setValue(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		s, err := parseString(value)
+		if err != nil {
+			return err
+		}
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		s, err := parseString(value)
+		if err != nil {
+			return err
+		}
+		field.SetString(s)
+	case reflect.Bool:
+		if value != "true" && value != "false" {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		field.SetBool(value == "true")
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(strings.ReplaceAll(value, "_", ""), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(n)
+	case reflect.Uint64:
+		n, err := strconv.ParseUint(strings.ReplaceAll(value, "_", ""), 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid unsigned integer %q", value)
+		}
+		field.SetUint(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(strings.ReplaceAll(value, "_", ""), 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// parseString parses a basic ("...") or literal ('...') TOML string.
+func parseString(value string) (string, error) {
+	if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+		return value[1 : len(value)-1], nil
+	}
+	if len(value) >= 2 && value[0] == '"' {
+		return strconv.Unquote(value)
+	}
+	return "", fmt.Errorf("expected a quoted string, got %s", value)
+}
+
+// stripComment removes a trailing # comment outside of quoted strings.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
@@ -12,29 +12,74 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/sara-star-quant/quantum-go/cmd/quantum-vpn/config"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 	"github.com/sara-star-quant/quantum-go/pkg/metrics"
 	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
 
-func runDemo(mode, addr, message string, verbose bool, obsAddr, logLevel, logFormat, tracing string) {
-	collector, observerFactory, logger, err := setupObservability(logLevel, logFormat, tracing)
+// demoIdentity names the identity key files used by the demo.
+type demoIdentity struct {
+	// KeyFile is the server's identity key, generated if missing (server mode).
+	KeyFile string
+
+	// ServerKeyFile is the server public key the client pins (client mode).
+	ServerKeyFile string
+}
+
+func runDemo(cfg config.Config, message string, verbose bool, identity demoIdentity) {
+	collector, observerFactory, logger, err := setupObservability(cfg.Log.Level, cfg.Log.Format, cfg.Tracing)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
 
-	switch mode {
+	transportConfig := tunnel.DefaultTransportConfig()
+	transportConfig.ObserverFactory = observerFactory
+	if err := cfg.ApplyTransport(&transportConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if err := applyDemoIdentity(cfg.Mode, identity, &transportConfig); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	switch cfg.Mode {
 	case "server":
-		runDemoServer(addr, verbose, obsAddr, collector, observerFactory, logger)
+		runDemoServer(cfg.Address, verbose, cfg.Observability.Address, transportConfig, collector, logger)
 	case "client":
-		runDemoClient(addr, message, verbose, observerFactory)
+		runDemoClient(cfg.Address, message, verbose, transportConfig)
 	default:
-		fmt.Fprintf(os.Stderr, "Invalid mode: %s (use 'server' or 'client')\n", mode)
+		fmt.Fprintf(os.Stderr, "Invalid mode: %s (use 'server' or 'client')\n", cfg.Mode)
 		os.Exit(1)
 	}
 }
 
-func runDemoServer(addr string, verbose bool, obsAddr string, collector *metrics.Collector, observerFactory tunnel.ObserverFactory, logger *metrics.Logger) {
+// applyDemoIdentity loads the identity keys for mode into config.
+func applyDemoIdentity(mode string, identity demoIdentity, config *tunnel.TransportConfig) error {
+	switch {
+	case mode == "server" && identity.KeyFile != "":
+		key, generated, err := loadOrGenerateIdentityKey(identity.KeyFile)
+		if err != nil {
+			return err
+		}
+		if generated {
+			fmt.Printf("✓ Generated server identity key %s (give clients %s%s)\n",
+				identity.KeyFile, identity.KeyFile, publicKeySuffix)
+		}
+		config.IdentityKey = key
+	case mode == "client" && identity.ServerKeyFile != "":
+		pinned, err := loadServerKey(identity.ServerKeyFile)
+		if err != nil {
+			return err
+		}
+		config.PinnedServerKey = pinned
+	}
+	return nil
+}
+
+func runDemoServer(addr string, verbose bool, obsAddr string, config tunnel.TransportConfig, collector *metrics.Collector, logger *metrics.Logger) {
 	fmt.Println("╔═══════════════════════════════════════════════════════════╗")
 	fmt.Println("║      Quantum-Resistant VPN Demo Server                   ║")
 	fmt.Println("║      CH-KEM: ML-KEM-1024 + X25519                        ║")
@@ -59,13 +104,18 @@ func runDemoServer(addr string, verbose bool, obsAddr string, collector *metrics
 	}
 	defer func() { _ = listener.Close() }()
 
-	config := tunnel.DefaultTransportConfig()
-	config.ObserverFactory = observerFactory
 	config.RateLimitObserver = metrics.NewRateLimitObserver(collector, logger)
-	listener.SetConfig(config)
+	if err := listener.SetConfig(config); err != nil {
+		_ = listener.Close()
+		fmt.Fprintf(os.Stderr, "Error: Invalid configuration: %v\n", err)
+		os.Exit(1)
+	}
 
 	actualAddr := listener.Addr().String()
 	fmt.Printf("✓ Server listening on %s\n", actualAddr)
+	if config.IdentityKey != nil {
+		fmt.Printf("✓ Server identity: %x\n", tunnel.IdentityFingerprint(config.IdentityKey.PublicKey()))
+	}
 	fmt.Println("Waiting for connections... (Press Ctrl+C to stop)")
 	fmt.Println()
 
@@ -160,7 +210,7 @@ func handleConnection(conn *tunnel.Tunnel, connNum int, verbose bool) {
 	}
 }
 
-func runDemoClient(addr, message string, verbose bool, observerFactory tunnel.ObserverFactory) {
+func runDemoClient(addr, message string, verbose bool, config tunnel.TransportConfig) {
 	fmt.Println("╔═══════════════════════════════════════════════════════════╗")
 	fmt.Println("║      Quantum-Resistant VPN Demo Client                   ║")
 	fmt.Println("║      CH-KEM: ML-KEM-1024 + X25519                        ║")
@@ -179,11 +229,12 @@ func runDemoClient(addr, message string, verbose bool, observerFactory tunnel.Ob
 	fmt.Printf("Connecting to %s...\n", addr)
 
 	startHandshake := time.Now()
-	config := tunnel.DefaultTransportConfig()
-	config.ObserverFactory = observerFactory
-
 	client, err := tunnel.DialWithConfig("tcp", addr, config)
 	if err != nil {
+		if config.PinnedServerKey != nil && errors.Is(err, qerrors.ErrAuthenticationFailed) {
+			fmt.Fprintln(os.Stderr, "Error: Server identity does not match the pinned server key; refusing to connect")
+			os.Exit(1)
+		}
 		fmt.Fprintf(os.Stderr, "Error: Failed to connect: %v\n", err)
 		os.Exit(1)
 	}
@@ -192,6 +243,13 @@ func runDemoClient(addr, message string, verbose bool, observerFactory tunnel.Ob
 	handshakeDuration := time.Since(startHandshake)
 
 	fmt.Printf("✓ Connected successfully\n")
+	if state := client.ConnectionState(); state.PeerAuthenticated {
+		if config.PinnedServerKey != nil {
+			fmt.Printf("✓ Server identity matches pinned key: %x\n", state.PeerFingerprint)
+		} else {
+			fmt.Printf("! Server identity not pinned: %x\n", state.PeerFingerprint)
+		}
+	}
 	if verbose {
 		fmt.Printf("  Handshake time: %v\n", handshakeDuration)
 		fmt.Printf("  Local: %s\n", client.LocalAddr())
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+// Identity key files hold hex-encoded keys on a single line: the private
+// key file holds the Ed25519 seed and the public key file, named after it
+// with a ".pub" suffix, holds the public key clients pin.
+const publicKeySuffix = ".pub"
+
+// loadOrGenerateIdentityKey reads the server identity key from path. If
+// path does not exist, it generates a key and saves it to path and its
+// public key to path.pub, reporting generated.
+func loadOrGenerateIdentityKey(path string) (key *tunnel.IdentityKey, generated bool, err error) {
+	data, err := os.ReadFile(path)
+	if err == nil {
+		seed, err := decodeKeyFile(data, ed25519.SeedSize)
+		if err != nil {
+			return nil, false, fmt.Errorf("identity key %s: %w", path, err)
+		}
+		key, err := tunnel.NewIdentityKeyFromSeed(seed)
+		return key, false, err
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, false, err
+	}
+
+	key, err = tunnel.GenerateIdentityKey()
+	if err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(path, encodeKeyFile(key.Seed()), 0o600); err != nil {
+		return nil, false, err
+	}
+	if err := os.WriteFile(path+publicKeySuffix, encodeKeyFile(key.PublicKey()), 0o644); err != nil {
+		return nil, false, err
+	}
+	return key, true, nil
+}
+
+// loadServerKey reads a pinned server public key from path.
+func loadServerKey(path string) (ed25519.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := decodeKeyFile(data, ed25519.PublicKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("server key %s: %w", path, err)
+	}
+	return ed25519.PublicKey(key), nil
+}
+
+// encodeKeyFile returns the contents of a key file holding key.
+func encodeKeyFile(key []byte) []byte {
+	return []byte(hex.EncodeToString(key) + "\n")
+}
+
+// decodeKeyFile parses the contents of a key file holding a key of size bytes.
+func decodeKeyFile(data []byte, size int) ([]byte, error) {
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid hex: %w", err)
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(key))
+	}
+	return key, nil
+}
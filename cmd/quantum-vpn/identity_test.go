@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+func TestDemoServerKeyPinning(t *testing.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "server.key")
+
+	key, generated, err := loadOrGenerateIdentityKey(keyFile)
+	if err != nil || !generated {
+		t.Fatalf("loadOrGenerateIdentityKey = %v, %v; want a generated key", generated, err)
+	}
+
+	// The key persists across restarts
+	reloaded, generated, err := loadOrGenerateIdentityKey(keyFile)
+	if err != nil || generated {
+		t.Fatalf("reloading = %v, %v; want the saved key", generated, err)
+	}
+	if !reloaded.PublicKey().Equal(key.PublicKey()) {
+		t.Fatal("reloaded identity key differs from the generated one")
+	}
+
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	serverConfig := tunnel.DefaultTransportConfig()
+	if err := applyDemoIdentity("server", demoIdentity{KeyFile: keyFile}, &serverConfig); err != nil {
+		t.Fatalf("applyDemoIdentity (server) failed: %v", err)
+	}
+	listener.SetConfig(serverConfig)
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	dial := func(serverKeyFile string) error {
+		config := tunnel.DefaultTransportConfig()
+		if err := applyDemoIdentity("client", demoIdentity{ServerKeyFile: serverKeyFile}, &config); err != nil {
+			t.Fatalf("applyDemoIdentity (client) failed: %v", err)
+		}
+		client, err := tunnel.DialWithConfig("tcp", listener.Addr().String(), config)
+		if err != nil {
+			return err
+		}
+		return client.Close()
+	}
+
+	// Pinning the server's public key succeeds
+	if err := dial(keyFile + publicKeySuffix); err != nil {
+		t.Fatalf("dial with the correct pinned key failed: %v", err)
+	}
+
+	// Pinning another key fails authentication
+	otherFile := filepath.Join(dir, "other.key")
+	if _, _, err := loadOrGenerateIdentityKey(otherFile); err != nil {
+		t.Fatalf("loadOrGenerateIdentityKey failed: %v", err)
+	}
+	if err := dial(otherFile + publicKeySuffix); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Fatalf("expected ErrAuthenticationFailed with a wrong pinned key, got %v", err)
+	}
+}
+
+func TestLoadServerKeyInvalid(t *testing.T) {
+	dir := t.TempDir()
+
+	for name, contents := range map[string]string{
+		"not hex":   "not a key\n",
+		"too short": "abcd\n",
+	} {
+		path := filepath.Join(dir, "server.pub")
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		if _, err := loadServerKey(path); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	if _, err := loadServerKey(filepath.Join(dir, "missing.pub")); err == nil {
+		t.Error("expected error for a missing key file")
+	}
+}
@@ -4,7 +4,9 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"time"
 
+	"github.com/sara-star-quant/quantum-go/cmd/quantum-vpn/config"
 	pkgversion "github.com/sara-star-quant/quantum-go/pkg/version"
 )
 
@@ -35,6 +37,8 @@ func main() {
 		demoCommand()
 	case "bench":
 		benchCommand()
+	case "perf":
+		perfCommand()
 	case "example":
 		exampleCommand()
 	case "version":
@@ -63,6 +67,7 @@ USAGE:
 COMMANDS:
     demo      Run interactive demo (client/server)
     bench     Run performance benchmarks
+    perf      Measure throughput and latency between two hosts
     example   Show example usage with explanations
     version   Print version information
     help      Show this help message
@@ -82,6 +87,10 @@ EXAMPLES:
     # Run throughput benchmark
     quantum-vpn bench --throughput --size 1GB --duration 30s
 
+    # Measure network throughput (server, then client on another host)
+    quantum-vpn perf --server --addr :5201
+    quantum-vpn perf --client --addr server:5201 --duration 30s --parallel 4
+
     # Show interactive examples
     quantum-vpn example
 
@@ -95,14 +104,10 @@ PROJECT:
 
 func demoCommand() {
 	fs := flag.NewFlagSet("demo", flag.ExitOnError)
-	mode := fs.String("mode", "server", "Mode: server or client")
-	addr := fs.String("addr", "localhost:8443", "Address to listen/connect")
 	message := fs.String("message", "Hello from quantum-vpn!", "Message to send (client mode)")
 	verbose := fs.Bool("verbose", false, "Verbose output")
-	obsAddr := fs.String("obs-addr", ":9090", "Observability server address (server mode). Empty disables")
-	logLevel := fs.String("log-level", "warn", "Log level: debug, info, warn, error, silent")
-	logFormat := fs.String("log-format", "text", "Log format: text or json")
-	tracing := fs.String("tracing", "none", "Tracing mode: none, simple, otel (requires -tags otel)")
+	genServerKey := fs.String("gen-server-key", "", "Server identity key file, generated with a .pub public key if missing (server mode)")
+	serverKey := fs.String("server-key", "", "Pinned server public key file; the handshake fails on any other server (client mode)")
 
 	fs.Usage = func() {
 		fmt.Println(`USAGE: quantum-vpn demo [options]
@@ -119,13 +124,27 @@ EXAMPLES:
     # Terminal 2: Connect client
     quantum-vpn demo --mode client --addr localhost:8443 --message "Test message"
 
+    # Authenticate the server with a persistent identity key, and pin it
+    quantum-vpn demo --mode server --addr :8443 --gen-server-key server.key
+    quantum-vpn demo --mode client --addr localhost:8443 --server-key server.key.pub
+
     # Verbose output (show handshake details)
-    quantum-vpn demo --mode server --addr :8443 --verbose`)
+    quantum-vpn demo --mode server --addr :8443 --verbose
+
+    # Load settings from a file, overriding its log level
+    quantum-vpn demo --config quantum-vpn.toml --log-level debug`)
 	}
 
-	_ = fs.Parse(os.Args[2:])
+	cfg, err := config.Parse(fs, os.Args[2:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	runDemo(*mode, *addr, *message, *verbose, *obsAddr, *logLevel, *logFormat, *tracing)
+	runDemo(cfg, *message, *verbose, demoIdentity{
+		KeyFile:       *genServerKey,
+		ServerKeyFile: *serverKey,
+	})
 }
 
 func benchCommand() {
@@ -163,6 +182,71 @@ EXAMPLES:
 	runBench(*handshakes, *throughput, *size, *duration, *cipherSuite)
 }
 
+func perfCommand() {
+	fs := flag.NewFlagSet("perf", flag.ExitOnError)
+	server := fs.Bool("server", false, "Run as perf server")
+	client := fs.Bool("client", false, "Run as perf client")
+	addr := fs.String("addr", ":5201", "Address to listen on (server) or connect to (client)")
+	duration := fs.Duration("duration", 10*time.Second, "Test duration (client)")
+	parallel := fs.Int("parallel", 1, "Number of parallel tunnels (client)")
+	chunk := fs.String("chunk", "16KB", "Size of each data message (client)")
+	interval := fs.Duration("ping-interval", 100*time.Millisecond, "Interval between latency probes (client)")
+	cipherSuite := fs.String("cipher", "aes-gcm", "Cipher suite: aes-gcm, aes128-gcm or chacha20 (client)")
+	rekeyBytes := fs.String("rekey-bytes", "0", "Rekey after this many bytes, e.g. 64MB (client, 0 = default policy)")
+	jsonOutput := fs.Bool("json", false, "Print the client summary as JSON")
+
+	fs.Usage = func() {
+		fmt.Println(`USAGE: quantum-vpn perf --server|--client [options]
+
+Measure throughput, latency under load and rekeys over real tunnels,
+similar to iperf3.
+
+OPTIONS:`)
+		fs.PrintDefaults()
+		fmt.Println(`
+EXAMPLES:
+    # Start perf server
+    quantum-vpn perf --server --addr :5201
+
+    # Run 4 parallel streams for 30 seconds
+    quantum-vpn perf --client --addr server:5201 --duration 30s --parallel 4
+
+    # Machine-readable summary with frequent rekeys
+    quantum-vpn perf --client --addr server:5201 --rekey-bytes 64MB --json`)
+	}
+
+	_ = fs.Parse(os.Args[2:])
+
+	if *server == *client {
+		fmt.Fprintln(os.Stderr, "Error: specify exactly one of --server or --client")
+		os.Exit(1)
+	}
+	if *parallel < 1 || *duration <= 0 || *interval <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: --parallel, --duration and --ping-interval must be positive")
+		os.Exit(1)
+	}
+	chunkSize := parseSize(*chunk)
+	if chunkSize < 1 || chunkSize > perfMaxChunk {
+		fmt.Fprintf(os.Stderr, "Error: --chunk must be between 1 and %d bytes\n", perfMaxChunk)
+		os.Exit(1)
+	}
+	suite, err := (&config.Config{Cipher: *cipherSuite}).CipherSuite()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	runPerf(*server, perfOptions{
+		Addr:         *addr,
+		Duration:     *duration,
+		Parallel:     *parallel,
+		ChunkSize:    int(chunkSize),
+		PingInterval: *interval,
+		CipherSuite:  suite,
+		RekeyBytes:   uint64(parseSize(*rekeyBytes)),
+	}, *jsonOutput)
+}
+
 func exampleCommand() {
 	if len(os.Args) > 2 && (os.Args[2] == "--help" || os.Args[2] == "-h") {
 		fmt.Println(`USAGE: quantum-vpn example
@@ -0,0 +1,341 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	"github.com/sara-star-quant/quantum-go/pkg/metrics"
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+// Perf stream messages. Each record starts with one of these type bytes.
+const (
+	perfData   byte = 'D' // payload, counted and discarded by the server
+	perfPing   byte = 'P' // 8-byte send timestamp, echoed by the server
+	perfEnd    byte = 'E' // client is done sending
+	perfResult byte = 'R' // 8-byte count of data bytes the server received
+)
+
+// perfResultTimeout bounds how long a client waits for the server's result
+// after it stops sending.
+const perfResultTimeout = 10 * time.Second
+
+// perfMaxChunk is the largest data message that fits in a single record.
+const perfMaxChunk = constants.MaxPayloadSize - constants.AESNonceSize - constants.AESTagSize
+
+// perfLatencyBuckets for ping round-trip times under load (milliseconds).
+var perfLatencyBuckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+// perfOptions configures a perf client run.
+type perfOptions struct {
+	Addr         string
+	Duration     time.Duration
+	Parallel     int
+	ChunkSize    int
+	PingInterval time.Duration
+	CipherSuite  constants.CipherSuite
+	RekeyBytes   uint64 // 0 keeps the default rekey policy
+}
+
+// perfReport summarizes a perf client run.
+type perfReport struct {
+	Streams        int         `json:"streams"`
+	CipherSuite    string      `json:"cipher_suite"`
+	Seconds        float64     `json:"duration_seconds"`
+	BytesSent      int64       `json:"bytes_sent"`
+	BytesReceived  int64       `json:"bytes_received"` // as reported by the server
+	ThroughputMbps float64     `json:"throughput_mbps"`
+	LatencyMs      perfLatency `json:"latency_ms"`
+	Rekeys         int64       `json:"rekeys"`
+	Errors         []string    `json:"errors,omitempty"`
+}
+
+// perfLatency summarizes ping round-trip times.
+type perfLatency struct {
+	Count uint64  `json:"count"`
+	Min   float64 `json:"min"`
+	Mean  float64 `json:"mean"`
+	Max   float64 `json:"max"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P95   float64 `json:"p95"`
+	P99   float64 `json:"p99"`
+}
+
+func runPerf(server bool, opts perfOptions, jsonOutput bool) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if server {
+		listener, err := tunnel.Listen("tcp", opts.Addr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: Failed to start listener: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Perf server listening on %s (Press Ctrl+C to stop)\n", listener.Addr())
+
+		if err := servePerf(ctx, listener, os.Stdout); err != nil && !errors.Is(err, context.Canceled) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if !jsonOutput {
+		fmt.Printf("Connecting to %s with %d stream(s) for %v\n", opts.Addr, opts.Parallel, opts.Duration)
+	}
+	report := runPerfClient(ctx, opts)
+
+	if jsonOutput {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(report)
+	} else {
+		printPerfReport(os.Stdout, report)
+	}
+	if len(report.Errors) > 0 {
+		os.Exit(1)
+	}
+}
+
+// servePerf serves perf clients on listener until ctx is cancelled, writing
+// one line per finished stream to out. Streams still running when ctx is
+// cancelled get perfResultTimeout to finish.
+func servePerf(ctx context.Context, listener *tunnel.Listener, out io.Writer) error {
+	var mu sync.Mutex
+	err := listener.Serve(ctx, func(t *tunnel.Tunnel) {
+		defer func() { _ = t.Close() }()
+
+		start := time.Now()
+		received, err := handlePerfStream(t)
+		elapsed := time.Since(start)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			_, _ = fmt.Fprintf(out, "[%s] %s: error after %s: %v\n",
+				time.Now().Format("15:04:05"), t.RemoteAddr(), formatSize(received), err)
+			return
+		}
+		_, _ = fmt.Fprintf(out, "[%s] %s: received %s in %v (%.2f Mbps)\n",
+			time.Now().Format("15:04:05"), t.RemoteAddr(), formatSize(received),
+			elapsed.Round(time.Millisecond), mbps(received, elapsed))
+	})
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), perfResultTimeout)
+	defer cancel()
+	_ = listener.Drain(drainCtx)
+	return err
+}
+
+// handlePerfStream counts data records and echoes pings until the client
+// ends the stream, then replies with the number of data bytes received.
+func handlePerfStream(t *tunnel.Tunnel) (int64, error) {
+	var received int64
+	for {
+		msg, err := t.Receive()
+		if err != nil {
+			return received, err
+		}
+		if len(msg) == 0 {
+			return received, fmt.Errorf("empty perf message")
+		}
+
+		switch msg[0] {
+		case perfData:
+			received += int64(len(msg))
+		case perfPing:
+			if err := t.Send(msg); err != nil {
+				return received, err
+			}
+		case perfEnd:
+			reply := binary.BigEndian.AppendUint64([]byte{perfResult}, uint64(received))
+			return received, t.Send(reply)
+		default:
+			return received, fmt.Errorf("unexpected perf message type %#x", msg[0])
+		}
+	}
+}
+
+// runPerfClient streams data to a perf server over opts.Parallel tunnels for
+// opts.Duration, or until ctx is cancelled, and reports the result.
+func runPerfClient(ctx context.Context, opts perfOptions) perfReport {
+	collector := metrics.NewCollector(metrics.Labels{"service": "quantum-vpn-perf"})
+	latency := metrics.NewHistogram(perfLatencyBuckets)
+
+	config := tunnel.DefaultTransportConfig()
+	config.Session.CipherSuites = []constants.CipherSuite{opts.CipherSuite}
+	if opts.RekeyBytes > 0 {
+		config.Session.RekeyPolicy.MaxBytes = opts.RekeyBytes
+	}
+	config.ObserverFactory = metrics.NewSessionObserverFactory(collector,
+		metrics.WithSessionObserverTracer(metrics.NoOpTracer{}),
+		metrics.WithSessionObserverLogger(metrics.NewLogger(metrics.WithLevel(metrics.LevelSilent))),
+	)
+
+	ctx, cancel := context.WithTimeout(ctx, opts.Duration)
+	defer cancel()
+
+	var (
+		sent, received atomic.Int64
+		errMu          sync.Mutex
+		errs           []string
+		wg             sync.WaitGroup
+	)
+	start := time.Now()
+	for i := 0; i < opts.Parallel; i++ {
+		wg.Add(1)
+		go func(stream int) {
+			defer wg.Done()
+			s, r, err := runPerfStream(ctx, opts, config, latency)
+			sent.Add(s)
+			received.Add(r)
+			if err != nil {
+				errMu.Lock()
+				errs = append(errs, fmt.Sprintf("stream %d: %v", stream, err))
+				errMu.Unlock()
+			}
+		}(i + 1)
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// Percentiles are interpolated within buckets, so cap them at the
+	// largest observed value
+	summary := latency.Summary()
+	for p, v := range summary.Percentiles {
+		summary.Percentiles[p] = min(v, summary.Max)
+	}
+	return perfReport{
+		Streams:        opts.Parallel,
+		CipherSuite:    opts.CipherSuite.String(),
+		Seconds:        elapsed.Seconds(),
+		BytesSent:      sent.Load(),
+		BytesReceived:  received.Load(),
+		ThroughputMbps: mbps(received.Load(), elapsed),
+		LatencyMs: perfLatency{
+			Count: summary.Count,
+			Min:   summary.Min,
+			Mean:  summary.Mean,
+			Max:   summary.Max,
+			P50:   summary.Percentiles[0.5],
+			P90:   summary.Percentiles[0.9],
+			P95:   summary.Percentiles[0.95],
+			P99:   summary.Percentiles[0.99],
+		},
+		Rekeys: collector.Snapshot().RekeysCompleted,
+		Errors: errs,
+	}
+}
+
+// runPerfStream sends data and periodic pings over one tunnel until ctx is
+// done, then ends the stream and returns the bytes sent and the bytes the
+// server reports receiving. Ping round trips are recorded in latency.
+func runPerfStream(ctx context.Context, opts perfOptions, config tunnel.TransportConfig, latency *metrics.Histogram) (sent, received int64, err error) {
+	t, err := tunnel.DialWithConfig("tcp", opts.Addr, config)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() { _ = t.Close() }()
+
+	// Read echoed pings until the server's result arrives
+	type result struct {
+		received int64
+		err      error
+	}
+	done := make(chan result, 1)
+	go func() {
+		for {
+			msg, err := t.Receive()
+			if err != nil {
+				done <- result{err: err}
+				return
+			}
+			switch {
+			case len(msg) == 9 && msg[0] == perfPing:
+				sentAt := time.Unix(0, int64(binary.BigEndian.Uint64(msg[1:])))
+				latency.Observe(float64(time.Since(sentAt)) / float64(time.Millisecond))
+			case len(msg) == 9 && msg[0] == perfResult:
+				done <- result{received: int64(binary.BigEndian.Uint64(msg[1:]))}
+				return
+			default:
+				done <- result{err: fmt.Errorf("unexpected perf message from server")}
+				return
+			}
+		}
+	}()
+
+	chunk := make([]byte, opts.ChunkSize)
+	chunk[0] = perfData
+	for i := 1; i < len(chunk); i++ {
+		chunk[i] = byte(i)
+	}
+	ping := make([]byte, 9)
+	ping[0] = perfPing
+	lastPing := time.Time{}
+
+	for ctx.Err() == nil {
+		if time.Since(lastPing) >= opts.PingInterval {
+			lastPing = time.Now()
+			binary.BigEndian.PutUint64(ping[1:], uint64(lastPing.UnixNano()))
+			if err := t.Send(ping); err != nil {
+				return sent, 0, err
+			}
+		}
+		if err := t.Send(chunk); err != nil {
+			return sent, 0, err
+		}
+		sent += int64(len(chunk))
+	}
+
+	if err := t.Send([]byte{perfEnd}); err != nil {
+		return sent, 0, err
+	}
+	select {
+	case res := <-done:
+		return sent, res.received, res.err
+	case <-time.After(perfResultTimeout):
+		return sent, 0, fmt.Errorf("timed out waiting for server result")
+	}
+}
+
+func printPerfReport(w io.Writer, r perfReport) {
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintln(w, "Results:")
+	_, _ = fmt.Fprintln(w, strings.Repeat("─", 60))
+	_, _ = fmt.Fprintf(w, "  Streams:     %d (%s)\n", r.Streams, r.CipherSuite)
+	_, _ = fmt.Fprintf(w, "  Duration:    %v\n", time.Duration(r.Seconds*float64(time.Second)).Round(time.Millisecond))
+	_, _ = fmt.Fprintf(w, "  Sent:        %s\n", formatSize(r.BytesSent))
+	_, _ = fmt.Fprintf(w, "  Received:    %s\n", formatSize(r.BytesReceived))
+	_, _ = fmt.Fprintf(w, "  Throughput:  %.2f Mbps\n", r.ThroughputMbps)
+	_, _ = fmt.Fprintf(w, "  Rekeys:      %d\n", r.Rekeys)
+	_, _ = fmt.Fprintln(w)
+	_, _ = fmt.Fprintf(w, "Latency under load (%d pings):\n", r.LatencyMs.Count)
+	if r.LatencyMs.Count > 0 {
+		_, _ = fmt.Fprintf(w, "  min/mean/max: %.2f / %.2f / %.2f ms\n", r.LatencyMs.Min, r.LatencyMs.Mean, r.LatencyMs.Max)
+		_, _ = fmt.Fprintf(w, "  p50/p90/p99:  %.2f / %.2f / %.2f ms\n", r.LatencyMs.P50, r.LatencyMs.P90, r.LatencyMs.P99)
+	}
+	for _, err := range r.Errors {
+		_, _ = fmt.Fprintf(w, "⚠ %s\n", err)
+	}
+}
+
+// mbps returns the rate of n bytes over d in megabits per second.
+func mbps(n int64, d time.Duration) float64 {
+	if d <= 0 {
+		return 0
+	}
+	return float64(n) * 8 / d.Seconds() / 1e6
+}
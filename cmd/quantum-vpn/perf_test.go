@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+func TestPerfLocalhost(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping perf integration test in short mode")
+	}
+
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var out bytes.Buffer
+	served := make(chan error, 1)
+	go func() { served <- servePerf(ctx, listener, &out) }()
+
+	report := runPerfClient(context.Background(), perfOptions{
+		Addr:         listener.Addr().String(),
+		Duration:     2 * time.Second,
+		Parallel:     2,
+		ChunkSize:    16 * 1024,
+		PingInterval: 50 * time.Millisecond,
+		CipherSuite:  constants.CipherSuiteAES256GCM,
+		RekeyBytes:   8 << 20,
+	})
+
+	cancel()
+	<-served
+
+	if len(report.Errors) > 0 {
+		t.Fatalf("perf client errors: %v\nserver:\n%s", report.Errors, out.String())
+	}
+	if report.BytesReceived == 0 || report.ThroughputMbps <= 0 {
+		t.Fatalf("no throughput: %+v", report)
+	}
+	if report.BytesReceived != report.BytesSent {
+		t.Errorf("server received %d bytes, client sent %d", report.BytesReceived, report.BytesSent)
+	}
+	if report.Seconds < 2 || report.Seconds > 2+perfResultTimeout.Seconds() {
+		t.Errorf("duration = %.2fs, want about 2s", report.Seconds)
+	}
+	if report.LatencyMs.Count == 0 || report.LatencyMs.P50 <= 0 {
+		t.Errorf("no latency samples: %+v", report.LatencyMs)
+	}
+	if report.BytesSent > 2*8<<20 && report.Rekeys == 0 {
+		t.Errorf("sent %d bytes with an 8MB rekey threshold but saw no rekeys", report.BytesSent)
+	}
+	if got := strings.Count(out.String(), "received"); got != 2 {
+		t.Errorf("server reported %d streams, want 2:\n%s", got, out.String())
+	}
+}
@@ -38,6 +38,16 @@ const (
 	MLKEMModulus          = 3329
 )
 
+// ML-KEM-768 Parameters (NIST FIPS 203)
+// These parameters provide NIST Category 3 security for constrained devices
+const (
+	// MLKEM768PublicKeySize is the size of ML-KEM-768 encapsulation key in bytes
+	MLKEM768PublicKeySize = 1184
+
+	// MLKEM768CiphertextSize is the size of ML-KEM-768 ciphertext in bytes
+	MLKEM768CiphertextSize = 1088
+)
+
 // X25519 Parameters (RFC 7748)
 const (
 	// X25519PublicKeySize is the size of X25519 public key in bytes
@@ -55,17 +65,34 @@ const (
 	// AESKeySize is the size of AES-256 keys in bytes
 	AESKeySize = 32
 
+	// AES128KeySize is the size of AES-128 keys in bytes
+	AES128KeySize = 16
+
 	// AESNonceSize is the size of AES-GCM nonce in bytes (96 bits)
 	AESNonceSize = 12
 
 	// AESTagSize is the size of AES-GCM authentication tag in bytes
 	AESTagSize = 16
 
+	// KeyCommitmentSize is the size of the key commitment prepended to
+	// ciphertexts by key-committing AEADs
+	KeyCommitmentSize = 32
+
 	// ChaCha20KeySize is the size of ChaCha20-Poly1305 keys in bytes
 	ChaCha20KeySize = 32
 
 	// ChaCha20NonceSize is the size of ChaCha20-Poly1305 nonce in bytes
 	ChaCha20NonceSize = 12
+
+	// NullEncryptionKeySize is the size of HMAC-SHA256 keys of the null
+	// encryption suite in bytes
+	NullEncryptionKeySize = 32
+
+	// StreamChunkSize is the plaintext size of each streaming AEAD frame in bytes
+	StreamChunkSize = 16 * 1024
+
+	// StreamSaltSize is the size of the per-stream salt in bytes
+	StreamSaltSize = 32
 )
 
 // Key Derivation Parameters (SHAKE-256)
@@ -85,11 +112,57 @@ const (
 	// DomainSeparatorTraffic is used in traffic key derivation
 	DomainSeparatorTraffic = "CH-KEM-VPN-Traffic"
 
+	// DomainSeparatorTrafficIV is used in traffic nonce IV derivation
+	DomainSeparatorTrafficIV = "CH-KEM-VPN-TrafficIV"
+
+	// DomainSeparatorKeyCommitment is used in key-committing AEAD derivation
+	DomainSeparatorKeyCommitment = "CH-KEM-VPN-KeyCommitment"
+
+	// DomainSeparatorDeterministicRNG is used by the deterministic test RNG
+	DomainSeparatorDeterministicRNG = "CH-KEM-VPN-DeterministicRNG"
+
 	// DomainSeparatorRekey is used in rekey derivation
 	DomainSeparatorRekey = "CH-KEM-VPN-Rekey"
 
+	// DomainSeparatorRatchet is mixed into symmetric (ratchet) rekey derivation
+	DomainSeparatorRatchet = "CH-KEM-VPN-Ratchet"
+
 	// DomainSeparatorResumption is used in resumption secret derivation
 	DomainSeparatorResumption = "CH-KEM-VPN-Resumption"
+
+	// DomainSeparatorStream is used in streaming AEAD subkey derivation
+	DomainSeparatorStream = "CH-KEM-VPN-Stream"
+
+	// DomainSeparatorExporter is used in exported keying material derivation
+	DomainSeparatorExporter = "CH-KEM-VPN-Exporter"
+
+	// DomainSeparatorResumptionMaster is used to derive the secret a session
+	// can later be resumed from
+	DomainSeparatorResumptionMaster = "CH-KEM-VPN-ResumptionMaster"
+
+	// DomainSeparatorAbbreviated is used in abbreviated (resumed) handshake
+	// secret derivation
+	DomainSeparatorAbbreviated = "CH-KEM-VPN-Abbreviated"
+
+	// DomainSeparatorCookie is used in HelloRetryRequest cookie MACs
+	DomainSeparatorCookie = "CH-KEM-VPN-Cookie"
+
+	// DomainSeparatorFingerprint is used in public key fingerprints
+	DomainSeparatorFingerprint = "CH-KEM-v1-Fingerprint"
+
+	// DomainSeparatorPSK is used to mix an external pre-shared key into the
+	// handshake secret
+	DomainSeparatorPSK = "CH-KEM-VPN-ExternalPSK"
+
+	// DomainSeparatorIdentity prefixes transcripts signed by a responder's
+	// identity key
+	DomainSeparatorIdentity = "CH-KEM-VPN-ServerIdentity"
+
+	// DomainSeparatorEarlyData is used to derive the 0-RTT early data key
+	DomainSeparatorEarlyData = "CH-KEM-VPN-EarlyData"
+
+	// DomainSeparatorSessionExport authenticates exported session state
+	DomainSeparatorSessionExport = "CH-KEM-VPN-SessionExport"
 )
 
 // Session Parameters
@@ -106,8 +179,23 @@ const (
 	// This prevents nonce exhaustion in AES-GCM (2^32 limit with 96-bit nonce)
 	MaxPacketsBeforeRekey = 1 << 28
 
+	// MaxSequenceNumber bounds the record sequence numbers a session sends.
+	// Sequence numbers continue across rekeys, so unlike
+	// MaxPacketsBeforeRekey this limits the session's lifetime: past it,
+	// Encrypt fails with ErrSequenceExhausted rather than wrapping around,
+	// and a new session is needed. The margin below 2^64 keeps rekey
+	// activation offsets and the replay window from overflowing.
+	MaxSequenceNumber = 1<<64 - 1<<32
+
 	// SessionIDSize is the size of session identifiers in bytes
 	SessionIDSize = 16
+
+	// DefaultReplayWindowSize is the default number of sequence numbers
+	// tracked by the replay protection window
+	DefaultReplayWindowSize = 64
+
+	// MinPSKSize is the minimum size of an external pre-shared key in bytes
+	MinPSKSize = 32
 )
 
 // Message Size Limits
@@ -132,6 +220,12 @@ const (
 
 	// CHKEMSharedSecretSize is the size of the final derived shared secret
 	CHKEMSharedSecretSize = 32
+
+	// CHKEM768PublicKeySize is the combined size of X25519 + ML-KEM-768 public keys
+	CHKEM768PublicKeySize = X25519PublicKeySize + MLKEM768PublicKeySize
+
+	// CHKEM768CiphertextSize is the combined size of X25519 public + ML-KEM-768 ciphertext
+	CHKEM768CiphertextSize = X25519PublicKeySize + MLKEM768CiphertextSize
 )
 
 // CipherSuite identifiers
@@ -143,6 +237,14 @@ const (
 
 	// CipherSuiteChaCha20Poly1305 uses ChaCha20-Poly1305 for symmetric encryption
 	CipherSuiteChaCha20Poly1305 CipherSuite = 0x0002
+
+	// CipherSuiteAES128GCM uses AES-128-GCM for symmetric encryption
+	CipherSuiteAES128GCM CipherSuite = 0x0003
+
+	// CipherSuiteNullEncryption authenticates records with HMAC-SHA256 but
+	// does not encrypt them. It is never offered by default and never
+	// approved in FIPS mode.
+	CipherSuiteNullEncryption CipherSuite = 0xFF00
 )
 
 // String returns a human-readable name for the cipher suite
@@ -152,6 +254,10 @@ func (cs CipherSuite) String() string {
 		return "AES-256-GCM"
 	case CipherSuiteChaCha20Poly1305:
 		return "ChaCha20-Poly1305"
+	case CipherSuiteAES128GCM:
+		return "AES-128-GCM"
+	case CipherSuiteNullEncryption:
+		return "NULL-HMAC-SHA256"
 	default:
 		return "Unknown"
 	}
@@ -159,11 +265,29 @@ func (cs CipherSuite) String() string {
 
 // IsSupported returns true if the cipher suite is supported
 func (cs CipherSuite) IsSupported() bool {
-	return cs == CipherSuiteAES256GCM || cs == CipherSuiteChaCha20Poly1305
+	return cs.KeySize() != 0
+}
+
+// KeySize returns the symmetric key size in bytes for the cipher suite,
+// or 0 if the suite is unknown.
+func (cs CipherSuite) KeySize() int {
+	switch cs {
+	case CipherSuiteAES256GCM:
+		return AESKeySize
+	case CipherSuiteChaCha20Poly1305:
+		return ChaCha20KeySize
+	case CipherSuiteAES128GCM:
+		return AES128KeySize
+	case CipherSuiteNullEncryption:
+		return NullEncryptionKeySize
+	default:
+		return 0
+	}
 }
 
 // IsFIPSApproved returns true if the cipher suite is FIPS 140-3 approved.
-// Currently only AES-256-GCM is FIPS approved; ChaCha20-Poly1305 is not.
+// AES-256-GCM and AES-128-GCM are FIPS approved; ChaCha20-Poly1305 and the
+// null encryption suite are not.
 func (cs CipherSuite) IsFIPSApproved() bool {
-	return cs == CipherSuiteAES256GCM
+	return cs == CipherSuiteAES256GCM || cs == CipherSuiteAES128GCM
 }
@@ -10,6 +10,8 @@ func TestCipherSuiteString(t *testing.T) {
 	}{
 		{CipherSuiteAES256GCM, "AES-256-GCM"},
 		{CipherSuiteChaCha20Poly1305, "ChaCha20-Poly1305"},
+		{CipherSuiteAES128GCM, "AES-128-GCM"},
+		{CipherSuiteNullEncryption, "NULL-HMAC-SHA256"},
 		{CipherSuite(0x9999), "Unknown"},
 	}
 
@@ -29,9 +31,11 @@ func TestCipherSuiteIsSupported(t *testing.T) {
 	}{
 		{CipherSuiteAES256GCM, true},
 		{CipherSuiteChaCha20Poly1305, true},
+		{CipherSuiteAES128GCM, true},
+		{CipherSuiteNullEncryption, true},
 		{CipherSuite(0x0000), false},
 		{CipherSuite(0xFFFF), false},
-		{CipherSuite(0x0003), false},
+		{CipherSuite(0x0004), false},
 	}
 
 	for _, tt := range tests {
@@ -161,11 +165,33 @@ func testDomainSeparators(t *testing.T) {
 
 // TestCipherSuiteUniqueness ensures cipher suite IDs are unique.
 func TestCipherSuiteUniqueness(t *testing.T) {
-	if CipherSuiteAES256GCM == CipherSuiteChaCha20Poly1305 {
+	if CipherSuiteAES256GCM == CipherSuiteChaCha20Poly1305 ||
+		CipherSuiteAES256GCM == CipherSuiteAES128GCM ||
+		CipherSuiteChaCha20Poly1305 == CipherSuiteAES128GCM {
 		t.Error("Cipher suite IDs must be unique")
 	}
 }
 
+// TestCipherSuiteKeySize tests KeySize method for CipherSuite.
+func TestCipherSuiteKeySize(t *testing.T) {
+	tests := []struct {
+		suite CipherSuite
+		want  int
+	}{
+		{CipherSuiteAES256GCM, 32},
+		{CipherSuiteChaCha20Poly1305, 32},
+		{CipherSuiteAES128GCM, 16},
+		{CipherSuite(0xFFFF), 0},
+	}
+
+	for _, tt := range tests {
+		got := tt.suite.KeySize()
+		if got != tt.want {
+			t.Errorf("CipherSuite(%d).KeySize() = %d, want %d", tt.suite, got, tt.want)
+		}
+	}
+}
+
 // TestCipherSuiteIsFIPSApproved tests IsFIPSApproved method for CipherSuite.
 func TestCipherSuiteIsFIPSApproved(t *testing.T) {
 	tests := []struct {
@@ -174,9 +200,11 @@ func TestCipherSuiteIsFIPSApproved(t *testing.T) {
 	}{
 		{CipherSuiteAES256GCM, true},         // AES-256-GCM is FIPS approved
 		{CipherSuiteChaCha20Poly1305, false}, // ChaCha20-Poly1305 is NOT FIPS approved
+		{CipherSuiteAES128GCM, true},         // AES-128-GCM is FIPS approved
+		{CipherSuiteNullEncryption, false},   // Null encryption is NOT FIPS approved
 		{CipherSuite(0x0000), false},         // Unknown suites are not approved
 		{CipherSuite(0xFFFF), false},         // Unknown suites are not approved
-		{CipherSuite(0x0003), false},         // Unknown suites are not approved
+		{CipherSuite(0x0004), false},         // Unknown suites are not approved
 	}
 
 	for _, tt := range tests {
@@ -189,7 +217,7 @@ func TestCipherSuiteIsFIPSApproved(t *testing.T) {
 
 // TestFIPSApprovedImpliesSupported verifies that all FIPS approved suites are also supported.
 func TestFIPSApprovedImpliesSupported(t *testing.T) {
-	suites := []CipherSuite{CipherSuiteAES256GCM, CipherSuiteChaCha20Poly1305}
+	suites := []CipherSuite{CipherSuiteAES256GCM, CipherSuiteChaCha20Poly1305, CipherSuiteAES128GCM}
 	for _, s := range suites {
 		if s.IsFIPSApproved() && !s.IsSupported() {
 			t.Errorf("CipherSuite %v is FIPS approved but not supported", s)
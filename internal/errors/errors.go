@@ -30,6 +30,22 @@ var (
 
 	// ErrInvalidPrivateKey indicates that a private key is invalid
 	ErrInvalidPrivateKey = errors.New("chkem: invalid private key")
+
+	// ErrInvalidPublicKeySize indicates a public key whose size matches no
+	// parameter set. It wraps ErrInvalidPublicKey.
+	ErrInvalidPublicKeySize = fmt.Errorf("%w: wrong size", ErrInvalidPublicKey)
+
+	// ErrInvalidCiphertextSize indicates a ciphertext whose size matches no
+	// parameter set. It wraps ErrInvalidCiphertext.
+	ErrInvalidCiphertextSize = fmt.Errorf("%w: wrong size", ErrInvalidCiphertext)
+
+	// ErrMalformedKey indicates a public key of the right size whose
+	// encoding is not a valid key. It wraps ErrInvalidPublicKey.
+	ErrMalformedKey = fmt.Errorf("%w: malformed encoding", ErrInvalidPublicKey)
+
+	// ErrRandReaderLocked indicates the random source cannot be replaced
+	// because FIPS mode only allows it to be set once
+	ErrRandReaderLocked = errors.New("crypto: random source already set in FIPS mode")
 )
 
 // Sentinel errors for AEAD operations
@@ -45,6 +61,12 @@ var (
 
 	// ErrNonceExhausted indicates nonce space is exhausted for the current key
 	ErrNonceExhausted = errors.New("aead: nonce space exhausted, rekey required")
+
+	// ErrStreamTruncated indicates an AEAD stream ended before its final frame
+	ErrStreamTruncated = errors.New("aead: stream truncated")
+
+	// ErrStreamClosed indicates a write to an AEAD stream after Close
+	ErrStreamClosed = errors.New("aead: stream closed")
 )
 
 // Sentinel errors for protocol operations
@@ -73,6 +95,9 @@ var (
 	// ErrReplayDetected indicates a potential replay attack
 	ErrReplayDetected = errors.New("protocol: replay detected")
 
+	// ErrUnsupportedKEMParameters indicates an unsupported CH-KEM parameter set
+	ErrUnsupportedKEMParameters = errors.New("protocol: unsupported KEM parameters")
+
 	// ErrCipherSuiteNotFIPSApproved indicates a cipher suite is not FIPS 140-3 approved
 	ErrCipherSuiteNotFIPSApproved = errors.New("protocol: cipher suite not FIPS approved")
 
@@ -81,6 +106,18 @@ var (
 
 	// ErrExpiredTicket indicates a session ticket has expired
 	ErrExpiredTicket = errors.New("protocol: expired ticket")
+
+	// ErrInvalidCookie indicates a HelloRetryRequest cookie is forged or malformed
+	ErrInvalidCookie = errors.New("protocol: invalid cookie")
+
+	// ErrExpiredCookie indicates a HelloRetryRequest cookie has expired
+	ErrExpiredCookie = errors.New("protocol: expired cookie")
+
+	// ErrUnauthorized indicates the responder's authorizer rejected the client
+	ErrUnauthorized = errors.New("protocol: client not authorized")
+
+	// ErrKeyCommitmentMismatch indicates the peers disagree on key-committing traffic ciphers
+	ErrKeyCommitmentMismatch = errors.New("protocol: key commitment mismatch")
 )
 
 // Sentinel errors for tunnel operations
@@ -88,14 +125,57 @@ var (
 	// ErrTunnelClosed indicates the tunnel has been closed
 	ErrTunnelClosed = errors.New("tunnel: connection closed")
 
+	// ErrConnectionReset indicates the connection was lost without the
+	// peer closing the tunnel
+	ErrConnectionReset = errors.New("tunnel: connection reset")
+
+	// ErrWriteClosed indicates a send after CloseWrite
+	ErrWriteClosed = errors.New("tunnel: write side closed")
+
 	// ErrRekeyRequired indicates a rekey operation is required
 	ErrRekeyRequired = errors.New("tunnel: rekey required")
 
 	// ErrRekeyInProgress indicates a rekey operation is already in progress
 	ErrRekeyInProgress = errors.New("tunnel: rekey already in progress")
 
+	// ErrUnexpectedRekey indicates a rekey message the current rekey state does not allow
+	ErrUnexpectedRekey = errors.New("tunnel: unexpected rekey message")
+
 	// ErrTimeout indicates an operation timed out
 	ErrTimeout = errors.New("tunnel: operation timed out")
+
+	// ErrKeepaliveTimeout indicates the peer stopped answering keepalive pings
+	ErrKeepaliveTimeout = errors.New("tunnel: keepalive timeout")
+
+	// ErrReorderBufferFull indicates too many records arrived ahead of a missing one
+	ErrReorderBufferFull = errors.New("tunnel: reorder buffer full")
+
+	// ErrUnsupportedCompression indicates an unknown compression setting
+	ErrUnsupportedCompression = errors.New("tunnel: unsupported compression")
+
+	// ErrInvalidRekeyPolicy indicates rekey thresholds beyond what the cipher suite allows
+	ErrInvalidRekeyPolicy = errors.New("tunnel: invalid rekey policy")
+
+	// ErrKeyPairConsumed indicates an ephemeral key pair was already used by another session
+	ErrKeyPairConsumed = errors.New("tunnel: key pair already used")
+
+	// ErrMuxStreamReset indicates a multiplexed stream was aborted by either peer
+	ErrMuxStreamReset = errors.New("tunnel: stream reset")
+
+	// ErrMuxStreamClosed indicates use of a multiplexed stream after Close
+	ErrMuxStreamClosed = errors.New("tunnel: stream closed")
+
+	// ErrMuxBacklogFull indicates the peer stopped reading while provoking more multiplexer frames than are queued
+	ErrMuxBacklogFull = errors.New("tunnel: multiplexer backlog full")
+
+	// ErrSequenceExhausted indicates a session sent its last sequence number and must be replaced
+	ErrSequenceExhausted = errors.New("tunnel: sequence numbers exhausted")
+
+	// ErrInvalidSessionExport indicates exported session state that is malformed or fails to authenticate
+	ErrInvalidSessionExport = errors.New("tunnel: invalid exported session")
+
+	// ErrReusePortUnsupported indicates SO_REUSEPORT listeners were requested on a platform without them
+	ErrReusePortUnsupported = errors.New("tunnel: SO_REUSEPORT not supported on this platform")
 )
 
 // Sentinel errors for connection pool operations
@@ -108,6 +188,12 @@ var (
 
 	// ErrPoolExhausted indicates the pool has no available connections
 	ErrPoolExhausted = errors.New("pool: no connections available")
+
+	// ErrPoolCircuitOpen indicates new connections are blocked after repeated dial failures
+	ErrPoolCircuitOpen = errors.New("pool: circuit breaker open")
+
+	// ErrPoolCloseTimeout indicates pooled connections did not close within the pool's CloseTimeout
+	ErrPoolCloseTimeout = errors.New("pool: connection close timed out")
 )
 
 // CryptoError wraps a cryptographic error with additional context
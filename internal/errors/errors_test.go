@@ -82,6 +82,14 @@ func TestIsFunction(t *testing.T) {
 	if Is(err, ErrInvalidCiphertext) {
 		t.Error("Is() should return false for non-matching error")
 	}
+
+	// Typed size and encoding errors match their general error
+	if !Is(ErrInvalidPublicKeySize, ErrInvalidPublicKey) || !Is(ErrMalformedKey, ErrInvalidPublicKey) {
+		t.Error("public key errors should match ErrInvalidPublicKey")
+	}
+	if !Is(ErrInvalidCiphertextSize, ErrInvalidCiphertext) || Is(ErrInvalidCiphertextSize, ErrInvalidPublicKey) {
+		t.Error("ErrInvalidCiphertextSize should only match ErrInvalidCiphertext")
+	}
 }
 
 // TestAsFunction tests the As helper function.
@@ -119,6 +127,9 @@ func TestSentinelErrors(t *testing.T) {
 		{"ErrEncapsulationFailed", ErrEncapsulationFailed},
 		{"ErrInvalidPublicKey", ErrInvalidPublicKey},
 		{"ErrInvalidPrivateKey", ErrInvalidPrivateKey},
+		{"ErrInvalidPublicKeySize", ErrInvalidPublicKeySize},
+		{"ErrInvalidCiphertextSize", ErrInvalidCiphertextSize},
+		{"ErrMalformedKey", ErrMalformedKey},
 		// AEAD errors
 		{"ErrAuthenticationFailed", ErrAuthenticationFailed},
 		{"ErrInvalidNonce", ErrInvalidNonce},
@@ -52,6 +52,21 @@
 // from random. In either case, the SHAKE-256 derivation produces a
 // computationally indistinguishable output (random oracle model).
 //
+// # Parameter Sets
+//
+// CH-KEM defaults to ML-KEM-1024 (CHKEM1024). CHKEM768 substitutes
+// ML-KEM-768 (NIST Category 3) for constrained devices, shrinking the public
+// key from 1600 to 1216 bytes and the ciphertext from 1600 to 1120 bytes.
+// The parameter set travels with every key and ciphertext, so Encapsulate
+// and Decapsulate need no extra arguments.
+//
+// # Analysis
+//
+// Building with the chkemdebug tag adds EncapsulateComponents and
+// DecapsulateComponents, which return the X25519 and ML-KEM shared secrets
+// and the transcript hash separately instead of combining them. They exist
+// for research tooling and must not be used to establish keys.
+//
 // # Compliance
 //
 // Components are based on:
@@ -66,39 +81,136 @@ package chkem
 
 import (
 	"crypto/ecdh"
+	"sync/atomic"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 )
 
-// KeyPair represents a CH-KEM key pair combining X25519 and ML-KEM-1024.
+// Parameters identifies a CH-KEM parameter set. The value is used on the wire
+// during handshake negotiation.
+type Parameters uint8
+
+// Supported CH-KEM parameter sets.
+const (
+	// CHKEM1024 combines X25519 with ML-KEM-1024 (NIST Category 5).
+	CHKEM1024 Parameters = 0x01
+	// CHKEM768 combines X25519 with ML-KEM-768 (NIST Category 3).
+	CHKEM768 Parameters = 0x02
+)
+
+// DefaultParameters is the parameter set used when none is specified.
+const DefaultParameters = CHKEM1024
+
+// String returns a human-readable name for the parameter set.
+func (p Parameters) String() string {
+	switch p {
+	case CHKEM1024:
+		return "CH-KEM-1024"
+	case CHKEM768:
+		return "CH-KEM-768"
+	default:
+		return "Unknown"
+	}
+}
+
+// IsSupported returns true if the parameter set is supported.
+func (p Parameters) IsSupported() bool {
+	return p == CHKEM1024 || p == CHKEM768
+}
+
+// PublicKeySize returns the serialized public key size, or 0 if unsupported.
+func (p Parameters) PublicKeySize() int {
+	switch p {
+	case CHKEM1024:
+		return constants.CHKEMPublicKeySize
+	case CHKEM768:
+		return constants.CHKEM768PublicKeySize
+	default:
+		return 0
+	}
+}
+
+// CiphertextSize returns the serialized ciphertext size, or 0 if unsupported.
+func (p Parameters) CiphertextSize() int {
+	switch p {
+	case CHKEM1024:
+		return constants.CHKEMCiphertextSize
+	case CHKEM768:
+		return constants.CHKEM768CiphertextSize
+	default:
+		return 0
+	}
+}
+
+// mlkemLevel returns the ML-KEM parameter set underlying p.
+func (p Parameters) mlkemLevel() crypto.MLKEMLevel {
+	if p == CHKEM768 {
+		return crypto.MLKEM768
+	}
+	return crypto.MLKEM1024
+}
+
+// parametersForPublicKeySize returns the parameter set whose public key has
+// the given serialized size.
+func parametersForPublicKeySize(n int) (Parameters, bool) {
+	for _, p := range []Parameters{CHKEM1024, CHKEM768} {
+		if p.PublicKeySize() == n {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// parametersForCiphertextSize returns the parameter set whose ciphertext has
+// the given serialized size.
+func parametersForCiphertextSize(n int) (Parameters, bool) {
+	for _, p := range []Parameters{CHKEM1024, CHKEM768} {
+		if p.CiphertextSize() == n {
+			return p, true
+		}
+	}
+	return 0, false
+}
+
+// KeyPair represents a CH-KEM key pair combining X25519 and ML-KEM.
 type KeyPair struct {
+	// Parameter set of the key pair
+	params Parameters
+
 	// X25519 key pair (classical)
 	x25519Public  *ecdh.PublicKey
 	x25519Private *ecdh.PrivateKey
 
-	// ML-KEM-1024 key pair (post-quantum)
+	// ML-KEM key pair (post-quantum)
 	mlkemPublic  *crypto.MLKEMPublicKey
 	mlkemPrivate *crypto.MLKEMPrivateKey
+
+	// Set once the key pair has been used as an ephemeral key
+	consumed atomic.Bool
 }
 
 // PublicKey represents a CH-KEM public key for encapsulation.
 type PublicKey struct {
+	params Parameters
 	x25519 *ecdh.PublicKey
 	mlkem  *crypto.MLKEMPublicKey
 }
 
 // Ciphertext represents a CH-KEM ciphertext.
 type Ciphertext struct {
+	params Parameters
+
 	// X25519 ephemeral public key (32 bytes)
 	x25519Ephemeral []byte
 
-	// ML-KEM-1024 ciphertext (1568 bytes)
+	// ML-KEM ciphertext (1568 bytes for ML-KEM-1024, 1088 for ML-KEM-768)
 	mlkemCiphertext []byte
 }
 
-// GenerateKeyPair generates a new CH-KEM key pair.
+// GenerateKeyPair generates a new CH-KEM key pair with the default
+// parameter set (CHKEM1024).
 //
 // This generates both X25519 and ML-KEM-1024 key pairs using the system's
 // cryptographically secure random number generator.
@@ -107,19 +219,30 @@ type Ciphertext struct {
 //   - KeyPair: The generated key pair
 //   - error: Non-nil if random number generation fails
 func GenerateKeyPair() (*KeyPair, error) {
+	return GenerateKeyPairWithParameters(DefaultParameters)
+}
+
+// GenerateKeyPairWithParameters generates a new CH-KEM key pair for the
+// given parameter set.
+func GenerateKeyPairWithParameters(params Parameters) (*KeyPair, error) {
+	if !params.IsSupported() {
+		return nil, qerrors.NewCryptoError("CHKEM.GenerateKeyPair", qerrors.ErrKeyGenerationFailed)
+	}
+
 	// Generate X25519 key pair
 	x25519KP, err := crypto.GenerateX25519KeyPair()
 	if err != nil {
 		return nil, qerrors.NewCryptoError("CHKEM.GenerateKeyPair", err)
 	}
 
-	// Generate ML-KEM-1024 key pair
-	mlkemKP, err := crypto.GenerateMLKEMKeyPair()
+	// Generate ML-KEM key pair
+	mlkemKP, err := crypto.GenerateMLKEMKeyPairLevel(params.mlkemLevel())
 	if err != nil {
 		return nil, qerrors.NewCryptoError("CHKEM.GenerateKeyPair", err)
 	}
 
 	return &KeyPair{
+		params:        params,
 		x25519Public:  x25519KP.PublicKey,
 		x25519Private: x25519KP.PrivateKey,
 		mlkemPublic:   mlkemKP.EncapsulationKey,
@@ -127,9 +250,28 @@ func GenerateKeyPair() (*KeyPair, error) {
 	}, nil
 }
 
+// Parameters returns the parameter set of the key pair.
+func (kp *KeyPair) Parameters() Parameters {
+	return kp.params
+}
+
+// Consume marks the key pair as used for a key exchange, and reports whether
+// it was unused until now. Ephemeral key pairs must be used only once: a key
+// pair shared by two sessions lets the compromise of one expose the other,
+// breaking forward secrecy.
+func (kp *KeyPair) Consume() bool {
+	return kp.consumed.CompareAndSwap(false, true)
+}
+
+// Consumed reports whether Consume has been called on the key pair.
+func (kp *KeyPair) Consumed() bool {
+	return kp.consumed.Load()
+}
+
 // PublicKey returns the public component of the key pair.
 func (kp *KeyPair) PublicKey() *PublicKey {
 	return &PublicKey{
+		params: kp.params,
 		x25519: kp.x25519Public,
 		mlkem:  kp.mlkemPublic,
 	}
@@ -140,7 +282,7 @@ func (kp *KeyPair) PublicKey() *PublicKey {
 // This operation:
 // 1. Generates an ephemeral X25519 key pair
 // 2. Performs X25519 DH with the recipient's public key
-// 3. Encapsulates using ML-KEM-1024
+// 3. Encapsulates using ML-KEM at the public key's parameter set
 // 4. Combines both secrets with transcript hash using SHAKE-256
 //
 // Parameters:
@@ -151,6 +293,57 @@ func (kp *KeyPair) PublicKey() *PublicKey {
 //   - sharedSecret: 32-byte derived shared secret
 //   - error: Non-nil if encapsulation fails
 func Encapsulate(recipientPublic *PublicKey) (*Ciphertext, []byte, error) {
+	ct, c, err := encapsulate(recipientPublic)
+	if err != nil {
+		return nil, nil, err
+	}
+	sharedSecret, err := c.derive()
+	if err != nil {
+		return nil, nil, err
+	}
+	return ct, sharedSecret, nil
+}
+
+// Decapsulate performs CH-KEM decapsulation to recover the shared secret.
+//
+// This operation:
+// 1. Performs X25519 DH with the ephemeral public key
+// 2. Decapsulates the ML-KEM ciphertext
+// 3. Combines both secrets with transcript hash using SHAKE-256
+//
+// Parameters:
+//   - ct: The ciphertext to decapsulate
+//   - kp: The recipient's key pair
+//
+// Returns:
+//   - sharedSecret: 32-byte derived shared secret (same as encapsulator)
+//   - error: Non-nil if decapsulation fails
+func Decapsulate(ct *Ciphertext, kp *KeyPair) ([]byte, error) {
+	c, err := decapsulate(ct, kp)
+	if err != nil {
+		return nil, err
+	}
+	return c.derive()
+}
+
+// components holds the inputs to the final CH-KEM key derivation.
+type components struct {
+	x25519Secret   []byte
+	mlkemSecret    []byte
+	transcriptHash []byte
+}
+
+// derive combines the components into the shared secret and zeroizes the
+// intermediate secrets.
+func (c *components) derive() ([]byte, error) {
+	// K = SHAKE-256(K_x25519 || K_mlkem || transcript, 256)
+	sharedSecret, err := crypto.DeriveCHKEMSecret(c.x25519Secret, c.mlkemSecret, c.transcriptHash)
+	crypto.ZeroizeMultiple(c.x25519Secret, c.mlkemSecret)
+	return sharedSecret, err
+}
+
+// encapsulate implements Encapsulate up to the final key derivation.
+func encapsulate(recipientPublic *PublicKey) (*Ciphertext, *components, error) {
 	if recipientPublic == nil || recipientPublic.x25519 == nil || recipientPublic.mlkem == nil {
 		return nil, nil, qerrors.ErrInvalidPublicKey
 	}
@@ -167,14 +360,16 @@ func Encapsulate(recipientPublic *PublicKey) (*Ciphertext, []byte, error) {
 		return nil, nil, qerrors.NewCryptoError("CHKEM.Encapsulate", err)
 	}
 
-	// Perform ML-KEM-1024 encapsulation
+	// Perform ML-KEM encapsulation
 	mlkemCiphertext, mlkemSecret, err := crypto.MLKEMEncapsulate(recipientPublic.mlkem)
 	if err != nil {
+		crypto.Zeroize(x25519Secret)
 		return nil, nil, qerrors.NewCryptoError("CHKEM.Encapsulate", err)
 	}
 
 	// Create ciphertext
 	ct := &Ciphertext{
+		params:          recipientPublic.params,
 		x25519Ephemeral: ephemeralKP.PublicKeyBytes(),
 		mlkemCiphertext: mlkemCiphertext,
 	}
@@ -188,43 +383,24 @@ func Encapsulate(recipientPublic *PublicKey) (*Ciphertext, []byte, error) {
 		ct.mlkemCiphertext,
 	)
 	if err != nil {
+		crypto.ZeroizeMultiple(x25519Secret, mlkemSecret)
 		return nil, nil, err
 	}
 
-	// Derive final shared secret
-	// K = SHAKE-256(K_x25519 || K_mlkem || transcript, 256)
-	sharedSecret, err := crypto.DeriveCHKEMSecret(x25519Secret, mlkemSecret, transcriptHash)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// Zeroize intermediate secrets
-	crypto.ZeroizeMultiple(x25519Secret, mlkemSecret)
-
-	return ct, sharedSecret, nil
+	return ct, &components{x25519Secret, mlkemSecret, transcriptHash}, nil
 }
 
-// Decapsulate performs CH-KEM decapsulation to recover the shared secret.
-//
-// This operation:
-// 1. Performs X25519 DH with the ephemeral public key
-// 2. Decapsulates the ML-KEM ciphertext
-// 3. Combines both secrets with transcript hash using SHAKE-256
-//
-// Parameters:
-//   - ct: The ciphertext to decapsulate
-//   - kp: The recipient's key pair
-//
-// Returns:
-//   - sharedSecret: 32-byte derived shared secret (same as encapsulator)
-//   - error: Non-nil if decapsulation fails
-func Decapsulate(ct *Ciphertext, kp *KeyPair) ([]byte, error) {
+// decapsulate implements Decapsulate up to the final key derivation.
+func decapsulate(ct *Ciphertext, kp *KeyPair) (*components, error) {
 	if ct == nil || len(ct.x25519Ephemeral) == 0 || len(ct.mlkemCiphertext) == 0 {
 		return nil, qerrors.ErrInvalidCiphertext
 	}
 	if kp == nil || kp.x25519Private == nil || kp.mlkemPrivate == nil {
 		return nil, qerrors.ErrInvalidPrivateKey
 	}
+	if ct.params != kp.params {
+		return nil, qerrors.ErrInvalidCiphertext
+	}
 
 	// Parse X25519 ephemeral public key
 	ephemeralPublic, err := crypto.ParseX25519PublicKey(ct.x25519Ephemeral)
@@ -238,9 +414,10 @@ func Decapsulate(ct *Ciphertext, kp *KeyPair) ([]byte, error) {
 		return nil, qerrors.NewCryptoError("CHKEM.Decapsulate", err)
 	}
 
-	// Perform ML-KEM-1024 decapsulation
+	// Perform ML-KEM decapsulation
 	mlkemSecret, err := crypto.MLKEMDecapsulate(kp.mlkemPrivate, ct.mlkemCiphertext)
 	if err != nil {
+		crypto.Zeroize(x25519Secret)
 		return nil, qerrors.NewCryptoError("CHKEM.Decapsulate", err)
 	}
 
@@ -252,72 +429,115 @@ func Decapsulate(ct *Ciphertext, kp *KeyPair) ([]byte, error) {
 		ct.mlkemCiphertext,
 	)
 	if err != nil {
+		crypto.ZeroizeMultiple(x25519Secret, mlkemSecret)
 		return nil, err
 	}
 
-	// Derive final shared secret
-	sharedSecret, err := crypto.DeriveCHKEMSecret(x25519Secret, mlkemSecret, transcriptHash)
-	if err != nil {
-		return nil, err
-	}
-
-	// Zeroize intermediate secrets
-	crypto.ZeroizeMultiple(x25519Secret, mlkemSecret)
-
-	return sharedSecret, nil
+	return &components{x25519Secret, mlkemSecret, transcriptHash}, nil
 }
 
 // Bytes serializes the public key to bytes.
 //
-// Format: x25519_public (32 bytes) || mlkem_public (1568 bytes)
-// Total: 1600 bytes
+// Format: x25519_public (32 bytes) || mlkem_public (1568 or 1184 bytes)
+// Total: 1600 bytes (CHKEM1024) or 1216 bytes (CHKEM768)
 func (pk *PublicKey) Bytes() []byte {
-	result := make([]byte, constants.CHKEMPublicKeySize)
+	result := make([]byte, pk.params.PublicKeySize())
 	copy(result[:constants.X25519PublicKeySize], pk.x25519.Bytes())
 	copy(result[constants.X25519PublicKeySize:], pk.mlkem.Bytes())
 	return result
 }
 
-// ParsePublicKey parses a CH-KEM public key from bytes.
+// Parameters returns the parameter set of the public key.
+func (pk *PublicKey) Parameters() Parameters {
+	return pk.params
+}
+
+// ParsePublicKey parses a CH-KEM public key from bytes. The parameter set
+// is determined by the length of data.
 func ParsePublicKey(data []byte) (*PublicKey, error) {
-	if len(data) != constants.CHKEMPublicKeySize {
-		return nil, qerrors.ErrInvalidPublicKey
+	params, ok := parametersForPublicKeySize(len(data))
+	if !ok {
+		return nil, qerrors.ErrInvalidPublicKeySize
 	}
 
 	x25519Public, err := crypto.ParseX25519PublicKey(data[:constants.X25519PublicKeySize])
 	if err != nil {
-		return nil, err
+		return nil, qerrors.NewCryptoError("ParsePublicKey", qerrors.ErrMalformedKey)
 	}
 
-	mlkemPublic, err := crypto.ParseMLKEMPublicKey(data[constants.X25519PublicKeySize:])
+	mlkemPublic, err := crypto.ParseMLKEMPublicKeyLevel(params.mlkemLevel(), data[constants.X25519PublicKeySize:])
 	if err != nil {
-		return nil, err
+		return nil, qerrors.NewCryptoError("ParsePublicKey", qerrors.ErrMalformedKey)
 	}
 
 	return &PublicKey{
+		params: params,
 		x25519: x25519Public,
 		mlkem:  mlkemPublic,
 	}, nil
 }
 
+// FingerprintSize is the size of a public key fingerprint in bytes.
+const FingerprintSize = 32
+
+// Fingerprint returns a stable digest of the public key for pinning:
+//
+//	fingerprint = SHA3-256(domain || pk_x || pk_m)
+//
+// computed with crypto.TranscriptHash, so each component is length-prefixed.
+func (pk *PublicKey) Fingerprint() [FingerprintSize]byte {
+	var fp [FingerprintSize]byte
+	// TranscriptHash only fails for components over 4 GiB
+	digest, _ := crypto.TranscriptHash(
+		[]byte(constants.DomainSeparatorFingerprint),
+		pk.x25519.Bytes(),
+		pk.mlkem.Bytes(),
+	)
+	copy(fp[:], digest)
+	return fp
+}
+
+// FingerprintString returns the fingerprint as colon-separated lowercase
+// hex, e.g. "3f:a2:...:9c".
+func (pk *PublicKey) FingerprintString() string {
+	fp := pk.Fingerprint()
+	const hexDigits = "0123456789abcdef"
+	buf := make([]byte, 0, 3*len(fp)-1)
+	for i, b := range fp {
+		if i > 0 {
+			buf = append(buf, ':')
+		}
+		buf = append(buf, hexDigits[b>>4], hexDigits[b&0x0f])
+	}
+	return string(buf)
+}
+
 // Bytes serializes the ciphertext to bytes.
 //
-// Format: x25519_ephemeral (32 bytes) || mlkem_ciphertext (1568 bytes)
-// Total: 1600 bytes
+// Format: x25519_ephemeral (32 bytes) || mlkem_ciphertext (1568 or 1088 bytes)
+// Total: 1600 bytes (CHKEM1024) or 1120 bytes (CHKEM768)
 func (ct *Ciphertext) Bytes() []byte {
-	result := make([]byte, constants.CHKEMCiphertextSize)
+	result := make([]byte, ct.params.CiphertextSize())
 	copy(result[:constants.X25519PublicKeySize], ct.x25519Ephemeral)
 	copy(result[constants.X25519PublicKeySize:], ct.mlkemCiphertext)
 	return result
 }
 
-// ParseCiphertext parses a CH-KEM ciphertext from bytes.
+// Parameters returns the parameter set of the ciphertext.
+func (ct *Ciphertext) Parameters() Parameters {
+	return ct.params
+}
+
+// ParseCiphertext parses a CH-KEM ciphertext from bytes. The parameter set
+// is determined by the length of data.
 func ParseCiphertext(data []byte) (*Ciphertext, error) {
-	if len(data) != constants.CHKEMCiphertextSize {
-		return nil, qerrors.ErrInvalidCiphertext
+	params, ok := parametersForCiphertextSize(len(data))
+	if !ok {
+		return nil, qerrors.ErrInvalidCiphertextSize
 	}
 
 	return &Ciphertext{
+		params:          params,
 		x25519Ephemeral: data[:constants.X25519PublicKeySize],
 		mlkemCiphertext: data[constants.X25519PublicKeySize:],
 	}, nil
@@ -334,6 +554,7 @@ func (kp *KeyPair) Zeroize() {
 // Clone creates a deep copy of the public key.
 func (pk *PublicKey) Clone() *PublicKey {
 	return &PublicKey{
+		params: pk.params,
 		x25519: pk.x25519,
 		mlkem:  pk.mlkem,
 	}
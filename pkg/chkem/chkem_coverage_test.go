@@ -1,9 +1,11 @@
 package chkem
 
 import (
+	"errors"
 	"testing"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 )
 
 func TestEncapsulateInvalidKey(t *testing.T) {
@@ -16,15 +18,43 @@ func TestEncapsulateInvalidKey(t *testing.T) {
 
 func TestParsePublicKeyInvalidSize(t *testing.T) {
 	_, err := ParsePublicKey(make([]byte, 10))
-	if err == nil {
-		t.Error("expected error for invalid public key size")
+	if !errors.Is(err, qerrors.ErrInvalidPublicKeySize) {
+		t.Errorf("expected ErrInvalidPublicKeySize, got %v", err)
+	}
+	// The typed error still matches the general one
+	if !errors.Is(err, qerrors.ErrInvalidPublicKey) || errors.Is(err, qerrors.ErrMalformedKey) {
+		t.Errorf("ErrInvalidPublicKeySize should only wrap ErrInvalidPublicKey, got %v", err)
+	}
+}
+
+func TestParsePublicKeyMalformed(t *testing.T) {
+	kp, err := GenerateKeyPairWithParameters(CHKEM768)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairWithParameters failed: %v", err)
+	}
+
+	// ML-KEM coefficients must be reduced modulo q, so a key of the right
+	// size filled with 0xFF does not decode
+	data := kp.PublicKey().Bytes()
+	for i := constants.X25519PublicKeySize; i < len(data); i++ {
+		data[i] = 0xFF
+	}
+	_, err = ParsePublicKey(data)
+	if !errors.Is(err, qerrors.ErrMalformedKey) || errors.Is(err, qerrors.ErrInvalidPublicKeySize) {
+		t.Errorf("expected ErrMalformedKey, got %v", err)
 	}
 }
 
 func TestParseCiphertextInvalidSize(t *testing.T) {
 	_, err := ParseCiphertext(make([]byte, 10))
-	if err == nil {
-		t.Error("expected error for invalid ciphertext size")
+	if !errors.Is(err, qerrors.ErrInvalidCiphertextSize) || !errors.Is(err, qerrors.ErrInvalidCiphertext) {
+		t.Errorf("expected ErrInvalidCiphertextSize, got %v", err)
+	}
+
+	// No ciphertext has the size of a CH-KEM-768 public key
+	kp, _ := GenerateKeyPairWithParameters(CHKEM768)
+	if _, err := ParseCiphertext(kp.PublicKey().Bytes()); !errors.Is(err, qerrors.ErrInvalidCiphertextSize) {
+		t.Errorf("CH-KEM-768 public key as ciphertext: expected ErrInvalidCiphertextSize, got %v", err)
 	}
 }
 
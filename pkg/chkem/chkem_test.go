@@ -2,6 +2,7 @@ package chkem_test
 
 import (
 	"bytes"
+	"encoding/hex"
 	"testing"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
@@ -314,3 +315,95 @@ func TestDifferentKeyPairsDifferentSecrets(t *testing.T) {
 		t.Error("Different recipients should produce different shared secrets")
 	}
 }
+
+func TestCHKEM768RoundTrip(t *testing.T) {
+	kp, err := chkem.GenerateKeyPairWithParameters(chkem.CHKEM768)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairWithParameters failed: %v", err)
+	}
+	if kp.Parameters() != chkem.CHKEM768 {
+		t.Errorf("Parameters: got %v, want %v", kp.Parameters(), chkem.CHKEM768)
+	}
+
+	pkBytes := kp.PublicKey().Bytes()
+	if len(pkBytes) != constants.CHKEM768PublicKeySize {
+		t.Errorf("Public key size: got %d, want %d", len(pkBytes), constants.CHKEM768PublicKeySize)
+	}
+
+	// Parameters are recovered from the encoded length
+	pk, err := chkem.ParsePublicKey(pkBytes)
+	if err != nil {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	if pk.Parameters() != chkem.CHKEM768 {
+		t.Errorf("Parsed parameters: got %v, want %v", pk.Parameters(), chkem.CHKEM768)
+	}
+
+	ct, secretEnc, err := chkem.Encapsulate(pk)
+	if err != nil {
+		t.Fatalf("Encapsulate failed: %v", err)
+	}
+	ctBytes := ct.Bytes()
+	if len(ctBytes) != constants.CHKEM768CiphertextSize {
+		t.Errorf("Ciphertext size: got %d, want %d", len(ctBytes), constants.CHKEM768CiphertextSize)
+	}
+
+	parsedCT, err := chkem.ParseCiphertext(ctBytes)
+	if err != nil {
+		t.Fatalf("ParseCiphertext failed: %v", err)
+	}
+	secretDec, err := chkem.Decapsulate(parsedCT, kp)
+	if err != nil {
+		t.Fatalf("Decapsulate failed: %v", err)
+	}
+	if !bytes.Equal(secretEnc, secretDec) {
+		t.Error("Shared secrets do not match")
+	}
+}
+
+func TestCHKEMParameterMismatch(t *testing.T) {
+	kp1024, _ := chkem.GenerateKeyPair()
+	kp768, _ := chkem.GenerateKeyPairWithParameters(chkem.CHKEM768)
+
+	ct, _, err := chkem.Encapsulate(kp768.PublicKey())
+	if err != nil {
+		t.Fatalf("Encapsulate failed: %v", err)
+	}
+	if _, err := chkem.Decapsulate(ct, kp1024); err == nil {
+		t.Error("expected error decapsulating CH-KEM-768 ciphertext with CH-KEM-1024 key")
+	}
+
+	if _, err := chkem.GenerateKeyPairWithParameters(chkem.Parameters(0x7F)); err == nil {
+		t.Error("expected error for unsupported parameters")
+	}
+}
+
+func TestPublicKeyFingerprint(t *testing.T) {
+	kp1, _ := chkem.GenerateKeyPair()
+	kp2, _ := chkem.GenerateKeyPair()
+
+	// Deterministic across calls and serialization
+	fp := kp1.PublicKey().Fingerprint()
+	if fp != kp1.PublicKey().Fingerprint() {
+		t.Error("fingerprint is not deterministic")
+	}
+	parsed, err := chkem.ParsePublicKey(kp1.PublicKey().Bytes())
+	if err != nil {
+		t.Fatalf("ParsePublicKey failed: %v", err)
+	}
+	if parsed.Fingerprint() != fp {
+		t.Error("fingerprint changed after parsing")
+	}
+
+	if kp2.PublicKey().Fingerprint() == fp {
+		t.Error("distinct keys produced the same fingerprint")
+	}
+
+	s := kp1.PublicKey().FingerprintString()
+	if len(s) != 3*chkem.FingerprintSize-1 {
+		t.Errorf("unexpected fingerprint string length %d: %q", len(s), s)
+	}
+	if want := hex.EncodeToString(fp[:1]) + ":" + hex.EncodeToString(fp[1:2]) + ":"; s[:6] != want {
+		t.Errorf("expected fingerprint string to start with %q, got %q", want, s)
+	}
+}
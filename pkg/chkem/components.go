@@ -0,0 +1,56 @@
+//go:build chkemdebug
+
+package chkem
+
+// This file is only built with the chkemdebug build tag. It exposes the
+// intermediate secrets of CH-KEM for analysis of the hybrid construction,
+// and must not be used to establish keys: either component alone only
+// carries the security of one of the two KEMs.
+//
+//	go test -tags chkemdebug ./pkg/chkem/
+
+// Components holds the inputs to the final CH-KEM key derivation. Passing
+// them to crypto.DeriveCHKEMSecret yields the shared secret that
+// Encapsulate and Decapsulate return.
+type Components struct {
+	// X25519Secret is the 32-byte X25519 shared secret (K_x).
+	X25519Secret []byte
+
+	// MLKEMSecret is the 32-byte ML-KEM shared secret (K_m).
+	MLKEMSecret []byte
+
+	// TranscriptHash is SHA3-256 over the recipient public key and the
+	// ciphertext, binding the shared secret to both.
+	TranscriptHash []byte
+}
+
+// EncapsulateComponents performs CH-KEM encapsulation like Encapsulate, but
+// returns the individual secrets instead of combining them. The caller is
+// responsible for zeroizing them.
+func EncapsulateComponents(recipientPublic *PublicKey) (*Ciphertext, *Components, error) {
+	ct, c, err := encapsulate(recipientPublic)
+	if err != nil {
+		return nil, nil, err
+	}
+	return ct, c.export(), nil
+}
+
+// DecapsulateComponents performs CH-KEM decapsulation like Decapsulate, but
+// returns the individual secrets instead of combining them. The caller is
+// responsible for zeroizing them.
+func DecapsulateComponents(ct *Ciphertext, kp *KeyPair) (*Components, error) {
+	c, err := decapsulate(ct, kp)
+	if err != nil {
+		return nil, err
+	}
+	return c.export(), nil
+}
+
+// export converts c to its exported form.
+func (c *components) export() *Components {
+	return &Components{
+		X25519Secret:   c.x25519Secret,
+		MLKEMSecret:    c.mlkemSecret,
+		TranscriptHash: c.transcriptHash,
+	}
+}
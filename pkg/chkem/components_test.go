@@ -0,0 +1,98 @@
+//go:build chkemdebug
+
+package chkem_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+func TestComponentsReproduceSharedSecret(t *testing.T) {
+	for _, params := range []chkem.Parameters{chkem.CHKEM1024, chkem.CHKEM768} {
+		t.Run(params.String(), func(t *testing.T) {
+			kp, err := chkem.GenerateKeyPairWithParameters(params)
+			if err != nil {
+				t.Fatalf("GenerateKeyPairWithParameters failed: %v", err)
+			}
+
+			ct, enc, err := chkem.EncapsulateComponents(kp.PublicKey())
+			if err != nil {
+				t.Fatalf("EncapsulateComponents failed: %v", err)
+			}
+			dec, err := chkem.DecapsulateComponents(ct, kp)
+			if err != nil {
+				t.Fatalf("DecapsulateComponents failed: %v", err)
+			}
+			if !bytes.Equal(enc.X25519Secret, dec.X25519Secret) ||
+				!bytes.Equal(enc.MLKEMSecret, dec.MLKEMSecret) ||
+				!bytes.Equal(enc.TranscriptHash, dec.TranscriptHash) {
+				t.Fatal("encapsulation and decapsulation components differ")
+			}
+			if bytes.Equal(enc.X25519Secret, enc.MLKEMSecret) {
+				t.Error("X25519 and ML-KEM secrets are identical")
+			}
+
+			// The components combine into the shared secret of the normal API
+			combined, err := crypto.DeriveCHKEMSecret(enc.X25519Secret, enc.MLKEMSecret, enc.TranscriptHash)
+			if err != nil {
+				t.Fatalf("DeriveCHKEMSecret failed: %v", err)
+			}
+			sharedSecret, err := chkem.Decapsulate(ct, kp)
+			if err != nil {
+				t.Fatalf("Decapsulate failed: %v", err)
+			}
+			if !bytes.Equal(combined, sharedSecret) {
+				t.Error("components do not reproduce the Decapsulate shared secret")
+			}
+		})
+	}
+}
+
+func TestComponentsMatchEncapsulate(t *testing.T) {
+	if crypto.FIPSMode() {
+		t.Skip("random source can only be set once in FIPS mode")
+	}
+	kp, err := chkem.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+
+	// Replay the same randomness through both encapsulation paths
+	encapsulate := func(f func() (*chkem.Ciphertext, []byte)) (*chkem.Ciphertext, []byte) {
+		t.Helper()
+		if err := crypto.SetRandReader(crypto.DeterministicReader([]byte("chkem components"))); err != nil {
+			t.Fatalf("SetRandReader failed: %v", err)
+		}
+		defer func() { _ = crypto.SetRandReader(nil) }()
+		return f()
+	}
+
+	ct, sharedSecret := encapsulate(func() (*chkem.Ciphertext, []byte) {
+		ct, ss, err := chkem.Encapsulate(kp.PublicKey())
+		if err != nil {
+			t.Fatalf("Encapsulate failed: %v", err)
+		}
+		return ct, ss
+	})
+	componentsCT, combined := encapsulate(func() (*chkem.Ciphertext, []byte) {
+		ct, c, err := chkem.EncapsulateComponents(kp.PublicKey())
+		if err != nil {
+			t.Fatalf("EncapsulateComponents failed: %v", err)
+		}
+		ss, err := crypto.DeriveCHKEMSecret(c.X25519Secret, c.MLKEMSecret, c.TranscriptHash)
+		if err != nil {
+			t.Fatalf("DeriveCHKEMSecret failed: %v", err)
+		}
+		return ct, ss
+	})
+
+	if !bytes.Equal(ct.Bytes(), componentsCT.Bytes()) {
+		t.Fatal("ciphertexts differ under the same randomness")
+	}
+	if !bytes.Equal(sharedSecret, combined) {
+		t.Error("components do not reproduce the Encapsulate shared secret")
+	}
+}
@@ -0,0 +1,163 @@
+package chkem
+
+import (
+	"encoding/binary"
+	"encoding/pem"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+// PEM block types for CH-KEM keys.
+const (
+	// PEMTypePrivateKey is the PEM block type of a serialized key pair.
+	PEMTypePrivateKey = "CH-KEM PRIVATE KEY"
+
+	// PEMTypePublicKey is the PEM block type of a serialized public key.
+	PEMTypePublicKey = "CH-KEM PUBLIC KEY"
+)
+
+// MarshalPEM serializes the key pair, including its private keys, as a
+// "CH-KEM PRIVATE KEY" PEM block.
+//
+// Block contents: params (1) || len (2) || x25519_private || len (2) || mlkem_private
+//
+// The result contains secret key material and should be zeroized by the
+// caller once written. A zeroized key pair cannot be marshaled.
+func (kp *KeyPair) MarshalPEM() ([]byte, error) {
+	if kp.x25519Private == nil || kp.mlkemPrivate == nil {
+		return nil, qerrors.ErrInvalidPrivateKey
+	}
+
+	mlkemPrivate, err := kp.mlkemPrivate.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Zeroize(mlkemPrivate)
+
+	x25519Private := kp.x25519Private.Bytes()
+	defer crypto.Zeroize(x25519Private)
+
+	body := encodePEMBody(kp.params, x25519Private, mlkemPrivate)
+	defer crypto.Zeroize(body)
+
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypePrivateKey, Bytes: body}), nil
+}
+
+// ParseKeyPairPEM parses a key pair from a "CH-KEM PRIVATE KEY" PEM block.
+// The decoded key material is zeroized after parsing; call Zeroize on the
+// returned key pair when it is no longer needed.
+func ParseKeyPairPEM(data []byte) (*KeyPair, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != PEMTypePrivateKey {
+		return nil, qerrors.ErrInvalidPrivateKey
+	}
+	defer crypto.Zeroize(block.Bytes)
+
+	params, x25519Private, mlkemPrivate, ok := decodePEMBody(block.Bytes)
+	if !ok || len(x25519Private) != constants.X25519PrivateKeySize {
+		return nil, qerrors.ErrInvalidPrivateKey
+	}
+
+	x25519KP, err := crypto.NewX25519KeyPairFromBytes(x25519Private)
+	if err != nil {
+		return nil, err
+	}
+
+	mlkemSK, err := crypto.ParseMLKEMPrivateKeyLevel(params.mlkemLevel(), mlkemPrivate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeyPair{
+		params:        params,
+		x25519Public:  x25519KP.PublicKey,
+		x25519Private: x25519KP.PrivateKey,
+		mlkemPublic:   mlkemSK.Public(),
+		mlkemPrivate:  mlkemSK,
+	}, nil
+}
+
+// MarshalPEM serializes the public key as a "CH-KEM PUBLIC KEY" PEM block.
+//
+// Block contents: params (1) || len (2) || x25519_public || len (2) || mlkem_public
+func (pk *PublicKey) MarshalPEM() ([]byte, error) {
+	if pk.x25519 == nil || pk.mlkem == nil {
+		return nil, qerrors.ErrInvalidPublicKey
+	}
+
+	body := encodePEMBody(pk.params, pk.x25519.Bytes(), pk.mlkem.Bytes())
+	return pem.EncodeToMemory(&pem.Block{Type: PEMTypePublicKey, Bytes: body}), nil
+}
+
+// ParsePublicKeyPEM parses a public key from a "CH-KEM PUBLIC KEY" PEM block.
+func ParsePublicKeyPEM(data []byte) (*PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != PEMTypePublicKey {
+		return nil, qerrors.ErrInvalidPublicKey
+	}
+
+	params, x25519Public, mlkemPublic, ok := decodePEMBody(block.Bytes)
+	if !ok || len(x25519Public) != constants.X25519PublicKeySize {
+		return nil, qerrors.ErrInvalidPublicKey
+	}
+
+	encoded := make([]byte, 0, len(x25519Public)+len(mlkemPublic))
+	encoded = append(encoded, x25519Public...)
+	pk, err := ParsePublicKey(append(encoded, mlkemPublic...))
+	if err != nil {
+		return nil, err
+	}
+	if pk.params != params {
+		return nil, qerrors.ErrInvalidPublicKey
+	}
+	return pk, nil
+}
+
+// encodePEMBody frames the X25519 and ML-KEM components of a key.
+func encodePEMBody(params Parameters, x25519Key, mlkemKey []byte) []byte {
+	body := make([]byte, 0, 1+2+len(x25519Key)+2+len(mlkemKey))
+	body = append(body, byte(params))
+	//nolint:gosec // G115: key components are far smaller than 64 KiB
+	body = binary.BigEndian.AppendUint16(body, uint16(len(x25519Key)))
+	body = append(body, x25519Key...)
+	//nolint:gosec // G115: key components are far smaller than 64 KiB
+	body = binary.BigEndian.AppendUint16(body, uint16(len(mlkemKey)))
+	return append(body, mlkemKey...)
+}
+
+// decodePEMBody splits a framed key into its parameter set and components.
+// The components alias body.
+func decodePEMBody(body []byte) (Parameters, []byte, []byte, bool) {
+	if len(body) < 1 {
+		return 0, nil, nil, false
+	}
+	params := Parameters(body[0])
+	if !params.IsSupported() {
+		return 0, nil, nil, false
+	}
+	rest := body[1:]
+
+	x25519Key, rest, ok := readPEMField(rest)
+	if !ok {
+		return 0, nil, nil, false
+	}
+	mlkemKey, rest, ok := readPEMField(rest)
+	if !ok || len(rest) != 0 {
+		return 0, nil, nil, false
+	}
+	return params, x25519Key, mlkemKey, true
+}
+
+// readPEMField reads a 2-byte length-prefixed field.
+func readPEMField(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 2 {
+		return nil, nil, false
+	}
+	n := int(binary.BigEndian.Uint16(data))
+	if len(data) < 2+n {
+		return nil, nil, false
+	}
+	return data[2 : 2+n], data[2+n:], true
+}
@@ -0,0 +1,135 @@
+package chkem_test
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"testing"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
+)
+
+func TestKeyPairPEMRoundTrip(t *testing.T) {
+	for _, params := range []chkem.Parameters{chkem.CHKEM1024, chkem.CHKEM768} {
+		t.Run(params.String(), func(t *testing.T) {
+			kp, err := chkem.GenerateKeyPairWithParameters(params)
+			if err != nil {
+				t.Fatalf("GenerateKeyPair failed: %v", err)
+			}
+
+			encoded, err := kp.MarshalPEM()
+			if err != nil {
+				t.Fatalf("MarshalPEM failed: %v", err)
+			}
+			if !bytes.HasPrefix(encoded, []byte("-----BEGIN "+chkem.PEMTypePrivateKey+"-----")) {
+				t.Errorf("unexpected PEM header: %q", encoded[:40])
+			}
+
+			parsed, err := chkem.ParseKeyPairPEM(encoded)
+			if err != nil {
+				t.Fatalf("ParseKeyPairPEM failed: %v", err)
+			}
+			if parsed.Parameters() != params {
+				t.Errorf("expected parameters %v, got %v", params, parsed.Parameters())
+			}
+			if !bytes.Equal(parsed.PublicKey().Bytes(), kp.PublicKey().Bytes()) {
+				t.Error("parsed public key does not match original")
+			}
+
+			// The parsed private key decapsulates secrets for the original
+			ct, ss1, err := chkem.Encapsulate(kp.PublicKey())
+			if err != nil {
+				t.Fatalf("Encapsulate failed: %v", err)
+			}
+			ss2, err := chkem.Decapsulate(ct, parsed)
+			if err != nil {
+				t.Fatalf("Decapsulate failed: %v", err)
+			}
+			if !bytes.Equal(ss1, ss2) {
+				t.Error("shared secrets do not match after PEM round trip")
+			}
+
+			// A zeroized key pair refuses to be marshaled
+			parsed.Zeroize()
+			if _, err := parsed.MarshalPEM(); !errors.Is(err, qerrors.ErrInvalidPrivateKey) {
+				t.Errorf("expected ErrInvalidPrivateKey for zeroized key pair, got %v", err)
+			}
+		})
+	}
+}
+
+func TestPublicKeyPEMRoundTrip(t *testing.T) {
+	for _, params := range []chkem.Parameters{chkem.CHKEM1024, chkem.CHKEM768} {
+		t.Run(params.String(), func(t *testing.T) {
+			kp, err := chkem.GenerateKeyPairWithParameters(params)
+			if err != nil {
+				t.Fatalf("GenerateKeyPair failed: %v", err)
+			}
+
+			encoded, err := kp.PublicKey().MarshalPEM()
+			if err != nil {
+				t.Fatalf("MarshalPEM failed: %v", err)
+			}
+
+			parsed, err := chkem.ParsePublicKeyPEM(encoded)
+			if err != nil {
+				t.Fatalf("ParsePublicKeyPEM failed: %v", err)
+			}
+			if parsed.Parameters() != params {
+				t.Errorf("expected parameters %v, got %v", params, parsed.Parameters())
+			}
+			if !bytes.Equal(parsed.Bytes(), kp.PublicKey().Bytes()) {
+				t.Error("parsed public key does not match original")
+			}
+		})
+	}
+}
+
+func TestPEMCorrupt(t *testing.T) {
+	kp, err := chkem.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	privatePEM, _ := kp.MarshalPEM()
+	publicPEM, _ := kp.PublicKey().MarshalPEM()
+
+	// reencode decodes a PEM block, applies fn to its contents, and encodes it again
+	reencode := func(data []byte, fn func([]byte) []byte) []byte {
+		block, _ := pem.Decode(data)
+		block.Bytes = fn(append([]byte(nil), block.Bytes...))
+		return pem.EncodeToMemory(block)
+	}
+	truncate := func(b []byte) []byte { return b[:len(b)-10] }
+	badParams := func(b []byte) []byte { b[0] = 0xFF; return b }
+	badLength := func(b []byte) []byte { b[1], b[2] = 0xFF, 0xFF; return b }
+	trailing := func(b []byte) []byte { return append(b, 0) }
+
+	privateCases := map[string][]byte{
+		"not PEM":        []byte("not a key"),
+		"truncated PEM":  privatePEM[:len(privatePEM)/2],
+		"wrong type":     publicPEM,
+		"truncated body": reencode(privatePEM, truncate),
+		"bad params":     reencode(privatePEM, badParams),
+		"bad length":     reencode(privatePEM, badLength),
+		"trailing data":  reencode(privatePEM, trailing),
+	}
+	for name, data := range privateCases {
+		if _, err := chkem.ParseKeyPairPEM(data); !errors.Is(err, qerrors.ErrInvalidPrivateKey) {
+			t.Errorf("private %s: expected ErrInvalidPrivateKey, got %v", name, err)
+		}
+	}
+
+	publicCases := map[string][]byte{
+		"not PEM":        []byte("not a key"),
+		"wrong type":     privatePEM,
+		"truncated body": reencode(publicPEM, truncate),
+		"bad params":     reencode(publicPEM, badParams),
+		"trailing data":  reencode(publicPEM, trailing),
+	}
+	for name, data := range publicCases {
+		if _, err := chkem.ParsePublicKeyPEM(data); !errors.Is(err, qerrors.ErrInvalidPublicKey) {
+			t.Errorf("public %s: expected ErrInvalidPublicKey, got %v", name, err)
+		}
+	}
+}
@@ -1,9 +1,13 @@
 // Package crypto implements Authenticated Encryption with Associated Data (AEAD).
 //
-// This file (aead.go) supports two AEAD algorithms:
+// This file (aead.go) supports three AEAD algorithms:
 //   - AES-256-GCM: FIPS-approved, hardware-accelerated on modern CPUs
+//   - AES-128-GCM: FIPS-approved, for peers constrained to 128-bit keys
 //   - ChaCha20-Poly1305: High performance without hardware support
 //
+// plus the null encryption suite of null.go, which authenticates without
+// encrypting.
+//
 // Mathematical Foundation:
 //
 // AES-256-GCM:
@@ -12,6 +16,10 @@
 //   - Security: IND-CCA2 secure, 128-bit authentication tag
 //   - Nonce: 96-bit, MUST be unique per (key, plaintext) pair
 //
+// AES-128-GCM:
+//   - Identical construction to AES-256-GCM with a 128-bit key
+//   - Security: 128-bit classical, ~64-bit against Grover's algorithm
+//
 // ChaCha20-Poly1305:
 //   - ChaCha20: Stream cipher with 256-bit key, 96-bit nonce
 //   - Poly1305: One-time authenticator for MAC
@@ -20,13 +28,17 @@
 //
 // CRITICAL: Nonce reuse completely breaks security. Each (key, nonce) pair
 // MUST be used at most once. This implementation uses counters for nonce
-// generation and tracks usage to prevent reuse.
+// generation and tracks usage to prevent reuse. Ciphers created with
+// NewAEADWithIV can instead derive each nonce from the caller's sequence
+// number (IV XOR seq, as in TLS 1.3), so the nonce cannot drift from the
+// sequence number authenticated alongside it.
 package crypto
 
 import (
 	"crypto/aes"
 	"crypto/cipher"
 	"encoding/binary"
+	"slices"
 	"sync"
 
 	"golang.org/x/crypto/chacha20poly1305"
@@ -44,13 +56,20 @@ type AEAD struct {
 	mu      sync.Mutex
 	counter uint64
 	maxSeq  uint64
+
+	// IV XORed with sequence numbers by SealSeq and OpenSeq
+	iv [constants.AESNonceSize]byte
+
+	// Key commitment prepended to ciphertexts, nil if not committing (see commit.go)
+	commitment []byte
 }
 
 // NewAEAD creates a new AEAD cipher with the specified suite and key.
 //
 // Parameters:
-//   - suite: CipherSuiteAES256GCM or CipherSuiteChaCha20Poly1305
-//   - key: 32-byte encryption key
+//   - suite: CipherSuiteAES256GCM, CipherSuiteAES128GCM, CipherSuiteChaCha20Poly1305
+//     or CipherSuiteNullEncryption
+//   - key: Encryption key of suite.KeySize() bytes (16 for AES-128-GCM, 32 otherwise)
 //
 // Returns:
 //   - AEAD: The initialized cipher
@@ -61,7 +80,11 @@ func NewAEAD(suite constants.CipherSuite, key []byte) (*AEAD, error) {
 		return nil, qerrors.ErrCipherSuiteNotFIPSApproved
 	}
 
-	if len(key) != constants.AESKeySize {
+	keySize := suite.KeySize()
+	if keySize == 0 {
+		return nil, qerrors.ErrUnsupportedCipherSuite
+	}
+	if len(key) != keySize {
 		return nil, qerrors.ErrInvalidKeySize
 	}
 
@@ -69,7 +92,7 @@ func NewAEAD(suite constants.CipherSuite, key []byte) (*AEAD, error) {
 	var err error
 
 	switch suite {
-	case constants.CipherSuiteAES256GCM:
+	case constants.CipherSuiteAES256GCM, constants.CipherSuiteAES128GCM:
 		block, err := aes.NewCipher(key)
 		if err != nil {
 			return nil, qerrors.NewCryptoError("NewAEAD", err)
@@ -85,6 +108,9 @@ func NewAEAD(suite constants.CipherSuite, key []byte) (*AEAD, error) {
 			return nil, qerrors.NewCryptoError("NewAEAD", err)
 		}
 
+	case constants.CipherSuiteNullEncryption:
+		aeadCipher = newNullCipher(key)
+
 	default:
 		return nil, qerrors.ErrUnsupportedCipherSuite
 	}
@@ -95,10 +121,43 @@ func NewAEAD(suite constants.CipherSuite, key []byte) (*AEAD, error) {
 		counter: 0,
 		// For a 96-bit nonce with 64-bit counter, we have 2^64 - 1 nonces available.
 		// In practice, we limit to 2^28 to trigger rekey well before exhaustion.
-		maxSeq: constants.MaxPacketsBeforeRekey,
+		maxSeq: NonceLimit(suite),
 	}, nil
 }
 
+// NewAEADWithIV creates an AEAD like NewAEAD whose SealSeq and OpenSeq
+// nonces are derived from the sequence number XORed with iv.
+//
+// Parameters:
+//   - suite: The cipher suite
+//   - key: Encryption key of suite.KeySize() bytes
+//   - iv: 12-byte per-direction IV, e.g. from DeriveTrafficIVs
+//
+// Returns:
+//   - AEAD: The initialized cipher
+//   - error: Non-nil if NewAEAD fails or the IV size is wrong
+func NewAEADWithIV(suite constants.CipherSuite, key, iv []byte) (*AEAD, error) {
+	if len(iv) != constants.AESNonceSize {
+		return nil, qerrors.ErrInvalidNonce
+	}
+	a, err := NewAEAD(suite, key)
+	if err != nil {
+		return nil, err
+	}
+	copy(a.iv[:], iv)
+	return a, nil
+}
+
+// NonceLimit returns the number of messages an AEAD for suite can seal under
+// one key before Seal fails with ErrNonceExhausted, or 0 if the suite is
+// not supported. All supported suites currently share the same limit.
+func NonceLimit(suite constants.CipherSuite) uint64 {
+	if suite.KeySize() == 0 {
+		return 0
+	}
+	return constants.MaxPacketsBeforeRekey
+}
+
 // Seal encrypts and authenticates plaintext, returning ciphertext.
 //
 // The operation:
@@ -119,18 +178,99 @@ func (a *AEAD) Seal(plaintext, additionalData []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	// Allocate space for nonce + ciphertext + tag
-	ciphertext := make([]byte, constants.AESNonceSize+len(plaintext)+constants.AESTagSize)
+	// Allocate space for nonce + commitment + ciphertext + tag
+	prefix := constants.AESNonceSize + len(a.commitment)
+	ciphertext := make([]byte, prefix+len(plaintext)+constants.AESTagSize)
 
-	// Copy nonce to beginning
+	// Copy nonce and key commitment to beginning
 	copy(ciphertext[:constants.AESNonceSize], nonce)
+	copy(ciphertext[constants.AESNonceSize:prefix], a.commitment)
 
-	// Encrypt in place after nonce
-	a.cipher.Seal(ciphertext[constants.AESNonceSize:constants.AESNonceSize], nonce, plaintext, additionalData)
+	// Encrypt in place after the prefix
+	a.cipher.Seal(ciphertext[prefix:prefix], nonce, plaintext, additionalData)
 
 	return ciphertext, nil
 }
 
+// SealSeq encrypts and authenticates plaintext using the nonce derived from
+// seq, returning nonce || ciphertext like Seal.
+//
+// Distinct sequence numbers always yield distinct nonces, so the caller
+// must never seal two messages with the same seq under one key. Each call
+// counts toward the cipher's nonce limit like Seal, regardless of seq.
+//
+// Parameters:
+//   - seq: Sequence number of the message, unique per key
+//   - plaintext: Data to encrypt
+//   - additionalData: Additional data to authenticate (not encrypted)
+//
+// Returns:
+//   - ciphertext: nonce || encrypted_data || auth_tag
+//   - error: Non-nil if the nonce limit is reached
+func (a *AEAD) SealSeq(seq uint64, plaintext, additionalData []byte) ([]byte, error) {
+	a.mu.Lock()
+	if a.counter >= a.maxSeq {
+		a.mu.Unlock()
+		return nil, qerrors.ErrNonceExhausted
+	}
+	a.counter++
+	a.mu.Unlock()
+
+	nonce := a.seqNonce(seq)
+	ciphertext := make([]byte, 0, a.Overhead()+len(plaintext))
+	ciphertext = append(ciphertext, nonce[:]...)
+	ciphertext = append(ciphertext, a.commitment...)
+	return a.cipher.Seal(ciphertext, nonce[:], plaintext, additionalData), nil
+}
+
+// OpenSeq decrypts and verifies ciphertext sealed by SealSeq with the same
+// seq. The nonce prefix must match the nonce derived from seq.
+//
+// Parameters:
+//   - seq: Sequence number the message was sealed with
+//   - ciphertext: nonce || encrypted_data || auth_tag
+//   - additionalData: Must match the additionalData used during SealSeq
+//
+// Returns:
+//   - plaintext: Decrypted data
+//   - error: Non-nil if authentication fails or ciphertext malformed
+func (a *AEAD) OpenSeq(seq uint64, ciphertext, additionalData []byte) ([]byte, error) {
+	return a.OpenSeqTo(nil, seq, ciphertext, additionalData)
+}
+
+// OpenSeqTo is like OpenSeq but appends the plaintext to dst, so callers
+// can decrypt into a reused buffer. dst must not overlap ciphertext.
+func (a *AEAD) OpenSeqTo(dst []byte, seq uint64, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(ciphertext) < constants.MinPacketSize {
+		return nil, qerrors.ErrCiphertextTooShort
+	}
+
+	nonce := a.seqNonce(seq)
+	if !ConstantTimeCompare(ciphertext[:constants.AESNonceSize], nonce[:]) {
+		return nil, qerrors.ErrAuthenticationFailed
+	}
+	encrypted, err := a.openCommitment(ciphertext[constants.AESNonceSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := a.cipher.Open(dst, nonce[:], encrypted, additionalData)
+	if err != nil {
+		return nil, qerrors.ErrAuthenticationFailed
+	}
+
+	return plaintext, nil
+}
+
+// seqNonce returns the nonce for seq: the IV with its last 8 bytes XORed
+// with seq in big-endian order.
+func (a *AEAD) seqNonce(seq uint64) [constants.AESNonceSize]byte {
+	nonce := a.iv
+	tail := binary.BigEndian.Uint64(nonce[4:]) ^ seq
+	binary.BigEndian.PutUint64(nonce[4:], tail)
+	return nonce
+}
+
 // SealWithNonce encrypts using an explicit nonce (for specific protocol needs).
 //
 // WARNING: The caller is responsible for ensuring nonce uniqueness.
@@ -142,14 +282,14 @@ func (a *AEAD) Seal(plaintext, additionalData []byte) ([]byte, error) {
 //   - additionalData: Additional data to authenticate
 //
 // Returns:
-//   - ciphertext: encrypted_data || auth_tag (nonce not included)
+//   - ciphertext: [commitment ||] encrypted_data || auth_tag (nonce not included)
 //   - error: Non-nil if nonce size is wrong
 func (a *AEAD) SealWithNonce(nonce, plaintext, additionalData []byte) ([]byte, error) {
 	if len(nonce) != constants.AESNonceSize {
 		return nil, qerrors.ErrInvalidNonce
 	}
 
-	ciphertext := a.cipher.Seal(nil, nonce, plaintext, additionalData)
+	ciphertext := a.cipher.Seal(slices.Clip(a.commitment), nonce, plaintext, additionalData)
 	return ciphertext, nil
 }
 
@@ -173,7 +313,10 @@ func (a *AEAD) Open(ciphertext, additionalData []byte) ([]byte, error) {
 	}
 
 	nonce := ciphertext[:constants.AESNonceSize]
-	encrypted := ciphertext[constants.AESNonceSize:]
+	encrypted, err := a.openCommitment(ciphertext[constants.AESNonceSize:])
+	if err != nil {
+		return nil, err
+	}
 
 	plaintext, err := a.cipher.Open(nil, nonce, encrypted, additionalData)
 	if err != nil {
@@ -201,6 +344,10 @@ func (a *AEAD) OpenWithNonce(nonce, ciphertext, additionalData []byte) ([]byte,
 	if len(ciphertext) < constants.AESTagSize {
 		return nil, qerrors.ErrCiphertextTooShort
 	}
+	ciphertext, err := a.openCommitment(ciphertext)
+	if err != nil {
+		return nil, err
+	}
 
 	plaintext, err := a.cipher.Open(nil, nonce, ciphertext, additionalData)
 	if err != nil {
@@ -229,7 +376,8 @@ func (a *AEAD) nextNonce() ([]byte, error) {
 }
 
 // Counter returns the current nonce counter value.
-// This can be used to check how many messages have been sent.
+// This can be used to check how many messages have been sent, including
+// messages sealed by SealSeq.
 func (a *AEAD) Counter() uint64 {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -258,15 +406,28 @@ func (a *AEAD) NeedsRekey() bool {
 	return a.counter >= (a.maxSeq * 9 / 10)
 }
 
+// RemainingCapacity returns how many more messages the cipher can seal
+// before its nonces are exhausted and sealing fails until a rekey.
+// NeedsRekey reports true once less than 10% of the capacity remains.
+func (a *AEAD) RemainingCapacity() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.counter >= a.maxSeq {
+		return 0
+	}
+	return a.maxSeq - a.counter
+}
+
 // Suite returns the cipher suite identifier.
 func (a *AEAD) Suite() constants.CipherSuite {
 	return a.suite
 }
 
 // Overhead returns the number of bytes of overhead added by encryption.
-// This is nonce size + authentication tag size.
+// This is nonce size + authentication tag size, plus the key commitment
+// size for committing AEADs.
 func (a *AEAD) Overhead() int {
-	return constants.AESNonceSize + a.cipher.Overhead()
+	return constants.AESNonceSize + len(a.commitment) + a.cipher.Overhead()
 }
 
 // NonceSize returns the required nonce size in bytes.
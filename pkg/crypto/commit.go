@@ -0,0 +1,107 @@
+// Package crypto implements key-committing authenticated encryption.
+//
+// This file (commit.go) provides AEADs that commit to their key. AES-GCM and
+// ChaCha20-Poly1305 are not key-committing: an attacker who knows two keys
+// can craft one ciphertext that authenticates under both, which breaks
+// protocols where the decrypting party's choice of key must be unambiguous
+// (multi-recipient or password-based encryption).
+//
+// Construction:
+//
+//	Output = SHAKE-256("CH-KEM-VPN-KeyCommitment", Key)
+//	Commitment = Output[0:32]
+//	EncryptionKey = Output[32:32+KeySize]
+//	Ciphertext = Nonce(12) || Commitment(32) || AEAD(EncryptionKey, ...) || Tag(16)
+//
+// Security Properties:
+//   - Open rejects any ciphertext whose commitment does not match its own key
+//     before decrypting, so a ciphertext opens under at most one key unless
+//     SHAKE-256 collisions can be found (128-bit security)
+//   - The encryption key is derived alongside the commitment, so the
+//     commitment reveals nothing about it
+//   - The commitment is the same for every message under a key; it only
+//     adds KeyCommitmentSize bytes to each ciphertext
+package crypto
+
+import (
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// NewCommittingAEAD creates an AEAD like NewAEAD whose ciphertexts carry a
+// commitment to key, so they cannot be opened validly under any other key.
+//
+// Parameters:
+//   - suite: The cipher suite
+//   - key: Encryption key of suite.KeySize() bytes
+//
+// Returns:
+//   - AEAD: The initialized cipher
+//   - error: Non-nil if the key size is wrong, suite unsupported, or not FIPS approved in FIPS mode
+func NewCommittingAEAD(suite constants.CipherSuite, key []byte) (*AEAD, error) {
+	encKey, commitment, err := commitKey(suite, key)
+	if err != nil {
+		return nil, err
+	}
+	defer Zeroize(encKey)
+
+	a, err := NewAEAD(suite, encKey)
+	if err != nil {
+		return nil, err
+	}
+	a.commitment = commitment
+	return a, nil
+}
+
+// NewCommittingAEADWithIV creates a committing AEAD like NewCommittingAEAD
+// whose SealSeq and OpenSeq nonces are derived from iv as by NewAEADWithIV.
+func NewCommittingAEADWithIV(suite constants.CipherSuite, key, iv []byte) (*AEAD, error) {
+	if len(iv) != constants.AESNonceSize {
+		return nil, qerrors.ErrInvalidNonce
+	}
+	a, err := NewCommittingAEAD(suite, key)
+	if err != nil {
+		return nil, err
+	}
+	copy(a.iv[:], iv)
+	return a, nil
+}
+
+// commitKey derives the encryption key and key commitment for key.
+func commitKey(suite constants.CipherSuite, key []byte) (encKey, commitment []byte, err error) {
+	keySize := suite.KeySize()
+	if keySize == 0 {
+		return nil, nil, qerrors.ErrUnsupportedCipherSuite
+	}
+	if len(key) != keySize {
+		return nil, nil, qerrors.ErrInvalidKeySize
+	}
+
+	output, err := DeriveKey(constants.DomainSeparatorKeyCommitment, key, constants.KeyCommitmentSize+keySize)
+	if err != nil {
+		return nil, nil, err
+	}
+	return output[constants.KeyCommitmentSize:], output[:constants.KeyCommitmentSize], nil
+}
+
+// openCommitment verifies and strips the key commitment that prefixes body,
+// the ciphertext following the nonce. Non-committing AEADs return body
+// unchanged.
+func (a *AEAD) openCommitment(body []byte) ([]byte, error) {
+	if a.commitment == nil {
+		return body, nil
+	}
+	n := len(a.commitment)
+	if len(body) < n+constants.AESTagSize {
+		return nil, qerrors.ErrCiphertextTooShort
+	}
+	if !ConstantTimeCompare(body[:n], a.commitment) {
+		return nil, qerrors.ErrAuthenticationFailed
+	}
+	return body[n:], nil
+}
+
+// Committing reports whether ciphertexts carry a key commitment.
+func (a *AEAD) Committing() bool {
+	return a.commitment != nil
+}
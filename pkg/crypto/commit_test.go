@@ -0,0 +1,214 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/aes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// gf128 is an element of GHASH's field GF(2^128) in GCM bit order.
+type gf128 struct{ hi, lo uint64 }
+
+func gf128FromBytes(b []byte) gf128 {
+	return gf128{binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])}
+}
+
+func (x gf128) bytes() []byte {
+	b := make([]byte, 16)
+	binary.BigEndian.PutUint64(b[:8], x.hi)
+	binary.BigEndian.PutUint64(b[8:], x.lo)
+	return b
+}
+
+func (x gf128) add(y gf128) gf128 {
+	return gf128{x.hi ^ y.hi, x.lo ^ y.lo}
+}
+
+// mul multiplies x and y as in NIST SP 800-38D, Algorithm 1.
+func (x gf128) mul(y gf128) gf128 {
+	var z gf128
+	v := y
+	for i := range 128 {
+		word := x.hi
+		if i >= 64 {
+			word = x.lo
+		}
+		if word&(1<<(63-i%64)) != 0 {
+			z = z.add(v)
+		}
+		carry := v.lo & 1
+		v.lo = v.lo>>1 | v.hi<<63
+		v.hi >>= 1
+		if carry != 0 {
+			v.hi ^= 0xE1 << 56
+		}
+	}
+	return z
+}
+
+// inv returns x^(2^128-2), the inverse of a non-zero x.
+func (x gf128) inv() gf128 {
+	r := gf128{hi: 1 << 63} // The multiplicative identity
+	for i := range 128 {
+		r = r.mul(r)
+		if i < 127 {
+			r = r.mul(x)
+		}
+	}
+	return r
+}
+
+// gcmCollision returns a one-block AES-GCM ciphertext and tag, without
+// additional data, that authenticate under both key1 and key2 with nonce.
+func gcmCollision(t *testing.T, key1, key2, nonce []byte) []byte {
+	t.Helper()
+
+	// H = E_K(0^128) and J = E_K(nonce || 1) for each key
+	subkeys := func(key []byte) (h, j gf128) {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			t.Fatalf("aes.NewCipher failed: %v", err)
+		}
+		buf := make([]byte, 16)
+		block.Encrypt(buf, buf)
+		h = gf128FromBytes(buf)
+		copy(buf, nonce)
+		binary.BigEndian.PutUint32(buf[12:], 1)
+		block.Encrypt(buf, buf)
+		return h, gf128FromBytes(buf)
+	}
+	h1, j1 := subkeys(key1)
+	h2, j2 := subkeys(key2)
+
+	// With one ciphertext block C and length block L, the tag is
+	// J ^ C*H^2 ^ L*H. Equating the tags under both keys gives
+	// C = (J1 ^ J2 ^ L*(H1 ^ H2)) / (H1^2 ^ H2^2).
+	l := gf128{lo: 128}
+	rhs := j1.add(j2).add(l.mul(h1.add(h2)))
+	c := rhs.mul(h1.mul(h1).add(h2.mul(h2)).inv())
+	tag := j1.add(c.mul(h1).mul(h1)).add(l.mul(h1))
+
+	return append(c.bytes(), tag.bytes()...)
+}
+
+func TestGF128Inverse(t *testing.T) {
+	x := gf128{0x0123456789abcdef, 0xfedcba9876543210}
+	if got := x.mul(x.inv()); got != (gf128{hi: 1 << 63}) {
+		t.Errorf("x * x^-1 = %x, want 1", got.bytes())
+	}
+}
+
+func TestCommittingAEADRoundtrip(t *testing.T) {
+	key := make([]byte, 32)
+	_ = SecureRandom(key)
+
+	a, err := NewCommittingAEAD(constants.CipherSuiteAES256GCM, key)
+	if err != nil {
+		t.Fatalf("NewCommittingAEAD failed: %v", err)
+	}
+	if !a.Committing() {
+		t.Error("Committing should report true")
+	}
+	if a.Overhead() != constants.AESNonceSize+constants.KeyCommitmentSize+constants.AESTagSize {
+		t.Errorf("Overhead: got %d", a.Overhead())
+	}
+
+	plaintext := []byte("committed message")
+	aad := []byte("aad")
+
+	ciphertext, err := a.Seal(plaintext, aad)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if len(ciphertext) != len(plaintext)+a.Overhead() {
+		t.Errorf("ciphertext size: got %d, want %d", len(ciphertext), len(plaintext)+a.Overhead())
+	}
+	decrypted, err := a.Open(ciphertext, aad)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("Open failed: %v", err)
+	}
+
+	ciphertext, err = a.SealWithNonce(make([]byte, constants.AESNonceSize), plaintext, aad)
+	if err != nil {
+		t.Fatalf("SealWithNonce failed: %v", err)
+	}
+	decrypted, err = a.OpenWithNonce(make([]byte, constants.AESNonceSize), ciphertext, aad)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("OpenWithNonce failed: %v", err)
+	}
+
+	// Tampering with the commitment fails before decryption
+	ciphertext, _ = a.Seal(plaintext, aad)
+	ciphertext[constants.AESNonceSize] ^= 0x01
+	if _, err := a.Open(ciphertext, aad); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("expected ErrAuthenticationFailed for tampered commitment, got %v", err)
+	}
+
+	// A plain AEAD under the same key must not accept committed ciphertexts
+	plain, _ := NewAEAD(constants.CipherSuiteAES256GCM, key)
+	ciphertext, _ = a.Seal(plaintext, aad)
+	if _, err := plain.Open(ciphertext, aad); err == nil {
+		t.Error("plain AEAD opened a committed ciphertext")
+	}
+
+	if _, err := NewCommittingAEAD(constants.CipherSuiteAES256GCM, key[:16]); err == nil {
+		t.Error("Expected error for invalid key size")
+	}
+	if _, err := NewCommittingAEADWithIV(constants.CipherSuiteAES256GCM, key, nil); err == nil {
+		t.Error("Expected error for invalid IV size")
+	}
+}
+
+func TestCommittingAEADRejectsKeyCollision(t *testing.T) {
+	keyA := make([]byte, 32)
+	keyB := make([]byte, 32)
+	_ = SecureRandom(keyA)
+	_ = SecureRandom(keyB)
+	nonce := make([]byte, constants.AESNonceSize)
+	_ = SecureRandom(nonce)
+
+	// Without commitment, an attacker who knows both keys can craft a
+	// ciphertext that authenticates under each of them
+	collision := append(append([]byte(nil), nonce...), gcmCollision(t, keyA, keyB, nonce)...)
+	plainA, _ := NewAEAD(constants.CipherSuiteAES256GCM, keyA)
+	plainB, _ := NewAEAD(constants.CipherSuiteAES256GCM, keyB)
+	if _, err := plainA.Open(collision, nil); err != nil {
+		t.Fatalf("crafted ciphertext does not open under key A: %v", err)
+	}
+	if _, err := plainB.Open(collision, nil); err != nil {
+		t.Fatalf("crafted ciphertext does not open under key B: %v", err)
+	}
+
+	// The same attack against the committing AEAD's derived encryption
+	// keys yields a ciphertext body valid under both, but the commitment
+	// only matches one key
+	encA, commitA, err := commitKey(constants.CipherSuiteAES256GCM, keyA)
+	if err != nil {
+		t.Fatalf("commitKey failed: %v", err)
+	}
+	encB, commitB, err := commitKey(constants.CipherSuiteAES256GCM, keyB)
+	if err != nil {
+		t.Fatalf("commitKey failed: %v", err)
+	}
+	body := gcmCollision(t, encA, encB, nonce)
+
+	committedA, _ := NewCommittingAEAD(constants.CipherSuiteAES256GCM, keyA)
+	committedB, _ := NewCommittingAEAD(constants.CipherSuiteAES256GCM, keyB)
+	for _, commitment := range [][]byte{commitA, commitB} {
+		ciphertext := append(append(append([]byte(nil), nonce...), commitment...), body...)
+
+		_, errA := committedA.Open(ciphertext, nil)
+		_, errB := committedB.Open(ciphertext, nil)
+		if errA == nil && errB == nil {
+			t.Fatal("committed ciphertext opened under two keys")
+		}
+		if errA != nil && errB != nil {
+			t.Errorf("committed ciphertext opened under neither key: %v, %v", errA, errB)
+		}
+	}
+}
@@ -2,9 +2,12 @@ package crypto_test
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"testing"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 )
 
@@ -102,6 +105,25 @@ func TestConstantTimeCompare(t *testing.T) {
 	}
 }
 
+func TestConstantTimeLengthCheck(t *testing.T) {
+	tests := []struct {
+		got, want int
+		equal     bool
+	}{
+		{0, 0, true},
+		{1568, 1568, true},
+		{1567, 1568, false},
+		{1569, 1568, false},
+		{1 << 20, 0, false},
+		{-1, 0, false},
+	}
+	for _, tc := range tests {
+		if got := crypto.ConstantTimeLengthCheck(tc.got, tc.want); got != tc.equal {
+			t.Errorf("ConstantTimeLengthCheck(%d, %d) = %v, want %v", tc.got, tc.want, got, tc.equal)
+		}
+	}
+}
+
 // --- X25519 Tests ---
 
 func TestX25519KeyGeneration(t *testing.T) {
@@ -223,8 +245,18 @@ func TestMLKEMInvalidCiphertext(t *testing.T) {
 
 	// Try to decapsulate invalid ciphertext (wrong size)
 	_, err = crypto.MLKEMDecapsulate(kp.DecapsulationKey, []byte("short"))
-	if err == nil {
-		t.Error("Expected error for invalid ciphertext size")
+	if !errors.Is(err, qerrors.ErrInvalidCiphertext) {
+		t.Errorf("Expected ErrInvalidCiphertext for invalid ciphertext size, got %v", err)
+	}
+
+	// A valid ciphertext with trailing bytes is rejected too
+	ciphertext, _, err := crypto.MLKEMEncapsulate(kp.EncapsulationKey)
+	if err != nil {
+		t.Fatalf("MLKEMEncapsulate failed: %v", err)
+	}
+	_, err = crypto.MLKEMDecapsulate(kp.DecapsulationKey, append(ciphertext, 0))
+	if !errors.Is(err, qerrors.ErrInvalidCiphertext) {
+		t.Errorf("Expected ErrInvalidCiphertext for padded ciphertext, got %v", err)
 	}
 }
 
@@ -336,6 +368,74 @@ func TestDeriveResumptionSecret(t *testing.T) {
 	}
 }
 
+func TestDeriveExternalPSKSecret(t *testing.T) {
+	psk := bytes.Repeat([]byte{0x42}, 48)
+	secret := make([]byte, 32)
+	for i := range secret {
+		secret[i] = byte(i)
+	}
+
+	mixed, err := crypto.DeriveExternalPSKSecret(psk, secret)
+	if err != nil {
+		t.Fatalf("DeriveExternalPSKSecret failed: %v", err)
+	}
+	if len(mixed) != 32 {
+		t.Errorf("secret size: got %d, want 32", len(mixed))
+	}
+	if bytes.Equal(mixed, secret) {
+		t.Error("mixed secret should differ from the input secret")
+	}
+
+	// Domain separated from resumption mixing with the same inputs
+	resumption, _ := crypto.DeriveResumptionSecret(psk[:32], secret)
+	mixed32, _ := crypto.DeriveExternalPSKSecret(psk[:32], secret)
+	if bytes.Equal(resumption, mixed32) {
+		t.Error("external PSK and resumption derivations should differ")
+	}
+
+	// A different PSK produces a different secret
+	psk2 := bytes.Repeat([]byte{0x43}, 48)
+	if mixed2, _ := crypto.DeriveExternalPSKSecret(psk2, secret); bytes.Equal(mixed, mixed2) {
+		t.Error("different PSK should produce different secret")
+	}
+
+	if _, err := crypto.DeriveExternalPSKSecret(psk[:31], secret); err == nil {
+		t.Error("expected error for PSK shorter than MinPSKSize")
+	}
+	if _, err := crypto.DeriveExternalPSKSecret(psk, []byte("short")); err == nil {
+		t.Error("expected error for invalid secret size")
+	}
+}
+
+func TestDeriveEarlyDataKey(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x42}, 32)
+	random := bytes.Repeat([]byte{0x01}, 32)
+
+	key, err := crypto.DeriveEarlyDataKey(secret, random, constants.CipherSuiteAES256GCM)
+	if err != nil {
+		t.Fatalf("DeriveEarlyDataKey failed: %v", err)
+	}
+	if len(key) != constants.CipherSuiteAES256GCM.KeySize() {
+		t.Errorf("key size: got %d, want %d", len(key), constants.CipherSuiteAES256GCM.KeySize())
+	}
+
+	// A different ClientHello random produces a different key
+	random2 := bytes.Repeat([]byte{0x02}, 32)
+	if key2, _ := crypto.DeriveEarlyDataKey(secret, random2, constants.CipherSuiteAES256GCM); bytes.Equal(key, key2) {
+		t.Error("different client random should produce different key")
+	}
+
+	if _, err := crypto.DeriveEarlyDataKey(secret[:31], random, constants.CipherSuiteAES256GCM); err == nil {
+		t.Error("expected error for invalid secret size")
+	}
+	if _, err := crypto.DeriveEarlyDataKey(secret, nil, constants.CipherSuiteAES256GCM); err == nil {
+		t.Error("expected error for empty client random")
+	}
+	if _, err := crypto.DeriveEarlyDataKey(secret, random, 0); err == nil {
+		t.Error("expected error for unsupported cipher suite")
+	}
+}
+
 func TestDeriveCHKEMSecret(t *testing.T) {
 	x25519Secret := make([]byte, 32)
 	mlkemSecret := make([]byte, 32)
@@ -384,6 +484,35 @@ func TestTranscriptHash(t *testing.T) {
 	}
 }
 
+func TestDeriveTrafficIVs(t *testing.T) {
+	secret := make([]byte, 32)
+	_ = crypto.SecureRandom(secret)
+
+	initiatorIV, responderIV, err := crypto.DeriveTrafficIVs(secret)
+	if err != nil {
+		t.Fatalf("DeriveTrafficIVs failed: %v", err)
+	}
+	if len(initiatorIV) != constants.AESNonceSize || len(responderIV) != constants.AESNonceSize {
+		t.Fatalf("IV sizes: got %d/%d, want %d", len(initiatorIV), len(responderIV), constants.AESNonceSize)
+	}
+	if bytes.Equal(initiatorIV, responderIV) {
+		t.Error("initiator and responder IVs should differ")
+	}
+
+	// IVs must be independent of the traffic keys
+	initiatorKey, _, err := crypto.DeriveTrafficKeys(secret)
+	if err != nil {
+		t.Fatalf("DeriveTrafficKeys failed: %v", err)
+	}
+	if bytes.Equal(initiatorKey[:constants.AESNonceSize], initiatorIV) {
+		t.Error("traffic IV should not be a prefix of the traffic key")
+	}
+
+	if _, _, err := crypto.DeriveTrafficIVs(make([]byte, 16)); err == nil {
+		t.Error("Expected error for invalid secret size")
+	}
+}
+
 // --- AEAD Tests ---
 
 func TestAEADAES256GCM(t *testing.T) {
@@ -448,6 +577,58 @@ func TestAEADChaCha20Poly1305(t *testing.T) {
 	}
 }
 
+func TestAEADNullEncryption(t *testing.T) {
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	aead, err := crypto.NewAEAD(constants.CipherSuiteNullEncryption, key)
+	if crypto.FIPSMode() {
+		if !errors.Is(err, qerrors.ErrCipherSuiteNotFIPSApproved) {
+			t.Fatalf("expected ErrCipherSuiteNotFIPSApproved in FIPS mode, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("NewAEAD failed: %v", err)
+	}
+
+	plaintext := []byte("Hello, quantum-resistant world!")
+	additionalData := []byte("additional data")
+
+	ciphertext, err := aead.Seal(plaintext, additionalData)
+	if err != nil {
+		t.Fatalf("Seal failed: %v", err)
+	}
+	if !bytes.Contains(ciphertext, plaintext) || len(ciphertext) != aead.Overhead()+len(plaintext) {
+		t.Error("null encryption should carry the plaintext with only the usual overhead")
+	}
+
+	decrypted, err := aead.Open(ciphertext, additionalData)
+	if err != nil || !bytes.Equal(decrypted, plaintext) {
+		t.Fatalf("Open = %q, %v", decrypted, err)
+	}
+
+	// Plaintext, tag and additional data are all authenticated
+	for _, i := range []int{constants.AESNonceSize, len(ciphertext) - 1} {
+		tampered := bytes.Clone(ciphertext)
+		tampered[i] ^= 0x01
+		if _, err := aead.Open(tampered, additionalData); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+			t.Errorf("byte %d tampered: expected ErrAuthenticationFailed, got %v", i, err)
+		}
+	}
+	if _, err := aead.Open(ciphertext, []byte("wrong data")); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("wrong AAD: expected ErrAuthenticationFailed, got %v", err)
+	}
+
+	// A different key cannot forge the tag
+	otherKey := bytes.Clone(key)
+	otherKey[0] ^= 0x01
+	other, _ := crypto.NewAEAD(constants.CipherSuiteNullEncryption, otherKey)
+	if _, err := other.Open(ciphertext, additionalData); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("wrong key: expected ErrAuthenticationFailed, got %v", err)
+	}
+}
+
 func TestAEADTamperedCiphertext(t *testing.T) {
 	key := make([]byte, 32)
 	_ = crypto.SecureRandom(key)
@@ -587,6 +768,36 @@ func TestAEADNeedsRekey(t *testing.T) {
 	}
 }
 
+func TestAEADRemainingCapacity(t *testing.T) {
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	aead, err := crypto.NewAEAD(constants.CipherSuiteAES256GCM, key)
+	if err != nil {
+		t.Fatalf("NewAEAD failed: %v", err)
+	}
+	if got := aead.RemainingCapacity(); got != constants.MaxPacketsBeforeRekey {
+		t.Errorf("fresh AEAD capacity = %d, want %d", got, uint64(constants.MaxPacketsBeforeRekey))
+	}
+
+	// Each seal near the limit uses up one nonce
+	_ = aead.SetCounter(constants.MaxPacketsBeforeRekey - 3)
+	for want := uint64(3); want > 0; want-- {
+		if got := aead.RemainingCapacity(); got != want {
+			t.Fatalf("RemainingCapacity = %d, want %d", got, want)
+		}
+		if _, err := aead.Seal([]byte("x"), nil); err != nil {
+			t.Fatalf("Seal with %d remaining failed: %v", want, err)
+		}
+	}
+	if got := aead.RemainingCapacity(); got != 0 {
+		t.Errorf("RemainingCapacity = %d after exhaustion, want 0", got)
+	}
+	if _, err := aead.Seal([]byte("x"), nil); err == nil {
+		t.Error("Expected error with no capacity left")
+	}
+}
+
 func TestAEADSuite(t *testing.T) {
 	key := make([]byte, 32)
 	_ = crypto.SecureRandom(key)
@@ -703,6 +914,134 @@ func TestAEADSealWithNonce(t *testing.T) {
 	}
 }
 
+// newSeqAEAD creates an AEAD with a random key and the given IV.
+func newSeqAEAD(t *testing.T, iv []byte) *crypto.AEAD {
+	t.Helper()
+
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	aead, err := crypto.NewAEADWithIV(constants.CipherSuiteAES256GCM, key, iv)
+	if err != nil {
+		t.Fatalf("NewAEADWithIV failed: %v", err)
+	}
+	return aead
+}
+
+func TestAEADSealSeqRoundtrip(t *testing.T) {
+	iv := make([]byte, constants.AESNonceSize)
+	_ = crypto.SecureRandom(iv)
+	aead := newSeqAEAD(t, iv)
+
+	plaintext := []byte("sequenced message")
+	aad := []byte("aad")
+
+	for _, seq := range []uint64{0, 1, 1 << 32, 1 << 63, ^uint64(0)} {
+		ciphertext, err := aead.SealSeq(seq, plaintext, aad)
+		if err != nil {
+			t.Fatalf("SealSeq(%d) failed: %v", seq, err)
+		}
+		decrypted, err := aead.OpenSeq(seq, ciphertext, aad)
+		if err != nil {
+			t.Fatalf("OpenSeq(%d) failed: %v", seq, err)
+		}
+		if !bytes.Equal(plaintext, decrypted) {
+			t.Errorf("seq %d: decrypted plaintext does not match original", seq)
+		}
+
+		// The same ciphertext must not open under another sequence number
+		if _, err := aead.OpenSeq(seq^1, ciphertext, aad); err == nil {
+			t.Errorf("seq %d: OpenSeq with wrong sequence should fail", seq)
+		}
+	}
+
+	if _, err := aead.OpenSeq(0, []byte("short"), aad); err == nil {
+		t.Error("Expected error for short ciphertext in OpenSeq")
+	}
+}
+
+func TestAEADSealSeqUniqueNonces(t *testing.T) {
+	iv := make([]byte, constants.AESNonceSize)
+	_ = crypto.SecureRandom(iv)
+	aead := newSeqAEAD(t, iv)
+
+	seqs := make([]uint64, 0, 10000+64)
+	for seq := range uint64(10000) {
+		seqs = append(seqs, seq)
+	}
+	// Single-bit sequences exercise every bit of the XOR
+	for bit := 14; bit < 64; bit++ {
+		seqs = append(seqs, 1<<bit)
+	}
+	seqs = append(seqs, ^uint64(0))
+
+	seen := make(map[string]uint64, len(seqs))
+	for _, seq := range seqs {
+		ciphertext, err := aead.SealSeq(seq, []byte("x"), nil)
+		if err != nil {
+			t.Fatalf("SealSeq(%d) failed: %v", seq, err)
+		}
+		nonce := string(ciphertext[:constants.AESNonceSize])
+		if prev, ok := seen[nonce]; ok {
+			t.Fatalf("sequences %d and %d produced the same nonce", prev, seq)
+		}
+		seen[nonce] = seq
+	}
+
+	// The nonce depends only on seq, not on how many messages were sealed
+	_ = aead.SetCounter(1 << 20)
+	ciphertext, err := aead.SealSeq(42, []byte("x"), nil)
+	if err != nil {
+		t.Fatalf("SealSeq failed: %v", err)
+	}
+	if seen[string(ciphertext[:constants.AESNonceSize])] != 42 {
+		t.Error("SealSeq nonce should not depend on the message counter")
+	}
+}
+
+func TestAEADSealSeqDistinctIVs(t *testing.T) {
+	iv1 := make([]byte, constants.AESNonceSize)
+	iv2 := make([]byte, constants.AESNonceSize)
+	_ = crypto.SecureRandom(iv1)
+	copy(iv2, iv1)
+	iv2[0] ^= 0x01
+
+	c1, err := newSeqAEAD(t, iv1).SealSeq(7, []byte("x"), nil)
+	if err != nil {
+		t.Fatalf("SealSeq failed: %v", err)
+	}
+	c2, err := newSeqAEAD(t, iv2).SealSeq(7, []byte("x"), nil)
+	if err != nil {
+		t.Fatalf("SealSeq failed: %v", err)
+	}
+	if bytes.Equal(c1[:constants.AESNonceSize], c2[:constants.AESNonceSize]) {
+		t.Error("different IVs should produce different nonces for the same sequence")
+	}
+}
+
+func TestAEADSealSeqNonceLimit(t *testing.T) {
+	aead := newSeqAEAD(t, make([]byte, constants.AESNonceSize))
+
+	_ = aead.SetCounter(constants.MaxPacketsBeforeRekey - 1)
+	if _, err := aead.SealSeq(0, []byte("x"), nil); err != nil {
+		t.Fatalf("SealSeq below the limit failed: %v", err)
+	}
+	if _, err := aead.SealSeq(1, []byte("x"), nil); err == nil {
+		t.Error("Expected error once the nonce limit is reached")
+	}
+}
+
+func TestNewAEADWithIVInvalidIV(t *testing.T) {
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	for _, size := range []int{0, 8, 16} {
+		if _, err := crypto.NewAEADWithIV(constants.CipherSuiteAES256GCM, key, make([]byte, size)); err == nil {
+			t.Errorf("Expected error for %d-byte IV", size)
+		}
+	}
+}
+
 // --- More ML-KEM Tests ---
 
 func TestMLKEMKeyPairFromSeed(t *testing.T) {
@@ -867,3 +1206,128 @@ func TestMustSecureRandomBytes(t *testing.T) {
 		t.Error("MustSecureRandomBytes returned all zeros")
 	}
 }
+
+// useDeterministicReader installs a DeterministicReader for seed until the
+// test ends. It skips the test in FIPS mode, where the source is set once.
+func useDeterministicReader(t *testing.T, seed string) {
+	t.Helper()
+
+	if crypto.FIPSMode() {
+		t.Skip("random source can only be set once in FIPS mode")
+	}
+	if err := crypto.SetRandReader(crypto.DeterministicReader([]byte(seed))); err != nil {
+		t.Fatalf("SetRandReader failed: %v", err)
+	}
+	t.Cleanup(func() { _ = crypto.SetRandReader(nil) })
+}
+
+func TestSetRandReaderReproducibleKeys(t *testing.T) {
+	generate := func(seed string) (x25519, mlkem, random []byte) {
+		useDeterministicReader(t, seed)
+
+		xkp, err := crypto.GenerateX25519KeyPair()
+		if err != nil {
+			t.Fatalf("GenerateX25519KeyPair failed: %v", err)
+		}
+		mkp, err := crypto.GenerateMLKEMKeyPair()
+		if err != nil {
+			t.Fatalf("GenerateMLKEMKeyPair failed: %v", err)
+		}
+		random, err = crypto.SecureRandomBytes(32)
+		if err != nil {
+			t.Fatalf("SecureRandomBytes failed: %v", err)
+		}
+		return xkp.PublicKeyBytes(), mkp.PublicKeyBytes(), random
+	}
+
+	x1, m1, r1 := generate("fixed seed")
+	x2, m2, r2 := generate("fixed seed")
+	if !bytes.Equal(x1, x2) || !bytes.Equal(m1, m2) || !bytes.Equal(r1, r2) {
+		t.Error("same seed should reproduce the same keys and random bytes")
+	}
+
+	x3, m3, _ := generate("other seed")
+	if bytes.Equal(x1, x3) || bytes.Equal(m1, m3) {
+		t.Error("different seeds should produce different keys")
+	}
+
+	// Restoring the default source makes keys unpredictable again
+	_ = crypto.SetRandReader(nil)
+	xkp, err := crypto.GenerateX25519KeyPair()
+	if err != nil {
+		t.Fatalf("GenerateX25519KeyPair failed: %v", err)
+	}
+	if bytes.Equal(xkp.PublicKeyBytes(), x1) {
+		t.Error("default source reproduced a deterministic key")
+	}
+}
+
+func TestSetRandReaderHealthCheck(t *testing.T) {
+	useDeterministicReader(t, "health check")
+
+	// The health check still runs against the installed source
+	if result := crypto.RNGHealthCheck(); !result.Passed {
+		t.Errorf("RNGHealthCheck failed with DeterministicReader: %v", result.Error)
+	}
+
+	if err := crypto.SetRandReader(bytes.NewReader(make([]byte, 64))); err != nil {
+		t.Fatalf("SetRandReader failed: %v", err)
+	}
+	if result := crypto.RNGHealthCheck(); result.Passed {
+		t.Error("RNGHealthCheck should fail for an all-zero source")
+	}
+	if result := crypto.RNGHealthCheck(); result.Passed {
+		t.Error("RNGHealthCheck should fail for an exhausted source")
+	}
+}
+
+func TestSetRandReaderReader(t *testing.T) {
+	useDeterministicReader(t, "reader")
+	want := make([]byte, 32)
+	_, _ = io.ReadFull(crypto.DeterministicReader([]byte("reader")), want)
+
+	// Installing Reader keeps the installed source instead of reading from itself
+	if err := crypto.SetRandReader(crypto.Reader); err != nil {
+		t.Fatalf("SetRandReader failed: %v", err)
+	}
+	got, err := crypto.SecureRandomBytes(32)
+	if err != nil {
+		t.Fatalf("SecureRandomBytes failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("installing Reader replaced the deterministic source")
+	}
+}
+
+func TestSetRandReaderFIPSLocked(t *testing.T) {
+	if !crypto.FIPSMode() {
+		t.Skip("only FIPS mode locks the random source")
+	}
+
+	// A failing source is rejected by the health check without being set
+	if err := crypto.SetRandReader(bytes.NewReader(nil)); err == nil {
+		t.Error("expected SetRandReader to reject a failing source")
+	}
+
+	_ = crypto.SetRandReader(crypto.Reader)
+	if err := crypto.SetRandReader(crypto.DeterministicReader(nil)); !errors.Is(err, qerrors.ErrRandReaderLocked) {
+		t.Errorf("expected ErrRandReaderLocked, got %v", err)
+	}
+}
+
+func TestSHA3TranscriptHasher(t *testing.T) {
+	components := [][]byte{[]byte("client hello"), []byte("server hello"), {}}
+
+	want, err := crypto.TranscriptHash(components...)
+	if err != nil {
+		t.Fatalf("TranscriptHash failed: %v", err)
+	}
+	var hasher crypto.TranscriptHasher = crypto.SHA3TranscriptHasher{}
+	got, err := hasher.Hash(components...)
+	if err != nil {
+		t.Fatalf("Hash failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Hash = %x, want TranscriptHash %x", got, want)
+	}
+}
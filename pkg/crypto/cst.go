@@ -21,6 +21,8 @@ import (
 	"fmt"
 	"sync"
 	"sync/atomic"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 )
 
 // CSTConfig configures Conditional Self-Test behavior
@@ -201,21 +203,29 @@ func runPairwiseTestMLKEM(kp *MLKEMKeyPair) error {
 
 // --- DRBG Health Check ---
 
-// RNGHealthCheck performs a health check on the random number generator.
-// It verifies that:
+// RNGHealthCheck performs a health check on the random number generator
+// installed by SetRandReader. It verifies that:
 // 1. The RNG produces non-zero output
 // 2. The RNG produces non-repeating output
 // 3. The RNG produces output with reasonable entropy distribution
+//
+// A DeterministicReader passes these checks like any sound generator; they
+// detect broken sources, not predictable ones.
 func RNGHealthCheck() *CSTResult {
+	return rngHealthCheck(SecureRandom)
+}
+
+// rngHealthCheck runs the RNGHealthCheck checks on samples read by read.
+func rngHealthCheck(read func([]byte) error) *CSTResult {
 	// Generate test samples
 	sample1 := make([]byte, 32)
 	sample2 := make([]byte, 32)
 
-	if err := SecureRandom(sample1); err != nil {
+	if err := read(sample1); err != nil {
 		return &CSTResult{Passed: false, Error: fmt.Errorf("RNG read 1 failed: %w", err)}
 	}
 
-	if err := SecureRandom(sample2); err != nil {
+	if err := read(sample2); err != nil {
 		return &CSTResult{Passed: false, Error: fmt.Errorf("RNG read 2 failed: %w", err)}
 	}
 
@@ -291,6 +301,118 @@ func ContinuousRNGTest(output []byte) *CSTResult {
 	return &CSTResult{Passed: true}
 }
 
+// SP 800-90B (Section 4.4) health test parameters for byte samples, assuming
+// full entropy (H = 8 bits per byte).
+const (
+	// rngHealthSampleSize is the number of fresh bytes tested.
+	rngHealthSampleSize = 1024
+
+	// rctCutoff fails the repetition count test when a byte repeats this
+	// many times in a row: 1 + ceil(-log2(alpha)/H) with a false positive
+	// rate alpha = 2^-40.
+	rctCutoff = 6
+
+	// aptWindowSize is the adaptive proportion test window for
+	// non-binary samples.
+	aptWindowSize = 512
+
+	// aptCutoff fails the adaptive proportion test when the first byte of a
+	// window occurs this many times within it:
+	// 1 + CRITBINOM(W, 2^-H, 1-alpha) = 1 + CRITBINOM(512, 2^-8, 1-2^-20),
+	// the value SP 800-90B Table 2 gives for H = 8.
+	aptCutoff = 13
+)
+
+// cstPanics reports whether failed explicit health checks panic, which they
+// do in FIPS mode. It is a variable so tests can exercise both policies
+// without the fips build tag.
+var cstPanics = FIPSMode
+
+// Reseeder is implemented by random sources that can be reseeded on demand,
+// such as an HSM-backed DRBG installed with SetRandReader.
+type Reseeder interface {
+	Reseed() error
+}
+
+// ReseedDRBG reseeds the random source installed by SetRandReader if it
+// implements Reseeder, restarts the continuous and periodic RNG tests, and
+// runs RunRNGHealthCheck on the reseeded output. The default crypto/rand
+// source is reseeded continuously by the operating system, so for it only
+// the tests are restarted.
+//
+// In FIPS mode a failed health check panics like other CST failures.
+func ReseedDRBG() error {
+	if r, ok := currentRandReader().(Reseeder); ok {
+		if err := r.Reseed(); err != nil {
+			return qerrors.NewCryptoError("ReseedDRBG", err)
+		}
+	}
+
+	lastRNGMutex.Lock()
+	lastRNGOutput = nil
+	lastRNGMutex.Unlock()
+	rngCallCount.Store(0)
+
+	if result := RunRNGHealthCheck(); !result.Passed {
+		return result.Error
+	}
+	return nil
+}
+
+// RunRNGHealthCheck runs the SP 800-90B repetition count and adaptive
+// proportion tests, along with the RNGHealthCheck checks, on fresh output
+// of the random source installed by SetRandReader. Unlike the periodic
+// checks it runs regardless of CSTConfig, so auditors can exercise the
+// health tests on demand.
+//
+// In FIPS mode a failed check panics like other CST failures; in standard
+// mode the failure is reported in the result.
+func RunRNGHealthCheck() *CSTResult {
+	result := rngHealthCheck(SecureRandom)
+	if result.Passed {
+		sample := make([]byte, rngHealthSampleSize)
+		if err := SecureRandom(sample); err != nil {
+			result = &CSTResult{Passed: false, Error: fmt.Errorf("RNG read failed: %w", err)}
+		} else {
+			result = entropyHealthTests(sample)
+		}
+	}
+
+	if !result.Passed && cstPanics() {
+		panic(fmt.Sprintf("FIPS CST failed: RNG health check: %v", result.Error))
+	}
+	return result
+}
+
+// entropyHealthTests runs the repetition count and adaptive proportion
+// tests on sample.
+func entropyHealthTests(sample []byte) *CSTResult {
+	// Repetition count test: no byte may repeat rctCutoff times in a row
+	run := 1
+	for i := 1; i < len(sample); i++ {
+		if sample[i] != sample[i-1] {
+			run = 1
+			continue
+		}
+		run++
+		if run >= rctCutoff {
+			return &CSTResult{Passed: false, Error: fmt.Errorf("RNG repetition count test failed: byte 0x%02x repeated %d times", sample[i], run)}
+		}
+	}
+
+	// Adaptive proportion test: the first byte of each window may not
+	// occur aptCutoff times within it
+	for start := 0; start+aptWindowSize <= len(sample); start += aptWindowSize {
+		window := sample[start : start+aptWindowSize]
+		count := bytes.Count(window, window[:1])
+		if count >= aptCutoff {
+			return &CSTResult{Passed: false, Error: fmt.Errorf("RNG adaptive proportion test failed: byte 0x%02x occurred %d times in %d", window[0], count, aptWindowSize)}
+		}
+	}
+
+	return &CSTResult{Passed: true}
+}
+
 // runRNGHealthCheck runs periodic RNG health checks if enabled.
 func runRNGHealthCheck() error {
 	config := getConfig()
@@ -0,0 +1,174 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"io"
+	"testing"
+)
+
+// stuckReader is a broken random source that always returns the same byte.
+type stuckReader byte
+
+func (r stuckReader) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = byte(r)
+	}
+	return len(b), nil
+}
+
+// reseedReader is a random source that records reseeds.
+type reseedReader struct {
+	io.Reader
+	reseeds int
+	err     error
+}
+
+func (r *reseedReader) Reseed() error {
+	r.reseeds++
+	return r.err
+}
+
+// withRandSource installs r as the random source until the test ends,
+// bypassing the FIPS mode lock in SetRandReader.
+func withRandSource(t *testing.T, r io.Reader) {
+	t.Helper()
+
+	randMu.Lock()
+	orig := randReader
+	randReader = r
+	randMu.Unlock()
+	t.Cleanup(func() {
+		randMu.Lock()
+		randReader = orig
+		randMu.Unlock()
+	})
+}
+
+// withCSTPolicy selects whether failed health checks panic until the test
+// ends.
+func withCSTPolicy(t *testing.T, panics bool) {
+	t.Helper()
+
+	orig := cstPanics
+	cstPanics = func() bool { return panics }
+	t.Cleanup(func() { cstPanics = orig })
+}
+
+func TestRunRNGHealthCheckModes(t *testing.T) {
+	for _, fips := range []bool{false, true} {
+		name := "standard"
+		if fips {
+			name = "fips"
+		}
+		t.Run(name, func(t *testing.T) {
+			withCSTPolicy(t, fips)
+
+			withRandSource(t, rand.Reader)
+			if result := RunRNGHealthCheck(); !result.Passed {
+				t.Fatalf("health check failed on real entropy: %v", result.Error)
+			}
+
+			withRandSource(t, stuckReader(0x42))
+			var result *CSTResult
+			panicked := func() (panicked bool) {
+				defer func() { panicked = recover() != nil }()
+				result = RunRNGHealthCheck()
+				return false
+			}()
+
+			if fips {
+				if !panicked {
+					t.Error("expected a panic for a stuck reader in FIPS mode")
+				}
+				return
+			}
+			if panicked {
+				t.Fatal("unexpected panic in standard mode")
+			}
+			if result.Passed || result.Error == nil {
+				t.Error("health check should fail for a stuck reader")
+			}
+		})
+	}
+}
+
+func TestEntropyHealthTests(t *testing.T) {
+	random := make([]byte, rngHealthSampleSize)
+	_, _ = rand.Read(random)
+	if result := entropyHealthTests(random); !result.Passed {
+		t.Errorf("random sample failed: %v", result.Error)
+	}
+
+	// A run of rctCutoff identical bytes fails the repetition count test
+	repeated := append([]byte(nil), random...)
+	for i := 100; i < 100+rctCutoff; i++ {
+		repeated[i] = 0x5A
+	}
+	if result := entropyHealthTests(repeated); result.Passed {
+		t.Error("repetition count test should fail")
+	}
+
+	// A byte occurring in every other position has no runs but fails the
+	// adaptive proportion test
+	biased := append([]byte(nil), random...)
+	for i := 0; i < len(biased); i += 2 {
+		biased[i] = 0x00
+		if biased[i+1] == 0x00 {
+			biased[i+1] = 0x01
+		}
+	}
+	if result := entropyHealthTests(biased); result.Passed {
+		t.Error("adaptive proportion test should fail")
+	}
+
+	// The first byte of a window may occur aptCutoff-1 times, not aptCutoff
+	spread := append([]byte(nil), random...)
+	for i := range spread {
+		if spread[i] == 0x5A {
+			spread[i] = 0x5B
+		}
+	}
+	for i := 0; i < aptCutoff-1; i++ {
+		spread[i*8] = 0x5A
+	}
+	if result := entropyHealthTests(spread); !result.Passed {
+		t.Errorf("%d occurrences should pass: %v", aptCutoff-1, result.Error)
+	}
+	spread[(aptCutoff-1)*8] = 0x5A
+	if result := entropyHealthTests(spread); result.Passed {
+		t.Errorf("%d occurrences should fail the adaptive proportion test", aptCutoff)
+	}
+}
+
+func TestReseedDRBG(t *testing.T) {
+	withCSTPolicy(t, false)
+
+	source := &reseedReader{Reader: rand.Reader}
+	withRandSource(t, source)
+
+	ContinuousRNGTest([]byte("previous output"))
+	if err := ReseedDRBG(); err != nil {
+		t.Fatalf("ReseedDRBG failed: %v", err)
+	}
+	if source.reseeds != 1 {
+		t.Errorf("expected 1 reseed, got %d", source.reseeds)
+	}
+	lastRNGMutex.Lock()
+	restarted := lastRNGOutput == nil
+	lastRNGMutex.Unlock()
+	if !restarted {
+		t.Error("ReseedDRBG should restart the continuous RNG test")
+	}
+
+	source.err = errors.New("hsm unavailable")
+	if err := ReseedDRBG(); !errors.Is(err, source.err) {
+		t.Errorf("expected reseed error, got %v", err)
+	}
+
+	// Sources without Reseed are only health checked
+	withRandSource(t, stuckReader(0))
+	if err := ReseedDRBG(); err == nil {
+		t.Error("ReseedDRBG should fail the health check of a stuck source")
+	}
+}
@@ -313,6 +313,104 @@ func TestKATAES256GCM(t *testing.T) {
 	}
 }
 
+// TestKATAES128GCM verifies AES-128-GCM with known test vectors.
+func TestKATAES128GCM(t *testing.T) {
+	// NIST test vectors for AES-128-GCM
+	// From: https://csrc.nist.gov/groups/ST/toolkit/BCM/documents/proposedmodes/gcm/gcm-spec.pdf
+	testCases := []struct {
+		name       string
+		key        string
+		nonce      string
+		plaintext  string
+		aad        string
+		ciphertext string
+		tag        string
+	}{
+		{
+			name:       "Test Case 1 - Empty plaintext",
+			key:        "00000000000000000000000000000000",
+			nonce:      "000000000000000000000000",
+			plaintext:  "",
+			aad:        "",
+			ciphertext: "",
+			tag:        "58e2fccefa7e3061367f1d57a4e7455a",
+		},
+		{
+			name:       "Test Case 2 - 16 byte plaintext",
+			key:        "00000000000000000000000000000000",
+			nonce:      "000000000000000000000000",
+			plaintext:  "00000000000000000000000000000000",
+			aad:        "",
+			ciphertext: "0388dace60b6a392f328c2b971b2fe78",
+			tag:        "ab6e47d42cec13bdf53a67b21257bddf",
+		},
+		{
+			name:  "Test Case 3 - 64 byte plaintext",
+			key:   "feffe9928665731c6d6a8f9467308308",
+			nonce: "cafebabefacedbaddecaf888",
+			plaintext: "d9313225f88406e5a55909c5aff5269a86a7a9531534f7da2e4c303d8a318a72" +
+				"1c3c0c95956809532fcf0e2449a6b525b16aedf5aa0de657ba637b391aafd255",
+			aad: "",
+			ciphertext: "42831ec2217774244b7221b784d0d49ce3aa212f2c02a4e035c17e2329aca12e" +
+				"21d514b25466931c7d8f6a5aac84aa051ba30b396a0aac973d58e091473f5985",
+			tag: "4d5c2af327cd64a62cf35abd2ba6fab4",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			key, _ := hex.DecodeString(tc.key)
+			nonce, _ := hex.DecodeString(tc.nonce)
+			plaintext, _ := hex.DecodeString(tc.plaintext)
+			aad, _ := hex.DecodeString(tc.aad)
+			expectedCiphertext, _ := hex.DecodeString(tc.ciphertext)
+			expectedTag, _ := hex.DecodeString(tc.tag)
+
+			aead, err := crypto.NewAEAD(constants.CipherSuiteAES128GCM, key)
+			if err != nil {
+				t.Fatalf("NewAEAD failed: %v", err)
+			}
+
+			ciphertext, err := aead.SealWithNonce(nonce, plaintext, aad)
+			if err != nil {
+				t.Fatalf("SealWithNonce failed: %v", err)
+			}
+
+			actualCiphertext := ciphertext[:len(ciphertext)-16]
+			actualTag := ciphertext[len(ciphertext)-16:]
+
+			if !bytes.Equal(actualCiphertext, expectedCiphertext) {
+				t.Errorf("ciphertext mismatch:\n  got:  %s\n  want: %s",
+					hex.EncodeToString(actualCiphertext),
+					hex.EncodeToString(expectedCiphertext))
+			}
+
+			if !bytes.Equal(actualTag, expectedTag) {
+				t.Errorf("tag mismatch:\n  got:  %s\n  want: %s",
+					hex.EncodeToString(actualTag),
+					hex.EncodeToString(expectedTag))
+			}
+
+			decrypted, err := aead.OpenWithNonce(nonce, ciphertext, aad)
+			if err != nil {
+				t.Fatalf("OpenWithNonce failed: %v", err)
+			}
+
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Error("decrypted plaintext doesn't match original")
+			}
+		})
+	}
+
+	// AES-128-GCM only accepts 16-byte keys
+	if _, err := crypto.NewAEAD(constants.CipherSuiteAES128GCM, make([]byte, 32)); err == nil {
+		t.Error("expected error for 32-byte AES-128-GCM key")
+	}
+	if _, err := crypto.NewAEAD(constants.CipherSuiteAES256GCM, make([]byte, 16)); err == nil {
+		t.Error("expected error for 16-byte AES-256-GCM key")
+	}
+}
+
 // TestKATAEADRoundtrip verifies AEAD encrypt/decrypt roundtrip with various inputs.
 func TestKATAEADRoundtrip(t *testing.T) {
 	suites := []constants.CipherSuite{
@@ -517,6 +615,35 @@ func TestKATDeriveTrafficKeys(t *testing.T) {
 
 }
 
+func TestKATDeriveTrafficKeysForSuite(t *testing.T) {
+	masterSecret, _ := hex.DecodeString("0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+
+	// 32-byte suites match DeriveTrafficKeys
+	ik, rk, _ := crypto.DeriveTrafficKeys(masterSecret)
+	ik256, rk256, err := crypto.DeriveTrafficKeysForSuite(masterSecret, constants.CipherSuiteAES256GCM)
+	if err != nil {
+		t.Fatalf("DeriveTrafficKeysForSuite failed: %v", err)
+	}
+	if !bytes.Equal(ik, ik256) || !bytes.Equal(rk, rk256) {
+		t.Error("AES-256-GCM traffic keys should match DeriveTrafficKeys")
+	}
+
+	ik128, rk128, err := crypto.DeriveTrafficKeysForSuite(masterSecret, constants.CipherSuiteAES128GCM)
+	if err != nil {
+		t.Fatalf("DeriveTrafficKeysForSuite failed: %v", err)
+	}
+	if len(ik128) != 16 || len(rk128) != 16 {
+		t.Errorf("AES-128-GCM key lengths: got %d/%d, want 16", len(ik128), len(rk128))
+	}
+	if bytes.Equal(ik128, rk128) {
+		t.Error("initiator and responder keys should be different")
+	}
+
+	if _, _, err := crypto.DeriveTrafficKeysForSuite(masterSecret, constants.CipherSuite(0xFFFF)); err == nil {
+		t.Error("expected error for unsupported cipher suite")
+	}
+}
+
 // --- Handshake Key Derivation Test ---
 
 func TestKATDeriveHandshakeKeys(t *testing.T) {
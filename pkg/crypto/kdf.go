@@ -209,6 +209,24 @@ func TranscriptHash(components ...[]byte) ([]byte, error) {
 	return h.Sum(nil), nil
 }
 
+// TranscriptHasher hashes the handshake transcript. It abstracts the hash
+// algorithm so that it can be selected per handshake; both peers must use
+// the same one.
+type TranscriptHasher interface {
+	// Hash returns the hash of the ordered transcript components, encoded
+	// as for TranscriptHash.
+	Hash(components ...[]byte) ([]byte, error)
+}
+
+// SHA3TranscriptHasher is the default TranscriptHasher. It computes
+// TranscriptHash (SHA3-256).
+type SHA3TranscriptHasher struct{}
+
+// Hash returns TranscriptHash(components...).
+func (SHA3TranscriptHasher) Hash(components ...[]byte) ([]byte, error) {
+	return TranscriptHash(components...)
+}
+
 // DeriveCHKEMSecret derives the final shared secret for CH-KEM.
 //
 // This is the core key derivation for the Cascaded Hybrid KEM:
@@ -265,30 +283,33 @@ func DeriveCHKEMSecret(x25519Secret, mlkemSecret, transcriptHash []byte) ([]byte
 //   - initiatorIV, responderIV: 12-byte IVs for AEAD
 //   - error: Non-nil if derivation fails
 func DeriveHandshakeKeys(masterSecret []byte) (initiatorKey, responderKey, initiatorIV, responderIV []byte, err error) {
-	if len(masterSecret) != constants.CHKEMSharedSecretSize {
-		return nil, nil, nil, nil, qerrors.NewCryptoError("DeriveHandshakeKeys", qerrors.ErrInvalidKeySize)
-	}
+	return DeriveHandshakeKeysForSuite(masterSecret, constants.CipherSuiteAES256GCM)
+}
 
-	// Derive all keys in one pass for efficiency
-	keyMaterial, err := DeriveKey(
-		constants.DomainSeparatorHandshake,
-		masterSecret,
-		2*constants.AESKeySize+2*constants.AESNonceSize,
-	)
+// DeriveHandshakeKeysForSuite derives handshake keys sized for suite.
+//
+// The derivation matches DeriveHandshakeKeys, but the write keys are
+// suite.KeySize() bytes (16 for AES-128-GCM, 32 otherwise). It is a
+// shorthand for KeySchedule.HandshakeKeys.
+//
+// Parameters:
+//   - masterSecret: The CH-KEM shared secret
+//   - suite: The negotiated cipher suite
+//
+// Returns:
+//   - initiatorKey, responderKey: Encryption keys for suite
+//   - initiatorIV, responderIV: 12-byte IVs for AEAD
+//   - error: Non-nil if the secret size is wrong or the suite is unsupported
+func DeriveHandshakeKeysForSuite(masterSecret []byte, suite constants.CipherSuite) (initiatorKey, responderKey, initiatorIV, responderIV []byte, err error) {
+	schedule, err := NewKeySchedule(masterSecret, suite)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
-
-	offset := 0
-	initiatorKey = keyMaterial[offset : offset+constants.AESKeySize]
-	offset += constants.AESKeySize
-	responderKey = keyMaterial[offset : offset+constants.AESKeySize]
-	offset += constants.AESKeySize
-	initiatorIV = keyMaterial[offset : offset+constants.AESNonceSize]
-	offset += constants.AESNonceSize
-	responderIV = keyMaterial[offset : offset+constants.AESNonceSize]
-
-	return initiatorKey, responderKey, initiatorIV, responderIV, nil
+	keys, err := schedule.HandshakeKeys()
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	return keys.Initiator.Key, keys.Responder.Key, keys.Initiator.IV, keys.Responder.IV, nil
 }
 
 // DeriveTrafficKeys derives keys for tunnel traffic encryption.
@@ -303,23 +324,58 @@ func DeriveHandshakeKeys(masterSecret []byte) (initiatorKey, responderKey, initi
 //   - initiatorKey, responderKey: 32-byte encryption keys
 //   - error: Non-nil if derivation fails
 func DeriveTrafficKeys(masterSecret []byte) (initiatorKey, responderKey []byte, err error) {
-	if len(masterSecret) != constants.CHKEMSharedSecretSize {
-		return nil, nil, qerrors.NewCryptoError("DeriveTrafficKeys", qerrors.ErrInvalidKeySize)
-	}
+	return DeriveTrafficKeysForSuite(masterSecret, constants.CipherSuiteAES256GCM)
+}
 
-	keyMaterial, err := DeriveKey(
-		constants.DomainSeparatorTraffic,
-		masterSecret,
-		2*constants.AESKeySize,
-	)
+// DeriveTrafficKeysForSuite derives traffic keys sized for suite.
+//
+// The derivation matches DeriveTrafficKeys, but the keys are
+// suite.KeySize() bytes (16 for AES-128-GCM, 32 otherwise). It is a
+// shorthand for the keys of KeySchedule.TrafficKeys.
+//
+// Parameters:
+//   - masterSecret: The CH-KEM shared secret
+//   - suite: The negotiated cipher suite
+//
+// Returns:
+//   - initiatorKey, responderKey: Encryption keys for suite
+//   - error: Non-nil if the secret size is wrong or the suite is unsupported
+func DeriveTrafficKeysForSuite(masterSecret []byte, suite constants.CipherSuite) (initiatorKey, responderKey []byte, err error) {
+	keys, err := deriveTrafficKeys(masterSecret, suite)
 	if err != nil {
 		return nil, nil, err
 	}
+	ZeroizeMultiple(keys.Initiator.IV, keys.Responder.IV)
+	return keys.Initiator.Key, keys.Responder.Key, nil
+}
 
-	initiatorKey = keyMaterial[:constants.AESKeySize]
-	responderKey = keyMaterial[constants.AESKeySize:]
+// DeriveTrafficIVs derives the per-direction IVs for traffic nonces.
+//
+// The IVs are derived under their own domain separator, so they are
+// independent of the traffic keys and of DeriveTrafficKeys' output.
+//
+// Parameters:
+//   - masterSecret: The CH-KEM shared secret
+//
+// Returns:
+//   - initiatorIV, responderIV: 12-byte IVs for NewAEADWithIV
+//   - error: Non-nil if the secret size is wrong
+func DeriveTrafficIVs(masterSecret []byte) (initiatorIV, responderIV []byte, err error) {
+	keys, err := deriveTrafficKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	if err != nil {
+		return nil, nil, err
+	}
+	ZeroizeMultiple(keys.Initiator.Key, keys.Responder.Key)
+	return keys.Initiator.IV, keys.Responder.IV, nil
+}
 
-	return initiatorKey, responderKey, nil
+// deriveTrafficKeys derives the traffic keys of masterSecret for suite.
+func deriveTrafficKeys(masterSecret []byte, suite constants.CipherSuite) (*SessionKeys, error) {
+	schedule, err := NewKeySchedule(masterSecret, suite)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.TrafficKeys()
 }
 
 // DeriveResumptionSecret derives a new master secret for resumed sessions.
@@ -350,6 +406,103 @@ func DeriveResumptionSecret(psk, freshSecret []byte) ([]byte, error) {
 	)
 }
 
+// DeriveAbbreviatedSecret derives the master secret for an abbreviated
+// handshake that skips the CH-KEM exchange.
+//
+// The stored resumption secret is mixed with both hello randoms, so every
+// resumed session gets fresh traffic keys. Unlike DeriveResumptionSecret
+// there is no fresh KEM input: anyone holding the resumption secret can
+// derive the keys, so its lifetime bounds the exposure.
+//
+// Parameters:
+//   - resumptionSecret: 32-byte secret stored when the session was resumable
+//   - clientRandom: Random from the ClientHello
+//   - serverRandom: Random from the ServerHello
+//
+// Returns:
+//   - newSecret: New 32-byte master secret
+//   - error: Non-nil if inputs are invalid
+func DeriveAbbreviatedSecret(resumptionSecret, clientRandom, serverRandom []byte) ([]byte, error) {
+	if len(resumptionSecret) != constants.CHKEMSharedSecretSize {
+		return nil, qerrors.NewCryptoError("DeriveAbbreviatedSecret", qerrors.ErrInvalidKeySize)
+	}
+	if len(clientRandom) == 0 || len(serverRandom) == 0 {
+		return nil, qerrors.NewCryptoError("DeriveAbbreviatedSecret", qerrors.ErrInvalidMessage)
+	}
+
+	return DeriveKeyMultiple(
+		constants.DomainSeparatorAbbreviated,
+		[][]byte{resumptionSecret, clientRandom, serverRandom},
+		constants.CHKEMSharedSecretSize,
+	)
+}
+
+// DeriveEarlyDataKey derives the key protecting 0-RTT early data sent with
+// the ClientHello of an abbreviated handshake.
+//
+// Only the client's random is available when early data is sent, so the key
+// depends solely on the resumption secret and ClientHello: unlike the traffic
+// keys it has no forward secrecy and a replayed first flight decrypts again.
+// Responders must reject replays themselves.
+//
+// Parameters:
+//   - resumptionSecret: 32-byte secret stored when the session was resumable
+//   - clientRandom: Random from the ClientHello
+//   - suite: Cipher suite of the resumed session, which sets the key size
+//
+// Returns:
+//   - key: Early data key for suite
+//   - error: Non-nil if inputs are invalid
+func DeriveEarlyDataKey(resumptionSecret, clientRandom []byte, suite constants.CipherSuite) ([]byte, error) {
+	if len(resumptionSecret) != constants.CHKEMSharedSecretSize {
+		return nil, qerrors.NewCryptoError("DeriveEarlyDataKey", qerrors.ErrInvalidKeySize)
+	}
+	if len(clientRandom) == 0 {
+		return nil, qerrors.NewCryptoError("DeriveEarlyDataKey", qerrors.ErrInvalidMessage)
+	}
+	keySize := suite.KeySize()
+	if keySize == 0 {
+		return nil, qerrors.NewCryptoError("DeriveEarlyDataKey", qerrors.ErrUnsupportedCipherSuite)
+	}
+
+	return DeriveKeyMultiple(
+		constants.DomainSeparatorEarlyData,
+		[][]byte{resumptionSecret, clientRandom},
+		keySize,
+	)
+}
+
+// DeriveExternalPSKSecret mixes an external pre-shared key into a handshake
+// secret.
+//
+//	newSecret = SHAKE-256("CH-KEM-VPN-ExternalPSK" || psk || secret, 256 bits)
+//
+// Both peers must hold the same PSK to derive matching keys. The result stays
+// confidential as long as EITHER the PSK OR the KEM output is secret, so a
+// break of both X25519 and ML-KEM alone does not expose the tunnel.
+//
+// Parameters:
+//   - psk: Pre-shared key of at least MinPSKSize bytes
+//   - secret: 32-byte secret from the CH-KEM exchange or resumption
+//
+// Returns:
+//   - newSecret: New 32-byte master secret
+//   - error: Non-nil if inputs are invalid
+func DeriveExternalPSKSecret(psk, secret []byte) ([]byte, error) {
+	if len(psk) < constants.MinPSKSize {
+		return nil, qerrors.NewCryptoError("DeriveExternalPSKSecret", qerrors.ErrInvalidKeySize)
+	}
+	if len(secret) != constants.CHKEMSharedSecretSize {
+		return nil, qerrors.NewCryptoError("DeriveExternalPSKSecret", qerrors.ErrInvalidKeySize)
+	}
+
+	return DeriveKeyMultiple(
+		constants.DomainSeparatorPSK,
+		[][]byte{psk, secret},
+		constants.CHKEMSharedSecretSize,
+	)
+}
+
 // DeriveRekeySecret derives a new master secret for session rekeying.
 //
 // The ratcheting pattern mixes the current master secret with fresh KEM output,
@@ -370,13 +523,13 @@ func DeriveResumptionSecret(psk, freshSecret []byte) ([]byte, error) {
 //   - newSecret: New 32-byte master secret
 //   - error: Non-nil if derivation fails
 func DeriveRekeySecret(currentSecret, additionalData []byte) ([]byte, error) {
-	if len(currentSecret) != constants.CHKEMSharedSecretSize {
-		return nil, qerrors.NewCryptoError("DeriveRekeySecret", qerrors.ErrInvalidKeySize)
+	schedule, err := NewKeySchedule(currentSecret, constants.CipherSuiteAES256GCM)
+	if err != nil {
+		return nil, err
 	}
-
-	return DeriveKeyMultiple(
-		constants.DomainSeparatorRekey,
-		[][]byte{currentSecret, additionalData},
-		constants.CHKEMSharedSecretSize,
-	)
+	next, err := schedule.Rekey(additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return next.Secret(), nil
 }
@@ -0,0 +1,168 @@
+// Package crypto implements the session key schedule.
+//
+// This file (key_schedule.go) centralizes how session keys are derived from
+// a master secret. Each phase squeezes SHAKE-256 under its own domain
+// separator and splits the output into named values whose lengths come from
+// the cipher suite's layout:
+//
+//	Handshake:  DeriveKey("CH-KEM-VPN-Handshake", secret)
+//	            = initiator key || responder key || initiator IV || responder IV
+//	Traffic:    DeriveKey("CH-KEM-VPN-Traffic", secret)
+//	            = initiator key || responder key
+//	            DeriveKey("CH-KEM-VPN-TrafficIV", secret)
+//	            = initiator IV || responder IV
+//	Rekey:      DeriveKeyMultiple("CH-KEM-VPN-Rekey", secret, additionalData)
+//	            = next master secret
+//
+// Adding a cipher suite only requires a layout entry.
+package crypto
+
+import (
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// keyLayout holds the lengths of the values a suite's key material is
+// split into.
+type keyLayout struct {
+	key int // AEAD key size
+	iv  int // Per-direction IV (nonce) size
+}
+
+// keyLayouts maps each supported cipher suite to its key layout.
+var keyLayouts = map[constants.CipherSuite]keyLayout{
+	constants.CipherSuiteAES256GCM:        {key: constants.AESKeySize, iv: constants.AESNonceSize},
+	constants.CipherSuiteChaCha20Poly1305: {key: constants.ChaCha20KeySize, iv: constants.ChaCha20NonceSize},
+	constants.CipherSuiteAES128GCM:        {key: constants.AES128KeySize, iv: constants.AESNonceSize},
+	constants.CipherSuiteNullEncryption:   {key: constants.NullEncryptionKeySize, iv: constants.AESNonceSize},
+}
+
+// KeySchedule derives the keys of one master secret for a cipher suite.
+//
+// The schedule references the master secret without copying it; the
+// caller remains responsible for zeroizing it.
+type KeySchedule struct {
+	secret []byte
+	suite  constants.CipherSuite
+	layout keyLayout
+}
+
+// DirectionKeys holds the key and IV one peer encrypts with.
+type DirectionKeys struct {
+	Key []byte
+	IV  []byte
+}
+
+// SessionKeys holds the keys of both directions of a session phase.
+type SessionKeys struct {
+	Initiator DirectionKeys
+	Responder DirectionKeys
+}
+
+// NewKeySchedule creates the key schedule for masterSecret and suite.
+//
+// Parameters:
+//   - masterSecret: 32-byte CH-KEM shared secret
+//   - suite: The negotiated cipher suite
+//
+// Returns:
+//   - schedule: Key schedule referencing masterSecret
+//   - error: Non-nil if the secret size is wrong or the suite is unsupported
+func NewKeySchedule(masterSecret []byte, suite constants.CipherSuite) (*KeySchedule, error) {
+	if len(masterSecret) != constants.CHKEMSharedSecretSize {
+		return nil, qerrors.NewCryptoError("NewKeySchedule", qerrors.ErrInvalidKeySize)
+	}
+	layout, ok := keyLayouts[suite]
+	if !ok {
+		return nil, qerrors.NewCryptoError("NewKeySchedule", qerrors.ErrUnsupportedCipherSuite)
+	}
+	return &KeySchedule{secret: masterSecret, suite: suite, layout: layout}, nil
+}
+
+// Suite returns the cipher suite the schedule derives keys for.
+func (ks *KeySchedule) Suite() constants.CipherSuite {
+	return ks.suite
+}
+
+// Secret returns the master secret. The slice aliases the schedule's secret.
+func (ks *KeySchedule) Secret() []byte {
+	return ks.secret
+}
+
+// HandshakeKeys derives the keys and IVs protecting handshake messages.
+func (ks *KeySchedule) HandshakeKeys() (*SessionKeys, error) {
+	key, iv := ks.layout.key, ks.layout.iv
+	material, err := DeriveKey(constants.DomainSeparatorHandshake, ks.secret, 2*key+2*iv)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := splitKeyMaterial(material, key, key, iv, iv)
+	return &SessionKeys{
+		Initiator: DirectionKeys{Key: parts[0], IV: parts[2]},
+		Responder: DirectionKeys{Key: parts[1], IV: parts[3]},
+	}, nil
+}
+
+// TrafficKeys derives the keys and nonce IVs protecting tunnel traffic.
+// The keys and IVs use separate domain separators, so each is independent
+// of the other and of the handshake keys.
+func (ks *KeySchedule) TrafficKeys() (*SessionKeys, error) {
+	key, iv := ks.layout.key, ks.layout.iv
+	keyMaterial, err := DeriveKey(constants.DomainSeparatorTraffic, ks.secret, 2*key)
+	if err != nil {
+		return nil, err
+	}
+	ivMaterial, err := DeriveKey(constants.DomainSeparatorTrafficIV, ks.secret, 2*iv)
+	if err != nil {
+		Zeroize(keyMaterial)
+		return nil, err
+	}
+
+	keys := splitKeyMaterial(keyMaterial, key, key)
+	ivs := splitKeyMaterial(ivMaterial, iv, iv)
+	return &SessionKeys{
+		Initiator: DirectionKeys{Key: keys[0], IV: ivs[0]},
+		Responder: DirectionKeys{Key: keys[1], IV: ivs[1]},
+	}, nil
+}
+
+// Rekey returns the schedule of the next master secret, derived from the
+// current one and additionalData (fresh KEM output or a ratchet label).
+// The new secret is owned by the caller.
+func (ks *KeySchedule) Rekey(additionalData []byte) (*KeySchedule, error) {
+	secret, err := DeriveKeyMultiple(
+		constants.DomainSeparatorRekey,
+		[][]byte{ks.secret, additionalData},
+		constants.CHKEMSharedSecretSize,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &KeySchedule{secret: secret, suite: ks.suite, layout: ks.layout}, nil
+}
+
+// ForRole returns the keys a peer sends and receives with.
+func (k *SessionKeys) ForRole(initiator bool) (send, recv DirectionKeys) {
+	if initiator {
+		return k.Initiator, k.Responder
+	}
+	return k.Responder, k.Initiator
+}
+
+// Zeroize clears all keys and IVs.
+func (k *SessionKeys) Zeroize() {
+	ZeroizeMultiple(k.Initiator.Key, k.Initiator.IV, k.Responder.Key, k.Responder.IV)
+}
+
+// splitKeyMaterial splits material into consecutive values of the given
+// lengths, which must sum to len(material).
+func splitKeyMaterial(material []byte, lengths ...int) [][]byte {
+	parts := make([][]byte, len(lengths))
+	offset := 0
+	for i, n := range lengths {
+		parts[i] = material[offset : offset+n : offset+n]
+		offset += n
+	}
+	return parts
+}
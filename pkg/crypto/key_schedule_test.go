@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+func mustHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatalf("invalid hex %q: %v", s, err)
+	}
+	return b
+}
+
+func TestKeyScheduleKAT(t *testing.T) {
+	secret := mustHex(t, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	schedule, err := NewKeySchedule(secret, constants.CipherSuiteAES256GCM)
+	if err != nil {
+		t.Fatalf("NewKeySchedule failed: %v", err)
+	}
+
+	handshake, err := schedule.HandshakeKeys()
+	if err != nil {
+		t.Fatalf("HandshakeKeys failed: %v", err)
+	}
+	traffic, err := schedule.TrafficKeys()
+	if err != nil {
+		t.Fatalf("TrafficKeys failed: %v", err)
+	}
+	next, err := schedule.Rekey(mustHex(t, "cafebabe"))
+	if err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		got  []byte
+		want string
+	}{
+		{"handshake initiator key", handshake.Initiator.Key, "c5b4f03e0305e19165a480983c63373abe134a53485b9b83a9003bb2de8f13ac"},
+		{"handshake responder key", handshake.Responder.Key, "e7fd8825ea3e72a3586d2e5304f94daac01aa6a6715d2280d81329b258ed6331"},
+		{"handshake initiator IV", handshake.Initiator.IV, "61599ad7fa3f09c9e606327b"},
+		{"handshake responder IV", handshake.Responder.IV, "31d79677a92b71857d191079"},
+		{"traffic initiator key", traffic.Initiator.Key, "74e062b6ba3f71d32bc23ba6cdead5377d2fd56c8c6c25a5ae08bd68824e1ef6"},
+		{"traffic responder key", traffic.Responder.Key, "6cbb1c602a28d11889384573b1ff02e33e53e052c3566d1e98c8ee85d9808da0"},
+		{"traffic initiator IV", traffic.Initiator.IV, "ccfd703f6f2655b466ab02a9"},
+		{"traffic responder IV", traffic.Responder.IV, "cf374f3f97acdbe701eb0921"},
+		{"rekey secret", next.Secret(), "b2ecdf4fde910a8928d2890c18bc771d8f7fbcdfea3452c2b345a59ca3a759bd"},
+	}
+	for _, tt := range tests {
+		if got := hex.EncodeToString(tt.got); got != tt.want {
+			t.Errorf("%s = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+
+	// The standalone derivation functions agree with the schedule
+	ik, rk, iiv, riv, _ := DeriveHandshakeKeys(secret)
+	if !bytes.Equal(ik, handshake.Initiator.Key) || !bytes.Equal(rk, handshake.Responder.Key) ||
+		!bytes.Equal(iiv, handshake.Initiator.IV) || !bytes.Equal(riv, handshake.Responder.IV) {
+		t.Error("DeriveHandshakeKeys differs from KeySchedule.HandshakeKeys")
+	}
+	ik, rk, _ = DeriveTrafficKeys(secret)
+	iiv, riv, _ = DeriveTrafficIVs(secret)
+	if !bytes.Equal(ik, traffic.Initiator.Key) || !bytes.Equal(rk, traffic.Responder.Key) ||
+		!bytes.Equal(iiv, traffic.Initiator.IV) || !bytes.Equal(riv, traffic.Responder.IV) {
+		t.Error("DeriveTrafficKeys/DeriveTrafficIVs differ from KeySchedule.TrafficKeys")
+	}
+}
+
+func TestKeyScheduleAES128Lengths(t *testing.T) {
+	secret := mustHex(t, "0123456789abcdef0123456789abcdef0123456789abcdef0123456789abcdef")
+	schedule, err := NewKeySchedule(secret, constants.CipherSuiteAES128GCM)
+	if err != nil {
+		t.Fatalf("NewKeySchedule failed: %v", err)
+	}
+
+	handshake, err := schedule.HandshakeKeys()
+	if err != nil {
+		t.Fatalf("HandshakeKeys failed: %v", err)
+	}
+	traffic, err := schedule.TrafficKeys()
+	if err != nil {
+		t.Fatalf("TrafficKeys failed: %v", err)
+	}
+	for name, keys := range map[string]*SessionKeys{"handshake": handshake, "traffic": traffic} {
+		for _, dir := range []DirectionKeys{keys.Initiator, keys.Responder} {
+			if len(dir.Key) != constants.AES128KeySize || len(dir.IV) != constants.AESNonceSize {
+				t.Errorf("%s key/IV lengths = %d/%d, want %d/%d", name,
+					len(dir.Key), len(dir.IV), constants.AES128KeySize, constants.AESNonceSize)
+			}
+		}
+		if bytes.Equal(keys.Initiator.Key, keys.Responder.Key) {
+			t.Errorf("%s initiator and responder keys should differ", name)
+		}
+	}
+	if _, err := NewAEADWithIV(constants.CipherSuiteAES128GCM, traffic.Initiator.Key, traffic.Initiator.IV); err != nil {
+		t.Errorf("traffic keys rejected by AES-128-GCM: %v", err)
+	}
+
+	// Rekeying keeps the suite
+	next, err := schedule.Rekey([]byte("fresh"))
+	if err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if next.Suite() != constants.CipherSuiteAES128GCM || len(next.Secret()) != constants.CHKEMSharedSecretSize {
+		t.Errorf("rekeyed schedule: suite %v, secret %d bytes", next.Suite(), len(next.Secret()))
+	}
+}
+
+func TestKeyScheduleInvalid(t *testing.T) {
+	if _, err := NewKeySchedule(make([]byte, 16), constants.CipherSuiteAES256GCM); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Errorf("expected ErrInvalidKeySize for a short secret, got %v", err)
+	}
+	secret := make([]byte, constants.CHKEMSharedSecretSize)
+	if _, err := NewKeySchedule(secret, constants.CipherSuite(0xFFFF)); !errors.Is(err, qerrors.ErrUnsupportedCipherSuite) {
+		t.Errorf("expected ErrUnsupportedCipherSuite, got %v", err)
+	}
+}
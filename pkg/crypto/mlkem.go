@@ -1,4 +1,4 @@
-// Package crypto implements ML-KEM-1024 key encapsulation mechanism wrapper.
+// Package crypto implements ML-KEM key encapsulation mechanism wrapper.
 //
 // This file (mlkem.go) wraps ML-KEM, which is standardized in
 // NIST FIPS 203. The security of ML-KEM is based on the computational difficulty
@@ -17,26 +17,85 @@
 // It is computationally infeasible to distinguish (A, As + e) from uniform random.
 //
 // Security Level: NIST Category 5 (equivalent to AES-256 against quantum adversaries)
+//
+// ML-KEM-768 (k=3, NIST Category 3) is also available for constrained
+// devices; its public key and ciphertext are roughly 25-30% smaller.
 package crypto
 
 import (
+	"io"
+
+	"github.com/cloudflare/circl/kem"
 	"github.com/cloudflare/circl/kem/mlkem/mlkem1024"
+	"github.com/cloudflare/circl/kem/mlkem/mlkem768"
 
-	"github.com/sara-star-quant/quantum-go/internal/constants"
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 )
 
-// MLKEMPublicKey wraps an ML-KEM-1024 public key
+// MLKEMLevel selects an ML-KEM parameter set.
+type MLKEMLevel int
+
+// Supported ML-KEM parameter sets.
+const (
+	// MLKEM1024 is ML-KEM-1024 (NIST Category 5). This is the default.
+	MLKEM1024 MLKEMLevel = iota
+	// MLKEM768 is ML-KEM-768 (NIST Category 3).
+	MLKEM768
+)
+
+// String returns the name of the parameter set.
+func (l MLKEMLevel) String() string {
+	switch l {
+	case MLKEM1024:
+		return "ML-KEM-1024"
+	case MLKEM768:
+		return "ML-KEM-768"
+	default:
+		return "Unknown"
+	}
+}
+
+// scheme returns the CIRCL scheme for the parameter set, or nil if unknown.
+func (l MLKEMLevel) scheme() kem.Scheme {
+	switch l {
+	case MLKEM1024:
+		return mlkem1024.Scheme()
+	case MLKEM768:
+		return mlkem768.Scheme()
+	default:
+		return nil
+	}
+}
+
+// PublicKeySize returns the encapsulation key size in bytes, or 0 if unknown.
+func (l MLKEMLevel) PublicKeySize() int {
+	if s := l.scheme(); s != nil {
+		return s.PublicKeySize()
+	}
+	return 0
+}
+
+// CiphertextSize returns the ciphertext size in bytes, or 0 if unknown.
+func (l MLKEMLevel) CiphertextSize() int {
+	if s := l.scheme(); s != nil {
+		return s.CiphertextSize()
+	}
+	return 0
+}
+
+// MLKEMPublicKey wraps an ML-KEM public key
 type MLKEMPublicKey struct {
-	key *mlkem1024.PublicKey
+	key   kem.PublicKey
+	level MLKEMLevel
 }
 
-// MLKEMPrivateKey wraps an ML-KEM-1024 private key
+// MLKEMPrivateKey wraps an ML-KEM private key
 type MLKEMPrivateKey struct {
-	key *mlkem1024.PrivateKey
+	key   kem.PrivateKey
+	level MLKEMLevel
 }
 
-// MLKEMKeyPair represents an ML-KEM-1024 key pair for post-quantum key encapsulation.
+// MLKEMKeyPair represents an ML-KEM key pair for post-quantum key encapsulation.
 type MLKEMKeyPair struct {
 	// EncapsulationKey is the public key used by others to encapsulate secrets
 	EncapsulationKey *MLKEMPublicKey
@@ -57,15 +116,34 @@ type MLKEMKeyPair struct {
 //
 // Returns error if the system's CSPRNG fails.
 func GenerateMLKEMKeyPair() (*MLKEMKeyPair, error) {
-	pk, sk, err := mlkem1024.GenerateKeyPair(Reader)
-	if err != nil {
+	return GenerateMLKEMKeyPairLevel(MLKEM1024)
+}
+
+// GenerateMLKEMKeyPairLevel generates a new ML-KEM key pair for the given
+// parameter set.
+func GenerateMLKEMKeyPairLevel(level MLKEMLevel) (*MLKEMKeyPair, error) {
+	scheme := level.scheme()
+	if scheme == nil {
+		return nil, qerrors.ErrKeyGenerationFailed
+	}
+
+	seed := make([]byte, scheme.SeedSize())
+	defer Zeroize(seed)
+	if _, err := io.ReadFull(Reader, seed); err != nil {
 		return nil, qerrors.NewCryptoError("MLKEMKeyPair.Generate", err)
 	}
 
+	return newMLKEMKeyPair(level, seed), nil
+}
+
+// newMLKEMKeyPair derives a key pair for level from a seed of the scheme's
+// seed size.
+func newMLKEMKeyPair(level MLKEMLevel, seed []byte) *MLKEMKeyPair {
+	pk, sk := level.scheme().DeriveKeyPair(seed)
 	return &MLKEMKeyPair{
-		EncapsulationKey: &MLKEMPublicKey{key: pk},
-		DecapsulationKey: &MLKEMPrivateKey{key: sk},
-	}, nil
+		EncapsulationKey: &MLKEMPublicKey{key: pk, level: level},
+		DecapsulationKey: &MLKEMPrivateKey{key: sk, level: level},
+	}
 }
 
 // NewMLKEMKeyPairFromSeed generates an ML-KEM-1024 key pair from a 64-byte seed.
@@ -74,35 +152,14 @@ func GenerateMLKEMKeyPair() (*MLKEMKeyPair, error) {
 // The seed should be generated from a cryptographically secure source.
 // This function is useful for key derivation from a master secret.
 func NewMLKEMKeyPairFromSeed(seed []byte) (*MLKEMKeyPair, error) {
-	if len(seed) != 64 {
+	if len(seed) != mlkem1024.KeySeedSize {
 		return nil, qerrors.ErrInvalidKeySize
 	}
 
-	// Use the seed as deterministic randomness source
-	pk, sk, err := mlkem1024.GenerateKeyPair(&deterministicReader{data: seed})
-	if err != nil {
-		return nil, qerrors.NewCryptoError("MLKEMKeyPair.FromSeed", err)
-	}
-
-	return &MLKEMKeyPair{
-		EncapsulationKey: &MLKEMPublicKey{key: pk},
-		DecapsulationKey: &MLKEMPrivateKey{key: sk},
-	}, nil
-}
-
-// deterministicReader provides deterministic "randomness" from a seed
-type deterministicReader struct {
-	data   []byte
-	offset int
-}
-
-func (r *deterministicReader) Read(p []byte) (n int, err error) {
-	n = copy(p, r.data[r.offset:])
-	r.offset += n
-	return n, nil
+	return newMLKEMKeyPair(MLKEM1024, seed), nil
 }
 
-// MLKEMEncapsulate performs key encapsulation using ML-KEM-1024.
+// MLKEMEncapsulate performs key encapsulation using the public key's ML-KEM parameter set.
 //
 // Encapsulation process:
 // 1. Sample random coins m ← {0,1}^256
@@ -114,7 +171,7 @@ func (r *deterministicReader) Read(p []byte) (n int, err error) {
 //   - ek: The recipient's encapsulation key (public key)
 //
 // Returns:
-//   - ciphertext: The encapsulated ciphertext (1568 bytes for ML-KEM-1024)
+//   - ciphertext: The encapsulated ciphertext (1568 bytes for ML-KEM-1024, 1088 for ML-KEM-768)
 //   - sharedSecret: The shared secret (32 bytes)
 //   - error: Non-nil if encapsulation fails
 func MLKEMEncapsulate(ek *MLKEMPublicKey) (ciphertext, sharedSecret []byte, err error) {
@@ -122,21 +179,24 @@ func MLKEMEncapsulate(ek *MLKEMPublicKey) (ciphertext, sharedSecret []byte, err
 		return nil, nil, qerrors.ErrInvalidPublicKey
 	}
 
-	ct := make([]byte, mlkem1024.CiphertextSize)
-	ss := make([]byte, mlkem1024.SharedKeySize)
+	scheme := ek.key.Scheme()
 
 	// Generate random seed for encapsulation
-	seed := make([]byte, mlkem1024.EncapsulationSeedSize)
+	seed := make([]byte, scheme.EncapsulationSeedSize())
+	defer Zeroize(seed)
 	if err := SecureRandom(seed); err != nil {
 		return nil, nil, qerrors.NewCryptoError("MLKEMEncapsulate", err)
 	}
 
-	ek.key.EncapsulateTo(ct, ss, seed)
+	ct, ss, err := scheme.EncapsulateDeterministically(ek.key, seed)
+	if err != nil {
+		return nil, nil, qerrors.NewCryptoError("MLKEMEncapsulate", err)
+	}
 
 	return ct, ss, nil
 }
 
-// MLKEMDecapsulate performs key decapsulation using ML-KEM-1024.
+// MLKEMDecapsulate performs key decapsulation using the private key's ML-KEM parameter set.
 //
 // Decapsulation process (IND-CCA2 secure via Fujisaki-Okamoto transform):
 // 1. Decrypt ciphertext c to obtain m'
@@ -155,17 +215,28 @@ func MLKEMEncapsulate(ek *MLKEMPublicKey) (ciphertext, sharedSecret []byte, err
 // Returns:
 //   - sharedSecret: The shared secret (32 bytes)
 //   - error: Non-nil if ciphertext is malformed
+//
+// A ciphertext of the wrong size is padded or truncated and decapsulated
+// anyway before being rejected, so its rejection takes as long as that of
+// a well-formed but invalid ciphertext.
 func MLKEMDecapsulate(dk *MLKEMPrivateKey, ciphertext []byte) ([]byte, error) {
 	if dk == nil || dk.key == nil {
 		return nil, qerrors.ErrInvalidPrivateKey
 	}
 
-	if len(ciphertext) != constants.MLKEMCiphertextSize {
-		return nil, qerrors.ErrInvalidCiphertext
-	}
+	scheme := dk.key.Scheme()
+	sizeOK := ConstantTimeLengthCheck(len(ciphertext), scheme.CiphertextSize())
+	fixed := make([]byte, scheme.CiphertextSize())
+	copy(fixed, ciphertext)
 
-	ss := make([]byte, mlkem1024.SharedKeySize)
-	dk.key.DecapsulateTo(ss, ciphertext)
+	ss, err := scheme.Decapsulate(dk.key, fixed)
+	if err != nil {
+		return nil, qerrors.NewCryptoError("MLKEMDecapsulate", err)
+	}
+	if !sizeOK {
+		Zeroize(ss)
+		return nil, qerrors.ErrInvalidCiphertextSize
+	}
 
 	return ss, nil
 }
@@ -175,11 +246,18 @@ func (pk *MLKEMPublicKey) Bytes() []byte {
 	if pk == nil || pk.key == nil {
 		return nil
 	}
-	buf := make([]byte, mlkem1024.PublicKeySize)
-	pk.key.Pack(buf)
+	buf, err := pk.key.MarshalBinary()
+	if err != nil {
+		return nil
+	}
 	return buf
 }
 
+// Level returns the ML-KEM parameter set of the public key.
+func (pk *MLKEMPublicKey) Level() MLKEMLevel {
+	return pk.level
+}
+
 // PublicKeyBytes returns the encoded bytes of the encapsulation key.
 func (kp *MLKEMKeyPair) PublicKeyBytes() []byte {
 	return kp.EncapsulationKey.Bytes()
@@ -187,16 +265,65 @@ func (kp *MLKEMKeyPair) PublicKeyBytes() []byte {
 
 // ParseMLKEMPublicKey parses an ML-KEM-1024 public key from its encoded form.
 func ParseMLKEMPublicKey(data []byte) (*MLKEMPublicKey, error) {
-	if len(data) != constants.MLKEMPublicKeySize {
+	return ParseMLKEMPublicKeyLevel(MLKEM1024, data)
+}
+
+// ParseMLKEMPublicKeyLevel parses an ML-KEM public key for the given
+// parameter set from its encoded form.
+func ParseMLKEMPublicKeyLevel(level MLKEMLevel, data []byte) (*MLKEMPublicKey, error) {
+	scheme := level.scheme()
+	if scheme == nil {
 		return nil, qerrors.ErrInvalidPublicKey
 	}
+	if len(data) != scheme.PublicKeySize() {
+		return nil, qerrors.ErrInvalidPublicKeySize
+	}
+
+	pk, err := scheme.UnmarshalBinaryPublicKey(data)
+	if err != nil {
+		return nil, qerrors.NewCryptoError("ParseMLKEMPublicKey", qerrors.ErrMalformedKey)
+	}
+
+	return &MLKEMPublicKey{key: pk, level: level}, nil
+}
+
+// Bytes returns the encoded bytes of the private key.
+// Warning: Handle with care - this exposes the secret key material.
+func (sk *MLKEMPrivateKey) Bytes() ([]byte, error) {
+	if sk == nil || sk.key == nil {
+		return nil, qerrors.ErrInvalidPrivateKey
+	}
+	buf, err := sk.key.MarshalBinary()
+	if err != nil {
+		return nil, qerrors.NewCryptoError("MLKEMPrivateKey.Bytes", err)
+	}
+	return buf, nil
+}
 
-	pk := new(mlkem1024.PublicKey)
-	if err := pk.Unpack(data); err != nil {
-		return nil, qerrors.NewCryptoError("ParseMLKEMPublicKey", err)
+// Level returns the ML-KEM parameter set of the private key.
+func (sk *MLKEMPrivateKey) Level() MLKEMLevel {
+	return sk.level
+}
+
+// Public returns the encapsulation key embedded in the private key.
+func (sk *MLKEMPrivateKey) Public() *MLKEMPublicKey {
+	return &MLKEMPublicKey{key: sk.key.Public(), level: sk.level}
+}
+
+// ParseMLKEMPrivateKeyLevel parses an ML-KEM private key for the given
+// parameter set from its encoded form.
+func ParseMLKEMPrivateKeyLevel(level MLKEMLevel, data []byte) (*MLKEMPrivateKey, error) {
+	scheme := level.scheme()
+	if scheme == nil || len(data) != scheme.PrivateKeySize() {
+		return nil, qerrors.ErrInvalidPrivateKey
+	}
+
+	sk, err := scheme.UnmarshalBinaryPrivateKey(data)
+	if err != nil {
+		return nil, qerrors.NewCryptoError("ParseMLKEMPrivateKey", err)
 	}
 
-	return &MLKEMPublicKey{key: pk}, nil
+	return &MLKEMPrivateKey{key: sk, level: level}, nil
 }
 
 // Zeroize securely erases the private key material.
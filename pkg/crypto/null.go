@@ -0,0 +1,79 @@
+// Package crypto implements the null encryption AEAD.
+//
+// This file (null.go) provides the cipher of CipherSuiteNullEncryption, for
+// lab and compliance deployments that must inspect traffic. Records are
+// authenticated but left in the clear:
+//
+//	Seal(nonce, P, AD) = P || HMAC-SHA256(key, nonce || len(AD) || len(P) || AD || P)[:16]
+//
+// The lengths are 64-bit big-endian, so the boundary between AD and P is
+// authenticated. Forgery resistance matches the other suites' 128-bit
+// tags; confidentiality is deliberately absent.
+package crypto
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// nullCipher is a cipher.AEAD that authenticates without encrypting.
+type nullCipher struct {
+	key []byte
+}
+
+// newNullCipher returns a null cipher authenticating under a copy of key.
+func newNullCipher(key []byte) *nullCipher {
+	return &nullCipher{key: append([]byte(nil), key...)}
+}
+
+// NonceSize returns the nonce size, matching the other suites.
+func (c *nullCipher) NonceSize() int {
+	return constants.AESNonceSize
+}
+
+// Overhead returns the tag size.
+func (c *nullCipher) Overhead() int {
+	return constants.AESTagSize
+}
+
+// Seal appends plaintext and its tag to dst.
+func (c *nullCipher) Seal(dst, nonce, plaintext, additionalData []byte) []byte {
+	if len(nonce) != constants.AESNonceSize {
+		panic("crypto: incorrect nonce length given to null cipher")
+	}
+	tag := c.tag(nonce, plaintext, additionalData)
+	dst = append(dst, plaintext...)
+	return append(dst, tag...)
+}
+
+// Open verifies the tag of ciphertext and appends its plaintext to dst.
+func (c *nullCipher) Open(dst, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	if len(nonce) != constants.AESNonceSize || len(ciphertext) < constants.AESTagSize {
+		return nil, qerrors.ErrAuthenticationFailed
+	}
+	plaintext := ciphertext[:len(ciphertext)-constants.AESTagSize]
+	tag := ciphertext[len(ciphertext)-constants.AESTagSize:]
+	if !hmac.Equal(c.tag(nonce, plaintext, additionalData), tag) {
+		return nil, qerrors.ErrAuthenticationFailed
+	}
+	return append(dst, plaintext...), nil
+}
+
+// tag computes the truncated HMAC over the nonce, lengths, additional data
+// and plaintext.
+func (c *nullCipher) tag(nonce, plaintext, additionalData []byte) []byte {
+	var lengths [16]byte
+	binary.BigEndian.PutUint64(lengths[:8], uint64(len(additionalData)))
+	binary.BigEndian.PutUint64(lengths[8:], uint64(len(plaintext)))
+
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(nonce)
+	mac.Write(lengths[:])
+	mac.Write(additionalData)
+	mac.Write(plaintext)
+	return mac.Sum(nil)[:constants.AESTagSize]
+}
@@ -2,8 +2,10 @@
 // This package wraps Go's standard library cryptographic functions with additional
 // safety checks and consistent error handling.
 //
-// Security Note: All random number generation uses crypto/rand which provides
-// cryptographically secure random bytes from the operating system's CSPRNG.
+// Security Note: By default all random number generation uses crypto/rand which
+// provides cryptographically secure random bytes from the operating system's
+// CSPRNG. SetRandReader replaces the source, e.g. with an HSM or, in tests,
+// with DeterministicReader.
 package crypto
 
 import (
@@ -11,17 +13,91 @@ import (
 	"crypto/subtle"
 	"io"
 	"runtime"
+	"sync"
 
+	"golang.org/x/crypto/sha3"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 )
 
+// Random source state
+var (
+	randMu     sync.RWMutex
+	randReader io.Reader = rand.Reader
+	randSet    bool      // SetRandReader was called; FIPS mode only allows this once
+)
+
+// SetRandReader replaces the random source used by SecureRandom,
+// SecureRandomBytes, Reader and key generation. A nil r restores
+// crypto/rand.Reader. Passing Reader, which reads from the installed source,
+// keeps that source.
+//
+// The source must be cryptographically secure: every key, nonce and salt is
+// drawn from it. In FIPS mode the source may only be set once, and only to a
+// reader that passes RNGHealthCheck, so an approved source cannot be
+// silently swapped later; further calls fail with ErrRandReaderLocked.
+func SetRandReader(r io.Reader) error {
+	// Installing Reader as its own source would recurse on every read
+	if _, ok := r.(randSource); ok {
+		r = currentRandReader()
+	}
+	if FIPSMode() && r != nil {
+		if result := rngHealthCheck(readFullFunc(r)); !result.Passed {
+			return qerrors.NewCryptoError("SetRandReader", result.Error)
+		}
+	}
+
+	randMu.Lock()
+	defer randMu.Unlock()
+
+	if FIPSMode() && randSet {
+		return qerrors.ErrRandReaderLocked
+	}
+	if r == nil {
+		r = rand.Reader
+	}
+	randReader = r
+	randSet = true
+	return nil
+}
+
+// currentRandReader returns the random source installed by SetRandReader.
+func currentRandReader() io.Reader {
+	randMu.RLock()
+	defer randMu.RUnlock()
+	return randReader
+}
+
+// readFullFunc returns a function that fills a slice from r.
+func readFullFunc(r io.Reader) func([]byte) error {
+	return func(b []byte) error {
+		_, err := io.ReadFull(r, b)
+		return err
+	}
+}
+
+// DeterministicReader returns a reader whose output is fully determined by
+// seed: the SHAKE-256 stream of the seed under a dedicated domain separator.
+//
+// WARNING: For tests and known-answer vectors only. Installing it with
+// SetRandReader makes every key predictable to anyone who knows the seed.
+func DeterministicReader(seed []byte) io.Reader {
+	h := sha3.NewShake256()
+	// Note: sha3.ShakeHash.Write never returns an error (in-memory operation)
+	_, _ = h.Write([]byte(constants.DomainSeparatorDeterministicRNG))
+	_, _ = h.Write(seed)
+	return h
+}
+
 // SecureRandom reads cryptographically secure random bytes into the provided slice.
-// It uses crypto/rand.Read which sources entropy from the OS CSPRNG.
+// It reads from the source installed by SetRandReader, crypto/rand.Reader by
+// default, which sources entropy from the OS CSPRNG.
 //
-// This function will only return an error if the system's random number generator
+// This function will only return an error if the random number generator
 // fails, which should be treated as a critical system failure.
 func SecureRandom(b []byte) error {
-	_, err := io.ReadFull(rand.Reader, b)
+	_, err := io.ReadFull(currentRandReader(), b)
 	if err != nil {
 		return qerrors.NewCryptoError("SecureRandom", err)
 	}
@@ -57,8 +133,15 @@ func MustSecureRandomBytes(n int) []byte {
 }
 
 // Reader is an io.Reader that returns cryptographically secure random bytes.
-// It wraps crypto/rand.Reader for consistent error handling.
-var Reader = rand.Reader
+// It reads from the source installed by SetRandReader.
+var Reader io.Reader = randSource{}
+
+// randSource reads from the current random source.
+type randSource struct{}
+
+func (randSource) Read(b []byte) (int, error) {
+	return currentRandReader().Read(b)
+}
 
 // ConstantTimeCompare compares two byte slices in constant time.
 // Returns true if the slices are equal, false otherwise.
@@ -67,6 +150,17 @@ func ConstantTimeCompare(a, b []byte) bool {
 	return subtle.ConstantTimeCompare(a, b) == 1
 }
 
+// ConstantTimeLengthCheck reports whether got equals want without branching
+// on either value. Use it where a length check must not take a different
+// path from the rest of validation, so rejections cannot be told apart by
+// timing.
+func ConstantTimeLengthCheck(got, want int) bool {
+	//nolint:gosec // G115: only equality of the bit patterns matters
+	diff := uint64(got) ^ uint64(want)
+	//nolint:gosec // G115: folding 64 bits into 32 preserves zero/non-zero
+	return subtle.ConstantTimeEq(int32(uint32(diff)|uint32(diff>>32)), 0) == 1
+}
+
 // Zeroize securely erases sensitive data from memory by overwriting with zeros.
 // This should be called on sensitive keys and secrets when they are no longer needed.
 //
@@ -0,0 +1,127 @@
+// Package crypto implements memory-locked buffers for secrets.
+//
+// This file (securebytes.go) provides SecureBytes, a buffer whose backing
+// memory is allocated outside the Go heap and locked with mlock where the
+// platform allows, so secrets stored in it are not written to swap. The
+// buffer is zeroized when freed, or by a cleanup if it becomes unreachable.
+//
+// Locking is best effort: unprivileged processes may exceed RLIMIT_MEMLOCK
+// (EPERM/ENOMEM) and some platforms have no mlock at all. The buffer then
+// still works, unlocked, and LockErr reports why so callers can warn.
+package crypto
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+)
+
+// errMemoryLockUnsupported is reported by LockErr on platforms without mlock.
+var errMemoryLockUnsupported = errors.New("crypto: memory locking not supported on this platform")
+
+// SecureBytes is a fixed-size buffer for secret data that is locked in
+// memory where supported and zeroized by Free.
+//
+// The zero value and nil are empty buffers. A SecureBytes is safe for
+// concurrent use, but the slice returned by Bytes is not protected.
+type SecureBytes struct {
+	mu      sync.Mutex
+	mem     secureMem
+	freed   bool
+	cleanup runtime.Cleanup
+}
+
+// secureMem is the backing memory of a SecureBytes.
+type secureMem struct {
+	buf     []byte
+	mapped  bool  // buf was allocated by mapSecure
+	lockErr error // Why buf is not locked, nil if locked
+}
+
+// NewSecureBytes allocates a zeroed n-byte buffer and attempts to lock it
+// in memory. The buffer is usable even if locking fails; see LockErr.
+func NewSecureBytes(n int) *SecureBytes {
+	s := &SecureBytes{mem: allocSecure(max(n, 0))}
+	s.cleanup = runtime.AddCleanup(s, freeSecure, s.mem)
+	return s
+}
+
+// allocSecure allocates n bytes, preferring locked memory outside the heap.
+func allocSecure(n int) secureMem {
+	if n == 0 {
+		return secureMem{buf: []byte{}}
+	}
+	buf, err := mapSecure(n)
+	if err != nil {
+		return secureMem{buf: make([]byte, n), lockErr: err}
+	}
+	return secureMem{buf: buf, mapped: true, lockErr: mlock(buf)}
+}
+
+// freeSecure zeroizes and releases memory from allocSecure.
+func freeSecure(m secureMem) {
+	Zeroize(m.buf)
+	if !m.mapped {
+		return
+	}
+	if m.lockErr == nil {
+		_ = munlock(m.buf)
+	}
+	_ = unmapSecure(m.buf)
+}
+
+// Bytes returns the buffer's contents, or nil once freed. The slice must
+// not be used after Free.
+func (s *SecureBytes) Bytes() []byte {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.freed {
+		return nil
+	}
+	return s.mem.buf
+}
+
+// Len returns the buffer size, or 0 once freed.
+func (s *SecureBytes) Len() int {
+	return len(s.Bytes())
+}
+
+// Locked reports whether the buffer's memory is locked against swapping.
+func (s *SecureBytes) Locked() bool {
+	if s == nil {
+		return false
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return !s.freed && s.mem.mapped && s.mem.lockErr == nil
+}
+
+// LockErr returns why the buffer's memory could not be locked, or nil if it
+// is locked or empty.
+func (s *SecureBytes) LockErr() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mem.lockErr
+}
+
+// Free zeroizes the buffer and releases its memory. It is safe to call more
+// than once.
+func (s *SecureBytes) Free() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.freed {
+		return
+	}
+	s.freed = true
+	s.cleanup.Stop()
+	freeSecure(s.mem)
+}
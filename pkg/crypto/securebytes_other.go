@@ -0,0 +1,14 @@
+//go:build !linux && !darwin
+// +build !linux,!darwin
+
+package crypto
+
+// Memory primitives, variables so tests can simulate failures. Memory
+// cannot be mapped outside the Go heap, so SecureBytes falls back to
+// unlocked heap memory.
+var (
+	mlock       = func([]byte) error { return errMemoryLockUnsupported }
+	munlock     = func([]byte) error { return errMemoryLockUnsupported }
+	mapSecure   = func(int) ([]byte, error) { return nil, errMemoryLockUnsupported }
+	unmapSecure = func([]byte) error { return errMemoryLockUnsupported }
+)
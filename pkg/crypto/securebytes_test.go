@@ -0,0 +1,85 @@
+package crypto
+
+import (
+	"bytes"
+	"errors"
+	"syscall"
+	"testing"
+)
+
+func TestSecureBytesZeroizesOnFree(t *testing.T) {
+	// Keep freed memory mapped so it can be inspected
+	origUnmap := unmapSecure
+	unmapSecure = func([]byte) error { return nil }
+	defer func() { unmapSecure = origUnmap }()
+
+	s := NewSecureBytes(64)
+	if s.Len() != 64 {
+		t.Fatalf("Len: got %d, want 64", s.Len())
+	}
+	if s.Locked() != (s.LockErr() == nil) {
+		t.Errorf("Locked() = %v inconsistent with LockErr() = %v", s.Locked(), s.LockErr())
+	}
+
+	buf := s.Bytes()
+	if !bytes.Equal(buf, make([]byte, 64)) {
+		t.Error("new buffer should be zeroed")
+	}
+	copy(buf, bytes.Repeat([]byte{0xAB}, 64))
+	if !bytes.Equal(s.Bytes(), buf) {
+		t.Error("Bytes should return the written secret")
+	}
+
+	s.Free()
+	if !bytes.Equal(buf, make([]byte, 64)) {
+		t.Error("Free did not zeroize the buffer")
+	}
+	if s.mem.mapped {
+		_ = origUnmap(buf)
+	}
+	if s.Bytes() != nil || s.Len() != 0 || s.Locked() {
+		t.Error("freed buffer should be empty and unlocked")
+	}
+	s.Free() // Safe to call again
+}
+
+func TestSecureBytesMlockEPERM(t *testing.T) {
+	origLock, origUnlock := mlock, munlock
+	mlock = func([]byte) error { return syscall.EPERM }
+	munlock = func([]byte) error {
+		t.Error("munlock called on memory that was never locked")
+		return nil
+	}
+	defer func() { mlock, munlock = origLock, origUnlock }()
+
+	s := NewSecureBytes(32)
+	if s.Locked() {
+		t.Error("buffer should not be locked when mlock fails")
+	}
+	if err := s.LockErr(); err == nil {
+		t.Error("LockErr should report the mlock failure")
+	} else if !errors.Is(err, syscall.EPERM) && !errors.Is(err, errMemoryLockUnsupported) {
+		t.Errorf("unexpected LockErr: %v", err)
+	}
+
+	// The unlocked buffer remains usable
+	copy(s.Bytes(), "secret")
+	if !bytes.HasPrefix(s.Bytes(), []byte("secret")) {
+		t.Error("unlocked buffer should hold data")
+	}
+	s.Free()
+}
+
+func TestSecureBytesNilAndEmpty(t *testing.T) {
+	var s *SecureBytes
+	if s.Bytes() != nil || s.Len() != 0 || s.Locked() || s.LockErr() != nil {
+		t.Error("nil SecureBytes should be empty")
+	}
+	s.Free()
+
+	empty := NewSecureBytes(0)
+	if empty.Len() != 0 || empty.LockErr() != nil {
+		t.Errorf("empty buffer: Len %d, LockErr %v", empty.Len(), empty.LockErr())
+	}
+	empty.Free()
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin
+// +build linux darwin
+
+package crypto
+
+import "golang.org/x/sys/unix"
+
+// Memory primitives, variables so tests can simulate failures.
+var (
+	mlock       = unix.Mlock
+	munlock     = unix.Munlock
+	unmapSecure = unix.Munmap
+)
+
+// mapSecure maps n bytes of private anonymous memory outside the Go heap.
+var mapSecure = func(n int) ([]byte, error) {
+	return unix.Mmap(-1, 0, n, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+}
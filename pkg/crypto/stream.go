@@ -0,0 +1,293 @@
+// Package crypto implements streaming authenticated encryption.
+//
+// This file (stream.go) provides io.Writer and io.Reader wrappers that encrypt
+// arbitrarily long streams as a sequence of fixed-size AEAD frames.
+//
+// Stream Format:
+//
+//	Salt(32) || Frame_0 || Frame_1 || ... || Frame_n
+//	Frame = Header(4) || Ciphertext(Length) || Tag(16)
+//	Header = Final(1 bit) || Length(31 bits)
+//
+// Security Properties:
+//   - Each stream uses a fresh subkey derived from the caller's key and a
+//     random salt, so a key may safely encrypt multiple streams
+//   - Frame i is sealed with nonce = 0^32 || i, so nonces never repeat
+//   - The AAD binds the frame counter and header, so reordered, dropped, or
+//     duplicated frames fail authentication
+//   - Only the last frame carries the final flag, so truncation at a frame
+//     boundary is detected as ErrStreamTruncated
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+const (
+	// streamHeaderSize is the size of the per-frame header.
+	streamHeaderSize = 4
+
+	// streamFinalFlag marks the last frame of a stream in the header.
+	streamFinalFlag = 1 << 31
+
+	// streamAADSize is the size of the per-frame additional data.
+	streamAADSize = 8 + streamHeaderSize
+)
+
+// SealingWriter encrypts a stream written to it in fixed-size frames.
+//
+// Close must be called to emit the final frame; a stream that is not closed
+// will be rejected by the reader as truncated.
+type SealingWriter struct {
+	w       io.Writer
+	aead    *AEAD
+	buf     []byte
+	frame   []byte
+	counter uint64
+	closed  bool
+	err     error
+}
+
+// NewSealingWriter returns a writer that encrypts everything written to it
+// and writes the sealed stream to w.
+//
+// A random salt is written to w immediately. The key may be reused across
+// streams since each stream derives its own subkey from the salt.
+//
+// Parameters:
+//   - suite: CipherSuiteAES256GCM, CipherSuiteAES128GCM or CipherSuiteChaCha20Poly1305
+//   - key: Encryption key of suite.KeySize() bytes
+//   - w: Destination for the sealed stream
+//
+// Returns:
+//   - SealingWriter: The stream writer; Close must be called when done
+//   - error: Non-nil if the key or suite is invalid or the salt cannot be written
+func NewSealingWriter(suite constants.CipherSuite, key []byte, w io.Writer) (*SealingWriter, error) {
+	salt := make([]byte, constants.StreamSaltSize)
+	if err := SecureRandom(salt); err != nil {
+		return nil, err
+	}
+
+	aead, err := newStreamAEAD(suite, key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(salt); err != nil {
+		return nil, err
+	}
+
+	return &SealingWriter{
+		w:     w,
+		aead:  aead,
+		buf:   make([]byte, 0, constants.StreamChunkSize),
+		frame: make([]byte, 0, streamHeaderSize+constants.StreamChunkSize+constants.AESTagSize),
+	}, nil
+}
+
+// Write encrypts p into the stream. Data is buffered until a full frame is
+// available or Close is called.
+func (s *SealingWriter) Write(p []byte) (int, error) {
+	if s.err != nil {
+		return 0, s.err
+	}
+	if s.closed {
+		return 0, qerrors.ErrStreamClosed
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only flushed once more data arrives, since the
+		// last frame must carry the final flag.
+		if len(s.buf) == constants.StreamChunkSize {
+			if err := s.sealFrame(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):constants.StreamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals any buffered data as the final frame. It does not close the
+// underlying writer.
+func (s *SealingWriter) Close() error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.sealFrame(true)
+}
+
+// sealFrame encrypts the buffered data as the next frame and writes it out.
+func (s *SealingWriter) sealFrame(final bool) error {
+	if s.counter == ^uint64(0) {
+		s.err = qerrors.ErrNonceExhausted
+		return s.err
+	}
+
+	//nolint:gosec // G115: buffer length is bounded by StreamChunkSize
+	header := uint32(len(s.buf))
+	if final {
+		header |= streamFinalFlag
+	}
+
+	nonce, aad := streamNonceAndAAD(s.counter, header)
+
+	s.frame = s.frame[:streamHeaderSize]
+	binary.BigEndian.PutUint32(s.frame, header)
+	s.frame = s.aead.cipher.Seal(s.frame, nonce[:], s.buf, aad[:])
+
+	if _, err := s.w.Write(s.frame); err != nil {
+		s.err = err
+		return err
+	}
+
+	s.counter++
+	s.buf = s.buf[:0]
+	return nil
+}
+
+// OpeningReader decrypts a stream produced by SealingWriter.
+//
+// Plaintext is only returned after its frame has been authenticated. Any
+// authentication failure is sticky: subsequent reads return the same error.
+type OpeningReader struct {
+	r       io.Reader
+	aead    *AEAD
+	pending []byte
+	frame   []byte
+	plain   []byte
+	counter uint64
+	done    bool
+	err     error
+}
+
+// NewOpeningReader returns a reader that decrypts the sealed stream read
+// from r. The stream salt is read from r immediately.
+//
+// Parameters:
+//   - suite: Cipher suite used by the writer
+//   - key: Key used by the writer
+//   - r: Source of the sealed stream
+//
+// Returns:
+//   - OpeningReader: The stream reader
+//   - error: Non-nil if the key or suite is invalid or the salt cannot be read
+func NewOpeningReader(suite constants.CipherSuite, key []byte, r io.Reader) (*OpeningReader, error) {
+	salt := make([]byte, constants.StreamSaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, streamReadError(err)
+	}
+
+	aead, err := newStreamAEAD(suite, key, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &OpeningReader{
+		r:     r,
+		aead:  aead,
+		frame: make([]byte, constants.StreamChunkSize+constants.AESTagSize),
+		plain: make([]byte, 0, constants.StreamChunkSize),
+	}, nil
+}
+
+// Read reads decrypted plaintext from the stream. It returns io.EOF only
+// after the final frame has been authenticated.
+func (o *OpeningReader) Read(p []byte) (int, error) {
+	for len(o.pending) == 0 {
+		if o.err != nil {
+			return 0, o.err
+		}
+		if o.done {
+			return 0, io.EOF
+		}
+		if err := o.openFrame(); err != nil {
+			o.err = err
+			return 0, err
+		}
+	}
+
+	n := copy(p, o.pending)
+	o.pending = o.pending[n:]
+	return n, nil
+}
+
+// openFrame reads, authenticates, and decrypts the next frame.
+func (o *OpeningReader) openFrame() error {
+	var hdr [streamHeaderSize]byte
+	if _, err := io.ReadFull(o.r, hdr[:]); err != nil {
+		return streamReadError(err)
+	}
+
+	header := binary.BigEndian.Uint32(hdr[:])
+	final := header&streamFinalFlag != 0
+	length := int(header &^ streamFinalFlag)
+	if length > constants.StreamChunkSize {
+		return qerrors.ErrAuthenticationFailed
+	}
+
+	ciphertext := o.frame[:length+constants.AESTagSize]
+	if _, err := io.ReadFull(o.r, ciphertext); err != nil {
+		return streamReadError(err)
+	}
+
+	nonce, aad := streamNonceAndAAD(o.counter, header)
+	plaintext, err := o.aead.cipher.Open(o.plain[:0], nonce[:], ciphertext, aad[:])
+	if err != nil {
+		return qerrors.ErrAuthenticationFailed
+	}
+
+	o.counter++
+	o.pending = plaintext
+	o.done = final
+	return nil
+}
+
+// newStreamAEAD derives the per-stream subkey and returns a cipher for it.
+func newStreamAEAD(suite constants.CipherSuite, key, salt []byte) (*AEAD, error) {
+	keySize := suite.KeySize()
+	if keySize == 0 {
+		return nil, qerrors.ErrUnsupportedCipherSuite
+	}
+	if len(key) != keySize {
+		return nil, qerrors.ErrInvalidKeySize
+	}
+
+	subkey, err := DeriveKeyMultiple(constants.DomainSeparatorStream, [][]byte{key, salt}, keySize)
+	if err != nil {
+		return nil, err
+	}
+	defer Zeroize(subkey)
+
+	return NewAEAD(suite, subkey)
+}
+
+// streamNonceAndAAD builds the nonce and additional data for a frame.
+func streamNonceAndAAD(counter uint64, header uint32) (nonce [constants.AESNonceSize]byte, aad [streamAADSize]byte) {
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	binary.BigEndian.PutUint64(aad[:8], counter)
+	binary.BigEndian.PutUint32(aad[8:], header)
+	return nonce, aad
+}
+
+// streamReadError reports an early end of input as a truncated stream.
+func streamReadError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return qerrors.ErrStreamTruncated
+	}
+	return err
+}
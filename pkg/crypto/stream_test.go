@@ -0,0 +1,252 @@
+package crypto_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+const (
+	streamSaltSize  = constants.StreamSaltSize
+	streamFrameSize = 4 + constants.StreamChunkSize + constants.AESTagSize
+)
+
+func streamKey(t *testing.T) []byte {
+	t.Helper()
+	key := make([]byte, constants.AESKeySize)
+	if err := crypto.SecureRandom(key); err != nil {
+		t.Fatalf("SecureRandom failed: %v", err)
+	}
+	return key
+}
+
+func sealStream(t *testing.T, suite constants.CipherSuite, key, plaintext []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := crypto.NewSealingWriter(suite, key, &buf)
+	if err != nil {
+		t.Fatalf("NewSealingWriter failed: %v", err)
+	}
+	if _, err := w.Write(plaintext); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// openStream reads the stream to its end, returning the plaintext delivered
+// before any error.
+func openStream(t *testing.T, suite constants.CipherSuite, key, sealed []byte) ([]byte, error) {
+	t.Helper()
+	r, err := crypto.NewOpeningReader(suite, key, bytes.NewReader(sealed))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStreamRoundTripLarge(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping 100 MiB stream in short mode")
+	}
+
+	const size = 100 << 20
+	key := streamKey(t)
+
+	pr, pw := io.Pipe()
+	srcHash := sha256.New()
+	errCh := make(chan error, 1)
+
+	go func() {
+		w, err := crypto.NewSealingWriter(constants.CipherSuiteAES256GCM, key, pw)
+		if err != nil {
+			pw.CloseWithError(err)
+			errCh <- err
+			return
+		}
+
+		// Write in odd-sized pieces so frames do not align with writes
+		chunk := make([]byte, 100_003)
+		for written := 0; written < size; {
+			n := len(chunk)
+			if size-written < n {
+				n = size - written
+			}
+			for i := range chunk[:n] {
+				chunk[i] = byte(written + i)
+			}
+			srcHash.Write(chunk[:n])
+			if _, err := w.Write(chunk[:n]); err != nil {
+				pw.CloseWithError(err)
+				errCh <- err
+				return
+			}
+			written += n
+		}
+		err = w.Close()
+		pw.CloseWithError(err)
+		errCh <- err
+	}()
+
+	r, err := crypto.NewOpeningReader(constants.CipherSuiteAES256GCM, key, pr)
+	if err != nil {
+		t.Fatalf("NewOpeningReader failed: %v", err)
+	}
+	dstHash := sha256.New()
+	n, err := io.Copy(dstHash, r)
+	if err != nil {
+		t.Fatalf("reading stream failed: %v", err)
+	}
+	if err := <-errCh; err != nil {
+		t.Fatalf("writing stream failed: %v", err)
+	}
+
+	if n != size {
+		t.Fatalf("read %d bytes, want %d", n, size)
+	}
+	if !bytes.Equal(srcHash.Sum(nil), dstHash.Sum(nil)) {
+		t.Error("decrypted stream does not match input")
+	}
+}
+
+func TestStreamRoundTripSizes(t *testing.T) {
+	key := streamKey(t)
+	chunk := constants.StreamChunkSize
+
+	for _, suite := range []constants.CipherSuite{constants.CipherSuiteAES256GCM, constants.CipherSuiteChaCha20Poly1305} {
+		if crypto.FIPSMode() && !suite.IsFIPSApproved() {
+			continue
+		}
+		for _, size := range []int{0, 1, chunk - 1, chunk, chunk + 1, 3 * chunk} {
+			plaintext := bytes.Repeat([]byte{0xA5}, size)
+			sealed := sealStream(t, suite, key, plaintext)
+
+			got, err := openStream(t, suite, key, sealed)
+			if err != nil {
+				t.Fatalf("%s size %d: read failed: %v", suite, size, err)
+			}
+			if !bytes.Equal(got, plaintext) {
+				t.Errorf("%s size %d: plaintext mismatch", suite, size)
+			}
+		}
+	}
+}
+
+func TestStreamTamperedChunk(t *testing.T) {
+	key := streamKey(t)
+	chunk := constants.StreamChunkSize
+	plaintext := make([]byte, 4*chunk+100)
+	sealed := sealStream(t, constants.CipherSuiteAES256GCM, key, plaintext)
+
+	// Flip a ciphertext byte inside the third frame
+	const tampered = 2
+	sealed[streamSaltSize+tampered*streamFrameSize+100] ^= 0x01
+
+	got, err := openStream(t, constants.CipherSuiteAES256GCM, key, sealed)
+	if !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Fatalf("expected ErrAuthenticationFailed, got %v", err)
+	}
+	if len(got) != tampered*chunk {
+		t.Errorf("delivered %d bytes before failure, want exactly %d", len(got), tampered*chunk)
+	}
+}
+
+func TestStreamTruncation(t *testing.T) {
+	key := streamKey(t)
+	chunk := constants.StreamChunkSize
+	sealed := sealStream(t, constants.CipherSuiteAES256GCM, key, make([]byte, 3*chunk+10))
+
+	tests := []struct {
+		name string
+		len  int
+	}{
+		{"drop final frame", streamSaltSize + 3*streamFrameSize},
+		{"mid final frame", len(sealed) - 5},
+		{"mid header", streamSaltSize + streamFrameSize + 2},
+		{"salt only", streamSaltSize},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openStream(t, constants.CipherSuiteAES256GCM, key, sealed[:tt.len])
+			if !errors.Is(err, qerrors.ErrStreamTruncated) {
+				t.Errorf("expected ErrStreamTruncated, got %v", err)
+			}
+		})
+	}
+
+	if _, err := crypto.NewOpeningReader(constants.CipherSuiteAES256GCM, key, bytes.NewReader(sealed[:10])); !errors.Is(err, qerrors.ErrStreamTruncated) {
+		t.Errorf("expected ErrStreamTruncated for short salt, got %v", err)
+	}
+}
+
+func TestStreamReorderedAndDroppedChunks(t *testing.T) {
+	key := streamKey(t)
+	chunk := constants.StreamChunkSize
+	sealed := sealStream(t, constants.CipherSuiteAES256GCM, key, make([]byte, 3*chunk+10))
+
+	salt := sealed[:streamSaltSize]
+	frame := func(i int) []byte {
+		start := streamSaltSize + i*streamFrameSize
+		return sealed[start : start+streamFrameSize]
+	}
+	final := sealed[streamSaltSize+3*streamFrameSize:]
+
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tests := []struct {
+		name   string
+		stream []byte
+	}{
+		{"swapped", join(salt, frame(1), frame(0), frame(2), final)},
+		{"dropped", join(salt, frame(0), frame(2), final)},
+		{"duplicated", join(salt, frame(0), frame(0), frame(1), frame(2), final)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := openStream(t, constants.CipherSuiteAES256GCM, key, tt.stream)
+			if !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+				t.Errorf("expected ErrAuthenticationFailed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestStreamWrongKey(t *testing.T) {
+	sealed := sealStream(t, constants.CipherSuiteAES256GCM, streamKey(t), []byte("secret"))
+
+	_, err := openStream(t, constants.CipherSuiteAES256GCM, streamKey(t), sealed)
+	if !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("expected ErrAuthenticationFailed, got %v", err)
+	}
+}
+
+func TestStreamWriteAfterClose(t *testing.T) {
+	w, err := crypto.NewSealingWriter(constants.CipherSuiteAES256GCM, streamKey(t), io.Discard)
+	if err != nil {
+		t.Fatalf("NewSealingWriter failed: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := w.Write([]byte("late")); !errors.Is(err, qerrors.ErrStreamClosed) {
+		t.Errorf("expected ErrStreamClosed, got %v", err)
+	}
+}
+
+func TestStreamInvalidKey(t *testing.T) {
+	if _, err := crypto.NewSealingWriter(constants.CipherSuiteAES256GCM, make([]byte, 16), io.Discard); err == nil {
+		t.Error("expected error for short key")
+	}
+}
@@ -0,0 +1,73 @@
+package crypto_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+// Timing tests here are best-effort smoke tests, not a constant-time proof.
+// Go offers no control over scheduling, frequency scaling, garbage
+// collection or cache state, so sub-microsecond leaks are far below the
+// noise they can resolve. They only catch gross differences, such as a
+// rejection path that skips the expensive work entirely. Use a dedicated
+// tool such as dudect on a quiet machine for real timing analysis.
+
+// timingSamples is the number of interleaved measurements per input.
+const timingSamples = 201
+
+// timingMaxRatio is the largest tolerated ratio between median timings.
+const timingMaxRatio = 3.0
+
+// medianDuration returns the median of samples.
+func medianDuration(samples []time.Duration) time.Duration {
+	sorted := slices.Clone(samples)
+	slices.Sort(sorted)
+	return sorted[len(sorted)/2]
+}
+
+func TestMLKEMDecapsulateTiming(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping timing test in short mode")
+	}
+
+	kp, err := crypto.GenerateMLKEMKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateMLKEMKeyPair failed: %v", err)
+	}
+	valid, _, err := crypto.MLKEMEncapsulate(kp.EncapsulationKey)
+	if err != nil {
+		t.Fatalf("MLKEMEncapsulate failed: %v", err)
+	}
+
+	inputs := []struct {
+		name       string
+		ciphertext []byte
+	}{
+		{"valid", valid},
+		{"truncated", valid[:len(valid)-1]},
+		{"padded", append(slices.Clone(valid), make([]byte, 32)...)},
+	}
+
+	// Interleave inputs so drift in machine load affects them equally
+	samples := make([][]time.Duration, len(inputs))
+	for range timingSamples {
+		for i, input := range inputs {
+			start := time.Now()
+			_, _ = crypto.MLKEMDecapsulate(kp.DecapsulationKey, input.ciphertext)
+			samples[i] = append(samples[i], time.Since(start))
+		}
+	}
+
+	base := medianDuration(samples[0])
+	for i, input := range inputs[1:] {
+		median := medianDuration(samples[i+1])
+		ratio := float64(max(base, median)) / float64(max(min(base, median), 1))
+		t.Logf("%s: median %v (valid %v)", input.name, median, base)
+		if ratio > timingMaxRatio {
+			t.Errorf("%s ciphertext median %v differs from valid %v by %.1fx", input.name, median, base, ratio)
+		}
+	}
+}
@@ -47,7 +47,15 @@ type X25519KeyPair struct {
 func GenerateX25519KeyPair() (*X25519KeyPair, error) {
 	curve := ecdh.X25519()
 
-	privateKey, err := curve.GenerateKey(Reader)
+	// Read the scalar from SecureRandom rather than passing Reader to
+	// GenerateKey, which may ignore custom random sources
+	scalar := make([]byte, constants.X25519PrivateKeySize)
+	defer Zeroize(scalar)
+	if err := SecureRandom(scalar); err != nil {
+		return nil, qerrors.NewCryptoError("X25519KeyPair.Generate", err)
+	}
+
+	privateKey, err := curve.NewPrivateKey(scalar)
 	if err != nil {
 		return nil, qerrors.NewCryptoError("X25519KeyPair.Generate", err)
 	}
@@ -124,13 +132,13 @@ func (kp *X25519KeyPair) PrivateKeyBytes() []byte {
 // ParseX25519PublicKey parses an X25519 public key from its encoded form.
 func ParseX25519PublicKey(data []byte) (*ecdh.PublicKey, error) {
 	if len(data) != constants.X25519PublicKeySize {
-		return nil, qerrors.ErrInvalidPublicKey
+		return nil, qerrors.ErrInvalidPublicKeySize
 	}
 
 	curve := ecdh.X25519()
 	publicKey, err := curve.NewPublicKey(data)
 	if err != nil {
-		return nil, qerrors.NewCryptoError("ParseX25519PublicKey", err)
+		return nil, qerrors.NewCryptoError("ParseX25519PublicKey", qerrors.ErrMalformedKey)
 	}
 
 	return publicKey, nil
@@ -56,6 +56,27 @@
 //	exporter := metrics.NewPrometheusExporter(collector, "quantum_vpn")
 //	http.Handle("/metrics", exporter.Handler())
 //
+// Scrapers that accept application/openmetrics-text are served OpenMetrics
+// instead, where each handshake latency bucket carries the trace ID of its
+// most recent traced handshake as an exemplar.
+//
+// # JSON Export
+//
+// Serve snapshots as JSON for scripts and ad-hoc dashboards. Field names are
+// snake_case and histograms include their buckets, count, and sum:
+//
+//	http.Handle("/metrics.json", metrics.NewJSONExporter(collector).Handler())
+//
+// # StatsD Export
+//
+// Push metrics to a StatsD or DogStatsD server. Labels become DogStatsD tags
+// and counters are sent as deltas between flushes:
+//
+//	exporter, err := metrics.NewStatsDExporter("127.0.0.1:8125", "quantum_vpn",
+//		metrics.WithStatsDCollector(collector),
+//		metrics.WithStatsDInterval(10*time.Second))
+//	defer exporter.Close()
+//
 // # Tracing
 //
 // The package provides a Tracer interface compatible with OpenTelemetry:
@@ -76,6 +97,22 @@
 //	// Use with OpenTelemetry SDK (implement the Tracer interface)
 //	// metrics.SetTracer(myOTelAdapter)
 //
+// # Per-Session Observers
+//
+// Feed a collector from every tunnel session without wiring observers by
+// hand. WithSessionLabel also gives each session its own labelled collector:
+//
+//	config := tunnel.DefaultTransportConfig()
+//	config.ObserverFactory = metrics.NewSessionObserverFactory(collector,
+//		metrics.WithSessionLabel("session_id", func(id string, c *metrics.Collector) {
+//			// Export c, e.g. with its own PrometheusExporter
+//		}))
+//	listener.SetConfig(config)
+//
+// Per-session series are opt-in because their number grows with the number
+// of sessions: WithSessionLabel creates them explicitly, and
+// WithRemainingCapacity adds an aead_remaining_capacity gauge per session.
+//
 // # Structured Logging
 //
 // The Logger provides structured logging with levels:
@@ -95,20 +132,36 @@
 //	sessionLog := logger.Named("session").With(metrics.Fields{"id": sessionID})
 //	sessionLog.Debug("encrypting data")
 //
+// Sampling and rate limiting keep floods, such as replay warnings during an
+// attack, from overwhelming the output. Both apply per level, and dropped
+// messages are reported by a periodic "suppressed N messages" line:
+//
+//	logger := metrics.NewLogger(
+//		metrics.WithSampling(10),    // 1 of every 10 messages
+//		metrics.WithRateLimit(100),  // then at most 100 per second
+//	)
+//
 // # Health Checks
 //
 // Provide health check endpoints for Kubernetes and load balancers:
 //
 //	health := metrics.NewHealthCheck(collector, "1.0.0")
-//	health.AddCheck("crypto", func() error {
-//		// Verify crypto subsystem
-//		return nil
+//	health.AddCheck("crypto", metrics.CryptoHealthCheck())
+//	health.AddCheck("database", func() error {
+//		return db.Ping()
 //	})
 //
 //	http.Handle("/health", health.Handler())
 //	http.Handle("/healthz", health.LivenessHandler())
 //	http.Handle("/readyz", health.ReadinessHandler())
 //
+// The same checks can be served over the gRPC health protocol
+// (grpc.health.v1.Health) when built with -tags grpc, which needs the
+// google.golang.org/grpc module. The empty service name reports the overall
+// status and other names report individual checks:
+//
+//	healthpb.RegisterHealthServer(grpcServer, metrics.NewGRPCHealthServer(health))
+//
 // # Observability Server
 //
 // Start a complete observability server:
@@ -118,6 +171,7 @@
 //		Version:          "1.0.0",
 //		Namespace:        "quantum_vpn",
 //		EnablePrometheus: true,
+//		EnableJSON:       true,
 //		EnableHealth:     true,
 //	})
 //
@@ -125,6 +179,7 @@
 //
 // This provides:
 //   - /metrics - Prometheus metrics
+//   - /metrics.json - JSON metrics snapshot
 //   - /health  - Detailed health status
 //   - /healthz - Kubernetes liveness probe
 //   - /readyz  - Kubernetes readiness probe
@@ -0,0 +1,103 @@
+//go:build grpc
+// +build grpc
+
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultGRPCWatchInterval is how often Watch re-runs the health checks.
+const DefaultGRPCWatchInterval = time.Second
+
+// GRPCHealthServer serves the gRPC health protocol (grpc.health.v1.Health)
+// from a HealthCheck.
+//
+// The empty service name reports the overall status and any other name
+// reports the check registered under it with AddCheck. As with
+// ReadinessHandler, unhealthy maps to NOT_SERVING while healthy and degraded
+// map to SERVING.
+type GRPCHealthServer struct {
+	healthpb.UnimplementedHealthServer
+
+	hc       *HealthCheck
+	interval time.Duration
+}
+
+// NewGRPCHealthServer creates a gRPC health service backed by hc.
+func NewGRPCHealthServer(hc *HealthCheck) *GRPCHealthServer {
+	return &GRPCHealthServer{
+		hc:       hc,
+		interval: DefaultGRPCWatchInterval,
+	}
+}
+
+// SetWatchInterval sets how often Watch streams re-run the checks to detect
+// status changes, DefaultGRPCWatchInterval by default. Adding or removing a
+// check is reported without waiting for the interval. It must be called
+// before the server is registered.
+func (s *GRPCHealthServer) SetWatchInterval(d time.Duration) {
+	if d > 0 {
+		s.interval = d
+	}
+}
+
+// Check returns the current serving status of the requested service.
+// Unknown services fail with codes.NotFound.
+func (s *GRPCHealthServer) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, ok := s.servingStatus(req.GetService())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+// Watch streams the serving status of the requested service, sending the
+// current status immediately and again whenever it changes. Checks are re-run
+// every watch interval (see SetWatchInterval) and whenever a check is added
+// or removed. Unknown services report SERVICE_UNKNOWN until a check is
+// registered under that name.
+func (s *GRPCHealthServer) Watch(req *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	var last healthpb.HealthCheckResponse_ServingStatus
+	sent := false
+	for {
+		changed := s.hc.changes()
+		st, ok := s.servingStatus(req.GetService())
+		if !ok {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+		if !sent || st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last, sent = st, true
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.FromContextError(stream.Context().Err()).Err()
+		case <-ticker.C:
+		case <-changed:
+		}
+	}
+}
+
+// servingStatus maps the HealthCheck status of service to a gRPC status.
+func (s *GRPCHealthServer) servingStatus(service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	st, ok := s.hc.ServiceStatus(service)
+	if !ok {
+		return healthpb.HealthCheckResponse_SERVICE_UNKNOWN, false
+	}
+	if st == HealthStatusUnhealthy {
+		return healthpb.HealthCheckResponse_NOT_SERVING, true
+	}
+	return healthpb.HealthCheckResponse_SERVING, true
+}
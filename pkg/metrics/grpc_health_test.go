@@ -0,0 +1,212 @@
+//go:build grpc
+// +build grpc
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+func newGRPCHealthClient(t *testing.T, hc *HealthCheck, interval time.Duration) healthpb.HealthClient {
+	t.Helper()
+
+	lis := bufconn.Listen(1 << 20)
+	hs := NewGRPCHealthServer(hc)
+	hs.SetWatchInterval(interval)
+
+	srv := grpc.NewServer()
+	healthpb.RegisterHealthServer(srv, hs)
+	go func() { _ = srv.Serve(lis) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("grpc.NewClient failed: %v", err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return healthpb.NewHealthClient(conn)
+}
+
+func TestGRPCHealthCheck(t *testing.T) {
+	hc := NewHealthCheck(NewCollector(nil), "1.0.0")
+	var failing atomic.Bool
+	hc.AddCheck("database", func() error {
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	client := newGRPCHealthClient(t, hc, 10*time.Millisecond)
+	ctx := context.Background()
+
+	for _, service := range []string{"", "database"} {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q) failed: %v", service, err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+			t.Errorf("Check(%q): expected SERVING, got %s", service, resp.GetStatus())
+		}
+	}
+
+	failing.Store(true)
+	for _, service := range []string{"", "database"} {
+		resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		if err != nil {
+			t.Fatalf("Check(%q) failed: %v", service, err)
+		}
+		if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+			t.Errorf("Check(%q): expected NOT_SERVING, got %s", service, resp.GetStatus())
+		}
+	}
+
+	_, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound for unknown service, got %v", err)
+	}
+}
+
+func TestGRPCCryptoHealthCheck(t *testing.T) {
+	hc := NewHealthCheck(NewCollector(nil), "1.0.0")
+	hc.AddCheck("crypto", CryptoHealthCheck())
+	client := newGRPCHealthClient(t, hc, 10*time.Millisecond)
+	ctx := context.Background()
+
+	resp, err := client.Check(ctx, &healthpb.HealthCheckRequest{Service: "crypto"})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING, got %s", resp.GetStatus())
+	}
+
+	original := pairwiseTestX25519
+	t.Cleanup(func() { pairwiseTestX25519 = original })
+	pairwiseTestX25519 = func(*crypto.X25519KeyPair) *crypto.CSTResult {
+		return &crypto.CSTResult{Passed: false, Error: errors.New("shared secrets do not match")}
+	}
+
+	resp, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "crypto"})
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING, got %s", resp.GetStatus())
+	}
+}
+
+func TestGRPCHealthWatch(t *testing.T) {
+	hc := NewHealthCheck(NewCollector(nil), "1.0.0")
+	var failing atomic.Bool
+	hc.AddCheck("database", func() error {
+		if failing.Load() {
+			return errors.New("connection refused")
+		}
+		return nil
+	})
+	client := newGRPCHealthClient(t, hc, 10*time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "database"})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Fatalf("expected initial SERVING, got %s", resp.GetStatus())
+	}
+
+	failing.Store(true)
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_NOT_SERVING {
+		t.Errorf("expected NOT_SERVING after check failed, got %s", resp.GetStatus())
+	}
+
+	failing.Store(false)
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING after check recovered, got %s", resp.GetStatus())
+	}
+
+	unknown, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	resp, err = unknown.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		t.Errorf("expected SERVICE_UNKNOWN, got %s", resp.GetStatus())
+	}
+}
+
+func TestGRPCHealthWatchRegistration(t *testing.T) {
+	hc := NewHealthCheck(NewCollector(nil), "1.0.0")
+
+	// With a long interval, only the registration can trigger an update
+	client := newGRPCHealthClient(t, hc, time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "database"})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	resp, err := stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		t.Fatalf("expected initial SERVICE_UNKNOWN, got %s", resp.GetStatus())
+	}
+
+	hc.AddCheck("database", func() error { return nil })
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVING {
+		t.Errorf("expected SERVING once registered, got %s", resp.GetStatus())
+	}
+
+	hc.RemoveCheck("database")
+	resp, err = stream.Recv()
+	if err != nil {
+		t.Fatalf("Recv failed: %v", err)
+	}
+	if resp.GetStatus() != healthpb.HealthCheckResponse_SERVICE_UNKNOWN {
+		t.Errorf("expected SERVICE_UNKNOWN once removed, got %s", resp.GetStatus())
+	}
+}
@@ -1,10 +1,16 @@
 package metrics
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"sync"
 	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 )
 
 // HealthStatus represents the overall health state.
@@ -23,6 +29,7 @@ const (
 type HealthCheck struct {
 	mu        sync.RWMutex
 	checks    map[string]CheckFunc
+	changed   chan struct{} // closed and replaced when checks change
 	collector *Collector
 	startTime time.Time
 	version   string
@@ -62,6 +69,7 @@ type HealthMetrics struct {
 func NewHealthCheck(collector *Collector, version string) *HealthCheck {
 	return &HealthCheck{
 		checks:    make(map[string]CheckFunc),
+		changed:   make(chan struct{}),
 		collector: collector,
 		startTime: time.Now(),
 		version:   version,
@@ -73,6 +81,7 @@ func (h *HealthCheck) AddCheck(name string, check CheckFunc) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	h.checks[name] = check
+	h.notifyLocked()
 }
 
 // RemoveCheck removes a named health check.
@@ -80,6 +89,21 @@ func (h *HealthCheck) RemoveCheck(name string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 	delete(h.checks, name)
+	h.notifyLocked()
+}
+
+// changes returns a channel closed the next time a check is added or
+// removed.
+func (h *HealthCheck) changes() <-chan struct{} {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.changed
+}
+
+// notifyLocked wakes the waiters on changes. Must hold h.mu.
+func (h *HealthCheck) notifyLocked() {
+	close(h.changed)
+	h.changed = make(chan struct{})
 }
 
 // Check performs all health checks and returns the overall status.
@@ -154,6 +178,27 @@ func (h *HealthCheck) Check() HealthResponse {
 	return response
 }
 
+// ServiceStatus runs the check registered as name and returns its status,
+// or the overall status from Check when name is empty. ok is false if no
+// check is registered under name.
+func (h *HealthCheck) ServiceStatus(name string) (status HealthStatus, ok bool) {
+	if name == "" {
+		return h.Check().Status, true
+	}
+
+	h.mu.RLock()
+	check, ok := h.checks[name]
+	h.mu.RUnlock()
+	if !ok {
+		return "", false
+	}
+
+	if err := check(); err != nil {
+		return HealthStatusUnhealthy, true
+	}
+	return HealthStatusHealthy, true
+}
+
 // Handler returns an http.Handler for the health check endpoint.
 func (h *HealthCheck) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -264,6 +309,79 @@ func ConnectivityCheck(addr string, timeout time.Duration) CheckFunc {
 	}
 }
 
+// Pairwise consistency tests run by CryptoHealthCheck, replaced by tests
+// to inject failures.
+var (
+	pairwiseTestX25519 = crypto.PairwiseConsistencyTestX25519
+	pairwiseTestMLKEM  = crypto.PairwiseConsistencyTestMLKEM
+)
+
+// CryptoHealthCheck returns a health check that exercises the cryptographic
+// primitives tunnels depend on: an AEAD seal/open roundtrip, and X25519 and
+// ML-KEM key generation followed by the pairwise consistency tests of
+// pkg/crypto. Registered as a readiness check, it shows that the crypto path
+// works, not just that the process is up:
+//
+//	health.AddCheck("crypto", metrics.CryptoHealthCheck())
+func CryptoHealthCheck() CheckFunc {
+	return func() error {
+		if err := aeadSelfTest(); err != nil {
+			return fmt.Errorf("aead self-test: %w", err)
+		}
+
+		x25519KeyPair, err := crypto.GenerateX25519KeyPair()
+		if err != nil {
+			return fmt.Errorf("x25519 key generation: %w", err)
+		}
+		if result := pairwiseTestX25519(x25519KeyPair); !result.Passed {
+			return fmt.Errorf("x25519 pairwise test: %w", result.Error)
+		}
+
+		mlkemKeyPair, err := crypto.GenerateMLKEMKeyPair()
+		if err != nil {
+			return fmt.Errorf("ml-kem key generation: %w", err)
+		}
+		if result := pairwiseTestMLKEM(mlkemKeyPair); !result.Passed {
+			return fmt.Errorf("ml-kem pairwise test: %w", result.Error)
+		}
+		return nil
+	}
+}
+
+// aeadSelfTest seals and opens a message under a fresh key, and checks that
+// a tampered ciphertext is rejected.
+func aeadSelfTest() error {
+	suite := constants.CipherSuiteAES256GCM
+	key := make([]byte, suite.KeySize())
+	defer crypto.Zeroize(key)
+	if err := crypto.SecureRandom(key); err != nil {
+		return err
+	}
+	aead, err := crypto.NewAEAD(suite, key)
+	if err != nil {
+		return err
+	}
+
+	plaintext := []byte("crypto health check")
+	ciphertext, err := aead.Seal(plaintext, nil)
+	if err != nil {
+		return err
+	}
+	opened, err := aead.Open(ciphertext, nil)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(opened, plaintext) {
+		return errors.New("roundtrip mismatch")
+	}
+
+	ciphertext[len(ciphertext)-1] ^= 1
+	if _, err := aead.Open(ciphertext, nil); err == nil {
+		return errors.New("tampered ciphertext accepted")
+	}
+	return nil
+}
+
 // --- Server ---
 
 // Server provides HTTP endpoints for metrics, health, and observability.
@@ -272,6 +390,7 @@ type Server struct {
 	collector  *Collector
 	health     *HealthCheck
 	prometheus *PrometheusExporter
+	json       *JSONExporter
 }
 
 // ServerConfig configures the observability server.
@@ -280,6 +399,7 @@ type ServerConfig struct {
 	Version          string
 	Namespace        string // Prometheus namespace
 	EnablePrometheus bool
+	EnableJSON       bool // Serve Collector snapshots at /metrics.json
 	EnableHealth     bool
 }
 
@@ -302,6 +422,11 @@ func NewServer(cfg ServerConfig) *Server {
 		s.mux.Handle("/metrics", s.prometheus.Handler())
 	}
 
+	if cfg.EnableJSON {
+		s.json = NewJSONExporter(cfg.Collector)
+		s.mux.Handle("/metrics.json", s.json.Handler())
+	}
+
 	if cfg.EnableHealth {
 		s.health = NewHealthCheck(cfg.Collector, cfg.Version)
 		s.mux.Handle("/health", s.health.Handler())
@@ -5,7 +5,10 @@ import (
 	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 )
 
 func TestHealthCheckBasic(t *testing.T) {
@@ -109,6 +112,35 @@ func TestHealthCheckRemoveCheck(t *testing.T) {
 	}
 }
 
+func TestHealthCheckServiceStatus(t *testing.T) {
+	h := NewHealthCheck(NewCollector(nil), "1.0.0")
+
+	var checkErr error
+	h.AddCheck("database", func() error {
+		return checkErr
+	})
+
+	status, ok := h.ServiceStatus("database")
+	if !ok || status != HealthStatusHealthy {
+		t.Errorf("expected healthy database, got %s (ok=%v)", status, ok)
+	}
+	if status, _ := h.ServiceStatus(""); status != HealthStatusHealthy {
+		t.Errorf("expected healthy overall status, got %s", status)
+	}
+
+	checkErr = errors.New("connection refused")
+	if status, _ := h.ServiceStatus("database"); status != HealthStatusUnhealthy {
+		t.Errorf("expected unhealthy database, got %s", status)
+	}
+	if status, _ := h.ServiceStatus(""); status != HealthStatusUnhealthy {
+		t.Errorf("expected unhealthy overall status, got %s", status)
+	}
+
+	if _, ok := h.ServiceStatus("missing"); ok {
+		t.Error("expected unknown check to report ok=false")
+	}
+}
+
 func TestHealthCheckHandler(t *testing.T) {
 	c := NewCollector(nil)
 	h := NewHealthCheck(c, "1.0.0")
@@ -237,6 +269,7 @@ func TestServerHandler(t *testing.T) {
 		Version:          "1.0.0",
 		Namespace:        "test",
 		EnablePrometheus: true,
+		EnableJSON:       true,
 		EnableHealth:     true,
 	})
 
@@ -249,6 +282,15 @@ func TestServerHandler(t *testing.T) {
 		t.Error("expected /metrics to return 200")
 	}
 
+	// Test JSON metrics endpoint
+	req = httptest.NewRequest("GET", "/metrics.json", nil)
+	w = httptest.NewRecorder()
+	server.Handler().ServeHTTP(w, req)
+
+	if w.Result().StatusCode != http.StatusOK {
+		t.Error("expected /metrics.json to return 200")
+	}
+
 	// Test health endpoint
 	req = httptest.NewRequest("GET", "/health", nil)
 	w = httptest.NewRecorder()
@@ -302,3 +344,43 @@ func TestFormatDuration(t *testing.T) {
 		t.Error("formatDuration should return non-empty string")
 	}
 }
+
+func TestCryptoHealthCheck(t *testing.T) {
+	h := NewHealthCheck(NewCollector(nil), "1.0.0")
+	h.AddCheck("crypto", CryptoHealthCheck())
+
+	if status, ok := h.ServiceStatus("crypto"); !ok || status != HealthStatusHealthy {
+		t.Errorf("expected healthy crypto check, got %s (ok=%v)", status, ok)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected ready, got status %d", rec.Code)
+	}
+}
+
+func TestCryptoHealthCheckFailingCST(t *testing.T) {
+	original := pairwiseTestMLKEM
+	t.Cleanup(func() { pairwiseTestMLKEM = original })
+	pairwiseTestMLKEM = func(*crypto.MLKEMKeyPair) *crypto.CSTResult {
+		return &crypto.CSTResult{Passed: false, Error: errors.New("shared secrets do not match")}
+	}
+
+	h := NewHealthCheck(NewCollector(nil), "1.0.0")
+	h.AddCheck("crypto", CryptoHealthCheck())
+
+	response := h.Check()
+	if response.Status != HealthStatusUnhealthy {
+		t.Errorf("expected unhealthy with a failing self-test, got %s", response.Status)
+	}
+	if msg := response.Checks["crypto"].Message; !strings.Contains(msg, "ml-kem pairwise test") {
+		t.Errorf("expected the failing self-test in the message, got %q", msg)
+	}
+
+	rec := httptest.NewRecorder()
+	h.ReadinessHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected not ready, got status %d", rec.Code)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"math"
 	"sort"
 	"sync"
+	"time"
 )
 
 // Histogram tracks the distribution of values across predefined buckets.
@@ -16,6 +17,16 @@ type Histogram struct {
 	count   uint64    // Total count of observations
 	min     float64   // Minimum observed value
 	max     float64   // Maximum observed value
+
+	exemplars []*Exemplar // Most recent exemplar per bucket, nil until one is observed
+}
+
+// Exemplar links an observation to the trace that produced it, so a slow
+// sample in a histogram bucket can be looked up in the tracing backend.
+type Exemplar struct {
+	TraceID   string    `json:"trace_id"`
+	Value     float64   `json:"value"`
+	Timestamp time.Time `json:"timestamp"`
 }
 
 // NewHistogram creates a histogram with the given bucket boundaries.
@@ -38,7 +49,28 @@ func NewHistogram(buckets []float64) *Histogram {
 func (h *Histogram) Observe(v float64) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
+	h.observeLocked(v)
+}
+
+// ObserveWithExemplar records a value like Observe and keeps it as the
+// exemplar of its bucket, replacing the bucket's previous exemplar. An
+// empty traceID records the value without an exemplar.
+func (h *Histogram) ObserveWithExemplar(v float64, traceID string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	idx := h.observeLocked(v)
+	if traceID == "" {
+		return
+	}
+	if h.exemplars == nil {
+		h.exemplars = make([]*Exemplar, len(h.counts))
+	}
+	h.exemplars[idx] = &Exemplar{TraceID: traceID, Value: v, Timestamp: time.Now()}
+}
 
+// observeLocked records v and returns the index of its bucket. Must hold h.mu.
+func (h *Histogram) observeLocked(v float64) int {
 	// Find bucket
 	idx := sort.SearchFloat64s(h.buckets, v)
 	h.counts[idx]++
@@ -52,6 +84,7 @@ func (h *Histogram) Observe(v float64) {
 	if v > h.max {
 		h.max = v
 	}
+	return idx
 }
 
 // HistogramSummary contains summarized histogram data.
@@ -67,8 +100,9 @@ type HistogramSummary struct {
 
 // BucketCount represents a histogram bucket with its upper bound and count.
 type BucketCount struct {
-	UpperBound float64 `json:"le"`    // Upper bound (less than or equal)
-	Count      uint64  `json:"count"` // Cumulative count
+	UpperBound float64   `json:"le"`                 // Upper bound (less than or equal)
+	Count      uint64    `json:"count"`              // Cumulative count
+	Exemplar   *Exemplar `json:"exemplar,omitempty"` // Most recent traced observation in the bucket
 }
 
 // Summary returns a summary of the histogram.
@@ -99,6 +133,10 @@ func (h *Histogram) Summary() HistogramSummary {
 		UpperBound: math.Inf(1),
 		Count:      cumulative,
 	}
+	// Exemplars are never modified once stored, so they can be shared
+	for i, ex := range h.exemplars {
+		buckets[i].Exemplar = ex
+	}
 
 	// Calculate percentiles
 	percentiles := h.calculatePercentiles([]float64{0.5, 0.9, 0.95, 0.99})
@@ -160,6 +198,7 @@ func (h *Histogram) Reset() {
 	for i := range h.counts {
 		h.counts[i] = 0
 	}
+	h.exemplars = nil
 	h.sum = 0
 	h.count = 0
 	h.min = math.MaxFloat64
@@ -114,6 +114,33 @@ func TestHistogramReset(t *testing.T) {
 	}
 }
 
+func TestHistogramExemplars(t *testing.T) {
+	h := NewHistogram([]float64{10, 50, 100})
+
+	h.ObserveWithExemplar(25, "trace-a")
+	h.ObserveWithExemplar(30, "trace-b")
+	h.ObserveWithExemplar(75, "")
+
+	summary := h.Summary()
+	if summary.Count != 3 {
+		t.Fatalf("expected 3 observations, got %d", summary.Count)
+	}
+	if ex := summary.Buckets[1].Exemplar; ex == nil || ex.TraceID != "trace-b" || ex.Value != 30 {
+		t.Errorf("expected the latest exemplar trace-b=30 in bucket le=50, got %+v", ex)
+	}
+	for _, i := range []int{0, 2, 3} {
+		if ex := summary.Buckets[i].Exemplar; ex != nil {
+			t.Errorf("bucket %d: unexpected exemplar %+v", i, ex)
+		}
+	}
+
+	h.Reset()
+	h.Observe(25)
+	if ex := h.Summary().Buckets[1].Exemplar; ex != nil {
+		t.Errorf("expected exemplars cleared by Reset, got %+v", ex)
+	}
+}
+
 func TestHistogramMinMax(t *testing.T) {
 	h := NewHistogram([]float64{100})
 
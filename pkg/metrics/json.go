@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// JSONExporter exports collector snapshots as JSON documents.
+type JSONExporter struct {
+	collector *Collector
+}
+
+// JSONSnapshot is the JSON document served by JSONExporter. Field names are
+// snake_case and stable across releases; new fields may be added.
+type JSONSnapshot struct {
+	Timestamp     time.Time `json:"timestamp"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+	Labels        Labels    `json:"labels"`
+
+	SessionsActive    int64            `json:"sessions_active"`
+	SessionsTotal     int64            `json:"sessions_total"`
+	SessionsFailed    int64            `json:"sessions_failed"`
+	HandshakeFailures map[string]int64 `json:"handshake_failures"`
+
+	BytesSent       int64 `json:"bytes_sent"`
+	BytesReceived   int64 `json:"bytes_received"`
+	PacketsSent     int64 `json:"packets_sent"`
+	PacketsReceived int64 `json:"packets_received"`
+
+	WireBytesSent     int64 `json:"wire_bytes_sent"`
+	WireBytesReceived int64 `json:"wire_bytes_received"`
+
+	ReplayAttacksBlocked    int64 `json:"replay_attacks_blocked"`
+	ReplayRejectedOld       int64 `json:"replay_rejected_old"`
+	ReplayRejectedDuplicate int64 `json:"replay_rejected_duplicate"`
+	AuthFailures            int64 `json:"auth_failures"`
+	RekeysInitiated         int64 `json:"rekeys_initiated"`
+	RekeysCompleted         int64 `json:"rekeys_completed"`
+	RekeysFailed            int64 `json:"rekeys_failed"`
+	UnexpectedRekeys        int64 `json:"unexpected_rekeys"`
+
+	AEADRemainingCapacity map[string]uint64 `json:"aead_remaining_capacity"`
+
+	EncryptErrors  int64 `json:"encrypt_errors"`
+	DecryptErrors  int64 `json:"decrypt_errors"`
+	ProtocolErrors int64 `json:"protocol_errors"`
+
+	ConnectionRateLimits int64 `json:"connection_rate_limits"`
+	HandshakeRateLimits  int64 `json:"handshake_rate_limits"`
+
+	SendRateLimitedBytes      int64   `json:"send_rate_limited_bytes"`
+	SendRateLimitDelaySeconds float64 `json:"send_rate_limit_delay_seconds"`
+
+	HandshakeLatencyMs JSONHistogram `json:"handshake_latency_ms"`
+	EncryptLatencyUs   JSONHistogram `json:"encrypt_latency_us"`
+	DecryptLatencyUs   JSONHistogram `json:"decrypt_latency_us"`
+
+	NetworkWriteLatencyUs JSONHistogram `json:"network_write_latency_us"`
+	NetworkReadLatencyUs  JSONHistogram `json:"network_read_latency_us"`
+
+	SessionDurationSeconds JSONHistogram `json:"session_duration_seconds"`
+	RekeysPerSession       JSONHistogram `json:"rekeys_per_session"`
+	BytesPerSession        JSONHistogram `json:"bytes_per_session"`
+}
+
+// JSONHistogram is the JSON form of a HistogramSummary.
+type JSONHistogram struct {
+	Count       uint64             `json:"count"`
+	Sum         float64            `json:"sum"`
+	Min         float64            `json:"min"`
+	Max         float64            `json:"max"`
+	Mean        float64            `json:"mean"`
+	Buckets     []JSONBucket       `json:"buckets"`
+	Percentiles map[string]float64 `json:"percentiles"`
+}
+
+// JSONBucket is a cumulative histogram bucket. LE is the upper bound
+// formatted as in Prometheus, with "+Inf" for the overflow bucket, since
+// JSON cannot represent infinity as a number.
+type JSONBucket struct {
+	LE    string `json:"le"`
+	Count uint64 `json:"count"`
+}
+
+// NewJSONExporter creates a new JSON exporter for the given collector.
+func NewJSONExporter(c *Collector) *JSONExporter {
+	return &JSONExporter{collector: c}
+}
+
+// Handler returns an http.Handler that serves the current snapshot as JSON.
+// Failures to write the response, typically a client that went away, are
+// logged at warn level with the global logger.
+func (e *JSONExporter) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if err := e.WriteJSON(w); err != nil {
+			Warn("writing JSON metrics failed", Fields{"error": err.Error()})
+		}
+	})
+}
+
+// WriteJSON writes the current snapshot as a JSON document to w.
+func (e *JSONExporter) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(e.Snapshot())
+}
+
+// Snapshot returns the collector's current snapshot in JSON document form.
+func (e *JSONExporter) Snapshot() JSONSnapshot {
+	snap := e.collector.Snapshot()
+
+	labels := snap.Labels
+	if labels == nil {
+		labels = Labels{}
+	}
+	failures := snap.HandshakeFailures
+	if failures == nil {
+		failures = map[string]int64{}
+	}
+	capacity := snap.AEADRemainingCapacity
+	if capacity == nil {
+		capacity = map[string]uint64{}
+	}
+
+	return JSONSnapshot{
+		Timestamp:                 snap.Timestamp,
+		UptimeSeconds:             snap.Uptime.Seconds(),
+		Labels:                    labels,
+		SessionsActive:            snap.SessionsActive,
+		SessionsTotal:             snap.SessionsTotal,
+		SessionsFailed:            snap.SessionsFailed,
+		HandshakeFailures:         failures,
+		BytesSent:                 snap.BytesSent,
+		BytesReceived:             snap.BytesReceived,
+		PacketsSent:               snap.PacketsSent,
+		PacketsReceived:           snap.PacketsRecv,
+		WireBytesSent:             snap.WireBytesSent,
+		WireBytesReceived:         snap.WireBytesReceived,
+		ReplayAttacksBlocked:      snap.ReplayAttacksBlocked,
+		ReplayRejectedOld:         snap.ReplayRejectedOld,
+		ReplayRejectedDuplicate:   snap.ReplayRejectedDuplicate,
+		AuthFailures:              snap.AuthFailures,
+		RekeysInitiated:           snap.RekeysInitiated,
+		RekeysCompleted:           snap.RekeysCompleted,
+		RekeysFailed:              snap.RekeysFailed,
+		UnexpectedRekeys:          snap.UnexpectedRekeys,
+		AEADRemainingCapacity:     capacity,
+		EncryptErrors:             snap.EncryptErrors,
+		DecryptErrors:             snap.DecryptErrors,
+		ProtocolErrors:            snap.ProtocolErrors,
+		ConnectionRateLimits:      snap.ConnectionRateLimits,
+		HandshakeRateLimits:       snap.HandshakeRateLimits,
+		SendRateLimitedBytes:      snap.SendRateLimitedBytes,
+		SendRateLimitDelaySeconds: snap.SendRateLimitDelay.Seconds(),
+		HandshakeLatencyMs:        newJSONHistogram(snap.HandshakeLatency),
+		EncryptLatencyUs:          newJSONHistogram(snap.EncryptLatency),
+		DecryptLatencyUs:          newJSONHistogram(snap.DecryptLatency),
+		NetworkWriteLatencyUs:     newJSONHistogram(snap.NetworkWriteLatency),
+		NetworkReadLatencyUs:      newJSONHistogram(snap.NetworkReadLatency),
+		SessionDurationSeconds:    newJSONHistogram(snap.SessionDuration),
+		RekeysPerSession:          newJSONHistogram(snap.RekeysPerSession),
+		BytesPerSession:           newJSONHistogram(snap.BytesPerSession),
+	}
+}
+
+// newJSONHistogram converts a HistogramSummary, keying percentiles as
+// "p50", "p90", "p95" and "p99".
+func newJSONHistogram(h HistogramSummary) JSONHistogram {
+	jh := JSONHistogram{
+		Count:       h.Count,
+		Sum:         h.Sum,
+		Min:         h.Min,
+		Max:         h.Max,
+		Mean:        h.Mean,
+		Buckets:     make([]JSONBucket, 0, len(h.Buckets)),
+		Percentiles: make(map[string]float64, len(h.Percentiles)),
+	}
+	for _, b := range h.Buckets {
+		le := "+Inf"
+		if !math.IsInf(b.UpperBound, 1) {
+			le = strconv.FormatFloat(b.UpperBound, 'g', -1, 64)
+		}
+		jh.Buckets = append(jh.Buckets, JSONBucket{LE: le, Count: b.Count})
+	}
+	for p, v := range h.Percentiles {
+		jh.Percentiles["p"+strconv.FormatFloat(p*100, 'g', -1, 64)] = v
+	}
+	return jh
+}
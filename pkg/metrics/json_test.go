@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestJSONExporterHandler(t *testing.T) {
+	c := NewCollector(Labels{"instance": "test"})
+	c.SessionStarted()
+	c.RecordBytesSent(1000)
+	c.RecordHandshakeFailure("timeout")
+	c.RecordHandshakeLatency(100 * time.Millisecond)
+	c.RecordHandshakeLatency(300 * time.Millisecond)
+
+	req := httptest.NewRequest("GET", "/metrics.json", nil)
+	w := httptest.NewRecorder()
+	NewJSONExporter(c).Handler().ServeHTTP(w, req)
+
+	resp := w.Result()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", resp.StatusCode)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+		t.Errorf("expected application/json content type, got %q", ct)
+	}
+
+	// Decode generically to check the wire types, not just our struct
+	var doc map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&doc); err != nil {
+		t.Fatalf("failed to decode JSON: %v", err)
+	}
+
+	if v, ok := doc["sessions_total"].(float64); !ok || v != 1 {
+		t.Errorf("expected sessions_total 1, got %#v", doc["sessions_total"])
+	}
+	if v, ok := doc["bytes_sent"].(float64); !ok || v != 1000 {
+		t.Errorf("expected bytes_sent 1000, got %#v", doc["bytes_sent"])
+	}
+	labels, ok := doc["labels"].(map[string]interface{})
+	if !ok || labels["instance"] != "test" {
+		t.Errorf("expected labels with instance=test, got %#v", doc["labels"])
+	}
+	failures, ok := doc["handshake_failures"].(map[string]interface{})
+	if !ok || failures["timeout"] != float64(1) {
+		t.Errorf("expected handshake_failures timeout=1, got %#v", doc["handshake_failures"])
+	}
+
+	hist, ok := doc["handshake_latency_ms"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected handshake_latency_ms object, got %#v", doc["handshake_latency_ms"])
+	}
+	if v, ok := hist["count"].(float64); !ok || v != 2 {
+		t.Errorf("expected histogram count 2, got %#v", hist["count"])
+	}
+	if v, ok := hist["sum"].(float64); !ok || v != 400 {
+		t.Errorf("expected histogram sum 400, got %#v", hist["sum"])
+	}
+	if _, ok := hist["percentiles"].(map[string]interface{})["p99"].(float64); !ok {
+		t.Errorf("expected numeric p99 percentile, got %#v", hist["percentiles"])
+	}
+
+	buckets, ok := hist["buckets"].([]interface{})
+	if !ok || len(buckets) == 0 {
+		t.Fatalf("expected histogram buckets, got %#v", hist["buckets"])
+	}
+	last, ok := buckets[len(buckets)-1].(map[string]interface{})
+	if !ok || last["le"] != "+Inf" || last["count"] != float64(2) {
+		t.Errorf("expected +Inf bucket with count 2, got %#v", buckets[len(buckets)-1])
+	}
+}
+
+func TestJSONExporterEmpty(t *testing.T) {
+	snap := NewJSONExporter(NewCollector(nil)).Snapshot()
+
+	data, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if _, ok := doc["labels"].(map[string]interface{}); !ok {
+		t.Errorf("expected empty labels object, got %#v", doc["labels"])
+	}
+	hist := doc["encrypt_latency_us"].(map[string]interface{})
+	if _, ok := hist["buckets"].([]interface{}); !ok {
+		t.Errorf("expected empty bucket array, got %#v", hist["buckets"])
+	}
+}
+
+// failingResponseWriter is a ResponseWriter whose client has gone away.
+type failingResponseWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w failingResponseWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestJSONExporterHandlerLogsWriteError(t *testing.T) {
+	saved := GetLogger()
+	t.Cleanup(func() { SetLogger(saved) })
+	var buf bytes.Buffer
+	SetLogger(NewLogger(WithOutput(&buf), WithFormat(FormatText)))
+
+	req := httptest.NewRequest("GET", "/metrics.json", nil)
+	w := failingResponseWriter{httptest.NewRecorder()}
+	NewJSONExporter(NewCollector(nil)).Handler().ServeHTTP(w, req)
+
+	if out := buf.String(); !strings.Contains(out, "writing JSON metrics failed") || !strings.Contains(out, "broken pipe") {
+		t.Errorf("expected the write error to be logged, got %q", out)
+	}
+}
@@ -72,6 +72,7 @@ type Logger struct {
 	fields   Fields
 	name     string
 	timeFunc func() time.Time
+	sampler  *logSampler
 }
 
 // Fields represents structured log fields.
@@ -156,6 +157,7 @@ func (l *Logger) With(fields Fields) *Logger {
 		fields:   newFields,
 		name:     l.name,
 		timeFunc: l.timeFunc,
+		sampler:  l.sampler,
 	}
 }
 
@@ -172,6 +174,7 @@ func (l *Logger) Named(name string) *Logger {
 		fields:   l.fields,
 		name:     newName,
 		timeFunc: l.timeFunc,
+		sampler:  l.sampler,
 	}
 }
 
@@ -208,6 +211,14 @@ func (l *Logger) log(level Level, msg string, extraFields ...Fields) {
 		return
 	}
 
+	if l.sampler != nil {
+		ok, suppressed := l.sampler.allow(level, l.timeFunc())
+		l.writeSuppressed(suppressed)
+		if !ok {
+			return
+		}
+	}
+
 	// Merge fields
 	allFields := make(Fields, len(l.fields))
 	for k, v := range l.fields {
@@ -219,13 +230,18 @@ func (l *Logger) log(level Level, msg string, extraFields ...Fields) {
 		}
 	}
 
+	l.write(level, msg, allFields)
+}
+
+// write writes a single entry in the configured format.
+func (l *Logger) write(level Level, msg string, fields Fields) {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
 	if l.format == FormatJSON {
-		l.writeJSON(level, msg, allFields)
+		l.writeJSON(level, msg, fields)
 	} else {
-		l.writeText(level, msg, allFields)
+		l.writeText(level, msg, fields)
 	}
 }
 
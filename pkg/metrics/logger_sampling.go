@@ -0,0 +1,142 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// DefaultSuppressionSummaryInterval is how often a sampled or rate limited
+// logger reports the number of messages it suppressed.
+const DefaultSuppressionSummaryInterval = 10 * time.Second
+
+// WithSampling logs only the first of every n messages at each level,
+// suppressing the rest. n <= 1 disables sampling.
+func WithSampling(n int) LoggerOption {
+	return func(l *Logger) {
+		l.ensureSampler().every = uint64(max(n, 1))
+	}
+}
+
+// WithRateLimit logs at most perSecond messages per second at each level,
+// using a token bucket that allows bursts of up to perSecond messages.
+// perSecond <= 0 disables rate limiting.
+func WithRateLimit(perSecond int) LoggerOption {
+	return func(l *Logger) {
+		l.ensureSampler().rate = float64(max(perSecond, 0))
+	}
+}
+
+// WithSuppressionSummary sets how often a sampled or rate limited logger
+// emits a "suppressed N messages" line for each level that dropped
+// messages. Summaries are written by the next message logged after the
+// interval elapses, or by FlushSuppressed.
+func WithSuppressionSummary(interval time.Duration) LoggerOption {
+	return func(l *Logger) {
+		l.ensureSampler().interval = interval
+	}
+}
+
+func (l *Logger) ensureSampler() *logSampler {
+	if l.sampler == nil {
+		l.sampler = &logSampler{interval: DefaultSuppressionSummaryInterval}
+	}
+	return l.sampler
+}
+
+// FlushSuppressed immediately writes the suppression summary for any
+// messages dropped by sampling or rate limiting since the last summary.
+func (l *Logger) FlushSuppressed() {
+	if l.sampler == nil {
+		return
+	}
+	l.writeSuppressed(l.sampler.takeSuppressed(l.timeFunc()))
+}
+
+// writeSuppressed writes one summary line per level in suppressed.
+func (l *Logger) writeSuppressed(suppressed [LevelSilent]uint64) {
+	for level, n := range suppressed {
+		if n == 0 {
+			continue
+		}
+		l.write(Level(level), fmt.Sprintf("suppressed %d messages", n), Fields{"suppressed": n})
+	}
+}
+
+// logSampler applies sampling and rate limiting per level. It is shared by
+// loggers derived with With and Named, so they draw from the same budget.
+type logSampler struct {
+	mu       sync.Mutex
+	every    uint64        // Log 1 of every n messages (0 or 1 logs all)
+	rate     float64       // Token refill rate per second (0 is unlimited)
+	interval time.Duration // Minimum time between suppression summaries
+
+	seen        [LevelSilent]uint64
+	tokens      [LevelSilent]float64
+	refilled    [LevelSilent]time.Time
+	suppressed  [LevelSilent]uint64
+	lastSummary time.Time
+}
+
+// allow reports whether a message at level should be written, and returns
+// the suppression counts to report first if a summary is due.
+func (s *logSampler) allow(level Level, now time.Time) (bool, [LevelSilent]uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.lastSummary.IsZero() {
+		s.lastSummary = now
+	}
+	var due [LevelSilent]uint64
+	if now.Sub(s.lastSummary) >= s.interval {
+		due = s.takeLocked(now)
+	}
+
+	ok := s.sample(level) && s.take(level, now)
+	if !ok {
+		s.suppressed[level]++
+	}
+	return ok, due
+}
+
+// sample applies 1-in-n sampling, always keeping the first message.
+func (s *logSampler) sample(level Level) bool {
+	n := s.seen[level]
+	s.seen[level]++
+	return s.every <= 1 || n%s.every == 0
+}
+
+// take consumes a token from level's bucket, refilling it for the time
+// elapsed since the last refill.
+func (s *logSampler) take(level Level, now time.Time) bool {
+	if s.rate == 0 {
+		return true
+	}
+	if s.refilled[level].IsZero() {
+		s.tokens[level] = s.rate
+	} else if elapsed := now.Sub(s.refilled[level]); elapsed > 0 {
+		s.tokens[level] = math.Min(s.rate, s.tokens[level]+elapsed.Seconds()*s.rate)
+	}
+	s.refilled[level] = now
+
+	if s.tokens[level] < 1 {
+		return false
+	}
+	s.tokens[level]--
+	return true
+}
+
+// takeSuppressed returns and resets the suppression counts.
+func (s *logSampler) takeSuppressed(now time.Time) [LevelSilent]uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.takeLocked(now)
+}
+
+func (s *logSampler) takeLocked(now time.Time) [LevelSilent]uint64 {
+	counts := s.suppressed
+	s.suppressed = [LevelSilent]uint64{}
+	s.lastSummary = now
+	return counts
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoggerLevels(t *testing.T) {
@@ -318,3 +319,119 @@ func TestLoggerTextFieldOrder(t *testing.T) {
 		t.Error("fields should be sorted alphabetically")
 	}
 }
+
+func TestTunnelLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewTunnelLogger(NewLogger(
+		WithOutput(&buf),
+		WithLevel(LevelWarn),
+		WithFormat(FormatJSON),
+		WithName("quantum"),
+	))
+
+	child := logger.Named("handshake")
+	child.Info("filtered", nil)
+	child.Warn("handshake failed", map[string]any{"reason": "timeout"})
+
+	var entry map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a single JSON entry: %v", err)
+	}
+	if entry["logger"] != "quantum.handshake" {
+		t.Errorf("expected logger 'quantum.handshake', got %v", entry["logger"])
+	}
+	if entry["level"] != "WARN" || entry["reason"] != "timeout" {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+}
+
+// countLines counts the lines in buf containing substr.
+func countLines(buf *bytes.Buffer, substr string) int {
+	n := 0
+	for _, line := range strings.Split(buf.String(), "\n") {
+		if strings.Contains(line, substr) {
+			n++
+		}
+	}
+	return n
+}
+
+func TestLoggerSampling(t *testing.T) {
+	var buf bytes.Buffer
+	logger := NewLogger(WithOutput(&buf), WithSampling(100))
+
+	for i := 0; i < 10000; i++ {
+		logger.Warn("replay attack blocked")
+	}
+	logger.Info("handshake completed")
+
+	if got := countLines(&buf, "replay attack blocked"); got != 100 {
+		t.Errorf("expected 100 sampled warn lines, got %d", got)
+	}
+	// Levels are sampled independently
+	if got := countLines(&buf, "handshake completed"); got != 1 {
+		t.Errorf("expected the first info line to be written, got %d", got)
+	}
+
+	logger.FlushSuppressed()
+	if got := countLines(&buf, "suppressed 9900 messages suppressed=9900"); got != 1 {
+		t.Errorf("expected one warn suppression summary, got:\n%s", buf.String())
+	}
+	if got := countLines(&buf, "suppressed"); got != 1 {
+		t.Errorf("expected no other summaries, got %d", got)
+	}
+
+	// The counts reset after each summary
+	buf.Reset()
+	logger.FlushSuppressed()
+	if buf.Len() != 0 {
+		t.Errorf("expected no summary without suppressed messages, got %q", buf.String())
+	}
+}
+
+func TestLoggerRateLimit(t *testing.T) {
+	var buf bytes.Buffer
+	now := time.Unix(1700000000, 0)
+	logger := NewLogger(
+		WithOutput(&buf),
+		WithFormat(FormatJSON),
+		WithRateLimit(50),
+		WithSuppressionSummary(time.Minute),
+	)
+	logger.timeFunc = func() time.Time { return now }
+
+	// A burst within one instant drains the bucket
+	for i := 0; i < 10000; i++ {
+		logger.Warn("replay attack blocked")
+	}
+	if got := countLines(&buf, "replay attack blocked"); got != 50 {
+		t.Errorf("expected 50 rate limited warn lines, got %d", got)
+	}
+
+	// Half a second refills half the bucket, shared by derived loggers
+	now = now.Add(500 * time.Millisecond)
+	child := logger.Named("tunnel")
+	for i := 0; i < 10000; i++ {
+		child.Warn("replay attack blocked")
+	}
+	if got := countLines(&buf, "replay attack blocked"); got != 75 {
+		t.Errorf("expected 75 warn lines after refill, got %d", got)
+	}
+
+	// The next message after the summary interval reports the suppressed count
+	now = now.Add(time.Minute)
+	buf.Reset()
+	logger.Warn("replay attack blocked")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected a summary and a message, got %q", buf.String())
+	}
+	var summary map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &summary); err != nil {
+		t.Fatalf("failed to parse summary: %v", err)
+	}
+	if summary["msg"] != "suppressed 19925 messages" || summary["level"] != "WARN" || summary["suppressed"] != float64(19925) {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+}
@@ -12,6 +12,8 @@ import (
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
 
 // Collector aggregates metrics from tunnel sessions and transports.
@@ -22,32 +24,59 @@ type Collector struct {
 	sessionsFailed   atomic.Int64
 	handshakeLatency *Histogram
 
+	// Handshake failures by reason
+	failuresMu        sync.Mutex
+	handshakeFailures map[string]int64
+
 	// Traffic metrics
 	bytesSent     atomic.Int64
 	bytesReceived atomic.Int64
 	packetsSent   atomic.Int64
 	packetsRecv   atomic.Int64
 
+	// Wire traffic, including framing and AEAD overhead
+	wireBytesSent     atomic.Int64
+	wireBytesReceived atomic.Int64
+
 	// Security metrics
-	replayAttacksBlocked atomic.Int64
-	authFailures         atomic.Int64
-	rekeysInitiated      atomic.Int64
-	rekeysCompleted      atomic.Int64
-	rekeysFailed         atomic.Int64
+	replayAttacksBlocked    atomic.Int64
+	replayRejectedOld       atomic.Int64
+	replayRejectedDuplicate atomic.Int64
+	authFailures            atomic.Int64
+	rekeysInitiated         atomic.Int64
+	rekeysCompleted         atomic.Int64
+	rekeysFailed            atomic.Int64
+	unexpectedRekeys        atomic.Int64
 
 	// Error metrics
 	encryptErrors  atomic.Int64
 	decryptErrors  atomic.Int64
 	protocolErrors atomic.Int64
 
+	// Remaining AEAD capacity of active sessions by session ID, read when
+	// a snapshot is taken
+	capacityMu        sync.Mutex
+	remainingCapacity map[string]func() uint64
+
 	// Rate limit metrics
 	connectionRateLimits atomic.Int64
 	handshakeRateLimits  atomic.Int64
+	sendRateLimitedBytes atomic.Int64
+	sendRateLimitDelay   atomic.Int64 // Nanoseconds
 
 	// Performance histograms
 	encryptLatency *Histogram
 	decryptLatency *Histogram
 
+	// Connection I/O histograms, separate from the crypto above
+	networkWriteLatency *Histogram
+	networkReadLatency  *Histogram
+
+	// Per-session histograms, recorded when established sessions end
+	sessionDuration  *Histogram
+	rekeysPerSession *Histogram
+	bytesPerSession  *Histogram
+
 	// Creation time for uptime tracking
 	createdAt time.Time
 
@@ -65,11 +94,20 @@ func NewCollector(labels Labels) *Collector {
 	}
 
 	return &Collector{
-		handshakeLatency: NewHistogram(HandshakeLatencyBuckets),
-		encryptLatency:   NewHistogram(LatencyBuckets),
-		decryptLatency:   NewHistogram(LatencyBuckets),
-		createdAt:        time.Now(),
-		labels:           labels,
+		handshakeLatency:  NewHistogram(HandshakeLatencyBuckets),
+		handshakeFailures: make(map[string]int64),
+		remainingCapacity: make(map[string]func() uint64),
+		encryptLatency:    NewHistogram(LatencyBuckets),
+		decryptLatency:    NewHistogram(LatencyBuckets),
+		createdAt:         time.Now(),
+		labels:            labels,
+
+		networkWriteLatency: NewHistogram(NetworkLatencyBuckets),
+		networkReadLatency:  NewHistogram(NetworkLatencyBuckets),
+
+		sessionDuration:  NewHistogram(SessionDurationBuckets),
+		rekeysPerSession: NewHistogram(RekeysPerSessionBuckets),
+		bytesPerSession:  NewHistogram(BytesPerSessionBuckets),
 	}
 }
 
@@ -80,6 +118,18 @@ var (
 
 	// LatencyBuckets for encrypt/decrypt operations (microseconds).
 	LatencyBuckets = []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000}
+
+	// NetworkLatencyBuckets for connection reads and writes (microseconds).
+	NetworkLatencyBuckets = []float64{10, 50, 100, 500, 1000, 5000, 10000, 50000, 100000, 500000, 1000000}
+
+	// SessionDurationBuckets for the lifetime of sessions (seconds).
+	SessionDurationBuckets = []float64{1, 10, 60, 300, 900, 1800, 3600, 14400, 43200, 86400}
+
+	// RekeysPerSessionBuckets for the rekeys completed by each session.
+	RekeysPerSessionBuckets = []float64{0, 1, 2, 5, 10, 25, 50, 100}
+
+	// BytesPerSessionBuckets for the data each session carried (bytes).
+	BytesPerSessionBuckets = []float64{1 << 10, 1 << 16, 1 << 20, 1 << 24, 1 << 27, 1 << 30, 1 << 33}
 )
 
 // --- Session Metrics ---
@@ -103,6 +153,15 @@ func (c *Collector) SessionEnded() {
 	}
 }
 
+// RecordSessionSummary records the lifetime of an established session that
+// has ended, the rekeys it completed and the bytes of data it sent and
+// received.
+func (c *Collector) RecordSessionSummary(d time.Duration, rekeys int64, bytes uint64) {
+	c.sessionDuration.Observe(d.Seconds())
+	c.rekeysPerSession.Observe(float64(rekeys))
+	c.bytesPerSession.Observe(float64(bytes))
+}
+
 // SessionFailed records a failed session attempt.
 func (c *Collector) SessionFailed() {
 	c.sessionsFailed.Add(1)
@@ -113,6 +172,36 @@ func (c *Collector) RecordHandshakeLatency(d time.Duration) {
 	c.handshakeLatency.Observe(float64(d.Milliseconds()))
 }
 
+// RecordHandshakeLatencyWithTrace records a handshake duration and keeps
+// traceID as an exemplar of its histogram bucket, which the OpenMetrics
+// exposition attaches to the bucket. An empty traceID records no exemplar.
+func (c *Collector) RecordHandshakeLatencyWithTrace(d time.Duration, traceID string) {
+	c.handshakeLatency.ObserveWithExemplar(float64(d.Milliseconds()), traceID)
+}
+
+// RecordHandshakeFailure records a failed handshake under the given reason
+// (e.g., "unsupported_version", "bad_ciphertext", "bad_public_key", "auth_failed", "unauthorized", "timeout").
+func (c *Collector) RecordHandshakeFailure(reason string) {
+	if reason == "" {
+		reason = "unknown"
+	}
+	c.failuresMu.Lock()
+	c.handshakeFailures[reason]++
+	c.failuresMu.Unlock()
+}
+
+// handshakeFailureCounts returns a copy of the per-reason failure counts.
+func (c *Collector) handshakeFailureCounts() map[string]int64 {
+	c.failuresMu.Lock()
+	defer c.failuresMu.Unlock()
+
+	counts := make(map[string]int64, len(c.handshakeFailures))
+	for reason, n := range c.handshakeFailures {
+		counts[reason] = n
+	}
+	return counts
+}
+
 // --- Traffic Metrics ---
 
 // RecordBytesSent adds to the bytes sent counter.
@@ -141,6 +230,24 @@ func (c *Collector) RecordPacketReceived() {
 	c.packetsRecv.Add(1)
 }
 
+// RecordWireBytesSent adds to the counter of bytes written to connections,
+// including framing and AEAD overhead.
+func (c *Collector) RecordWireBytesSent(n int) {
+	if n < 0 {
+		return
+	}
+	c.wireBytesSent.Add(int64(n))
+}
+
+// RecordWireBytesReceived adds to the counter of bytes read from
+// connections, including framing and AEAD overhead.
+func (c *Collector) RecordWireBytesReceived(n int) {
+	if n < 0 {
+		return
+	}
+	c.wireBytesReceived.Add(int64(n))
+}
+
 // --- Security Metrics ---
 
 // RecordReplayBlocked increments the replay attack counter.
@@ -148,6 +255,18 @@ func (c *Collector) RecordReplayBlocked() {
 	c.replayAttacksBlocked.Add(1)
 }
 
+// RecordReplayRejected counts a replay window rejection by reason,
+// tunnel.ReplayRejectedOld or tunnel.ReplayRejectedDuplicate. It does not
+// increment the combined counter recorded by RecordReplayBlocked.
+func (c *Collector) RecordReplayRejected(reason string) {
+	switch reason {
+	case tunnel.ReplayRejectedOld:
+		c.replayRejectedOld.Add(1)
+	case tunnel.ReplayRejectedDuplicate:
+		c.replayRejectedDuplicate.Add(1)
+	}
+}
+
 // RecordAuthFailure increments the authentication failure counter.
 func (c *Collector) RecordAuthFailure() {
 	c.authFailures.Add(1)
@@ -168,6 +287,47 @@ func (c *Collector) RecordRekeyFailed() {
 	c.rekeysFailed.Add(1)
 }
 
+// RecordUnexpectedRekey records a rekey message that arrived when the
+// rekey state did not expect it.
+func (c *Collector) RecordUnexpectedRekey() {
+	c.unexpectedRekeys.Add(1)
+}
+
+// TrackRemainingCapacity reports the AEAD capacity of an active session,
+// as returned by remaining (typically tunnel.Session.RemainingCapacity),
+// under sessionID in each snapshot until UntrackRemainingCapacity is
+// called.
+func (c *Collector) TrackRemainingCapacity(sessionID string, remaining func() uint64) {
+	c.capacityMu.Lock()
+	c.remainingCapacity[sessionID] = remaining
+	c.capacityMu.Unlock()
+}
+
+// UntrackRemainingCapacity stops reporting a session's AEAD capacity.
+func (c *Collector) UntrackRemainingCapacity(sessionID string) {
+	c.capacityMu.Lock()
+	delete(c.remainingCapacity, sessionID)
+	c.capacityMu.Unlock()
+}
+
+// remainingCapacities returns the current AEAD capacity of each tracked
+// session.
+func (c *Collector) remainingCapacities() map[string]uint64 {
+	c.capacityMu.Lock()
+	tracked := make(map[string]func() uint64, len(c.remainingCapacity))
+	for id, remaining := range c.remainingCapacity {
+		tracked[id] = remaining
+	}
+	c.capacityMu.Unlock()
+
+	// Query sessions without holding the lock
+	capacities := make(map[string]uint64, len(tracked))
+	for id, remaining := range tracked {
+		capacities[id] = remaining()
+	}
+	return capacities
+}
+
 // --- Error Metrics ---
 
 // RecordEncryptError increments encryption error counter.
@@ -195,6 +355,16 @@ func (c *Collector) RecordHandshakeRateLimit() {
 	c.handshakeRateLimits.Add(1)
 }
 
+// RecordSendRateLimited records n bytes of data that waited delay for the
+// send rate limit.
+func (c *Collector) RecordSendRateLimited(n int, delay time.Duration) {
+	if n < 0 || delay < 0 {
+		return
+	}
+	c.sendRateLimitedBytes.Add(int64(n))
+	c.sendRateLimitDelay.Add(int64(delay))
+}
+
 // --- Performance Metrics ---
 
 // RecordEncryptLatency records encryption operation latency.
@@ -207,6 +377,16 @@ func (c *Collector) RecordDecryptLatency(d time.Duration) {
 	c.decryptLatency.Observe(float64(d.Microseconds()))
 }
 
+// RecordNetworkWriteLatency records the latency of a connection write.
+func (c *Collector) RecordNetworkWriteLatency(d time.Duration) {
+	c.networkWriteLatency.Observe(float64(d.Microseconds()))
+}
+
+// RecordNetworkReadLatency records the latency of a connection read.
+func (c *Collector) RecordNetworkReadLatency(d time.Duration) {
+	c.networkReadLatency.Observe(float64(d.Microseconds()))
+}
+
 // --- Snapshot ---
 
 // Snapshot returns a point-in-time snapshot of all metrics.
@@ -222,18 +402,32 @@ type Snapshot struct {
 	SessionsTotal  int64
 	SessionsFailed int64
 
+	// HandshakeFailures counts failed handshakes by reason
+	HandshakeFailures map[string]int64
+
 	// Traffic metrics
 	BytesSent     int64
 	BytesReceived int64
 	PacketsSent   int64
 	PacketsRecv   int64
 
+	// Wire traffic metrics
+	WireBytesSent     int64
+	WireBytesReceived int64
+
 	// Security metrics
-	ReplayAttacksBlocked int64
-	AuthFailures         int64
-	RekeysInitiated      int64
-	RekeysCompleted      int64
-	RekeysFailed         int64
+	ReplayAttacksBlocked    int64
+	ReplayRejectedOld       int64
+	ReplayRejectedDuplicate int64
+	AuthFailures            int64
+	RekeysInitiated         int64
+	RekeysCompleted         int64
+	RekeysFailed            int64
+	UnexpectedRekeys        int64
+
+	// AEADRemainingCapacity holds the records each tracked session can
+	// still encrypt before a forced rekey, by session ID
+	AEADRemainingCapacity map[string]uint64
 
 	// Error metrics
 	EncryptErrors  int64
@@ -244,11 +438,23 @@ type Snapshot struct {
 	ConnectionRateLimits int64
 	HandshakeRateLimits  int64
 
+	// SendRateLimitedBytes counts the data delayed by send rate limits and
+	// SendRateLimitDelay the total time it waited
+	SendRateLimitedBytes int64
+	SendRateLimitDelay   time.Duration
+
 	// Histogram summaries
 	HandshakeLatency HistogramSummary
 	EncryptLatency   HistogramSummary
 	DecryptLatency   HistogramSummary
 
+	NetworkWriteLatency HistogramSummary
+	NetworkReadLatency  HistogramSummary
+
+	SessionDuration  HistogramSummary
+	RekeysPerSession HistogramSummary
+	BytesPerSession  HistogramSummary
+
 	// Labels
 	Labels Labels
 }
@@ -256,29 +462,43 @@ type Snapshot struct {
 // Snapshot returns a point-in-time snapshot of all metrics.
 func (c *Collector) Snapshot() Snapshot {
 	return Snapshot{
-		Timestamp:            time.Now(),
-		Uptime:               time.Since(c.createdAt),
-		SessionsActive:       c.sessionsActive.Load(),
-		SessionsTotal:        c.sessionsTotal.Load(),
-		SessionsFailed:       c.sessionsFailed.Load(),
-		BytesSent:            c.bytesSent.Load(),
-		BytesReceived:        c.bytesReceived.Load(),
-		PacketsSent:          c.packetsSent.Load(),
-		PacketsRecv:          c.packetsRecv.Load(),
-		ReplayAttacksBlocked: c.replayAttacksBlocked.Load(),
-		AuthFailures:         c.authFailures.Load(),
-		RekeysInitiated:      c.rekeysInitiated.Load(),
-		RekeysCompleted:      c.rekeysCompleted.Load(),
-		RekeysFailed:         c.rekeysFailed.Load(),
-		EncryptErrors:        c.encryptErrors.Load(),
-		DecryptErrors:        c.decryptErrors.Load(),
-		ProtocolErrors:       c.protocolErrors.Load(),
-		ConnectionRateLimits: c.connectionRateLimits.Load(),
-		HandshakeRateLimits:  c.handshakeRateLimits.Load(),
-		HandshakeLatency:     c.handshakeLatency.Summary(),
-		EncryptLatency:       c.encryptLatency.Summary(),
-		DecryptLatency:       c.decryptLatency.Summary(),
-		Labels:               c.labels,
+		Timestamp:               time.Now(),
+		Uptime:                  time.Since(c.createdAt),
+		SessionsActive:          c.sessionsActive.Load(),
+		SessionsTotal:           c.sessionsTotal.Load(),
+		SessionsFailed:          c.sessionsFailed.Load(),
+		BytesSent:               c.bytesSent.Load(),
+		BytesReceived:           c.bytesReceived.Load(),
+		PacketsSent:             c.packetsSent.Load(),
+		PacketsRecv:             c.packetsRecv.Load(),
+		WireBytesSent:           c.wireBytesSent.Load(),
+		WireBytesReceived:       c.wireBytesReceived.Load(),
+		ReplayAttacksBlocked:    c.replayAttacksBlocked.Load(),
+		ReplayRejectedOld:       c.replayRejectedOld.Load(),
+		ReplayRejectedDuplicate: c.replayRejectedDuplicate.Load(),
+		AuthFailures:            c.authFailures.Load(),
+		RekeysInitiated:         c.rekeysInitiated.Load(),
+		RekeysCompleted:         c.rekeysCompleted.Load(),
+		RekeysFailed:            c.rekeysFailed.Load(),
+		UnexpectedRekeys:        c.unexpectedRekeys.Load(),
+		EncryptErrors:           c.encryptErrors.Load(),
+		DecryptErrors:           c.decryptErrors.Load(),
+		ProtocolErrors:          c.protocolErrors.Load(),
+		ConnectionRateLimits:    c.connectionRateLimits.Load(),
+		HandshakeRateLimits:     c.handshakeRateLimits.Load(),
+		SendRateLimitedBytes:    c.sendRateLimitedBytes.Load(),
+		SendRateLimitDelay:      time.Duration(c.sendRateLimitDelay.Load()),
+		HandshakeFailures:       c.handshakeFailureCounts(),
+		AEADRemainingCapacity:   c.remainingCapacities(),
+		HandshakeLatency:        c.handshakeLatency.Summary(),
+		EncryptLatency:          c.encryptLatency.Summary(),
+		DecryptLatency:          c.decryptLatency.Summary(),
+		NetworkWriteLatency:     c.networkWriteLatency.Summary(),
+		NetworkReadLatency:      c.networkReadLatency.Summary(),
+		SessionDuration:         c.sessionDuration.Summary(),
+		RekeysPerSession:        c.rekeysPerSession.Summary(),
+		BytesPerSession:         c.bytesPerSession.Summary(),
+		Labels:                  c.labels,
 	}
 }
 
@@ -291,19 +511,34 @@ func (c *Collector) Reset() {
 	c.bytesReceived.Store(0)
 	c.packetsSent.Store(0)
 	c.packetsRecv.Store(0)
+	c.wireBytesSent.Store(0)
+	c.wireBytesReceived.Store(0)
 	c.replayAttacksBlocked.Store(0)
+	c.replayRejectedOld.Store(0)
+	c.replayRejectedDuplicate.Store(0)
 	c.authFailures.Store(0)
 	c.rekeysInitiated.Store(0)
 	c.rekeysCompleted.Store(0)
 	c.rekeysFailed.Store(0)
+	c.unexpectedRekeys.Store(0)
 	c.encryptErrors.Store(0)
 	c.decryptErrors.Store(0)
 	c.protocolErrors.Store(0)
 	c.connectionRateLimits.Store(0)
 	c.handshakeRateLimits.Store(0)
+	c.sendRateLimitedBytes.Store(0)
+	c.sendRateLimitDelay.Store(0)
 	c.handshakeLatency.Reset()
+	c.failuresMu.Lock()
+	c.handshakeFailures = make(map[string]int64)
+	c.failuresMu.Unlock()
 	c.encryptLatency.Reset()
 	c.decryptLatency.Reset()
+	c.networkWriteLatency.Reset()
+	c.networkReadLatency.Reset()
+	c.sessionDuration.Reset()
+	c.rekeysPerSession.Reset()
+	c.bytesPerSession.Reset()
 	c.createdAt = time.Now()
 }
 
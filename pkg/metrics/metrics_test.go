@@ -1,8 +1,13 @@
 package metrics
 
 import (
+	"bytes"
+	"context"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
 
 func TestNewCollector(t *testing.T) {
@@ -60,6 +65,9 @@ func TestCollectorTrafficMetrics(t *testing.T) {
 	c.RecordPacketSent()
 	c.RecordPacketSent()
 	c.RecordPacketReceived()
+	c.RecordWireBytesSent(1541)
+	c.RecordWireBytesReceived(2041)
+	c.RecordWireBytesReceived(-1)
 
 	snap := c.Snapshot()
 	if snap.BytesSent != 1500 {
@@ -74,6 +82,9 @@ func TestCollectorTrafficMetrics(t *testing.T) {
 	if snap.PacketsRecv != 1 {
 		t.Errorf("expected 1 packet received, got %d", snap.PacketsRecv)
 	}
+	if snap.WireBytesSent != 1541 || snap.WireBytesReceived != 2041 {
+		t.Errorf("expected 1541/2041 wire bytes, got %d/%d", snap.WireBytesSent, snap.WireBytesReceived)
+	}
 }
 
 func TestCollectorSecurityMetrics(t *testing.T) {
@@ -84,6 +95,7 @@ func TestCollectorSecurityMetrics(t *testing.T) {
 	c.RecordRekeyInitiated()
 	c.RecordRekeyCompleted()
 	c.RecordRekeyFailed()
+	c.RecordUnexpectedRekey()
 
 	snap := c.Snapshot()
 	if snap.ReplayAttacksBlocked != 1 {
@@ -101,6 +113,63 @@ func TestCollectorSecurityMetrics(t *testing.T) {
 	if snap.RekeysFailed != 1 {
 		t.Errorf("expected 1 rekey failed, got %d", snap.RekeysFailed)
 	}
+	if snap.UnexpectedRekeys != 1 {
+		t.Errorf("expected 1 unexpected rekey, got %d", snap.UnexpectedRekeys)
+	}
+}
+
+func TestCollectorReplayRejected(t *testing.T) {
+	c := NewCollector(nil)
+
+	c.RecordReplayRejected(tunnel.ReplayRejectedOld)
+	c.RecordReplayRejected(tunnel.ReplayRejectedDuplicate)
+	c.RecordReplayRejected(tunnel.ReplayRejectedDuplicate)
+	c.RecordReplayRejected("unknown")
+
+	snap := c.Snapshot()
+	if snap.ReplayRejectedOld != 1 {
+		t.Errorf("expected 1 old replay rejection, got %d", snap.ReplayRejectedOld)
+	}
+	if snap.ReplayRejectedDuplicate != 2 {
+		t.Errorf("expected 2 duplicate replay rejections, got %d", snap.ReplayRejectedDuplicate)
+	}
+
+	c.Reset()
+	snap = c.Snapshot()
+	if snap.ReplayRejectedOld != 0 || snap.ReplayRejectedDuplicate != 0 {
+		t.Error("expected replay rejections to be cleared by Reset")
+	}
+}
+
+func TestCollectorHandshakeFailures(t *testing.T) {
+	c := NewCollector(nil)
+
+	c.RecordHandshakeFailure("timeout")
+	c.RecordHandshakeFailure("timeout")
+	c.RecordHandshakeFailure("auth_failed")
+	c.RecordHandshakeFailure("")
+
+	snap := c.Snapshot()
+	if got := snap.HandshakeFailures["timeout"]; got != 2 {
+		t.Errorf("expected 2 timeout failures, got %d", got)
+	}
+	if got := snap.HandshakeFailures["auth_failed"]; got != 1 {
+		t.Errorf("expected 1 auth_failed failure, got %d", got)
+	}
+	if got := snap.HandshakeFailures["unknown"]; got != 1 {
+		t.Errorf("expected empty reason to be recorded as unknown, got %d", got)
+	}
+
+	// Snapshots are independent of later updates
+	c.RecordHandshakeFailure("timeout")
+	if got := snap.HandshakeFailures["timeout"]; got != 2 {
+		t.Errorf("snapshot changed after recording, got %d", got)
+	}
+
+	c.Reset()
+	if n := len(c.Snapshot().HandshakeFailures); n != 0 {
+		t.Errorf("expected no failures after reset, got %d reasons", n)
+	}
 }
 
 func TestCollectorErrorMetrics(t *testing.T) {
@@ -145,6 +214,59 @@ func TestCollectorLatencyMetrics(t *testing.T) {
 	}
 }
 
+func TestTunnelObserverNetworkLatency(t *testing.T) {
+	c := NewCollector(nil)
+	o := NewTunnelObserver(TunnelObserverConfig{Collector: c})
+
+	_, done := o.OnNetworkWrite(context.Background(), 64)
+	time.Sleep(2 * time.Millisecond)
+	done(nil)
+	_, done = o.OnNetworkRead(context.Background())
+	done(nil)
+
+	snap := c.Snapshot()
+	if snap.NetworkWriteLatency.Count != 1 || snap.NetworkWriteLatency.Sum < 2000 {
+		t.Errorf("network write latency = %d observations summing to %.0fus, want 1 of at least 2000us",
+			snap.NetworkWriteLatency.Count, snap.NetworkWriteLatency.Sum)
+	}
+	if snap.NetworkReadLatency.Count != 1 {
+		t.Errorf("expected 1 network read latency observation, got %d", snap.NetworkReadLatency.Count)
+	}
+	if snap.EncryptLatency.Count != 0 || snap.DecryptLatency.Count != 0 {
+		t.Error("network timing should not be recorded as crypto latency")
+	}
+}
+
+func TestTunnelObserverSendRateLimited(t *testing.T) {
+	c := NewCollector(nil)
+	o := NewTunnelObserver(TunnelObserverConfig{Collector: c})
+
+	o.OnSendRateLimited(1000, 250*time.Millisecond)
+	o.OnSendRateLimited(500, 250*time.Millisecond)
+
+	snap := c.Snapshot()
+	if snap.SendRateLimitedBytes != 1500 || snap.SendRateLimitDelay != 500*time.Millisecond {
+		t.Errorf("send rate limit = %d bytes delayed %v, want 1500 bytes delayed 500ms",
+			snap.SendRateLimitedBytes, snap.SendRateLimitDelay)
+	}
+
+	var buf bytes.Buffer
+	NewPrometheusExporter(c, "").WriteMetrics(&buf)
+	for _, want := range []string{
+		"rate_limit_send_delayed_bytes_total 1500",
+		"rate_limit_send_delay_seconds_total 0.5",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Prometheus output missing %q", want)
+		}
+	}
+
+	c.Reset()
+	if snap := c.Snapshot(); snap.SendRateLimitedBytes != 0 || snap.SendRateLimitDelay != 0 {
+		t.Error("Reset should clear the send rate limit counters")
+	}
+}
+
 func TestCollectorReset(t *testing.T) {
 	c := NewCollector(nil)
 
@@ -18,6 +18,16 @@ func (t *OTelTracer) StartSpan(ctx context.Context, name string, opts ...SpanOpt
 	return ctx, func(err error) {}
 }
 
+// TraceParent returns "" since the stub records no spans.
+func (t *OTelTracer) TraceParent(ctx context.Context) string {
+	return ""
+}
+
+// ContextWithTraceParent returns ctx unchanged.
+func (t *OTelTracer) ContextWithTraceParent(ctx context.Context, traceparent string) context.Context {
+	return ctx
+}
+
 // OTelEnabled reports whether OpenTelemetry support is built in.
 func OTelEnabled() bool {
 	return false
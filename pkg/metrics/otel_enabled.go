@@ -10,6 +10,7 @@ import (
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
 	"go.opentelemetry.io/otel/trace"
 )
 
@@ -57,6 +58,19 @@ func (t *OTelTracer) StartSpan(ctx context.Context, name string, opts ...SpanOpt
 	}
 }
 
+// TraceParent returns the W3C traceparent of the span in ctx.
+func (t *OTelTracer) TraceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+	return carrier.Get("traceparent")
+}
+
+// ContextWithTraceParent returns ctx with the remote span context described
+// by traceparent.
+func (t *OTelTracer) ContextWithTraceParent(ctx context.Context, traceparent string) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{"traceparent": traceparent})
+}
+
 // OTelEnabled reports whether OpenTelemetry support is built in.
 func OTelEnabled() bool {
 	return true
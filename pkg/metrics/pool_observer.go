@@ -22,6 +22,10 @@ type PoolMetricsObserver struct {
 	connectionsClosed    atomic.Uint64
 	healthChecksTotal    atomic.Uint64
 	healthChecksFailed   atomic.Uint64
+	circuitOpens         atomic.Uint64
+
+	// Circuit breaker state (tunnel.CircuitState)
+	circuitState atomic.Int32
 
 	// Histograms
 	acquireLatency *Histogram
@@ -151,6 +155,19 @@ func (o *PoolMetricsObserver) OnPoolStats(stats tunnel.PoolStatsSnapshot) {
 	})
 }
 
+// OnCircuitStateChange implements tunnel.PoolObserver.
+func (o *PoolMetricsObserver) OnCircuitStateChange(state tunnel.CircuitState) {
+	o.circuitState.Store(int32(state))
+
+	fields := Fields{"state": state.String()}
+	if state == tunnel.CircuitOpen {
+		o.circuitOpens.Add(1)
+		o.logger.Warn("circuit breaker opened", fields)
+		return
+	}
+	o.logger.Info("circuit breaker state changed", fields)
+}
+
 // PoolMetricsSnapshot is a snapshot of pool metrics.
 type PoolMetricsSnapshot struct {
 	// Current state (gauges)
@@ -166,6 +183,10 @@ type PoolMetricsSnapshot struct {
 	ConnectionsClosed    uint64
 	HealthChecksTotal    uint64
 	HealthChecksFailed   uint64
+	CircuitOpens         uint64
+
+	// Circuit breaker state
+	CircuitState tunnel.CircuitState
 
 	// Histogram summaries
 	AcquireLatency HistogramSummary
@@ -188,6 +209,8 @@ func (o *PoolMetricsObserver) Snapshot() PoolMetricsSnapshot {
 		ConnectionsClosed:    o.connectionsClosed.Load(),
 		HealthChecksTotal:    o.healthChecksTotal.Load(),
 		HealthChecksFailed:   o.healthChecksFailed.Load(),
+		CircuitOpens:         o.circuitOpens.Load(),
+		CircuitState:         tunnel.CircuitState(o.circuitState.Load()),
 		AcquireLatency:       o.acquireLatency.Summary(),
 		DialLatency:          o.dialLatency.Summary(),
 		PoolName:             o.poolName,
@@ -206,6 +229,8 @@ func (o *PoolMetricsObserver) Reset() {
 	o.connectionsClosed.Store(0)
 	o.healthChecksTotal.Store(0)
 	o.healthChecksFailed.Store(0)
+	o.circuitOpens.Store(0)
+	o.circuitState.Store(0)
 	o.acquireLatency.Reset()
 	o.dialLatency.Reset()
 }
@@ -6,18 +6,27 @@ import (
 	"math"
 	"net/http"
 	"sort"
+	"strconv"
 	"strings"
 )
 
-// PrometheusExporter exports metrics in Prometheus text format.
+// Content types of the two exposition formats.
+const (
+	prometheusContentType  = "text/plain; version=0.0.4; charset=utf-8"
+	openMetricsContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// PrometheusExporter exports metrics in Prometheus text format, or in
+// OpenMetrics format with histogram exemplars.
 type PrometheusExporter struct {
 	collector *Collector
 	namespace string
 }
 
 type promWriter struct {
-	w   io.Writer
-	err error
+	w           io.Writer
+	err         error
+	openMetrics bool // Write OpenMetrics, with exemplars and counter family names
 }
 
 func (pw *promWriter) writef(format string, args ...interface{}) {
@@ -36,17 +45,38 @@ func NewPrometheusExporter(c *Collector, namespace string) *PrometheusExporter {
 	}
 }
 
-// Handler returns an http.Handler that serves Prometheus metrics.
+// Handler returns an http.Handler that serves Prometheus metrics. Clients
+// whose Accept header lists application/openmetrics-text are served
+// OpenMetrics, which carries histogram exemplars.
 func (e *PrometheusExporter) Handler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		if strings.Contains(r.Header.Get("Accept"), "application/openmetrics-text") {
+			w.Header().Set("Content-Type", openMetricsContentType)
+			e.WriteOpenMetrics(w)
+			return
+		}
+		w.Header().Set("Content-Type", prometheusContentType)
 		e.WriteMetrics(w)
 	})
 }
 
 // WriteMetrics writes all metrics in Prometheus text format to the writer.
 func (e *PrometheusExporter) WriteMetrics(w io.Writer) {
-	pw := &promWriter{w: w}
+	e.writeMetrics(&promWriter{w: w})
+}
+
+// WriteOpenMetrics writes all metrics in OpenMetrics text format to the
+// writer. Histogram buckets carry the trace ID of their most recent traced
+// observation as an exemplar, for example after
+// Collector.RecordHandshakeLatencyWithTrace.
+func (e *PrometheusExporter) WriteOpenMetrics(w io.Writer) {
+	pw := &promWriter{w: w, openMetrics: true}
+	e.writeMetrics(pw)
+	pw.writef("# EOF\n")
+}
+
+// writeMetrics writes all metrics in the format of pw.
+func (e *PrometheusExporter) writeMetrics(pw *promWriter) {
 	snap := e.collector.Snapshot()
 	labels := e.formatLabels(snap.Labels)
 
@@ -63,6 +93,22 @@ func (e *PrometheusExporter) WriteMetrics(w io.Writer) {
 	e.writeType(pw, "sessions_failed_total", "counter")
 	e.writeMetric(pw, "sessions_failed_total", labels, float64(snap.SessionsFailed))
 
+	e.writeHelp(pw, "handshake_failures_total", "Total failed handshakes by reason")
+	e.writeType(pw, "handshake_failures_total", "counter")
+	reasons := make([]string, 0, len(snap.HandshakeFailures))
+	for reason := range snap.HandshakeFailures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+	for _, reason := range reasons {
+		reasonLabels := make(Labels, len(snap.Labels)+1)
+		for k, v := range snap.Labels {
+			reasonLabels[k] = v
+		}
+		reasonLabels["reason"] = reason
+		e.writeMetric(pw, "handshake_failures_total", e.formatLabels(reasonLabels), float64(snap.HandshakeFailures[reason]))
+	}
+
 	// --- Traffic Metrics ---
 	e.writeHelp(pw, "bytes_sent_total", "Total bytes sent")
 	e.writeType(pw, "bytes_sent_total", "counter")
@@ -80,11 +126,27 @@ func (e *PrometheusExporter) WriteMetrics(w io.Writer) {
 	e.writeType(pw, "packets_received_total", "counter")
 	e.writeMetric(pw, "packets_received_total", labels, float64(snap.PacketsRecv))
 
+	e.writeHelp(pw, "wire_bytes_sent_total", "Total bytes written to connections, including framing and AEAD overhead")
+	e.writeType(pw, "wire_bytes_sent_total", "counter")
+	e.writeMetric(pw, "wire_bytes_sent_total", labels, float64(snap.WireBytesSent))
+
+	e.writeHelp(pw, "wire_bytes_received_total", "Total bytes read from connections, including framing and AEAD overhead")
+	e.writeType(pw, "wire_bytes_received_total", "counter")
+	e.writeMetric(pw, "wire_bytes_received_total", labels, float64(snap.WireBytesReceived))
+
 	// --- Security Metrics ---
 	e.writeHelp(pw, "replay_attacks_blocked_total", "Total replay attacks blocked")
 	e.writeType(pw, "replay_attacks_blocked_total", "counter")
 	e.writeMetric(pw, "replay_attacks_blocked_total", labels, float64(snap.ReplayAttacksBlocked))
 
+	e.writeHelp(pw, "replay_rejected_old_total", "Total packets rejected as older than the replay window")
+	e.writeType(pw, "replay_rejected_old_total", "counter")
+	e.writeMetric(pw, "replay_rejected_old_total", labels, float64(snap.ReplayRejectedOld))
+
+	e.writeHelp(pw, "replay_rejected_duplicate_total", "Total packets rejected as duplicates within the replay window")
+	e.writeType(pw, "replay_rejected_duplicate_total", "counter")
+	e.writeMetric(pw, "replay_rejected_duplicate_total", labels, float64(snap.ReplayRejectedDuplicate))
+
 	e.writeHelp(pw, "auth_failures_total", "Total authentication failures")
 	e.writeType(pw, "auth_failures_total", "counter")
 	e.writeMetric(pw, "auth_failures_total", labels, float64(snap.AuthFailures))
@@ -101,6 +163,26 @@ func (e *PrometheusExporter) WriteMetrics(w io.Writer) {
 	e.writeType(pw, "rekeys_failed_total", "counter")
 	e.writeMetric(pw, "rekeys_failed_total", labels, float64(snap.RekeysFailed))
 
+	e.writeHelp(pw, "unexpected_rekeys_total", "Total rekey messages received when no matching rekey was in progress")
+	e.writeType(pw, "unexpected_rekeys_total", "counter")
+	e.writeMetric(pw, "unexpected_rekeys_total", labels, float64(snap.UnexpectedRekeys))
+
+	e.writeHelp(pw, "aead_remaining_capacity", "Records each active session can encrypt before nonce exhaustion forces a rekey")
+	e.writeType(pw, "aead_remaining_capacity", "gauge")
+	sessionIDs := make([]string, 0, len(snap.AEADRemainingCapacity))
+	for id := range snap.AEADRemainingCapacity {
+		sessionIDs = append(sessionIDs, id)
+	}
+	sort.Strings(sessionIDs)
+	for _, id := range sessionIDs {
+		sessionLabels := make(Labels, len(snap.Labels)+1)
+		for k, v := range snap.Labels {
+			sessionLabels[k] = v
+		}
+		sessionLabels["session"] = id
+		e.writeMetric(pw, "aead_remaining_capacity", e.formatLabels(sessionLabels), float64(snap.AEADRemainingCapacity[id]))
+	}
+
 	// --- Error Metrics ---
 	e.writeHelp(pw, "encrypt_errors_total", "Total encryption errors")
 	e.writeType(pw, "encrypt_errors_total", "counter")
@@ -123,6 +205,14 @@ func (e *PrometheusExporter) WriteMetrics(w io.Writer) {
 	e.writeType(pw, "rate_limit_handshakes_total", "counter")
 	e.writeMetric(pw, "rate_limit_handshakes_total", labels, float64(snap.HandshakeRateLimits))
 
+	e.writeHelp(pw, "rate_limit_send_delayed_bytes_total", "Total bytes of data delayed by send rate limiting")
+	e.writeType(pw, "rate_limit_send_delayed_bytes_total", "counter")
+	e.writeMetric(pw, "rate_limit_send_delayed_bytes_total", labels, float64(snap.SendRateLimitedBytes))
+
+	e.writeHelp(pw, "rate_limit_send_delay_seconds_total", "Total time sends waited for send rate limiting")
+	e.writeType(pw, "rate_limit_send_delay_seconds_total", "counter")
+	e.writeMetric(pw, "rate_limit_send_delay_seconds_total", labels, snap.SendRateLimitDelay.Seconds())
+
 	// --- Uptime ---
 	e.writeHelp(pw, "uptime_seconds", "Time since the collector was created")
 	e.writeType(pw, "uptime_seconds", "gauge")
@@ -132,16 +222,30 @@ func (e *PrometheusExporter) WriteMetrics(w io.Writer) {
 	e.writeHistogram(pw, "handshake_duration_milliseconds", "Handshake duration in milliseconds", labels, snap.HandshakeLatency)
 	e.writeHistogram(pw, "encrypt_duration_microseconds", "Encryption duration in microseconds", labels, snap.EncryptLatency)
 	e.writeHistogram(pw, "decrypt_duration_microseconds", "Decryption duration in microseconds", labels, snap.DecryptLatency)
+	e.writeHistogram(pw, "network_write_duration_microseconds", "Connection write duration in microseconds", labels, snap.NetworkWriteLatency)
+	e.writeHistogram(pw, "network_read_duration_microseconds", "Connection read duration in microseconds", labels, snap.NetworkReadLatency)
+	e.writeHistogram(pw, "session_duration_seconds", "Lifetime of ended sessions in seconds", labels, snap.SessionDuration)
+	e.writeHistogram(pw, "rekeys_per_session", "Rekeys completed by each ended session", labels, snap.RekeysPerSession)
+	e.writeHistogram(pw, "bytes_per_session", "Data bytes sent and received by each ended session", labels, snap.BytesPerSession)
 }
 
 // writeHelp writes a HELP line.
 func (e *PrometheusExporter) writeHelp(pw *promWriter, name, help string) {
-	pw.writef("# HELP %s_%s %s\n", e.namespace, name, help)
+	pw.writef("# HELP %s_%s %s\n", e.namespace, pw.family(name), help)
 }
 
 // writeType writes a TYPE line.
 func (e *PrometheusExporter) writeType(pw *promWriter, name, typ string) {
-	pw.writef("# TYPE %s_%s %s\n", e.namespace, name, typ)
+	pw.writef("# TYPE %s_%s %s\n", e.namespace, pw.family(name), typ)
+}
+
+// family returns the metric family name of the metric name. OpenMetrics
+// names counter families without the "_total" suffix of their samples.
+func (pw *promWriter) family(name string) string {
+	if pw.openMetrics {
+		return strings.TrimSuffix(name, "_total")
+	}
+	return name
 }
 
 // writeMetric writes a single metric line.
@@ -167,10 +271,16 @@ func (e *PrometheusExporter) writeHistogram(pw *promWriter, name, help, labels s
 			le = "+Inf"
 		}
 		if labels != "" {
-			pw.writef("%s_bucket{%s,le=\"%s\"} %d\n", fullName, labels, le, b.Count)
+			pw.writef("%s_bucket{%s,le=\"%s\"} %d", fullName, labels, le, b.Count)
 		} else {
-			pw.writef("%s_bucket{le=\"%s\"} %d\n", fullName, le, b.Count)
+			pw.writef("%s_bucket{le=\"%s\"} %d", fullName, le, b.Count)
 		}
+		if pw.openMetrics && b.Exemplar != nil {
+			ts := float64(b.Exemplar.Timestamp.UnixMilli()) / 1000
+			pw.writef(" # {trace_id=\"%s\"} %g %s", escapePromValue(b.Exemplar.TraceID), b.Exemplar.Value,
+				strconv.FormatFloat(ts, 'f', 3, 64))
+		}
+		pw.writef("\n")
 	}
 
 	// Write sum and count
@@ -241,6 +351,10 @@ func (e *PrometheusExporter) WritePoolMetrics(w io.Writer, pool *PoolMetricsObse
 	e.writeType(pw, "pool_waiting_count", "gauge")
 	e.writeMetric(pw, "pool_waiting_count", labels, float64(snap.WaitingCount))
 
+	e.writeHelp(pw, "pool_circuit_state", "Circuit breaker state (0=closed, 1=open, 2=half-open)")
+	e.writeType(pw, "pool_circuit_state", "gauge")
+	e.writeMetric(pw, "pool_circuit_state", labels, float64(snap.CircuitState))
+
 	// --- Pool Counters ---
 	e.writeHelp(pw, "pool_acquires_total", "Total number of successful connection acquires")
 	e.writeType(pw, "pool_acquires_total", "counter")
@@ -266,6 +380,10 @@ func (e *PrometheusExporter) WritePoolMetrics(w io.Writer, pool *PoolMetricsObse
 	e.writeType(pw, "pool_health_checks_failed_total", "counter")
 	e.writeMetric(pw, "pool_health_checks_failed_total", labels, float64(snap.HealthChecksFailed))
 
+	e.writeHelp(pw, "pool_circuit_opens_total", "Total number of times the circuit breaker opened")
+	e.writeType(pw, "pool_circuit_opens_total", "counter")
+	e.writeMetric(pw, "pool_circuit_opens_total", labels, float64(snap.CircuitOpens))
+
 	// --- Pool Histograms ---
 	e.writeHistogram(pw, "pool_acquire_duration_milliseconds", "Time to acquire a connection in milliseconds", labels, snap.AcquireLatency)
 	e.writeHistogram(pw, "pool_dial_duration_milliseconds", "Time to establish new connection in milliseconds", labels, snap.DialLatency)
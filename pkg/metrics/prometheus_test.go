@@ -2,11 +2,14 @@ package metrics
 
 import (
 	"bytes"
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
 
 func TestPrometheusExporterWriteMetrics(t *testing.T) {
@@ -52,6 +55,60 @@ func TestPrometheusExporterWriteMetrics(t *testing.T) {
 	}
 }
 
+func TestPrometheusExporterHandshakeFailures(t *testing.T) {
+	c := NewCollector(Labels{"instance": "test"})
+	c.RecordHandshakeFailure("timeout")
+	c.RecordHandshakeFailure("bad_ciphertext")
+	c.RecordHandshakeFailure("bad_ciphertext")
+
+	var buf bytes.Buffer
+	NewPrometheusExporter(c, "quantum_vpn").WriteMetrics(&buf)
+	output := buf.String()
+
+	expected := []string{
+		"# TYPE quantum_vpn_handshake_failures_total counter",
+		`quantum_vpn_handshake_failures_total{instance="test",reason="bad_ciphertext"} 2`,
+		`quantum_vpn_handshake_failures_total{instance="test",reason="timeout"} 1`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected %q in output", line)
+		}
+	}
+}
+
+func TestPrometheusExporterRemainingCapacity(t *testing.T) {
+	c := NewCollector(Labels{"instance": "test"})
+	remaining := uint64(1000)
+	c.TrackRemainingCapacity("abcd", func() uint64 { return remaining })
+	c.TrackRemainingCapacity("ef01", func() uint64 { return 5 })
+
+	write := func() string {
+		var buf bytes.Buffer
+		NewPrometheusExporter(c, "quantum_vpn").WriteMetrics(&buf)
+		return buf.String()
+	}
+
+	// The gauge is read when metrics are written
+	remaining = 999
+	output := write()
+	expected := []string{
+		"# TYPE quantum_vpn_aead_remaining_capacity gauge",
+		`quantum_vpn_aead_remaining_capacity{instance="test",session="abcd"} 999`,
+		`quantum_vpn_aead_remaining_capacity{instance="test",session="ef01"} 5`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("expected %q in output", line)
+		}
+	}
+
+	c.UntrackRemainingCapacity("abcd")
+	if output := write(); strings.Contains(output, `session="abcd"`) {
+		t.Error("untracked session still exported")
+	}
+}
+
 func TestPrometheusExporterHandler(t *testing.T) {
 	c := NewCollector(nil)
 	c.SessionStarted()
@@ -107,6 +164,47 @@ func TestPrometheusExporterHistogram(t *testing.T) {
 	}
 }
 
+func TestPrometheusExporterExemplars(t *testing.T) {
+	c := NewCollector(nil)
+	tracer := NewSimpleTracer()
+	obs := NewTunnelObserver(TunnelObserverConfig{Collector: c, Tracer: tracer, Role: "initiator"})
+
+	_, done := obs.OnHandshakeStart(context.Background())
+	done(nil)
+	spans := tracer.Spans()
+	if len(spans) != 1 {
+		t.Fatalf("expected 1 handshake span, got %d", len(spans))
+	}
+
+	exp := NewPrometheusExporter(c, "test")
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text;version=1.0.0;q=0.5,text/plain;version=0.0.4;q=0.4")
+	w := httptest.NewRecorder()
+	exp.Handler().ServeHTTP(w, req)
+
+	if contentType := w.Result().Header.Get("Content-Type"); !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("expected OpenMetrics content type, got %s", contentType)
+	}
+	body := w.Body.String()
+	exemplar := `test_handshake_duration_milliseconds_bucket{le="10"} 1 # {trace_id="` + spans[0].TraceID + `"} 0 `
+	if !strings.Contains(body, exemplar) {
+		t.Errorf("expected exemplar line %q in output:\n%s", exemplar, body)
+	}
+	if !strings.Contains(body, "# TYPE test_sessions counter\n") {
+		t.Error("expected counter family without _total suffix")
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Error("expected OpenMetrics output to end with # EOF")
+	}
+
+	// The Prometheus text format has no exemplars
+	var buf bytes.Buffer
+	exp.WriteMetrics(&buf)
+	if strings.Contains(buf.String(), "trace_id") || strings.Contains(buf.String(), "# EOF") {
+		t.Error("Prometheus text format should not contain OpenMetrics syntax")
+	}
+}
+
 func TestPrometheusExporterLabelEscaping(t *testing.T) {
 	c := NewCollector(Labels{
 		"path":    "/api/v1",
@@ -141,7 +239,11 @@ func TestPrometheusExporterAllMetricTypes(t *testing.T) {
 	c.RecordBytesReceived(200)
 	c.RecordPacketSent()
 	c.RecordPacketReceived()
+	c.RecordWireBytesSent(141)
+	c.RecordWireBytesReceived(241)
 	c.RecordReplayBlocked()
+	c.RecordReplayRejected(tunnel.ReplayRejectedOld)
+	c.RecordReplayRejected(tunnel.ReplayRejectedDuplicate)
 	c.RecordAuthFailure()
 	c.RecordRekeyInitiated()
 	c.RecordRekeyCompleted()
@@ -171,11 +273,16 @@ func TestPrometheusExporterAllMetricTypes(t *testing.T) {
 		"bytes_received_total",
 		"packets_sent_total",
 		"packets_received_total",
+		"wire_bytes_sent_total",
+		"wire_bytes_received_total",
 		"replay_attacks_blocked_total",
+		"replay_rejected_old_total",
+		"replay_rejected_duplicate_total",
 		"auth_failures_total",
 		"rekeys_initiated_total",
 		"rekeys_completed_total",
 		"rekeys_failed_total",
+		"unexpected_rekeys_total",
 		"encrypt_errors_total",
 		"decrypt_errors_total",
 		"protocol_errors_total",
@@ -185,6 +292,8 @@ func TestPrometheusExporterAllMetricTypes(t *testing.T) {
 		"handshake_duration_milliseconds",
 		"encrypt_duration_microseconds",
 		"decrypt_duration_microseconds",
+		"network_write_duration_microseconds",
+		"network_read_duration_microseconds",
 	}
 
 	for _, metric := range expectedMetrics {
@@ -0,0 +1,340 @@
+package metrics
+
+import (
+	"context"
+	"encoding/hex"
+	"sync/atomic"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+// SessionObserverOption configures NewSessionObserverFactory.
+type SessionObserverOption func(*sessionObserverConfig)
+
+type sessionObserverConfig struct {
+	tracer     Tracer
+	logger     *Logger
+	labelKey   string
+	onRegister func(sessionID string, c *Collector)
+	capacity   bool
+}
+
+// WithSessionObserverTracer sets the tracer for handshake, encrypt, decrypt
+// and rekey spans. The default is the global tracer.
+func WithSessionObserverTracer(tracer Tracer) SessionObserverOption {
+	return func(cfg *sessionObserverConfig) {
+		cfg.tracer = tracer
+	}
+}
+
+// WithSessionObserverLogger sets the logger for session events. The default
+// is the global logger.
+func WithSessionObserverLogger(logger *Logger) SessionObserverOption {
+	return func(cfg *sessionObserverConfig) {
+		cfg.logger = logger
+	}
+}
+
+// WithSessionLabel additionally records each session's metrics in its own
+// Collector, labelled with the collector's labels plus key set to the
+// session ID. Session IDs are only final once the handshake completes, so
+// the per-session collector is created then and passed to register, which
+// may export it; it stops changing once its sessions_active returns to 0.
+func WithSessionLabel(key string, register func(sessionID string, c *Collector)) SessionObserverOption {
+	return func(cfg *sessionObserverConfig) {
+		cfg.labelKey = key
+		cfg.onRegister = register
+	}
+}
+
+// WithRemainingCapacity reports each established session's remaining AEAD
+// capacity to the collector until the session ends, exported as the
+// aead_remaining_capacity gauge labelled with the session ID. Every active
+// session adds a series, so enable it only where the number of concurrent
+// sessions is bounded.
+func WithRemainingCapacity() SessionObserverOption {
+	return func(cfg *sessionObserverConfig) {
+		cfg.capacity = true
+	}
+}
+
+// NewSessionObserverFactory returns a tunnel.ObserverFactory that gives each
+// session its own observer feeding c, for use as
+// TransportConfig.ObserverFactory. A nil collector uses the global collector.
+//
+// Session start and end, handshake, encrypt and decrypt latency, traffic,
+// and rekey events are all recorded. When an established
+// session ends, its duration, completed rekeys and bytes sent and received
+// are recorded in the session histograms.
+func NewSessionObserverFactory(c *Collector, opts ...SessionObserverOption) tunnel.ObserverFactory {
+	if c == nil {
+		c = Global()
+	}
+	var cfg sessionObserverConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	return func(session *tunnel.Session) tunnel.Observer {
+		return &sessionObserver{
+			TunnelObserver: NewTunnelObserver(TunnelObserverConfig{
+				Collector: c,
+				Tracer:    cfg.tracer,
+				Logger:    cfg.logger,
+				SessionID: session.ID,
+				Role:      session.Role.String(),
+			}),
+			session: session,
+			parent:  c,
+			cfg:     cfg,
+		}
+	}
+}
+
+// sessionObserver is a TunnelObserver that also feeds an optional
+// per-session collector.
+type sessionObserver struct {
+	*TunnelObserver
+
+	session *tunnel.Session
+	parent  *Collector
+	cfg     sessionObserverConfig
+
+	// child is the per-session collector, set once the handshake completes
+	child atomic.Pointer[Collector]
+
+	// tracked is set once the parent reports the session's AEAD capacity
+	tracked atomic.Bool
+
+	// establishedAt is the handshake completion time in Unix nanoseconds,
+	// cleared once the session's summary is recorded
+	establishedAt atomic.Int64
+	rekeys        atomic.Int64
+}
+
+var (
+	_ tunnel.Observer                = (*sessionObserver)(nil)
+	_ tunnel.ReplayRejectionObserver = (*sessionObserver)(nil)
+	_ tunnel.UnexpectedRekeyObserver = (*sessionObserver)(nil)
+	_ tunnel.WireObserver            = (*sessionObserver)(nil)
+	_ tunnel.NetworkObserver         = (*sessionObserver)(nil)
+	_ tunnel.SendRateLimitObserver   = (*sessionObserver)(nil)
+)
+
+// OnHandshakeStart traces the handshake and, on success, tracks the
+// session's AEAD capacity if enabled and registers the per-session
+// collector under the negotiated session ID.
+func (o *sessionObserver) OnHandshakeStart(ctx context.Context) (context.Context, func(error)) {
+	ctx, done := o.TunnelObserver.OnHandshakeStart(ctx)
+	return ctx, func(err error) {
+		done(err)
+		if err != nil {
+			return
+		}
+		if o.cfg.capacity && !o.tracked.Swap(true) {
+			o.parent.TrackRemainingCapacity(o.sessionID(), o.session.RemainingCapacity)
+		}
+		o.establishedAt.CompareAndSwap(0, time.Now().UnixNano())
+		if o.cfg.labelKey != "" {
+			o.registerChild()
+		}
+	}
+}
+
+// sessionID returns the session ID metrics are labelled with.
+func (o *sessionObserver) sessionID() string {
+	return hex.EncodeToString(o.session.ID[:min(8, len(o.session.ID))])
+}
+
+func (o *sessionObserver) registerChild() {
+	if o.child.Load() != nil {
+		return
+	}
+
+	sessionID := o.sessionID()
+	labels := make(Labels, len(o.parent.labels)+1)
+	for k, v := range o.parent.labels {
+		labels[k] = v
+	}
+	labels[o.cfg.labelKey] = sessionID
+
+	child := NewCollector(labels)
+	child.SessionStarted()
+	o.child.Store(child)
+	if o.cfg.onRegister != nil {
+		o.cfg.onRegister(sessionID, child)
+	}
+}
+
+// OnSessionEnd records the end of the session and, if it was established,
+// its duration, rekeys and data carried.
+func (o *sessionObserver) OnSessionEnd() {
+	if o.tracked.Load() {
+		o.parent.UntrackRemainingCapacity(o.sessionID())
+	}
+	o.TunnelObserver.OnSessionEnd()
+
+	child := o.child.Load()
+	if established := o.establishedAt.Swap(0); established != 0 {
+		duration := time.Since(time.Unix(0, established))
+		rekeys := o.rekeys.Load()
+		bytes := uint64(o.session.BytesSent.Load() + o.session.BytesReceived.Load())
+		o.parent.RecordSessionSummary(duration, rekeys, bytes)
+		if child != nil {
+			child.RecordSessionSummary(duration, rekeys, bytes)
+		}
+	}
+	if child != nil {
+		child.SessionEnded()
+	}
+}
+
+// OnEncrypt records encryption metrics.
+func (o *sessionObserver) OnEncrypt(ctx context.Context, plaintextLen int) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, done := o.TunnelObserver.OnEncrypt(ctx, plaintextLen)
+	return ctx, func(err error) {
+		done(err)
+		if child := o.child.Load(); child != nil {
+			child.RecordEncryptLatency(time.Since(start))
+			if err != nil {
+				child.RecordEncryptError()
+			} else {
+				child.RecordBytesSent(plaintextLen)
+				child.RecordPacketSent()
+			}
+		}
+	}
+}
+
+// OnDecrypt records decryption metrics.
+func (o *sessionObserver) OnDecrypt(ctx context.Context, ciphertextLen int) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, done := o.TunnelObserver.OnDecrypt(ctx, ciphertextLen)
+	return ctx, func(err error) {
+		done(err)
+		if child := o.child.Load(); child != nil {
+			child.RecordDecryptLatency(time.Since(start))
+			if err != nil {
+				child.RecordDecryptError()
+			} else {
+				child.RecordBytesReceived(ciphertextLen)
+				child.RecordPacketReceived()
+			}
+		}
+	}
+}
+
+// OnNetworkWrite records connection write latency.
+func (o *sessionObserver) OnNetworkWrite(ctx context.Context, n int) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, done := o.TunnelObserver.OnNetworkWrite(ctx, n)
+	return ctx, func(err error) {
+		done(err)
+		if child := o.child.Load(); child != nil {
+			child.RecordNetworkWriteLatency(time.Since(start))
+		}
+	}
+}
+
+// OnNetworkRead records connection read latency.
+func (o *sessionObserver) OnNetworkRead(ctx context.Context) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, done := o.TunnelObserver.OnNetworkRead(ctx)
+	return ctx, func(err error) {
+		done(err)
+		if child := o.child.Load(); child != nil {
+			child.RecordNetworkReadLatency(time.Since(start))
+		}
+	}
+}
+
+// OnReplayDetected records a blocked replay attack.
+func (o *sessionObserver) OnReplayDetected() {
+	o.TunnelObserver.OnReplayDetected()
+	if child := o.child.Load(); child != nil {
+		child.RecordReplayBlocked()
+	}
+}
+
+// OnReplayRejected records why the replay window rejected a packet.
+func (o *sessionObserver) OnReplayRejected(reason string) {
+	o.TunnelObserver.OnReplayRejected(reason)
+	if child := o.child.Load(); child != nil {
+		child.RecordReplayRejected(reason)
+	}
+}
+
+// OnWireBytesSent records bytes written to the connection.
+func (o *sessionObserver) OnWireBytesSent(n int) {
+	o.TunnelObserver.OnWireBytesSent(n)
+	if child := o.child.Load(); child != nil {
+		child.RecordWireBytesSent(n)
+	}
+}
+
+// OnWireBytesReceived records bytes read from the connection.
+func (o *sessionObserver) OnWireBytesReceived(n int) {
+	o.TunnelObserver.OnWireBytesReceived(n)
+	if child := o.child.Load(); child != nil {
+		child.RecordWireBytesReceived(n)
+	}
+}
+
+// OnAuthFailure records an authentication failure.
+func (o *sessionObserver) OnAuthFailure() {
+	o.TunnelObserver.OnAuthFailure()
+	if child := o.child.Load(); child != nil {
+		child.RecordAuthFailure()
+	}
+}
+
+// OnRekeyStart records the start of a rekey operation.
+func (o *sessionObserver) OnRekeyStart(ctx context.Context) (context.Context, func(error)) {
+	if child := o.child.Load(); child != nil {
+		child.RecordRekeyInitiated()
+	}
+	return o.TunnelObserver.OnRekeyStart(ctx)
+}
+
+// OnRekeyCompleted records a rekey whose new keys have been activated.
+func (o *sessionObserver) OnRekeyCompleted() {
+	o.rekeys.Add(1)
+	o.TunnelObserver.OnRekeyCompleted()
+	if child := o.child.Load(); child != nil {
+		child.RecordRekeyCompleted()
+	}
+}
+
+// OnRekeyFailed records a failed rekey.
+func (o *sessionObserver) OnRekeyFailed(err error) {
+	o.TunnelObserver.OnRekeyFailed(err)
+	if child := o.child.Load(); child != nil {
+		child.RecordRekeyFailed()
+	}
+}
+
+// OnUnexpectedRekey records a rekey message the rekey state did not expect.
+func (o *sessionObserver) OnUnexpectedRekey(stale bool) {
+	o.TunnelObserver.OnUnexpectedRekey(stale)
+	if child := o.child.Load(); child != nil {
+		child.RecordUnexpectedRekey()
+	}
+}
+
+// OnSendRateLimited records data delayed by the send rate limit.
+func (o *sessionObserver) OnSendRateLimited(n int, delay time.Duration) {
+	o.TunnelObserver.OnSendRateLimited(n, delay)
+	if child := o.child.Load(); child != nil {
+		child.RecordSendRateLimited(n, delay)
+	}
+}
+
+// OnProtocolError records a protocol error.
+func (o *sessionObserver) OnProtocolError(err error) {
+	o.TunnelObserver.OnProtocolError(err)
+	if child := o.child.Load(); child != nil {
+		child.RecordProtocolError()
+	}
+}
@@ -0,0 +1,286 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+// waitForActive polls until c reports want active sessions.
+func waitForActive(t *testing.T, c *Collector, want int64) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for c.Snapshot().SessionsActive != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d active sessions, got %d", want, c.Snapshot().SessionsActive)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSessionObserverFactoryListener(t *testing.T) {
+	const numTunnels = 4
+
+	c := NewCollector(Labels{"instance": "test"})
+	var (
+		mu       sync.Mutex
+		sessions = make(map[string]*Collector)
+	)
+	register := func(sessionID string, sc *Collector) {
+		mu.Lock()
+		defer mu.Unlock()
+		sessions[sessionID] = sc
+	}
+	quiet := NewLogger(WithOutput(&bytes.Buffer{}))
+
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	config := tunnel.DefaultTransportConfig()
+	config.ObserverFactory = NewSessionObserverFactory(c,
+		WithSessionObserverLogger(quiet),
+		WithSessionLabel("session", register),
+		WithRemainingCapacity())
+	listener.SetConfig(config)
+
+	servers := make(chan *tunnel.Tunnel, numTunnels)
+	go func() {
+		for range numTunnels {
+			server, err := listener.Accept()
+			if err != nil {
+				close(servers)
+				return
+			}
+			go func() {
+				if data, err := server.Receive(); err == nil {
+					_ = server.Send(data)
+				}
+				servers <- server
+			}()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	clients := make([]*tunnel.Tunnel, numTunnels)
+	for i := range clients {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			client, err := tunnel.Dial("tcp", listener.Addr().String())
+			if err != nil {
+				t.Errorf("Dial failed: %v", err)
+				return
+			}
+			clients[i] = client
+			if err := client.Send([]byte("ping")); err != nil {
+				t.Errorf("Send failed: %v", err)
+				return
+			}
+			if _, err := client.Receive(); err != nil {
+				t.Errorf("Receive failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	accepted := make([]*tunnel.Tunnel, 0, numTunnels)
+	for range numTunnels {
+		server, ok := <-servers
+		if !ok {
+			t.Fatal("Accept failed")
+		}
+		accepted = append(accepted, server)
+	}
+	waitForActive(t, c, numTunnels)
+
+	snap := c.Snapshot()
+	if snap.SessionsTotal != numTunnels {
+		t.Errorf("expected %d total sessions, got %d", numTunnels, snap.SessionsTotal)
+	}
+	if snap.PacketsRecv < numTunnels || snap.PacketsSent < numTunnels {
+		t.Errorf("expected at least %d packets each way, got %d sent, %d received",
+			numTunnels, snap.PacketsSent, snap.PacketsRecv)
+	}
+	if snap.HandshakeLatency.Count != numTunnels {
+		t.Errorf("expected %d handshake latencies, got %d", numTunnels, snap.HandshakeLatency.Count)
+	}
+
+	mu.Lock()
+	if len(sessions) != numTunnels {
+		t.Errorf("expected %d per-session collectors, got %d", numTunnels, len(sessions))
+	}
+	for id, sc := range sessions {
+		s := sc.Snapshot()
+		if s.Labels["session"] != id || s.Labels["instance"] != "test" {
+			t.Errorf("unexpected per-session labels: %v", s.Labels)
+		}
+		if s.SessionsActive != 1 || s.PacketsRecv != 1 || s.PacketsSent != 1 {
+			t.Errorf("session %s: expected 1 active session and 1 packet each way, got %+v", id, s)
+		}
+	}
+	mu.Unlock()
+
+	capacity := c.Snapshot().AEADRemainingCapacity
+	if len(capacity) != numTunnels {
+		t.Errorf("expected AEAD capacity for %d sessions, got %d", numTunnels, len(capacity))
+	}
+	for id, remaining := range capacity {
+		if remaining == 0 {
+			t.Errorf("session %s: expected remaining AEAD capacity", id)
+		}
+	}
+
+	// Closing half the tunnels ends their sessions
+	for _, server := range accepted[:numTunnels/2] {
+		_ = server.Close()
+	}
+	waitForActive(t, c, numTunnels-numTunnels/2)
+
+	for _, server := range accepted[numTunnels/2:] {
+		_ = server.Close()
+	}
+	waitForActive(t, c, 0)
+	if capacity := c.Snapshot().AEADRemainingCapacity; len(capacity) != 0 {
+		t.Errorf("expected no tracked sessions after close, got %d", len(capacity))
+	}
+
+	mu.Lock()
+	for id, sc := range sessions {
+		if active := sc.Snapshot().SessionsActive; active != 0 {
+			t.Errorf("session %s: expected 0 active sessions after close, got %d", id, active)
+		}
+	}
+	mu.Unlock()
+
+	for _, client := range clients {
+		_ = client.Close()
+	}
+}
+
+func TestSessionObserverSessionHistograms(t *testing.T) {
+	c := NewCollector(nil)
+	factory := NewSessionObserverFactory(c, WithSessionObserverLogger(NewLogger(WithOutput(&bytes.Buffer{}))))
+
+	sessions := []struct {
+		lifetime time.Duration
+		rekeys   int
+		sent     int64
+		received int64
+	}{
+		{0, 0, 0, 0},
+		{10 * time.Millisecond, 1, 2 << 10, 1 << 10},
+		{20 * time.Millisecond, 3, 1 << 20, 1 << 19},
+		{30 * time.Millisecond, 12, 1 << 25, 1 << 24},
+	}
+	var wantBytes float64
+	start := time.Now()
+	for _, tt := range sessions {
+		session, err := tunnel.NewSession(tunnel.RoleInitiator)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+		observer := factory(session)
+		_, done := observer.OnHandshakeStart(t.Context())
+		done(nil)
+		for range tt.rekeys {
+			observer.OnRekeyCompleted()
+		}
+		session.BytesSent.Add(tt.sent)
+		session.BytesReceived.Add(tt.received)
+		wantBytes += float64(tt.sent + tt.received)
+		time.Sleep(tt.lifetime)
+		observer.OnSessionEnd()
+		// A second end is not recorded twice
+		observer.OnSessionEnd()
+	}
+	elapsed := time.Since(start)
+
+	// A session whose handshake failed is not recorded
+	session, _ := tunnel.NewSession(tunnel.RoleResponder)
+	observer := factory(session)
+	_, done := observer.OnHandshakeStart(t.Context())
+	done(errors.New("handshake failed"))
+	observer.OnSessionEnd()
+
+	snap := c.Snapshot()
+	n := uint64(len(sessions))
+	if snap.SessionDuration.Count != n || snap.RekeysPerSession.Count != n || snap.BytesPerSession.Count != n {
+		t.Fatalf("expected %d observations each, got duration %d, rekeys %d, bytes %d", n,
+			snap.SessionDuration.Count, snap.RekeysPerSession.Count, snap.BytesPerSession.Count)
+	}
+	if sum := snap.SessionDuration.Sum; sum < 0.06 || sum > elapsed.Seconds() {
+		t.Errorf("session duration sum = %.3fs, want between 0.06s and %.3fs", sum, elapsed.Seconds())
+	}
+	if snap.SessionDuration.Max < 0.03 {
+		t.Errorf("longest session = %.3fs, want at least 0.03s", snap.SessionDuration.Max)
+	}
+	if snap.RekeysPerSession.Sum != 16 || snap.RekeysPerSession.Min != 0 || snap.RekeysPerSession.Max != 12 {
+		t.Errorf("rekeys per session: sum %v, min %v, max %v; want 16, 0, 12",
+			snap.RekeysPerSession.Sum, snap.RekeysPerSession.Min, snap.RekeysPerSession.Max)
+	}
+	if snap.BytesPerSession.Sum != wantBytes {
+		t.Errorf("bytes per session sum = %v, want %v", snap.BytesPerSession.Sum, wantBytes)
+	}
+
+	// Sessions spread across the rekey buckets: 0, 1, 3 and 12 rekeys
+	wantRekeys := map[float64]uint64{0: 1, 1: 2, 2: 2, 5: 3, 10: 3, 25: 4}
+	for _, b := range snap.RekeysPerSession.Buckets {
+		if want, ok := wantRekeys[b.UpperBound]; ok && b.Count != want {
+			t.Errorf("rekeys_per_session bucket le=%v = %d, want %d", b.UpperBound, b.Count, want)
+		}
+	}
+
+	var buf bytes.Buffer
+	NewPrometheusExporter(c, "quantum").WriteMetrics(&buf)
+	for _, name := range []string{
+		"quantum_session_duration_seconds_count 4",
+		"quantum_rekeys_per_session_sum 16",
+		"quantum_bytes_per_session_count 4",
+	} {
+		if !bytes.Contains(buf.Bytes(), []byte(name)) {
+			t.Errorf("Prometheus output missing %q", name)
+		}
+	}
+}
+
+func TestSessionObserverRemainingCapacityOptIn(t *testing.T) {
+	quiet := WithSessionObserverLogger(NewLogger(WithOutput(&bytes.Buffer{})))
+	establish := func(factory tunnel.ObserverFactory) tunnel.Observer {
+		session, err := tunnel.NewSession(tunnel.RoleInitiator)
+		if err != nil {
+			t.Fatalf("NewSession failed: %v", err)
+		}
+		observer := factory(session)
+		_, done := observer.OnHandshakeStart(t.Context())
+		done(nil)
+		return observer
+	}
+
+	// Per-session gauges are not exported by default
+	c := NewCollector(nil)
+	observer := establish(NewSessionObserverFactory(c, quiet))
+	if capacity := c.Snapshot().AEADRemainingCapacity; len(capacity) != 0 {
+		t.Errorf("expected no tracked sessions by default, got %d", len(capacity))
+	}
+	observer.OnSessionEnd()
+
+	c = NewCollector(nil)
+	observer = establish(NewSessionObserverFactory(c, quiet, WithRemainingCapacity()))
+	if capacity := c.Snapshot().AEADRemainingCapacity; len(capacity) != 1 {
+		t.Errorf("expected 1 tracked session, got %d", len(capacity))
+	}
+	observer.OnSessionEnd()
+	if capacity := c.Snapshot().AEADRemainingCapacity; len(capacity) != 0 {
+		t.Errorf("expected no tracked sessions after the session ended, got %d", len(capacity))
+	}
+}
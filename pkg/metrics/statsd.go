@@ -0,0 +1,350 @@
+package metrics
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultStatsDPacketSize keeps datagrams under a typical Ethernet MTU.
+const defaultStatsDPacketSize = 1432
+
+// StatsDExporter pushes collector snapshots to a StatsD or DogStatsD server
+// over UDP.
+//
+// Counters are sent as deltas since the previous flush, gauges as current
+// values, and latency histograms as timing samples in milliseconds. Each
+// histogram is reported as the mean of the observations since the previous
+// flush with a sample rate of 1/count, so the server reconstructs the exact
+// count and sum.
+//
+// Handshake failures are taken from the collector's per-reason counts only;
+// the exporter does not hook the tunnel observer, so each failure is sent
+// once.
+type StatsDExporter struct {
+	collector  *Collector
+	namespace  string
+	interval   time.Duration
+	packetSize int
+	tags       bool
+
+	conn net.Conn
+
+	mu   sync.Mutex
+	prev Snapshot
+
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// StatsDOption configures a StatsD exporter.
+type StatsDOption func(*StatsDExporter)
+
+// WithStatsDCollector sets the collector to export. Default: Global().
+func WithStatsDCollector(c *Collector) StatsDOption {
+	return func(e *StatsDExporter) {
+		e.collector = c
+	}
+}
+
+// WithStatsDInterval starts a background goroutine that flushes every
+// interval until Close is called. Default: no background flushing.
+func WithStatsDInterval(interval time.Duration) StatsDOption {
+	return func(e *StatsDExporter) {
+		e.interval = interval
+	}
+}
+
+// WithStatsDPacketSize sets the maximum datagram size. Default: 1432 bytes.
+func WithStatsDPacketSize(size int) StatsDOption {
+	return func(e *StatsDExporter) {
+		e.packetSize = size
+	}
+}
+
+// WithoutStatsDTags disables DogStatsD tags for plain StatsD servers.
+func WithoutStatsDTags() StatsDOption {
+	return func(e *StatsDExporter) {
+		e.tags = false
+	}
+}
+
+// NewStatsDExporter creates an exporter sending to the StatsD server at addr.
+// The namespace is prepended to all metric names (e.g., "quantum_vpn").
+func NewStatsDExporter(addr, namespace string, opts ...StatsDOption) (*StatsDExporter, error) {
+	e := &StatsDExporter{
+		namespace:  namespace,
+		packetSize: defaultStatsDPacketSize,
+		tags:       true,
+	}
+	for _, opt := range opts {
+		opt(e)
+	}
+	if e.collector == nil {
+		e.collector = Global()
+	}
+	if e.packetSize <= 0 {
+		e.packetSize = defaultStatsDPacketSize
+	}
+
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	e.conn = conn
+
+	if e.interval > 0 {
+		e.stop = make(chan struct{})
+		e.done = make(chan struct{})
+		go e.run()
+	}
+	return e, nil
+}
+
+// run flushes on every tick until Close is called.
+func (e *StatsDExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = e.Flush() // Best effort; the next flush carries the deltas
+		case <-e.stop:
+			return
+		}
+	}
+}
+
+// Flush sends the current snapshot to the server.
+//
+// Counter deltas are only advanced once every datagram has been written, so
+// a failed flush is reported again on the next one.
+func (e *StatsDExporter) Flush() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	snap := e.collector.Snapshot()
+	lines := e.formatSnapshot(snap, e.prev)
+
+	for _, packet := range e.packets(lines) {
+		if _, err := e.conn.Write(packet); err != nil {
+			return err
+		}
+	}
+	e.prev = snap
+	return nil
+}
+
+// Close stops background flushing, sends a final flush and closes the
+// connection.
+func (e *StatsDExporter) Close() error {
+	var err error
+	e.once.Do(func() {
+		if e.stop != nil {
+			close(e.stop)
+			<-e.done
+		}
+		err = e.Flush()
+		if cerr := e.conn.Close(); err == nil {
+			err = cerr
+		}
+	})
+	return err
+}
+
+// formatSnapshot renders snap as StatsD lines, with counters relative to prev.
+func (e *StatsDExporter) formatSnapshot(snap, prev Snapshot) []string {
+	tags := ""
+	if e.tags {
+		tags = formatStatsDTags(snap.Labels)
+	}
+
+	lines := make([]string, 0, 24)
+	counter := func(name string, cur, last int64) {
+		lines = append(lines, e.line(name, strconv.FormatInt(cur-last, 10), "c", "", tags))
+	}
+	gauge := func(name string, value float64) {
+		lines = append(lines, e.line(name, formatStatsDFloat(value), "g", "", tags))
+	}
+
+	// --- Session Metrics ---
+	gauge("sessions_active", float64(snap.SessionsActive))
+	counter("sessions_total", snap.SessionsTotal, prev.SessionsTotal)
+	counter("sessions_failed", snap.SessionsFailed, prev.SessionsFailed)
+	lines = e.appendHandshakeFailures(lines, snap, prev)
+
+	// --- Traffic Metrics ---
+	counter("bytes_sent", snap.BytesSent, prev.BytesSent)
+	counter("bytes_received", snap.BytesReceived, prev.BytesReceived)
+	counter("packets_sent", snap.PacketsSent, prev.PacketsSent)
+	counter("packets_received", snap.PacketsRecv, prev.PacketsRecv)
+	counter("wire_bytes_sent", snap.WireBytesSent, prev.WireBytesSent)
+	counter("wire_bytes_received", snap.WireBytesReceived, prev.WireBytesReceived)
+
+	// --- Security Metrics ---
+	counter("replay_attacks_blocked", snap.ReplayAttacksBlocked, prev.ReplayAttacksBlocked)
+	counter("replay_rejected_old", snap.ReplayRejectedOld, prev.ReplayRejectedOld)
+	counter("replay_rejected_duplicate", snap.ReplayRejectedDuplicate, prev.ReplayRejectedDuplicate)
+	counter("auth_failures", snap.AuthFailures, prev.AuthFailures)
+	counter("rekeys_initiated", snap.RekeysInitiated, prev.RekeysInitiated)
+	counter("rekeys_completed", snap.RekeysCompleted, prev.RekeysCompleted)
+	counter("rekeys_failed", snap.RekeysFailed, prev.RekeysFailed)
+	counter("unexpected_rekeys", snap.UnexpectedRekeys, prev.UnexpectedRekeys)
+
+	// --- Error Metrics ---
+	counter("encrypt_errors", snap.EncryptErrors, prev.EncryptErrors)
+	counter("decrypt_errors", snap.DecryptErrors, prev.DecryptErrors)
+	counter("protocol_errors", snap.ProtocolErrors, prev.ProtocolErrors)
+
+	// --- Rate Limit Metrics ---
+	counter("rate_limit_connections", snap.ConnectionRateLimits, prev.ConnectionRateLimits)
+	counter("rate_limit_handshakes", snap.HandshakeRateLimits, prev.HandshakeRateLimits)
+	counter("rate_limit_send_delayed_bytes", snap.SendRateLimitedBytes, prev.SendRateLimitedBytes)
+	counter("rate_limit_send_delay_ms", snap.SendRateLimitDelay.Milliseconds(), prev.SendRateLimitDelay.Milliseconds())
+
+	// --- Uptime ---
+	gauge("uptime_seconds", snap.Uptime.Seconds())
+
+	// --- Histograms ---
+	// Encrypt, decrypt and network latencies are recorded in microseconds
+	lines = e.appendTiming(lines, "handshake_duration", snap.HandshakeLatency, prev.HandshakeLatency, 1, tags)
+	lines = e.appendTiming(lines, "encrypt_duration", snap.EncryptLatency, prev.EncryptLatency, 1000, tags)
+	lines = e.appendTiming(lines, "decrypt_duration", snap.DecryptLatency, prev.DecryptLatency, 1000, tags)
+	lines = e.appendTiming(lines, "network_write_duration", snap.NetworkWriteLatency, prev.NetworkWriteLatency, 1000, tags)
+	lines = e.appendTiming(lines, "network_read_duration", snap.NetworkReadLatency, prev.NetworkReadLatency, 1000, tags)
+
+	return lines
+}
+
+// appendHandshakeFailures appends one handshake_failures counter per reason
+// recorded in the collector, tagged with the reason. Without tags the reason
+// is appended to the metric name instead.
+func (e *StatsDExporter) appendHandshakeFailures(lines []string, snap, prev Snapshot) []string {
+	reasons := make([]string, 0, len(snap.HandshakeFailures))
+	for reason := range snap.HandshakeFailures {
+		reasons = append(reasons, reason)
+	}
+	sort.Strings(reasons)
+
+	for _, reason := range reasons {
+		delta := strconv.FormatInt(snap.HandshakeFailures[reason]-prev.HandshakeFailures[reason], 10)
+		if !e.tags {
+			lines = append(lines, e.line("handshake_failures."+escapeStatsDName(reason), delta, "c", "", ""))
+			continue
+		}
+		reasonLabels := make(Labels, len(snap.Labels)+1)
+		for k, v := range snap.Labels {
+			reasonLabels[k] = v
+		}
+		reasonLabels["reason"] = reason
+		lines = append(lines, e.line("handshake_failures", delta, "c", "", formatStatsDTags(reasonLabels)))
+	}
+	return lines
+}
+
+// appendTiming appends a timing sample for the observations recorded between
+// prev and cur, scaled to milliseconds by dividing by unitsPerMs.
+func (e *StatsDExporter) appendTiming(lines []string, name string, cur, prev HistogramSummary, unitsPerMs float64, tags string) []string {
+	if cur.Count <= prev.Count {
+		return lines
+	}
+	count := cur.Count - prev.Count
+	mean := (cur.Sum - prev.Sum) / float64(count) / unitsPerMs
+
+	rate := ""
+	if count > 1 {
+		rate = "@" + formatStatsDFloat(1/float64(count))
+	}
+	return append(lines, e.line(name, formatStatsDFloat(mean), "ms", rate, tags))
+}
+
+// line formats a single StatsD line: ns.name:value|type[|@rate][|#tags].
+func (e *StatsDExporter) line(name, value, typ, rate, tags string) string {
+	var b strings.Builder
+	if e.namespace != "" {
+		b.WriteString(e.namespace)
+		b.WriteByte('.')
+	}
+	b.WriteString(name)
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(typ)
+	if rate != "" {
+		b.WriteByte('|')
+		b.WriteString(rate)
+	}
+	if tags != "" {
+		b.WriteString("|#")
+		b.WriteString(tags)
+	}
+	return b.String()
+}
+
+// packets groups lines into newline-separated datagrams of at most
+// packetSize bytes. A single oversized line is sent on its own.
+func (e *StatsDExporter) packets(lines []string) [][]byte {
+	var packets [][]byte
+	var cur []byte
+	for _, l := range lines {
+		if len(cur) > 0 && len(cur)+1+len(l) > e.packetSize {
+			packets = append(packets, cur)
+			cur = nil
+		}
+		if len(cur) > 0 {
+			cur = append(cur, '\n')
+		}
+		cur = append(cur, l...)
+	}
+	if len(cur) > 0 {
+		packets = append(packets, cur)
+	}
+	return packets
+}
+
+// formatStatsDTags converts Labels to DogStatsD tag format, sorted by key.
+func formatStatsDTags(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, escapeStatsDTag(k)+":"+escapeStatsDTag(labels[k]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// statsDTagReplacer replaces characters that delimit StatsD fields and tags.
+var statsDTagReplacer = strings.NewReplacer("|", "_", ",", "_", "#", "_", "\n", "_")
+
+// escapeStatsDTag makes a string safe for use in a DogStatsD tag.
+func escapeStatsDTag(s string) string {
+	return statsDTagReplacer.Replace(s)
+}
+
+// statsDNameReplacer replaces characters that delimit StatsD fields.
+var statsDNameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "#", "_", "\n", "_")
+
+// escapeStatsDName makes a string safe for use in a StatsD metric name.
+func escapeStatsDName(s string) string {
+	return statsDNameReplacer.Replace(s)
+}
+
+// formatStatsDFloat formats a value without exponent notation.
+func formatStatsDFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
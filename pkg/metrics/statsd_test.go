@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// listenStatsD starts a UDP listener standing in for a StatsD server.
+func listenStatsD(t *testing.T) *net.UDPConn {
+	t.Helper()
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("ListenUDP failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// readStatsDLines reads datagrams until the listener goes quiet and returns
+// the received lines keyed by metric name.
+func readStatsDLines(t *testing.T, conn *net.UDPConn) map[string]string {
+	t.Helper()
+
+	lines := make(map[string]string)
+	buf := make([]byte, 65536)
+	for {
+		_ = conn.SetReadDeadline(time.Now().Add(200 * time.Millisecond))
+		n, err := conn.Read(buf)
+		if err != nil {
+			break
+		}
+		for _, l := range strings.Split(string(buf[:n]), "\n") {
+			name, _, _ := strings.Cut(l, ":")
+			lines[name] = l
+		}
+	}
+	if len(lines) == 0 {
+		t.Fatal("no StatsD packets received")
+	}
+	return lines
+}
+
+func expectStatsDLine(t *testing.T, lines map[string]string, name, want string) {
+	t.Helper()
+	if got := lines[name]; got != want {
+		t.Errorf("%s: got %q, want %q", name, got, want)
+	}
+}
+
+func TestStatsDExporterFlush(t *testing.T) {
+	server := listenStatsD(t)
+
+	c := NewCollector(Labels{"region": "eu", "instance": "test"})
+	c.SessionStarted()
+	c.SessionStarted()
+	c.RecordBytesSent(1000)
+	c.RecordHandshakeLatency(100 * time.Millisecond)
+	c.RecordHandshakeLatency(300 * time.Millisecond)
+
+	exp, err := NewStatsDExporter(server.LocalAddr().String(), "quantum_vpn", WithStatsDCollector(c))
+	if err != nil {
+		t.Fatalf("NewStatsDExporter failed: %v", err)
+	}
+	defer exp.Close()
+
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines := readStatsDLines(t, server)
+
+	tags := "|#instance:test,region:eu"
+	expectStatsDLine(t, lines, "quantum_vpn.sessions_active", "quantum_vpn.sessions_active:2|g"+tags)
+	expectStatsDLine(t, lines, "quantum_vpn.sessions_total", "quantum_vpn.sessions_total:2|c"+tags)
+	expectStatsDLine(t, lines, "quantum_vpn.bytes_sent", "quantum_vpn.bytes_sent:1000|c"+tags)
+	expectStatsDLine(t, lines, "quantum_vpn.handshake_duration", "quantum_vpn.handshake_duration:200|ms|@0.5"+tags)
+	if _, ok := lines["quantum_vpn.encrypt_duration"]; ok {
+		t.Error("timing without observations should not be sent")
+	}
+
+	// The second flush carries only what happened since the first
+	c.SessionEnded()
+	c.RecordBytesSent(234)
+	c.RecordEncryptLatency(1500 * time.Microsecond)
+
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines = readStatsDLines(t, server)
+
+	expectStatsDLine(t, lines, "quantum_vpn.sessions_active", "quantum_vpn.sessions_active:1|g"+tags)
+	expectStatsDLine(t, lines, "quantum_vpn.sessions_total", "quantum_vpn.sessions_total:0|c"+tags)
+	expectStatsDLine(t, lines, "quantum_vpn.bytes_sent", "quantum_vpn.bytes_sent:234|c"+tags)
+	expectStatsDLine(t, lines, "quantum_vpn.encrypt_duration", "quantum_vpn.encrypt_duration:1.5|ms"+tags)
+	if _, ok := lines["quantum_vpn.handshake_duration"]; ok {
+		t.Error("handshake timing should not repeat without new observations")
+	}
+}
+
+func TestStatsDExporterHandshakeFailures(t *testing.T) {
+	server := listenStatsD(t)
+
+	c := NewCollector(Labels{"instance": "test"})
+	o := NewTunnelObserver(TunnelObserverConfig{Collector: c})
+	o.OnHandshakeFailed("auth_failed", errors.New("bad signature"))
+
+	exp, err := NewStatsDExporter(server.LocalAddr().String(), "vpn", WithStatsDCollector(c))
+	if err != nil {
+		t.Fatalf("NewStatsDExporter failed: %v", err)
+	}
+	defer exp.Close()
+
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines := readStatsDLines(t, server)
+
+	// One failed handshake is one count, sent once
+	expectStatsDLine(t, lines, "vpn.handshake_failures", "vpn.handshake_failures:1|c|#instance:test,reason:auth_failed")
+
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines = readStatsDLines(t, server)
+	expectStatsDLine(t, lines, "vpn.handshake_failures", "vpn.handshake_failures:0|c|#instance:test,reason:auth_failed")
+}
+
+func TestStatsDExporterWithoutTags(t *testing.T) {
+	server := listenStatsD(t)
+
+	c := NewCollector(Labels{"instance": "test"})
+	c.SessionStarted()
+	c.RecordHandshakeFailure("timeout")
+
+	exp, err := NewStatsDExporter(server.LocalAddr().String(), "vpn",
+		WithStatsDCollector(c), WithoutStatsDTags())
+	if err != nil {
+		t.Fatalf("NewStatsDExporter failed: %v", err)
+	}
+	defer exp.Close()
+
+	if err := exp.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	lines := readStatsDLines(t, server)
+	expectStatsDLine(t, lines, "vpn.sessions_active", "vpn.sessions_active:1|g")
+	expectStatsDLine(t, lines, "vpn.handshake_failures.timeout", "vpn.handshake_failures.timeout:1|c")
+}
+
+func TestStatsDExporterInterval(t *testing.T) {
+	server := listenStatsD(t)
+
+	c := NewCollector(nil)
+	c.RecordAuthFailure()
+
+	exp, err := NewStatsDExporter(server.LocalAddr().String(), "vpn",
+		WithStatsDCollector(c), WithStatsDInterval(20*time.Millisecond))
+	if err != nil {
+		t.Fatalf("NewStatsDExporter failed: %v", err)
+	}
+
+	_ = server.SetReadDeadline(time.Now().Add(2 * time.Second))
+	buf := make([]byte, 65536)
+	n, err := server.Read(buf)
+	if err != nil {
+		t.Fatalf("no background flush received: %v", err)
+	}
+	if !strings.Contains(string(buf[:n]), "vpn.auth_failures:1|c") {
+		t.Errorf("unexpected packet: %q", buf[:n])
+	}
+
+	if err := exp.Close(); err != nil {
+		t.Errorf("Close failed: %v", err)
+	}
+	if err := exp.Close(); err != nil {
+		t.Errorf("second Close failed: %v", err)
+	}
+}
+
+func TestStatsDExporterPacketSize(t *testing.T) {
+	e := &StatsDExporter{packetSize: 20}
+	packets := e.packets([]string{"a:1|c", "b:2|c", "c:3|c", "a_very_long_metric_name:1|g"})
+
+	if len(packets) != 2 {
+		t.Fatalf("expected 2 packets, got %d", len(packets))
+	}
+	if string(packets[0]) != "a:1|c\nb:2|c\nc:3|c" {
+		t.Errorf("unexpected first packet %q", packets[0])
+	}
+	if string(packets[1]) != "a_very_long_metric_name:1|g" {
+		t.Errorf("oversized line should be sent alone, got %q", packets[1])
+	}
+}
@@ -2,6 +2,9 @@ package metrics
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
 	"sync"
 	"time"
 )
@@ -14,6 +17,18 @@ type Tracer interface {
 	StartSpan(ctx context.Context, name string, opts ...SpanOption) (context.Context, SpanEnder)
 }
 
+// TracePropagator is optionally implemented by a Tracer to carry span
+// context across process boundaries as a W3C traceparent.
+type TracePropagator interface {
+	// TraceParent returns the W3C traceparent of the span in ctx, or "" if
+	// there is none.
+	TraceParent(ctx context.Context) string
+
+	// ContextWithTraceParent returns ctx carrying traceparent as the remote
+	// parent span. Invalid values leave ctx unchanged.
+	ContextWithTraceParent(ctx context.Context, traceparent string) context.Context
+}
+
 // SpanEnder is a function that ends a span.
 // Call with nil error for success, or pass an error to mark the span as failed.
 type SpanEnder func(err error)
@@ -106,8 +121,8 @@ func (t *SimpleTracer) StartSpan(ctx context.Context, name string, opts ...SpanO
 		StartTime:  time.Now(),
 		Kind:       cfg.kind,
 		Attributes: cfg.attributes,
-		TraceID:    generateID(),
-		SpanID:     generateID(),
+		TraceID:    generateID(traceIDSize),
+		SpanID:     generateID(spanIDSize),
 	}
 
 	// Check for parent span in context
@@ -129,6 +144,25 @@ func (t *SimpleTracer) StartSpan(ctx context.Context, name string, opts ...SpanO
 	}
 }
 
+// TraceParent returns the W3C traceparent of the span in ctx.
+func (t *SimpleTracer) TraceParent(ctx context.Context) string {
+	span := spanFromContext(ctx)
+	if span == nil {
+		return ""
+	}
+	return formatTraceParent(span.TraceID, span.SpanID)
+}
+
+// ContextWithTraceParent returns ctx with the remote parent span described
+// by traceparent, so spans started from it join the remote trace.
+func (t *SimpleTracer) ContextWithTraceParent(ctx context.Context, traceparent string) context.Context {
+	traceID, spanID, ok := parseTraceParent(traceparent)
+	if !ok {
+		return ctx
+	}
+	return contextWithSpan(ctx, &RecordedSpan{TraceID: traceID, SpanID: spanID})
+}
+
 // Spans returns all recorded spans.
 func (t *SimpleTracer) Spans() []RecordedSpan {
 	t.mu.Lock()
@@ -160,11 +194,57 @@ func spanFromContext(ctx context.Context) *RecordedSpan {
 	return nil
 }
 
-// generateID generates a simple ID for spans.
-// In production, use a proper trace ID generator.
-func generateID() string {
-	// Simple time-based ID for the simple tracer
-	return time.Now().Format("20060102150405.000000000")
+// W3C trace context ID sizes in bytes.
+const (
+	traceIDSize = 16
+	spanIDSize  = 8
+)
+
+// generateID generates a random hex ID of size bytes, in the W3C trace
+// context format.
+func generateID(size int) string {
+	id := make([]byte, size)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// formatTraceParent formats a sampled W3C traceparent.
+func formatTraceParent(traceID, spanID string) string {
+	return "00-" + traceID + "-" + spanID + "-01"
+}
+
+// parseTraceParent parses a version 00 W3C traceparent, rejecting all-zero
+// IDs as the specification requires.
+func parseTraceParent(traceparent string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[0] != "00" || len(parts[3]) != 2 {
+		return "", "", false
+	}
+	traceID, spanID = parts[1], parts[2]
+	if !validTraceID(traceID, traceIDSize) || !validTraceID(spanID, spanIDSize) {
+		return "", "", false
+	}
+	if _, err := hex.DecodeString(parts[3]); err != nil {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+// validTraceID reports whether id is a lowercase hex, non-zero ID of size bytes.
+func validTraceID(id string, size int) bool {
+	if len(id) != 2*size || strings.ToLower(id) != id {
+		return false
+	}
+	b, err := hex.DecodeString(id)
+	if err != nil {
+		return false
+	}
+	for _, c := range b {
+		if c != 0 {
+			return true
+		}
+	}
+	return false
 }
 
 // --- Global Tracer ---
@@ -201,6 +281,8 @@ const (
 	SpanHandshakeResponder = "quantum.handshake.responder"
 	SpanEncrypt            = "quantum.encrypt"
 	SpanDecrypt            = "quantum.decrypt"
+	SpanNetworkWrite       = "quantum.network.write"
+	SpanNetworkRead        = "quantum.network.read"
 	SpanSend               = "quantum.send"
 	SpanReceive            = "quantum.receive"
 	SpanRekey              = "quantum.rekey"
@@ -3,8 +3,11 @@ package metrics
 import (
 	"context"
 	"errors"
+	"io"
 	"testing"
 	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
 
 func TestNoOpTracer(t *testing.T) {
@@ -273,3 +276,108 @@ func TestSimpleTracerConcurrency(t *testing.T) {
 		t.Errorf("expected 1000 spans, got %d", len(spans))
 	}
 }
+
+func TestSimpleTracerTraceParent(t *testing.T) {
+	tracer := NewSimpleTracer()
+
+	if tp := tracer.TraceParent(context.Background()); tp != "" {
+		t.Errorf("expected no traceparent without a span, got %q", tp)
+	}
+
+	ctx, end := tracer.StartSpan(context.Background(), "local")
+	traceparent := tracer.TraceParent(ctx)
+	end(nil)
+
+	remote := tracer.ContextWithTraceParent(context.Background(), traceparent)
+	_, endChild := tracer.StartSpan(remote, "child")
+	endChild(nil)
+
+	spans := tracer.Spans()
+	if spans[1].TraceID != spans[0].TraceID || spans[1].ParentID != spans[0].SpanID {
+		t.Errorf("child not parented to %q: got %q", traceparent, spans[1].ParentID)
+	}
+
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-zz",
+	}
+	for _, tp := range invalid {
+		if ctx := tracer.ContextWithTraceParent(context.Background(), tp); spanFromContext(ctx) != nil {
+			t.Errorf("expected %q to be rejected", tp)
+		}
+	}
+}
+
+func TestHandshakeTracePropagation(t *testing.T) {
+	tracer := NewSimpleTracer()
+	logger := NewLogger(WithOutput(io.Discard))
+
+	config := tunnel.DefaultTransportConfig()
+	config.ObserverFactory = func(session *tunnel.Session) tunnel.Observer {
+		return NewTunnelObserver(TunnelObserverConfig{
+			Collector: NewCollector(nil),
+			Tracer:    tracer,
+			Logger:    logger,
+			SessionID: session.ID,
+			Role:      session.Role.String(),
+		})
+	}
+
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	listener.SetConfig(config)
+
+	accepted := make(chan *tunnel.Tunnel, 1)
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- server
+	}()
+
+	client, err := tunnel.DialWithConfig("tcp", listener.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	server, ok := <-accepted
+	if !ok {
+		t.Fatal("Accept failed")
+	}
+	defer func() { _ = server.Close() }()
+
+	var initiator, responder *RecordedSpan
+	for _, span := range tracer.Spans() {
+		switch span.Name {
+		case SpanHandshakeInitiator:
+			initiator = &span
+		case SpanHandshakeResponder:
+			responder = &span
+		}
+	}
+	if initiator == nil || responder == nil {
+		t.Fatalf("expected both handshake spans, got %+v", tracer.Spans())
+	}
+	if responder.TraceID != initiator.TraceID {
+		t.Errorf("responder trace ID %q, want the initiator's %q", responder.TraceID, initiator.TraceID)
+	}
+	if responder.ParentID != initiator.SpanID {
+		t.Errorf("responder parent %q, want the initiator span %q", responder.ParentID, initiator.SpanID)
+	}
+
+	// Application spans can be parented under the handshake span
+	if span := spanFromContext(server.Session().TraceContext()); span == nil || span.SpanID != responder.SpanID {
+		t.Error("expected the responder's TraceContext to carry its handshake span")
+	}
+}
@@ -86,7 +86,7 @@ func (o *TunnelObserver) OnHandshakeStart(ctx context.Context) (context.Context,
 
 	return ctx, func(err error) {
 		duration := time.Since(start)
-		o.collector.RecordHandshakeLatency(duration)
+		o.collector.RecordHandshakeLatencyWithTrace(duration, o.traceID(ctx))
 
 		if err != nil {
 			o.logger.Error("handshake failed", Fields{
@@ -103,6 +103,40 @@ func (o *TunnelObserver) OnHandshakeStart(ctx context.Context) (context.Context,
 	}
 }
 
+// TraceParent returns the W3C traceparent of the span in ctx, or "" if the
+// tracer does not support propagation.
+func (o *TunnelObserver) TraceParent(ctx context.Context) string {
+	if p, ok := o.tracer.(TracePropagator); ok {
+		return p.TraceParent(ctx)
+	}
+	return ""
+}
+
+// traceID returns the trace ID of the span in ctx, or "" if the tracer does
+// not support propagation.
+func (o *TunnelObserver) traceID(ctx context.Context) string {
+	traceID, _, ok := parseTraceParent(o.TraceParent(ctx))
+	if !ok {
+		return ""
+	}
+	return traceID
+}
+
+// ContextWithTraceParent returns ctx with the remote parent span described by
+// traceparent, or ctx unchanged if the tracer does not support propagation.
+func (o *TunnelObserver) ContextWithTraceParent(ctx context.Context, traceparent string) context.Context {
+	if p, ok := o.tracer.(TracePropagator); ok {
+		return p.ContextWithTraceParent(ctx, traceparent)
+	}
+	return ctx
+}
+
+// OnHandshakeFailed records a failed handshake by reason.
+func (o *TunnelObserver) OnHandshakeFailed(reason string, err error) {
+	o.collector.RecordHandshakeFailure(reason)
+	o.logger.Debug("handshake failure recorded", Fields{"reason": reason})
+}
+
 // OnEncrypt records encryption metrics.
 func (o *TunnelObserver) OnEncrypt(ctx context.Context, plaintextLen int) (context.Context, func(error)) {
 	start := time.Now()
@@ -145,12 +179,49 @@ func (o *TunnelObserver) OnDecrypt(ctx context.Context, ciphertextLen int) (cont
 	}
 }
 
+// OnNetworkWrite records connection write latency.
+func (o *TunnelObserver) OnNetworkWrite(ctx context.Context, n int) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, endSpan := o.tracer.StartSpan(ctx, SpanNetworkWrite)
+
+	return ctx, func(err error) {
+		o.collector.RecordNetworkWriteLatency(time.Since(start))
+		endSpan(err)
+	}
+}
+
+// OnNetworkRead records connection read latency.
+func (o *TunnelObserver) OnNetworkRead(ctx context.Context) (context.Context, func(error)) {
+	start := time.Now()
+	ctx, endSpan := o.tracer.StartSpan(ctx, SpanNetworkRead)
+
+	return ctx, func(err error) {
+		o.collector.RecordNetworkReadLatency(time.Since(start))
+		endSpan(err)
+	}
+}
+
 // OnReplayDetected records a blocked replay attack.
 func (o *TunnelObserver) OnReplayDetected() {
 	o.collector.RecordReplayBlocked()
 	o.logger.Warn("replay attack blocked")
 }
 
+// OnReplayRejected records why the replay window rejected a packet.
+func (o *TunnelObserver) OnReplayRejected(reason string) {
+	o.collector.RecordReplayRejected(reason)
+}
+
+// OnWireBytesSent records bytes written to the connection.
+func (o *TunnelObserver) OnWireBytesSent(n int) {
+	o.collector.RecordWireBytesSent(n)
+}
+
+// OnWireBytesReceived records bytes read from the connection.
+func (o *TunnelObserver) OnWireBytesReceived(n int) {
+	o.collector.RecordWireBytesReceived(n)
+}
+
 // OnAuthFailure records an authentication failure.
 func (o *TunnelObserver) OnAuthFailure() {
 	o.collector.RecordAuthFailure()
@@ -165,17 +236,34 @@ func (o *TunnelObserver) OnRekeyStart(ctx context.Context) (context.Context, fun
 	o.logger.Debug("rekey initiated")
 
 	return ctx, func(err error) {
-		if err != nil {
-			o.collector.RecordRekeyFailed()
-			o.logger.Error("rekey failed", Fields{"error": err.Error()})
-		} else {
-			o.collector.RecordRekeyCompleted()
-			o.logger.Info("rekey completed")
-		}
+		// Outcomes are counted by OnRekeyCompleted and OnRekeyFailed, since
+		// a rekey only completes once the new keys activate
 		endSpan(err)
 	}
 }
 
+// OnRekeyCompleted records a rekey whose new keys have been activated.
+func (o *TunnelObserver) OnRekeyCompleted() {
+	o.collector.RecordRekeyCompleted()
+	o.logger.Info("rekey completed")
+}
+
+// OnRekeyFailed records a failed rekey.
+func (o *TunnelObserver) OnRekeyFailed(err error) {
+	o.collector.RecordRekeyFailed()
+	o.logger.Error("rekey failed", Fields{"error": err.Error()})
+}
+
+// OnUnexpectedRekey records a rekey message the rekey state did not expect.
+func (o *TunnelObserver) OnUnexpectedRekey(stale bool) {
+	o.collector.RecordUnexpectedRekey()
+}
+
+// OnSendRateLimited records data delayed by the send rate limit.
+func (o *TunnelObserver) OnSendRateLimited(n int, delay time.Duration) {
+	o.collector.RecordSendRateLimited(n, delay)
+}
+
 // OnProtocolError records a protocol error.
 func (o *TunnelObserver) OnProtocolError(err error) {
 	o.collector.RecordProtocolError()
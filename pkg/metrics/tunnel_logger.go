@@ -0,0 +1,45 @@
+package metrics
+
+import "github.com/sara-star-quant/quantum-go/pkg/tunnel"
+
+// TunnelLogger adapts a Logger to tunnel.Logger, so tunnel events can be
+// logged by setting TransportConfig.Logger.
+type TunnelLogger struct {
+	logger *Logger
+}
+
+var _ tunnel.Logger = (*TunnelLogger)(nil)
+
+// NewTunnelLogger creates a tunnel.Logger that writes to logger. A nil
+// logger uses the global logger.
+func NewTunnelLogger(logger *Logger) *TunnelLogger {
+	if logger == nil {
+		logger = GetLogger()
+	}
+	return &TunnelLogger{logger: logger}
+}
+
+// Debug logs at debug level.
+func (t *TunnelLogger) Debug(msg string, fields map[string]any) {
+	t.logger.Debug(msg, fields)
+}
+
+// Info logs at info level.
+func (t *TunnelLogger) Info(msg string, fields map[string]any) {
+	t.logger.Info(msg, fields)
+}
+
+// Warn logs at warn level.
+func (t *TunnelLogger) Warn(msg string, fields map[string]any) {
+	t.logger.Warn(msg, fields)
+}
+
+// Error logs at error level.
+func (t *TunnelLogger) Error(msg string, fields map[string]any) {
+	t.logger.Error(msg, fields)
+}
+
+// Named returns a child logger whose name is extended with name.
+func (t *TunnelLogger) Named(name string) tunnel.Logger {
+	return &TunnelLogger{logger: t.logger.Named(name)}
+}
@@ -10,13 +10,25 @@ package protocol
 import "github.com/sara-star-quant/quantum-go/internal/constants"
 
 // SupportedCipherSuites returns the list of cipher suites supported in FIPS mode.
-// In FIPS mode, only AES-256-GCM is available as it is FIPS 140-3 approved.
+// In FIPS mode, only the FIPS 140-3 approved AES-GCM suites are available.
 func SupportedCipherSuites() []constants.CipherSuite {
-	return []constants.CipherSuite{constants.CipherSuiteAES256GCM}
+	return []constants.CipherSuite{
+		constants.CipherSuiteAES256GCM,
+		constants.CipherSuiteAES128GCM,
+	}
+}
+
+// DefaultCipherSuites returns the cipher suites an initiator offers when not
+// configured otherwise. AES-128-GCM is supported but opt-in, so only
+// AES-256-GCM is offered by default.
+func DefaultCipherSuites() []constants.CipherSuite {
+	return []constants.CipherSuite{
+		constants.CipherSuiteAES256GCM,
+	}
 }
 
 // PreferredCipherSuite returns the preferred cipher suite for new connections.
-// In FIPS mode, AES-256-GCM is the only option.
+// In FIPS mode, AES-256-GCM is preferred over AES-128-GCM.
 func PreferredCipherSuite() constants.CipherSuite {
 	return constants.CipherSuiteAES256GCM
 }
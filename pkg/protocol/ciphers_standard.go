@@ -10,8 +10,20 @@ package protocol
 import "github.com/sara-star-quant/quantum-go/internal/constants"
 
 // SupportedCipherSuites returns the list of cipher suites supported in standard mode.
-// Both AES-256-GCM and ChaCha20-Poly1305 are available.
+// AES-256-GCM, ChaCha20-Poly1305 and AES-128-GCM are available, in
+// order of preference.
 func SupportedCipherSuites() []constants.CipherSuite {
+	return []constants.CipherSuite{
+		constants.CipherSuiteAES256GCM,
+		constants.CipherSuiteChaCha20Poly1305,
+		constants.CipherSuiteAES128GCM,
+	}
+}
+
+// DefaultCipherSuites returns the cipher suites an initiator offers when not
+// configured otherwise, in order of preference. AES-128-GCM is supported
+// but opt-in, so it is not offered by default.
+func DefaultCipherSuites() []constants.CipherSuite {
 	return []constants.CipherSuite{
 		constants.CipherSuiteAES256GCM,
 		constants.CipherSuiteChaCha20Poly1305,
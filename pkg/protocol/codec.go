@@ -13,27 +13,58 @@
 //
 // ClientHello Format:
 //
-//	+----------+--------+-----------+------------------+--------------+
-//	| Version  | Random | SessionID | CHKEMPublicKey   | CipherSuites |
-//	| 2B       | 32B    | 16B       | 1600B            | 2B * count   |
-//	+----------+--------+-----------+------------------+--------------+
+//	+----------+--------+-----------+-----------+------------------+--------------+----------+------------+
+//	| Version  | Random | SessionID | KEMParams | CHKEMPublicKey   | CipherSuites | Cookie   | Extensions |
+//	| 2B       | 32B    | 16B       | 1B        | 1600B / 1216B    | 2B * count   | optional | optional   |
+//	+----------+--------+-----------+-----------+------------------+--------------+----------+------------+
+//
+// The cookie is a 2-byte length followed by the cookie, and is only present
+// when answering a HelloRetryRequest or when extensions follow (possibly
+// with length zero).
+//
+// Extensions are a 2-byte count followed by [Type 2B][Length 2B][Value]
+// entries sorted by type, and are omitted when there are none.
+//
+// HelloRetryRequest Format:
+//
+//	+----------+-----------+----------+
+//	| Version  | CookieLen | Cookie   |
+//	| 2B       | 2B        | Variable |
+//	+----------+-----------+----------+
 //
 // ServerHello Format:
 //
-//	+----------+--------+-----------+------------------+-------------+
-//	| Version  | Random | SessionID | CHKEMCiphertext  | CipherSuite |
-//	| 2B       | 32B    | 16B       | 1600B            | 2B          |
-//	+----------+--------+-----------+------------------+-------------+
+//	+----------+--------+-----------+-----------+------------------+-------------+------------+
+//	| Version  | Random | SessionID | KEMParams | CHKEMCiphertext  | CipherSuite | Extensions |
+//	| 2B       | 32B    | 16B       | 1B        | 1600B / 1120B    | 2B          | optional   |
+//	+----------+--------+-----------+-----------+------------------+-------------+------------+
+//
+// The public key and ciphertext sizes are determined by KEMParams. A resumed
+// ServerHello sets the high bit of KEMParams and omits the ciphertext.
+//
+// ServerFinished Format:
+//
+//	+------------+---------------------------+
+//	| VerifyData | IdentityKey || Signature  |
+//	| 32B        | 32B + 64B, optional       |
+//	+------------+---------------------------+
 package protocol
 
 import (
+	"crypto/ed25519"
 	"encoding/binary"
 	"io"
+	"maps"
+	"slices"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
 )
 
+// kemParamsResumedFlag marks a resumed ServerHello in the KEMParams byte.
+const kemParamsResumedFlag = 0x80
+
 // Codec provides message serialization and deserialization.
 type Codec struct{}
 
@@ -48,12 +79,18 @@ func (c *Codec) EncodeClientHello(m *ClientHello) ([]byte, error) {
 		return nil, err
 	}
 
+	params := m.Parameters()
+
 	// Calculate payload size
 	payloadSize := 2 + // version
 		32 + // random
 		1 + len(m.SessionID) + // session ID length + data
-		constants.CHKEMPublicKeySize + // public key
+		1 + params.PublicKeySize() + // KEM parameters + public key
 		2 + 2*len(m.CipherSuites) // cipher suites count + data
+	if len(m.Cookie) > 0 || len(m.Extensions) > 0 {
+		payloadSize += 2 + len(m.Cookie) // cookie length + data
+	}
+	payloadSize += extensionsSize(m.Extensions)
 
 	buf := make([]byte, HeaderSize+payloadSize)
 	offset := 0
@@ -79,9 +116,11 @@ func (c *Codec) EncodeClientHello(m *ClientHello) ([]byte, error) {
 	copy(buf[offset:], m.SessionID)
 	offset += len(m.SessionID)
 
-	// CH-KEM public key
+	// KEM parameters and CH-KEM public key
+	buf[offset] = byte(params)
+	offset++
 	copy(buf[offset:], m.CHKEMPublicKey)
-	offset += constants.CHKEMPublicKeySize
+	offset += params.PublicKeySize()
 
 	// Cipher suites
 	binary.BigEndian.PutUint16(buf[offset:], uint16(len(m.CipherSuites)))
@@ -91,6 +130,17 @@ func (c *Codec) EncodeClientHello(m *ClientHello) ([]byte, error) {
 		offset += 2
 	}
 
+	// Cookie (optional, length-prefixed, empty if only extensions follow)
+	if len(m.Cookie) > 0 || len(m.Extensions) > 0 {
+		binary.BigEndian.PutUint16(buf[offset:], uint16(len(m.Cookie)))
+		offset += 2
+		copy(buf[offset:], m.Cookie)
+		offset += len(m.Cookie)
+	}
+
+	// Extensions (optional)
+	putExtensions(buf[offset:], m.Extensions)
+
 	return buf, nil
 }
 
@@ -109,11 +159,10 @@ func (c *Codec) DecodeClientHello(data []byte) (*ClientHello, error) {
 		return nil, qerrors.ErrInvalidMessage
 	}
 
-	// Minimum payload: version(2) + random(32) + sessionIDLen(1) + publicKey(1600) + cipherSuiteCount(2) + minCipherSuite(2) = 1639
-	minPayloadLen := 2 + 32 + 1 + constants.CHKEMPublicKeySize + 2 + 2
-	if int(payloadLen) < minPayloadLen {
+	if int(payloadLen) < MinClientHelloPayloadSize {
 		return nil, qerrors.ErrInvalidMessage
 	}
+	end := HeaderSize + int(payloadLen)
 
 	offset := HeaderSize
 	m := &ClientHello{}
@@ -130,26 +179,72 @@ func (c *Codec) DecodeClientHello(data []byte) (*ClientHello, error) {
 	// SessionID
 	sessionIDLen := int(data[offset])
 	offset++
+	if offset+sessionIDLen > end {
+		return nil, qerrors.ErrInvalidMessage
+	}
 	if sessionIDLen > 0 {
 		m.SessionID = make([]byte, sessionIDLen)
 		copy(m.SessionID, data[offset:offset+sessionIDLen])
 		offset += sessionIDLen
 	}
 
+	// KEM parameters determine the public key size
+	if offset+1 > end {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	m.KEMParameters = chkem.Parameters(data[offset])
+	offset++
+	pkSize := m.KEMParameters.PublicKeySize()
+	if pkSize == 0 {
+		return nil, qerrors.ErrUnsupportedKEMParameters
+	}
+
 	// CH-KEM public key
-	m.CHKEMPublicKey = make([]byte, constants.CHKEMPublicKeySize)
-	copy(m.CHKEMPublicKey, data[offset:offset+constants.CHKEMPublicKeySize])
-	offset += constants.CHKEMPublicKeySize
+	if offset+pkSize+2 > end {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	m.CHKEMPublicKey = make([]byte, pkSize)
+	copy(m.CHKEMPublicKey, data[offset:offset+pkSize])
+	offset += pkSize
 
 	// Cipher suites
 	cipherSuiteCount := binary.BigEndian.Uint16(data[offset:])
 	offset += 2
+	if cipherSuiteCount > MaxCipherSuites || offset+2*int(cipherSuiteCount) > end {
+		return nil, qerrors.ErrInvalidMessage
+	}
 	m.CipherSuites = make([]constants.CipherSuite, cipherSuiteCount)
 	for i := range m.CipherSuites {
 		m.CipherSuites[i] = constants.CipherSuite(binary.BigEndian.Uint16(data[offset:]))
 		offset += 2
 	}
 
+	// Cookie (optional)
+	if offset < end {
+		if offset+2 > end {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		cookieLen := int(binary.BigEndian.Uint16(data[offset:]))
+		offset += 2
+		if offset+cookieLen > end || (cookieLen == 0 && offset == end) {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		if cookieLen > 0 {
+			m.Cookie = make([]byte, cookieLen)
+			copy(m.Cookie, data[offset:offset+cookieLen])
+			offset += cookieLen
+		}
+	}
+
+	// Extensions (optional)
+	if offset < end {
+		var err error
+		m.Extensions, err = decodeExtensions(data[offset:end])
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	if err := m.Validate(); err != nil {
 		return nil, err
 	}
@@ -163,11 +258,20 @@ func (c *Codec) EncodeServerHello(m *ServerHello) ([]byte, error) {
 		return nil, err
 	}
 
+	params := m.Parameters()
+	paramsByte := byte(params)
+	ctSize := params.CiphertextSize()
+	if m.Resumed {
+		paramsByte |= kemParamsResumedFlag
+		ctSize = 0
+	}
+
 	payloadSize := 2 + // version
 		32 + // random
 		1 + len(m.SessionID) + // session ID length + data
-		constants.CHKEMCiphertextSize + // ciphertext
-		2 // cipher suite
+		1 + ctSize + // KEM parameters + ciphertext
+		2 + // cipher suite
+		extensionsSize(m.Extensions)
 
 	buf := make([]byte, HeaderSize+payloadSize)
 	offset := 0
@@ -193,12 +297,18 @@ func (c *Codec) EncodeServerHello(m *ServerHello) ([]byte, error) {
 	copy(buf[offset:], m.SessionID)
 	offset += len(m.SessionID)
 
-	// CH-KEM ciphertext
+	// KEM parameters and CH-KEM ciphertext
+	buf[offset] = paramsByte
+	offset++
 	copy(buf[offset:], m.CHKEMCiphertext)
-	offset += constants.CHKEMCiphertextSize
+	offset += ctSize
 
 	// Cipher suite
 	binary.BigEndian.PutUint16(buf[offset:], uint16(m.CipherSuite))
+	offset += 2
+
+	// Extensions (optional)
+	putExtensions(buf[offset:], m.Extensions)
 
 	return buf, nil
 }
@@ -218,11 +328,10 @@ func (c *Codec) DecodeServerHello(data []byte) (*ServerHello, error) {
 		return nil, qerrors.ErrInvalidMessage
 	}
 
-	// Minimum payload: version(2) + random(32) + sessionIDLen(1) + ciphertext(1600) + cipherSuite(2) = 1637
-	minPayloadLen := 2 + 32 + 1 + constants.CHKEMCiphertextSize + 2
-	if int(payloadLen) < minPayloadLen {
+	if int(payloadLen) < MinServerHelloPayloadSize {
 		return nil, qerrors.ErrInvalidMessage
 	}
+	end := HeaderSize + int(payloadLen)
 
 	offset := HeaderSize
 	m := &ServerHello{}
@@ -239,19 +348,167 @@ func (c *Codec) DecodeServerHello(data []byte) (*ServerHello, error) {
 	// SessionID
 	sessionIDLen := int(data[offset])
 	offset++
+	if offset+sessionIDLen > end {
+		return nil, qerrors.ErrInvalidMessage
+	}
 	if sessionIDLen > 0 {
 		m.SessionID = make([]byte, sessionIDLen)
 		copy(m.SessionID, data[offset:offset+sessionIDLen])
 		offset += sessionIDLen
 	}
 
+	// KEM parameters determine the ciphertext size; a resumed hello has none
+	if offset+1 > end {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	m.Resumed = data[offset]&kemParamsResumedFlag != 0
+	m.KEMParameters = chkem.Parameters(data[offset] &^ kemParamsResumedFlag)
+	offset++
+	ctSize := m.KEMParameters.CiphertextSize()
+	if ctSize == 0 {
+		return nil, qerrors.ErrUnsupportedKEMParameters
+	}
+	if m.Resumed {
+		ctSize = 0
+	}
+
 	// CH-KEM ciphertext
-	m.CHKEMCiphertext = make([]byte, constants.CHKEMCiphertextSize)
-	copy(m.CHKEMCiphertext, data[offset:offset+constants.CHKEMCiphertextSize])
-	offset += constants.CHKEMCiphertextSize
+	if offset+ctSize+2 > end {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	if ctSize > 0 {
+		m.CHKEMCiphertext = make([]byte, ctSize)
+		copy(m.CHKEMCiphertext, data[offset:offset+ctSize])
+		offset += ctSize
+	}
 
 	// Cipher suite
 	m.CipherSuite = constants.CipherSuite(binary.BigEndian.Uint16(data[offset:]))
+	offset += 2
+
+	// Extensions (optional)
+	if offset < end {
+		var err error
+		m.Extensions, err = decodeExtensions(data[offset:end])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// extensionsSize returns the encoded size of an extensions block, or 0 if
+// there are no extensions.
+func extensionsSize(extensions map[uint16][]byte) int {
+	if len(extensions) == 0 {
+		return 0
+	}
+	size := 2 // count
+	for _, value := range extensions {
+		size += 4 + len(value) // type + length + value
+	}
+	return size
+}
+
+// putExtensions writes the extensions block into buf, sorted by type so the
+// encoding is deterministic.
+func putExtensions(buf []byte, extensions map[uint16][]byte) {
+	if len(extensions) == 0 {
+		return
+	}
+	binary.BigEndian.PutUint16(buf, uint16(len(extensions)))
+	offset := 2
+	for _, typ := range slices.Sorted(maps.Keys(extensions)) {
+		value := extensions[typ]
+		binary.BigEndian.PutUint16(buf[offset:], typ)
+		binary.BigEndian.PutUint16(buf[offset+2:], uint16(len(value)))
+		offset += 4
+		copy(buf[offset:], value)
+		offset += len(value)
+	}
+}
+
+// decodeExtensions parses an extensions block that must fill data exactly.
+// Duplicate types are rejected.
+func decodeExtensions(data []byte) (map[uint16][]byte, error) {
+	if len(data) < 2 {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	count := int(binary.BigEndian.Uint16(data))
+	if count == 0 || count > MaxExtensions || 2+4*count > len(data) {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	offset := 2
+
+	extensions := make(map[uint16][]byte, count)
+	for i := 0; i < count; i++ {
+		if offset+4 > len(data) {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		typ := binary.BigEndian.Uint16(data[offset:])
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+		offset += 4
+		if offset+length > len(data) {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		if _, ok := extensions[typ]; ok {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		extensions[typ] = append([]byte{}, data[offset:offset+length]...)
+		offset += length
+	}
+	if offset != len(data) {
+		return nil, qerrors.ErrInvalidMessage
+	}
+
+	return extensions, nil
+}
+
+// EncodeHelloRetryRequest serializes a HelloRetryRequest message.
+func (c *Codec) EncodeHelloRetryRequest(m *HelloRetryRequest) ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	payloadSize := 2 + 2 + len(m.Cookie) // version + cookie length + cookie
+	buf := make([]byte, HeaderSize+payloadSize)
+
+	buf[0] = byte(MessageTypeHelloRetryRequest)
+	binary.BigEndian.PutUint32(buf[1:], uint32(payloadSize))
+	buf[HeaderSize] = m.Version.Major
+	buf[HeaderSize+1] = m.Version.Minor
+	binary.BigEndian.PutUint16(buf[HeaderSize+2:], uint16(len(m.Cookie)))
+	copy(buf[HeaderSize+4:], m.Cookie)
+
+	return buf, nil
+}
+
+// DecodeHelloRetryRequest deserializes a HelloRetryRequest message.
+func (c *Codec) DecodeHelloRetryRequest(data []byte) (*HelloRetryRequest, error) {
+	if len(data) < HeaderSize+4 {
+		return nil, qerrors.ErrInvalidMessage
+	}
+
+	if MessageType(data[0]) != MessageTypeHelloRetryRequest {
+		return nil, qerrors.ErrInvalidMessage
+	}
+
+	payloadLen := int(binary.BigEndian.Uint32(data[1:5]))
+	cookieLen := int(binary.BigEndian.Uint16(data[HeaderSize+2:]))
+	if payloadLen != 4+cookieLen || len(data) < HeaderSize+payloadLen {
+		return nil, qerrors.ErrInvalidMessage
+	}
+
+	m := &HelloRetryRequest{
+		Version: Version{Major: data[HeaderSize], Minor: data[HeaderSize+1]},
+		Cookie:  make([]byte, cookieLen),
+	}
+	copy(m.Cookie, data[HeaderSize+4:HeaderSize+payloadLen])
 
 	if err := m.Validate(); err != nil {
 		return nil, err
@@ -291,21 +548,73 @@ func (c *Codec) DecodeFinished(data []byte) ([]byte, error) {
 	return verifyData, nil
 }
 
+// EncodeServerFinished serializes a ServerFinished message.
+//
+// Payload: verify_data (32) [|| identity_key (32) || signature (64)]
+//
+// The identity fields are optional. Peers that predate them read only the
+// verify_data and ignore the rest of the payload.
+func (c *Codec) EncodeServerFinished(m *ServerFinished) ([]byte, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+
+	payloadLen := len(m.VerifyData) + len(m.IdentityKey) + len(m.Signature)
+	buf := make([]byte, HeaderSize, HeaderSize+payloadLen)
+	buf[0] = byte(MessageTypeServerFinished)
+	//nolint:gosec // G115: payload is at most 128 bytes
+	binary.BigEndian.PutUint32(buf[1:], uint32(payloadLen))
+	buf = append(buf, m.VerifyData...)
+	buf = append(buf, m.IdentityKey...)
+	return append(buf, m.Signature...), nil
+}
+
+// DecodeServerFinished deserializes a ServerFinished message.
+func (c *Codec) DecodeServerFinished(data []byte) (*ServerFinished, error) {
+	if len(data) < HeaderSize+32 || MessageType(data[0]) != MessageTypeServerFinished {
+		return nil, qerrors.ErrInvalidMessage
+	}
+
+	payloadLen := binary.BigEndian.Uint32(data[1:])
+	if uint64(len(data)) != uint64(HeaderSize)+uint64(payloadLen) {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	payload := data[HeaderSize:]
+
+	m := &ServerFinished{VerifyData: append([]byte(nil), payload[:32]...)}
+	if rest := payload[32:]; len(rest) > 0 {
+		if len(rest) != ed25519.PublicKeySize+ed25519.SignatureSize {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		m.IdentityKey = append([]byte(nil), rest[:ed25519.PublicKeySize]...)
+		m.Signature = append([]byte(nil), rest[ed25519.PublicKeySize:]...)
+	}
+
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // EncodeData serializes a data message.
 func (c *Codec) EncodeData(seq uint64, payload []byte) ([]byte, error) {
+	return c.EncodeDataInto(make([]byte, 0, HeaderSize+8+len(payload)), seq, payload)
+}
+
+// EncodeDataInto appends a serialized data message to buf and returns the
+// extended slice. Passing a reused buffer with enough spare capacity, such
+// as a pooled buffer sliced to zero length, avoids allocating.
+func (c *Codec) EncodeDataInto(buf []byte, seq uint64, payload []byte) ([]byte, error) {
 	if len(payload) > constants.MaxPayloadSize {
 		return nil, qerrors.ErrMessageTooLarge
 	}
 
 	payloadSize := 8 + len(payload)
-	buf := make([]byte, HeaderSize+payloadSize)
-
-	buf[0] = byte(MessageTypeData)
-	binary.BigEndian.PutUint32(buf[1:], uint32(payloadSize))
-	binary.BigEndian.PutUint64(buf[HeaderSize:], seq)
-	copy(buf[HeaderSize+8:], payload)
-
-	return buf, nil
+	buf = append(buf, byte(MessageTypeData))
+	//nolint:gosec // G115: payloadSize is bounded by MaxPayloadSize + 8
+	buf = binary.BigEndian.AppendUint32(buf, uint32(payloadSize))
+	buf = binary.BigEndian.AppendUint64(buf, seq)
+	return append(buf, payload...), nil
 }
 
 // DecodeData deserializes a data message.
@@ -324,6 +633,38 @@ func (c *Codec) DecodeData(data []byte) (uint64, []byte, error) {
 	return seq, payload, nil
 }
 
+// EncodeDataHeaderInto appends a serialized data message carrying an
+// application header to buf, like EncodeDataInto. The header is not part of
+// the message framing: the sender frames it inside the encrypted payload.
+// Format: [DataHeader(1B)] [Len(4B)] [Seq(8B)] [AEAD-Ciphertext]
+func (c *Codec) EncodeDataHeaderInto(buf []byte, seq uint64, payload []byte) ([]byte, error) {
+	if len(payload) > constants.MaxPayloadSize {
+		return nil, qerrors.ErrMessageTooLarge
+	}
+
+	payloadSize := 8 + len(payload)
+	buf = append(buf, byte(MessageTypeDataHeader))
+	//nolint:gosec // G115: payloadSize is bounded by MaxPayloadSize + 8
+	buf = binary.BigEndian.AppendUint32(buf, uint32(payloadSize))
+	buf = binary.BigEndian.AppendUint64(buf, seq)
+	return append(buf, payload...), nil
+}
+
+// DecodeDataHeader deserializes a data message carrying an application
+// header. The payload aliases data.
+func (c *Codec) DecodeDataHeader(data []byte) (seq uint64, payload []byte, err error) {
+	if len(data) < HeaderSize+8 {
+		return 0, nil, qerrors.ErrInvalidMessage
+	}
+
+	if MessageType(data[0]) != MessageTypeDataHeader {
+		return 0, nil, qerrors.ErrInvalidMessage
+	}
+
+	seq = binary.BigEndian.Uint64(data[HeaderSize:])
+	return seq, data[HeaderSize+8:], nil
+}
+
 // EncodeAlert serializes an alert message.
 func (c *Codec) EncodeAlert(level AlertLevel, code AlertCode, description string) []byte {
 	// Description length is stored in a single byte (max 255)
@@ -370,57 +711,135 @@ func (c *Codec) DecodeAlert(data []byte) (AlertLevel, AlertCode, string, error)
 }
 
 // EncodeRekeyPayload serializes the plaintext inner rekey payload.
-// Format: NewPublicKey (1600B) + ActivationSequence (8B)
-func (c *Codec) EncodeRekeyPayload(newPublicKey []byte, activationSeq uint64) ([]byte, error) {
-	if len(newPublicKey) != constants.CHKEMPublicKeySize {
-		return nil, qerrors.ErrInvalidPublicKey
+// Format: Kind(1B) + KEMData (public key or ciphertext) + ActivationSequence (8B)
+//
+// KEMData is a CH-KEM public key in a rekey request and a CH-KEM ciphertext
+// in a rekey response; its size depends on the session's KEM parameters.
+func (c *Codec) EncodeRekeyPayload(kind RekeyKind, kemData []byte, activationSeq uint64) ([]byte, error) {
+	if !kind.IsRequest() && kind != RekeyKindResponse {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	if !isRekeyKEMDataSize(len(kemData)) {
+		if kind == RekeyKindResponse {
+			return nil, qerrors.ErrInvalidCiphertextSize
+		}
+		return nil, qerrors.ErrInvalidPublicKeySize
 	}
 
-	buf := make([]byte, constants.CHKEMPublicKeySize+8)
-	copy(buf, newPublicKey)
-	binary.BigEndian.PutUint64(buf[constants.CHKEMPublicKeySize:], activationSeq)
+	buf := make([]byte, 1+len(kemData)+8)
+	buf[0] = byte(kind)
+	copy(buf[1:], kemData)
+	binary.BigEndian.PutUint64(buf[1+len(kemData):], activationSeq)
 
 	return buf, nil
 }
 
 // DecodeRekeyPayload deserializes the plaintext inner rekey payload.
-func (c *Codec) DecodeRekeyPayload(data []byte) ([]byte, uint64, error) {
-	minLen := constants.CHKEMPublicKeySize + 8
-	if len(data) < minLen {
-		return nil, 0, qerrors.ErrInvalidMessage
+func (c *Codec) DecodeRekeyPayload(data []byte) (RekeyKind, []byte, uint64, error) {
+	if len(data) < 1+8 || !isRekeyKEMDataSize(len(data)-1-8) {
+		return 0, nil, 0, qerrors.ErrInvalidMessage
+	}
+
+	kind := RekeyKind(data[0])
+	if !kind.IsRequest() && kind != RekeyKindResponse {
+		return 0, nil, 0, qerrors.ErrInvalidMessage
 	}
 
-	newPublicKey := make([]byte, constants.CHKEMPublicKeySize)
-	copy(newPublicKey, data[:constants.CHKEMPublicKeySize])
+	kemLen := len(data) - 1 - 8
+	kemData := make([]byte, kemLen)
+	copy(kemData, data[1:1+kemLen])
 
-	activationSeq := binary.BigEndian.Uint64(data[constants.CHKEMPublicKeySize:])
+	activationSeq := binary.BigEndian.Uint64(data[1+kemLen:])
 
-	return newPublicKey, activationSeq, nil
+	return kind, kemData, activationSeq, nil
+}
+
+// isRekeyKEMDataSize reports whether n is the size of a CH-KEM public key or
+// ciphertext for any supported parameter set.
+func isRekeyKEMDataSize(n int) bool {
+	for _, p := range []chkem.Parameters{chkem.CHKEM1024, chkem.CHKEM768} {
+		if n == p.PublicKeySize() || n == p.CiphertextSize() {
+			return true
+		}
+	}
+	return false
 }
 
 // EncodeRekey serializes an encrypted rekey message.
 // Format: [Rekey(1B)] [Len(4B)] [Seq(8B)] [AEAD-Ciphertext]
 func (c *Codec) EncodeRekey(seq uint64, ciphertext []byte) ([]byte, error) {
+	return encodeSealed(MessageTypeRekey, seq, ciphertext), nil
+}
+
+// DecodeRekey deserializes an encrypted rekey message.
+// Returns the sequence number and ciphertext from the outer message.
+func (c *Codec) DecodeRekey(data []byte) (uint64, []byte, error) {
+	return decodeSealed(MessageTypeRekey, data)
+}
+
+// EncodeCloseWrite serializes an encrypted close-write message. The
+// ciphertext seals the single byte MessageTypeCloseWrite so the peer can
+// authenticate it.
+// Format: [CloseWrite(1B)] [Len(4B)] [Seq(8B)] [AEAD-Ciphertext]
+func (c *Codec) EncodeCloseWrite(seq uint64, ciphertext []byte) ([]byte, error) {
+	return encodeSealed(MessageTypeCloseWrite, seq, ciphertext), nil
+}
+
+// DecodeCloseWrite deserializes an encrypted close-write message.
+// Returns the sequence number and ciphertext from the outer message.
+func (c *Codec) DecodeCloseWrite(data []byte) (uint64, []byte, error) {
+	return decodeSealed(MessageTypeCloseWrite, data)
+}
+
+// EncodeRatchetPayload serializes the plaintext inner ratchet payload.
+// Format: ActivationSequence (8B)
+func (c *Codec) EncodeRatchetPayload(activationSeq uint64) []byte {
+	return binary.BigEndian.AppendUint64(make([]byte, 0, 8), activationSeq)
+}
+
+// DecodeRatchetPayload deserializes the plaintext inner ratchet payload.
+func (c *Codec) DecodeRatchetPayload(data []byte) (uint64, error) {
+	if len(data) != 8 {
+		return 0, qerrors.ErrInvalidMessage
+	}
+	return binary.BigEndian.Uint64(data), nil
+}
+
+// EncodeRatchet serializes an encrypted ratchet message.
+// Format: [Ratchet(1B)] [Len(4B)] [Seq(8B)] [AEAD-Ciphertext]
+func (c *Codec) EncodeRatchet(seq uint64, ciphertext []byte) ([]byte, error) {
+	return encodeSealed(MessageTypeRatchet, seq, ciphertext), nil
+}
+
+// DecodeRatchet deserializes an encrypted ratchet message.
+// Returns the sequence number and ciphertext from the outer message.
+func (c *Codec) DecodeRatchet(data []byte) (uint64, []byte, error) {
+	return decodeSealed(MessageTypeRatchet, data)
+}
+
+// encodeSealed serializes a control message carrying a sequence number and
+// AEAD ciphertext.
+func encodeSealed(msgType MessageType, seq uint64, ciphertext []byte) []byte {
 	payloadSize := 8 + len(ciphertext)
 	buf := make([]byte, HeaderSize+payloadSize)
 
-	buf[0] = byte(MessageTypeRekey)
+	buf[0] = byte(msgType)
 	binary.BigEndian.PutUint32(buf[1:], uint32(payloadSize))
 	binary.BigEndian.PutUint64(buf[HeaderSize:], seq)
 	copy(buf[HeaderSize+8:], ciphertext)
 
-	return buf, nil
+	return buf
 }
 
-// DecodeRekey deserializes an encrypted rekey message.
-// Returns the sequence number and ciphertext from the outer message.
-func (c *Codec) DecodeRekey(data []byte) (uint64, []byte, error) {
+// decodeSealed deserializes a control message of msgType carrying a
+// sequence number and AEAD ciphertext.
+func decodeSealed(msgType MessageType, data []byte) (uint64, []byte, error) {
 	minLen := HeaderSize + 8
 	if len(data) < minLen {
 		return 0, nil, qerrors.ErrInvalidMessage
 	}
 
-	if MessageType(data[0]) != MessageTypeRekey {
+	if MessageType(data[0]) != msgType {
 		return 0, nil, qerrors.ErrInvalidMessage
 	}
 
@@ -433,7 +852,18 @@ func (c *Codec) DecodeRekey(data []byte) (uint64, []byte, error) {
 
 // ReadMessage reads a complete message from the reader.
 func (c *Codec) ReadMessage(r io.Reader) ([]byte, error) {
-	header := make([]byte, HeaderSize)
+	return c.ReadMessageInto(r, nil)
+}
+
+// ReadMessageInto reads a complete message like ReadMessage, storing it in
+// buf when buf has enough capacity. The result aliases buf in that case and
+// is only valid until buf is reused; a result with a larger capacity than
+// buf was freshly allocated and may be kept as the new buffer.
+func (c *Codec) ReadMessageInto(r io.Reader, buf []byte) ([]byte, error) {
+	if cap(buf) < HeaderSize {
+		buf = make([]byte, HeaderSize)
+	}
+	header := buf[:HeaderSize]
 	if _, err := io.ReadFull(r, header); err != nil {
 		return nil, err
 	}
@@ -443,8 +873,13 @@ func (c *Codec) ReadMessage(r io.Reader) ([]byte, error) {
 		return nil, qerrors.ErrMessageTooLarge
 	}
 
-	msg := make([]byte, HeaderSize+payloadLen)
-	copy(msg, header)
+	var msg []byte
+	if size := HeaderSize + int(payloadLen); cap(buf) >= size {
+		msg = buf[:size]
+	} else {
+		msg = make([]byte, size)
+		copy(msg, header)
+	}
 
 	if payloadLen > 0 {
 		if _, err := io.ReadFull(r, msg[HeaderSize:]); err != nil {
@@ -3,7 +3,9 @@ package protocol_test
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"io"
+	"runtime"
 	"testing"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
@@ -185,6 +187,120 @@ func TestEncodeDecodeServerHello(t *testing.T) {
 	}
 }
 
+func TestHelloKEMParameters768(t *testing.T) {
+	codec := protocol.NewCodec()
+	kp, err := chkem.GenerateKeyPairWithParameters(chkem.CHKEM768)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairWithParameters failed: %v", err)
+	}
+
+	hello := &protocol.ClientHello{
+		Version:        protocol.Current,
+		Random:         make([]byte, 32),
+		KEMParameters:  chkem.CHKEM768,
+		CHKEMPublicKey: kp.PublicKey().Bytes(),
+		CipherSuites:   []constants.CipherSuite{constants.CipherSuiteAES256GCM},
+	}
+	encoded, err := codec.EncodeClientHello(hello)
+	if err != nil {
+		t.Fatalf("EncodeClientHello failed: %v", err)
+	}
+	decodedHello, err := codec.DecodeClientHello(encoded)
+	if err != nil {
+		t.Fatalf("DecodeClientHello failed: %v", err)
+	}
+	if decodedHello.KEMParameters != chkem.CHKEM768 {
+		t.Errorf("ClientHello KEM parameters: got %v, want %v", decodedHello.KEMParameters, chkem.CHKEM768)
+	}
+	if !bytes.Equal(decodedHello.CHKEMPublicKey, hello.CHKEMPublicKey) {
+		t.Error("public key mismatch")
+	}
+
+	ct, _, err := chkem.Encapsulate(kp.PublicKey())
+	if err != nil {
+		t.Fatalf("Encapsulate failed: %v", err)
+	}
+	serverHello := &protocol.ServerHello{
+		Version:         protocol.Current,
+		Random:          make([]byte, 32),
+		KEMParameters:   chkem.CHKEM768,
+		CHKEMCiphertext: ct.Bytes(),
+		CipherSuite:     constants.CipherSuiteAES256GCM,
+	}
+	encoded, err = codec.EncodeServerHello(serverHello)
+	if err != nil {
+		t.Fatalf("EncodeServerHello failed: %v", err)
+	}
+	decodedServer, err := codec.DecodeServerHello(encoded)
+	if err != nil {
+		t.Fatalf("DecodeServerHello failed: %v", err)
+	}
+	if decodedServer.KEMParameters != chkem.CHKEM768 {
+		t.Errorf("ServerHello KEM parameters: got %v, want %v", decodedServer.KEMParameters, chkem.CHKEM768)
+	}
+	if !bytes.Equal(decodedServer.CHKEMCiphertext, serverHello.CHKEMCiphertext) {
+		t.Error("ciphertext mismatch")
+	}
+
+	// A 768 public key labelled as 1024 is rejected
+	hello.KEMParameters = chkem.CHKEM1024
+	if _, err := codec.EncodeClientHello(hello); err == nil {
+		t.Error("expected error for mismatched KEM parameters and key size")
+	}
+
+	// Unknown parameter sets on the wire are rejected
+	hello.KEMParameters = chkem.CHKEM768
+	encoded, _ = codec.EncodeClientHello(hello)
+	encoded[protocol.HeaderSize+2+32+1] = 0x7F
+	if _, err := codec.DecodeClientHello(encoded); !errors.Is(err, qerrors.ErrUnsupportedKEMParameters) {
+		t.Errorf("expected ErrUnsupportedKEMParameters, got %v", err)
+	}
+}
+
+func TestEncodeDecodeResumedServerHello(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	original := &protocol.ServerHello{
+		Version:       protocol.Current,
+		Random:        make([]byte, 32),
+		SessionID:     bytes.Repeat([]byte{0x42}, constants.SessionIDSize),
+		KEMParameters: chkem.CHKEM768,
+		CipherSuite:   constants.CipherSuiteChaCha20Poly1305,
+		Resumed:       true,
+	}
+
+	encoded, err := codec.EncodeServerHello(original)
+	if err != nil {
+		t.Fatalf("EncodeServerHello failed: %v", err)
+	}
+	if want := protocol.HeaderSize + 2 + 32 + 1 + constants.SessionIDSize + 1 + 2; len(encoded) != want {
+		t.Errorf("resumed ServerHello is %d bytes, want %d", len(encoded), want)
+	}
+
+	decoded, err := codec.DecodeServerHello(encoded)
+	if err != nil {
+		t.Fatalf("DecodeServerHello failed: %v", err)
+	}
+	if !decoded.Resumed {
+		t.Error("resumed flag lost")
+	}
+	if decoded.KEMParameters != chkem.CHKEM768 {
+		t.Errorf("KEM parameters: got %v, want %v", decoded.KEMParameters, chkem.CHKEM768)
+	}
+	if len(decoded.CHKEMCiphertext) != 0 {
+		t.Error("resumed ServerHello should carry no ciphertext")
+	}
+	if !bytes.Equal(decoded.SessionID, original.SessionID) {
+		t.Error("session ID mismatch")
+	}
+
+	// A resumed hello must name the session it resumes
+	original.SessionID = nil
+	if _, err := codec.EncodeServerHello(original); !errors.Is(err, qerrors.ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage without session ID, got %v", err)
+	}
+}
+
 func TestDecodeServerHelloInvalidInputs(t *testing.T) {
 	codec := protocol.NewCodec()
 
@@ -209,6 +325,340 @@ func TestDecodeServerHelloInvalidInputs(t *testing.T) {
 	}
 }
 
+// --- HelloRetryRequest Tests ---
+
+func TestEncodeDecodeHelloRetryRequest(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	original := &protocol.HelloRetryRequest{
+		Version: protocol.Current,
+		Cookie:  bytes.Repeat([]byte{0xC0}, 40),
+	}
+	encoded, err := codec.EncodeHelloRetryRequest(original)
+	if err != nil {
+		t.Fatalf("EncodeHelloRetryRequest failed: %v", err)
+	}
+	decoded, err := codec.DecodeHelloRetryRequest(encoded)
+	if err != nil {
+		t.Fatalf("DecodeHelloRetryRequest failed: %v", err)
+	}
+	if !bytes.Equal(decoded.Cookie, original.Cookie) {
+		t.Error("cookie mismatch")
+	}
+
+	// Empty and oversized cookies are rejected
+	original.Cookie = nil
+	if _, err := codec.EncodeHelloRetryRequest(original); err == nil {
+		t.Error("expected error for empty cookie")
+	}
+	original.Cookie = make([]byte, protocol.MaxCookieSize+1)
+	if _, err := codec.EncodeHelloRetryRequest(original); err == nil {
+		t.Error("expected error for oversized cookie")
+	}
+
+	// Length fields must agree
+	encoded[protocol.HeaderSize+3]++
+	if _, err := codec.DecodeHelloRetryRequest(encoded); err == nil {
+		t.Error("expected error for mismatched cookie length")
+	}
+}
+
+func TestEncodeDecodeClientHelloCookie(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	kp, err := chkem.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	hello := &protocol.ClientHello{
+		Version:        protocol.Current,
+		Random:         make([]byte, 32),
+		CHKEMPublicKey: kp.PublicKey().Bytes(),
+		CipherSuites:   []constants.CipherSuite{constants.CipherSuiteAES256GCM},
+		Cookie:         []byte("cookie"),
+	}
+
+	encoded, err := codec.EncodeClientHello(hello)
+	if err != nil {
+		t.Fatalf("EncodeClientHello failed: %v", err)
+	}
+	decoded, err := codec.DecodeClientHello(encoded)
+	if err != nil {
+		t.Fatalf("DecodeClientHello failed: %v", err)
+	}
+	if !bytes.Equal(decoded.Cookie, hello.Cookie) {
+		t.Errorf("cookie: got %q, want %q", decoded.Cookie, hello.Cookie)
+	}
+
+	// Trailing bytes that are not a well-formed cookie are rejected
+	truncated := append([]byte(nil), encoded[:len(encoded)-1]...)
+	binary.BigEndian.PutUint32(truncated[1:], uint32(len(truncated)-protocol.HeaderSize))
+	if _, err := codec.DecodeClientHello(truncated); err == nil {
+		t.Error("expected error for truncated cookie")
+	}
+}
+
+func TestEncodeDecodeClientHelloExtensions(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	kp, err := chkem.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	extensions := map[uint16][]byte{
+		protocol.ExtensionTraceParent: []byte("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"),
+		0x7F00:                        []byte("unknown"),
+		0x0002:                        {},
+	}
+
+	for _, cookie := range [][]byte{nil, []byte("cookie")} {
+		hello := &protocol.ClientHello{
+			Version:        protocol.Current,
+			Random:         make([]byte, 32),
+			CHKEMPublicKey: kp.PublicKey().Bytes(),
+			CipherSuites:   []constants.CipherSuite{constants.CipherSuiteAES256GCM},
+			Cookie:         cookie,
+			Extensions:     extensions,
+		}
+
+		encoded, err := codec.EncodeClientHello(hello)
+		if err != nil {
+			t.Fatalf("EncodeClientHello failed: %v", err)
+		}
+		decoded, err := codec.DecodeClientHello(encoded)
+		if err != nil {
+			t.Fatalf("DecodeClientHello failed: %v", err)
+		}
+		if !bytes.Equal(decoded.Cookie, cookie) {
+			t.Errorf("cookie: got %q, want %q", decoded.Cookie, cookie)
+		}
+		if len(decoded.Extensions) != len(extensions) {
+			t.Fatalf("expected %d extensions, got %d", len(extensions), len(decoded.Extensions))
+		}
+		for typ, value := range extensions {
+			if !bytes.Equal(decoded.Extensions[typ], value) {
+				t.Errorf("extension %#04x: got %q, want %q", typ, decoded.Extensions[typ], value)
+			}
+		}
+
+		// Encoding is deterministic regardless of map order
+		for i := 0; i < 10; i++ {
+			again, _ := codec.EncodeClientHello(hello)
+			if !bytes.Equal(again, encoded) {
+				t.Fatal("encoding is not deterministic")
+			}
+		}
+	}
+
+	hello := &protocol.ClientHello{
+		Version:        protocol.Current,
+		Random:         make([]byte, 32),
+		CHKEMPublicKey: kp.PublicKey().Bytes(),
+		CipherSuites:   []constants.CipherSuite{constants.CipherSuiteAES256GCM},
+		Extensions:     map[uint16][]byte{protocol.ExtensionTraceParent: make([]byte, protocol.MaxTraceParentSize+1)},
+	}
+	if _, err := codec.EncodeClientHello(hello); err == nil {
+		t.Error("expected error for oversized traceparent")
+	}
+
+	for name, ext := range map[string]map[uint16][]byte{
+		"empty compression":    {protocol.ExtensionCompression: {}},
+		"too many compression": {protocol.ExtensionCompression: make([]byte, protocol.MaxCompressionOffers+1)},
+		"non-empty padding":    {protocol.ExtensionPadding: {1}},
+		"non-empty commitment": {protocol.ExtensionKeyCommitment: {1}},
+	} {
+		hello.Extensions = ext
+		if _, err := codec.EncodeClientHello(hello); err == nil {
+			t.Errorf("expected error for %s", name)
+		}
+	}
+}
+
+func TestDecodeHelloExtensionsUnknown(t *testing.T) {
+	codec := protocol.NewCodec()
+	kp, _ := chkem.GenerateKeyPair()
+	ct, _, _ := chkem.Encapsulate(kp.PublicKey())
+
+	hello := &protocol.ServerHello{
+		Version:         protocol.Current,
+		Random:          make([]byte, 32),
+		SessionID:       make([]byte, constants.SessionIDSize),
+		CHKEMCiphertext: ct.Bytes(),
+		CipherSuite:     constants.CipherSuiteAES256GCM,
+	}
+	plain, err := codec.EncodeServerHello(hello)
+	if err != nil {
+		t.Fatalf("EncodeServerHello failed: %v", err)
+	}
+
+	// A ServerHello from a newer peer with an extension this version does
+	// not know decodes to the same fields
+	hello.Extensions = map[uint16][]byte{0xBEEF: []byte("future")}
+	encoded, err := codec.EncodeServerHello(hello)
+	if err != nil {
+		t.Fatalf("EncodeServerHello failed: %v", err)
+	}
+	if len(encoded) != len(plain)+2+4+len("future") {
+		t.Errorf("unexpected encoded size %d", len(encoded))
+	}
+	decoded, err := codec.DecodeServerHello(encoded)
+	if err != nil {
+		t.Fatalf("DecodeServerHello failed: %v", err)
+	}
+	if decoded.CipherSuite != hello.CipherSuite || !bytes.Equal(decoded.CHKEMCiphertext, hello.CHKEMCiphertext) {
+		t.Error("fields changed by an unknown extension")
+	}
+	if !bytes.Equal(decoded.Extensions[0xBEEF], []byte("future")) {
+		t.Errorf("unknown extension not preserved: %v", decoded.Extensions)
+	}
+}
+
+func TestDecodeHelloExtensionsMalformed(t *testing.T) {
+	codec := protocol.NewCodec()
+	kp, _ := chkem.GenerateKeyPair()
+	ct, _, _ := chkem.Encapsulate(kp.PublicKey())
+
+	plain, err := codec.EncodeServerHello(&protocol.ServerHello{
+		Version:         protocol.Current,
+		Random:          make([]byte, 32),
+		SessionID:       make([]byte, constants.SessionIDSize),
+		CHKEMCiphertext: ct.Bytes(),
+		CipherSuite:     constants.CipherSuiteAES256GCM,
+	})
+	if err != nil {
+		t.Fatalf("EncodeServerHello failed: %v", err)
+	}
+
+	// withBlock appends a raw extensions block to the ServerHello
+	withBlock := func(block ...byte) []byte {
+		msg := append(append([]byte(nil), plain...), block...)
+		binary.BigEndian.PutUint32(msg[1:], uint32(len(msg)-protocol.HeaderSize))
+		return msg
+	}
+
+	tests := []struct {
+		name  string
+		block []byte
+	}{
+		{"truncated count", []byte{0x00}},
+		{"zero count", []byte{0x00, 0x00}},
+		{"missing extension", []byte{0x00, 0x02, 0x00, 0x05, 0x00, 0x00}},
+		{"length beyond message", []byte{0x00, 0x01, 0x00, 0x05, 0x00, 0x04, 'a', 'b'}},
+		{"trailing bytes", []byte{0x00, 0x01, 0x00, 0x05, 0x00, 0x01, 'a', 'b'}},
+		{"duplicate type", []byte{0x00, 0x02, 0x00, 0x05, 0x00, 0x01, 'a', 0x00, 0x05, 0x00, 0x01, 'b'}},
+		{"empty traceparent", []byte{0x00, 0x01, 0x00, 0x01, 0x00, 0x00}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := codec.DecodeServerHello(withBlock(tt.block...)); !errors.Is(err, qerrors.ErrInvalidMessage) {
+				t.Errorf("expected ErrInvalidMessage, got %v", err)
+			}
+		})
+	}
+
+	if _, err := codec.DecodeServerHello(withBlock(0x00, 0x01, 0x00, 0x05, 0x00, 0x01, 'a')); err != nil {
+		t.Errorf("well-formed block rejected: %v", err)
+	}
+}
+
+// decodeAllocBytes returns the bytes allocated per call of decode.
+func decodeAllocBytes(decode func()) uint64 {
+	const runs = 20
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	for range runs {
+		decode()
+	}
+	runtime.ReadMemStats(&after)
+	return (after.TotalAlloc - before.TotalAlloc) / runs
+}
+
+func TestDecodeHelloOversizedCounts(t *testing.T) {
+	codec := protocol.NewCodec()
+	kp, _ := chkem.GenerateKeyPair()
+
+	// clientHello builds a ClientHello with count cipher suites and an
+	// extensions block of extCount empty extensions, with all the bytes
+	// the counts claim actually present
+	clientHello := func(count, extCount int) []byte {
+		msg := []byte{byte(protocol.MessageTypeClientHello), 0, 0, 0, 0}
+		msg = append(msg, protocol.Current.Major, protocol.Current.Minor)
+		msg = append(msg, make([]byte, 32)...) // Random
+		msg = append(msg, 0)                   // SessionID length
+		msg = append(msg, byte(chkem.DefaultParameters))
+		msg = append(msg, kp.PublicKey().Bytes()...)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(count))
+		for range count {
+			msg = binary.BigEndian.AppendUint16(msg, uint16(constants.CipherSuiteAES256GCM))
+		}
+		if extCount > 0 {
+			msg = binary.BigEndian.AppendUint16(msg, 0) // Empty cookie
+			msg = binary.BigEndian.AppendUint16(msg, uint16(extCount))
+			for i := range extCount {
+				msg = binary.BigEndian.AppendUint16(msg, uint16(0x8000+i))
+				msg = binary.BigEndian.AppendUint16(msg, 0)
+			}
+		}
+		binary.BigEndian.PutUint32(msg[1:], uint32(len(msg)-protocol.HeaderSize))
+		return msg
+	}
+
+	// At the limits the hello decodes
+	valid := clientHello(protocol.MaxCipherSuites, protocol.MaxExtensions)
+	if _, err := codec.DecodeClientHello(valid); err != nil {
+		t.Fatalf("hello at the limits rejected: %v", err)
+	}
+	// A rejection must not cost much more than decoding a valid hello
+	limit := 2 * decodeAllocBytes(func() { _, _ = codec.DecodeClientHello(valid) })
+
+	tests := []struct {
+		name            string
+		count, extCount int
+	}{
+		{"cipher suites above limit", protocol.MaxCipherSuites + 1, 0},
+		{"cipher suites at uint16 max", 0xFFFF, 0},
+		{"extensions above limit", 1, protocol.MaxExtensions + 1},
+		{"extensions at uint16 max", 1, 0xFFFF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			msg := clientHello(tt.count, tt.extCount)
+			if _, err := codec.DecodeClientHello(msg); !errors.Is(err, qerrors.ErrInvalidMessage) {
+				t.Fatalf("expected ErrInvalidMessage, got %v", err)
+			}
+			if got := decodeAllocBytes(func() { _, _ = codec.DecodeClientHello(msg) }); got > limit {
+				t.Errorf("rejection allocated %d bytes, want at most %d", got, limit)
+			}
+		})
+	}
+
+	// Counts that claim more data than the message holds are rejected
+	// before allocating as well
+	for _, count := range []uint16{protocol.MaxCipherSuites, 0xFFFF} {
+		msg := clientHello(1, 0)
+		binary.BigEndian.PutUint16(msg[len(msg)-4:], count)
+		if _, err := codec.DecodeClientHello(msg); !errors.Is(err, qerrors.ErrInvalidMessage) {
+			t.Errorf("count %d: expected ErrInvalidMessage, got %v", count, err)
+		}
+	}
+	plain, _ := codec.EncodeServerHello(&protocol.ServerHello{
+		Version:         protocol.Current,
+		Random:          make([]byte, 32),
+		SessionID:       make([]byte, constants.SessionIDSize),
+		CHKEMCiphertext: make([]byte, chkem.DefaultParameters.CiphertextSize()),
+		CipherSuite:     constants.CipherSuiteAES256GCM,
+	})
+	for _, count := range []uint16{protocol.MaxExtensions, 0xFFFF} {
+		msg := binary.BigEndian.AppendUint16(append([]byte(nil), plain...), count)
+		msg = append(msg, 0x80, 0x00, 0x00, 0x00)
+		binary.BigEndian.PutUint32(msg[1:], uint32(len(msg)-protocol.HeaderSize))
+		if _, err := codec.DecodeServerHello(msg); !errors.Is(err, qerrors.ErrInvalidMessage) {
+			t.Errorf("extension count %d: expected ErrInvalidMessage, got %v", count, err)
+		}
+	}
+}
+
 // --- Finished Message Tests ---
 
 func TestEncodeDecodeFinished(t *testing.T) {
@@ -279,6 +729,57 @@ func TestDecodeFinishedInvalidInputs(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeServerFinishedIdentity(t *testing.T) {
+	codec := protocol.NewCodec()
+	verifyData := bytes.Repeat([]byte{0x01}, 32)
+
+	// Without identity the encoding matches EncodeFinished
+	plain, err := codec.EncodeServerFinished(&protocol.ServerFinished{VerifyData: verifyData})
+	if err != nil {
+		t.Fatalf("EncodeServerFinished failed: %v", err)
+	}
+	legacy, _ := codec.EncodeFinished(protocol.MessageTypeServerFinished, verifyData)
+	if !bytes.Equal(plain, legacy) {
+		t.Error("ServerFinished without identity should match EncodeFinished")
+	}
+
+	msg := &protocol.ServerFinished{
+		VerifyData:  verifyData,
+		IdentityKey: bytes.Repeat([]byte{0x02}, 32),
+		Signature:   bytes.Repeat([]byte{0x03}, 64),
+	}
+	encoded, err := codec.EncodeServerFinished(msg)
+	if err != nil {
+		t.Fatalf("EncodeServerFinished failed: %v", err)
+	}
+
+	decoded, err := codec.DecodeServerFinished(encoded)
+	if err != nil {
+		t.Fatalf("DecodeServerFinished failed: %v", err)
+	}
+	if !bytes.Equal(decoded.VerifyData, msg.VerifyData) ||
+		!bytes.Equal(decoded.IdentityKey, msg.IdentityKey) ||
+		!bytes.Equal(decoded.Signature, msg.Signature) {
+		t.Error("ServerFinished round trip mismatch")
+	}
+
+	// Peers without identity support still read the verify_data
+	if vd, err := codec.DecodeFinished(encoded); err != nil || !bytes.Equal(vd, verifyData) {
+		t.Errorf("DecodeFinished should accept an identity ServerFinished, got %v", err)
+	}
+
+	// Truncated identity and a signature without a key are rejected
+	truncated := append([]byte(nil), encoded[:len(encoded)-1]...)
+	binary.BigEndian.PutUint32(truncated[1:], uint32(len(truncated)-protocol.HeaderSize))
+	if _, err := codec.DecodeServerFinished(truncated); !errors.Is(err, qerrors.ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage for truncated identity, got %v", err)
+	}
+	msg.IdentityKey = nil
+	if _, err := codec.EncodeServerFinished(msg); !errors.Is(err, qerrors.ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage for signature without key, got %v", err)
+	}
+}
+
 // --- Data Message Tests ---
 
 func TestEncodeDecodeData(t *testing.T) {
@@ -336,6 +837,38 @@ func TestEncodeDataTooLarge(t *testing.T) {
 	}
 }
 
+func TestEncodeDataInto(t *testing.T) {
+	codec := protocol.NewCodec()
+	payload := []byte("pooled payload")
+
+	want, _ := codec.EncodeData(7, payload)
+
+	// Appends after existing contents
+	got, err := codec.EncodeDataInto([]byte("prefix"), 7, payload)
+	if err != nil {
+		t.Fatalf("EncodeDataInto failed: %v", err)
+	}
+	if !bytes.Equal(got, append([]byte("prefix"), want...)) {
+		t.Error("EncodeDataInto should append the encoded message")
+	}
+
+	// Reuses a buffer with enough capacity
+	buf := make([]byte, 0, 64)
+	allocs := testing.AllocsPerRun(10, func() {
+		got, _ = codec.EncodeDataInto(buf[:0], 7, payload)
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations with a large enough buffer, got %v", allocs)
+	}
+	if !bytes.Equal(got, want) || &got[0] != &buf[:1][0] {
+		t.Error("EncodeDataInto should encode into the provided buffer")
+	}
+
+	if _, err := codec.EncodeDataInto(nil, 0, make([]byte, constants.MaxPayloadSize+1)); !errors.Is(err, qerrors.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+}
+
 func TestDecodeDataInvalidInputs(t *testing.T) {
 	codec := protocol.NewCodec()
 
@@ -359,6 +892,40 @@ func TestDecodeDataInvalidInputs(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeDataHeader(t *testing.T) {
+	codec := protocol.NewCodec()
+	payload := []byte("sealed stream payload")
+
+	encoded, err := codec.EncodeDataHeaderInto(nil, 42, payload)
+	if err != nil {
+		t.Fatalf("EncodeDataHeaderInto failed: %v", err)
+	}
+	if protocol.MessageType(encoded[0]) != protocol.MessageTypeDataHeader {
+		t.Errorf("wrong message type: got %d, want %d", encoded[0], protocol.MessageTypeDataHeader)
+	}
+
+	seq, gotPayload, err := codec.DecodeDataHeader(encoded)
+	if err != nil {
+		t.Fatalf("DecodeDataHeader failed: %v", err)
+	}
+	if seq != 42 || !bytes.Equal(gotPayload, payload) {
+		t.Errorf("decoded (%d, %q), want (42, %q)", seq, gotPayload, payload)
+	}
+
+	if _, err := codec.EncodeDataHeaderInto(nil, 0, make([]byte, constants.MaxPayloadSize+1)); !errors.Is(err, qerrors.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+
+	for name, data := range map[string][]byte{
+		"truncated seq": encoded[:protocol.HeaderSize+7],
+		"data message":  append([]byte{byte(protocol.MessageTypeData)}, encoded[1:]...),
+	} {
+		if _, _, err := codec.DecodeDataHeader(data); !errors.Is(err, qerrors.ErrInvalidMessage) {
+			t.Errorf("%s: expected ErrInvalidMessage, got %v", name, err)
+		}
+	}
+}
+
 // --- Alert Message Tests ---
 
 func TestEncodeDecodeAlert(t *testing.T) {
@@ -568,6 +1135,43 @@ func TestReadMessageMultiple(t *testing.T) {
 	}
 }
 
+func TestReadMessageInto(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	small, _ := codec.EncodeData(1, []byte("small"))
+	large, _ := codec.EncodeData(2, make([]byte, 512))
+
+	var stream bytes.Buffer
+	stream.Write(small)
+	stream.Write(large)
+	stream.Write(small)
+
+	// A buffer that is too small is replaced by a fresh one
+	buf := make([]byte, 0, 32)
+	read, err := codec.ReadMessageInto(&stream, buf)
+	if err != nil || !bytes.Equal(read, small) {
+		t.Fatalf("ReadMessageInto 1: got %x (err=%v)", read, err)
+	}
+	if &read[0] != &buf[:1][0] {
+		t.Error("message fitting the buffer should be read into it")
+	}
+
+	read, err = codec.ReadMessageInto(&stream, buf)
+	if err != nil || !bytes.Equal(read, large) {
+		t.Fatalf("ReadMessageInto 2: message mismatch (err=%v)", err)
+	}
+	if cap(read) <= cap(buf) {
+		t.Error("oversized message should be read into a new buffer")
+	}
+
+	// ...which can be reused for later messages
+	grown := read[:0]
+	read, err = codec.ReadMessageInto(&stream, grown)
+	if err != nil || !bytes.Equal(read, small) || &read[0] != &grown[:1][0] {
+		t.Errorf("ReadMessageInto 3: got %x (err=%v)", read, err)
+	}
+}
+
 func TestReadMessageTooLarge(t *testing.T) {
 	codec := protocol.NewCodec()
 
@@ -673,6 +1277,33 @@ func TestClientHelloValidation(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "too many cipher suites",
+			modify: func(m *protocol.ClientHello) {
+				m.CipherSuites = make([]constants.CipherSuite, protocol.MaxCipherSuites+1)
+				for i := range m.CipherSuites {
+					m.CipherSuites[i] = constants.CipherSuiteAES256GCM
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "too many extensions",
+			modify: func(m *protocol.ClientHello) {
+				m.Extensions = make(map[uint16][]byte)
+				for i := range protocol.MaxExtensions + 1 {
+					m.Extensions[uint16(0x7F00+i)] = nil
+				}
+			},
+			wantErr: true,
+		},
+		{
+			name: "session ID too long",
+			modify: func(m *protocol.ClientHello) {
+				m.SessionID = make([]byte, protocol.MaxSessionIDSize+1)
+			},
+			wantErr: true,
+		},
 	}
 
 	for _, tc := range tests {
@@ -771,6 +1402,32 @@ func TestServerHelloValidation(t *testing.T) {
 	}
 }
 
+func TestHelloKEMSizeErrors(t *testing.T) {
+	kp, _ := chkem.GenerateKeyPair()
+	ct, _, _ := chkem.Encapsulate(kp.PublicKey())
+
+	clientHello := &protocol.ClientHello{
+		Version:        protocol.Current,
+		Random:         make([]byte, 32),
+		CHKEMPublicKey: kp.PublicKey().Bytes()[1:],
+		CipherSuites:   []constants.CipherSuite{constants.CipherSuiteAES256GCM},
+	}
+	if err := clientHello.Validate(); !errors.Is(err, qerrors.ErrInvalidPublicKeySize) {
+		t.Errorf("ClientHello: expected ErrInvalidPublicKeySize, got %v", err)
+	}
+
+	serverHello := &protocol.ServerHello{
+		Version:         protocol.Current,
+		Random:          make([]byte, 32),
+		SessionID:       make([]byte, constants.SessionIDSize),
+		CHKEMCiphertext: ct.Bytes()[1:],
+		CipherSuite:     constants.CipherSuiteAES256GCM,
+	}
+	if err := serverHello.Validate(); !errors.Is(err, qerrors.ErrInvalidCiphertextSize) {
+		t.Errorf("ServerHello: expected ErrInvalidCiphertextSize, got %v", err)
+	}
+}
+
 // --- Version Tests ---
 
 func TestVersionCompatibility(t *testing.T) {
@@ -853,6 +1510,9 @@ func TestMessageTypeString(t *testing.T) {
 		{protocol.MessageTypePing, "Ping"},
 		{protocol.MessageTypePong, "Pong"},
 		{protocol.MessageTypeClose, "Close"},
+		{protocol.MessageTypeCloseWrite, "CloseWrite"},
+		{protocol.MessageTypeRatchet, "Ratchet"},
+		{protocol.MessageTypeDataHeader, "DataHeader"},
 		{protocol.MessageTypeAlert, "Alert"},
 		{protocol.MessageType(0xFF), "Unknown"},
 	}
@@ -930,25 +1590,29 @@ func TestVersionString(t *testing.T) {
 func TestSupportedCipherSuites(t *testing.T) {
 	suites := protocol.SupportedCipherSuites()
 
-	// In FIPS mode, only AES-256-GCM is available
-	// In standard mode, both cipher suites are available
+	// In FIPS mode, only the AES-GCM suites are available
+	// In standard mode, all cipher suites are available
 	if crypto.FIPSMode() {
-		if len(suites) != 1 {
-			t.Errorf("FIPS mode: SupportedCipherSuites length: got %d, want 1", len(suites))
+		if len(suites) != 2 {
+			t.Errorf("FIPS mode: SupportedCipherSuites length: got %d, want 2", len(suites))
 		}
 	} else {
-		if len(suites) != 2 {
-			t.Errorf("Standard mode: SupportedCipherSuites length: got %d, want 2", len(suites))
+		if len(suites) != 3 {
+			t.Errorf("Standard mode: SupportedCipherSuites length: got %d, want 3", len(suites))
 		}
 	}
 
 	// Check that expected suites are present
 	hasAES := false
+	hasAES128 := false
 	hasChaCha := false
 	for _, s := range suites {
 		if s == constants.CipherSuiteAES256GCM {
 			hasAES = true
 		}
+		if s == constants.CipherSuiteAES128GCM {
+			hasAES128 = true
+		}
 		if s == constants.CipherSuiteChaCha20Poly1305 {
 			hasChaCha = true
 		}
@@ -957,6 +1621,9 @@ func TestSupportedCipherSuites(t *testing.T) {
 	if !hasAES {
 		t.Error("SupportedCipherSuites missing AES-256-GCM")
 	}
+	if !hasAES128 {
+		t.Error("SupportedCipherSuites missing AES-128-GCM")
+	}
 
 	if crypto.FIPSMode() {
 		if hasChaCha {
@@ -978,6 +1645,30 @@ func TestSupportedCipherSuites(t *testing.T) {
 	}
 }
 
+func TestDefaultCipherSuites(t *testing.T) {
+	suites := protocol.DefaultCipherSuites()
+	if len(suites) == 0 || suites[0] != protocol.PreferredCipherSuite() {
+		t.Errorf("DefaultCipherSuites = %v, want %v first", suites, protocol.PreferredCipherSuite())
+	}
+
+	// Every default suite is supported, but AES-128-GCM is opt-in
+	supported := protocol.SupportedCipherSuites()
+	for _, s := range suites {
+		if s == constants.CipherSuiteAES128GCM {
+			t.Error("DefaultCipherSuites should not offer AES-128-GCM")
+		}
+		found := false
+		for _, sup := range supported {
+			if s == sup {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("default cipher suite %v is not supported", s)
+		}
+	}
+}
+
 func TestPreferredCipherSuite(t *testing.T) {
 	preferred := protocol.PreferredCipherSuite()
 
@@ -1058,17 +1749,21 @@ func TestEncodeDecodeRekeyPayload(t *testing.T) {
 	var activationSeq uint64 = 12345
 
 	// Encode inner payload
-	payload, err := codec.EncodeRekeyPayload(publicKey, activationSeq)
+	payload, err := codec.EncodeRekeyPayload(protocol.RekeyKindRequest, publicKey, activationSeq)
 	if err != nil {
 		t.Fatalf("EncodeRekeyPayload failed: %v", err)
 	}
 
 	// Decode inner payload
-	decodedKey, decodedSeq, err := codec.DecodeRekeyPayload(payload)
+	kind, decodedKey, decodedSeq, err := codec.DecodeRekeyPayload(payload)
 	if err != nil {
 		t.Fatalf("DecodeRekeyPayload failed: %v", err)
 	}
 
+	if kind != protocol.RekeyKindRequest {
+		t.Errorf("decoded kind: got %d, want %d", kind, protocol.RekeyKindRequest)
+	}
+
 	if !bytes.Equal(publicKey, decodedKey) {
 		t.Error("decoded public key doesn't match")
 	}
@@ -1078,6 +1773,31 @@ func TestEncodeDecodeRekeyPayload(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeDirectionalRekeyPayload(t *testing.T) {
+	codec := protocol.NewCodec()
+	publicKey := make([]byte, constants.CHKEMPublicKeySize)
+
+	for _, kind := range []protocol.RekeyKind{protocol.RekeyKindRequestSend, protocol.RekeyKindRequestReceive} {
+		if !kind.IsRequest() {
+			t.Errorf("kind %d is not a request", kind)
+		}
+		payload, err := codec.EncodeRekeyPayload(kind, publicKey, 7)
+		if err != nil {
+			t.Fatalf("EncodeRekeyPayload failed: %v", err)
+		}
+		decodedKind, _, decodedSeq, err := codec.DecodeRekeyPayload(payload)
+		if err != nil {
+			t.Fatalf("DecodeRekeyPayload failed: %v", err)
+		}
+		if decodedKind != kind || decodedSeq != 7 {
+			t.Errorf("decoded (%d, %d), want (%d, 7)", decodedKind, decodedSeq, kind)
+		}
+	}
+	if protocol.RekeyKindResponse.IsRequest() {
+		t.Error("a rekey response is not a request")
+	}
+}
+
 func TestEncodeDecodeRekey(t *testing.T) {
 	codec := protocol.NewCodec()
 
@@ -1110,13 +1830,85 @@ func TestEncodeDecodeRekey(t *testing.T) {
 	}
 }
 
+func TestEncodeDecodeCloseWrite(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	ciphertext := []byte("encrypted-close-write")
+	encoded, err := codec.EncodeCloseWrite(7, ciphertext)
+	if err != nil {
+		t.Fatalf("EncodeCloseWrite failed: %v", err)
+	}
+	if protocol.MessageType(encoded[0]) != protocol.MessageTypeCloseWrite {
+		t.Errorf("wrong message type: got %d, want %d", encoded[0], protocol.MessageTypeCloseWrite)
+	}
+
+	seq, decodedCT, err := codec.DecodeCloseWrite(encoded)
+	if err != nil {
+		t.Fatalf("DecodeCloseWrite failed: %v", err)
+	}
+	if seq != 7 || !bytes.Equal(decodedCT, ciphertext) {
+		t.Errorf("decoded (%d, %q), want (7, %q)", seq, decodedCT, ciphertext)
+	}
+
+	// A rekey message is not a close-write
+	rekey, _ := codec.EncodeRekey(7, ciphertext)
+	if _, _, err := codec.DecodeCloseWrite(rekey); err == nil {
+		t.Error("expected error decoding a rekey message as close-write")
+	}
+}
+
+func TestEncodeDecodeRatchet(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	payload := codec.EncodeRatchetPayload(1 << 40)
+	activationSeq, err := codec.DecodeRatchetPayload(payload)
+	if err != nil {
+		t.Fatalf("DecodeRatchetPayload failed: %v", err)
+	}
+	if activationSeq != 1<<40 {
+		t.Errorf("activation sequence = %d, want %d", activationSeq, uint64(1<<40))
+	}
+	if _, err := codec.DecodeRatchetPayload(payload[:7]); err == nil {
+		t.Error("expected error for a short ratchet payload")
+	}
+
+	ciphertext := []byte("encrypted-ratchet")
+	encoded, err := codec.EncodeRatchet(9, ciphertext)
+	if err != nil {
+		t.Fatalf("EncodeRatchet failed: %v", err)
+	}
+	seq, decodedCT, err := codec.DecodeRatchet(encoded)
+	if err != nil {
+		t.Fatalf("DecodeRatchet failed: %v", err)
+	}
+	if seq != 9 || !bytes.Equal(decodedCT, ciphertext) {
+		t.Errorf("decoded (%d, %q), want (9, %q)", seq, decodedCT, ciphertext)
+	}
+
+	// A rekey message is not a ratchet
+	rekey, _ := codec.EncodeRekey(9, ciphertext)
+	if _, _, err := codec.DecodeRatchet(rekey); err == nil {
+		t.Error("expected error decoding a rekey message as ratchet")
+	}
+}
+
 func TestEncodeRekeyPayloadInvalidKey(t *testing.T) {
 	codec := protocol.NewCodec()
 
 	// Try with invalid key size
-	_, err := codec.EncodeRekeyPayload([]byte("short"), 100)
+	_, err := codec.EncodeRekeyPayload(protocol.RekeyKindRequest, []byte("short"), 100)
+	if !errors.Is(err, qerrors.ErrInvalidPublicKeySize) {
+		t.Errorf("expected ErrInvalidPublicKeySize for a short request key, got %v", err)
+	}
+	_, err = codec.EncodeRekeyPayload(protocol.RekeyKindResponse, []byte("short"), 100)
+	if !errors.Is(err, qerrors.ErrInvalidCiphertextSize) {
+		t.Errorf("expected ErrInvalidCiphertextSize for a short response ciphertext, got %v", err)
+	}
+
+	// Try with unknown kind
+	_, err = codec.EncodeRekeyPayload(protocol.RekeyKind(0x7F), make([]byte, constants.CHKEMPublicKeySize), 100)
 	if err == nil {
-		t.Error("expected error for invalid key size")
+		t.Error("expected error for unknown kind")
 	}
 }
 
@@ -1130,11 +1922,12 @@ func TestDecodeRekeyPayloadInvalid(t *testing.T) {
 		{"empty", []byte{}},
 		{"too short for public key", make([]byte, 100)},
 		{"public key only no seq", make([]byte, constants.CHKEMPublicKeySize)},
+		{"unknown kind", append([]byte{0x7F}, make([]byte, constants.CHKEMPublicKeySize+8)...)},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			_, _, err := codec.DecodeRekeyPayload(tc.data)
+			_, _, _, err := codec.DecodeRekeyPayload(tc.data)
 			if err == nil {
 				t.Error("expected error for invalid input")
 			}
@@ -1162,3 +1955,22 @@ func TestDecodeRekeyInvalid(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionNegotiate(t *testing.T) {
+	older := protocol.Version{Major: protocol.Current.Major - 1, Minor: 9}
+	newer := protocol.Version{Major: protocol.Current.Major, Minor: protocol.Current.Minor + 1}
+
+	tests := []struct {
+		offered protocol.Version
+		want    protocol.Version
+	}{
+		{newer, protocol.Current},
+		{protocol.Current, protocol.Current},
+		{older, older},
+	}
+	for _, tt := range tests {
+		if got := protocol.Negotiate(tt.offered); got != tt.want {
+			t.Errorf("Negotiate(%v) = %v, want %v", tt.offered, got, tt.want)
+		}
+	}
+}
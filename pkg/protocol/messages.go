@@ -6,6 +6,9 @@
 //	    |                                      |
 //	    | -------- ClientHello --------------> |
 //	    |                                      |
+//	    | <------- HelloRetryRequest --------- |  (optional, carries cookie)
+//	    | -------- ClientHello + cookie -----> |
+//	    |                                      |
 //	    | <------- ServerHello --------------- |
 //	    |                                      |
 //	    | -------- ClientFinished -----------> |
@@ -18,8 +21,11 @@
 package protocol
 
 import (
+	"crypto/ed25519"
+
 	"github.com/sara-star-quant/quantum-go/internal/constants"
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
 )
 
 // MessageType identifies the type of protocol message.
@@ -35,6 +41,8 @@ const (
 	MessageTypeClientFinished MessageType = 0x03
 	// MessageTypeServerFinished confirms handshake completion from server.
 	MessageTypeServerFinished MessageType = 0x04
+	// MessageTypeHelloRetryRequest asks the client to resend ClientHello with a cookie.
+	MessageTypeHelloRetryRequest MessageType = 0x05
 
 	// MessageTypeData carries encrypted application data.
 	MessageTypeData MessageType = 0x10
@@ -46,6 +54,13 @@ const (
 	MessageTypePong MessageType = 0x13
 	// MessageTypeClose signals graceful connection termination.
 	MessageTypeClose MessageType = 0x14
+	// MessageTypeCloseWrite signals that the sender will send no more data.
+	MessageTypeCloseWrite MessageType = 0x15
+	// MessageTypeRatchet initiates a symmetric key rotation without a KEM exchange.
+	MessageTypeRatchet MessageType = 0x16
+	// MessageTypeDataHeader carries encrypted application data with an
+	// application header framed inside the encrypted payload.
+	MessageTypeDataHeader MessageType = 0x17
 
 	// MessageTypeAlert signals an error condition.
 	MessageTypeAlert MessageType = 0xF0
@@ -62,6 +77,8 @@ func (mt MessageType) String() string {
 		return "ClientFinished"
 	case MessageTypeServerFinished:
 		return "ServerFinished"
+	case MessageTypeHelloRetryRequest:
+		return "HelloRetryRequest"
 	case MessageTypeData:
 		return "Data"
 	case MessageTypeRekey:
@@ -72,6 +89,12 @@ func (mt MessageType) String() string {
 		return "Pong"
 	case MessageTypeClose:
 		return "Close"
+	case MessageTypeCloseWrite:
+		return "CloseWrite"
+	case MessageTypeRatchet:
+		return "Ratchet"
+	case MessageTypeDataHeader:
+		return "DataHeader"
 	case MessageTypeAlert:
 		return "Alert"
 	default:
@@ -113,11 +136,20 @@ type ClientHello struct {
 	// SessionID for session resumption (16 bytes, or empty for new session)
 	SessionID []byte
 
-	// Client's CH-KEM public key (1600 bytes)
+	// KEM parameter set of the public key (zero means chkem.DefaultParameters)
+	KEMParameters chkem.Parameters
+
+	// Client's CH-KEM public key (1600 bytes, or 1216 for CHKEM768)
 	CHKEMPublicKey []byte
 
 	// Supported cipher suites in preference order
 	CipherSuites []constants.CipherSuite
+
+	// Cookie echoed from a HelloRetryRequest (empty on the first attempt)
+	Cookie []byte
+
+	// Optional extensions by type; unknown types are ignored
+	Extensions map[uint16][]byte
 }
 
 // ServerHello is sent by the responder in response to ClientHello.
@@ -131,11 +163,31 @@ type ServerHello struct {
 	// SessionID assigned by server (16 bytes)
 	SessionID []byte
 
-	// CH-KEM ciphertext (1600 bytes)
+	// KEM parameter set accepted by the server (zero means chkem.DefaultParameters)
+	KEMParameters chkem.Parameters
+
+	// CH-KEM ciphertext (1600 bytes, or 1120 for CHKEM768, empty when Resumed)
 	CHKEMCiphertext []byte
 
 	// Selected cipher suite
 	CipherSuite constants.CipherSuite
+
+	// Resumed marks an abbreviated handshake: the server accepted the
+	// client's SessionID and skipped the CH-KEM exchange
+	Resumed bool
+
+	// Optional extensions by type; unknown types are ignored
+	Extensions map[uint16][]byte
+}
+
+// HelloRetryRequest is sent by the responder to make the client prove it can
+// receive at its address before any CH-KEM work is done.
+type HelloRetryRequest struct {
+	// Protocol version of the server
+	Version Version
+
+	// Stateless cookie the client must echo in its next ClientHello
+	Cookie []byte
 }
 
 // ClientFinished confirms the handshake from the client side.
@@ -150,6 +202,13 @@ type ClientFinished struct {
 type ServerFinished struct {
 	// VerifyData is a MAC over the handshake transcript
 	VerifyData []byte
+
+	// IdentityKey is the responder's Ed25519 identity public key (optional)
+	IdentityKey []byte
+
+	// Signature is the identity key's signature over the transcript,
+	// present if and only if IdentityKey is
+	Signature []byte
 }
 
 // DataMessage carries encrypted application data.
@@ -170,6 +229,30 @@ type RekeyMessage struct {
 	ActivationSequence uint64
 }
 
+// RekeyKind distinguishes a rekey request from a rekey response. CH-KEM-1024
+// public keys and ciphertexts have the same size, so the payload carries the
+// kind explicitly.
+type RekeyKind uint8
+
+// Rekey payload kinds.
+const (
+	// RekeyKindRequest carries a fresh CH-KEM public key.
+	RekeyKindRequest RekeyKind = 0x01
+	// RekeyKindResponse carries a CH-KEM ciphertext for the requester's key.
+	RekeyKindResponse RekeyKind = 0x02
+	// RekeyKindRequestSend is a request that rotates only the keys of the
+	// records the requester sends.
+	RekeyKindRequestSend RekeyKind = 0x03
+	// RekeyKindRequestReceive is a request that rotates only the keys of the
+	// records the requester receives.
+	RekeyKindRequestReceive RekeyKind = 0x04
+)
+
+// IsRequest reports whether k is one of the rekey request kinds.
+func (k RekeyKind) IsRequest() bool {
+	return k == RekeyKindRequest || k == RekeyKindRequestSend || k == RekeyKindRequestReceive
+}
+
 // AlertLevel indicates the severity of the alert.
 type AlertLevel uint8
 
@@ -204,6 +287,22 @@ func (m *AlertMessage) Validate() error {
 	return nil
 }
 
+// Parameters returns the effective KEM parameter set of the ClientHello.
+func (m *ClientHello) Parameters() chkem.Parameters {
+	if m.KEMParameters == 0 {
+		return chkem.DefaultParameters
+	}
+	return m.KEMParameters
+}
+
+// Parameters returns the effective KEM parameter set of the ServerHello.
+func (m *ServerHello) Parameters() chkem.Parameters {
+	if m.KEMParameters == 0 {
+		return chkem.DefaultParameters
+	}
+	return m.KEMParameters
+}
+
 // Validate checks if the ClientHello message is valid.
 func (m *ClientHello) Validate() error {
 	if !m.Version.IsCompatible(Current) {
@@ -212,15 +311,24 @@ func (m *ClientHello) Validate() error {
 	if len(m.Random) != 32 {
 		return qerrors.ErrInvalidMessage
 	}
-	if len(m.CHKEMPublicKey) != constants.CHKEMPublicKeySize {
-		return qerrors.ErrInvalidPublicKey
+	if !m.Parameters().IsSupported() {
+		return qerrors.ErrUnsupportedKEMParameters
+	}
+	if len(m.CHKEMPublicKey) != m.Parameters().PublicKeySize() {
+		return qerrors.ErrInvalidPublicKeySize
 	}
-	if len(m.SessionID) > 2048 {
+	if len(m.SessionID) > MaxSessionIDSize {
 		return qerrors.ErrInvalidMessage
 	}
-	if len(m.CipherSuites) == 0 {
+	if len(m.CipherSuites) == 0 || len(m.CipherSuites) > MaxCipherSuites {
 		return qerrors.ErrInvalidMessage
 	}
+	if len(m.Cookie) > MaxCookieSize {
+		return qerrors.ErrInvalidMessage
+	}
+	if err := validateExtensions(m.Extensions); err != nil {
+		return err
+	}
 	for _, cs := range m.CipherSuites {
 		if !cs.IsSupported() {
 			return qerrors.ErrUnsupportedCipherSuite
@@ -237,15 +345,62 @@ func (m *ServerHello) Validate() error {
 	if len(m.Random) != 32 {
 		return qerrors.ErrInvalidMessage
 	}
-	if len(m.SessionID) > 2048 {
+	if len(m.SessionID) > MaxSessionIDSize {
 		return qerrors.ErrInvalidMessage
 	}
-	if len(m.CHKEMCiphertext) != constants.CHKEMCiphertextSize {
-		return qerrors.ErrInvalidCiphertext
+	if !m.Parameters().IsSupported() {
+		return qerrors.ErrUnsupportedKEMParameters
+	}
+	if m.Resumed {
+		if len(m.SessionID) == 0 || len(m.CHKEMCiphertext) != 0 {
+			return qerrors.ErrInvalidMessage
+		}
+	} else if len(m.CHKEMCiphertext) != m.Parameters().CiphertextSize() {
+		return qerrors.ErrInvalidCiphertextSize
 	}
 	if !m.CipherSuite.IsSupported() {
 		return qerrors.ErrUnsupportedCipherSuite
 	}
+	return validateExtensions(m.Extensions)
+}
+
+// validateExtensions checks that extensions fit the wire format and that
+// known extensions are well-formed.
+func validateExtensions(extensions map[uint16][]byte) error {
+	if len(extensions) > MaxExtensions {
+		return qerrors.ErrInvalidMessage
+	}
+	for _, value := range extensions {
+		if len(value) > MaxExtensionSize {
+			return qerrors.ErrInvalidMessage
+		}
+	}
+	if tp, ok := extensions[ExtensionTraceParent]; ok && (len(tp) == 0 || len(tp) > MaxTraceParentSize) {
+		return qerrors.ErrInvalidMessage
+	}
+	if c, ok := extensions[ExtensionCompression]; ok && (len(c) == 0 || len(c) > MaxCompressionOffers) {
+		return qerrors.ErrInvalidMessage
+	}
+	if p, ok := extensions[ExtensionPadding]; ok && len(p) != 0 {
+		return qerrors.ErrInvalidMessage
+	}
+	if t, ok := extensions[ExtensionAuthToken]; ok && len(t) == 0 {
+		return qerrors.ErrInvalidMessage
+	}
+	if k, ok := extensions[ExtensionKeyCommitment]; ok && len(k) != 0 {
+		return qerrors.ErrInvalidMessage
+	}
+	return nil
+}
+
+// Validate checks if the HelloRetryRequest message is valid.
+func (m *HelloRetryRequest) Validate() error {
+	if !m.Version.IsCompatible(Current) {
+		return qerrors.ErrUnsupportedVersion
+	}
+	if len(m.Cookie) == 0 || len(m.Cookie) > MaxCookieSize {
+		return qerrors.ErrInvalidMessage
+	}
 	return nil
 }
 
@@ -262,6 +417,12 @@ func (m *ServerFinished) Validate() error {
 	if len(m.VerifyData) != 32 {
 		return qerrors.ErrInvalidMessage
 	}
+	if len(m.IdentityKey) == 0 && len(m.Signature) == 0 {
+		return nil
+	}
+	if len(m.IdentityKey) != ed25519.PublicKeySize || len(m.Signature) != ed25519.SignatureSize {
+		return qerrors.ErrInvalidMessage
+	}
 	return nil
 }
 
@@ -270,3 +431,79 @@ const HeaderSize = 5 // 1 byte type + 4 bytes length
 
 // MaxMessageSize is the maximum size of a protocol message.
 const MaxMessageSize = constants.MaxMessageSize
+
+// MaxDataHeaderSize is the maximum size of the application header carried
+// by a DataHeader message.
+const MaxDataHeaderSize = 255
+
+// MinClientHelloPayloadSize is the smallest ClientHello payload: version(2) +
+// random(32) + sessionIDLen(1) + kemParams(1) + publicKey(1216) +
+// cipherSuiteCount(2) + one cipher suite(2).
+const MinClientHelloPayloadSize = 2 + 32 + 1 + 1 + constants.CHKEM768PublicKeySize + 2 + 2
+
+// MinServerHelloPayloadSize is the smallest ServerHello payload, that of a
+// resumed hello without ciphertext: version(2) + random(32) +
+// sessionIDLen(1) + kemParams(1) + cipherSuite(2).
+const MinServerHelloPayloadSize = 2 + 32 + 1 + 1 + 2
+
+// MaxSessionIDSize is the maximum size of a hello session ID, bounded by its
+// one-byte length prefix.
+const MaxSessionIDSize = 0xFF
+
+// MaxCipherSuites is the maximum number of cipher suites a ClientHello may
+// offer. Decoders reject larger counts before allocating.
+const MaxCipherSuites = 32
+
+// MaxCookieSize is the maximum size of a HelloRetryRequest cookie.
+const MaxCookieSize = 255
+
+// MaxTraceParentSize is the maximum size of a traceparent extension.
+const MaxTraceParentSize = 255
+
+// Hello extension types.
+const (
+	// ExtensionTraceParent carries the W3C traceparent of the initiator's
+	// handshake span. Sent in the clear but covered by the Finished
+	// verify_data.
+	ExtensionTraceParent uint16 = 0x0001
+
+	// ExtensionEarlyData has an empty value. In a ClientHello it announces
+	// that a 0-RTT early data record follows the hello; in a ServerHello it
+	// confirms the responder accepted that record.
+	ExtensionEarlyData uint16 = 0x0002
+
+	// ExtensionCompression lists, in a ClientHello, the record compression
+	// algorithms the initiator offers, one byte each in order of
+	// preference. In a ServerHello it holds the single algorithm the
+	// responder selected. Without it records are not compressed.
+	ExtensionCompression uint16 = 0x0003
+
+	// ExtensionPadding has an empty value. In a ClientHello it announces
+	// that the initiator pads records; in a ServerHello it confirms both
+	// peers pad. Without it records are not padded.
+	ExtensionPadding uint16 = 0x0004
+
+	// ExtensionAuthToken carries, in a ClientHello, an application-defined
+	// token for the responder's authorization hook, such as a bearer token
+	// or a signed device assertion. Sent in the clear but covered by the
+	// Finished verify_data, so it must not be a reusable secret unless the
+	// responder also binds it to the connection.
+	ExtensionAuthToken uint16 = 0x0005
+
+	// ExtensionKeyCommitment has an empty value. In a ClientHello it
+	// announces that the initiator uses key-committing traffic ciphers; in
+	// a ServerHello it confirms the responder does too. Peers whose
+	// settings differ fail the handshake.
+	ExtensionKeyCommitment uint16 = 0x0006
+)
+
+// MaxCompressionOffers is the maximum number of compression algorithms a
+// ClientHello may offer.
+const MaxCompressionOffers = 16
+
+// MaxExtensionSize is the maximum size of an extension value.
+const MaxExtensionSize = 0xFFFF
+
+// MaxExtensions is the maximum number of extensions in a hello. Decoders
+// reject larger counts before allocating.
+const MaxExtensions = 16
@@ -0,0 +1,349 @@
+package protocol
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// Direction is the direction of a recorded message relative to the local
+// endpoint.
+type Direction uint8
+
+// Message directions.
+const (
+	DirectionSent     Direction = 0
+	DirectionReceived Direction = 1
+)
+
+// String returns "sent" or "received".
+func (d Direction) String() string {
+	if d == DirectionReceived {
+		return "received"
+	}
+	return "sent"
+}
+
+// RecordedMessage describes one message seen on a connection. It holds only
+// header information; payloads, and so any key material or plaintext, are
+// never recorded.
+type RecordedMessage struct {
+	Time       time.Time
+	Direction  Direction
+	Type       MessageType
+	Length     int // Total wire length, including the header
+	LocalAddr  string
+	RemoteAddr string
+}
+
+// Recorder receives a RecordedMessage for every message sent or received on
+// a connection wrapped by RecordConn or RecordDatagramConn. Record is
+// called from the goroutines reading and writing the connection, so it
+// must be safe for concurrent use and should not block.
+type Recorder interface {
+	Record(msg RecordedMessage)
+}
+
+// RecorderFunc adapts a function to a Recorder.
+type RecorderFunc func(msg RecordedMessage)
+
+// Record calls f(msg).
+func (f RecorderFunc) Record(msg RecordedMessage) {
+	f(msg)
+}
+
+// RecordConn returns conn wrapped so that every message written to or read
+// from it is reported to rec. conn must carry a stream of messages in the
+// wire format described in codec.go; messages may be split across or
+// combined in reads and writes.
+func RecordConn(conn net.Conn, rec Recorder) net.Conn {
+	return newRecordingConn(conn, rec, false)
+}
+
+// RecordDatagramConn is like RecordConn for connections where each read and
+// write is a single datagram holding exactly one message.
+func RecordDatagramConn(conn net.Conn, rec Recorder) net.Conn {
+	return newRecordingConn(conn, rec, true)
+}
+
+func newRecordingConn(conn net.Conn, rec Recorder, datagram bool) *recordingConn {
+	c := &recordingConn{Conn: conn, rec: rec, datagram: datagram}
+	if addr := conn.LocalAddr(); addr != nil {
+		c.local = addr.String()
+	}
+	if addr := conn.RemoteAddr(); addr != nil {
+		c.remote = addr.String()
+	}
+	return c
+}
+
+// recordingConn reports the messages passing through a net.Conn.
+type recordingConn struct {
+	net.Conn
+	rec           Recorder
+	datagram      bool
+	local, remote string
+
+	readMu, writeMu sync.Mutex
+	read, written   messageScanner
+}
+
+func (c *recordingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.readMu.Lock()
+		c.scan(&c.read, b[:n], DirectionReceived)
+		c.readMu.Unlock()
+	}
+	return n, err
+}
+
+func (c *recordingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	if n > 0 {
+		c.writeMu.Lock()
+		c.scan(&c.written, b[:n], DirectionSent)
+		c.writeMu.Unlock()
+	}
+	return n, err
+}
+
+// scan reports the messages whose headers complete in p.
+func (c *recordingConn) scan(s *messageScanner, p []byte, dir Direction) {
+	if c.datagram {
+		*s = messageScanner{}
+	}
+	s.scan(p, func(msgType MessageType, length int) {
+		c.rec.Record(RecordedMessage{
+			Time:       time.Now(),
+			Direction:  dir,
+			Type:       msgType,
+			Length:     length,
+			LocalAddr:  c.local,
+			RemoteAddr: c.remote,
+		})
+	})
+}
+
+// messageScanner finds message boundaries in one direction of a stream,
+// looking only at message headers.
+//
+// Over streams the handshake also sends encrypted records framed by a bare
+// 4-byte length: early data after a ClientHello offering it, then the
+// ClientFinished and ServerFinished. Message types are never zero, while a
+// record length is below MaxMessageSize and so starts with a zero byte,
+// which tells the two apart at a message boundary.
+type messageScanner struct {
+	header    [HeaderSize]byte
+	buffered  int    // Header bytes collected so far
+	remaining uint64 // Payload bytes of the current message still to skip
+
+	hello     []byte // ClientHello being collected to check for early data
+	initiator bool   // A ClientHello was seen in this direction
+	earlyData bool   // The next encrypted record holds early data
+}
+
+// scan consumes p, calling found with the type and total length of each
+// message whose header completes in p. Encrypted handshake records are
+// reported as the message they carry, with early data reported as
+// MessageTypeData.
+func (s *messageScanner) scan(p []byte, found func(MessageType, int)) {
+	for len(p) > 0 {
+		if s.remaining > 0 {
+			skip := min(uint64(len(p)), s.remaining)
+			if s.hello != nil {
+				s.hello = append(s.hello, p[:skip]...)
+			}
+			s.remaining -= skip
+			p = p[skip:]
+			if s.remaining == 0 && s.hello != nil {
+				s.checkEarlyData()
+			}
+			continue
+		}
+
+		need := HeaderSize
+		if s.buffered == 0 {
+			s.header[0] = p[0]
+			s.buffered = 1
+			p = p[1:]
+		}
+		if s.header[0] == 0 {
+			need = 4
+		}
+		n := copy(s.header[s.buffered:need], p)
+		s.buffered += n
+		p = p[n:]
+		if s.buffered < need {
+			return
+		}
+		s.buffered = 0
+
+		if need == 4 {
+			length := binary.BigEndian.Uint32(s.header[:4])
+			found(s.recordType(), 4+int(length))
+			s.remaining = uint64(length)
+			continue
+		}
+
+		msgType := MessageType(s.header[0])
+		length := binary.BigEndian.Uint32(s.header[1:5])
+		found(msgType, HeaderSize+int(length))
+		s.remaining = uint64(length)
+		if msgType == MessageTypeClientHello {
+			s.initiator = true
+			s.earlyData = false
+			if length <= MaxMessageSize {
+				s.hello = append(make([]byte, 0, HeaderSize+int(length)), s.header[:]...)
+				if length == 0 {
+					s.checkEarlyData()
+				}
+			}
+		}
+	}
+}
+
+// recordType returns the message carried by the next encrypted handshake
+// record.
+func (s *messageScanner) recordType() MessageType {
+	switch {
+	case s.earlyData:
+		s.earlyData = false
+		return MessageTypeData
+	case s.initiator:
+		return MessageTypeClientFinished
+	default:
+		return MessageTypeServerFinished
+	}
+}
+
+// checkEarlyData inspects a collected ClientHello for the early data
+// extension.
+func (s *messageScanner) checkEarlyData() {
+	hello, err := NewCodec().DecodeClientHello(s.hello)
+	s.hello = nil
+	if err != nil {
+		return
+	}
+	_, s.earlyData = hello.Extensions[ExtensionEarlyData]
+}
+
+// JSONRecorder writes each recorded message to an io.Writer as a line of
+// JSON:
+//
+//	{"time":"2026-01-02T15:04:05.000000001Z","direction":"sent","type":"ClientHello","type_code":1,"length":1667,"local":"10.0.0.1:50000","remote":"10.0.0.2:8443"}
+type JSONRecorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+	err error
+}
+
+// NewJSONRecorder returns a recorder writing JSON lines to w.
+func NewJSONRecorder(w io.Writer) *JSONRecorder {
+	return &JSONRecorder{enc: json.NewEncoder(w)}
+}
+
+// jsonRecord is the JSON form of a RecordedMessage.
+type jsonRecord struct {
+	Time      time.Time `json:"time"`
+	Direction string    `json:"direction"`
+	Type      string    `json:"type"`
+	TypeCode  uint8     `json:"type_code"`
+	Length    int       `json:"length"`
+	Local     string    `json:"local,omitempty"`
+	Remote    string    `json:"remote,omitempty"`
+}
+
+// Record writes msg. After a write error, further messages are dropped.
+func (r *JSONRecorder) Record(msg RecordedMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+	r.err = r.enc.Encode(jsonRecord{
+		Time:      msg.Time,
+		Direction: msg.Direction.String(),
+		Type:      msg.Type.String(),
+		TypeCode:  uint8(msg.Type),
+		Length:    msg.Length,
+		Local:     msg.LocalAddr,
+		Remote:    msg.RemoteAddr,
+	})
+}
+
+// Err returns the first error writing a record, if any.
+func (r *JSONRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
+
+// pcap file format constants (see https://www.tcpdump.org/manpages/pcap-savefile.5.html).
+const (
+	pcapMagic        = 0xa1b2c3d4 // Microsecond timestamps
+	pcapVersionMajor = 2
+	pcapVersionMinor = 4
+	pcapSnapLen      = 1 + HeaderSize
+	pcapLinkTypeUser = 147 // LINKTYPE_USER0
+)
+
+// PcapRecorder writes recorded messages to a pcap capture file using the
+// LINKTYPE_USER0 link type, for viewing with tcpdump or Wireshark. Each
+// packet holds a direction byte (0 sent, 1 received) followed by the
+// message's 5-byte header; the payload is not captured, and the packet's
+// original length is that of the full message plus the direction byte.
+type PcapRecorder struct {
+	mu  sync.Mutex
+	w   io.Writer
+	buf [16 + pcapSnapLen]byte
+	err error
+}
+
+// NewPcapRecorder writes a pcap file header to w and returns a recorder
+// appending packets to it.
+func NewPcapRecorder(w io.Writer) (*PcapRecorder, error) {
+	var header [24]byte
+	binary.LittleEndian.PutUint32(header[0:], pcapMagic)
+	binary.LittleEndian.PutUint16(header[4:], pcapVersionMajor)
+	binary.LittleEndian.PutUint16(header[6:], pcapVersionMinor)
+	binary.LittleEndian.PutUint32(header[16:], pcapSnapLen)
+	binary.LittleEndian.PutUint32(header[20:], pcapLinkTypeUser)
+	if _, err := w.Write(header[:]); err != nil {
+		return nil, err
+	}
+	return &PcapRecorder{w: w}, nil
+}
+
+// Record appends msg as a packet. After a write error, further messages
+// are dropped.
+func (r *PcapRecorder) Record(msg RecordedMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err != nil {
+		return
+	}
+
+	//nolint:gosec // G115: pcap timestamps are 32-bit by definition
+	binary.LittleEndian.PutUint32(r.buf[0:], uint32(msg.Time.Unix()))
+	//nolint:gosec // G115: microseconds are below 1e6
+	binary.LittleEndian.PutUint32(r.buf[4:], uint32(msg.Time.Nanosecond()/1000))
+	binary.LittleEndian.PutUint32(r.buf[8:], pcapSnapLen)
+	//nolint:gosec // G115: message lengths are bounded by MaxMessageSize
+	binary.LittleEndian.PutUint32(r.buf[12:], uint32(1+msg.Length))
+	r.buf[16] = byte(msg.Direction)
+	r.buf[17] = byte(msg.Type)
+	//nolint:gosec // G115: message lengths are bounded by MaxMessageSize
+	binary.BigEndian.PutUint32(r.buf[18:], uint32(msg.Length-HeaderSize))
+	_, r.err = r.w.Write(r.buf[:])
+}
+
+// Err returns the first error writing a packet, if any.
+func (r *PcapRecorder) Err() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.err
+}
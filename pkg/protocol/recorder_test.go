@@ -0,0 +1,216 @@
+package protocol_test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// recorded collects recorded messages.
+type recorded struct {
+	mu   sync.Mutex
+	msgs []protocol.RecordedMessage
+}
+
+func (r *recorded) Record(msg protocol.RecordedMessage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.msgs = append(r.msgs, msg)
+}
+
+func (r *recorded) get() []protocol.RecordedMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]protocol.RecordedMessage(nil), r.msgs...)
+}
+
+func frame(msgType protocol.MessageType, payload string) []byte {
+	msg := make([]byte, protocol.HeaderSize, protocol.HeaderSize+len(payload))
+	msg[0] = byte(msgType)
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(payload)))
+	return append(msg, payload...)
+}
+
+func TestRecordConnSplitMessages(t *testing.T) {
+	a, b := net.Pipe()
+	defer func() { _ = a.Close() }()
+	defer func() { _ = b.Close() }()
+
+	var sent, received recorded
+	writer := protocol.RecordConn(a, &sent)
+	reader := protocol.RecordConn(b, &received)
+
+	// An encrypted handshake record is framed by a bare length
+	record := []byte{0, 0, 0, 10, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	stream := append(frame(protocol.MessageTypeClientHello, "hello"), record...)
+	stream = append(stream, frame(protocol.MessageTypePing, "")...)
+	stream = append(stream, frame(protocol.MessageTypeData, strings.Repeat("x", 100))...)
+
+	// Write in chunks that split headers and payloads, and read with a
+	// small buffer
+	go func() {
+		for rest := stream; len(rest) > 0; {
+			n := min(3, len(rest))
+			if _, err := writer.Write(rest[:n]); err != nil {
+				t.Errorf("Write failed: %v", err)
+				return
+			}
+			rest = rest[n:]
+		}
+	}()
+	buf := make([]byte, 7)
+	for total := 0; total < len(stream); {
+		n, err := reader.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		total += n
+	}
+
+	want := []struct {
+		msgType protocol.MessageType
+		length  int
+	}{
+		{protocol.MessageTypeClientHello, protocol.HeaderSize + 5},
+		{protocol.MessageTypeClientFinished, len(record)},
+		{protocol.MessageTypePing, protocol.HeaderSize},
+		{protocol.MessageTypeData, protocol.HeaderSize + 100},
+	}
+	for _, tc := range []struct {
+		name string
+		msgs []protocol.RecordedMessage
+		dir  protocol.Direction
+	}{{"sent", sent.get(), protocol.DirectionSent}, {"received", received.get(), protocol.DirectionReceived}} {
+		if len(tc.msgs) != len(want) {
+			t.Fatalf("%s: recorded %d messages, want %d", tc.name, len(tc.msgs), len(want))
+		}
+		for i, msg := range tc.msgs {
+			if msg.Type != want[i].msgType || msg.Length != want[i].length || msg.Direction != tc.dir {
+				t.Errorf("%s[%d] = %v/%d/%v, want %v/%d/%v", tc.name, i,
+					msg.Type, msg.Length, msg.Direction, want[i].msgType, want[i].length, tc.dir)
+			}
+			if msg.Time.IsZero() || msg.LocalAddr == "" || msg.RemoteAddr == "" {
+				t.Errorf("%s[%d]: missing time or addresses: %+v", tc.name, i, msg)
+			}
+		}
+	}
+}
+
+func TestRecordDatagramConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer func() { _ = a.Close() }()
+	defer func() { _ = b.Close() }()
+
+	var rec recorded
+	conn := protocol.RecordDatagramConn(a, &rec)
+
+	// A truncated datagram must not throw off the following ones
+	go func() {
+		for _, datagram := range [][]byte{
+			frame(protocol.MessageTypeData, "abc")[:6],
+			frame(protocol.MessageTypePing, ""),
+		} {
+			if _, err := b.Write(datagram); err != nil {
+				t.Errorf("Write failed: %v", err)
+			}
+		}
+	}()
+	buf := make([]byte, 64)
+	for range 2 {
+		if _, err := conn.Read(buf); err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+	}
+
+	msgs := rec.get()
+	if len(msgs) != 2 || msgs[0].Type != protocol.MessageTypeData || msgs[1].Type != protocol.MessageTypePing {
+		t.Fatalf("recorded %+v, want Data then Ping", msgs)
+	}
+}
+
+func TestJSONRecorder(t *testing.T) {
+	var out bytes.Buffer
+	rec := protocol.NewJSONRecorder(&out)
+
+	a, b := net.Pipe()
+	defer func() { _ = b.Close() }()
+	go func() { _, _ = io.Copy(io.Discard, b) }()
+
+	conn := protocol.RecordConn(a, rec)
+	if _, err := conn.Write(frame(protocol.MessageTypeData, "top secret")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	_ = conn.Close()
+
+	if err := rec.Err(); err != nil {
+		t.Fatalf("Err = %v", err)
+	}
+	if strings.Contains(out.String(), "top secret") {
+		t.Fatal("payload leaked into the recording")
+	}
+
+	var event map[string]any
+	scanner := bufio.NewScanner(&out)
+	if !scanner.Scan() {
+		t.Fatal("no JSON line written")
+	}
+	if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+		t.Fatalf("invalid JSON %q: %v", scanner.Text(), err)
+	}
+	if event["direction"] != "sent" || event["type"] != "Data" || event["type_code"] != float64(protocol.MessageTypeData) ||
+		event["length"] != float64(protocol.HeaderSize+10) {
+		t.Errorf("unexpected event %v", event)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, event["time"].(string)); err != nil {
+		t.Errorf("invalid time: %v", err)
+	}
+}
+
+func TestPcapRecorder(t *testing.T) {
+	var out bytes.Buffer
+	rec, err := protocol.NewPcapRecorder(&out)
+	if err != nil {
+		t.Fatalf("NewPcapRecorder failed: %v", err)
+	}
+
+	at := time.Unix(1700000000, 123456000)
+	rec.Record(protocol.RecordedMessage{
+		Time:      at,
+		Direction: protocol.DirectionReceived,
+		Type:      protocol.MessageTypeServerHello,
+		Length:    protocol.HeaderSize + 1000,
+	})
+	if err := rec.Err(); err != nil {
+		t.Fatalf("Err = %v", err)
+	}
+
+	data := out.Bytes()
+	if len(data) != 24+16+6 {
+		t.Fatalf("pcap length = %d, want %d", len(data), 24+16+6)
+	}
+	le := binary.LittleEndian
+	if le.Uint32(data[0:]) != 0xa1b2c3d4 || le.Uint32(data[20:]) != 147 {
+		t.Errorf("bad global header % x", data[:24])
+	}
+
+	packet := data[24:]
+	if le.Uint32(packet[0:]) != 1700000000 || le.Uint32(packet[4:]) != 123456 {
+		t.Errorf("bad timestamp % x", packet[:8])
+	}
+	if le.Uint32(packet[8:]) != 6 || le.Uint32(packet[12:]) != 1+protocol.HeaderSize+1000 {
+		t.Errorf("bad lengths % x", packet[8:16])
+	}
+	want := []byte{byte(protocol.DirectionReceived), byte(protocol.MessageTypeServerHello), 0, 0, 0x03, 0xe8}
+	if !bytes.Equal(packet[16:], want) {
+		t.Errorf("packet data = % x, want % x", packet[16:], want)
+	}
+}
@@ -44,6 +44,17 @@ func (v Version) IsCompatible(other Version) bool {
 	return v.Major == other.Major
 }
 
+// Negotiate returns the version a responder running Current speaks with an
+// initiator that offered a compatible version: the older of the two. The
+// hellos carrying both versions are bound into the Finished verify_data, so
+// an attacker cannot force a lower version without failing the handshake.
+func Negotiate(offered Version) Version {
+	if offered.Uint16() < Current.Uint16() {
+		return offered
+	}
+	return Current
+}
+
 // String returns a string representation of the version.
 func (v Version) String() string {
 	return string('0'+v.Major) + "." + string('0'+v.Minor)
@@ -2,11 +2,18 @@ package tunnel_test
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"net"
+	"os"
+	"runtime"
 	"sync"
 	"testing"
 	"time"
 
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
 	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
 
@@ -439,6 +446,91 @@ func TestTunnelGetters(t *testing.T) {
 	serverTunnel.SetWriteTimeout(5 * time.Second)
 }
 
+// TestConnectionState tests the negotiated parameters reported after Dial.
+func TestConnectionState(t *testing.T) {
+	identity, err := tunnel.GenerateIdentityKey()
+	if err != nil {
+		t.Fatalf("GenerateIdentityKey failed: %v", err)
+	}
+
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	config := tunnel.DefaultTransportConfig()
+	config.IdentityKey = identity
+	listener.SetConfig(config)
+
+	accepted := make(chan *tunnel.Tunnel, 1)
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			close(accepted)
+			return
+		}
+		accepted <- server
+	}()
+
+	before := time.Now()
+	client, err := tunnel.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	server := <-accepted
+	if server == nil {
+		t.Fatal("Accept failed")
+	}
+	defer func() { _ = server.Close() }()
+
+	state := client.ConnectionState()
+	if state.CipherSuite != protocol.PreferredCipherSuite() {
+		t.Errorf("expected the server's preferred suite %s, got %s", protocol.PreferredCipherSuite(), state.CipherSuite)
+	}
+	if state.Version != protocol.Current {
+		t.Errorf("expected version %s, got %s", protocol.Current, state.Version)
+	}
+	if state.Resumed {
+		t.Error("full handshake reported as resumed")
+	}
+	if !state.PeerAuthenticated || state.PeerFingerprint != tunnel.IdentityFingerprint(identity.PublicKey()) {
+		t.Error("PeerFingerprint should identify the responder's identity key")
+	}
+	if state.EstablishedAt.Before(before) || state.EstablishedAt.After(time.Now()) {
+		t.Errorf("EstablishedAt %v outside the handshake", state.EstablishedAt)
+	}
+	if state.SendSeq != 0 || state.RecvSeq != 0 {
+		t.Errorf("expected fresh sequence numbers, got send=%d recv=%d", state.SendSeq, state.RecvSeq)
+	}
+
+	// Both sides agree, and sequence numbers track traffic
+	for i := 0; i < 3; i++ {
+		if err := client.Send([]byte("ping")); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if _, err := server.Receive(); err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+	}
+
+	serverState := server.ConnectionState()
+	if serverState.CipherSuite != state.CipherSuite {
+		t.Errorf("server negotiated %s, client %s", serverState.CipherSuite, state.CipherSuite)
+	}
+	if serverState.PeerAuthenticated {
+		t.Error("responder should not report an authenticated peer")
+	}
+	if got := client.ConnectionState().SendSeq; got != 3 {
+		t.Errorf("expected client SendSeq 3, got %d", got)
+	}
+	if serverState.RecvSeq != 3 {
+		t.Errorf("expected server RecvSeq 3, got %d", serverState.RecvSeq)
+	}
+}
+
 // TestListenInvalidNetwork tests Listen with invalid network type.
 func TestListenInvalidNetwork(t *testing.T) {
 	_, err := tunnel.Listen("invalid", "127.0.0.1:0")
@@ -446,3 +538,431 @@ func TestListenInvalidNetwork(t *testing.T) {
 		t.Error("Expected error for invalid network type, got nil")
 	}
 }
+
+// startDrainableEchoServer runs an echo server on listener. The returned
+// channel is closed when the accept loop exits.
+func startDrainableEchoServer(listener *tunnel.Listener) <-chan struct{} {
+	acceptDone := make(chan struct{})
+	go func() {
+		defer close(acceptDone)
+		runEchoServer(listener)
+	}()
+	return acceptDone
+}
+
+// dialEchoClients dials n tunnels and verifies each one echoes.
+func dialEchoClients(t *testing.T, addr string, n int) []*tunnel.Tunnel {
+	t.Helper()
+	clients := make([]*tunnel.Tunnel, 0, n)
+	for i := 0; i < n; i++ {
+		client, err := tunnel.Dial("tcp", addr)
+		if err != nil {
+			t.Fatalf("Dial %d failed: %v", i, err)
+		}
+		msg := []byte(fmt.Sprintf("echo %d", i))
+		if err := client.Send(msg); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+		got, err := client.Receive()
+		if err != nil || !bytes.Equal(got, msg) {
+			t.Fatalf("echo %d: got %q, %v", i, got, err)
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// TestListenerDrain tests that Drain waits for accepted tunnels to close
+// and refuses new connections.
+func TestListenerDrain(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	addr := listener.Addr().String()
+	acceptDone := startDrainableEchoServer(listener)
+
+	clients := dialEchoClients(t, addr, 3)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	drainErr := make(chan error, 1)
+	go func() { drainErr <- listener.Drain(ctx) }()
+
+	select {
+	case <-acceptDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Accept loop did not stop after Drain")
+	}
+
+	// Existing tunnels keep working while draining
+	if err := clients[0].Send([]byte("still here")); err != nil {
+		t.Fatalf("Send during drain failed: %v", err)
+	}
+	if got, err := clients[0].Receive(); err != nil || string(got) != "still here" {
+		t.Fatalf("echo during drain: got %q, %v", got, err)
+	}
+
+	// New tunnels are refused
+	if late, err := tunnel.Dial("tcp", addr); err == nil {
+		_ = late.Close()
+		t.Error("Dial succeeded after Drain")
+	}
+
+	select {
+	case err := <-drainErr:
+		t.Fatalf("Drain returned before tunnels closed: %v", err)
+	default:
+	}
+
+	for _, client := range clients {
+		_ = client.Close()
+	}
+
+	select {
+	case err := <-drainErr:
+		if err != nil {
+			t.Errorf("Drain failed: %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Drain did not return after all tunnels closed")
+	}
+}
+
+// TestListenerDrainDeadline tests that Drain force-closes tunnels that
+// outlive the context.
+func TestListenerDrainDeadline(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go runEchoServer(listener)
+
+	clients := dialEchoClients(t, listener.Addr().String(), 2)
+	defer func() {
+		for _, client := range clients {
+			_ = client.Close()
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := listener.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	// The server side was closed, so clients see the tunnel end
+	for i, client := range clients {
+		client.SetReadTimeout(2 * time.Second)
+		if _, err := client.Receive(); err == nil {
+			t.Errorf("client %d: expected error after forced drain", i)
+		}
+	}
+}
+
+// TestListenerDrainIdle tests that Drain returns immediately with no tunnels.
+func TestListenerDrainIdle(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	if err := listener.Drain(context.Background()); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	if _, err := listener.Accept(); err == nil {
+		t.Error("Accept succeeded after Drain")
+	}
+}
+
+// TestListenerServe tests that a client stalling mid-handshake does not hold
+// up other clients.
+func TestListenerServe(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	config := tunnel.DefaultTransportConfig()
+	config.MaxConcurrentHandshakes = 2
+	listener.SetConfig(config)
+	addr := listener.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- listener.Serve(ctx, echoHandler) }()
+
+	// Send part of a ClientHello and stall
+	stalled, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = stalled.Close() }()
+	if _, err := stalled.Write([]byte{byte(protocol.MessageTypeClientHello), 0x00}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	start := time.Now()
+	clients := dialEchoClients(t, addr, 3)
+	if elapsed := time.Since(start); elapsed > config.HandshakeTimeout/2 {
+		t.Errorf("fast clients took %v to connect", elapsed)
+	}
+	for _, client := range clients {
+		_ = client.Close()
+	}
+
+	cancel()
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+}
+
+// TestListenerServeDrain tests that Serve returns once the listener drains.
+func TestListenerServeDrain(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- listener.Serve(context.Background(), echoHandler) }()
+
+	client := dialEchoClients(t, listener.Addr().String(), 1)[0]
+	_ = client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := listener.Drain(ctx); err != nil {
+		t.Fatalf("Drain failed: %v", err)
+	}
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, net.ErrClosed) {
+			t.Errorf("expected net.ErrClosed, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after Drain")
+	}
+}
+
+// TestListenerShards tests that connections to a sharded listener are
+// accepted by every shard and served through a single Serve loop.
+func TestListenerShards(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("only Linux balances connections across SO_REUSEPORT sockets")
+	}
+	listener, err := tunnel.ListenWithConfig("tcp", "127.0.0.1:0", tunnel.ListenConfig{ReusePort: true, Shards: 2})
+	if err != nil {
+		t.Fatalf("ListenWithConfig failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- listener.Serve(ctx, echoHandler) }()
+
+	// The kernel picks a shard by hashing each connection's addresses, so
+	// both shards are all but certain to see some of them
+	const clients = 32
+	for _, client := range dialEchoClients(t, listener.Addr().String(), clients) {
+		_ = client.Close()
+	}
+
+	accepts := listener.ShardAccepts()
+	if len(accepts) != 2 || accepts[0]+accepts[1] != clients || accepts[0] == 0 || accepts[1] == 0 {
+		t.Errorf("shard accepts = %v, want %d connections spread over 2 shards", accepts, clients)
+	}
+
+	cancel()
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Serve did not return after cancellation")
+	}
+
+	// Closing the listener frees the port on every shard
+	if _, err := net.Dial("tcp", listener.Addr().String()); err == nil {
+		t.Error("dial succeeded after the sharded listener closed")
+	}
+}
+
+// TestListenerReusePort tests that listeners with ReusePort can share a
+// port, while a plain listener cannot join them.
+func TestListenerReusePort(t *testing.T) {
+	first, err := tunnel.ListenWithConfig("tcp", "127.0.0.1:0", tunnel.ListenConfig{ReusePort: true})
+	if errors.Is(err, qerrors.ErrReusePortUnsupported) {
+		t.Skip("SO_REUSEPORT not supported")
+	}
+	if err != nil {
+		t.Fatalf("ListenWithConfig failed: %v", err)
+	}
+	defer func() { _ = first.Close() }()
+	addr := first.Addr().String()
+
+	second, err := tunnel.ListenWithConfig("tcp", addr, tunnel.ListenConfig{ReusePort: true})
+	if err != nil {
+		t.Fatalf("second ReusePort listener failed: %v", err)
+	}
+	defer func() { _ = second.Close() }()
+	if second.ShardAccepts() != nil {
+		t.Error("a single-socket listener should report no shards")
+	}
+
+	if plain, err := tunnel.Listen("tcp", addr); err == nil {
+		_ = plain.Close()
+		t.Error("a listener without ReusePort should not bind a shared port")
+	}
+}
+
+// TestListenerMiddleware tests that Accept runs the middleware chain in
+// order and skips tunnels a middleware rejects.
+func TestListenerMiddleware(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	// Connect both clients first so the rejected remote is known
+	rejected, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = rejected.Close() }()
+	allowed, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = allowed.Close() }()
+	errDenied := errors.New("remote denied")
+
+	var mu sync.Mutex
+	var calls []string
+	tags := make(map[*tunnel.Tunnel]string)
+	listener.Use(func(tun *tunnel.Tunnel) (*tunnel.Tunnel, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, "deny "+tun.RemoteAddr().String())
+		if tun.RemoteAddr().String() == rejected.LocalAddr().String() {
+			return nil, errDenied
+		}
+		return tun, nil
+	}, func(tun *tunnel.Tunnel) (*tunnel.Tunnel, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls = append(calls, "tag "+tun.RemoteAddr().String())
+		tags[tun] = "allowed"
+		return tun, nil
+	})
+
+	var wg sync.WaitGroup
+	for _, conn := range []net.Conn{rejected, allowed} {
+		wg.Go(func() {
+			session, _ := tunnel.NewSession(tunnel.RoleInitiator)
+			_ = tunnel.InitiatorHandshake(session, conn)
+		})
+	}
+
+	accepted, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	defer func() { _ = accepted.Close() }()
+
+	if got := accepted.RemoteAddr().String(); got != allowed.LocalAddr().String() {
+		t.Errorf("Accept returned the tunnel from %s, want %s", got, allowed.LocalAddr())
+	}
+	if accepted.Session().State() != tunnel.SessionStateEstablished {
+		t.Error("accepted tunnel not established")
+	}
+
+	wg.Wait()
+	mu.Lock()
+	defer mu.Unlock()
+	if tags[accepted] != "allowed" {
+		t.Error("accepted tunnel was not tagged")
+	}
+	want := []string{
+		"deny " + rejected.LocalAddr().String(),
+		"deny " + allowed.LocalAddr().String(),
+		"tag " + allowed.LocalAddr().String(),
+	}
+	if fmt.Sprint(calls) != fmt.Sprint(want) {
+		t.Errorf("middleware calls = %v, want %v", calls, want)
+	}
+
+	// The rejected connection is closed once the close alert is read
+	_ = rejected.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	var readErr error
+	for readErr == nil {
+		_, readErr = rejected.Read(buf)
+	}
+	if errors.Is(readErr, os.ErrDeadlineExceeded) {
+		t.Error("rejected connection was not closed")
+	}
+}
+
+// TestListenerMiddlewareNilTunnel tests that a middleware returning no
+// tunnel and no error rejects the connection.
+func TestListenerMiddlewareNilTunnel(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	rejected, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = rejected.Close() }()
+	allowed, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = allowed.Close() }()
+
+	listener.Use(func(tun *tunnel.Tunnel) (*tunnel.Tunnel, error) {
+		if tun.RemoteAddr().String() == rejected.LocalAddr().String() {
+			return nil, nil
+		}
+		return tun, nil
+	})
+
+	var wg sync.WaitGroup
+	for _, conn := range []net.Conn{rejected, allowed} {
+		wg.Go(func() {
+			session, _ := tunnel.NewSession(tunnel.RoleInitiator)
+			_ = tunnel.InitiatorHandshake(session, conn)
+		})
+	}
+
+	accepted, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	defer func() { _ = accepted.Close() }()
+	wg.Wait()
+
+	if got := accepted.RemoteAddr().String(); got != allowed.LocalAddr().String() {
+		t.Errorf("Accept returned the tunnel from %s, want %s", got, allowed.LocalAddr())
+	}
+
+	// The rejected connection is closed once the close alert is read
+	_ = rejected.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	var readErr error
+	for readErr == nil {
+		_, readErr = rejected.Read(buf)
+	}
+	if errors.Is(readErr, os.ErrDeadlineExceeded) {
+		t.Error("rejected connection was not closed")
+	}
+}
@@ -0,0 +1,143 @@
+package tunnel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"sync"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// compressionFlagSize is the size of the flag byte inside compressed plaintext.
+const compressionFlagSize = 1
+
+// Compression flag values.
+const (
+	compressionFlagRaw  byte = 0x00
+	compressionFlagGzip byte = 0x01
+)
+
+// Compression selects how data records are compressed before encryption.
+//
+// When compression is enabled, each record's plaintext is encoded as
+// Flag(1B) || Body, where the flag says whether Body is compressed. Records
+// that do not shrink are sent uncompressed with the flag clear. Both peers
+// must use the same setting.
+//
+// Compressing before encryption makes ciphertext sizes depend on the
+// plaintext's content. If an attacker can inject data into a record that
+// also contains secrets (cookies, tokens, keys), observing record sizes
+// lets them recover the secrets byte by byte, as in the CRIME and BREACH
+// attacks on TLS and HTTP. Only enable compression for traffic that never
+// mixes attacker-controlled and secret data in one record, such as logs.
+type Compression int
+
+const (
+	// CompressionNone disables compression.
+	CompressionNone Compression = iota
+
+	// CompressionGzip compresses records with gzip (DEFLATE). Zstandard is
+	// not offered to keep the module free of non-standard dependencies.
+	CompressionGzip
+)
+
+// String returns the compression name.
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionGzip:
+		return "gzip"
+	default:
+		return "unknown"
+	}
+}
+
+// IsSupported reports whether c is a known compression setting.
+func (c Compression) IsSupported() bool {
+	return c == CompressionNone || c == CompressionGzip
+}
+
+// Enabled reports whether records are compressed.
+func (c Compression) Enabled() bool {
+	return c != CompressionNone
+}
+
+// Overhead returns the fixed per-record overhead added by compression.
+func (c Compression) Overhead() int {
+	if !c.Enabled() {
+		return 0
+	}
+	return compressionFlagSize
+}
+
+// gzipWriters reuses gzip writers, which are expensive to allocate.
+var gzipWriters = sync.Pool{
+	New: func() any { return gzip.NewWriter(nil) },
+}
+
+// compress frames data with the compression flag, compressing it if that
+// makes it smaller.
+func (c Compression) compress(data []byte) ([]byte, error) {
+	if !c.Enabled() {
+		return data, nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(compressionFlagGzip)
+
+	zw := gzipWriters.Get().(*gzip.Writer)
+	defer gzipWriters.Put(zw)
+	zw.Reset(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+
+	// Incompressible data is sent as is
+	if buf.Len()-compressionFlagSize >= len(data) {
+		frame := make([]byte, compressionFlagSize+len(data))
+		frame[0] = compressionFlagRaw
+		copy(frame[compressionFlagSize:], data)
+		return frame, nil
+	}
+	return buf.Bytes(), nil
+}
+
+// decompress strips the compression flag from a decrypted frame and
+// decompresses its body. Bodies that expand beyond MaxPayloadSize are
+// rejected, since no peer can send such a record.
+func (c Compression) decompress(frame []byte) ([]byte, error) {
+	if !c.Enabled() {
+		return frame, nil
+	}
+
+	if len(frame) < compressionFlagSize {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	body := frame[compressionFlagSize:]
+
+	switch frame[0] {
+	case compressionFlagRaw:
+		return body, nil
+	case compressionFlagGzip:
+		zr, err := gzip.NewReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		data, err := io.ReadAll(io.LimitReader(zr, constants.MaxPayloadSize+1))
+		if err != nil {
+			return nil, qerrors.ErrInvalidMessage
+		}
+		if len(data) > constants.MaxPayloadSize {
+			return nil, qerrors.ErrMessageTooLarge
+		}
+		return data, nil
+	default:
+		return nil, qerrors.ErrInvalidMessage
+	}
+}
@@ -0,0 +1,116 @@
+package tunnel
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"testing"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+func TestCompressionRoundTrip(t *testing.T) {
+	client, server := newPipeTransports(t)
+	client.compression = CompressionGzip
+	server.compression = CompressionGzip
+
+	random := make([]byte, 1024)
+	_ = crypto.SecureRandom(random)
+
+	messages := [][]byte{
+		bytes.Repeat([]byte("level=info msg=\"request served\" status=200\n"), 100),
+		random,
+		[]byte("x"),
+		{},
+	}
+
+	for i, msg := range messages {
+		go func() { _ = client.Send(msg) }()
+
+		raw, err := server.codec.ReadMessage(server.conn)
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		got, err := server.handleData(raw)
+		if err != nil {
+			t.Fatalf("handleData failed: %v", err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("message %d mismatch after round trip", i)
+		}
+
+		// The repetitive log lines shrink on the wire
+		if i == 0 && len(raw) >= len(msg) {
+			t.Errorf("compressible record not compressed: %d bytes on the wire for %d", len(raw), len(msg))
+		}
+	}
+}
+
+func TestCompressionSkipsIncompressible(t *testing.T) {
+	random := make([]byte, 4096)
+	_ = crypto.SecureRandom(random)
+
+	frame, err := CompressionGzip.compress(random)
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	if frame[0] != compressionFlagRaw {
+		t.Errorf("expected flag %#x for incompressible data, got %#x", compressionFlagRaw, frame[0])
+	}
+	if !bytes.Equal(frame[compressionFlagSize:], random) {
+		t.Error("incompressible data should be sent unchanged")
+	}
+
+	frame, err = CompressionGzip.compress(bytes.Repeat([]byte{'a'}, 4096))
+	if err != nil {
+		t.Fatalf("compress failed: %v", err)
+	}
+	if frame[0] != compressionFlagGzip {
+		t.Errorf("expected flag %#x for compressible data, got %#x", compressionFlagGzip, frame[0])
+	}
+
+	// Disabled compression leaves the plaintext untouched
+	if frame, _ := CompressionNone.compress(random); !bytes.Equal(frame, random) {
+		t.Error("CompressionNone should not frame data")
+	}
+}
+
+func TestCompressionMalformed(t *testing.T) {
+	var bomb bytes.Buffer
+	bomb.WriteByte(compressionFlagGzip)
+	zw := gzip.NewWriter(&bomb)
+	_, _ = zw.Write(make([]byte, constants.MaxPayloadSize+1))
+	_ = zw.Close()
+
+	tests := []struct {
+		name  string
+		frame []byte
+		want  error
+	}{
+		{"empty", nil, qerrors.ErrInvalidMessage},
+		{"unknown flag", []byte{0x7F, 1, 2, 3}, qerrors.ErrInvalidMessage},
+		{"corrupt gzip", []byte{compressionFlagGzip, 1, 2, 3}, qerrors.ErrInvalidMessage},
+		{"oversized", bomb.Bytes(), qerrors.ErrMessageTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := CompressionGzip.decompress(tt.frame); !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestCompressionUnsupported(t *testing.T) {
+	session, _ := NewSession(RoleInitiator)
+	_ = session.InitializeKeys(make([]byte, constants.CHKEMSharedSecretSize), constants.CipherSuiteAES256GCM)
+
+	config := DefaultTransportConfig()
+	config.Compression = Compression(99)
+	if _, err := NewTransport(session, nil, config); !errors.Is(err, qerrors.ErrUnsupportedCompression) {
+		t.Errorf("expected ErrUnsupportedCompression, got %v", err)
+	}
+}
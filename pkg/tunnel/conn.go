@@ -0,0 +1,171 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// Conn returns a net.Conn view of the transport with byte-stream semantics.
+//
+// Reads return decrypted plaintext, buffering any remainder of a data
+// message across calls. Writes that do not fit in a single data record
+// are split into multiple records. Deadlines apply to the underlying connection as
+// well as to the transport's own read and write timeouts.
+//
+// The returned Conn shares the transport; mixing its use with direct
+// Send/Receive calls interleaves the byte stream.
+func (t *Transport) Conn() net.Conn {
+	return &transportConn{t: t}
+}
+
+// transportConn adapts a message-oriented Transport to net.Conn.
+type transportConn struct {
+	t *Transport
+
+	readMu  sync.Mutex
+	pending []byte // Undelivered plaintext from the last data message
+
+	writeMu sync.Mutex
+
+	deadlineMu    sync.Mutex
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+// Read reads decrypted plaintext from the tunnel.
+func (c *transportConn) Read(b []byte) (int, error) {
+	c.readMu.Lock()
+	defer c.readMu.Unlock()
+
+	if len(b) == 0 {
+		return 0, nil
+	}
+
+	for len(c.pending) == 0 {
+		ctx, cancel := c.deadlineContext(c.getReadDeadline())
+		data, err := c.t.ReceiveContext(ctx)
+		cancel()
+		if err != nil {
+			return 0, mapConnError(err, io.EOF)
+		}
+		c.pending = data
+	}
+
+	n := copy(b, c.pending)
+	c.pending = c.pending[n:]
+	return n, nil
+}
+
+// Write encrypts and sends b, fragmenting it into as many records as needed.
+func (c *transportConn) Write(b []byte) (int, error) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	written := 0
+	for written < len(b) {
+		end := written + c.t.maxRecordData()
+		if end > len(b) {
+			end = len(b)
+		}
+
+		ctx, cancel := c.deadlineContext(c.getWriteDeadline())
+		err := c.t.SendContext(ctx, b[written:end])
+		cancel()
+		if err != nil {
+			return written, mapConnError(err, net.ErrClosed)
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// Close closes the underlying transport.
+func (c *transportConn) Close() error {
+	return c.t.Close()
+}
+
+// CloseWrite shuts down the writing side; the peer reads io.EOF.
+func (c *transportConn) CloseWrite() error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.t.CloseWrite()
+}
+
+// LocalAddr returns the local network address.
+func (c *transportConn) LocalAddr() net.Addr {
+	return c.t.LocalAddr()
+}
+
+// RemoteAddr returns the remote network address.
+func (c *transportConn) RemoteAddr() net.Addr {
+	return c.t.RemoteAddr()
+}
+
+// SetDeadline sets both the read and write deadlines.
+func (c *transportConn) SetDeadline(d time.Time) error {
+	if err := c.SetReadDeadline(d); err != nil {
+		return err
+	}
+	return c.SetWriteDeadline(d)
+}
+
+// SetReadDeadline sets the read deadline. It is also applied to the
+// underlying connection so that a pending Read is interrupted.
+func (c *transportConn) SetReadDeadline(d time.Time) error {
+	c.deadlineMu.Lock()
+	c.readDeadline = d
+	c.deadlineMu.Unlock()
+	return c.t.conn.SetReadDeadline(d)
+}
+
+// SetWriteDeadline sets the write deadline. It is also applied to the
+// underlying connection so that a pending Write is interrupted.
+func (c *transportConn) SetWriteDeadline(d time.Time) error {
+	c.deadlineMu.Lock()
+	c.writeDeadline = d
+	c.deadlineMu.Unlock()
+	return c.t.conn.SetWriteDeadline(d)
+}
+
+func (c *transportConn) getReadDeadline() time.Time {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	return c.readDeadline
+}
+
+func (c *transportConn) getWriteDeadline() time.Time {
+	c.deadlineMu.Lock()
+	defer c.deadlineMu.Unlock()
+	return c.writeDeadline
+}
+
+// deadlineContext returns a context bounded by d, or a background context
+// when d is zero.
+func (c *transportConn) deadlineContext(d time.Time) (context.Context, context.CancelFunc) {
+	if d.IsZero() {
+		return context.Background(), func() {}
+	}
+	return context.WithDeadline(context.Background(), d)
+}
+
+// mapConnError converts transport errors to their net.Conn equivalents,
+// reporting a closed tunnel as closedErr. A connection lost without a close
+// alert ends the stream just the same, as it does for a net.TCPConn whose
+// peer went away.
+func mapConnError(err, closedErr error) error {
+	switch {
+	case errors.Is(err, qerrors.ErrTunnelClosed), errors.Is(err, qerrors.ErrConnectionReset):
+		return closedErr
+	case errors.Is(err, context.DeadlineExceeded):
+		return os.ErrDeadlineExceeded
+	default:
+		return err
+	}
+}
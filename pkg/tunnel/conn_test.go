@@ -0,0 +1,125 @@
+package tunnel
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+func TestConnFragmentsLargeWrites(t *testing.T) {
+	client, server := newPipeTransports(t)
+	clientConn, serverConn := client.Conn(), server.Conn()
+
+	payload := make([]byte, 3*constants.MaxPayloadSize+123)
+	_ = crypto.SecureRandom(payload)
+
+	errCh := make(chan error, 1)
+	go func() {
+		n, err := clientConn.Write(payload)
+		if err == nil && n != len(payload) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			_ = client.conn.Close()
+		}
+		errCh <- err
+	}()
+
+	got := make([]byte, len(payload))
+	_, readErr := io.ReadFull(serverConn, got)
+	if err := <-errCh; err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if readErr != nil {
+		t.Fatalf("ReadFull failed: %v", readErr)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Error("payload mismatch after fragmentation")
+	}
+}
+
+func TestConnPartialReads(t *testing.T) {
+	client, server := newPipeTransports(t)
+	serverConn := server.Conn()
+
+	go func() { _ = client.Send([]byte("hello, world")) }()
+
+	var out []byte
+	buf := make([]byte, 5)
+	for len(out) < len("hello, world") {
+		n, err := serverConn.Read(buf)
+		if err != nil {
+			t.Fatalf("Read failed: %v", err)
+		}
+		if n > len(buf) {
+			t.Fatalf("Read returned %d bytes for %d-byte buffer", n, len(buf))
+		}
+		out = append(out, buf[:n]...)
+	}
+	if string(out) != "hello, world" {
+		t.Errorf("got %q, want %q", out, "hello, world")
+	}
+}
+
+func TestConnReadDeadline(t *testing.T) {
+	_, server := newPipeTransports(t)
+	serverConn := server.Conn()
+
+	if err := serverConn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+		t.Fatalf("SetReadDeadline failed: %v", err)
+	}
+
+	_, err := serverConn.Read(make([]byte, 16))
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected os.ErrDeadlineExceeded, got %v", err)
+	}
+	var netErr net.Error
+	if !errors.As(err, &netErr) || !netErr.Timeout() {
+		t.Errorf("expected timeout net.Error, got %v", err)
+	}
+}
+
+func TestConnEOFOnPeerClose(t *testing.T) {
+	client, server := newPipeTransports(t)
+	clientConn, serverConn := client.Conn(), server.Conn()
+
+	go func() {
+		_, _ = clientConn.Write([]byte("last"))
+		_ = clientConn.Close()
+	}()
+
+	data, err := io.ReadAll(serverConn)
+	if err != nil {
+		t.Fatalf("ReadAll failed: %v", err)
+	}
+	if string(data) != "last" {
+		t.Errorf("got %q, want %q", data, "last")
+	}
+
+	if _, err := serverConn.Write([]byte("x")); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed writing to closed tunnel, got %v", err)
+	}
+}
+
+func TestConnEOFOnDroppedPeer(t *testing.T) {
+	client, server := newPipeTransports(t)
+	serverConn := server.Conn()
+
+	// The peer's connection drops mid-read, without a close alert
+	time.AfterFunc(50*time.Millisecond, func() { _ = client.conn.Close() })
+
+	if _, err := serverConn.Read(make([]byte, 16)); err != io.EOF {
+		t.Errorf("Read after the peer dropped = %v, want io.EOF", err)
+	}
+	if _, err := server.Receive(); !errors.Is(err, qerrors.ErrConnectionReset) {
+		t.Errorf("Receive after the peer dropped = %v, want ErrConnectionReset", err)
+	}
+}
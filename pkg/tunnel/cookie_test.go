@@ -0,0 +1,219 @@
+package tunnel
+
+import (
+	"errors"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// startRetryListener starts a listener with the given retry config that
+// accepts a single connection and reports the Accept result.
+func startRetryListener(t *testing.T, retry HelloRetryConfig) (*Listener, <-chan error) {
+	t.Helper()
+
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	config := DefaultTransportConfig()
+	config.HelloRetry = retry
+	listener.SetConfig(config)
+
+	acceptErr := make(chan error, 1)
+	go func() {
+		tunnel, err := listener.Accept()
+		if err == nil {
+			tunnel.Close()
+		}
+		acceptErr <- err
+	}()
+
+	return listener, acceptErr
+}
+
+// sendClientHello dials the listener, sends a ClientHello carrying cookie,
+// and returns the handshake, connection, and the server's first reply.
+func sendClientHello(t *testing.T, listener *Listener, cookie []byte) (*Handshake, net.Conn, []byte) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	session, _ := NewSession(RoleInitiator)
+	h := NewHandshake(session)
+	h.cookie = cookie
+
+	hello, err := h.CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+	if _, err := conn.Write(hello); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	reply, err := h.codec.ReadMessage(conn)
+	if err != nil {
+		t.Fatalf("reading reply failed: %v", err)
+	}
+	return h, conn, reply
+}
+
+func TestHelloRetryFlow(t *testing.T) {
+	listener, acceptErr := startRetryListener(t, HelloRetryConfig{Enabled: true})
+
+	// The first ClientHello is answered with a cookie, not a ServerHello
+	h, conn, reply := sendClientHello(t, listener, nil)
+	if msgType := protocol.MessageType(reply[0]); msgType != protocol.MessageTypeHelloRetryRequest {
+		t.Fatalf("expected HelloRetryRequest, got %s", msgType)
+	}
+
+	// Echoing the cookie completes the handshake
+	hello, err := h.ProcessHelloRetryRequest(reply)
+	if err != nil {
+		t.Fatalf("ProcessHelloRetryRequest failed: %v", err)
+	}
+	if _, err := conn.Write(hello); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	serverHello, err := h.codec.ReadMessage(conn)
+	if err != nil {
+		t.Fatalf("reading ServerHello failed: %v", err)
+	}
+	if err := h.ProcessServerHello(serverHello); err != nil {
+		t.Fatalf("ProcessServerHello failed: %v", err)
+	}
+	clientFinished, err := h.CreateClientFinished()
+	if err != nil {
+		t.Fatalf("CreateClientFinished failed: %v", err)
+	}
+	if err := writeEncryptedRecord(conn, clientFinished); err != nil {
+		t.Fatalf("writing ClientFinished failed: %v", err)
+	}
+	serverFinished, err := readEncryptedRecord(conn)
+	if err != nil {
+		t.Fatalf("reading ServerFinished failed: %v", err)
+	}
+	if err := h.ProcessServerFinished(serverFinished); err != nil {
+		t.Fatalf("ProcessServerFinished failed: %v", err)
+	}
+
+	if err := <-acceptErr; err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+
+	// A second retry in the same handshake is refused
+	if _, err := h.ProcessHelloRetryRequest(reply); !errors.Is(err, qerrors.ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState for a second retry, got %v", err)
+	}
+}
+
+func TestHelloRetryDial(t *testing.T) {
+	listener, acceptErr := startRetryListener(t, HelloRetryConfig{Enabled: true})
+
+	tunnel, err := Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer tunnel.Close()
+
+	if err := <-acceptErr; err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+}
+
+func TestHelloRetryForgedCookie(t *testing.T) {
+	listener, acceptErr := startRetryListener(t, HelloRetryConfig{Enabled: true})
+
+	forged := crypto.MustSecureRandomBytes(cookieSize)
+	_, _, reply := sendClientHello(t, listener, forged)
+	if msgType := protocol.MessageType(reply[0]); msgType != protocol.MessageTypeAlert {
+		t.Errorf("expected Alert, got %s", msgType)
+	}
+
+	if err := <-acceptErr; !errors.Is(err, qerrors.ErrInvalidCookie) {
+		t.Errorf("expected ErrInvalidCookie, got %v", err)
+	}
+}
+
+func TestHelloRetryExpiredCookie(t *testing.T) {
+	retry := HelloRetryConfig{
+		Enabled:  true,
+		Secret:   crypto.MustSecureRandomBytes(32),
+		Lifetime: 20 * time.Millisecond,
+	}
+	listener, acceptErr := startRetryListener(t, retry)
+
+	// Cookies from another listener with the same secret are accepted while
+	// fresh, so an old one can be minted directly
+	jar := newCookieJar(retry)
+	cookie := jar.issue(netip.MustParseAddr("127.0.0.1"))
+	time.Sleep(50 * time.Millisecond)
+
+	if err := jar.verify(cookie, netip.MustParseAddr("127.0.0.1")); !errors.Is(err, qerrors.ErrExpiredCookie) {
+		t.Errorf("expected ErrExpiredCookie, got %v", err)
+	}
+
+	_, _, reply := sendClientHello(t, listener, cookie)
+	if msgType := protocol.MessageType(reply[0]); msgType != protocol.MessageTypeAlert {
+		t.Errorf("expected Alert, got %s", msgType)
+	}
+	if err := <-acceptErr; !errors.Is(err, qerrors.ErrExpiredCookie) {
+		t.Errorf("expected ErrExpiredCookie, got %v", err)
+	}
+}
+
+func TestHelloRetryTrustedNetwork(t *testing.T) {
+	listener, acceptErr := startRetryListener(t, HelloRetryConfig{
+		Enabled:         true,
+		TrustedNetworks: []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")},
+	})
+
+	// Trusted clients go straight to the ServerHello
+	_, conn, reply := sendClientHello(t, listener, nil)
+	if msgType := protocol.MessageType(reply[0]); msgType != protocol.MessageTypeServerHello {
+		t.Errorf("expected ServerHello, got %s", msgType)
+	}
+
+	conn.Close()
+	<-acceptErr
+}
+
+func TestCookieJarBinding(t *testing.T) {
+	jar := newCookieJar(HelloRetryConfig{Enabled: true})
+	ip := netip.MustParseAddr("192.0.2.1")
+	cookie := jar.issue(ip)
+
+	if err := jar.verify(cookie, ip); err != nil {
+		t.Fatalf("verify failed: %v", err)
+	}
+	if err := jar.verify(cookie, netip.MustParseAddr("192.0.2.2")); !errors.Is(err, qerrors.ErrInvalidCookie) {
+		t.Errorf("expected ErrInvalidCookie for another IP, got %v", err)
+	}
+
+	other := newCookieJar(HelloRetryConfig{Enabled: true})
+	if err := other.verify(cookie, ip); !errors.Is(err, qerrors.ErrInvalidCookie) {
+		t.Errorf("expected ErrInvalidCookie for another secret, got %v", err)
+	}
+
+	if newCookieJar(HelloRetryConfig{}) != nil {
+		t.Error("disabled config should not create a cookie jar")
+	}
+
+	// Addresses without an IP are local and skip the retry
+	local, _ := net.Pipe()
+	defer local.Close()
+	if _, ok := jar.clientIP(local.RemoteAddr()); ok {
+		t.Error("pipe addresses should skip the retry")
+	}
+}
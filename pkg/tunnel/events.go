@@ -0,0 +1,173 @@
+package tunnel
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType identifies a session event.
+type EventType int
+
+// Session event types.
+const (
+	// EventEstablished is delivered when the handshake completes.
+	EventEstablished EventType = iota + 1
+
+	// EventRekeyStarted is delivered when this side requests a rekey or
+	// answers the peer's request.
+	EventRekeyStarted
+
+	// EventRekeyCompleted is delivered when the session switches to the
+	// keys from a rekey.
+	EventRekeyCompleted
+
+	// EventReplayBlocked is delivered when the replay window rejects a
+	// record.
+	EventReplayBlocked
+
+	// EventClosed is delivered when the session closes. It is always the
+	// last event, and is never dropped.
+	EventClosed
+)
+
+// String returns the name of the event type.
+func (t EventType) String() string {
+	switch t {
+	case EventEstablished:
+		return "established"
+	case EventRekeyStarted:
+		return "rekey_started"
+	case EventRekeyCompleted:
+		return "rekey_completed"
+	case EventReplayBlocked:
+		return "replay_blocked"
+	case EventClosed:
+		return "closed"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is a session lifecycle event delivered to OnEvent subscribers.
+type Event struct {
+	Type EventType
+	Time time.Time
+
+	// Seq is the rejected sequence number (EventReplayBlocked only)
+	Seq uint64
+
+	// Reason is ReplayRejectedOld or ReplayRejectedDuplicate
+	// (EventReplayBlocked only)
+	Reason string
+}
+
+// defaultEventBufferSize is the default number of events queued per
+// subscriber.
+const defaultEventBufferSize = 64
+
+// eventBus fans session events out to OnEvent subscribers. Each subscriber
+// has its own queue and goroutine, so a slow subscriber delays only itself
+// and never the session.
+type eventBus struct {
+	mu          sync.Mutex
+	subscribers map[*eventSubscriber]struct{}
+	bufferSize  int
+	established *Event // Replayed to late subscribers
+	closed      *Event
+	dropped     atomic.Uint64
+}
+
+// eventSubscriber is the queue of one subscriber. The queue has room for
+// one event beyond bufferSize, reserved for EventClosed.
+type eventSubscriber struct {
+	queue chan Event
+}
+
+// OnEvent subscribes fn to the session's events and returns a function
+// that cancels the subscription. Events are delivered in order on a
+// goroutine owned by the subscription, so fn may block without stalling
+// the session; while it does, up to SessionConfig.EventBufferSize events
+// are queued and later ones are dropped (see DroppedEvents). EventClosed
+// is always delivered and ends the subscription.
+//
+// A subscriber added after the handshake completed first receives
+// EventEstablished, and one added after the session closed receives only
+// EventClosed.
+func (s *Session) OnEvent(fn func(Event)) (cancel func()) {
+	b := &s.events
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	size := b.bufferSize
+	if size <= 0 {
+		size = defaultEventBufferSize
+	}
+	sub := &eventSubscriber{queue: make(chan Event, size+1)}
+	go func() {
+		for event := range sub.queue {
+			fn(event)
+		}
+	}()
+
+	if b.established != nil {
+		sub.queue <- *b.established
+	}
+	if b.closed != nil {
+		sub.queue <- *b.closed
+		close(sub.queue)
+		return func() {}
+	}
+
+	if b.subscribers == nil {
+		b.subscribers = make(map[*eventSubscriber]struct{})
+	}
+	b.subscribers[sub] = struct{}{}
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subscribers[sub]; ok {
+			delete(b.subscribers, sub)
+			close(sub.queue)
+		}
+	}
+}
+
+// DroppedEvents returns the number of events dropped because a subscriber's
+// queue was full.
+func (s *Session) DroppedEvents() uint64 {
+	return s.events.dropped.Load()
+}
+
+// emit delivers event to all subscribers without blocking.
+func (s *Session) emit(event Event) {
+	b := &s.events
+	event.Time = time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed != nil {
+		return
+	}
+
+	switch event.Type {
+	case EventEstablished:
+		b.established = &event
+	case EventClosed:
+		b.closed = &event
+		for sub := range b.subscribers {
+			sub.queue <- event
+			close(sub.queue)
+		}
+		b.subscribers = nil
+		return
+	}
+
+	for sub := range b.subscribers {
+		if len(sub.queue) >= cap(sub.queue)-1 {
+			b.dropped.Add(1)
+			continue
+		}
+		sub.queue <- event
+	}
+}
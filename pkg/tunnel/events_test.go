@@ -0,0 +1,178 @@
+package tunnel
+
+import (
+	"net"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventLog collects the events delivered to one subscriber.
+type eventLog struct {
+	mu     sync.Mutex
+	events []Event
+	closed chan struct{}
+}
+
+func subscribe(s *Session) *eventLog {
+	l := &eventLog{closed: make(chan struct{})}
+	s.OnEvent(func(e Event) {
+		l.mu.Lock()
+		l.events = append(l.events, e)
+		l.mu.Unlock()
+		if e.Type == EventClosed {
+			close(l.closed)
+		}
+	})
+	return l
+}
+
+// types waits for EventClosed and returns the delivered event types.
+func (l *eventLog) types(t *testing.T) []EventType {
+	t.Helper()
+	select {
+	case <-l.closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for EventClosed")
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	types := make([]EventType, len(l.events))
+	for i, e := range l.events {
+		types[i] = e.Type
+	}
+	return types
+}
+
+func TestSessionEventSubscribers(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	first, second := subscribe(client), subscribe(client)
+
+	done := make(chan error, 1)
+	go func() { done <- runResponderHandshake(server, serverConn, func(*Handshake) {}) }()
+	if err := runInitiatorHandshake(client, clientConn, func(*Handshake) {}); err != nil {
+		t.Fatalf("initiator handshake failed: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("responder handshake failed: %v", err)
+	}
+	client.Close()
+	client.Close()
+
+	want := []EventType{EventEstablished, EventClosed}
+	for name, l := range map[string]*eventLog{"first": first, "second": second} {
+		if got := l.types(t); !slices.Equal(got, want) {
+			t.Errorf("%s subscriber got %v, want %v", name, got, want)
+		}
+	}
+
+	// Late subscribers see the established session, then its close
+	if got := subscribe(client).types(t); !slices.Equal(got, want) {
+		t.Errorf("late subscriber got %v, want %v", got, want)
+	}
+}
+
+func TestSessionEventsRekeyAndReplay(t *testing.T) {
+	client, server := newPipeTransports(t)
+	clientEvents, serverEvents := subscribe(client.session), subscribe(server.session)
+
+	response := exchangeRekey(t, client, server)
+	if err := client.handleRekey(response); err != nil {
+		t.Fatalf("client handleRekey failed: %v", err)
+	}
+	client.session.ActivatePendingKeys()
+	server.session.ActivatePendingKeys()
+
+	ciphertext, seq, err := client.session.Encrypt([]byte("once"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if _, err := server.session.Decrypt(ciphertext, seq); err != nil {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	if _, err := server.session.Decrypt(ciphertext, seq); err == nil {
+		t.Fatal("replayed record was accepted")
+	}
+	client.session.Close()
+	server.session.Close()
+
+	if got, want := clientEvents.types(t), []EventType{EventRekeyStarted, EventRekeyCompleted, EventClosed}; !slices.Equal(got, want) {
+		t.Errorf("client got %v, want %v", got, want)
+	}
+	want := []EventType{EventRekeyStarted, EventRekeyCompleted, EventReplayBlocked, EventClosed}
+	if got := serverEvents.types(t); !slices.Equal(got, want) {
+		t.Fatalf("server got %v, want %v", got, want)
+	}
+	if replay := serverEvents.events[2]; replay.Seq != seq || replay.Reason != ReplayRejectedDuplicate {
+		t.Errorf("replay event = %+v, want seq %d reason %q", replay, seq, ReplayRejectedDuplicate)
+	}
+}
+
+func TestSessionEventsDropWhenFull(t *testing.T) {
+	config := DefaultSessionConfig()
+	config.EventBufferSize = 1
+	session, _ := NewSessionWithConfig(RoleResponder, config)
+
+	// The subscriber blocks on its first event while more arrive
+	blocked, release := make(chan struct{}), make(chan struct{})
+	var delivered []EventType
+	closed := make(chan struct{})
+	session.OnEvent(func(e Event) {
+		if len(delivered) == 0 {
+			close(blocked)
+			<-release
+		}
+		delivered = append(delivered, e.Type)
+		if e.Type == EventClosed {
+			close(closed)
+		}
+	})
+
+	session.emit(Event{Type: EventRekeyStarted})
+	<-blocked
+	for range 3 {
+		session.emit(Event{Type: EventReplayBlocked})
+	}
+	session.Close()
+	close(release)
+	<-closed
+
+	// One event in the subscriber, one queued, the rest dropped, and
+	// EventClosed still delivered
+	want := []EventType{EventRekeyStarted, EventReplayBlocked, EventClosed}
+	if !slices.Equal(delivered, want) {
+		t.Errorf("delivered %v, want %v", delivered, want)
+	}
+	if got := session.DroppedEvents(); got != 2 {
+		t.Errorf("DroppedEvents = %d, want 2", got)
+	}
+}
+
+func TestSessionEventCancel(t *testing.T) {
+	session, _ := NewSession(RoleInitiator)
+	var mu sync.Mutex
+	var got []EventType
+	cancel := session.OnEvent(func(e Event) {
+		mu.Lock()
+		got = append(got, e.Type)
+		mu.Unlock()
+	})
+	cancel()
+	cancel()
+
+	session.emit(Event{Type: EventReplayBlocked})
+	session.Close()
+	time.Sleep(10 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(got) != 0 {
+		t.Errorf("cancelled subscriber received %v", got)
+	}
+}
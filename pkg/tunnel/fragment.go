@@ -0,0 +1,115 @@
+package tunnel
+
+import (
+	"encoding/binary"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// fragmentHeaderSize is the size of the Index(4B) || Count(4B) header that
+// prefixes each record's data when fragmentation is enabled.
+const fragmentHeaderSize = 8
+
+// fragmentCount returns the number of records needed to send n bytes with
+// up to size bytes per record. An empty message still takes one record.
+func fragmentCount(n, size int) int {
+	if n == 0 {
+		return 1
+	}
+	return (n + size - 1) / size
+}
+
+// appendFragment appends fragment index of count, carrying chunk, to buf.
+func appendFragment(buf []byte, index, count int, chunk []byte) []byte {
+	var header [fragmentHeaderSize]byte
+	//nolint:gosec // G115: index < count, bounded by MaxMessageSize checks
+	binary.BigEndian.PutUint32(header[0:], uint32(index))
+	//nolint:gosec // G115: count is bounded by MaxMessageSize checks
+	binary.BigEndian.PutUint32(header[4:], uint32(count))
+	buf = append(buf, header[:]...)
+	return append(buf, chunk...)
+}
+
+// reassembler rebuilds messages that were split across data records.
+//
+// Each record's data starts with a fragment header giving its index within
+// the message and the message's fragment count. Fragments must arrive in
+// order, which the reorder buffer guarantees on transports that reorder.
+// The reassembled message is bounded by limit, so a peer cannot exhaust
+// memory by claiming a huge message. The reassembler is only used by the
+// receiving goroutine.
+type reassembler struct {
+	limit int
+	buf   []byte // Data of the message being reassembled
+	next  uint32 // Index of the next expected fragment, 0 between messages
+	count uint32 // Fragment count of the message being reassembled
+}
+
+// newReassembler creates a reassembler for messages of up to limit bytes,
+// or returns nil if limit is 0, which disables fragmentation.
+func newReassembler(limit int) *reassembler {
+	if limit <= 0 {
+		return nil
+	}
+	return &reassembler{limit: limit}
+}
+
+// add processes one record's data. It returns the message and true once its
+// last fragment arrives. A nil reassembler returns data unchanged.
+func (r *reassembler) add(data []byte) ([]byte, bool, error) {
+	if r == nil {
+		return data, true, nil
+	}
+
+	if len(data) < fragmentHeaderSize {
+		return nil, false, qerrors.ErrInvalidMessage
+	}
+	index := binary.BigEndian.Uint32(data[0:])
+	count := binary.BigEndian.Uint32(data[4:])
+	chunk := data[fragmentHeaderSize:]
+
+	if index != r.next || (index > 0 && count != r.count) {
+		return nil, false, qerrors.ErrInvalidMessage
+	}
+	if index == 0 {
+		// Every fragment but a lone one carries at least one byte, so a
+		// count beyond the limit can never be satisfied
+		if count == 0 || uint64(count) > uint64(max(r.limit, 1)) {
+			return nil, false, qerrors.ErrMessageTooLarge
+		}
+		if count == 1 {
+			if len(chunk) > r.limit {
+				return nil, false, qerrors.ErrMessageTooLarge
+			}
+			return chunk, true, nil
+		}
+		r.count = count
+		r.buf = nil
+	}
+
+	if len(r.buf)+len(chunk) > r.limit {
+		r.reset()
+		return nil, false, qerrors.ErrMessageTooLarge
+	}
+	r.buf = append(r.buf, chunk...)
+	r.next++
+
+	if r.next < r.count {
+		return nil, false, nil
+	}
+	msg := r.buf
+	r.reset()
+	return msg, true, nil
+}
+
+// pending reports whether a message is partially reassembled.
+func (r *reassembler) pending() bool {
+	return r != nil && r.next > 0
+}
+
+// reset discards any partially reassembled message.
+func (r *reassembler) reset() {
+	r.buf = nil
+	r.next = 0
+	r.count = 0
+}
@@ -0,0 +1,155 @@
+package tunnel
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+// newFragmentingTransports returns a connected transport pair with
+// fragmentation enabled for messages of up to limit bytes.
+func newFragmentingTransports(t *testing.T, limit int) (*Transport, *Transport) {
+	t.Helper()
+
+	client, server := newPipeTransports(t)
+	client.reassembly = newReassembler(limit)
+	server.reassembly = newReassembler(limit)
+	return client, server
+}
+
+// sealFragment encrypts a raw fragment record on tr.
+func sealFragment(t *testing.T, tr *Transport, index, count int, chunk []byte) []byte {
+	t.Helper()
+
+	ciphertext, seq, err := tr.session.Encrypt(appendFragment(nil, index, count, chunk))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	msg, err := tr.codec.EncodeData(seq, ciphertext)
+	if err != nil {
+		t.Fatalf("EncodeData failed: %v", err)
+	}
+	return msg
+}
+
+func TestFragmentationLargeMessages(t *testing.T) {
+	client, server := newFragmentingTransports(t, 16<<20)
+
+	sizes := []int{0, 100, maxRecordPlaintext, 1 << 20, 10 << 20}
+	messages := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		messages[i] = make([]byte, size)
+		_ = crypto.SecureRandom(messages[i])
+	}
+
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, msg := range messages {
+			if err := client.Send(msg); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- nil
+	}()
+
+	// Each Send yields exactly one Receive of the same bytes
+	for i, want := range messages {
+		got, err := server.Receive()
+		if err != nil {
+			t.Fatalf("Receive of %d bytes failed: %v", sizes[i], err)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("message of %d bytes corrupted: got %d bytes", sizes[i], len(got))
+		}
+	}
+	if err := <-sendErr; err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	if err := client.Send(make([]byte, 16<<20+1)); !errors.Is(err, qerrors.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge above MaxMessageSize, got %v", err)
+	}
+}
+
+func TestFragmentationMaliciousClaims(t *testing.T) {
+	const limit = 1 << 20
+
+	tests := []struct {
+		name string
+		seal func(t *testing.T, client *Transport) [][]byte
+		want error
+	}{
+		{
+			name: "absurd fragment count",
+			seal: func(t *testing.T, client *Transport) [][]byte {
+				return [][]byte{sealFragment(t, client, 0, 1<<31, []byte("x"))}
+			},
+			want: qerrors.ErrMessageTooLarge,
+		},
+		{
+			name: "fragments beyond the limit",
+			seal: func(t *testing.T, client *Transport) [][]byte {
+				chunk := make([]byte, maxRecordPlaintext-fragmentHeaderSize)
+				count := limit/len(chunk) + 2
+				records := make([][]byte, count)
+				for i := range records {
+					records[i] = sealFragment(t, client, i, count, chunk)
+				}
+				return records
+			},
+			want: qerrors.ErrMessageTooLarge,
+		},
+		{
+			name: "zero fragment count",
+			seal: func(t *testing.T, client *Transport) [][]byte {
+				return [][]byte{sealFragment(t, client, 0, 0, nil)}
+			},
+			want: qerrors.ErrMessageTooLarge,
+		},
+		{
+			name: "skipped fragment",
+			seal: func(t *testing.T, client *Transport) [][]byte {
+				return [][]byte{
+					sealFragment(t, client, 0, 3, []byte("a")),
+					sealFragment(t, client, 2, 3, []byte("c")),
+				}
+			},
+			want: qerrors.ErrInvalidMessage,
+		},
+		{
+			name: "changed fragment count",
+			seal: func(t *testing.T, client *Transport) [][]byte {
+				return [][]byte{
+					sealFragment(t, client, 0, 3, []byte("a")),
+					sealFragment(t, client, 1, 2, []byte("b")),
+				}
+			},
+			want: qerrors.ErrInvalidMessage,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newFragmentingTransports(t, limit)
+
+			records := tt.seal(t, client)
+			order := make([]int, len(records))
+			for i := range order {
+				order[i] = i
+			}
+			writeRecords(client, records, order)
+
+			var err error
+			for err == nil {
+				_, err = server.Receive()
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
@@ -6,12 +6,15 @@
 //	    |                                      |
 //	    | -------- ClientHello --------------> |
 //	    |   - version, random                  |
-//	    |   - CH-KEM public key                |
+//	    |   - KEM params, CH-KEM public key    |
 //	    |   - cipher suites                    |
 //	    |                                      |
+//	    | <------- HelloRetryRequest --------- |  (optional)
+//	    | -------- ClientHello + cookie -----> |
+//	    |                                      |
 //	    | <------- ServerHello --------------- |
 //	    |   - version, random                  |
-//	    |   - CH-KEM ciphertext                |
+//	    |   - KEM params, CH-KEM ciphertext    |
 //	    |   - selected cipher suite            |
 //	    |                                      |
 //	    |   [Both derive shared secret]        |
@@ -24,6 +27,11 @@
 //	    |                                      |
 //	    |    === Tunnel Established ===        |
 //
+// A responder with a ResumptionStore may instead run the abbreviated
+// handshake described in resumption.go. A Listener with HelloRetry enabled
+// first answers with a HelloRetryRequest (see cookie.go) and only performs
+// CH-KEM work once the client echoes a valid cookie.
+//
 // Security Properties:
 //   - Forward secrecy: Ephemeral keys used for each session
 //   - Quantum resistance: CH-KEM hybrid key exchange
@@ -34,8 +42,13 @@ package tunnel
 import (
 	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/binary"
+	"fmt"
 	"io"
+	"net"
+	"net/netip"
+	"time"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
@@ -81,22 +94,90 @@ type Handshake struct {
 	recvCipher *crypto.AEAD
 
 	// Transcript for verify_data computation
-	transcript bytes.Buffer
+	transcript       bytes.Buffer
+	transcriptHasher crypto.TranscriptHasher // Hashes the transcript for the key schedule
 
 	// Resumption state
 	ticket        []byte         // Client ticket to send
 	ticketSecret  []byte         // Initiator's secret for the ticket
 	ticketManager *TicketManager // Server ticket manager to verify
 	resumed       bool           // Whether this is a resumed session
+
+	// Abbreviated resumption state
+	resumptionStore ResumptionStore // Server store of resumable sessions
+	abbreviated     bool            // Whether the CH-KEM exchange is skipped
+	resumedFrom     time.Time       // Establishment time of the resumed session
+
+	// 0-RTT early data state
+	earlyData         []byte                // Initiator: data to send; responder: data accepted
+	allowEarlyData    bool                  // Responder accepts early data
+	earlyDataOffered  bool                  // The ClientHello announced an early data record
+	earlyDataSuite    constants.CipherSuite // Suite protecting the early data record
+	earlyDataAccepted bool                  // The responder accepted the early data
+
+	// Retry cookie state
+	cookies      *cookieJar // Responder cookie jar, nil if no retry is required
+	cookieIP     netip.Addr // Client IP the responder binds cookies to
+	cookie       []byte     // Cookie echoed by the initiator
+	retryPending bool       // Responder must send a HelloRetryRequest
+	retried      bool       // A HelloRetryRequest was already exchanged
+
+	// Responder cache of recent ClientHello randoms, nil if replays are allowed
+	helloReplay *helloReplayCache
+
+	// Responder authentication state
+	identityKey     *IdentityKey      // Responder identity key, nil if anonymous
+	pinnedServerKey ed25519.PublicKey // Identity the initiator requires, nil for any
+
+	// External pre-shared key mixed into the shared secret, nil if unused
+	psk []byte
+
+	// CipherSuiteNullEncryption may be negotiated
+	allowNullEncryption bool
+
+	// External authorization: the initiator's token and the responder's
+	// hook, called with authorizeCtx
+	authToken    []byte
+	authorizer   Authorizer
+	authorizeCtx context.Context
+
+	// Record options negotiated through hello extensions (see negotiation.go)
+	negotiateRecords    bool        // SetCompression or SetPadding was called
+	compression         Compression // Local compression setting
+	compressionRequired bool        // Initiator fails if compression is declined
+	compressionOffers   []byte      // Responder: algorithms the initiator offered
+	padding             bool        // Local peer pads records
+	paddingOffered      bool        // Responder: the initiator pads records
+
+	// Logger for handshake events, nil if logging is disabled
+	logger Logger
 }
 
 // NewHandshake creates a new handshake for the given session.
 func NewHandshake(session *Session) *Handshake {
-	return &Handshake{
-		session: session,
-		codec:   protocol.NewCodec(),
-		state:   HandshakeStateInitial,
+	h := &Handshake{
+		session:          session,
+		codec:            protocol.NewCodec(),
+		state:            HandshakeStateInitial,
+		transcriptHasher: crypto.SHA3TranscriptHasher{},
+	}
+	if session.logger != nil {
+		h.logger = session.logger.Named("handshake")
 	}
+	return h
+}
+
+// SetTranscriptHasher replaces the hash algorithm applied to the transcript
+// for the session's exporter and resumption secrets and for identity
+// signatures. Both peers must use the same algorithm.
+// Default: crypto.SHA3TranscriptHasher
+func (h *Handshake) SetTranscriptHasher(hasher crypto.TranscriptHasher) {
+	h.transcriptHasher = hasher
+}
+
+// transcriptHash hashes the transcript so far.
+func (h *Handshake) transcriptHash() ([]byte, error) {
+	return h.transcriptHasher.Hash(h.transcript.Bytes())
 }
 
 // SetTicket sets the session ticket for resumption (initiator).
@@ -110,6 +191,79 @@ func (h *Handshake) SetTicketManager(tm *TicketManager) {
 	h.ticketManager = tm
 }
 
+// SetResumptionStore sets the store used to resume sessions with an
+// abbreviated handshake (responder).
+func (h *Handshake) SetResumptionStore(store ResumptionStore) {
+	h.resumptionStore = store
+}
+
+// SetEarlyData makes the initiator send data as 0-RTT early data right
+// after the ClientHello. It only takes effect together with SetTicket, and
+// data over MaxEarlyDataSize is not sent. Whether the responder accepted it
+// is reported by Session.EarlyDataAccepted once the handshake completes.
+func (h *Handshake) SetEarlyData(data []byte) {
+	h.earlyData = data
+}
+
+// SetAllowEarlyData makes the responder accept 0-RTT early data on
+// abbreviated handshakes. Early data is only accepted if the resumption
+// store implements EarlyDataStore, which prevents replays.
+func (h *Handshake) SetAllowEarlyData(allow bool) {
+	h.allowEarlyData = allow
+}
+
+// SetPSK mixes an external pre-shared key into the handshake secret. Both
+// peers must set the same PSK; a mismatch fails Finished verification.
+// A nil or empty psk leaves the handshake unchanged.
+func (h *Handshake) SetPSK(psk []byte) {
+	h.psk = psk
+}
+
+// SetAllowNullEncryption lets the handshake negotiate the unencrypted
+// constants.CipherSuiteNullEncryption: the initiator offers it ahead of
+// the other suites and the responder selects it when offered. It has no
+// effect in FIPS mode. See TransportConfig.AllowNullEncryption.
+func (h *Handshake) SetAllowNullEncryption(allow bool) {
+	h.allowNullEncryption = allow
+}
+
+// Authorizer decides whether a responder accepts a client, given its
+// ClientHello. Returning an error refuses the client. See
+// TransportConfig.Authorizer.
+type Authorizer func(ctx context.Context, hello *protocol.ClientHello) error
+
+// SetAuthToken makes the initiator send token to the responder's
+// authorizer in the ClientHello. A nil or empty token is not sent.
+func (h *Handshake) SetAuthToken(token []byte) {
+	h.authToken = token
+}
+
+// SetAuthorizer makes the responder call authorize, with ctx, on each
+// ClientHello before doing any CH-KEM work. A nil authorizer accepts
+// every client. See TransportConfig.Authorizer.
+func (h *Handshake) SetAuthorizer(ctx context.Context, authorize Authorizer) {
+	h.authorizer = authorize
+	h.authorizeCtx = ctx
+}
+
+// requireCookie makes the responder demand a retry cookie from clients at
+// addr. Local and trusted addresses, and a nil jar, skip the retry.
+func (h *Handshake) requireCookie(jar *cookieJar, addr net.Addr) {
+	if jar == nil {
+		return
+	}
+	if ip, ok := jar.clientIP(addr); ok {
+		h.cookies = jar
+		h.cookieIP = ip
+	}
+}
+
+// rejectReplayedHellos makes the responder refuse a ClientHello whose
+// random is already in cache. A nil cache allows replays.
+func (h *Handshake) rejectReplayedHellos(cache *helloReplayCache) {
+	h.helloReplay = cache
+}
+
 // sendHandshakeAlert sends a handshake failure alert. Best effort.
 func sendHandshakeAlert(rw io.ReadWriter, codec *protocol.Codec, code protocol.AlertCode, desc string) {
 	msg := codec.EncodeAlert(protocol.AlertLevelFatal, code, desc)
@@ -127,12 +281,72 @@ func (h *Handshake) CreateClientHello() ([]byte, error) {
 	// Generate client random
 	h.clientRandom = crypto.MustSecureRandomBytes(32)
 
+	// Early data needs a resumption secret to derive its key from
+	h.earlyDataOffered = len(h.earlyData) > 0 && len(h.earlyData) <= MaxEarlyDataSize &&
+		len(h.ticket) > 0 && len(h.ticketSecret) == constants.CHKEMSharedSecretSize
+
+	data, err := h.encodeClientHello()
+	if err != nil {
+		return nil, err
+	}
+
+	h.state = HandshakeStateClientHelloSent
+	h.session.SetState(SessionStateHandshaking)
+
+	return data, nil
+}
+
+// ProcessHelloRetryRequest processes a HelloRetryRequest and returns the
+// ClientHello to resend with the server's cookie (initiator). Only one retry
+// is allowed per handshake.
+func (h *Handshake) ProcessHelloRetryRequest(data []byte) ([]byte, error) {
+	h.logMessage("received handshake message", protocol.MessageTypeHelloRetryRequest, len(data))
+
+	if h.state != HandshakeStateClientHelloSent || h.retried {
+		return nil, qerrors.ErrInvalidState
+	}
+
+	msg, err := h.codec.DecodeHelloRetryRequest(data)
+	if err != nil {
+		return nil, err
+	}
+	h.retried = true
+	h.cookie = msg.Cookie
+
+	// The responder discarded the early data with the first ClientHello;
+	// it is sent after the handshake instead
+	h.earlyDataOffered = false
+
+	// The transcript starts over with the ClientHello carrying the cookie,
+	// since the stateless responder does not remember the first one
+	h.transcript.Reset()
+	return h.encodeClientHello()
+}
+
+// encodeClientHello encodes the ClientHello and adds it to the transcript.
+func (h *Handshake) encodeClientHello() ([]byte, error) {
 	msg := &protocol.ClientHello{
 		Version:        protocol.Current,
 		Random:         h.clientRandom,
 		SessionID:      h.ticket,
+		KEMParameters:  h.session.LocalKeyPair.Parameters(),
 		CHKEMPublicKey: h.session.LocalKeyPair.PublicKey().Bytes(),
-		CipherSuites:   protocol.SupportedCipherSuites(),
+		CipherSuites:   h.offeredCipherSuites(),
+		Cookie:         h.cookie,
+	}
+	extensions := make(map[uint16][]byte)
+	if tp := h.session.traceParent(); tp != "" {
+		extensions[protocol.ExtensionTraceParent] = []byte(tp)
+	}
+	if h.earlyDataOffered {
+		extensions[protocol.ExtensionEarlyData] = []byte{}
+	}
+	if len(h.authToken) > 0 {
+		extensions[protocol.ExtensionAuthToken] = h.authToken
+	}
+	h.offerRecordOptions(extensions)
+	if len(extensions) > 0 {
+		msg.Extensions = extensions
 	}
 
 	data, err := h.codec.EncodeClientHello(msg)
@@ -143,14 +357,14 @@ func (h *Handshake) CreateClientHello() ([]byte, error) {
 	// Add to transcript
 	h.transcript.Write(data)
 
-	h.state = HandshakeStateClientHelloSent
-	h.session.SetState(SessionStateHandshaking)
-
+	h.logMessage("sending handshake message", protocol.MessageTypeClientHello, len(data))
 	return data, nil
 }
 
 // ProcessServerHello processes the ServerHello message (initiator).
 func (h *Handshake) ProcessServerHello(data []byte) error {
+	h.logMessage("received handshake message", protocol.MessageTypeServerHello, len(data))
+
 	if h.state != HandshakeStateClientHelloSent {
 		return qerrors.ErrInvalidState
 	}
@@ -160,39 +374,76 @@ func (h *Handshake) ProcessServerHello(data []byte) error {
 		return err
 	}
 
-	// Validate version compatibility
-	if !msg.Version.IsCompatible(protocol.Current) {
+	// Validate version compatibility; the responder may not select a
+	// version newer than the one we offered
+	if !msg.Version.IsCompatible(protocol.Current) || msg.Version.Uint16() > protocol.Current.Uint16() {
 		return qerrors.ErrUnsupportedVersion
 	}
 
-	// Check if server accepted resumption
-	if len(msg.SessionID) > 0 && h.ticket != nil && bytes.Equal(msg.SessionID, h.ticket) {
-		h.resumed = true
+	// The server must accept the parameter set we offered
+	if msg.Parameters() != h.session.LocalKeyPair.Parameters() {
+		return qerrors.ErrUnsupportedKEMParameters
+	}
+
+	// ...and select one of the cipher suites we offered
+	if !containsCipherSuite(h.offeredCipherSuites(), msg.CipherSuite) {
+		return qerrors.ErrUnsupportedCipherSuite
 	}
 
 	// Store server random
 	h.serverRandom = msg.Random
 
-	// Always decapsulate (server always sends real ciphertext now)
-	ct, err := chkem.ParseCiphertext(msg.CHKEMCiphertext)
-	if err != nil {
-		return err
-	}
+	if msg.Resumed {
+		// Abbreviated mode: the server may only resume the session we offered
+		if h.ticket == nil || !bytes.Equal(msg.SessionID, h.ticket) {
+			return qerrors.NewProtocolError("handshake", qerrors.ErrInvalidMessage)
+		}
+		h.abbreviated = true
+		h.sharedSecret, err = crypto.DeriveAbbreviatedSecret(h.ticketSecret, h.clientRandom, h.serverRandom)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Check if server accepted resumption
+		if len(msg.SessionID) > 0 && h.ticket != nil && bytes.Equal(msg.SessionID, h.ticket) {
+			h.resumed = true
+		}
 
-	freshSecret, err := chkem.Decapsulate(ct, h.session.LocalKeyPair)
-	if err != nil {
+		ct, err := chkem.ParseCiphertext(msg.CHKEMCiphertext)
+		if err != nil {
+			return err
+		}
+
+		freshSecret, err := chkem.Decapsulate(ct, h.session.LocalKeyPair)
+		if err != nil {
+			return fmt.Errorf("%w: %w", qerrors.ErrDecapsulationFailed, err)
+		}
+
+		if h.resumed {
+			// PSK+KEM mode: mix ticket secret with fresh KEM secret
+			h.sharedSecret, err = crypto.DeriveResumptionSecret(h.ticketSecret, freshSecret)
+			if err != nil {
+				return err
+			}
+			crypto.Zeroize(freshSecret)
+		} else {
+			h.sharedSecret = freshSecret
+		}
+	}
+	if err := h.mixPSK(); err != nil {
 		return err
 	}
 
-	if h.resumed {
-		// PSK+KEM mode: mix ticket secret with fresh KEM secret
-		h.sharedSecret, err = crypto.DeriveResumptionSecret(h.ticketSecret, freshSecret)
-		if err != nil {
-			return err
+	// The responder may only accept early data on the abbreviated
+	// handshake we offered it with
+	if _, ok := msg.Extensions[protocol.ExtensionEarlyData]; ok {
+		if !h.earlyDataOffered || !h.abbreviated {
+			return qerrors.NewProtocolError("handshake", qerrors.ErrInvalidMessage)
 		}
-		crypto.Zeroize(freshSecret)
-	} else {
-		h.sharedSecret = freshSecret
+		h.earlyDataAccepted = true
+	}
+	if err := h.acceptRecordOptions(msg.Extensions); err != nil {
+		return err
 	}
 
 	// Add to transcript
@@ -202,6 +453,7 @@ func (h *Handshake) ProcessServerHello(data []byte) error {
 	h.session.ID = msg.SessionID
 	h.session.Version = msg.Version
 	h.session.CipherSuite = msg.CipherSuite
+	h.session.KEMParameters = msg.Parameters()
 
 	// Derive handshake keys
 	return h.deriveHandshakeKeys()
@@ -214,7 +466,10 @@ func (h *Handshake) CreateClientFinished() ([]byte, error) {
 	}
 
 	// Compute verify_data = SHAKE-256(sharedSecret || transcript || "client finished")
-	// Including the shared secret proves both sides hold the same key material
+	// Including the shared secret proves both sides hold the same key material.
+	// The transcript holds the raw hellos, so verify_data also binds the
+	// offered and negotiated versions, KEM parameters and cipher suites:
+	// a peer that saw an edited hello computes different verify_data.
 	verifyData, err := crypto.DeriveKeyMultiple(
 		"CH-KEM-VPN-ClientFinished",
 		[][]byte{h.sharedSecret, h.transcript.Bytes()},
@@ -241,11 +496,14 @@ func (h *Handshake) CreateClientFinished() ([]byte, error) {
 
 	h.state = HandshakeStateClientFinishedSent
 
+	h.logMessage("sending handshake message", protocol.MessageTypeClientFinished, len(ciphertext))
 	return ciphertext, nil
 }
 
 // ProcessServerFinished processes the ServerFinished message (initiator).
 func (h *Handshake) ProcessServerFinished(data []byte) error {
+	h.logMessage("received handshake message", protocol.MessageTypeServerFinished, len(data))
+
 	if h.state != HandshakeStateClientFinishedSent {
 		return qerrors.ErrInvalidState
 	}
@@ -256,8 +514,8 @@ func (h *Handshake) ProcessServerFinished(data []byte) error {
 		return qerrors.NewProtocolError("handshake", qerrors.ErrAuthenticationFailed)
 	}
 
-	// Decode verify_data
-	verifyData, err := h.codec.DecodeFinished(plaintext)
+	// Decode verify_data and the optional responder identity
+	msg, err := h.codec.DecodeServerFinished(plaintext)
 	if err != nil {
 		return err
 	}
@@ -273,16 +531,22 @@ func (h *Handshake) ProcessServerFinished(data []byte) error {
 	}
 
 	// Verify
-	if !crypto.ConstantTimeCompare(verifyData, expectedVerifyData) {
+	if !crypto.ConstantTimeCompare(msg.VerifyData, expectedVerifyData) {
 		return qerrors.NewProtocolError("handshake", qerrors.ErrAuthenticationFailed)
 	}
 
-	// Initialize session with traffic keys
-	if err := h.session.InitializeKeys(h.sharedSecret, h.session.CipherSuite); err != nil {
+	// Authenticate the responder against the pinned identity key
+	if err := h.verifyIdentity(msg); err != nil {
+		return err
+	}
+
+	// Initialize session with traffic keys, keeping the transcript hash for exporters
+	if err := h.initializeSessionKeys(); err != nil {
 		return err
 	}
 
 	h.state = HandshakeStateComplete
+	h.session.emit(Event{Type: EventEstablished})
 
 	// Cleanup
 	h.cleanup()
@@ -294,6 +558,8 @@ func (h *Handshake) ProcessServerFinished(data []byte) error {
 
 // ProcessClientHello processes the ClientHello message (responder).
 func (h *Handshake) ProcessClientHello(data []byte) error {
+	h.logMessage("received handshake message", protocol.MessageTypeClientHello, len(data))
+
 	if h.state != HandshakeStateInitial {
 		return qerrors.ErrInvalidState
 	}
@@ -302,17 +568,55 @@ func (h *Handshake) ProcessClientHello(data []byte) error {
 	if err != nil {
 		return err
 	}
+	h.session.continueTrace(string(msg.Extensions[protocol.ExtensionTraceParent]))
+	_, h.earlyDataOffered = msg.Extensions[protocol.ExtensionEarlyData]
+	if h.earlyDataOffered && len(msg.CipherSuites) > 0 {
+		h.earlyDataSuite = msg.CipherSuites[0]
+	}
+	h.compressionOffers = msg.Extensions[protocol.ExtensionCompression]
+	_, h.paddingOffered = msg.Extensions[protocol.ExtensionPadding]
 
 	// Validate version
 	if !msg.Version.IsCompatible(protocol.Current) {
 		return qerrors.ErrUnsupportedVersion
 	}
+	if err := h.checkKeyCommitment(msg.Extensions); err != nil {
+		return err
+	}
+
+	// Require a valid cookie before any CH-KEM work
+	if h.cookies != nil {
+		if len(msg.Cookie) == 0 && !h.retried {
+			h.retryPending = true
+			return nil
+		}
+		if err := h.cookies.verify(msg.Cookie, h.cookieIP); err != nil {
+			return err
+		}
+	}
+
+	// Let the application refuse the client before any CH-KEM work. This
+	// runs ahead of the replay check so that refused clients do not fill
+	// the replay cache
+	if err := h.authorize(msg); err != nil {
+		return err
+	}
+
+	// Refuse a replayed ClientHello before any CH-KEM work
+	if h.helloReplay != nil {
+		if err := h.helloReplay.check(msg.Random); err != nil {
+			return err
+		}
+	}
 
 	// Store client random
 	h.clientRandom = msg.Random
 
-	// Check for resumption
-	if len(msg.SessionID) > 0 && h.ticketManager != nil {
+	// Check for an abbreviated resumption, then for a PSK+KEM ticket
+	if len(msg.SessionID) > 0 && h.resumptionStore != nil {
+		h.lookupResumption(msg.SessionID, msg.CipherSuites)
+	}
+	if !h.abbreviated && len(msg.SessionID) > 0 && h.ticketManager != nil {
 		secret, err := h.session.Resume(msg.SessionID, h.ticketManager)
 		if err == nil {
 			h.resumed = true
@@ -327,9 +631,13 @@ func (h *Handshake) ProcessClientHello(data []byte) error {
 		return err
 	}
 	h.session.RemotePublicKey = clientPublicKey
+	h.session.KEMParameters = clientPublicKey.Parameters()
 
-	// Select cipher suite (first mutually supported)
-	h.session.CipherSuite = selectCipherSuite(msg.CipherSuites)
+	// Select cipher suite (first mutually supported); an abbreviated
+	// handshake keeps the suite of the resumed session
+	if !h.abbreviated {
+		h.session.CipherSuite = selectCipherSuite(msg.CipherSuites, h.supportedCipherSuites())
+	}
 	if !h.session.CipherSuite.IsSupported() {
 		return qerrors.ErrUnsupportedCipherSuite
 	}
@@ -337,12 +645,55 @@ func (h *Handshake) ProcessClientHello(data []byte) error {
 	// Add to transcript
 	h.transcript.Write(data)
 
-	h.session.Version = msg.Version
+	h.session.Version = protocol.Negotiate(msg.Version)
 	h.session.SetState(SessionStateHandshaking)
 
 	return nil
 }
 
+// authorize runs the responder's authorizer on msg, wrapping its error in
+// ErrUnauthorized.
+func (h *Handshake) authorize(msg *protocol.ClientHello) error {
+	if h.authorizer == nil {
+		return nil
+	}
+	ctx := h.authorizeCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if err := h.authorizer(ctx, msg); err != nil {
+		return fmt.Errorf("%w: %w", qerrors.ErrUnauthorized, err)
+	}
+	return nil
+}
+
+// RetryPending reports whether the responder must answer the last
+// ClientHello with a HelloRetryRequest instead of a ServerHello.
+func (h *Handshake) RetryPending() bool {
+	return h.retryPending
+}
+
+// CreateHelloRetryRequest generates a HelloRetryRequest carrying a fresh
+// cookie (responder). The client must answer with a new ClientHello.
+func (h *Handshake) CreateHelloRetryRequest() ([]byte, error) {
+	if h.state != HandshakeStateInitial || !h.retryPending {
+		return nil, qerrors.ErrInvalidState
+	}
+	h.retryPending = false
+	h.retried = true
+
+	data, err := h.codec.EncodeHelloRetryRequest(&protocol.HelloRetryRequest{
+		Version: protocol.Current,
+		Cookie:  h.cookies.issue(h.cookieIP),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	h.logMessage("sending handshake message", protocol.MessageTypeHelloRetryRequest, len(data))
+	return data, nil
+}
+
 // CreateServerHello generates the ServerHello message.
 func (h *Handshake) CreateServerHello() ([]byte, error) {
 	if h.session.RemotePublicKey == nil {
@@ -352,30 +703,61 @@ func (h *Handshake) CreateServerHello() ([]byte, error) {
 	// Generate server random
 	h.serverRandom = crypto.MustSecureRandomBytes(32)
 
-	// Always perform fresh KEM exchange (even during resumption for forward secrecy)
-	ct, freshSecret, err := chkem.Encapsulate(h.session.RemotePublicKey)
-	if err != nil {
-		return nil, err
-	}
-	ctBytes := ct.Bytes()
-
-	if h.resumed {
-		// PSK+KEM mode: mix ticket secret with fresh KEM secret
-		h.sharedSecret, err = crypto.DeriveResumptionSecret(h.ticketSecret, freshSecret)
+	var ctBytes []byte
+	var err error
+	if h.abbreviated {
+		// Abbreviated mode: skip the KEM, deriving fresh keys from the
+		// stored resumption secret and both randoms
+		h.sharedSecret, err = crypto.DeriveAbbreviatedSecret(h.ticketSecret, h.clientRandom, h.serverRandom)
 		if err != nil {
 			return nil, err
 		}
-		crypto.Zeroize(freshSecret)
 	} else {
-		h.sharedSecret = freshSecret
+		// Fresh KEM exchange (also during PSK+KEM resumption for forward secrecy)
+		var ct *chkem.Ciphertext
+		var freshSecret []byte
+		ct, freshSecret, err = chkem.Encapsulate(h.session.RemotePublicKey)
+		if err != nil {
+			return nil, err
+		}
+		ctBytes = ct.Bytes()
+
+		if h.resumed {
+			// PSK+KEM mode: mix ticket secret with fresh KEM secret
+			h.sharedSecret, err = crypto.DeriveResumptionSecret(h.ticketSecret, freshSecret)
+			if err != nil {
+				return nil, err
+			}
+			crypto.Zeroize(freshSecret)
+		} else {
+			h.sharedSecret = freshSecret
+		}
+
+		// Assign a new session ID the client can resume from later
+		if h.resumptionStore != nil && !h.resumed {
+			h.session.ID = crypto.MustSecureRandomBytes(constants.SessionIDSize)
+		}
+	}
+	if err := h.mixPSK(); err != nil {
+		return nil, err
 	}
 
 	msg := &protocol.ServerHello{
-		Version:         protocol.Current,
+		Version:         h.session.Version,
 		Random:          h.serverRandom,
 		SessionID:       h.session.ID,
+		KEMParameters:   h.session.KEMParameters,
 		CHKEMCiphertext: ctBytes,
 		CipherSuite:     h.session.CipherSuite,
+		Resumed:         h.abbreviated,
+	}
+	extensions := make(map[uint16][]byte)
+	if h.earlyDataAccepted {
+		extensions[protocol.ExtensionEarlyData] = []byte{}
+	}
+	h.selectRecordOptions(extensions)
+	if len(extensions) > 0 {
+		msg.Extensions = extensions
 	}
 
 	data, err := h.codec.EncodeServerHello(msg)
@@ -393,11 +775,14 @@ func (h *Handshake) CreateServerHello() ([]byte, error) {
 
 	h.state = HandshakeStateServerHelloSent
 
+	h.logMessage("sending handshake message", protocol.MessageTypeServerHello, len(data))
 	return data, nil
 }
 
 // ProcessClientFinished processes the ClientFinished message (responder).
 func (h *Handshake) ProcessClientFinished(data []byte) error {
+	h.logMessage("received handshake message", protocol.MessageTypeClientFinished, len(data))
+
 	if h.state != HandshakeStateServerHelloSent {
 		return qerrors.ErrInvalidState
 	}
@@ -451,8 +836,14 @@ func (h *Handshake) CreateServerFinished() ([]byte, error) {
 		return nil, err
 	}
 
+	// Sign the transcript if the responder has an identity key
+	msg := &protocol.ServerFinished{VerifyData: verifyData}
+	if err := h.signIdentity(msg); err != nil {
+		return nil, err
+	}
+
 	// Encode message
-	plaintext, err := h.codec.EncodeFinished(protocol.MessageTypeServerFinished, verifyData)
+	plaintext, err := h.codec.EncodeServerFinished(msg)
 	if err != nil {
 		return nil, err
 	}
@@ -463,58 +854,235 @@ func (h *Handshake) CreateServerFinished() ([]byte, error) {
 		return nil, err
 	}
 
-	// Initialize session with traffic keys
-	if err := h.session.InitializeKeys(h.sharedSecret, h.session.CipherSuite); err != nil {
+	// Initialize session with traffic keys, keeping the transcript hash for exporters
+	if err := h.initializeSessionKeys(); err != nil {
 		return nil, err
 	}
+	h.saveResumption()
 
 	h.state = HandshakeStateComplete
+	h.session.emit(Event{Type: EventEstablished})
 
 	// Cleanup
 	h.cleanup()
 
+	h.logMessage("sending handshake message", protocol.MessageTypeServerFinished, len(ciphertext))
 	return ciphertext, nil
 }
 
 // --- Helper Functions ---
 
+// mixPSK replaces the shared secret with one bound to the external PSK, if set.
+func (h *Handshake) mixPSK() error {
+	if len(h.psk) == 0 {
+		return nil
+	}
+	secret, err := crypto.DeriveExternalPSKSecret(h.psk, h.sharedSecret)
+	if err != nil {
+		return err
+	}
+	crypto.Zeroize(h.sharedSecret)
+	h.sharedSecret = secret
+	return nil
+}
+
 // deriveHandshakeKeys derives encryption keys for the handshake phase.
 func (h *Handshake) deriveHandshakeKeys() error {
-	initiatorKey, responderKey, _, _, err := crypto.DeriveHandshakeKeys(h.sharedSecret)
+	schedule, err := crypto.NewKeySchedule(h.sharedSecret, h.session.CipherSuite)
 	if err != nil {
 		return err
 	}
+	keys, err := schedule.HandshakeKeys()
+	if err != nil {
+		return err
+	}
+	defer keys.Zeroize()
 
 	// Set up ciphers based on role
-	var sendKey, recvKey []byte
-	if h.session.Role == RoleInitiator {
-		sendKey = initiatorKey
-		recvKey = responderKey
-	} else {
-		sendKey = responderKey
-		recvKey = initiatorKey
+	send, recv := keys.ForRole(h.session.Role == RoleInitiator)
+
+	h.sendCipher, err = crypto.NewAEAD(h.session.CipherSuite, send.Key)
+	if err != nil {
+		return err
 	}
 
-	h.sendCipher, err = crypto.NewAEAD(h.session.CipherSuite, sendKey)
+	h.recvCipher, err = crypto.NewAEAD(h.session.CipherSuite, recv.Key)
 	if err != nil {
 		return err
 	}
 
-	h.recvCipher, err = crypto.NewAEAD(h.session.CipherSuite, recvKey)
+	return nil
+}
+
+// initializeSessionKeys installs traffic keys on the session and records the
+// final transcript hash and the secret the session can be resumed from.
+func (h *Handshake) initializeSessionKeys() error {
+	transcriptHash, err := h.transcriptHash()
 	if err != nil {
 		return err
 	}
+	if err := h.session.initializeKeys(h.sharedSecret, h.session.CipherSuite, transcriptHash); err != nil {
+		return err
+	}
 
-	// Zeroize key material (sendKey/recvKey are aliases to initiatorKey/responderKey)
-	crypto.ZeroizeMultiple(initiatorKey, responderKey)
+	resumptionSecret, err := crypto.DeriveKeyMultiple(
+		constants.DomainSeparatorResumptionMaster,
+		[][]byte{h.sharedSecret, transcriptHash},
+		constants.CHKEMSharedSecretSize,
+	)
+	if err != nil {
+		return err
+	}
+	h.session.setResumption(resumptionSecret, h.abbreviated)
 
+	var earlyData []byte
+	if h.session.Role == RoleResponder {
+		earlyData = h.earlyData
+	}
+	h.session.setEarlyData(earlyData, h.earlyDataAccepted)
 	return nil
 }
 
-// selectCipherSuite selects the first mutually supported cipher suite.
-func selectCipherSuite(offered []constants.CipherSuite) constants.CipherSuite {
-	supported := protocol.SupportedCipherSuites()
+// sealEarlyData encrypts the initiator's early data under the early data
+// key, bound to the ClientHello as additional data.
+func (h *Handshake) sealEarlyData() ([]byte, error) {
+	h.earlyDataSuite = h.offeredCipherSuites()[0]
+	cipher, err := h.earlyDataCipher()
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Seal(h.earlyData, h.transcript.Bytes())
+}
 
+// openEarlyData decrypts the early data record sent with the ClientHello if
+// the responder accepts it. Otherwise the record is discarded and the
+// initiator sends the data again after the handshake.
+func (h *Handshake) openEarlyData(record []byte) {
+	if h.retryPending || !h.abbreviated || !h.allowEarlyData ||
+		!containsCipherSuite(h.supportedCipherSuites(), h.earlyDataSuite) {
+		return
+	}
+	if _, ok := h.resumptionStore.(EarlyDataStore); !ok {
+		return
+	}
+
+	cipher, err := h.earlyDataCipher()
+	if err != nil {
+		return
+	}
+	data, err := cipher.Open(record, h.transcript.Bytes())
+	if err != nil || len(data) > MaxEarlyDataSize {
+		return
+	}
+	h.earlyData = data
+	h.earlyDataAccepted = true
+}
+
+// earlyDataCipher returns the AEAD protecting the early data record.
+func (h *Handshake) earlyDataCipher() (*crypto.AEAD, error) {
+	key, err := crypto.DeriveEarlyDataKey(h.ticketSecret, h.clientRandom, h.earlyDataSuite)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Zeroize(key)
+	return crypto.NewAEAD(h.earlyDataSuite, key)
+}
+
+// lookupResumption checks the resumption store for the offered session ID and
+// switches to an abbreviated handshake on a hit. Unknown, expired, or
+// unusable entries leave the full handshake in place.
+func (h *Handshake) lookupResumption(sessionID []byte, offered []constants.CipherSuite) {
+	// Consume the entry when early data may be accepted, so a replayed
+	// first flight cannot deliver the same early data twice
+	get := h.resumptionStore.Get
+	if store, ok := h.resumptionStore.(EarlyDataStore); ok && h.allowEarlyData && h.earlyDataOffered {
+		get = store.Take
+	}
+	ticket, err := get(sessionID)
+	if err != nil {
+		return
+	}
+	if len(ticket.MasterSecret) != constants.CHKEMSharedSecretSize ||
+		!containsCipherSuite(h.supportedCipherSuites(), ticket.CipherSuite) ||
+		!containsCipherSuite(offered, ticket.CipherSuite) {
+		crypto.Zeroize(ticket.MasterSecret)
+		return
+	}
+
+	h.abbreviated = true
+	h.ticketSecret = ticket.MasterSecret
+	h.resumedFrom = ticket.CreatedAt
+	h.session.ID = append([]byte(nil), sessionID...)
+	h.session.CipherSuite = ticket.CipherSuite
+}
+
+// saveResumption stores the completed session in the resumption store. A
+// resumed session replaces its old entry but keeps the original creation
+// time, so the store's lifetime bounds the whole resumption chain. Storing
+// is best effort: a failure only costs the client a full handshake later.
+func (h *Handshake) saveResumption() {
+	if h.resumptionStore == nil || len(h.session.ID) == 0 {
+		return
+	}
+
+	secret, err := h.session.ResumptionSecret()
+	if err != nil {
+		return
+	}
+	defer crypto.Zeroize(secret)
+
+	createdAt := time.Now()
+	if h.abbreviated {
+		createdAt = h.resumedFrom
+	}
+
+	_ = h.resumptionStore.Put(h.session.ID, &SessionTicket{
+		Version:      1,
+		CipherSuite:  h.session.CipherSuite,
+		MasterSecret: secret,
+		CreatedAt:    createdAt,
+	})
+}
+
+// offeredCipherSuites returns the cipher suites the initiator offers.
+func (h *Handshake) offeredCipherSuites() []constants.CipherSuite {
+	suites := h.session.cipherSuites
+	if len(suites) == 0 {
+		suites = protocol.DefaultCipherSuites()
+	}
+	if h.nullEncryptionAllowed() {
+		suites = append([]constants.CipherSuite{constants.CipherSuiteNullEncryption}, suites...)
+	}
+	return suites
+}
+
+// supportedCipherSuites returns the cipher suites the responder selects from.
+func (h *Handshake) supportedCipherSuites() []constants.CipherSuite {
+	suites := protocol.SupportedCipherSuites()
+	if h.nullEncryptionAllowed() {
+		suites = append(suites, constants.CipherSuiteNullEncryption)
+	}
+	return suites
+}
+
+// nullEncryptionAllowed reports whether CipherSuiteNullEncryption may be
+// negotiated.
+func (h *Handshake) nullEncryptionAllowed() bool {
+	return h.allowNullEncryption && !crypto.FIPSMode()
+}
+
+// containsCipherSuite reports whether suite is in offered.
+func containsCipherSuite(offered []constants.CipherSuite, suite constants.CipherSuite) bool {
+	for _, o := range offered {
+		if o == suite {
+			return true
+		}
+	}
+	return false
+}
+
+// selectCipherSuite selects the first offered cipher suite that is supported.
+func selectCipherSuite(offered, supported []constants.CipherSuite) constants.CipherSuite {
 	for _, o := range offered {
 		for _, s := range supported {
 			if o == s {
@@ -540,6 +1108,10 @@ func (h *Handshake) cleanup() {
 		crypto.Zeroize(h.serverRandom)
 		h.serverRandom = nil
 	}
+	if h.abbreviated && h.resumptionStore != nil && h.ticketSecret != nil {
+		crypto.Zeroize(h.ticketSecret)
+		h.ticketSecret = nil
+	}
 	h.sendCipher = nil
 	h.recvCipher = nil
 	h.transcript.Reset()
@@ -591,62 +1163,99 @@ func readEncryptedRecord(r io.Reader) ([]byte, error) {
 	return ciphertext, nil
 }
 
-// --- High-Level API ---
-
-// InitiatorHandshake performs the complete handshake as initiator.
-func InitiatorHandshake(session *Session, rw io.ReadWriter) error {
-	observer := session.observer
-	var done func(error)
-	if observer != nil {
-		_, done = observer.OnHandshakeStart(context.Background())
+// readServerHello reads the ServerHello, first answering a single
+// HelloRetryRequest with a ClientHello carrying the server's cookie.
+func (h *Handshake) readServerHello(rw io.ReadWriter) ([]byte, error) {
+	msg, err := h.readHandshakeMessage(rw)
+	if err != nil {
+		return nil, err
+	}
+	if msgType, _ := h.codec.GetMessageType(msg); msgType != protocol.MessageTypeHelloRetryRequest {
+		return msg, nil
 	}
 
-	err := func() error {
-		h := NewHandshake(session)
+	clientHello, err := h.ProcessHelloRetryRequest(msg)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := rw.Write(clientHello); err != nil {
+		return nil, err
+	}
+	return h.readHandshakeMessage(rw)
+}
 
-		// Send ClientHello
-		clientHello, err := h.CreateClientHello()
-		if err != nil {
-			return err
-		}
-		if _, err := rw.Write(clientHello); err != nil {
-			return err
+// readHandshakeMessage reads the next handshake message, failing with the
+// peer's AlertError if it is a fatal alert.
+func (h *Handshake) readHandshakeMessage(rw io.ReadWriter) ([]byte, error) {
+	msg, err := h.codec.ReadMessage(rw)
+	if err != nil {
+		return nil, err
+	}
+	if msgType, _ := h.codec.GetMessageType(msg); msgType == protocol.MessageTypeAlert {
+		level, code, desc, err := h.codec.DecodeAlert(msg)
+		if err == nil && level == protocol.AlertLevelFatal {
+			return nil, qerrors.NewProtocolError("handshake", &AlertError{level: level, code: code, desc: desc})
 		}
+	}
+	return msg, nil
+}
 
-		// Receive ServerHello
-		serverHello, err := h.codec.ReadMessage(rw)
+// receiveClientHello reads and processes the ClientHello. If the responder
+// requires a cookie, it sends a HelloRetryRequest and processes the client's
+// second ClientHello instead.
+func (h *Handshake) receiveClientHello(rw io.ReadWriter) error {
+	for {
+		clientHello, err := h.codec.ReadMessage(rw)
 		if err != nil {
 			return err
 		}
-		if err := h.ProcessServerHello(serverHello); err != nil {
+		if err := h.ProcessClientHello(clientHello); err != nil {
 			sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
 			return err
 		}
-
-		// Send ClientFinished (encrypted, with length framing)
-		clientFinished, err := h.CreateClientFinished()
-		if err != nil {
-			return err
+		if h.earlyDataOffered {
+			record, err := readEncryptedRecord(rw)
+			if err != nil {
+				return err
+			}
+			h.openEarlyData(record)
 		}
-		if err := writeEncryptedRecord(rw, clientFinished); err != nil {
-			return err
+		if !h.RetryPending() {
+			return nil
 		}
 
-		// Receive ServerFinished (encrypted, with length framing)
-		serverFinished, err := readEncryptedRecord(rw)
+		retry, err := h.CreateHelloRetryRequest()
 		if err != nil {
 			return err
 		}
-		if err := h.ProcessServerFinished(serverFinished); err != nil {
-			sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
+		if _, err := rw.Write(retry); err != nil {
 			return err
 		}
+	}
+}
 
-		return nil
-	}()
+// --- High-Level API ---
+
+// observeHandshake runs handshake, reporting its start and outcome to the
+// session's observer and logger.
+func observeHandshake(session *Session, handshake func() error) error {
+	observer := session.observer
+	if session.Role == RoleResponder {
+		session.traceDeferred = true
+	} else {
+		session.startHandshakeSpan(context.Background())
+	}
+
+	start := time.Now()
+	err := handshake()
+	logHandshakeResult(session, time.Since(start), err)
+
+	// Failures before the ClientHello still get a span
+	session.startHandshakeSpan(context.Background())
 
 	if observer != nil {
 		if err != nil {
+			observer.OnHandshakeFailed(handshakeFailureReason(err), err)
 			if qerrors.Is(err, qerrors.ErrAuthenticationFailed) {
 				observer.OnAuthFailure()
 			}
@@ -654,187 +1263,259 @@ func InitiatorHandshake(session *Session, rw io.ReadWriter) error {
 				observer.OnProtocolError(err)
 			}
 		}
-		if done != nil {
-			done(err)
-		}
 	}
+	session.endHandshakeSpan(err)
 
 	return err
 }
 
-// ResponderHandshake performs the complete handshake as responder.
-func ResponderHandshake(session *Session, rw io.ReadWriter) error {
-	observer := session.observer
-	var done func(error)
-	if observer != nil {
-		_, done = observer.OnHandshakeStart(context.Background())
+// logHandshakeResult logs the outcome of a handshake that took elapsed.
+// Authentication failures are logged as errors since they may indicate an
+// attack; other failures are warnings.
+func logHandshakeResult(session *Session, elapsed time.Duration, err error) {
+	if session.logger == nil {
+		return
 	}
+	logger := session.logger.Named("handshake")
 
-	err := func() error {
-		h := NewHandshake(session)
-
-		// Receive ClientHello
-		clientHello, err := h.codec.ReadMessage(rw)
-		if err != nil {
-			return err
-		}
-		if err := h.ProcessClientHello(clientHello); err != nil {
-			sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
-			return err
-		}
-
-		// Send ServerHello
-		serverHello, err := h.CreateServerHello()
-		if err != nil {
-			return err
-		}
-		if _, err := rw.Write(serverHello); err != nil {
-			return err
-		}
-
-		// Receive ClientFinished (encrypted, with length framing)
-		clientFinished, err := readEncryptedRecord(rw)
-		if err != nil {
-			return err
-		}
-		if err := h.ProcessClientFinished(clientFinished); err != nil {
-			sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
-			return err
+	if err != nil {
+		fields := session.logFields(map[string]any{
+			"error":    err.Error(),
+			"reason":   handshakeFailureReason(err),
+			"duration": elapsed.String(),
+		})
+		if qerrors.Is(err, qerrors.ErrAuthenticationFailed) {
+			logger.Error("handshake failed", fields)
+		} else {
+			logger.Warn("handshake failed", fields)
 		}
+		return
+	}
 
-		// Send ServerFinished (encrypted, with length framing)
-		serverFinished, err := h.CreateServerFinished()
-		if err != nil {
-			return err
-		}
-		return writeEncryptedRecord(rw, serverFinished)
-	}()
+	logger.Info("handshake established", session.logFields(map[string]any{
+		"cipher_suite": session.CipherSuite.String(),
+		"kem":          session.KEMParameters.String(),
+		"resumed":      session.resumed,
+		"duration":     elapsed.String(),
+	}))
+	if session.CipherSuite == constants.CipherSuiteNullEncryption {
+		logger.Warn("records are authenticated but not encrypted", session.logFields(map[string]any{
+			"cipher_suite": session.CipherSuite.String(),
+		}))
+	}
+}
 
-	if observer != nil {
-		if err != nil {
-			if qerrors.Is(err, qerrors.ErrAuthenticationFailed) {
-				observer.OnAuthFailure()
-			}
-			if isProtocolError(err) {
-				observer.OnProtocolError(err)
-			}
-		}
-		if done != nil {
-			done(err)
-		}
+// logMessage logs a handshake message at debug level.
+func (h *Handshake) logMessage(msg string, msgType protocol.MessageType, size int) {
+	if h.logger == nil {
+		return
 	}
+	h.logger.Debug(msg, h.session.logFields(map[string]any{
+		"type": msgType.String(),
+		"size": size,
+	}))
+}
 
-	return err
+// InitiatorHandshake performs the complete handshake as initiator.
+func InitiatorHandshake(session *Session, rw io.ReadWriter) error {
+	return runInitiatorHandshake(session, rw, func(*Handshake) {})
 }
 
-// InitiatorResumptionHandshake performs the complete handshake as initiator with resumption.
-func InitiatorResumptionHandshake(session *Session, rw io.ReadWriter, ticket, secret []byte) error {
-	observer := session.observer
-	var done func(error)
-	if observer != nil {
-		_, done = observer.OnHandshakeStart(context.Background())
-	}
+// InitiatorHandshakeWithPin performs the handshake as initiator, requiring
+// the responder to authenticate with the identity key pinned. A nil pin
+// behaves like InitiatorHandshake.
+func InitiatorHandshakeWithPin(session *Session, rw io.ReadWriter, pinned ed25519.PublicKey) error {
+	return runInitiatorHandshake(session, rw, func(h *Handshake) {
+		h.SetPinnedServerKey(pinned)
+	})
+}
 
-	err := func() error {
+// runInitiatorHandshake performs the initiator handshake after configure has
+// set up the handshake's optional features.
+func runInitiatorHandshake(session *Session, rw io.ReadWriter, configure func(*Handshake)) error {
+	return observeHandshake(session, func() error {
 		h := NewHandshake(session)
-		h.SetTicket(ticket, secret)
+		configure(h)
 
 		// Send ClientHello
 		clientHello, err := h.CreateClientHello()
 		if err != nil {
-			return err
+			return newHandshakeError(HandshakePhaseClientHello, err)
 		}
 		if _, err := rw.Write(clientHello); err != nil {
-			return err
+			return newHandshakeError(HandshakePhaseClientHello, err)
+		}
+
+		// Send 0-RTT early data without waiting for the ServerHello
+		if h.earlyDataOffered {
+			earlyData, err := h.sealEarlyData()
+			if err != nil {
+				return newHandshakeError(HandshakePhaseClientHello, err)
+			}
+			if err := writeEncryptedRecord(rw, earlyData); err != nil {
+				return newHandshakeError(HandshakePhaseClientHello, err)
+			}
 		}
 
-		// Receive ServerHello
-		serverHello, err := h.codec.ReadMessage(rw)
+		// Receive ServerHello, answering a HelloRetryRequest if sent
+		serverHello, err := h.readServerHello(rw)
 		if err != nil {
-			return err
+			return newHandshakeError(HandshakePhaseServerHello, err)
 		}
 		if err := h.ProcessServerHello(serverHello); err != nil {
 			sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
-			return err
+			return newHandshakeError(HandshakePhaseServerHello, err)
 		}
 
 		// Send ClientFinished (encrypted, with length framing)
 		clientFinished, err := h.CreateClientFinished()
 		if err != nil {
-			return err
+			return newHandshakeError(HandshakePhaseClientFinished, err)
 		}
 		if err := writeEncryptedRecord(rw, clientFinished); err != nil {
-			return err
+			return newHandshakeError(HandshakePhaseClientFinished, err)
 		}
 
 		// Receive ServerFinished (encrypted, with length framing)
 		serverFinished, err := readEncryptedRecord(rw)
 		if err != nil {
-			return err
+			return newHandshakeError(HandshakePhaseServerFinished, err)
 		}
 		if err := h.ProcessServerFinished(serverFinished); err != nil {
 			sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
-			return err
+			return newHandshakeError(HandshakePhaseServerFinished, err)
 		}
 
 		return nil
-	}()
+	})
+}
 
-	if observer != nil {
-		if err != nil {
-			if qerrors.Is(err, qerrors.ErrAuthenticationFailed) {
-				observer.OnAuthFailure()
-			}
-			if isProtocolError(err) {
-				observer.OnProtocolError(err)
-			}
-		}
-		if done != nil {
-			done(err)
+// ResponderHandshake performs the complete handshake as responder.
+func ResponderHandshake(session *Session, rw io.ReadWriter) error {
+	return ResponderHandshakeWithStore(session, rw, nil)
+}
+
+// ResponderHandshakeWithStore performs the handshake as responder, resuming
+// sessions found in store with an abbreviated handshake. Full handshakes are
+// assigned a fresh session ID and added to store. A nil store behaves like
+// ResponderHandshake.
+func ResponderHandshakeWithStore(session *Session, rw io.ReadWriter, store ResumptionStore) error {
+	return runResponderHandshake(session, rw, func(h *Handshake) {
+		h.SetResumptionStore(store)
+	})
+}
+
+// ResponderHandshakeWithIdentity performs the handshake as responder,
+// authenticating to the initiator with key. A nil key behaves like
+// ResponderHandshake.
+func ResponderHandshakeWithIdentity(session *Session, rw io.ReadWriter, key *IdentityKey) error {
+	return runResponderHandshake(session, rw, func(h *Handshake) {
+		h.SetIdentityKey(key)
+	})
+}
+
+// InitiatorHandshakeContext performs the handshake as initiator, bounded by
+// ctx: ctx's deadline is applied to conn, and cancelling ctx interrupts any
+// pending read or write. If ctx ends before the handshake completes, its
+// error (e.g. context.DeadlineExceeded) is returned.
+func InitiatorHandshakeContext(ctx context.Context, session *Session, conn net.Conn) error {
+	return withHandshakeDeadline(ctx, conn, func() error {
+		return InitiatorHandshake(session, conn)
+	})
+}
+
+// ResponderHandshakeContext performs the handshake as responder, bounded by
+// ctx as described for InitiatorHandshakeContext.
+func ResponderHandshakeContext(ctx context.Context, session *Session, conn net.Conn) error {
+	return withHandshakeDeadline(ctx, conn, func() error {
+		return ResponderHandshake(session, conn)
+	})
+}
+
+// withHandshakeDeadline runs handshake over conn with ctx's deadline as the
+// connection deadline, interrupting it if ctx is cancelled. The deadline is
+// cleared afterwards. A handshake interrupted by ctx fails with ctx's error,
+// wrapped in a HandshakeError for the phase it reached.
+func withHandshakeDeadline(ctx context.Context, conn net.Conn, handshake func() error) error {
+	if d, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(d); err != nil {
+			return err
 		}
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
 	}
 
+	stop := interruptOnCancel(ctx, conn.SetDeadline)
+	err := handshake()
+	stop()
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			var hsErr *HandshakeError
+			if qerrors.As(err, &hsErr) {
+				return newHandshakeError(hsErr.Phase, ctxErr)
+			}
+			return ctxErr
+		}
+	}
 	return err
 }
 
-// ResponderResumptionHandshake performs the complete handshake as responder with resumption.
-func ResponderResumptionHandshake(session *Session, rw io.ReadWriter, tm *TicketManager) error {
-	h := NewHandshake(session)
-	h.SetTicketManager(tm)
+// runResponderHandshake performs the responder handshake after configure has
+// set up the handshake's optional features.
+func runResponderHandshake(session *Session, rw io.ReadWriter, configure func(*Handshake)) error {
+	return observeHandshake(session, func() error {
+		h := NewHandshake(session)
+		configure(h)
+		return respond(h, rw)
+	})
+}
 
-	// Receive ClientHello
-	clientHello, err := h.codec.ReadMessage(rw)
-	if err != nil {
-		return err
-	}
-	if err := h.ProcessClientHello(clientHello); err != nil {
-		sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
-		return err
+// respond runs the responder side of the handshake h over rw.
+func respond(h *Handshake, rw io.ReadWriter) error {
+	// Receive ClientHello, sending a HelloRetryRequest first if required
+	if err := h.receiveClientHello(rw); err != nil {
+		return newHandshakeError(HandshakePhaseClientHello, err)
 	}
 
 	// Send ServerHello
 	serverHello, err := h.CreateServerHello()
 	if err != nil {
-		return err
+		return newHandshakeError(HandshakePhaseServerHello, err)
 	}
 	if _, err := rw.Write(serverHello); err != nil {
-		return err
+		return newHandshakeError(HandshakePhaseServerHello, err)
 	}
 
 	// Receive ClientFinished (encrypted, with length framing)
 	clientFinished, err := readEncryptedRecord(rw)
 	if err != nil {
-		return err
+		return newHandshakeError(HandshakePhaseClientFinished, err)
 	}
 	if err := h.ProcessClientFinished(clientFinished); err != nil {
 		sendHandshakeAlert(rw, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
-		return err
+		return newHandshakeError(HandshakePhaseClientFinished, err)
 	}
 
 	// Send ServerFinished (encrypted, with length framing)
 	serverFinished, err := h.CreateServerFinished()
 	if err != nil {
-		return err
+		return newHandshakeError(HandshakePhaseServerFinished, err)
+	}
+	if err := writeEncryptedRecord(rw, serverFinished); err != nil {
+		return newHandshakeError(HandshakePhaseServerFinished, err)
 	}
-	return writeEncryptedRecord(rw, serverFinished)
+	return nil
+}
+
+// InitiatorResumptionHandshake performs the complete handshake as initiator with resumption.
+func InitiatorResumptionHandshake(session *Session, rw io.ReadWriter, ticket, secret []byte) error {
+	return runInitiatorHandshake(session, rw, func(h *Handshake) {
+		h.SetTicket(ticket, secret)
+	})
+}
+
+// ResponderResumptionHandshake performs the complete handshake as responder with resumption.
+func ResponderResumptionHandshake(session *Session, rw io.ReadWriter, tm *TicketManager) error {
+	h := NewHandshake(session)
+	h.SetTicketManager(tm)
+	return respond(h, rw)
 }
@@ -0,0 +1,87 @@
+package tunnel
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// HandshakePhase identifies the handshake message a failed handshake was
+// creating, sending, receiving or processing.
+type HandshakePhase int
+
+// Handshake phases, in the order the messages are exchanged.
+const (
+	HandshakePhaseClientHello HandshakePhase = iota + 1
+	HandshakePhaseServerHello
+	HandshakePhaseClientFinished
+	HandshakePhaseServerFinished
+)
+
+// String returns the name of the phase's message.
+func (p HandshakePhase) String() string {
+	switch p {
+	case HandshakePhaseClientHello:
+		return "ClientHello"
+	case HandshakePhaseServerHello:
+		return "ServerHello"
+	case HandshakePhaseClientFinished:
+		return "ClientFinished"
+	case HandshakePhaseServerFinished:
+		return "ServerFinished"
+	default:
+		return "unknown"
+	}
+}
+
+// HandshakeError is returned by the handshake functions, Dial and Pool
+// when a handshake fails. It tells network failures, which are worth
+// retrying, from authentication failures and protocol violations, which
+// will fail again:
+//
+//	var hsErr *tunnel.HandshakeError
+//	if errors.As(err, &hsErr) && hsErr.Retryable {
+//		// Reconnect
+//	}
+//
+// The underlying error is still matched by errors.Is and errors.As.
+type HandshakeError struct {
+	Phase     HandshakePhase // Message being handled when the handshake failed
+	Err       error          // Underlying error
+	Retryable bool           // The connection failed or timed out
+}
+
+func (e *HandshakeError) Error() string {
+	return fmt.Sprintf("handshake %s: %v", e.Phase, e.Err)
+}
+
+// Unwrap returns the underlying error for errors.Is/As support.
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+// newHandshakeError wraps err, a failure during phase, in a HandshakeError.
+func newHandshakeError(phase HandshakePhase, err error) *HandshakeError {
+	return &HandshakeError{Phase: phase, Err: err, Retryable: isNetworkError(err)}
+}
+
+// isNetworkError reports whether err is a connection failure or timeout
+// rather than a failure of the peer's messages. Alerts are sent by a peer
+// that rejected the handshake, so they are never network errors.
+func isNetworkError(err error) bool {
+	var alert *AlertError
+	if qerrors.As(err, &alert) || qerrors.Is(err, qerrors.ErrHandshakeFailed) {
+		return false
+	}
+	var netErr net.Error
+	return qerrors.As(err, &netErr) ||
+		qerrors.Is(err, io.EOF) ||
+		qerrors.Is(err, io.ErrUnexpectedEOF) ||
+		qerrors.Is(err, io.ErrClosedPipe) ||
+		qerrors.Is(err, net.ErrClosed) ||
+		qerrors.Is(err, qerrors.ErrTimeout) ||
+		qerrors.Is(err, context.DeadlineExceeded)
+}
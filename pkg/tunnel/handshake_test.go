@@ -2,13 +2,18 @@ package tunnel
 
 import (
 	"bytes"
+	"context"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"io"
 	"net"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 	"github.com/sara-star-quant/quantum-go/pkg/protocol"
 )
@@ -117,12 +122,39 @@ func TestHandshakeSelectCipherSuite(t *testing.T) {
 	// No common cipher suite
 	offered := []constants.CipherSuite{constants.CipherSuite(0xFF)}
 
-	suite := selectCipherSuite(offered)
+	suite := selectCipherSuite(offered, protocol.SupportedCipherSuites())
 	if suite != 0 {
 		t.Errorf("expected 0 (no match), got %v", suite)
 	}
 }
 
+func TestHandshakeOfferedCipherSuites(t *testing.T) {
+	cfg := DefaultSessionConfig()
+	cfg.CipherSuites = []constants.CipherSuite{constants.CipherSuiteAES128GCM}
+	session, err := NewSessionWithConfig(RoleInitiator, cfg)
+	if err != nil {
+		t.Fatalf("NewSessionWithConfig failed: %v", err)
+	}
+	h := NewHandshake(session)
+
+	data, err := h.CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+	msg, err := h.codec.DecodeClientHello(data)
+	if err != nil {
+		t.Fatalf("DecodeClientHello failed: %v", err)
+	}
+	if len(msg.CipherSuites) != 1 || msg.CipherSuites[0] != constants.CipherSuiteAES128GCM {
+		t.Errorf("expected only AES-128-GCM offered, got %v", msg.CipherSuites)
+	}
+
+	cfg.CipherSuites = []constants.CipherSuite{constants.CipherSuite(0xFF)}
+	if _, err := NewSessionWithConfig(RoleInitiator, cfg); !errors.Is(err, qerrors.ErrUnsupportedCipherSuite) {
+		t.Errorf("expected ErrUnsupportedCipherSuite, got %v", err)
+	}
+}
+
 func TestHandshakeDeriveKeysError(t *testing.T) {
 	session, _ := NewSession(RoleInitiator)
 	h := NewHandshake(session)
@@ -392,3 +424,998 @@ func TestVerifyDataDifferentSecrets(t *testing.T) {
 		t.Error("verify_data with different shared secrets should differ")
 	}
 }
+
+// runEditedHelloHandshake runs a handshake between client and server in
+// which edit, if not nil, rewrites the ClientHello in transit. An untouched
+// handshake must succeed; an edited one must fail on both sides' Finished
+// verification.
+func runEditedHelloHandshake(t *testing.T, clientSession, serverSession *Session, edit func([]byte) []byte) {
+	t.Helper()
+	client := NewHandshake(clientSession)
+	server := NewHandshake(serverSession)
+
+	hello, err := client.CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+	tamper := edit != nil
+	if tamper {
+		hello = edit(hello)
+	}
+	if err := server.ProcessClientHello(hello); err != nil {
+		t.Fatalf("ProcessClientHello failed: %v", err)
+	}
+	serverHello, err := server.CreateServerHello()
+	if err != nil {
+		t.Fatalf("CreateServerHello failed: %v", err)
+	}
+	if err := client.ProcessServerHello(serverHello); err != nil {
+		t.Fatalf("ProcessServerHello failed: %v", err)
+	}
+
+	clientFinished, err := client.CreateClientFinished()
+	if err != nil {
+		t.Fatalf("CreateClientFinished failed: %v", err)
+	}
+	err = server.ProcessClientFinished(clientFinished)
+	if !tamper {
+		if err != nil {
+			t.Fatalf("ProcessClientFinished failed: %v", err)
+		}
+	} else {
+		if !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+			t.Fatalf("expected responder ErrAuthenticationFailed, got %v", err)
+		}
+		// Even a responder that skipped the check would be caught by
+		// the initiator: record ClientFinished as if it had passed
+		plaintext, err := server.recvCipher.Open(clientFinished, nil)
+		if err != nil {
+			t.Fatalf("Open ClientFinished failed: %v", err)
+		}
+		server.transcript.Write(plaintext)
+	}
+
+	serverFinished, err := server.CreateServerFinished()
+	if err != nil {
+		t.Fatalf("CreateServerFinished failed: %v", err)
+	}
+	err = client.ProcessServerFinished(serverFinished)
+	if !tamper {
+		if err != nil {
+			t.Fatalf("ProcessServerFinished failed: %v", err)
+		}
+	} else if !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("expected initiator ErrAuthenticationFailed, got %v", err)
+	}
+}
+
+func TestHandshakeVersionDowngrade(t *testing.T) {
+	for _, tamper := range []bool{false, true} {
+		name := "untouched"
+		var edit func([]byte) []byte
+		if tamper {
+			name = "edited version"
+			edit = func(hello []byte) []byte {
+				// A man-in-the-middle rewrites the offered version to
+				// another compatible one
+				hello[protocol.HeaderSize+1] = protocol.Current.Minor + 1
+				return hello
+			}
+		}
+		t.Run(name, func(t *testing.T) {
+			clientSession, _ := NewSession(RoleInitiator)
+			serverSession, _ := NewSession(RoleResponder)
+			runEditedHelloHandshake(t, clientSession, serverSession, edit)
+			if clientSession.Version != serverSession.Version {
+				t.Errorf("negotiated versions differ: %v and %v", clientSession.Version, serverSession.Version)
+			}
+		})
+	}
+}
+
+func TestHandshakeCipherSuiteDowngrade(t *testing.T) {
+	offered := []constants.CipherSuite{constants.CipherSuiteAES256GCM, constants.CipherSuiteAES128GCM}
+
+	// rewrite returns an edit replacing the offered cipher suites
+	rewrite := func(suites ...constants.CipherSuite) func([]byte) []byte {
+		return func(hello []byte) []byte {
+			codec := protocol.NewCodec()
+			msg, err := codec.DecodeClientHello(hello)
+			if err != nil {
+				t.Fatalf("DecodeClientHello failed: %v", err)
+			}
+			msg.CipherSuites = suites
+			edited, err := codec.EncodeClientHello(msg)
+			if err != nil {
+				t.Fatalf("EncodeClientHello failed: %v", err)
+			}
+			return edited
+		}
+	}
+
+	tests := []struct {
+		name string
+		edit func([]byte) []byte
+		want constants.CipherSuite // Suite the responder selects
+	}{
+		{"untouched", nil, constants.CipherSuiteAES256GCM},
+		{"stronger suite stripped", rewrite(constants.CipherSuiteAES128GCM), constants.CipherSuiteAES128GCM},
+		{"suites reordered", rewrite(constants.CipherSuiteAES128GCM, constants.CipherSuiteAES256GCM), constants.CipherSuiteAES128GCM},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientSession, _ := NewSessionWithConfig(RoleInitiator, SessionConfig{CipherSuites: offered})
+			serverSession, _ := NewSession(RoleResponder)
+
+			// The responder cannot tell the list was edited and selects
+			// the weaker suite, but the Finished messages expose the edit
+			runEditedHelloHandshake(t, clientSession, serverSession, tt.edit)
+			if serverSession.CipherSuite != tt.want {
+				t.Errorf("responder selected %v, want %v", serverSession.CipherSuite, tt.want)
+			}
+		})
+	}
+}
+
+func TestHandshakeServerHelloNewerVersion(t *testing.T) {
+	clientSession, _ := NewSession(RoleInitiator)
+	serverSession, _ := NewSession(RoleResponder)
+	client := NewHandshake(clientSession)
+	server := NewHandshake(serverSession)
+
+	hello, _ := client.CreateClientHello()
+	if err := server.ProcessClientHello(hello); err != nil {
+		t.Fatalf("ProcessClientHello failed: %v", err)
+	}
+	serverHello, _ := server.CreateServerHello()
+
+	// A responder may not select a version newer than the one offered
+	serverHello[protocol.HeaderSize+1] = protocol.Current.Minor + 1
+	if err := client.ProcessServerHello(serverHello); !errors.Is(err, qerrors.ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+// failureObserver records handshake failure reasons.
+type failureObserver struct {
+	rekeyObserver
+	reasons []string
+}
+
+func (o *failureObserver) OnHandshakeFailed(reason string, _ error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.reasons = append(o.reasons, reason)
+}
+
+func TestHandshakeFailureReasons(t *testing.T) {
+	tests := []struct {
+		name string
+		role Role
+		run  func(t *testing.T, session *Session) error
+		want string
+	}{
+		{
+			name: HandshakeFailureUnsupportedVersion,
+			role: RoleResponder,
+			run: func(t *testing.T, session *Session) error {
+				client, _ := NewSession(RoleInitiator)
+				clientHello, err := NewHandshake(client).CreateClientHello()
+				if err != nil {
+					t.Fatalf("CreateClientHello failed: %v", err)
+				}
+				clientHello[5] = 99 // Major version
+				clientHello[6] = 99 // Minor version
+				return ResponderHandshake(session, &mockReadWriter{readData: clientHello})
+			},
+			want: HandshakeFailureUnsupportedVersion,
+		},
+		{
+			name: HandshakeFailureBadCiphertext,
+			role: RoleInitiator,
+			run: func(t *testing.T, session *Session) error {
+				// An all-zero X25519 ephemeral key cannot be decapsulated
+				serverHello, err := protocol.NewCodec().EncodeServerHello(&protocol.ServerHello{
+					Version:         protocol.Current,
+					Random:          make([]byte, 32),
+					SessionID:       make([]byte, 16),
+					CHKEMCiphertext: make([]byte, session.LocalKeyPair.Parameters().CiphertextSize()),
+					CipherSuite:     constants.CipherSuiteAES256GCM,
+				})
+				if err != nil {
+					t.Fatalf("EncodeServerHello failed: %v", err)
+				}
+				return InitiatorHandshake(session, &mockReadWriter{readData: serverHello})
+			},
+			want: HandshakeFailureBadCiphertext,
+		},
+		{
+			name: HandshakeFailureBadPublicKey,
+			role: RoleResponder,
+			run: func(t *testing.T, session *Session) error {
+				// ML-KEM coefficients of 0xFFF are not reduced modulo q
+				client, _ := NewSession(RoleInitiator)
+				publicKey := client.LocalKeyPair.PublicKey().Bytes()
+				for i := constants.X25519PublicKeySize; i < len(publicKey); i++ {
+					publicKey[i] = 0xFF
+				}
+				clientHello, err := protocol.NewCodec().EncodeClientHello(&protocol.ClientHello{
+					Version:        protocol.Current,
+					Random:         make([]byte, 32),
+					KEMParameters:  client.KEMParameters,
+					CHKEMPublicKey: publicKey,
+					CipherSuites:   []constants.CipherSuite{constants.CipherSuiteAES256GCM},
+				})
+				if err != nil {
+					t.Fatalf("EncodeClientHello failed: %v", err)
+				}
+				return ResponderHandshake(session, &mockReadWriter{readData: clientHello})
+			},
+			want: HandshakeFailureBadPublicKey,
+		},
+		{
+			name: HandshakeFailureAuthFailed,
+			role: RoleResponder,
+			run: func(t *testing.T, session *Session) error {
+				client, _ := NewSession(RoleInitiator)
+				clientHello, err := NewHandshake(client).CreateClientHello()
+				if err != nil {
+					t.Fatalf("CreateClientHello failed: %v", err)
+				}
+				var input bytes.Buffer
+				input.Write(clientHello)
+				if err := writeEncryptedRecord(&input, make([]byte, 64)); err != nil {
+					t.Fatalf("writeEncryptedRecord failed: %v", err)
+				}
+				return ResponderHandshake(session, &mockReadWriter{readData: input.Bytes()})
+			},
+			want: HandshakeFailureAuthFailed,
+		},
+		{
+			name: HandshakeFailureTimeout,
+			role: RoleInitiator,
+			run: func(t *testing.T, session *Session) error {
+				conn, peer := net.Pipe()
+				defer conn.Close()
+				defer peer.Close()
+				_ = conn.SetDeadline(time.Now().Add(20 * time.Millisecond))
+				return InitiatorHandshake(session, conn)
+			},
+			want: HandshakeFailureTimeout,
+		},
+		{
+			name: HandshakeFailureOther,
+			role: RoleInitiator,
+			run: func(t *testing.T, session *Session) error {
+				return InitiatorHandshake(session, &mockReadWriter{writeError: errors.New("write error")})
+			},
+			want: HandshakeFailureOther,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			session, _ := NewSession(tt.role)
+			obs := &failureObserver{}
+			session.SetObserver(obs)
+
+			if err := tt.run(t, session); err == nil {
+				t.Fatal("expected handshake to fail")
+			}
+
+			obs.mu.Lock()
+			defer obs.mu.Unlock()
+			if len(obs.reasons) != 1 || obs.reasons[0] != tt.want {
+				t.Errorf("expected reason %q, got %v", tt.want, obs.reasons)
+			}
+		})
+	}
+}
+
+// pskHandshake runs a handshake with each side's PSK set and returns the
+// initiator's and responder's errors.
+func pskHandshake(t *testing.T, clientPSK, serverPSK []byte) (error, error) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+
+	var serverErr error
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serverErr = runResponderHandshake(server, serverConn, func(h *Handshake) { h.SetPSK(serverPSK) })
+		_ = serverConn.Close()
+	}()
+
+	clientErr := runInitiatorHandshake(client, clientConn, func(h *Handshake) { h.SetPSK(clientPSK) })
+	_ = clientConn.Close()
+	<-done
+	return clientErr, serverErr
+}
+
+func TestHandshakePSK(t *testing.T) {
+	psk := bytes.Repeat([]byte{0x42}, constants.MinPSKSize)
+
+	t.Run("matching", func(t *testing.T) {
+		clientErr, serverErr := pskHandshake(t, psk, psk)
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("handshake with matching PSK failed: client=%v server=%v", clientErr, serverErr)
+		}
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		// An empty PSK is the same as none
+		clientErr, serverErr := pskHandshake(t, []byte{}, nil)
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("handshake with empty PSK failed: client=%v server=%v", clientErr, serverErr)
+		}
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		_, serverErr := pskHandshake(t, psk[:16], psk[:16])
+		if !errors.Is(serverErr, qerrors.ErrInvalidKeySize) {
+			t.Errorf("expected ErrInvalidKeySize for short PSK, got %v", serverErr)
+		}
+	})
+}
+
+func TestTransportConfigValidatePSK(t *testing.T) {
+	config := DefaultTransportConfig()
+	config.PSK = bytes.Repeat([]byte{0x42}, constants.MinPSKSize-1)
+	if err := config.Validate(); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Fatalf("expected ErrInvalidKeySize for a short PSK, got %v", err)
+	}
+
+	// Dial refuses the config before connecting: nothing listens on port 1
+	if _, err := DialWithConfig("tcp", "127.0.0.1:1", config); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Errorf("DialWithConfig: expected ErrInvalidKeySize, got %v", err)
+	}
+	if _, err := DialPacketWithConfig("udp", "127.0.0.1:1", config); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Errorf("DialPacketWithConfig: expected ErrInvalidKeySize, got %v", err)
+	}
+
+	// SetConfig keeps the previous config
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	if err := listener.SetConfig(config); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Errorf("SetConfig: expected ErrInvalidKeySize, got %v", err)
+	}
+	if listener.config.PSK != nil {
+		t.Error("SetConfig applied an invalid config")
+	}
+
+	packetListener, err := ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer func() { _ = packetListener.Close() }()
+	if err := packetListener.SetConfig(config); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Errorf("PacketListener.SetConfig: expected ErrInvalidKeySize, got %v", err)
+	}
+
+	// Empty and full-length PSKs are valid
+	for _, psk := range [][]byte{nil, {}, bytes.Repeat([]byte{0x42}, constants.MinPSKSize)} {
+		config.PSK = psk
+		if err := config.Validate(); err != nil {
+			t.Errorf("PSK of %d bytes: unexpected error %v", len(psk), err)
+		}
+	}
+}
+
+func TestHandshakePSKMismatch(t *testing.T) {
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+
+	initiator := NewHandshake(client)
+	initiator.SetPSK(bytes.Repeat([]byte{0x01}, constants.MinPSKSize))
+	responder := NewHandshake(server)
+	responder.SetPSK(bytes.Repeat([]byte{0x02}, constants.MinPSKSize))
+
+	clientHello, err := initiator.CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+	if err := responder.ProcessClientHello(clientHello); err != nil {
+		t.Fatalf("ProcessClientHello failed: %v", err)
+	}
+	serverHello, err := responder.CreateServerHello()
+	if err != nil {
+		t.Fatalf("CreateServerHello failed: %v", err)
+	}
+	if err := initiator.ProcessServerHello(serverHello); err != nil {
+		t.Fatalf("ProcessServerHello failed: %v", err)
+	}
+	clientFinished, err := initiator.CreateClientFinished()
+	if err != nil {
+		t.Fatalf("CreateClientFinished failed: %v", err)
+	}
+
+	// The responder cannot authenticate the initiator...
+	if err := responder.ProcessClientFinished(clientFinished); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("expected ErrAuthenticationFailed from ProcessClientFinished, got %v", err)
+	}
+
+	// ...nor the initiator the responder
+	serverFinished, err := responder.CreateServerFinished()
+	if err != nil {
+		t.Fatalf("CreateServerFinished failed: %v", err)
+	}
+	if err := initiator.ProcessServerFinished(serverFinished); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("expected ErrAuthenticationFailed from ProcessServerFinished, got %v", err)
+	}
+}
+
+// logEntry is a log event recorded by captureLogger.
+type logEntry struct {
+	level  string
+	name   string
+	msg    string
+	fields map[string]any
+}
+
+// captureLogger records log events. Child loggers share the parent's
+// entries.
+type captureLogger struct {
+	name    string
+	mu      *sync.Mutex
+	entries *[]logEntry
+}
+
+func newCaptureLogger() *captureLogger {
+	return &captureLogger{mu: &sync.Mutex{}, entries: &[]logEntry{}}
+}
+
+func (l *captureLogger) record(level, msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	*l.entries = append(*l.entries, logEntry{level: level, name: l.name, msg: msg, fields: fields})
+}
+
+func (l *captureLogger) Debug(msg string, fields map[string]any) { l.record("debug", msg, fields) }
+func (l *captureLogger) Info(msg string, fields map[string]any)  { l.record("info", msg, fields) }
+func (l *captureLogger) Warn(msg string, fields map[string]any)  { l.record("warn", msg, fields) }
+func (l *captureLogger) Error(msg string, fields map[string]any) { l.record("error", msg, fields) }
+
+func (l *captureLogger) Named(name string) Logger {
+	return &captureLogger{name: name, mu: l.mu, entries: l.entries}
+}
+
+func (l *captureLogger) find(level, msg string) []logEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var found []logEntry
+	for _, e := range *l.entries {
+		if e.level == level && e.msg == msg {
+			found = append(found, e)
+		}
+	}
+	return found
+}
+
+func TestHandshakeLogging(t *testing.T) {
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	config := DefaultTransportConfig()
+	config.Logger = newCaptureLogger()
+	listener.SetConfig(config)
+
+	go func() {
+		if server, err := listener.Accept(); err == nil {
+			_ = server.Close()
+		}
+	}()
+
+	clientLog := newCaptureLogger()
+	config.Logger = clientLog
+	client, err := DialWithConfig("tcp", listener.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+
+	established := clientLog.find("info", "handshake established")
+	if len(established) != 1 {
+		t.Fatalf("expected one establishment entry, got %d", len(established))
+	}
+	entry := established[0]
+	if entry.name != "handshake" {
+		t.Errorf("expected logger name %q, got %q", "handshake", entry.name)
+	}
+	if got, want := entry.fields["cipher_suite"], client.Session().CipherSuite.String(); got != want {
+		t.Errorf("expected cipher_suite %q, got %v", want, got)
+	}
+	if d, err := time.ParseDuration(entry.fields["duration"].(string)); err != nil || d <= 0 {
+		t.Errorf("expected a positive duration, got %v", entry.fields["duration"])
+	}
+	if got, want := entry.fields["session_id"], hex.EncodeToString(client.Session().ID[:8]); got != want {
+		t.Errorf("expected session_id %q, got %v", want, got)
+	}
+
+	// The initiator sends two handshake messages and receives two
+	sent := clientLog.find("debug", "sending handshake message")
+	received := clientLog.find("debug", "received handshake message")
+	if len(sent) != 2 || len(received) != 2 {
+		t.Errorf("expected 2 sent and 2 received messages, got %d and %d", len(sent), len(received))
+	}
+	for _, e := range append(sent, received...) {
+		if size, _ := e.fields["size"].(int); size <= 0 {
+			t.Errorf("%v entry without size", e.fields["type"])
+		}
+	}
+}
+
+func TestHandshakeLoggingFailure(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	clientLog, serverLog := newCaptureLogger(), newCaptureLogger()
+	client.SetLogger(clientLog)
+	server.SetLogger(serverLog)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = runResponderHandshake(server, serverConn, func(h *Handshake) {
+			h.SetPSK(bytes.Repeat([]byte{0x02}, constants.MinPSKSize))
+		})
+		_ = serverConn.Close()
+	}()
+	_ = runInitiatorHandshake(client, clientConn, func(h *Handshake) {
+		h.SetPSK(bytes.Repeat([]byte{0x01}, constants.MinPSKSize))
+	})
+	_ = clientConn.Close()
+	<-done
+
+	// The responder rejects the initiator's Finished
+	failures := serverLog.find("error", "handshake failed")
+	if len(failures) != 1 {
+		t.Fatalf("expected one error entry, got %d", len(failures))
+	}
+	if failures[0].fields["reason"] != HandshakeFailureAuthFailed {
+		t.Errorf("expected reason %q, got %v", HandshakeFailureAuthFailed, failures[0].fields["reason"])
+	}
+
+	// The initiator only sees the connection drop
+	if len(clientLog.find("warn", "handshake failed")) != 1 {
+		t.Error("expected the initiator to log its failure as a warning")
+	}
+	if len(clientLog.find("info", "handshake established")) != 0 {
+		t.Error("failed handshake logged as established")
+	}
+}
+
+func TestHandshakeContextStalledPeer(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	// The client sends the first bytes of a ClientHello, then nothing
+	go func() {
+		client, _ := NewSession(RoleInitiator)
+		clientHello, err := NewHandshake(client).CreateClientHello()
+		if err != nil {
+			t.Errorf("CreateClientHello failed: %v", err)
+			return
+		}
+		_, _ = clientConn.Write(clientHello[:protocol.HeaderSize+4])
+	}()
+
+	server, _ := NewSession(RoleResponder)
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := ResponderHandshakeContext(ctx, server, serverConn)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("handshake aborted after %v, want about 100ms", elapsed)
+	}
+}
+
+func TestHandshakeContextCancel(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	client, _ := NewSession(RoleInitiator)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	// Nobody reads from serverConn, so the ClientHello write blocks
+	if err := InitiatorHandshakeContext(ctx, client, clientConn); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestListenerHandshakeTimeout(t *testing.T) {
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	config := DefaultTransportConfig()
+	config.HandshakeTimeout = 100 * time.Millisecond
+	listener.SetConfig(config)
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	if _, err := conn.Write([]byte{byte(protocol.MessageTypeClientHello), 0x00}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := listener.Accept()
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("expected context.DeadlineExceeded, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Accept blocked on a stalled handshake")
+	}
+}
+
+// stubTranscriptHasher returns a fixed digest and counts its calls.
+type stubTranscriptHasher struct {
+	digest []byte
+	calls  int
+}
+
+func (s *stubTranscriptHasher) Hash(...[]byte) ([]byte, error) {
+	s.calls++
+	return bytes.Clone(s.digest), nil
+}
+
+// driveHandshake runs a full handshake between client and server in the
+// calling goroutine, and returns the complete transcript as the responder
+// saw it before finishing.
+func driveHandshake(t *testing.T, client, server *Handshake) []byte {
+	t.Helper()
+
+	clientHello, err := client.CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+	if err := server.ProcessClientHello(clientHello); err != nil {
+		t.Fatalf("ProcessClientHello failed: %v", err)
+	}
+	serverHello, err := server.CreateServerHello()
+	if err != nil {
+		t.Fatalf("CreateServerHello failed: %v", err)
+	}
+	if err := client.ProcessServerHello(serverHello); err != nil {
+		t.Fatalf("ProcessServerHello failed: %v", err)
+	}
+	clientFinished, err := client.CreateClientFinished()
+	if err != nil {
+		t.Fatalf("CreateClientFinished failed: %v", err)
+	}
+	if err := server.ProcessClientFinished(clientFinished); err != nil {
+		t.Fatalf("ProcessClientFinished failed: %v", err)
+	}
+	transcript := bytes.Clone(server.transcript.Bytes())
+	serverFinished, err := server.CreateServerFinished()
+	if err != nil {
+		t.Fatalf("CreateServerFinished failed: %v", err)
+	}
+	if err := client.ProcessServerFinished(serverFinished); err != nil {
+		t.Fatalf("ProcessServerFinished failed: %v", err)
+	}
+	return transcript
+}
+
+func TestHandshakeDefaultTranscriptHasher(t *testing.T) {
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	transcript := driveHandshake(t, NewHandshake(client), NewHandshake(server))
+
+	// The default is the SHA3-256 transcript hash over the whole transcript
+	want, err := crypto.TranscriptHash(transcript)
+	if err != nil {
+		t.Fatalf("TranscriptHash failed: %v", err)
+	}
+	for _, s := range []*Session{client, server} {
+		if !bytes.Equal(s.transcriptHash, want) {
+			t.Errorf("%s transcript hash = %x, want %x", s.Role, s.transcriptHash, want)
+		}
+	}
+
+	clientExport, _ := client.ExporterSecret("transcript", 32)
+	serverExport, _ := server.ExporterSecret("transcript", 32)
+	if !bytes.Equal(clientExport, serverExport) {
+		t.Error("exporters differ between the peers")
+	}
+}
+
+func TestHandshakeInjectedTranscriptHasher(t *testing.T) {
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	stub := &stubTranscriptHasher{digest: bytes.Repeat([]byte{0xab}, 48)}
+
+	initiator, responder := NewHandshake(client), NewHandshake(server)
+	initiator.SetTranscriptHasher(stub)
+	responder.SetTranscriptHasher(stub)
+	driveHandshake(t, initiator, responder)
+
+	if stub.calls < 2 {
+		t.Errorf("stub hasher called %d times, want at least once per peer", stub.calls)
+	}
+	for _, s := range []*Session{client, server} {
+		if !bytes.Equal(s.transcriptHash, stub.digest) {
+			t.Errorf("%s transcript hash = %x, want the stub digest", s.Role, s.transcriptHash)
+		}
+	}
+
+	// The exporter is keyed by the stub digest
+	clientExport, _ := client.ExporterSecret("transcript", 32)
+	serverExport, _ := server.ExporterSecret("transcript", 32)
+	if !bytes.Equal(clientExport, serverExport) {
+		t.Error("exporters differ between the peers")
+	}
+}
+
+// initiatorHandshakeError runs InitiatorHandshake against peer and returns
+// the HandshakeError it fails with.
+func initiatorHandshakeError(t *testing.T, peer func(conn net.Conn, h *Handshake)) *HandshakeError {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+
+	server, _ := NewSession(RoleResponder)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer func() { _ = serverConn.Close() }()
+		peer(serverConn, NewHandshake(server))
+	}()
+
+	client, _ := NewSession(RoleInitiator)
+	err := InitiatorHandshake(client, clientConn)
+	_ = clientConn.Close()
+	<-done
+
+	var hsErr *HandshakeError
+	if !errors.As(err, &hsErr) {
+		t.Fatalf("expected a *HandshakeError, got %T: %v", err, err)
+	}
+	return hsErr
+}
+
+func TestHandshakeErrorPhases(t *testing.T) {
+	codec := protocol.NewCodec()
+
+	// respond reads the ClientHello and answers it with a ServerHello
+	respond := func(conn net.Conn, h *Handshake) bool {
+		hello, err := codec.ReadMessage(conn)
+		if err != nil || h.ProcessClientHello(hello) != nil {
+			return false
+		}
+		serverHello, err := h.CreateServerHello()
+		if err != nil {
+			return false
+		}
+		_, err = conn.Write(serverHello)
+		return err == nil
+	}
+
+	tests := []struct {
+		name      string
+		peer      func(net.Conn, *Handshake)
+		phase     HandshakePhase
+		retryable bool
+	}{
+		{
+			name:      "connection closed before ClientHello",
+			peer:      func(net.Conn, *Handshake) {},
+			phase:     HandshakePhaseClientHello,
+			retryable: true,
+		},
+		{
+			name: "connection lost awaiting ServerHello",
+			peer: func(conn net.Conn, _ *Handshake) {
+				_, _ = codec.ReadMessage(conn)
+			},
+			phase:     HandshakePhaseServerHello,
+			retryable: true,
+		},
+		{
+			name: "unexpected message instead of ServerHello",
+			peer: func(conn net.Conn, _ *Handshake) {
+				hello, _ := codec.ReadMessage(conn)
+				_, _ = conn.Write(hello)
+				_, _ = codec.ReadMessage(conn)
+			},
+			phase: HandshakePhaseServerHello,
+		},
+		{
+			name: "connection lost sending ClientFinished",
+			peer: func(conn net.Conn, h *Handshake) {
+				respond(conn, h)
+			},
+			phase:     HandshakePhaseClientFinished,
+			retryable: true,
+		},
+		{
+			name: "forged ServerFinished",
+			peer: func(conn net.Conn, h *Handshake) {
+				if !respond(conn, h) {
+					return
+				}
+				if _, err := readEncryptedRecord(conn); err != nil {
+					return
+				}
+				plaintext, _ := codec.EncodeServerFinished(&protocol.ServerFinished{VerifyData: make([]byte, 32)})
+				forged, _ := h.sendCipher.Seal(plaintext, nil)
+				_ = writeEncryptedRecord(conn, forged)
+				_, _ = codec.ReadMessage(conn)
+			},
+			phase: HandshakePhaseServerFinished,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hsErr := initiatorHandshakeError(t, tt.peer)
+			if hsErr.Phase != tt.phase || hsErr.Retryable != tt.retryable {
+				t.Errorf("got phase %v, retryable %v (%v); want phase %v, retryable %v",
+					hsErr.Phase, hsErr.Retryable, hsErr.Err, tt.phase, tt.retryable)
+			}
+		})
+	}
+}
+
+func TestHandshakeErrorResponderAndTimeout(t *testing.T) {
+	// A PSK mismatch is an authentication failure on both sides
+	clientErr, serverErr := pskHandshake(t,
+		bytes.Repeat([]byte{0x01}, constants.MinPSKSize), bytes.Repeat([]byte{0x02}, constants.MinPSKSize))
+	for _, tt := range []struct {
+		side  string
+		err   error
+		phase HandshakePhase
+	}{
+		{"initiator", clientErr, HandshakePhaseServerFinished},
+		{"responder", serverErr, HandshakePhaseClientFinished},
+	} {
+		var hsErr *HandshakeError
+		if !errors.As(tt.err, &hsErr) || hsErr.Phase != tt.phase || hsErr.Retryable {
+			t.Errorf("%s: got %#v; want a fatal %v failure", tt.side, hsErr, tt.phase)
+		}
+	}
+	if !errors.Is(serverErr, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("responder error should unwrap to ErrAuthenticationFailed, got %v", serverErr)
+	}
+
+	// A peer that never answers times out, which is worth retrying
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = serverConn.Close() }()
+	defer func() { _ = clientConn.Close() }()
+	go func() { _, _ = io.Copy(io.Discard, serverConn) }()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	client, _ := NewSession(RoleInitiator)
+	err := InitiatorHandshakeContext(ctx, client, clientConn)
+	var hsErr *HandshakeError
+	if !errors.As(err, &hsErr) || hsErr.Phase != HandshakePhaseServerHello || !hsErr.Retryable {
+		t.Fatalf("got %v; want a retryable ServerHello failure", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+}
+
+// authorizedHandshake dials a listener configured with authorize, sending
+// token, and returns the dialer's and listener's errors and the failure
+// reasons reported for the listener's session.
+func authorizedHandshake(t *testing.T, token []byte, authorize Authorizer) (error, error, []string) {
+	t.Helper()
+
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	obs := &failureObserver{}
+	serverConfig := DefaultTransportConfig()
+	serverConfig.Authorizer = authorize
+	serverConfig.ObserverFactory = func(*Session) Observer { return obs }
+	listener.SetConfig(serverConfig)
+
+	done := make(chan error, 1)
+	go func() {
+		tunnel, err := listener.Accept()
+		if err == nil {
+			_ = tunnel.Close()
+		}
+		done <- err
+	}()
+
+	clientConfig := DefaultTransportConfig()
+	clientConfig.AuthToken = token
+	tunnel, clientErr := DialWithConfig("tcp", listener.Addr().String(), clientConfig)
+	if clientErr == nil {
+		_ = tunnel.Close()
+	}
+	serverErr := <-done
+
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	return clientErr, serverErr, obs.reasons
+}
+
+func TestHandshakeAuthorizer(t *testing.T) {
+	token := []byte("device-42")
+	errDenied := errors.New("unknown device")
+
+	t.Run("accept", func(t *testing.T) {
+		var got []byte
+		clientErr, serverErr, _ := authorizedHandshake(t, token, func(ctx context.Context, hello *protocol.ClientHello) error {
+			if ctx == nil {
+				t.Error("authorizer called with nil context")
+			}
+			got = hello.Extensions[protocol.ExtensionAuthToken]
+			return nil
+		})
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("authorized handshake failed: client=%v server=%v", clientErr, serverErr)
+		}
+		if !bytes.Equal(got, token) {
+			t.Errorf("authorizer saw token %q, want %q", got, token)
+		}
+	})
+
+	t.Run("reject", func(t *testing.T) {
+		clientErr, serverErr, reasons := authorizedHandshake(t, token, func(context.Context, *protocol.ClientHello) error {
+			return errDenied
+		})
+
+		var alert *AlertError
+		if !errors.As(clientErr, &alert) || alert.Code() != protocol.AlertCodeHandshakeFailure {
+			t.Errorf("expected HandshakeFailure alert, got %v", clientErr)
+		}
+		if !errors.Is(serverErr, qerrors.ErrUnauthorized) || !errors.Is(serverErr, errDenied) {
+			t.Errorf("expected ErrUnauthorized wrapping the authorizer's error, got %v", serverErr)
+		}
+		var hsErr *HandshakeError
+		if errors.As(serverErr, &hsErr) && hsErr.Retryable {
+			t.Error("rejected handshake reported as retryable")
+		}
+		if len(reasons) != 1 || reasons[0] != HandshakeFailureUnauthorized {
+			t.Errorf("expected reason %q, got %v", HandshakeFailureUnauthorized, reasons)
+		}
+	})
+
+	t.Run("nil", func(t *testing.T) {
+		clientErr, serverErr, _ := authorizedHandshake(t, token, nil)
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("handshake without authorizer failed: client=%v server=%v", clientErr, serverErr)
+		}
+	})
+}
@@ -0,0 +1,133 @@
+// Package tunnel implements ClientHello anti-replay for the CH-KEM VPN.
+//
+// This file (hello_replay.go) provides an optional cache of recently seen
+// ClientHello randoms. A replayed ClientHello cannot complete a handshake
+// without the initiator's CH-KEM private key, but it still costs the
+// responder an encapsulation; with the cache enabled the Listener rejects
+// it with a HandshakeFailure alert instead.
+package tunnel
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+const (
+	// helloRandomSize is the size of a ClientHello random.
+	helloRandomSize = 32
+
+	// defaultHelloReplayWindow is how long randoms are remembered by default.
+	defaultHelloReplayWindow = 2 * time.Minute
+
+	// defaultHelloReplayEntries is the default bound on remembered randoms.
+	defaultHelloReplayEntries = 100_000
+)
+
+// HelloReplayConfig configures ClientHello replay detection on a Listener.
+type HelloReplayConfig struct {
+	// Enabled makes the Listener reject a ClientHello whose random it has
+	// already seen within Window. Clients that resend an identical
+	// ClientHello, e.g. retransmitting over an unreliable transport, are
+	// rejected too, so this is disabled by default.
+	Enabled bool
+
+	// Window is how long a random is remembered.
+	// Default: 2m
+	Window time.Duration
+
+	// MaxEntries bounds the number of remembered randoms. When full, the
+	// oldest are forgotten first, shortening the effective window under
+	// heavy load.
+	// Default: 100000
+	MaxEntries int
+}
+
+// helloReplayCache remembers recent ClientHello randoms in arrival order.
+// All entries share one lifetime, so the oldest entry expires first.
+type helloReplayCache struct {
+	mu         sync.Mutex
+	window     time.Duration
+	maxEntries int
+	seen       map[[helloRandomSize]byte]*list.Element
+	order      *list.List // helloReplayEntry values, oldest first
+}
+
+// helloReplayEntry is a remembered random and when it was first seen.
+type helloReplayEntry struct {
+	random [helloRandomSize]byte
+	seenAt time.Time
+}
+
+// newHelloReplayCache creates a cache for config, or returns nil if replay
+// detection is disabled.
+func newHelloReplayCache(config HelloReplayConfig) *helloReplayCache {
+	if !config.Enabled {
+		return nil
+	}
+
+	window := config.Window
+	if window <= 0 {
+		window = defaultHelloReplayWindow
+	}
+	maxEntries := config.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = defaultHelloReplayEntries
+	}
+
+	return &helloReplayCache{
+		window:     window,
+		maxEntries: maxEntries,
+		seen:       make(map[[helloRandomSize]byte]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// check remembers random and returns ErrReplayDetected if it was already
+// seen within the window.
+func (c *helloReplayCache) check(random []byte) error {
+	if len(random) != helloRandomSize {
+		return qerrors.ErrInvalidMessage
+	}
+	key := [helloRandomSize]byte(random)
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.expireLocked(now)
+	if _, ok := c.seen[key]; ok {
+		return qerrors.ErrReplayDetected
+	}
+	if c.order.Len() >= c.maxEntries {
+		c.removeLocked(c.order.Front())
+	}
+	c.seen[key] = c.order.PushBack(helloReplayEntry{random: key, seenAt: now})
+	return nil
+}
+
+// Len returns the number of remembered randoms, including expired ones not
+// yet dropped.
+func (c *helloReplayCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// expireLocked drops entries older than the window. Must hold c.mu.
+func (c *helloReplayCache) expireLocked(now time.Time) {
+	for e := c.order.Front(); e != nil; e = c.order.Front() {
+		if now.Sub(e.Value.(helloReplayEntry).seenAt) <= c.window {
+			return
+		}
+		c.removeLocked(e)
+	}
+}
+
+// removeLocked forgets the entry in e. Must hold c.mu.
+func (c *helloReplayCache) removeLocked(e *list.Element) {
+	delete(c.seen, e.Value.(helloReplayEntry).random)
+	c.order.Remove(e)
+}
@@ -0,0 +1,176 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+func TestHelloReplayCache(t *testing.T) {
+	cache := newHelloReplayCache(HelloReplayConfig{Enabled: true, Window: 30 * time.Millisecond})
+	a := bytes.Repeat([]byte{1}, helloRandomSize)
+	b := bytes.Repeat([]byte{2}, helloRandomSize)
+
+	if err := cache.check(a); err != nil {
+		t.Fatalf("first check failed: %v", err)
+	}
+	if err := cache.check(a); !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("expected ErrReplayDetected for a duplicate, got %v", err)
+	}
+	if err := cache.check(b); err != nil {
+		t.Errorf("distinct random rejected: %v", err)
+	}
+	if err := cache.check(a[:16]); !errors.Is(err, qerrors.ErrInvalidMessage) {
+		t.Errorf("expected ErrInvalidMessage for a short random, got %v", err)
+	}
+
+	// Both randoms are accepted again, and forgotten, once the window passes
+	time.Sleep(60 * time.Millisecond)
+	if err := cache.check(a); err != nil {
+		t.Errorf("random rejected after expiry: %v", err)
+	}
+	if cache.Len() != 1 {
+		t.Errorf("Len = %d after expiry, want 1", cache.Len())
+	}
+}
+
+func TestHelloReplayCacheBounded(t *testing.T) {
+	cache := newHelloReplayCache(HelloReplayConfig{Enabled: true, MaxEntries: 2})
+	randoms := [][]byte{
+		bytes.Repeat([]byte{1}, helloRandomSize),
+		bytes.Repeat([]byte{2}, helloRandomSize),
+		bytes.Repeat([]byte{3}, helloRandomSize),
+	}
+	for _, random := range randoms {
+		if err := cache.check(random); err != nil {
+			t.Fatalf("check failed: %v", err)
+		}
+	}
+	if cache.Len() != 2 {
+		t.Errorf("Len = %d, want 2", cache.Len())
+	}
+
+	// The oldest random was evicted; the newest is still remembered
+	if err := cache.check(randoms[0]); err != nil {
+		t.Errorf("evicted random rejected: %v", err)
+	}
+	if err := cache.check(randoms[2]); !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("expected ErrReplayDetected, got %v", err)
+	}
+}
+
+func TestHelloReplayDisabled(t *testing.T) {
+	if cache := newHelloReplayCache(HelloReplayConfig{}); cache != nil {
+		t.Error("expected no cache when disabled")
+	}
+}
+
+func TestHelloReplayAfterAuthorizer(t *testing.T) {
+	cache := newHelloReplayCache(HelloReplayConfig{Enabled: true})
+	session, _ := NewSession(RoleInitiator)
+	hello, err := NewHandshake(session).CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+
+	process := func(authorize Authorizer) error {
+		server, _ := NewSession(RoleResponder)
+		h := NewHandshake(server)
+		h.rejectReplayedHellos(cache)
+		h.SetAuthorizer(context.Background(), authorize)
+		return h.ProcessClientHello(hello)
+	}
+	deny := func(context.Context, *protocol.ClientHello) error { return errors.New("denied") }
+
+	// Refused hellos do not take a place in the replay cache
+	for range 2 {
+		if err := process(deny); !errors.Is(err, qerrors.ErrUnauthorized) {
+			t.Fatalf("expected ErrUnauthorized, got %v", err)
+		}
+	}
+	if cache.Len() != 0 {
+		t.Errorf("Len = %d after refused hellos, want 0", cache.Len())
+	}
+
+	// The same hello, once authorized, is accepted and then refused as a replay
+	if err := process(nil); err != nil {
+		t.Fatalf("authorized hello failed: %v", err)
+	}
+	if err := process(nil); !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("expected ErrReplayDetected, got %v", err)
+	}
+}
+
+func TestListenerRejectsReplayedClientHello(t *testing.T) {
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	config := DefaultTransportConfig()
+	config.HelloReplay = HelloReplayConfig{Enabled: true, Window: 100 * time.Millisecond}
+	listener.SetConfig(config)
+
+	acceptErr := make(chan error, 3)
+	go func() {
+		for range 3 {
+			tunnel, err := listener.Accept()
+			if err == nil {
+				tunnel.Close()
+			}
+			acceptErr <- err
+		}
+	}()
+
+	session, _ := NewSession(RoleInitiator)
+	hello, err := NewHandshake(session).CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+
+	// sendHello sends the captured ClientHello on a new connection and
+	// returns the type of the server's reply
+	sendHello := func() protocol.MessageType {
+		t.Helper()
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+		if _, err := conn.Write(hello); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		reply, err := protocol.NewCodec().ReadMessage(conn)
+		if err != nil {
+			t.Fatalf("reading reply failed: %v", err)
+		}
+		return protocol.MessageType(reply[0])
+	}
+
+	if msgType := sendHello(); msgType != protocol.MessageTypeServerHello {
+		t.Fatalf("expected ServerHello, got %s", msgType)
+	}
+	<-acceptErr
+
+	// The same ClientHello within the window is refused
+	if msgType := sendHello(); msgType != protocol.MessageTypeAlert {
+		t.Errorf("expected Alert for a replayed ClientHello, got %s", msgType)
+	}
+	if err := <-acceptErr; !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("expected ErrReplayDetected, got %v", err)
+	}
+
+	// After the window it is accepted again
+	time.Sleep(150 * time.Millisecond)
+	if msgType := sendHello(); msgType != protocol.MessageTypeServerHello {
+		t.Errorf("expected ServerHello after the window, got %s", msgType)
+	}
+	<-acceptErr
+}
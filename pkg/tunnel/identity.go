@@ -0,0 +1,148 @@
+// Package tunnel implements responder authentication for the CH-KEM VPN.
+//
+// This file (identity.go) provides the long-term identity key a responder
+// uses to prove who it is. CH-KEM key pairs are ephemeral and ML-KEM cannot
+// sign, so without an identity key a man in the middle can run separate
+// handshakes with both peers. A responder configured with an IdentityKey
+// appends its public key and a signature to ServerFinished:
+//
+//	Signature = Ed25519-Sign(identity_key,
+//	    "CH-KEM-VPN-ServerIdentity" || SHA3-256(ClientHello || ServerHello || ClientFinished))
+//
+// The transcript contains the initiator's ephemeral public key, the
+// responder's CH-KEM ciphertext and the initiator's verify_data, so a
+// signature cannot be replayed into another handshake. ServerFinished is
+// encrypted with the handshake keys, which hides the identity from passive
+// observers.
+//
+// An initiator with a pinned key rejects a ServerFinished that is unsigned,
+// signed by another key, or carries a bad signature. An initiator without a
+// pin still verifies any signature it receives and records the key, which
+// allows trust-on-first-use.
+package tunnel
+
+import (
+	"crypto/ed25519"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// IdentityKey is a responder's long-term Ed25519 identity key.
+type IdentityKey struct {
+	private ed25519.PrivateKey
+}
+
+// GenerateIdentityKey generates a new random identity key.
+func GenerateIdentityKey() (*IdentityKey, error) {
+	seed, err := crypto.SecureRandomBytes(ed25519.SeedSize)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Zeroize(seed)
+
+	return &IdentityKey{private: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// NewIdentityKeyFromSeed restores an identity key from the Ed25519 seed
+// returned by Seed.
+func NewIdentityKeyFromSeed(seed []byte) (*IdentityKey, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, qerrors.ErrInvalidKeySize
+	}
+	return &IdentityKey{private: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// Seed returns the Ed25519 seed the key is derived from, for persisting it.
+// The seed is as sensitive as the key itself.
+func (k *IdentityKey) Seed() []byte {
+	return k.private.Seed()
+}
+
+// PublicKey returns the public key initiators pin to authenticate the responder.
+func (k *IdentityKey) PublicKey() ed25519.PublicKey {
+	return k.private.Public().(ed25519.PublicKey)
+}
+
+// Zeroize clears the private key. The key cannot be used afterwards.
+func (k *IdentityKey) Zeroize() {
+	crypto.Zeroize(k.private)
+}
+
+// IdentityFingerprint returns a stable digest of an identity public key for
+// logging and pinning, computed like CH-KEM public key fingerprints:
+//
+//	fingerprint = SHA3-256(domain || identity_key)
+func IdentityFingerprint(key ed25519.PublicKey) [chkem.FingerprintSize]byte {
+	var fp [chkem.FingerprintSize]byte
+	// TranscriptHash only fails for components over 4 GiB
+	digest, _ := crypto.TranscriptHash([]byte(constants.DomainSeparatorFingerprint), key)
+	copy(fp[:], digest)
+	return fp
+}
+
+// SetIdentityKey makes the responder sign the transcript with key.
+func (h *Handshake) SetIdentityKey(key *IdentityKey) {
+	h.identityKey = key
+}
+
+// SetPinnedServerKey makes the initiator require a ServerFinished signed by
+// the identity key with public key pinned.
+func (h *Handshake) SetPinnedServerKey(pinned ed25519.PublicKey) {
+	h.pinnedServerKey = pinned
+}
+
+// identityMessage returns the message an identity key signs for the
+// current transcript.
+func (h *Handshake) identityMessage() ([]byte, error) {
+	transcriptHash, err := h.transcriptHash()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(constants.DomainSeparatorIdentity), transcriptHash...), nil
+}
+
+// signIdentity adds the responder's identity to msg, if one is configured.
+func (h *Handshake) signIdentity(msg *protocol.ServerFinished) error {
+	if h.identityKey == nil {
+		return nil
+	}
+
+	message, err := h.identityMessage()
+	if err != nil {
+		return err
+	}
+	msg.IdentityKey = h.identityKey.PublicKey()
+	msg.Signature = ed25519.Sign(h.identityKey.private, message)
+	return nil
+}
+
+// verifyIdentity checks the responder's identity in msg against the pinned
+// key and records it on the session.
+func (h *Handshake) verifyIdentity(msg *protocol.ServerFinished) error {
+	if msg.IdentityKey == nil {
+		if h.pinnedServerKey != nil {
+			return qerrors.NewProtocolError("handshake", qerrors.ErrAuthenticationFailed)
+		}
+		return nil
+	}
+
+	identity := ed25519.PublicKey(msg.IdentityKey)
+	if h.pinnedServerKey != nil && !identity.Equal(h.pinnedServerKey) {
+		return qerrors.NewProtocolError("handshake", qerrors.ErrAuthenticationFailed)
+	}
+
+	message, err := h.identityMessage()
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(identity, message, msg.Signature) {
+		return qerrors.NewProtocolError("handshake", qerrors.ErrAuthenticationFailed)
+	}
+
+	h.session.remoteIdentity = identity
+	return nil
+}
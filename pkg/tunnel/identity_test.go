@@ -0,0 +1,145 @@
+package tunnel
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// identityHandshake runs a handshake between a responder using key and an
+// initiator pinning pinned, and returns the initiator session and its error.
+func identityHandshake(t *testing.T, key *IdentityKey, pinned *IdentityKey) (*Session, error) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		_ = ResponderHandshakeWithIdentity(server, serverConn, key)
+		// Drain the initiator's alert, if any
+		_ = serverConn.SetReadDeadline(time.Now().Add(time.Second))
+		_, _ = serverConn.Read(make([]byte, 1024))
+	}()
+
+	var err error
+	if pinned != nil {
+		err = InitiatorHandshakeWithPin(client, clientConn, pinned.PublicKey())
+	} else {
+		err = InitiatorHandshake(client, clientConn)
+	}
+	_ = clientConn.Close()
+	wg.Wait()
+	return client, err
+}
+
+func TestIdentityPinnedKey(t *testing.T) {
+	key, err := GenerateIdentityKey()
+	if err != nil {
+		t.Fatalf("GenerateIdentityKey failed: %v", err)
+	}
+
+	client, err := identityHandshake(t, key, key)
+	if err != nil {
+		t.Fatalf("handshake with correct pin failed: %v", err)
+	}
+	if !client.RemoteIdentity().Equal(key.PublicKey()) {
+		t.Error("RemoteIdentity does not match the responder's identity key")
+	}
+}
+
+func TestIdentityWrongPinnedKey(t *testing.T) {
+	key, _ := GenerateIdentityKey()
+	other, _ := GenerateIdentityKey()
+
+	client, err := identityHandshake(t, key, other)
+	if !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Fatalf("expected ErrAuthenticationFailed for wrong pin, got %v", err)
+	}
+	if client.State() == SessionStateEstablished {
+		t.Error("session should not be established after failed authentication")
+	}
+}
+
+func TestIdentityPinnedKeyAnonymousResponder(t *testing.T) {
+	pinned, _ := GenerateIdentityKey()
+
+	if _, err := identityHandshake(t, nil, pinned); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Fatalf("expected ErrAuthenticationFailed for unauthenticated responder, got %v", err)
+	}
+}
+
+func TestIdentityWithoutPin(t *testing.T) {
+	key, _ := GenerateIdentityKey()
+
+	// An unpinned initiator accepts and records the responder's identity
+	client, err := identityHandshake(t, key, nil)
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if !client.RemoteIdentity().Equal(key.PublicKey()) {
+		t.Error("RemoteIdentity should record an unpinned responder identity")
+	}
+
+	// ...and an anonymous responder leaves it unset
+	client, err = identityHandshake(t, nil, nil)
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if client.RemoteIdentity() != nil {
+		t.Error("RemoteIdentity should be nil for an anonymous responder")
+	}
+}
+
+func TestIdentityBadSignature(t *testing.T) {
+	key, _ := GenerateIdentityKey()
+
+	client, _ := NewSession(RoleInitiator)
+	h := NewHandshake(client)
+	h.SetPinnedServerKey(key.PublicKey())
+	h.transcript.WriteString("transcript")
+
+	msg := &protocol.ServerFinished{VerifyData: make([]byte, 32)}
+	signer := NewHandshake(client)
+	signer.SetIdentityKey(key)
+	signer.transcript.WriteString("transcript")
+	if err := signer.signIdentity(msg); err != nil {
+		t.Fatalf("signIdentity failed: %v", err)
+	}
+	if err := h.verifyIdentity(msg); err != nil {
+		t.Fatalf("verifyIdentity failed for a valid signature: %v", err)
+	}
+
+	// A signature over a different transcript is rejected
+	h.transcript.WriteString("tampered")
+	if err := h.verifyIdentity(msg); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("expected ErrAuthenticationFailed for bad signature, got %v", err)
+	}
+}
+
+func TestIdentityKeyFromSeed(t *testing.T) {
+	key, _ := GenerateIdentityKey()
+
+	restored, err := NewIdentityKeyFromSeed(key.Seed())
+	if err != nil {
+		t.Fatalf("NewIdentityKeyFromSeed failed: %v", err)
+	}
+	if !restored.PublicKey().Equal(key.PublicKey()) {
+		t.Error("restored key has a different public key")
+	}
+
+	if _, err := NewIdentityKeyFromSeed(make([]byte, 16)); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Errorf("expected ErrInvalidKeySize for short seed, got %v", err)
+	}
+}
@@ -0,0 +1,73 @@
+package tunnel
+
+import (
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// startKeepalive launches the background goroutine that pings the peer
+// whenever no data has been sent for interval.
+func (t *Transport) startKeepalive(interval time.Duration, maxMissed int) {
+	t.lastSend.Store(time.Now().UnixNano())
+	t.keepaliveStop = make(chan struct{})
+	t.keepaliveDone = make(chan struct{})
+
+	go func() {
+		if t.runKeepalive(interval, maxMissed) {
+			if t.session.observer != nil {
+				t.session.observer.OnSessionFailed(qerrors.ErrKeepaliveTimeout)
+			}
+			_ = t.Close()
+		}
+	}()
+}
+
+// runKeepalive sends pings until the transport is closed. It returns true
+// if the peer missed maxMissed consecutive pongs.
+func (t *Transport) runKeepalive(interval time.Duration, maxMissed int) bool {
+	defer close(t.keepaliveDone)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.keepaliveStop:
+			return false
+		case <-ticker.C:
+		}
+
+		if t.checkClosed() != nil {
+			return false
+		}
+
+		idle := time.Since(time.Unix(0, t.lastSend.Load()))
+		if idle < interval {
+			continue
+		}
+
+		if maxMissed > 0 && int(t.pingsOutstanding.Load()) >= maxMissed {
+			return true
+		}
+
+		// Count the ping before sending so a fast pong is not overwritten
+		t.pingsOutstanding.Add(1)
+		if err := t.SendPing(); err != nil {
+			t.pingsOutstanding.Add(-1)
+			if t.checkClosed() != nil {
+				return false
+			}
+		}
+	}
+}
+
+// stopKeepalive signals the keepalive goroutine to exit and waits for it.
+// It is a no-op if keepalives were never started.
+func (t *Transport) stopKeepalive() {
+	if t.keepaliveStop == nil {
+		return
+	}
+	t.keepaliveOnce.Do(func() { close(t.keepaliveStop) })
+	<-t.keepaliveDone
+}
@@ -0,0 +1,116 @@
+package tunnel
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+func TestKeepaliveSendsPingWhenIdle(t *testing.T) {
+	client, server := newPipeTransports(t)
+	server.startKeepalive(20*time.Millisecond, 0)
+
+	_ = client.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	msg, err := client.codec.ReadMessage(client.conn)
+	if err != nil {
+		t.Fatalf("ReadMessage failed: %v", err)
+	}
+	if msgType, _ := client.codec.GetMessageType(msg); msgType != protocol.MessageTypePing {
+		t.Fatalf("expected ping, got message type %v", msgType)
+	}
+
+	// Drain the close notification so Close does not block on the pipe
+	go func() { _, _ = client.codec.ReadMessage(client.conn) }()
+	_ = server.Close()
+
+	select {
+	case <-server.keepaliveDone:
+	case <-time.After(time.Second):
+		t.Fatal("keepalive goroutine did not exit after Close")
+	}
+}
+
+func TestKeepaliveSuppressedByTraffic(t *testing.T) {
+	client, server := newPipeTransports(t)
+	client.startKeepalive(50*time.Millisecond, 0)
+	defer func() {
+		go func() { _, _ = server.codec.ReadMessage(server.conn) }()
+		_ = client.Close()
+	}()
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := client.Send([]byte("busy")); err != nil {
+				return
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	for time.Now().Before(deadline) {
+		_ = server.conn.SetReadDeadline(time.Now().Add(time.Second))
+		msg, err := server.codec.ReadMessage(server.conn)
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		if msgType, _ := server.codec.GetMessageType(msg); msgType == protocol.MessageTypePing {
+			t.Fatal("ping sent while data was flowing")
+		}
+	}
+}
+
+func TestKeepaliveClosesAfterMissedPongs(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	// Read pings without ever answering them
+	go func() {
+		for {
+			if _, err := client.codec.ReadMessage(client.conn); err != nil {
+				return
+			}
+		}
+	}()
+
+	server.startKeepalive(20*time.Millisecond, 2)
+
+	select {
+	case <-server.keepaliveDone:
+	case <-time.After(2 * time.Second):
+		t.Fatal("keepalive did not give up after missed pongs")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for server.checkClosed() == nil && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if server.checkClosed() == nil {
+		t.Error("transport should be closed after missed pongs")
+	}
+}
+
+func TestKeepaliveAnsweredPongsKeepTunnelOpen(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	// Both sides run Receive: the client answers pings, the server consumes pongs
+	go func() { _, _ = client.Receive() }()
+	go func() { _, _ = server.Receive() }()
+
+	server.startKeepalive(20*time.Millisecond, 2)
+	time.Sleep(200 * time.Millisecond)
+
+	if err := server.checkClosed(); err != nil {
+		t.Fatal("transport closed although pongs were answered")
+	}
+
+	_ = client.conn.Close()
+	_ = server.Close()
+}
@@ -1,15 +1,24 @@
 package tunnel
 
 import (
+	"context"
+	"maps"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 )
 
 // IPRateLimiter tracks and limits the number of concurrent connections per IP.
+// Connections are counted even without a limit, so one can be imposed later
+// with SetMaxPerIP.
 type IPRateLimiter struct {
 	mu          sync.Mutex
 	connections map[string]int
 	maxPerIP    int
+	rejected    atomic.Uint64
 }
 
 // NewIPRateLimiter creates a new IPRateLimiter.
@@ -23,14 +32,11 @@ func NewIPRateLimiter(maxPerIP int) *IPRateLimiter {
 // AllowConnection checks if the IP is allowed to establish a new connection.
 // If allowed, it increments the connection count.
 func (l *IPRateLimiter) AllowConnection(ip string) bool {
-	if l.maxPerIP <= 0 {
-		return true // No limit
-	}
-
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	if l.connections[ip] >= l.maxPerIP {
+	if l.maxPerIP > 0 && l.connections[ip] >= l.maxPerIP {
+		l.rejected.Add(1)
 		return false
 	}
 	l.connections[ip]++
@@ -39,10 +45,6 @@ func (l *IPRateLimiter) AllowConnection(ip string) bool {
 
 // ReleaseConnection decrements the connection count for the IP.
 func (l *IPRateLimiter) ReleaseConnection(ip string) {
-	if l.maxPerIP <= 0 {
-		return
-	}
-
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
@@ -54,6 +56,27 @@ func (l *IPRateLimiter) ReleaseConnection(ip string) {
 	}
 }
 
+// SetMaxPerIP changes the limit. Open connections keep their slots: an IP
+// already over a lowered limit is refused until enough of its connections
+// close. 0 means no limit.
+func (l *IPRateLimiter) SetMaxPerIP(maxPerIP int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.maxPerIP = maxPerIP
+}
+
+// Connections returns the number of open connections from each IP.
+func (l *IPRateLimiter) Connections() map[string]int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return maps.Clone(l.connections)
+}
+
+// Rejected returns the number of connections refused so far.
+func (l *IPRateLimiter) Rejected() uint64 {
+	return l.rejected.Load()
+}
+
 // HandshakeLimiter limits the rate of handshakes using a token bucket algorithm.
 type HandshakeLimiter struct {
 	mu         sync.Mutex
@@ -61,10 +84,14 @@ type HandshakeLimiter struct {
 	burst      int     // Max bucket size
 	tokens     float64 // Current tokens
 	lastRefill time.Time
+	rejected   atomic.Uint64
 }
 
-// NewHandshakeLimiter creates a new HandshakeLimiter.
+// NewHandshakeLimiter creates a new HandshakeLimiter. A burst of 0 is
+// treated as 1, as RateLimitConfig.HandshakeBurst documents: a bucket
+// holding no tokens would refuse every handshake.
 func NewHandshakeLimiter(rate float64, burst int) *HandshakeLimiter {
+	burst = max(burst, 1)
 	return &HandshakeLimiter{
 		rate:       rate,
 		burst:      burst,
@@ -75,27 +102,254 @@ func NewHandshakeLimiter(rate float64, burst int) *HandshakeLimiter {
 
 // AllowHandshake checks if a handshake is allowed (consumes 1 token).
 func (l *HandshakeLimiter) AllowHandshake() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
 	if l.rate <= 0 {
 		return true // No limit
 	}
+	l.refill(time.Now())
 
-	l.mu.Lock()
-	defer l.mu.Unlock()
+	// Consume token
+	if l.tokens >= 1.0 {
+		l.tokens -= 1.0
+		return true
+	}
+	l.rejected.Add(1)
+	return false
+}
 
-	now := time.Now()
+// refill adds the tokens accrued since the last refill. l.mu must be held.
+func (l *HandshakeLimiter) refill(now time.Time) {
 	elapsed := now.Sub(l.lastRefill).Seconds()
-
-	// Refill tokens
 	l.tokens += elapsed * l.rate
 	if l.tokens > float64(l.burst) {
 		l.tokens = float64(l.burst)
 	}
 	l.lastRefill = now
+}
 
-	// Consume token
-	if l.tokens >= 1.0 {
-		l.tokens -= 1.0
+// SetRate changes the rate and burst. Tokens accrued under the old rate
+// are kept, up to the new burst. A rate of 0 means no limit, and a burst of
+// 0 is treated as 1, as in NewHandshakeLimiter.
+func (l *HandshakeLimiter) SetRate(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	unlimited := l.rate <= 0
+	if !unlimited {
+		l.refill(now)
+	}
+	l.rate = rate
+	l.burst = max(burst, 1)
+	l.lastRefill = now
+	if unlimited {
+		// Unlimited until now, so the bucket is full
+		l.tokens = float64(l.burst)
+	}
+	l.tokens = min(l.tokens, float64(l.burst))
+}
+
+// Tokens returns the number of handshakes the bucket allows right now.
+func (l *HandshakeLimiter) Tokens() float64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.rate > 0 {
+		l.refill(time.Now())
+	}
+	return l.tokens
+}
+
+// Rejected returns the number of handshakes refused so far.
+func (l *HandshakeLimiter) Rejected() uint64 {
+	return l.rejected.Load()
+}
+
+// RateLimitStats is a snapshot of a listener's rate limiting state.
+type RateLimitStats struct {
+	// Limits are the limits in force.
+	Limits RateLimitConfig
+
+	// ConnectionsPerIP is the number of open connections from each IP.
+	ConnectionsPerIP map[string]int
+
+	// HandshakeTokens is the number of handshakes the token bucket allows
+	// right now. It is 0 without a handshake rate limit.
+	HandshakeTokens float64
+
+	// ConnectionsRejected and HandshakesRejected count the connections
+	// refused by each limit since the listener was configured.
+	ConnectionsRejected uint64
+	HandshakesRejected  uint64
+}
+
+// rateLimiters holds a listener's rate limiters. They are always present
+// and updated in place, so connections admitted under earlier limits
+// release their slots in the same limiter.
+type rateLimiters struct {
+	mu        sync.Mutex // Serializes updates
+	limits    RateLimitConfig
+	ip        *IPRateLimiter
+	handshake *HandshakeLimiter
+}
+
+// newRateLimiters creates limiters enforcing limits.
+func newRateLimiters(limits RateLimitConfig) *rateLimiters {
+	return &rateLimiters{
+		limits:    limits,
+		ip:        NewIPRateLimiter(limits.MaxConnectionsPerIP),
+		handshake: NewHandshakeLimiter(limits.HandshakeRateLimit, limits.HandshakeBurst),
+	}
+}
+
+// update switches to new limits, keeping the open connection counts.
+func (r *rateLimiters) update(limits RateLimitConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.limits = limits
+	r.ip.SetMaxPerIP(limits.MaxConnectionsPerIP)
+	r.handshake.SetRate(limits.HandshakeRateLimit, limits.HandshakeBurst)
+}
+
+// stats returns a snapshot of the limiters.
+func (r *rateLimiters) stats() RateLimitStats {
+	r.mu.Lock()
+	limits := r.limits
+	r.mu.Unlock()
+
+	stats := RateLimitStats{
+		Limits:              limits,
+		ConnectionsPerIP:    r.ip.Connections(),
+		ConnectionsRejected: r.ip.Rejected(),
+		HandshakesRejected:  r.handshake.Rejected(),
+	}
+	if limits.HandshakeRateLimit > 0 {
+		stats.HandshakeTokens = r.handshake.Tokens()
+	}
+	return stats
+}
+
+// allowConnection applies the per-IP limit to a new connection, reporting
+// a refusal to observer.
+func (r *rateLimiters) allowConnection(ip string, observer RateLimitObserver) bool {
+	if r.ip.AllowConnection(ip) {
 		return true
 	}
+	if observer != nil {
+		observer.OnConnectionRateLimit(ip)
+	}
+	return false
+}
+
+// allowHandshake applies the handshake rate limit, reporting a refusal to
+// observer.
+func (r *rateLimiters) allowHandshake(ip string, observer RateLimitObserver) bool {
+	if r.handshake.AllowHandshake() {
+		return true
+	}
+	if observer != nil {
+		observer.OnHandshakeRateLimit(ip)
+	}
 	return false
 }
+
+// DefaultSendBurst is the send burst used when SendRateLimitConfig.Burst is
+// 0: one full record.
+const DefaultSendBurst = constants.MaxPayloadSize
+
+// sendLimiter paces a transport's outgoing data with a token bucket of
+// bytes. Unlike HandshakeLimiter, it delays senders instead of refusing
+// them.
+type sendLimiter struct {
+	mu         sync.Mutex
+	rate       float64 // Bytes per second
+	burst      float64 // Max bucket size in bytes
+	tokens     float64 // Current tokens; negative while sends wait for them
+	lastRefill time.Time
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newSendLimiter creates a limiter enforcing config, or returns nil if
+// config sets no limit.
+func newSendLimiter(config SendRateLimitConfig) *sendLimiter {
+	if config.Rate <= 0 {
+		return nil
+	}
+	burst := config.Burst
+	if burst <= 0 {
+		burst = DefaultSendBurst
+	}
+	return &sendLimiter{
+		rate:       config.Rate,
+		burst:      float64(burst),
+		tokens:     float64(burst),
+		lastRefill: time.Now(),
+		closed:     make(chan struct{}),
+	}
+}
+
+// wait takes n bytes from the bucket and blocks until the bucket has been
+// refilled enough to cover them, returning how long it waited. n may exceed
+// the burst: the bucket goes into debt, which later sends wait out.
+//
+// wait fails without waiting if ctx's deadline passes first, and returns
+// the bytes to the bucket if ctx is cancelled or the limiter is closed
+// while waiting.
+func (l *sendLimiter) wait(ctx context.Context, n int) (time.Duration, error) {
+	l.mu.Lock()
+	now := time.Now()
+	l.refill(now)
+	l.tokens -= float64(n)
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	l.mu.Unlock()
+
+	if delay <= 0 {
+		return 0, nil
+	}
+	if d, ok := ctx.Deadline(); ok && d.Before(now.Add(delay)) {
+		l.cancel(n)
+		return 0, context.DeadlineExceeded
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return delay, nil
+	case <-ctx.Done():
+		l.cancel(n)
+		return 0, ctx.Err()
+	case <-l.closed:
+		l.cancel(n)
+		return 0, qerrors.ErrTunnelClosed
+	}
+}
+
+// cancel returns n bytes taken by an abandoned wait to the bucket.
+func (l *sendLimiter) cancel(n int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.refill(time.Now())
+	l.tokens = min(l.tokens+float64(n), l.burst)
+}
+
+// refill adds the tokens accrued since the last refill. l.mu must be held.
+func (l *sendLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.lastRefill).Seconds()
+	l.tokens = min(l.tokens+elapsed*l.rate, l.burst)
+	l.lastRefill = now
+}
+
+// close wakes all waiting sends, which fail with ErrTunnelClosed.
+func (l *sendLimiter) close() {
+	l.closeOnce.Do(func() { close(l.closed) })
+}
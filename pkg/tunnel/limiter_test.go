@@ -1,8 +1,13 @@
 package tunnel
 
 import (
+	"context"
+	"errors"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 )
 
 func TestIPRateLimiter(t *testing.T) {
@@ -82,3 +87,281 @@ func TestHandshakeLimiter(t *testing.T) {
 		}
 	}
 }
+
+func TestHandshakeLimiterZeroBurst(t *testing.T) {
+	// A burst of 0 admits one handshake at a time rather than none
+	limiter := NewHandshakeLimiter(10, 0)
+	if !limiter.AllowHandshake() {
+		t.Error("expected the first handshake to be allowed with a burst of 0")
+	}
+	if limiter.AllowHandshake() {
+		t.Error("expected the second handshake to be blocked with a burst of 0")
+	}
+
+	// SetRate applies the same floor
+	limiter = NewHandshakeLimiter(10, 5)
+	limiter.SetRate(10, 0)
+	if !limiter.AllowHandshake() {
+		t.Error("expected a handshake to be allowed after SetRate with a burst of 0")
+	}
+	if limiter.AllowHandshake() {
+		t.Error("expected the bucket to hold a single token after SetRate with a burst of 0")
+	}
+}
+
+// countingRateLimitObserver counts rate limit notifications.
+type countingRateLimitObserver struct {
+	connections atomic.Int32
+	handshakes  atomic.Int32
+}
+
+func (o *countingRateLimitObserver) OnConnectionRateLimit(string) { o.connections.Add(1) }
+func (o *countingRateLimitObserver) OnHandshakeRateLimit(string)  { o.handshakes.Add(1) }
+
+// isRateLimitError reports whether err is a listener's rate limit refusal.
+func isRateLimitError(err error) bool {
+	var protoErr *qerrors.ProtocolError
+	return errors.As(err, &protoErr) && protoErr.Phase == "rate limit"
+}
+
+func TestListenerUpdateRateLimits(t *testing.T) {
+	const ip = "127.0.0.1"
+
+	listener, err := Listen("tcp", ip+":0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	observer := &countingRateLimitObserver{}
+	config := DefaultTransportConfig()
+	config.RateLimit.MaxConnectionsPerIP = 2
+	config.RateLimitObserver = observer
+	listener.SetConfig(config)
+
+	type result struct {
+		tunnel *Tunnel
+		err    error
+	}
+	accepted := make(chan result)
+	go func() {
+		for {
+			tunnel, err := listener.Accept()
+			if tunnel == nil && err == nil {
+				return
+			}
+			accepted <- result{tunnel, err}
+			if tunnel == nil && !isRateLimitError(err) {
+				return
+			}
+		}
+	}()
+	// dial connects a client and returns the server side, or the error
+	// Accept returned for the connection.
+	dial := func() (*Tunnel, error) {
+		t.Helper()
+		client, dialErr := Dial("tcp", listener.Addr().String())
+		if dialErr == nil {
+			t.Cleanup(func() { _ = client.Close() })
+		}
+		r := <-accepted
+		if r.tunnel != nil {
+			t.Cleanup(func() { _ = r.tunnel.Close() })
+			if dialErr != nil {
+				t.Fatalf("Dial failed: %v", dialErr)
+			}
+		}
+		return r.tunnel, r.err
+	}
+
+	var held []*Tunnel
+	for range 2 {
+		server, err := dial()
+		if err != nil {
+			t.Fatalf("connection %d refused: %v", len(held)+1, err)
+		}
+		held = append(held, server)
+	}
+
+	// Lowering the limit keeps the held connections but refuses new ones
+	listener.UpdateRateLimits(RateLimitConfig{MaxConnectionsPerIP: 1})
+	stats := listener.RateLimitStats()
+	if got := stats.ConnectionsPerIP[ip]; got != 2 {
+		t.Errorf("ConnectionsPerIP[%s] = %d, want 2", ip, got)
+	}
+	if stats.Limits.MaxConnectionsPerIP != 1 {
+		t.Errorf("Limits.MaxConnectionsPerIP = %d, want 1", stats.Limits.MaxConnectionsPerIP)
+	}
+	if _, err := dial(); !isRateLimitError(err) {
+		t.Fatalf("expected rate limit error below the lowered limit, got %v", err)
+	}
+	if got := listener.RateLimitStats().ConnectionsRejected; got != 1 {
+		t.Errorf("ConnectionsRejected = %d, want 1", got)
+	}
+	if got := observer.connections.Load(); got != 1 {
+		t.Errorf("observer saw %d connection rejections, want 1", got)
+	}
+
+	// Closing one held connection still leaves the IP at the limit
+	_ = held[0].Close()
+	if got := listener.RateLimitStats().ConnectionsPerIP[ip]; got != 1 {
+		t.Errorf("ConnectionsPerIP[%s] after close = %d, want 1", ip, got)
+	}
+	if _, err := dial(); !isRateLimitError(err) {
+		t.Fatalf("expected rate limit error at the lowered limit, got %v", err)
+	}
+
+	// Raising the limit admits new connections again
+	listener.UpdateRateLimits(RateLimitConfig{
+		MaxConnectionsPerIP: 3,
+		HandshakeRateLimit:  0.001,
+		HandshakeBurst:      2,
+	})
+	if got := listener.RateLimitStats().HandshakeTokens; got < 1.99 {
+		t.Errorf("HandshakeTokens = %v, want a full bucket of 2", got)
+	}
+	if _, err := dial(); err != nil {
+		t.Fatalf("connection refused after raising the limit: %v", err)
+	}
+	stats = listener.RateLimitStats()
+	if got := stats.ConnectionsPerIP[ip]; got != 2 {
+		t.Errorf("ConnectionsPerIP[%s] = %d, want 2", ip, got)
+	}
+	if stats.HandshakeTokens < 0.99 || stats.HandshakeTokens >= 1.5 {
+		t.Errorf("HandshakeTokens = %v, want 1 after a handshake", stats.HandshakeTokens)
+	}
+	if stats.ConnectionsRejected != 2 || stats.HandshakesRejected != 0 {
+		t.Errorf("rejected = %d connections, %d handshakes, want 2, 0",
+			stats.ConnectionsRejected, stats.HandshakesRejected)
+	}
+}
+
+// sendDelayObserver counts the data delayed by the send rate limit.
+type sendDelayObserver struct {
+	rekeyObserver
+	bytes atomic.Int64
+	delay atomic.Int64
+}
+
+func (o *sendDelayObserver) OnSendRateLimited(n int, delay time.Duration) {
+	o.bytes.Add(int64(n))
+	o.delay.Add(int64(delay))
+}
+
+// sendTimed sends total bytes in chunks from client to server and returns
+// how long it took.
+func sendTimed(t *testing.T, client, server *Transport, total, chunk int) time.Duration {
+	t.Helper()
+
+	received := make(chan int, 1)
+	go func() {
+		n := 0
+		for n < total {
+			data, err := server.Receive()
+			if err != nil {
+				break
+			}
+			n += len(data)
+		}
+		received <- n
+	}()
+
+	start := time.Now()
+	data := make([]byte, chunk)
+	for sent := 0; sent < total; sent += chunk {
+		if err := client.Send(data); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if n := <-received; n != total {
+		t.Fatalf("received %d bytes, want %d", n, total)
+	}
+	return time.Since(start)
+}
+
+func TestSendRateLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("takes about 2 seconds")
+	}
+	const (
+		total = 1 << 20
+		chunk = 32 << 10
+		rate  = 512 << 10
+	)
+	client, server := newPipeTransports(t)
+	client.sendLimiter = newSendLimiter(SendRateLimitConfig{Rate: rate, Burst: chunk})
+	observer := &sendDelayObserver{}
+	client.session.SetObserver(observer)
+
+	// Everything beyond the initial burst is paced at the rate
+	elapsed := sendTimed(t, client, server, total, chunk)
+	want := time.Duration(float64(total-chunk) / rate * float64(time.Second))
+	if elapsed < want-100*time.Millisecond || elapsed > want+time.Second {
+		t.Errorf("sending %d bytes at %d B/s took %v, want about %v", total, rate, elapsed, want)
+	}
+
+	if got := observer.bytes.Load(); got != total-chunk {
+		t.Errorf("observer saw %d delayed bytes, want %d", got, total-chunk)
+	}
+	if got := time.Duration(observer.delay.Load()); got < want/2 {
+		t.Errorf("observer saw %v of delay, want about %v", got, want)
+	}
+}
+
+func TestSendRateLimitUnlimited(t *testing.T) {
+	if l := newSendLimiter(SendRateLimitConfig{Burst: 1024}); l != nil {
+		t.Fatal("a zero rate should disable the limiter")
+	}
+	if l := newSendLimiter(SendRateLimitConfig{Rate: 1024}); l == nil || l.burst != DefaultSendBurst {
+		t.Fatalf("a zero burst should default to DefaultSendBurst, got %+v", l)
+	}
+
+	transportConfig := DefaultTransportConfig()
+	client, server := newPipeTransports(t)
+	client.sendLimiter = newSendLimiter(transportConfig.SendRateLimit)
+	if elapsed := sendTimed(t, client, server, 1<<20, 32<<10); elapsed > time.Second {
+		t.Errorf("sending without a limit took %v", elapsed)
+	}
+}
+
+func TestSendRateLimitContext(t *testing.T) {
+	client, _ := newPipeTransports(t)
+	client.sendLimiter = newSendLimiter(SendRateLimitConfig{Rate: 1024, Burst: 1024})
+	data := make([]byte, 4096)
+
+	// A wait that would outlast the deadline fails at once
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if err := client.SendContext(ctx, data); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("SendContext waited %v for a deadline it could not meet", elapsed)
+	}
+
+	// The abandoned bytes are returned to the bucket
+	if tokens := client.sendLimiter.tokens; tokens < 1000 {
+		t.Errorf("bucket holds %v tokens after the failed send, want about 1024", tokens)
+	}
+
+	// Cancelling a waiting send returns promptly
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := client.SendContext(ctx, data); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// Closing the transport wakes waiting sends
+	sendErr := make(chan error, 1)
+	go func() { sendErr <- client.Send(data) }()
+	time.Sleep(50 * time.Millisecond)
+	client.markClosed()
+	select {
+	case err := <-sendErr:
+		if !errors.Is(err, qerrors.ErrTunnelClosed) {
+			t.Fatalf("expected ErrTunnelClosed, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Send still waiting after the transport closed")
+	}
+}
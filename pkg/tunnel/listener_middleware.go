@@ -0,0 +1,72 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Middleware wraps a tunnel accepted by a Listener with cross-cutting
+// behavior, such as logging, per-connection metrics or authorization. It
+// returns the tunnel to hand on, which may be the one it was given or a new
+// Tunnel over the same Transport, or an error to reject the connection. A
+// nil tunnel, or one over another Transport, rejects the connection too.
+type Middleware func(*Tunnel) (*Tunnel, error)
+
+// errTunnelRejected marks a tunnel rejected by a middleware, which Accept
+// skips rather than returns.
+var errTunnelRejected = errors.New("tunnel: rejected by middleware")
+
+// errMiddlewareTunnel reports a middleware that returned no tunnel, or one
+// over a Transport other than the one it was given.
+var errMiddlewareTunnel = errors.New("middleware returned no tunnel over the accepted transport")
+
+// Use appends middleware to the chain applied to every tunnel once its
+// handshake completes, before Accept returns it or Serve hands it to the
+// handler. Middleware runs in the order it was added, each receiving the
+// tunnel returned by the previous one. If one returns an error, the rest
+// are skipped and the tunnel is closed: Accept waits for the next
+// connection, and Serve drops it. Like failed handshakes, rejections are
+// logged to the config's Logger and reported to the session's
+// Observer.OnSessionFailed.
+//
+// Like SetConfig, Use must be called before Accept or Serve.
+func (l *Listener) Use(middleware ...Middleware) {
+	l.middleware = append(l.middleware, middleware...)
+}
+
+// applyMiddleware runs the middleware chain on tunnel. On error, the tunnel
+// handed to the failing middleware is rejected (see rejectTunnel), and any
+// tunnel over another Transport it returned is closed.
+func (l *Listener) applyMiddleware(tunnel *Tunnel) (*Tunnel, error) {
+	for _, middleware := range l.middleware {
+		next, err := middleware(tunnel)
+		if err == nil && (next == nil || next.Transport != tunnel.Transport) {
+			if next != nil && next.Transport != nil {
+				_ = next.Close()
+			}
+			err = errMiddlewareTunnel
+		}
+		if err != nil {
+			err = fmt.Errorf("%w: %w", errTunnelRejected, err)
+			l.rejectTunnel(tunnel, err)
+			return nil, err
+		}
+		tunnel = next
+	}
+	return tunnel, nil
+}
+
+// rejectTunnel reports a tunnel rejected by a middleware to the listener's
+// logger and the session's observer, like a failed handshake, and closes it.
+func (l *Listener) rejectTunnel(tunnel *Tunnel, err error) {
+	session := tunnel.Session()
+	if l.config.Logger != nil {
+		l.config.Logger.Named("listener").Warn("tunnel rejected by middleware", session.logFields(map[string]any{
+			"error": err.Error(),
+		}))
+	}
+	if session.observer != nil {
+		session.observer.OnSessionFailed(err)
+	}
+	_ = tunnel.Close()
+}
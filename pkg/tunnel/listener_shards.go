@@ -0,0 +1,174 @@
+package tunnel
+
+import (
+	"context"
+	"net"
+	"sync"
+	"sync/atomic"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// ListenConfig configures the sockets a Listener accepts connections on.
+//
+// SO_REUSEPORT is supported on Linux, macOS and the BSDs; elsewhere,
+// ListenWithConfig fails with ErrReusePortUnsupported when it is
+// requested. Only Linux balances incoming connections across the sockets
+// bound to a port. macOS and the BSDs let them bind but may deliver every
+// connection to one of them, so sharding does not spread load there.
+type ListenConfig struct {
+	// ReusePort sets SO_REUSEPORT on the listening sockets, so other
+	// listeners, in this process or others, can bind the same address.
+	// Default: false
+	ReusePort bool
+
+	// Shards is the number of sockets bound to the address, each with its
+	// own accept loop. Their connections are fanned into a single queue
+	// consumed by Accept and Serve, letting a gateway accept on several
+	// cores without a front-end load balancer. More than one shard
+	// implies ReusePort.
+	// 0 or 1 binds a single socket.
+	Shards int
+}
+
+// ListenWithConfig creates a listener for incoming tunnel connections like
+// Listen, with the sockets configured by config.
+func ListenWithConfig(network, address string, config ListenConfig) (*Listener, error) {
+	shards := max(config.Shards, 1)
+	reusePort := config.ReusePort || shards > 1
+	if reusePort && !reusePortSupported {
+		return nil, qerrors.ErrReusePortUnsupported
+	}
+
+	var lc net.ListenConfig
+	if reusePort {
+		lc.Control = reusePortControl
+	}
+	first, err := lc.Listen(context.Background(), network, address)
+	if err != nil {
+		return nil, err
+	}
+	if shards == 1 {
+		return newListener(first), nil
+	}
+
+	// The remaining shards bind the port the first one was given, which
+	// differs from address when it asks for any free port
+	listeners := []net.Listener{first}
+	for len(listeners) < shards {
+		ln, err := lc.Listen(context.Background(), network, first.Addr().String())
+		if err != nil {
+			for _, ln := range listeners {
+				_ = ln.Close()
+			}
+			return nil, err
+		}
+		listeners = append(listeners, ln)
+	}
+	return newListener(newShardedListener(listeners)), nil
+}
+
+// ShardAccepts returns the number of connections each shard has accepted,
+// or nil if the listener is not sharded. With SO_REUSEPORT balancing, the
+// counts show how evenly the kernel spreads connections.
+func (l *Listener) ShardAccepts() []uint64 {
+	sl, ok := l.listener.(*shardedListener)
+	if !ok {
+		return nil
+	}
+	counts := make([]uint64, len(sl.accepted))
+	for i := range sl.accepted {
+		counts[i] = sl.accepted[i].Load()
+	}
+	return counts
+}
+
+// acceptResult is a connection or error returned by a shard's Accept.
+type acceptResult struct {
+	conn net.Conn
+	err  error
+}
+
+// shardedListener is a net.Listener that accepts from several listeners
+// bound to the same address, each in its own goroutine.
+type shardedListener struct {
+	shards   []net.Listener
+	accepted []atomic.Uint64
+	results  chan acceptResult
+
+	done      chan struct{}
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// newShardedListener starts an accept loop for each of shards.
+func newShardedListener(shards []net.Listener) *shardedListener {
+	l := &shardedListener{
+		shards:   shards,
+		accepted: make([]atomic.Uint64, len(shards)),
+		results:  make(chan acceptResult),
+		done:     make(chan struct{}),
+	}
+	for i := range shards {
+		go l.acceptLoop(i)
+	}
+	return l
+}
+
+// acceptLoop accepts connections on shard i and hands them to Accept until
+// the listener is closed. Errors are handed over too, so Accept reports
+// them as it would for a single listener.
+func (l *shardedListener) acceptLoop(i int) {
+	for {
+		conn, err := l.shards[i].Accept()
+		select {
+		case <-l.done:
+			if conn != nil {
+				_ = conn.Close()
+			}
+			return
+		default:
+		}
+		if err == nil {
+			l.accepted[i].Add(1)
+		}
+
+		select {
+		case l.results <- acceptResult{conn: conn, err: err}:
+		case <-l.done:
+			if conn != nil {
+				_ = conn.Close()
+			}
+			return
+		}
+	}
+}
+
+// Accept returns the next connection accepted by any shard.
+func (l *shardedListener) Accept() (net.Conn, error) {
+	select {
+	case r := <-l.results:
+		return r.conn, r.err
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes every shard. Connections accepted but not yet returned by
+// Accept are closed.
+func (l *shardedListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+		for _, ln := range l.shards {
+			if err := ln.Close(); err != nil && l.closeErr == nil {
+				l.closeErr = err
+			}
+		}
+	})
+	return l.closeErr
+}
+
+// Addr returns the address the shards are bound to.
+func (l *shardedListener) Addr() net.Addr {
+	return l.shards[0].Addr()
+}
@@ -0,0 +1,34 @@
+package tunnel
+
+import "encoding/hex"
+
+// Logger receives structured log events from the tunnel. Each event carries
+// its fields as key-value pairs. metrics.NewTunnelLogger adapts a
+// *metrics.Logger.
+type Logger interface {
+	Debug(msg string, fields map[string]any)
+	Info(msg string, fields map[string]any)
+	Warn(msg string, fields map[string]any)
+	Error(msg string, fields map[string]any)
+
+	// Named returns a child logger whose name is extended with name.
+	Named(name string) Logger
+}
+
+// SetLogger sets the logger for session events. Should be called before
+// the handshake.
+func (s *Session) SetLogger(logger Logger) {
+	s.logger = logger
+}
+
+// logFields returns fields identifying the session, merged with extra. The
+// session ID is truncated like in metrics.TunnelObserver.
+func (s *Session) logFields(extra map[string]any) map[string]any {
+	fields := make(map[string]any, len(extra)+2)
+	fields["session_id"] = hex.EncodeToString(s.ID[:min(8, len(s.ID))])
+	fields["role"] = s.Role.String()
+	for k, v := range extra {
+		fields[k] = v
+	}
+	return fields
+}
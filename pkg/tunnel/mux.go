@@ -0,0 +1,641 @@
+package tunnel
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// MuxConfig configures a Muxer.
+type MuxConfig struct {
+	// StreamWindow is how many bytes a stream buffers for its reader. The
+	// peer's writes to the stream block once the window is full, so a
+	// stream whose reader falls behind cannot starve the others. Values
+	// below the initial window of every stream, 256 KiB, are raised to it,
+	// and values above 1 GiB lowered to that.
+	// Default: 256 KiB
+	StreamWindow uint32
+
+	// MaxStreams limits how many streams opened by the peer may be open at
+	// once. Further streams are reset.
+	// Default: 1024
+	MaxStreams int
+
+	// AcceptBacklog is how many streams opened by the peer may wait for
+	// AcceptStream. Further streams are reset.
+	// Default: 64
+	AcceptBacklog int
+}
+
+// Defaults for MuxConfig.
+const (
+	defaultMuxMaxStreams    = 1024
+	defaultMuxAcceptBacklog = 64
+)
+
+// muxInitialWindow is the receive window every stream starts with. Larger
+// windows are granted with a window frame once the stream exists.
+const muxInitialWindow = 256 << 10
+
+// muxMaxWindow is the largest receive window a stream may grant.
+const muxMaxWindow = 1 << 30
+
+// muxMaxChunk is the largest amount of stream data sent in one frame.
+const muxMaxChunk = 16 << 10
+
+// muxFrameType identifies a multiplexer frame.
+type muxFrameType uint8
+
+// Multiplexer frame types.
+const (
+	muxFrameOpen   muxFrameType = iota + 1 // A new stream
+	muxFrameData                           // Stream data
+	muxFrameClose                          // No more data from the sender
+	muxFrameReset                          // The stream was aborted in both directions
+	muxFrameWindow                         // 4-byte receive window increment
+)
+
+// muxControlBacklog is how many frames sent on behalf of the reader may
+// wait for the peer to read.
+const muxControlBacklog = 1024
+
+// muxHeaderSize is the size of the record header of each frame: the frame
+// type followed by the 4-byte stream ID.
+const muxHeaderSize = 1 + 4
+
+// Muxer multiplexes independent, flow-controlled streams over one
+// transport, so concurrent conversations with the same peer share a single
+// handshake. Each frame travels as one record whose application header
+// (see Transport.SendWithHeader) holds the frame type and stream ID, so
+// both are encrypted along with the frame data.
+//
+// Both peers wrap their end of the transport in a Muxer, and either may
+// open streams: the initiator numbers its streams with odd IDs and the
+// responder with even ones. The Muxer reads from the transport on its own
+// goroutine, so Receive must not be called once it is created. The
+// transport must deliver records reliably and in order, which rules out
+// packet tunnels.
+type Muxer struct {
+	t        *Transport
+	config   MuxConfig
+	maxChunk int
+
+	writeMu sync.Mutex // Keeps frames in sequence-number order on the wire
+
+	mu        sync.Mutex
+	streams   map[uint32]*Stream
+	nextID    uint32
+	peerOdd   bool // The peer's streams have odd IDs
+	peerCount int  // Open streams opened by the peer
+	err       error
+
+	accept  chan *Stream
+	control chan muxControlFrame // Frames queued by the reader
+	done    chan struct{}        // Closed when the reader exits
+}
+
+// muxControlFrame is a frame queued by the reader for the control writer.
+type muxControlFrame struct {
+	frame muxFrameType
+	id    uint32
+	data  []byte
+}
+
+// NewMuxer starts multiplexing streams over t.
+func NewMuxer(t *Transport, config MuxConfig) *Muxer {
+	config.StreamWindow = min(max(config.StreamWindow, muxInitialWindow), muxMaxWindow)
+	if config.MaxStreams <= 0 {
+		config.MaxStreams = defaultMuxMaxStreams
+	}
+	if config.AcceptBacklog <= 0 {
+		config.AcceptBacklog = defaultMuxAcceptBacklog
+	}
+
+	m := &Muxer{
+		t:        t,
+		config:   config,
+		maxChunk: min(muxMaxChunk, t.maxRecordData()-(1+muxHeaderSize)),
+		streams:  make(map[uint32]*Stream),
+		nextID:   2,
+		peerOdd:  true,
+		accept:   make(chan *Stream, config.AcceptBacklog),
+		control:  make(chan muxControlFrame, muxControlBacklog),
+		done:     make(chan struct{}),
+	}
+	if t.session.Role == RoleInitiator {
+		m.nextID = 1
+		m.peerOdd = false
+	}
+	go m.run()
+	go m.writeControl()
+	return m
+}
+
+// OpenStream opens a new stream to the peer.
+func (m *Muxer) OpenStream() (*Stream, error) {
+	m.mu.Lock()
+	if m.err != nil {
+		m.mu.Unlock()
+		return nil, m.err
+	}
+	s := m.newStream(m.nextID)
+	m.nextID += 2
+	m.streams[s.id] = s
+	m.mu.Unlock()
+
+	if err := m.sendFrame(muxFrameOpen, s.id, nil); err != nil {
+		m.remove(s)
+		return nil, err
+	}
+	if extra := s.extraWindow(); extra > 0 {
+		if err := s.sendWindowUpdate(extra); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// AcceptStream waits for the peer to open a stream. It fails once the
+// muxer stops, with ErrTunnelClosed if the transport was closed.
+func (m *Muxer) AcceptStream() (*Stream, error) {
+	select {
+	case s := <-m.accept:
+		return s, nil
+	case <-m.done:
+		// Streams opened before the muxer stopped were reset
+		return nil, m.failure()
+	}
+}
+
+// Close closes the transport, resetting every open stream, and waits for
+// the muxer to stop.
+func (m *Muxer) Close() error {
+	err := m.t.Close()
+	<-m.done
+	return err
+}
+
+// newStream creates a stream with the initial window in both directions.
+func (m *Muxer) newStream(id uint32) *Stream {
+	s := &Stream{
+		id:         id,
+		m:          m,
+		sendWindow: muxInitialWindow,
+		recvWindow: muxInitialWindow,
+	}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// sendFrame sends one frame. The header fills the record plaintext of
+// frames without data.
+func (m *Muxer) sendFrame(frame muxFrameType, id uint32, data []byte) error {
+	var header [muxHeaderSize]byte
+	header[0] = byte(frame)
+	binary.BigEndian.PutUint32(header[1:], id)
+
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	return m.t.SendWithHeader(header[:], data)
+}
+
+// queueFrame queues a frame for the control writer. The reader never sends
+// itself: if both peers' readers blocked writing to each other, neither
+// would read again. A peer that stops reading while provoking more frames
+// than the backlog holds fails the muxer.
+func (m *Muxer) queueFrame(frame muxFrameType, id uint32, data []byte) error {
+	select {
+	case m.control <- muxControlFrame{frame: frame, id: id, data: data}:
+		return nil
+	default:
+		return qerrors.ErrMuxBacklogFull
+	}
+}
+
+// writeControl is the control writer goroutine. It sends the frames queued
+// by the reader until the muxer stops.
+func (m *Muxer) writeControl() {
+	for {
+		select {
+		case f := <-m.control:
+			if err := m.sendFrame(f.frame, f.id, f.data); err != nil {
+				_ = m.t.Close()
+				return
+			}
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// remove forgets a stream that is done in both directions.
+func (m *Muxer) remove(s *Stream) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.streams[s.id] == s {
+		delete(m.streams, s.id)
+		if m.isPeerStream(s.id) {
+			m.peerCount--
+		}
+	}
+}
+
+// isPeerStream reports whether the peer opened the stream with id.
+func (m *Muxer) isPeerStream(id uint32) bool {
+	return (id%2 == 1) == m.peerOdd
+}
+
+// failure returns why the muxer stopped.
+func (m *Muxer) failure() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.err
+}
+
+// run is the reader goroutine. It dispatches frames to their streams until
+// the transport fails or a frame breaks the protocol.
+func (m *Muxer) run() {
+	defer close(m.done)
+
+	for {
+		header, data, err := m.t.ReceiveWithHeader()
+		if err == nil {
+			err = m.handleFrame(header, data)
+			if err != nil {
+				_ = m.t.Close()
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				err = qerrors.ErrTunnelClosed
+			}
+			m.stop(err)
+			return
+		}
+	}
+}
+
+// stop fails the muxer and every open stream with err.
+func (m *Muxer) stop(err error) {
+	m.mu.Lock()
+	m.err = err
+	streams := m.streams
+	m.streams = make(map[uint32]*Stream)
+	m.peerCount = 0
+	m.mu.Unlock()
+
+	for _, s := range streams {
+		s.fail(err)
+	}
+}
+
+// handleFrame dispatches one received frame.
+func (m *Muxer) handleFrame(header, data []byte) error {
+	if len(header) != muxHeaderSize {
+		return qerrors.ErrInvalidMessage
+	}
+	frame := muxFrameType(header[0])
+	id := binary.BigEndian.Uint32(header[1:])
+	switch frame {
+	case muxFrameData, muxFrameWindow:
+	case muxFrameOpen, muxFrameClose, muxFrameReset:
+		if len(data) != 0 {
+			return qerrors.ErrInvalidMessage
+		}
+	default:
+		return qerrors.ErrInvalidMessage
+	}
+
+	if frame == muxFrameOpen {
+		return m.handleOpen(id)
+	}
+
+	m.mu.Lock()
+	s := m.streams[id]
+	m.mu.Unlock()
+	if s == nil {
+		// Frames for a stream may still arrive after it was reset
+		return nil
+	}
+
+	switch frame {
+	case muxFrameData:
+		return s.receive(data)
+	case muxFrameClose:
+		s.receiveClose()
+	case muxFrameReset:
+		s.fail(qerrors.ErrMuxStreamReset)
+		m.remove(s)
+	case muxFrameWindow:
+		if len(data) != 4 {
+			return qerrors.ErrInvalidMessage
+		}
+		return s.receiveWindow(binary.BigEndian.Uint32(data))
+	default:
+		return qerrors.ErrInvalidMessage
+	}
+	return nil
+}
+
+// handleOpen registers a stream opened by the peer and queues it for
+// AcceptStream, or resets it if the peer has too many streams open.
+func (m *Muxer) handleOpen(id uint32) error {
+	if !m.isPeerStream(id) {
+		return qerrors.ErrInvalidMessage
+	}
+
+	m.mu.Lock()
+	if _, ok := m.streams[id]; ok {
+		m.mu.Unlock()
+		return qerrors.ErrInvalidMessage
+	}
+	if m.peerCount >= m.config.MaxStreams || len(m.accept) == cap(m.accept) {
+		m.mu.Unlock()
+		return m.queueFrame(muxFrameReset, id, nil)
+	}
+	s := m.newStream(id)
+	m.streams[id] = s
+	m.peerCount++
+	// Only this goroutine sends, and the backlog has room
+	m.accept <- s
+	m.mu.Unlock()
+
+	if extra := s.extraWindow(); extra > 0 {
+		return m.queueFrame(muxFrameWindow, id, muxWindowIncrement(extra))
+	}
+	return nil
+}
+
+// Stream is a bidirectional stream multiplexed by a Muxer. It is an
+// io.ReadWriteCloser, and delivers data in order. Read and Write may be
+// called concurrently with each other.
+type Stream struct {
+	id      uint32
+	m       *Muxer
+	writeMu sync.Mutex // Keeps concurrent writes from interleaving
+
+	mu           sync.Mutex
+	cond         *sync.Cond // Signals data, window and state changes
+	buf          []byte     // Received data not yet read
+	consumed     uint32     // Bytes read since the last window update
+	recvWindow   uint32     // Bytes the peer may still send
+	sendWindow   uint32     // Bytes the peer still accepts
+	remoteClosed bool       // The peer sent close
+	writeClosed  bool       // Close or CloseWrite was called
+	closed       bool       // Close was called
+	err          error      // Reset, or why the muxer stopped
+}
+
+// ID returns the stream ID.
+func (s *Stream) ID() uint32 {
+	return s.id
+}
+
+// Read reads data from the stream, blocking until some is available. It
+// returns io.EOF once the peer has closed its side and all its data was
+// read, and ErrMuxStreamReset if either peer reset the stream.
+func (s *Stream) Read(p []byte) (int, error) {
+	s.mu.Lock()
+	for len(s.buf) == 0 && !s.remoteClosed && !s.closed && s.err == nil {
+		s.cond.Wait()
+	}
+	switch {
+	case s.closed:
+		s.mu.Unlock()
+		return 0, qerrors.ErrMuxStreamClosed
+	case s.err != nil:
+		s.mu.Unlock()
+		return 0, s.err
+	case len(s.buf) == 0:
+		s.mu.Unlock()
+		return 0, io.EOF
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+	increment := s.credit(n)
+	s.mu.Unlock()
+
+	if increment > 0 {
+		if err := s.sendWindowUpdate(increment); err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// credit notes n bytes taken from the receive buffer and returns the window
+// increment to grant the peer, once enough has been read to be worth an
+// update. Must hold s.mu.
+func (s *Stream) credit(n int) uint32 {
+	//nolint:gosec // G115: n is bounded by the receive window
+	s.consumed += uint32(n)
+	if s.consumed < s.m.config.StreamWindow/2 {
+		return 0
+	}
+	increment := s.consumed
+	s.consumed = 0
+	s.recvWindow += increment
+	return increment
+}
+
+// Write writes data to the stream, blocking while the peer's receive window
+// is full. It fails with ErrWriteClosed after CloseWrite, and with
+// ErrMuxStreamReset if either peer reset the stream.
+func (s *Stream) Write(p []byte) (int, error) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	n := 0
+	for {
+		s.mu.Lock()
+		for n < len(p) && s.sendWindow == 0 && !s.writeClosed && s.err == nil {
+			s.cond.Wait()
+		}
+		if err := s.writeErr(); err != nil {
+			s.mu.Unlock()
+			return n, err
+		}
+		if n == len(p) {
+			s.mu.Unlock()
+			return n, nil
+		}
+		chunk := min(len(p)-n, int(s.sendWindow), s.m.maxChunk)
+		//nolint:gosec // G115: chunk is bounded by the send window
+		s.sendWindow -= uint32(chunk)
+		s.mu.Unlock()
+
+		if err := s.m.sendFrame(muxFrameData, s.id, p[n:n+chunk]); err != nil {
+			return n, err
+		}
+		n += chunk
+	}
+}
+
+// writeErr returns why the stream cannot be written. Must hold s.mu.
+func (s *Stream) writeErr() error {
+	switch {
+	case s.closed:
+		return qerrors.ErrMuxStreamClosed
+	case s.err != nil:
+		return s.err
+	case s.writeClosed:
+		return qerrors.ErrWriteClosed
+	}
+	return nil
+}
+
+// CloseWrite tells the peer no more data follows, so its reads return
+// io.EOF. The stream can still be read.
+func (s *Stream) CloseWrite() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	if err := s.writeErr(); err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.writeClosed = true
+	done := s.remoteClosed
+	s.mu.Unlock()
+
+	if done {
+		s.m.remove(s)
+	}
+	return s.m.sendFrame(muxFrameClose, s.id, nil)
+}
+
+// Close closes the stream. Unless CloseWrite was called, it tells the peer
+// no more data follows. Data the peer sends afterwards is discarded. Other
+// streams are not affected.
+func (s *Stream) Close() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return qerrors.ErrMuxStreamClosed
+	}
+	s.closed = true
+	s.buf = nil
+	sendClose := !s.writeClosed && s.err == nil
+	s.writeClosed = true
+	done := s.remoteClosed || s.err != nil
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	if done {
+		s.m.remove(s)
+	}
+	if sendClose {
+		return s.m.sendFrame(muxFrameClose, s.id, nil)
+	}
+	return nil
+}
+
+// Reset aborts the stream in both directions. Pending and later reads and
+// writes on both ends fail with ErrMuxStreamReset.
+func (s *Stream) Reset() error {
+	s.mu.Lock()
+	if s.err != nil {
+		s.mu.Unlock()
+		return nil
+	}
+	s.mu.Unlock()
+
+	s.fail(qerrors.ErrMuxStreamReset)
+	s.m.remove(s)
+	return s.m.sendFrame(muxFrameReset, s.id, nil)
+}
+
+// fail stops the stream with err, waking blocked reads and writes.
+func (s *Stream) fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil {
+		s.err = err
+		s.buf = nil
+	}
+	s.cond.Broadcast()
+}
+
+// extraWindow adds the part of StreamWindow beyond the initial window to
+// the receive window, and returns it for granting to the peer.
+func (s *Stream) extraWindow() uint32 {
+	extra := s.m.config.StreamWindow - muxInitialWindow
+	s.mu.Lock()
+	s.recvWindow += extra
+	s.mu.Unlock()
+	return extra
+}
+
+// sendWindowUpdate grants the peer increment more bytes.
+func (s *Stream) sendWindowUpdate(increment uint32) error {
+	return s.m.sendFrame(muxFrameWindow, s.id, muxWindowIncrement(increment))
+}
+
+// muxWindowIncrement encodes the data of a window frame.
+func muxWindowIncrement(increment uint32) []byte {
+	return binary.BigEndian.AppendUint32(nil, increment)
+}
+
+// receive buffers data from the peer. Data beyond the receive window
+// breaks flow control and resets the stream.
+func (s *Stream) receive(data []byte) error {
+	s.mu.Lock()
+	if s.remoteClosed || uint64(len(data)) > uint64(s.recvWindow) {
+		s.mu.Unlock()
+		s.fail(qerrors.ErrMuxStreamReset)
+		s.m.remove(s)
+		return s.m.queueFrame(muxFrameReset, s.id, nil)
+	}
+	//nolint:gosec // G115: len(data) is bounded by the receive window
+	s.recvWindow -= uint32(len(data))
+
+	var increment uint32
+	switch {
+	case s.err != nil:
+	case s.closed:
+		// Nobody reads any more, so return the credit at once
+		increment = s.credit(len(data))
+	default:
+		s.buf = append(s.buf, data...)
+		s.cond.Broadcast()
+	}
+	s.mu.Unlock()
+
+	if increment > 0 {
+		return s.m.queueFrame(muxFrameWindow, s.id, muxWindowIncrement(increment))
+	}
+	return nil
+}
+
+// receiveClose notes that the peer sends no more data.
+func (s *Stream) receiveClose() {
+	s.mu.Lock()
+	s.remoteClosed = true
+	done := s.writeClosed
+	s.cond.Broadcast()
+	s.mu.Unlock()
+
+	if done {
+		s.m.remove(s)
+	}
+}
+
+// receiveWindow adds the peer's window increment to the send window.
+func (s *Stream) receiveWindow(increment uint32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if uint64(s.sendWindow)+uint64(increment) > muxMaxWindow {
+		return qerrors.ErrInvalidMessage
+	}
+	s.sendWindow += increment
+	s.cond.Broadcast()
+	return nil
+}
@@ -0,0 +1,303 @@
+package tunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// newMuxers returns a connected client/server muxer pair.
+func newMuxers(t *testing.T, config MuxConfig) (*Muxer, *Muxer) {
+	t.Helper()
+
+	client, server := newPipeTransports(t)
+	clientMux, serverMux := NewMuxer(client, config), NewMuxer(server, config)
+	t.Cleanup(func() {
+		_ = clientMux.Close()
+		_ = serverMux.Close()
+	})
+	return clientMux, serverMux
+}
+
+// acceptStream accepts a stream on m, failing the test on error.
+func acceptStream(t *testing.T, m *Muxer) *Stream {
+	t.Helper()
+
+	s, err := m.AcceptStream()
+	if err != nil {
+		t.Fatalf("AcceptStream failed: %v", err)
+	}
+	return s
+}
+
+func TestMuxerInterleavedStreams(t *testing.T) {
+	clientMux, serverMux := newMuxers(t, MuxConfig{})
+
+	const streams, writes = 4, 50
+	opened := make([]*Stream, streams)
+	for i := range opened {
+		s, err := clientMux.OpenStream()
+		if err != nil {
+			t.Fatalf("OpenStream failed: %v", err)
+		}
+		if s.ID()%2 != 1 {
+			t.Errorf("initiator opened stream with even ID %d", s.ID())
+		}
+		opened[i] = s
+	}
+	accepted := make(map[uint32]*Stream)
+	for range streams {
+		s := acceptStream(t, serverMux)
+		accepted[s.ID()] = s
+	}
+
+	// Every stream writes concurrently, so their frames interleave
+	var wg sync.WaitGroup
+	for i, s := range opened {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range writes {
+				if _, err := fmt.Fprintf(s, "stream %d write %d\n", i, j); err != nil {
+					t.Errorf("Write failed: %v", err)
+					return
+				}
+			}
+			if err := s.CloseWrite(); err != nil {
+				t.Errorf("CloseWrite failed: %v", err)
+			}
+		}()
+	}
+
+	for i, s := range opened {
+		peer := accepted[s.ID()]
+		if peer == nil {
+			t.Fatalf("stream %d was not accepted", s.ID())
+		}
+		got, err := io.ReadAll(peer)
+		if err != nil {
+			t.Fatalf("ReadAll failed: %v", err)
+		}
+		var want bytes.Buffer
+		for j := range writes {
+			fmt.Fprintf(&want, "stream %d write %d\n", i, j)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Errorf("stream %d delivered its writes out of order or incomplete", i)
+		}
+	}
+	wg.Wait()
+
+	// The responder opens even streams, and half-closed streams still read
+	s, err := serverMux.OpenStream()
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	if s.ID()%2 != 0 {
+		t.Errorf("responder opened stream with odd ID %d", s.ID())
+	}
+	peer := acceptStream(t, clientMux)
+	if _, err := peer.Write([]byte("reply")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	buf := make([]byte, 16)
+	if n, err := s.Read(buf); err != nil || string(buf[:n]) != "reply" {
+		t.Errorf("Read = %q, %v, want %q", buf[:n], err, "reply")
+	}
+}
+
+func TestMuxerIndependentClose(t *testing.T) {
+	clientMux, serverMux := newMuxers(t, MuxConfig{})
+
+	var client, server [3]*Stream
+	for i := range client {
+		var err error
+		if client[i], err = clientMux.OpenStream(); err != nil {
+			t.Fatalf("OpenStream failed: %v", err)
+		}
+		server[i] = acceptStream(t, serverMux)
+	}
+
+	// Closing one stream ends it for the peer only
+	if err := client[0].Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := server[0].Read(make([]byte, 1)); err != io.EOF {
+		t.Errorf("Read on closed stream = %v, want io.EOF", err)
+	}
+	if _, err := client[0].Write([]byte("late")); !errors.Is(err, qerrors.ErrMuxStreamClosed) {
+		t.Errorf("Write after Close = %v, want ErrMuxStreamClosed", err)
+	}
+	// The peer may still write; the data is discarded
+	if _, err := server[0].Write([]byte("ignored")); err != nil {
+		t.Errorf("Write to a stream closed by the peer failed: %v", err)
+	}
+
+	// Resetting another aborts it in both directions
+	if err := server[1].Reset(); err != nil {
+		t.Fatalf("Reset failed: %v", err)
+	}
+	if _, err := client[1].Read(make([]byte, 1)); !errors.Is(err, qerrors.ErrMuxStreamReset) {
+		t.Errorf("Read on reset stream = %v, want ErrMuxStreamReset", err)
+	}
+	if _, err := client[1].Write([]byte("late")); !errors.Is(err, qerrors.ErrMuxStreamReset) {
+		t.Errorf("Write on reset stream = %v, want ErrMuxStreamReset", err)
+	}
+
+	// The remaining stream is unaffected
+	for _, pair := range [][2]*Stream{{client[2], server[2]}, {server[2], client[2]}} {
+		if _, err := pair[0].Write([]byte("still open")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		buf := make([]byte, 32)
+		if n, err := pair[1].Read(buf); err != nil || string(buf[:n]) != "still open" {
+			t.Errorf("Read = %q, %v, want %q", buf[:n], err, "still open")
+		}
+	}
+
+	// Closing the muxer fails the streams still open
+	_ = clientMux.Close()
+	if _, err := server[2].Read(make([]byte, 1)); !errors.Is(err, qerrors.ErrTunnelClosed) {
+		t.Errorf("Read after the peer closed = %v, want ErrTunnelClosed", err)
+	}
+	if _, err := serverMux.AcceptStream(); !errors.Is(err, qerrors.ErrTunnelClosed) {
+		t.Errorf("AcceptStream after the peer closed = %v, want ErrTunnelClosed", err)
+	}
+}
+
+func TestMuxerFlowControl(t *testing.T) {
+	clientMux, serverMux := newMuxers(t, MuxConfig{})
+
+	slow, err := clientMux.OpenStream()
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	slowPeer := acceptStream(t, serverMux)
+	fast, err := clientMux.OpenStream()
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	fastPeer := acceptStream(t, serverMux)
+
+	// Writing more than the window to an unread stream blocks
+	data := bytes.Repeat([]byte("flow"), muxInitialWindow/2)
+	written := make(chan error, 1)
+	go func() {
+		_, err := slow.Write(data)
+		written <- err
+	}()
+	select {
+	case err := <-written:
+		t.Fatalf("Write beyond the window returned early: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Other streams keep flowing meanwhile
+	if _, err := fast.Write([]byte("not starved")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	buf := make([]byte, 32)
+	if n, err := fastPeer.Read(buf); err != nil || string(buf[:n]) != "not starved" {
+		t.Errorf("Read = %q, %v, want %q", buf[:n], err, "not starved")
+	}
+
+	// Reading grants window, so the blocked write completes
+	got := make([]byte, len(data))
+	if _, err := io.ReadFull(slowPeer, got); err != nil {
+		t.Fatalf("ReadFull failed: %v", err)
+	}
+	if err := <-written; err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("blocked stream delivered corrupted data")
+	}
+	if slowPeer.recvWindow > muxInitialWindow {
+		t.Errorf("receive window grew to %d beyond %d", slowPeer.recvWindow, muxInitialWindow)
+	}
+}
+
+func TestMuxerStreamLimits(t *testing.T) {
+	clientMux, serverMux := newMuxers(t, MuxConfig{MaxStreams: 1})
+
+	first, err := clientMux.OpenStream()
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	firstPeer := acceptStream(t, serverMux)
+
+	// A stream beyond the limit is reset by the peer
+	second, err := clientMux.OpenStream()
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	if _, err := second.Read(make([]byte, 1)); !errors.Is(err, qerrors.ErrMuxStreamReset) {
+		t.Errorf("Read on stream over the limit = %v, want ErrMuxStreamReset", err)
+	}
+
+	// Closing the first on both ends frees its slot
+	if err := first.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := firstPeer.Read(make([]byte, 1)); err != io.EOF {
+		t.Fatalf("Read on closed stream = %v, want io.EOF", err)
+	}
+	if err := firstPeer.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	third, err := clientMux.OpenStream()
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	if _, err := third.Write([]byte("fits")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	buf := make([]byte, 8)
+	if n, err := acceptStream(t, serverMux).Read(buf); err != nil || string(buf[:n]) != "fits" {
+		t.Errorf("Read = %q, %v, want %q", buf[:n], err, "fits")
+	}
+}
+
+func TestMuxerFramesEncrypted(t *testing.T) {
+	client, server := newPipeTransports(t)
+	clientMux := NewMuxer(client, MuxConfig{})
+	t.Cleanup(func() { _ = clientMux.Close() })
+	inbox := readMessages(server)
+
+	s, err := clientMux.OpenStream()
+	if err != nil {
+		t.Fatalf("OpenStream failed: %v", err)
+	}
+	if _, err := s.Write([]byte("secret")); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	// Neither the frame header nor the data appears on the wire
+	for _, want := range []struct {
+		frame muxFrameType
+		data  string
+	}{{muxFrameOpen, ""}, {muxFrameData, "secret"}} {
+		msg := <-inbox
+		var header [muxHeaderSize]byte
+		header[0] = byte(want.frame)
+		binary.BigEndian.PutUint32(header[1:], s.ID())
+		if bytes.Contains(msg, header[:]) || (want.data != "" && bytes.Contains(msg, []byte(want.data))) {
+			t.Errorf("frame %d sent in the clear", want.frame)
+		}
+
+		_, gotHeader, data, err := server.openRecord(msg, false)
+		if err != nil {
+			t.Fatalf("openRecord failed: %v", err)
+		}
+		if !bytes.Equal(gotHeader, header[:]) || string(data) != want.data {
+			t.Errorf("record = %x, %q, want %x, %q", gotHeader, data, header, want.data)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+// Package tunnel implements record option negotiation for the CH-KEM VPN.
+//
+// This file (negotiation.go) lets peers agree on record compression and
+// padding in the hellos instead of relying on matching configuration, and
+// checks that they agree on key commitment:
+//
+//	Initiator                              Responder
+//	    |                                      |
+//	    | -------- ClientHello --------------> |
+//	    |   - compression: offered algorithms  |
+//	    |   - padding: initiator pads          |
+//	    |   - key commitment: initiator commits|
+//	    |                                      |
+//	    | <------- ServerHello --------------- |
+//	    |   - compression: selected algorithm  |
+//	    |   - padding: both peers pad          |
+//	    |   - key commitment: both peers commit|
+//
+// The responder selects the first offered algorithm it is configured with
+// and echoes padding only if it pads too. An extension the responder does
+// not echo, including one an older responder does not understand, leaves
+// the option disabled on both sides. Both hellos are covered by the
+// Finished verify_data, so a man in the middle cannot strip the offers.
+//
+// Key commitment is not optional in the same way: records sealed by a
+// committing cipher do not open under a plain one, so a responder whose
+// setting differs from the initiator's fails the handshake with
+// ErrKeyCommitmentMismatch, as does an initiator whose setting is not
+// echoed, including by an older responder.
+//
+// Padding modes are not negotiated: each peer pads its own records as
+// configured, and unpadding does not depend on the sender's mode.
+package tunnel
+
+import (
+	"bytes"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// SetCompression makes the handshake negotiate record compression: the
+// initiator offers c and the responder accepts c if offered. With required
+// set, an initiator whose offer is declined fails the handshake with
+// ErrUnsupportedCompression.
+func (h *Handshake) SetCompression(c Compression, required bool) {
+	h.negotiateRecords = true
+	h.compression = c
+	h.compressionRequired = required
+}
+
+// SetPadding makes the handshake negotiate record padding: records are
+// padded only if both peers enable it.
+func (h *Handshake) SetPadding(enabled bool) {
+	h.negotiateRecords = true
+	h.padding = enabled
+}
+
+// offerRecordOptions adds the initiator's record options to the
+// ClientHello extensions.
+func (h *Handshake) offerRecordOptions(extensions map[uint16][]byte) {
+	if h.compression.Enabled() && h.compression.IsSupported() {
+		extensions[protocol.ExtensionCompression] = []byte{byte(h.compression)}
+	}
+	if h.padding {
+		extensions[protocol.ExtensionPadding] = []byte{}
+	}
+	if h.session.keyCommitment {
+		extensions[protocol.ExtensionKeyCommitment] = []byte{}
+	}
+}
+
+// selectRecordOptions picks the record options for the session from the
+// initiator's offers and adds them to the ServerHello extensions.
+func (h *Handshake) selectRecordOptions(extensions map[uint16][]byte) {
+	if h.session.keyCommitment {
+		extensions[protocol.ExtensionKeyCommitment] = []byte{}
+	}
+	if !h.negotiateRecords {
+		return
+	}
+
+	compression := CompressionNone
+	if h.compression.Enabled() && bytes.IndexByte(h.compressionOffers, byte(h.compression)) >= 0 {
+		compression = h.compression
+		extensions[protocol.ExtensionCompression] = []byte{byte(compression)}
+	}
+	padding := h.padding && h.paddingOffered
+	if padding {
+		extensions[protocol.ExtensionPadding] = []byte{}
+	}
+	h.session.setRecordOptions(compression, padding)
+}
+
+// acceptRecordOptions checks the responder's selection in the ServerHello
+// extensions against the initiator's offers and records it.
+func (h *Handshake) acceptRecordOptions(extensions map[uint16][]byte) error {
+	if err := h.checkKeyCommitment(extensions); err != nil {
+		return err
+	}
+
+	selected, compressed := extensions[protocol.ExtensionCompression]
+	_, padding := extensions[protocol.ExtensionPadding]
+
+	// The responder may only select what we offered
+	offered := h.compression.Enabled() && h.compression.IsSupported()
+	if compressed && (!offered || len(selected) != 1 || selected[0] != byte(h.compression)) {
+		return qerrors.NewProtocolError("handshake", qerrors.ErrInvalidMessage)
+	}
+	if padding && !h.padding {
+		return qerrors.NewProtocolError("handshake", qerrors.ErrInvalidMessage)
+	}
+	if !h.negotiateRecords {
+		return nil
+	}
+	if offered && !compressed && h.compressionRequired {
+		return qerrors.ErrUnsupportedCompression
+	}
+
+	compression := CompressionNone
+	if compressed {
+		compression = h.compression
+	}
+	h.session.setRecordOptions(compression, padding)
+	return nil
+}
+
+// checkKeyCommitment checks the peer's hello extensions against the local
+// key commitment setting.
+func (h *Handshake) checkKeyCommitment(extensions map[uint16][]byte) error {
+	if _, ok := extensions[protocol.ExtensionKeyCommitment]; ok != h.session.keyCommitment {
+		return qerrors.ErrKeyCommitmentMismatch
+	}
+	return nil
+}
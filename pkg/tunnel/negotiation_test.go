@@ -0,0 +1,346 @@
+package tunnel
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// negotiateHandshake runs a handshake between an initiator configured with
+// client and a responder configured with server, and returns transports
+// over both sessions. A nil configure runs that side without negotiation.
+func negotiateHandshake(t *testing.T, client, server func(*Handshake), config TransportConfig) (*Transport, *Transport, error) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	})
+	if client == nil {
+		client = func(*Handshake) {}
+	}
+	if server == nil {
+		server = func(*Handshake) {}
+	}
+
+	clientSession, _ := NewSession(RoleInitiator)
+	serverSession, _ := NewSession(RoleResponder)
+
+	var wg sync.WaitGroup
+	var serverErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		serverErr = runResponderHandshake(serverSession, serverConn, server)
+		if serverErr != nil {
+			// Drain the initiator's alert
+			_ = serverConn.SetReadDeadline(time.Now().Add(time.Second))
+			_, _ = serverConn.Read(make([]byte, 1024))
+		}
+	}()
+
+	err := runInitiatorHandshake(clientSession, clientConn, client)
+	if err != nil {
+		_ = clientConn.Close()
+		wg.Wait()
+		return nil, nil, err
+	}
+	wg.Wait()
+	if serverErr != nil {
+		return nil, nil, serverErr
+	}
+
+	clientTransport, err := NewTransport(clientSession, clientConn, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	serverTransport, err := NewTransport(serverSession, serverConn, config)
+	if err != nil {
+		return nil, nil, err
+	}
+	return clientTransport, serverTransport, nil
+}
+
+// checkRoundTrip sends data from client to server and checks it arrives.
+func checkRoundTrip(t *testing.T, client, server *Transport, data []byte) {
+	t.Helper()
+
+	go func() { _ = client.Send(data) }()
+	got, err := server.Receive()
+	if err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("got %d bytes, want %d", len(got), len(data))
+	}
+}
+
+func TestNegotiateCompressionAndPadding(t *testing.T) {
+	configure := func(h *Handshake) {
+		h.SetCompression(CompressionGzip, false)
+		h.SetPadding(true)
+	}
+	config := DefaultTransportConfig()
+	config.Compression = CompressionGzip
+	config.Padding = PaddingBlock(64)
+
+	client, server, err := negotiateHandshake(t, configure, configure, config)
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	for _, tr := range []*Transport{client, server} {
+		state := tr.ConnectionState()
+		if state.Compression != CompressionGzip || !state.Padding {
+			t.Errorf("%v: negotiated compression %v, padding %v; want gzip with padding",
+				tr.session.Role, state.Compression, state.Padding)
+		}
+	}
+	checkRoundTrip(t, client, server, bytes.Repeat([]byte("compressible "), 100))
+}
+
+func TestNegotiateFallback(t *testing.T) {
+	offer := func(h *Handshake) {
+		h.SetCompression(CompressionGzip, false)
+		h.SetPadding(true)
+	}
+	decline := func(h *Handshake) {
+		h.SetCompression(CompressionNone, false)
+		h.SetPadding(false)
+	}
+	config := DefaultTransportConfig()
+	config.Compression = CompressionGzip
+	config.Padding = PaddingBlock(64)
+
+	tests := []struct {
+		name           string
+		client, server func(*Handshake)
+	}{
+		{"responder declines", offer, decline},
+		{"responder unaware", offer, nil},
+		{"initiator declines", decline, offer},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server, err := negotiateHandshake(t, tt.client, tt.server, config)
+			if err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			state := client.ConnectionState()
+			if state.Compression != CompressionNone || state.Padding {
+				t.Errorf("negotiated compression %v, padding %v; want neither", state.Compression, state.Padding)
+			}
+
+			// The unaware responder keeps its config, so only check
+			// peers that both negotiated
+			if tt.server != nil {
+				checkRoundTrip(t, client, server, []byte("uncompressed"))
+			}
+		})
+	}
+}
+
+func TestNegotiateRequiredCompression(t *testing.T) {
+	client := func(h *Handshake) { h.SetCompression(CompressionGzip, true) }
+	server := func(h *Handshake) { h.SetCompression(CompressionNone, false) }
+
+	_, _, err := negotiateHandshake(t, client, server, DefaultTransportConfig())
+	if !errors.Is(err, qerrors.ErrUnsupportedCompression) {
+		t.Fatalf("expected ErrUnsupportedCompression, got %v", err)
+	}
+}
+
+func TestNegotiateUnofferedSelection(t *testing.T) {
+	client, _ := NewSession(RoleInitiator)
+	h := NewHandshake(client)
+	h.SetCompression(CompressionNone, false)
+
+	// A responder may not select compression or padding nobody offered
+	for _, ext := range []map[uint16][]byte{
+		{protocol.ExtensionCompression: {byte(CompressionGzip)}},
+		{protocol.ExtensionPadding: {}},
+	} {
+		if err := h.acceptRecordOptions(ext); !errors.Is(err, qerrors.ErrInvalidMessage) {
+			t.Errorf("expected ErrInvalidMessage for %v, got %v", ext, err)
+		}
+	}
+}
+
+func TestNegotiateKeyCommitment(t *testing.T) {
+	commit := func(h *Handshake) { h.session.SetKeyCommitment(true) }
+	plain := func(*Handshake) {}
+
+	client, server, err := negotiateHandshake(t, commit, commit, DefaultTransportConfig())
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+	if !client.session.sendCipher.Committing() || !server.session.sendCipher.Committing() {
+		t.Error("traffic ciphers are not key-committing")
+	}
+	checkRoundTrip(t, client, server, []byte("committed"))
+
+	// Peers with different settings fail the handshake, not every record
+	for _, tt := range []struct {
+		name           string
+		client, server func(*Handshake)
+	}{
+		{"initiator only", commit, plain},
+		{"responder only", plain, commit},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			clientConn, serverConn := net.Pipe()
+			defer func() { _ = clientConn.Close() }()
+			defer func() { _ = serverConn.Close() }()
+
+			clientSession, _ := NewSession(RoleInitiator)
+			serverSession, _ := NewSession(RoleResponder)
+			serverErr := make(chan error, 1)
+			go func() {
+				err := runResponderHandshake(serverSession, serverConn, tt.server)
+				_ = serverConn.Close()
+				serverErr <- err
+			}()
+			clientErr := runInitiatorHandshake(clientSession, clientConn, tt.client)
+			_ = clientConn.Close()
+
+			// The responder sees the mismatch in the ClientHello
+			if err := <-serverErr; !errors.Is(err, qerrors.ErrKeyCommitmentMismatch) {
+				t.Errorf("responder: expected ErrKeyCommitmentMismatch, got %v", err)
+			}
+			if clientErr == nil {
+				t.Error("initiator completed a mismatched handshake")
+			}
+		})
+	}
+
+	// An initiator whose setting is not echoed, as by an older responder,
+	// fails too
+	session, _ := NewSession(RoleInitiator)
+	session.SetKeyCommitment(true)
+	if err := NewHandshake(session).acceptRecordOptions(map[uint16][]byte{}); !errors.Is(err, qerrors.ErrKeyCommitmentMismatch) {
+		t.Errorf("unechoed commitment: expected ErrKeyCommitmentMismatch, got %v", err)
+	}
+}
+
+func TestNegotiateNullEncryption(t *testing.T) {
+	allow := func(h *Handshake) { h.SetAllowNullEncryption(true) }
+	nullSuite := constants.CipherSuiteNullEncryption
+	if crypto.FIPSMode() {
+		// Never negotiated in FIPS mode, even when both peers allow it
+		nullSuite = constants.CipherSuiteAES256GCM
+	}
+
+	for _, tt := range []struct {
+		name           string
+		client, server func(*Handshake)
+		want           constants.CipherSuite
+	}{
+		{"both allow", allow, allow, nullSuite},
+		{"client allows", allow, nil, constants.CipherSuiteAES256GCM},
+		{"server allows", nil, allow, constants.CipherSuiteAES256GCM},
+		{"neither allows", nil, nil, constants.CipherSuiteAES256GCM},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server, err := negotiateHandshake(t, tt.client, tt.server, DefaultTransportConfig())
+			if err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			for _, tr := range []*Transport{client, server} {
+				if suite := tr.ConnectionState().CipherSuite; suite != tt.want {
+					t.Errorf("%v: negotiated %v, want %v", tr.session.Role, suite, tt.want)
+				}
+			}
+			checkRoundTrip(t, client, server, []byte("inspect me"))
+
+			// Only the null suite leaves plaintext visible in records
+			ciphertext, _, err := client.session.Encrypt([]byte("inspect me"))
+			if err != nil {
+				t.Fatalf("Encrypt failed: %v", err)
+			}
+			if visible := bytes.Contains(ciphertext, []byte("inspect me")); visible != (tt.want == constants.CipherSuiteNullEncryption) {
+				t.Errorf("plaintext visible in record = %v with %v", visible, tt.want)
+			}
+		})
+	}
+}
+
+func TestNegotiateNullEncryptionRefused(t *testing.T) {
+	// An initiator offering only the null suite is refused by a responder
+	// that does not allow it
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	client.cipherSuites = []constants.CipherSuite{constants.CipherSuiteNullEncryption}
+	clientHello, err := NewHandshake(client).CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+	if err := NewHandshake(server).ProcessClientHello(clientHello); !errors.Is(err, qerrors.ErrUnsupportedCipherSuite) {
+		t.Errorf("expected ErrUnsupportedCipherSuite, got %v", err)
+	}
+
+	// Session configs cannot name it either
+	cfg := DefaultSessionConfig()
+	cfg.CipherSuites = []constants.CipherSuite{constants.CipherSuiteNullEncryption}
+	if _, err := NewSessionWithConfig(RoleInitiator, cfg); !errors.Is(err, qerrors.ErrUnsupportedCipherSuite) {
+		t.Errorf("NewSessionWithConfig: expected ErrUnsupportedCipherSuite, got %v", err)
+	}
+}
+
+func TestNullEncryptionTampering(t *testing.T) {
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+
+	err := client.InitializeKeys(masterSecret, constants.CipherSuiteNullEncryption)
+	if crypto.FIPSMode() {
+		if !errors.Is(err, qerrors.ErrCipherSuiteNotFIPSApproved) {
+			t.Fatalf("FIPS mode: expected ErrCipherSuiteNotFIPSApproved, got %v", err)
+		}
+		return
+	}
+	if err != nil {
+		t.Fatalf("InitializeKeys failed: %v", err)
+	}
+	if err := server.InitializeKeys(masterSecret, constants.CipherSuiteNullEncryption); err != nil {
+		t.Fatalf("InitializeKeys failed: %v", err)
+	}
+
+	// Any modified byte, of the nonce, plaintext or tag, fails authentication
+	for _, i := range []int{0, constants.AESNonceSize + 1, -1} {
+		ciphertext, seq, err := client.Encrypt([]byte("authenticated only"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if i < 0 {
+			i += len(ciphertext)
+		}
+		ciphertext[i] ^= 0x01
+		if _, err := server.Decrypt(ciphertext, seq); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+			t.Errorf("byte %d tampered: expected ErrAuthenticationFailed, got %v", i, err)
+		}
+	}
+
+	ciphertext, seq, err := client.Encrypt([]byte("authenticated only"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if !bytes.Contains(ciphertext, []byte("authenticated only")) {
+		t.Error("null encryption should leave the plaintext visible")
+	}
+	plaintext, err := server.Decrypt(ciphertext, seq)
+	if err != nil || string(plaintext) != "authenticated only" {
+		t.Fatalf("Decrypt = %q, %v", plaintext, err)
+	}
+	if _, err := server.Decrypt(ciphertext, seq); !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("replayed record: expected ErrReplayDetected, got %v", err)
+	}
+}
@@ -1,6 +1,12 @@
 package tunnel
 
-import qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+import (
+	"context"
+	"net"
+	"os"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
 
 func isProtocolError(err error) bool {
 	if err == nil {
@@ -22,3 +28,29 @@ func isProtocolError(err error) bool {
 		qerrors.Is(err, qerrors.ErrInvalidTicket) ||
 		qerrors.Is(err, qerrors.ErrExpiredTicket)
 }
+
+// handshakeFailureReason classifies a handshake error for
+// Observer.OnHandshakeFailed.
+func handshakeFailureReason(err error) string {
+	var netErr net.Error
+	switch {
+	case qerrors.Is(err, qerrors.ErrUnsupportedVersion):
+		return HandshakeFailureUnsupportedVersion
+	case qerrors.Is(err, qerrors.ErrInvalidCiphertext),
+		qerrors.Is(err, qerrors.ErrDecapsulationFailed):
+		return HandshakeFailureBadCiphertext
+	case qerrors.Is(err, qerrors.ErrInvalidPublicKey):
+		return HandshakeFailureBadPublicKey
+	case qerrors.Is(err, qerrors.ErrAuthenticationFailed):
+		return HandshakeFailureAuthFailed
+	case qerrors.Is(err, qerrors.ErrUnauthorized):
+		return HandshakeFailureUnauthorized
+	case qerrors.Is(err, qerrors.ErrTimeout),
+		qerrors.Is(err, os.ErrDeadlineExceeded),
+		qerrors.Is(err, context.DeadlineExceeded),
+		qerrors.As(err, &netErr) && netErr.Timeout():
+		return HandshakeFailureTimeout
+	default:
+		return HandshakeFailureOther
+	}
+}
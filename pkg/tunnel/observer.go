@@ -1,6 +1,9 @@
 package tunnel
 
-import "context"
+import (
+	"context"
+	"time"
+)
 
 // Observer provides hooks for tunnel lifecycle, metrics, and tracing.
 // Implementations should be lightweight; callbacks may run on hot paths.
@@ -9,14 +12,81 @@ type Observer interface {
 	OnSessionEnd()
 	OnSessionFailed(err error)
 	OnHandshakeStart(ctx context.Context) (context.Context, func(error))
+	OnHandshakeFailed(reason string, err error)
 	OnEncrypt(ctx context.Context, plaintextLen int) (context.Context, func(error))
 	OnDecrypt(ctx context.Context, ciphertextLen int) (context.Context, func(error))
 	OnReplayDetected()
 	OnAuthFailure()
 	OnRekeyStart(ctx context.Context) (context.Context, func(error))
+	OnRekeyCompleted()
+	OnRekeyFailed(err error)
 	OnProtocolError(err error)
 }
 
+// Handshake failure reasons reported to Observer.OnHandshakeFailed.
+const (
+	// HandshakeFailureUnsupportedVersion means the peer's protocol version is incompatible.
+	HandshakeFailureUnsupportedVersion = "unsupported_version"
+
+	// HandshakeFailureBadCiphertext means the CH-KEM ciphertext was malformed or failed to decapsulate.
+	HandshakeFailureBadCiphertext = "bad_ciphertext"
+
+	// HandshakeFailureBadPublicKey means the peer's CH-KEM public key had the wrong size or a malformed encoding.
+	HandshakeFailureBadPublicKey = "bad_public_key"
+
+	// HandshakeFailureAuthFailed means a Finished message failed to authenticate.
+	HandshakeFailureAuthFailed = "auth_failed"
+
+	// HandshakeFailureUnauthorized means the responder's Authorizer refused the client.
+	HandshakeFailureUnauthorized = "unauthorized"
+
+	// HandshakeFailureTimeout means the handshake deadline expired.
+	HandshakeFailureTimeout = "timeout"
+
+	// HandshakeFailureOther covers all remaining failures, such as I/O errors.
+	HandshakeFailureOther = "other"
+)
+
+// ReplayRejectionObserver is optionally implemented by an Observer to learn
+// why a packet was rejected by the replay window. OnReplayRejected is called
+// after OnReplayDetected with ReplayRejectedOld or ReplayRejectedDuplicate.
+type ReplayRejectionObserver interface {
+	OnReplayRejected(reason string)
+}
+
+// WireObserver is optionally implemented by an Observer to count the bytes
+// a transport writes to and reads from its connection, including framing
+// and AEAD overhead.
+type WireObserver interface {
+	OnWireBytesSent(n int)
+	OnWireBytesReceived(n int)
+}
+
+// NetworkObserver is optionally implemented by an Observer to time the
+// transport's connection I/O separately from the crypto timed by OnEncrypt
+// and OnDecrypt. OnNetworkWrite wraps each write of n bytes; OnNetworkRead
+// wraps each read of a message, including the time spent waiting for the
+// peer to send it.
+type NetworkObserver interface {
+	OnNetworkWrite(ctx context.Context, n int) (context.Context, func(error))
+	OnNetworkRead(ctx context.Context) (context.Context, func(error))
+}
+
+// SendRateLimitObserver is optionally implemented by an Observer to count
+// the data delayed by TransportConfig.SendRateLimit: each Send of n bytes
+// that had to wait for the token bucket reports how long it waited.
+type SendRateLimitObserver interface {
+	OnSendRateLimited(n int, delay time.Duration)
+}
+
+// UnexpectedRekeyObserver is optionally implemented by an Observer to count
+// rekey messages that arrive when the rekey state does not expect them.
+// stale reports whether the message was ignored as a leftover of an
+// abandoned exchange; otherwise the tunnel was closed with an alert.
+type UnexpectedRekeyObserver interface {
+	OnUnexpectedRekey(stale bool)
+}
+
 // ObserverFactory builds a per-session observer.
 type ObserverFactory func(session *Session) Observer
 
@@ -26,3 +96,17 @@ func observerFromConfig(config TransportConfig, session *Session) Observer {
 	}
 	return config.Observer
 }
+
+// TracePropagator is optionally implemented by an Observer to propagate the
+// handshake trace context to the peer. The initiator sends the W3C
+// traceparent of its handshake span in the ClientHello, and the responder
+// starts its handshake span as a child of it.
+type TracePropagator interface {
+	// TraceParent returns the W3C traceparent of the span in ctx, or ""
+	// if there is none.
+	TraceParent(ctx context.Context) string
+
+	// ContextWithTraceParent returns ctx carrying traceparent as the remote
+	// parent span. Invalid values leave ctx unchanged.
+	ContextWithTraceParent(ctx context.Context, traceparent string) context.Context
+}
@@ -0,0 +1,754 @@
+// Package tunnel implements the UDP datagram transport for the CH-KEM VPN.
+//
+// This file (packet.go) provides DialPacket and ListenPacket, which run
+// tunnels over datagram sockets. Every datagram carries exactly one
+// protocol message with its standard 5-byte header, so no stream framing is
+// needed: each encrypted Data message is one datagram. The encrypted
+// ClientFinished and ServerFinished records, which streams frame with a
+// 4-byte length, are sent as ClientFinished and ServerFinished messages.
+//
+// Handshake loss is handled by the initiator, which retransmits its current
+// flight (ClientHello, then ClientFinished) until the responder's reply
+// arrives. The first retransmission follows packetRetransmitInterval and
+// each later one waits twice as long, up to packetMaxRetransmitInterval;
+// after packetHandshakeAttempts sends the handshake fails with ErrTimeout.
+// DialPacketContext also stops retransmitting when its context ends. The
+// responder never retransmits on a timer; it answers a repeated flight with
+// its cached reply, so it sends at most one datagram per datagram received
+// and cannot be used to amplify spoofed traffic. A ClientHello is
+// recognized as repeated by its client random. If ServerFinished is lost,
+// the responder's transport answers the retransmitted ClientFinished while
+// Receive is being called.
+//
+// Alerts are not authenticated and a datagram's source address can be
+// spoofed, so a fatal alert only ends the handshake once the peer has
+// answered a flight of ours from the address the tunnel is bound to: the
+// initiator honors alerts only after the ServerHello has arrived. Before
+// that round trip, and on the responder, which has no further flight to
+// wait for once its round trip completes, alerts are ignored and a
+// handshake the peer abandoned times out instead.
+//
+// After the handshake, lost, duplicated and reordered datagrams are handled
+// by the existing sequence numbers: duplicates are rejected by the replay
+// window, and MaxReorderBuffer restores ordering if the application needs
+// it. Lost data is not retransmitted. Rekey messages are not retransmitted
+// either, so rekeying over a lossy path can fail the tunnel.
+//
+// MTU: a datagram holds at most MaxPayloadSize (65507) bytes, the largest
+// UDP payload over IPv4, so Send accepts at most maxDatagramPlaintext
+// (65466) bytes, less the padding and compression overhead. Datagrams
+// larger than the path MTU are fragmented by IP, and losing any fragment
+// loses the whole message. To avoid fragmentation, keep each send at or
+// below the path MTU minus 41 bytes of tunnel overhead (header, sequence
+// number, nonce and tag), the padding overhead and the IP and UDP headers
+// (28 bytes over IPv4, 48 over IPv6); for a 1500-byte Ethernet MTU over
+// IPv4 that is 1431 bytes. Handshake messages are larger than a typical MTU
+// (the ClientHello carries a CH-KEM public key) and rely on IP
+// fragmentation.
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// maxDatagramPlaintext is the largest plaintext whose Data message
+// (header || seq || nonce || ciphertext || tag) fits in one UDP datagram.
+const maxDatagramPlaintext = maxRecordPlaintext - protocol.HeaderSize - 8
+
+// maxDatagramSize is the largest datagram read; anything longer is not a
+// valid message.
+const maxDatagramSize = protocol.HeaderSize + protocol.MaxMessageSize
+
+// packetPeerQueue is the number of datagrams a PacketListener buffers per
+// peer before dropping new ones.
+const packetPeerQueue = 64
+
+// Handshake retransmission schedule (variables so tests can shorten them).
+var (
+	packetRetransmitInterval    = 250 * time.Millisecond
+	packetMaxRetransmitInterval = 2 * time.Second
+	packetHandshakeAttempts     = 8
+)
+
+// packetRetransmitDelay returns how long to wait for a reply to the given
+// attempt (counting from 0) before retransmitting.
+func packetRetransmitDelay(attempt int) time.Duration {
+	delay := packetRetransmitInterval
+	for range attempt {
+		if delay >= packetMaxRetransmitInterval/2 {
+			return packetMaxRetransmitInterval
+		}
+		delay *= 2
+	}
+	return delay
+}
+
+// packetFlight is the responder's final handshake flight, kept so a
+// retransmitted ClientFinished can be answered after the handshake.
+type packetFlight struct {
+	peer  []byte // The peer's ClientFinished datagram
+	reply []byte // Our ServerFinished datagram
+}
+
+// DialPacket establishes a new tunnel over a datagram network ("udp",
+// "udp4" or "udp6") as initiator.
+func DialPacket(network, address string) (*Tunnel, error) {
+	return DialPacketWithConfig(network, address, DefaultTransportConfig())
+}
+
+// DialPacketWithConfig establishes a new datagram tunnel with custom
+// configuration.
+func DialPacketWithConfig(network, address string, config TransportConfig) (*Tunnel, error) {
+	return DialPacketContext(context.Background(), network, address, config)
+}
+
+// DialPacketContext establishes a new datagram tunnel like
+// DialPacketWithConfig, but stops retransmitting the handshake and returns
+// ctx's error once ctx is done.
+func DialPacketContext(ctx context.Context, network, address string, config TransportConfig) (*Tunnel, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, network, address)
+	if err != nil {
+		return nil, err
+	}
+	return dialPacketConn(ctx, config.recordDatagramConn(conn), config)
+}
+
+// dialPacketConn runs the initiator side of a datagram tunnel over conn.
+func dialPacketConn(ctx context.Context, conn net.Conn, config TransportConfig) (*Tunnel, error) {
+	transport, err := establishInitiator(conn, config, func(session *Session) error {
+		return runPacketInitiatorHandshake(ctx, session, conn, config.configureInitiator)
+	})
+	if err != nil {
+		return nil, err
+	}
+	transport.enablePacketMode(nil)
+	return &Tunnel{Transport: transport}, nil
+}
+
+// runPacketInitiatorHandshake performs the initiator handshake over a
+// datagram connection, retransmitting each flight until it is answered or
+// ctx is done.
+func runPacketInitiatorHandshake(ctx context.Context, session *Session, conn net.Conn, configure func(*Handshake)) error {
+	return observeHandshake(session, func() error {
+		h := NewHandshake(session)
+		configure(h)
+		buf := make([]byte, maxDatagramSize)
+
+		// Send ClientHello until a ServerHello arrives
+		clientHello, err := h.CreateClientHello()
+		if err != nil {
+			return newHandshakeError(HandshakePhaseClientHello, err)
+		}
+		serverHello, err := exchangeDatagrams(ctx, conn, buf, clientHello, nil, true, false, protocol.MessageTypeServerHello)
+		if err != nil {
+			return newHandshakeError(HandshakePhaseServerHello, err)
+		}
+		if err := h.ProcessServerHello(serverHello); err != nil {
+			sendHandshakeAlert(conn, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
+			return newHandshakeError(HandshakePhaseServerHello, err)
+		}
+
+		// Send ClientFinished until a ServerFinished arrives. The ServerHello
+		// completed a round trip, so the responder's alerts are honored.
+		clientFinished, err := h.CreateClientFinished()
+		if err != nil {
+			return newHandshakeError(HandshakePhaseClientFinished, err)
+		}
+		flight := frameDatagram(protocol.MessageTypeClientFinished, clientFinished)
+		serverFinished, err := exchangeDatagrams(ctx, conn, buf, flight, nil, true, true, protocol.MessageTypeServerFinished)
+		if err != nil {
+			return newHandshakeError(HandshakePhaseServerFinished, err)
+		}
+		if err := h.ProcessServerFinished(serverFinished[protocol.HeaderSize:]); err != nil {
+			sendHandshakeAlert(conn, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
+			return newHandshakeError(HandshakePhaseServerFinished, err)
+		}
+
+		return nil
+	})
+}
+
+// runPacketResponderHandshake performs the responder handshake over a
+// datagram connection. It answers repeated flights from the initiator but
+// never retransmits on its own, and returns its final flight for the
+// transport to replay.
+func runPacketResponderHandshake(session *Session, conn net.Conn, configure func(*Handshake)) (*packetFlight, error) {
+	var flight *packetFlight
+	err := observeHandshake(session, func() error {
+		h := NewHandshake(session)
+		configure(h)
+		buf := make([]byte, maxDatagramSize)
+
+		// Receive ClientHello
+		ctx := context.Background()
+		clientHello, err := exchangeDatagrams(ctx, conn, buf, nil, nil, false, false, protocol.MessageTypeClientHello)
+		if err != nil {
+			return newHandshakeError(HandshakePhaseClientHello, err)
+		}
+		if err := h.ProcessClientHello(clientHello); err != nil {
+			sendHandshakeAlert(conn, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
+			return newHandshakeError(HandshakePhaseClientHello, err)
+		}
+
+		// Send ServerHello, resending it for each repeated ClientHello. A
+		// ClientHello with another client random belongs to no retransmission
+		// of ours and is ignored.
+		serverHello, err := h.CreateServerHello()
+		if err != nil {
+			return newHandshakeError(HandshakePhaseServerHello, err)
+		}
+		clientRandom := bytes.Clone(h.clientRandom)
+		repeatedHello := func(msg []byte) bool {
+			if protocol.MessageType(msg[0]) != protocol.MessageTypeClientHello {
+				return false
+			}
+			hello, err := h.codec.DecodeClientHello(msg)
+			return err == nil && bytes.Equal(hello.Random, clientRandom)
+		}
+		clientFinished, err := exchangeDatagrams(ctx, conn, buf, serverHello, repeatedHello, false, false, protocol.MessageTypeClientFinished)
+		if err != nil {
+			return newHandshakeError(HandshakePhaseClientFinished, err)
+		}
+		if err := h.ProcessClientFinished(clientFinished[protocol.HeaderSize:]); err != nil {
+			sendHandshakeAlert(conn, h.codec, protocol.AlertCodeHandshakeFailure, "handshake failed")
+			return newHandshakeError(HandshakePhaseClientFinished, err)
+		}
+
+		// Send ServerFinished
+		serverFinished, err := h.CreateServerFinished()
+		if err != nil {
+			return newHandshakeError(HandshakePhaseServerFinished, err)
+		}
+		reply := frameDatagram(protocol.MessageTypeServerFinished, serverFinished)
+		if _, err := conn.Write(reply); err != nil {
+			return newHandshakeError(HandshakePhaseServerFinished, err)
+		}
+
+		flight = &packetFlight{peer: clientFinished, reply: reply}
+		return nil
+	})
+	return flight, err
+}
+
+// exchangeDatagrams writes flight (if any) and returns the first datagram
+// whose type is in want. A fatal alert from the peer fails the exchange if
+// peerValidated is set, meaning the peer has already answered one of our
+// flights; otherwise alerts are ignored. The flight is resent whenever repeated reports a datagram as the peer's
+// previous flight arriving again and, if retransmit is set, on the backoff
+// schedule of packetRetransmitDelay. The exchange fails with ErrTimeout
+// after packetHandshakeAttempts intervals, or with ctx's error once ctx is
+// done.
+func exchangeDatagrams(ctx context.Context, conn net.Conn, buf, flight []byte, repeated func([]byte) bool, retransmit, peerValidated bool, want ...protocol.MessageType) ([]byte, error) {
+	defer func() { _ = conn.SetReadDeadline(time.Time{}) }()
+	stop := interruptOnCancel(ctx, conn.SetReadDeadline)
+	defer stop()
+
+	codec := protocol.NewCodec()
+	for attempt := 0; attempt < packetHandshakeAttempts; attempt++ {
+		if err := contextError(ctx); err != nil {
+			return nil, err
+		}
+		if flight != nil && (attempt == 0 || retransmit) {
+			if _, err := conn.Write(flight); err != nil {
+				return nil, err
+			}
+		}
+		if err := conn.SetReadDeadline(contextDeadline(ctx, packetRetransmitDelay(attempt))); err != nil {
+			return nil, err
+		}
+
+		for {
+			msg, err := readDatagram(conn, buf)
+			if qerrors.Is(err, os.ErrDeadlineExceeded) {
+				if err := contextError(ctx); err != nil {
+					return nil, err
+				}
+				break
+			}
+			if qerrors.Is(err, qerrors.ErrInvalidMessage) {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+
+			msgType := protocol.MessageType(msg[0])
+			switch {
+			case slices.Contains(want, msgType):
+				return msg, nil
+			case msgType == protocol.MessageTypeAlert && peerValidated:
+				level, code, desc, err := codec.DecodeAlert(msg)
+				if err == nil && level == protocol.AlertLevelFatal {
+					return nil, qerrors.NewProtocolError("handshake", &AlertError{level: level, code: code, desc: desc})
+				}
+			case flight != nil && repeated != nil && repeated(msg):
+				if _, err := conn.Write(flight); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return nil, qerrors.ErrTimeout
+}
+
+// readDatagram reads one datagram into buf and returns a copy of it. It
+// fails with ErrInvalidMessage unless the datagram is exactly one message.
+func readDatagram(conn net.Conn, buf []byte) ([]byte, error) {
+	n, err := conn.Read(buf)
+	if err != nil {
+		return nil, err
+	}
+	if n < protocol.HeaderSize || n == len(buf) {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	if uint64(n) != uint64(protocol.HeaderSize)+uint64(binary.BigEndian.Uint32(buf[1:5])) {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	return append([]byte(nil), buf[:n]...), nil
+}
+
+// frameDatagram prepends a message header to an encrypted handshake record.
+func frameDatagram(msgType protocol.MessageType, record []byte) []byte {
+	msg := make([]byte, protocol.HeaderSize, protocol.HeaderSize+len(record))
+	msg[0] = byte(msgType)
+	//nolint:gosec // G115: handshake records are far smaller than 4 GiB
+	binary.BigEndian.PutUint32(msg[1:], uint32(len(record)))
+	return append(msg, record...)
+}
+
+// enablePacketMode switches the transport to one message per datagram.
+// flight, if set, is the responder's final handshake flight to replay.
+func (t *Transport) enablePacketMode(flight *packetFlight) {
+	t.packet = true
+	t.datagramBuf = make([]byte, maxDatagramSize)
+	t.packetFlight = flight
+}
+
+// readPacket reads the next message in packet mode. Malformed datagrams and
+// late handshake messages are dropped, and a retransmitted ClientFinished
+// is answered with the cached ServerFinished until data arrives.
+func (t *Transport) readPacket(ctx context.Context) ([]byte, error) {
+	for {
+		msg, err := readDatagram(t.conn, t.datagramBuf)
+		if qerrors.Is(err, qerrors.ErrInvalidMessage) {
+			t.recordProtocolError(err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		msgType := protocol.MessageType(msg[0])
+		if flight := t.packetFlight; flight != nil {
+			if bytes.Equal(msg, flight.peer) {
+				if err := t.writeMessage(ctx, flight.reply); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if msgType == protocol.MessageTypeData || msgType == protocol.MessageTypeDataHeader {
+				// The initiator only sends data once it has our ServerFinished
+				t.packetFlight = nil
+			}
+		}
+		if msgType < protocol.MessageTypeData {
+			continue
+		}
+		return msg, nil
+	}
+}
+
+// --- Packet Listener ---
+
+// ListenPacket creates a listener for tunnels over a datagram network
+// ("udp", "udp4" or "udp6"). All tunnels share the listener's socket, so
+// closing the listener closes them too.
+func ListenPacket(network, address string) (*PacketListener, error) {
+	conn, err := net.ListenPacket(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return newPacketListener(conn), nil
+}
+
+// PacketListener accepts incoming datagram tunnels, demultiplexing
+// datagrams to tunnels by their source address.
+//
+// HelloRetry and ResumptionStore are not used over datagrams; rate limits
+// apply as for Listener, counting each peer address as a connection.
+type PacketListener struct {
+	conn net.PacketConn
+
+	mu     sync.Mutex
+	config TransportConfig
+	limits *rateLimiters
+	peers  map[string]*packetPeerConn
+	err    error // Read error that stopped the listener
+
+	accepted  chan *Tunnel
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// newPacketListener starts a listener serving conn.
+func newPacketListener(conn net.PacketConn) *PacketListener {
+	config := DefaultTransportConfig()
+	l := &PacketListener{
+		conn:     conn,
+		config:   config,
+		limits:   newRateLimiters(config.RateLimit),
+		peers:    make(map[string]*packetPeerConn),
+		accepted: make(chan *Tunnel),
+		done:     make(chan struct{}),
+	}
+	go l.serve()
+	return l
+}
+
+// Accept waits for and returns the next tunnel whose handshake completed.
+func (l *PacketListener) Accept() (*Tunnel, error) {
+	select {
+	case tunnel := <-l.accepted:
+		return tunnel, nil
+	case <-l.done:
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.err != nil {
+			return nil, l.err
+		}
+		return nil, net.ErrClosed
+	}
+}
+
+// Close closes the listener and all of its tunnels.
+func (l *PacketListener) Close() error {
+	var err error
+	l.closeOnce.Do(func() {
+		close(l.done)
+		err = l.conn.Close()
+
+		l.mu.Lock()
+		peers := make([]*packetPeerConn, 0, len(l.peers))
+		for _, peer := range l.peers {
+			peers = append(peers, peer)
+		}
+		l.mu.Unlock()
+
+		for _, peer := range peers {
+			_ = peer.Close()
+		}
+	})
+	return err
+}
+
+// Addr returns the listener's network address.
+func (l *PacketListener) Addr() net.Addr {
+	return l.conn.LocalAddr()
+}
+
+// SetConfig sets the transport configuration for new tunnels. An invalid
+// configuration is rejected and the previous one kept.
+func (l *PacketListener) SetConfig(config TransportConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.config = config
+	l.limits = newRateLimiters(config.RateLimit)
+	return nil
+}
+
+// UpdateRateLimits changes the rate limits while the listener is running,
+// keeping the per-IP counts of open tunnels (see Listener.UpdateRateLimits).
+func (l *PacketListener) UpdateRateLimits(limits RateLimitConfig) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.limits.update(limits)
+}
+
+// RateLimitStats returns the rate limits in force, the open tunnels per IP
+// and the handshake token bucket state.
+func (l *PacketListener) RateLimitStats() RateLimitStats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.limits.stats()
+}
+
+// serve reads datagrams and dispatches them to peers until the socket closes.
+func (l *PacketListener) serve() {
+	buf := make([]byte, maxDatagramSize)
+	for {
+		n, addr, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			l.mu.Lock()
+			select {
+			case <-l.done:
+			default:
+				l.err = err
+			}
+			l.mu.Unlock()
+			_ = l.Close()
+			return
+		}
+		l.dispatch(addr, buf[:n])
+	}
+}
+
+// dispatch queues a datagram for its peer. A ClientHello from an unknown
+// address starts a handshake; other datagrams from unknown addresses are
+// dropped.
+func (l *PacketListener) dispatch(addr net.Addr, datagram []byte) {
+	key := addr.String()
+
+	l.mu.Lock()
+	peer, ok := l.peers[key]
+	if !ok {
+		if len(datagram) < protocol.HeaderSize || protocol.MessageType(datagram[0]) != protocol.MessageTypeClientHello {
+			l.mu.Unlock()
+			return
+		}
+		if !l.allowPeer(addr) {
+			l.mu.Unlock()
+			return
+		}
+		peer = newPacketPeerConn(l, addr)
+		l.peers[key] = peer
+		go l.handshake(peer, l.config)
+	}
+	l.mu.Unlock()
+
+	peer.deliver(datagram)
+}
+
+// allowPeer applies the rate limits to a new peer. l.mu must be held.
+func (l *PacketListener) allowPeer(addr net.Addr) bool {
+	ip := packetRemoteIP(addr)
+	return l.limits.allowHandshake(ip, l.config.RateLimitObserver) &&
+		l.limits.allowConnection(ip, l.config.RateLimitObserver)
+}
+
+// handshake runs the responder handshake with a new peer and hands the
+// resulting tunnel to Accept.
+func (l *PacketListener) handshake(peer *packetPeerConn, config TransportConfig) {
+	session, err := NewSessionWithConfig(RoleResponder, config.Session)
+	if err != nil {
+		_ = peer.Close()
+		return
+	}
+	session.SetLogger(config.Logger)
+	session.SetKeyCommitment(config.RequireKeyCommitment)
+	session.SetLockMemory(config.LockMemory)
+	if observer := observerFromConfig(config, session); observer != nil {
+		session.SetObserver(observer)
+		observer.OnSessionStart()
+	}
+
+	conn := config.recordDatagramConn(peer)
+	flight, err := runPacketResponderHandshake(session, conn, func(h *Handshake) {
+		h.SetIdentityKey(config.IdentityKey)
+		h.SetPSK(config.PSK)
+		h.SetAuthorizer(context.Background(), config.Authorizer)
+		h.SetAllowNullEncryption(config.AllowNullEncryption)
+		h.SetCompression(config.Compression, false)
+		h.SetPadding(config.Padding.Enabled())
+	})
+	if err != nil {
+		failPacketSession(session, err)
+		_ = peer.Close()
+		return
+	}
+
+	transport, err := newTransport(session, conn, config, nil)
+	if err != nil {
+		failPacketSession(session, err)
+		_ = peer.Close()
+		return
+	}
+	transport.enablePacketMode(flight)
+
+	select {
+	case l.accepted <- &Tunnel{Transport: transport}:
+	case <-l.done:
+		_ = transport.Close()
+	}
+}
+
+// failPacketSession notifies the session observer of failure.
+func failPacketSession(session *Session, err error) {
+	if session.observer != nil {
+		session.observer.OnSessionFailed(err)
+		session.observer.OnSessionEnd()
+	}
+}
+
+// removePeer forgets peer and releases its rate limit token.
+func (l *PacketListener) removePeer(peer *packetPeerConn) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	key := peer.addr.String()
+	if l.peers[key] != peer {
+		return
+	}
+	delete(l.peers, key)
+	l.limits.ip.ReleaseConnection(packetRemoteIP(peer.addr))
+}
+
+// packetRemoteIP extracts the IP address from a datagram source address.
+func packetRemoteIP(addr net.Addr) string {
+	if udpAddr, ok := addr.(*net.UDPAddr); ok {
+		return udpAddr.IP.String()
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err == nil {
+		return host
+	}
+	return addr.String()
+}
+
+// packetPeerConn is a net.Conn carrying one peer's datagrams over a
+// PacketListener's shared socket. Each Read returns one datagram.
+type packetPeerConn struct {
+	listener *PacketListener
+	addr     net.Addr
+	inbox    chan []byte
+
+	// Read deadline; deadlineChanged is closed and replaced on each change
+	mu              sync.Mutex
+	readDeadline    time.Time
+	deadlineChanged chan struct{}
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// newPacketPeerConn creates the connection for a peer of l.
+func newPacketPeerConn(l *PacketListener, addr net.Addr) *packetPeerConn {
+	return &packetPeerConn{
+		listener:        l,
+		addr:            addr,
+		inbox:           make(chan []byte, packetPeerQueue),
+		deadlineChanged: make(chan struct{}),
+		closed:          make(chan struct{}),
+	}
+}
+
+// deliver queues a copy of datagram, dropping it if the queue is full.
+func (c *packetPeerConn) deliver(datagram []byte) {
+	select {
+	case c.inbox <- append([]byte(nil), datagram...):
+	default:
+	}
+}
+
+// Read returns the next datagram from the peer, truncated to len(b).
+func (c *packetPeerConn) Read(b []byte) (int, error) {
+	for {
+		c.mu.Lock()
+		deadline := c.readDeadline
+		changed := c.deadlineChanged
+		c.mu.Unlock()
+
+		var timer *time.Timer
+		var expired <-chan time.Time
+		if !deadline.IsZero() {
+			wait := time.Until(deadline)
+			if wait <= 0 {
+				return 0, os.ErrDeadlineExceeded
+			}
+			timer = time.NewTimer(wait)
+			expired = timer.C
+		}
+
+		select {
+		case datagram := <-c.inbox:
+			stopTimer(timer)
+			return copy(b, datagram), nil
+		case <-c.closed:
+			stopTimer(timer)
+			return 0, net.ErrClosed
+		case <-expired:
+			return 0, os.ErrDeadlineExceeded
+		case <-changed:
+			stopTimer(timer)
+		}
+	}
+}
+
+// stopTimer stops timer if it is set.
+func stopTimer(timer *time.Timer) {
+	if timer != nil {
+		timer.Stop()
+	}
+}
+
+// Write sends b to the peer as one datagram.
+func (c *packetPeerConn) Write(b []byte) (int, error) {
+	select {
+	case <-c.closed:
+		return 0, net.ErrClosed
+	default:
+	}
+	return c.listener.conn.WriteTo(b, c.addr)
+}
+
+// Close detaches the peer from the listener. The shared socket stays open.
+func (c *packetPeerConn) Close() error {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.listener.removePeer(c)
+	})
+	return nil
+}
+
+// LocalAddr returns the listener's address.
+func (c *packetPeerConn) LocalAddr() net.Addr {
+	return c.listener.conn.LocalAddr()
+}
+
+// RemoteAddr returns the peer's address.
+func (c *packetPeerConn) RemoteAddr() net.Addr {
+	return c.addr
+}
+
+// SetDeadline sets the read deadline; writes to a datagram socket do not block.
+func (c *packetPeerConn) SetDeadline(t time.Time) error {
+	return c.SetReadDeadline(t)
+}
+
+// SetReadDeadline sets the deadline for pending and future Reads.
+func (c *packetPeerConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.readDeadline = t
+	close(c.deadlineChanged)
+	c.deadlineChanged = make(chan struct{})
+	return nil
+}
+
+// SetWriteDeadline is a no-op: the socket is shared between peers, and
+// writes to a datagram socket do not block.
+func (c *packetPeerConn) SetWriteDeadline(time.Time) error {
+	return nil
+}
+
+var _ net.Conn = (*packetPeerConn)(nil)
@@ -0,0 +1,390 @@
+package tunnel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// lossyConn drops the writes numbered in drop (counting from 1).
+type lossyConn struct {
+	net.Conn
+	drop   map[int32]bool
+	writes atomic.Int32
+}
+
+func (c *lossyConn) Write(b []byte) (int, error) {
+	if c.drop[c.writes.Add(1)] {
+		return len(b), nil
+	}
+	return c.Conn.Write(b)
+}
+
+// lossyPacketConn drops the writes numbered in drop (counting from 1).
+type lossyPacketConn struct {
+	net.PacketConn
+	drop   map[int32]bool
+	writes atomic.Int32
+}
+
+func (c *lossyPacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	if c.drop[c.writes.Add(1)] {
+		return len(b), nil
+	}
+	return c.PacketConn.WriteTo(b, addr)
+}
+
+// packetTunnels dials a packet listener whose socket drops the listed server
+// writes, from a client dropping the listed client writes, and checks that a
+// first message gets through. The server receives while the client finishes
+// its handshake, so that it can resend a lost ServerFinished. It returns the
+// client tunnel, the server tunnel and any error from either side.
+func packetTunnels(t *testing.T, clientDrops, serverDrops []int32) (*Tunnel, *Tunnel, error) {
+	t.Helper()
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	listener := newPacketListener(&lossyPacketConn{PacketConn: pc, drop: dropSet(serverDrops)})
+	t.Cleanup(func() { _ = listener.Close() })
+
+	var server *Tunnel
+	var serverErr error
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if server, serverErr = listener.Accept(); serverErr != nil {
+			return
+		}
+		data, err := server.Receive()
+		if err == nil && string(data) != "ping" {
+			err = fmt.Errorf("expected ping, got %q", data)
+		}
+		serverErr = err
+	}()
+
+	conn, err := net.Dial("udp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	client, err := dialPacketConn(context.Background(), &lossyConn{Conn: conn, drop: dropSet(clientDrops)}, DefaultTransportConfig())
+	if err != nil {
+		_ = listener.Close()
+		wg.Wait()
+		return nil, nil, err
+	}
+	t.Cleanup(func() { _ = client.Close() })
+
+	if err := client.Send([]byte("ping")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	wg.Wait()
+	if serverErr != nil {
+		return nil, nil, serverErr
+	}
+	return client, server, nil
+}
+
+// shortenRetransmit speeds up the handshake retransmission schedule for the
+// rest of the test.
+func shortenRetransmit(t *testing.T, interval, maxInterval time.Duration, attempts int) {
+	t.Helper()
+	savedInterval, savedMax, savedAttempts := packetRetransmitInterval, packetMaxRetransmitInterval, packetHandshakeAttempts
+	t.Cleanup(func() {
+		packetRetransmitInterval, packetMaxRetransmitInterval, packetHandshakeAttempts = savedInterval, savedMax, savedAttempts
+	})
+	packetRetransmitInterval, packetMaxRetransmitInterval, packetHandshakeAttempts = interval, maxInterval, attempts
+}
+
+func dropSet(writes []int32) map[int32]bool {
+	set := make(map[int32]bool, len(writes))
+	for _, w := range writes {
+		set[w] = true
+	}
+	return set
+}
+
+func TestPacketTunnel(t *testing.T) {
+	client, server, err := packetTunnels(t, nil, nil)
+	if err != nil {
+		t.Fatalf("handshake failed: %v", err)
+	}
+
+	// A malformed datagram is dropped without failing the tunnel
+	if _, err := client.conn.Write([]byte{0x10, 0, 0, 0, 9}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	for _, msg := range []string{"hello", "world"} {
+		if err := client.Send([]byte(msg)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		data, err := server.Receive()
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if string(data) != msg {
+			t.Errorf("expected %q, got %q", msg, data)
+		}
+	}
+
+	if err := server.Send([]byte("reply")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if data, err := client.Receive(); err != nil || string(data) != "reply" {
+		t.Fatalf("expected reply, got %q (err=%v)", data, err)
+	}
+
+	// Each data message must fit in one datagram
+	if err := client.Send(make([]byte, maxDatagramPlaintext+1)); !errors.Is(err, qerrors.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge, got %v", err)
+	}
+
+	_ = client.Close()
+	if _, err := server.Receive(); !errors.Is(err, qerrors.ErrTunnelClosed) {
+		t.Errorf("expected ErrTunnelClosed after close, got %v", err)
+	}
+}
+
+func TestPacketHandshakeRetransmit(t *testing.T) {
+	tests := []struct {
+		name        string
+		clientDrops []int32
+		serverDrops []int32
+	}{
+		{"ClientHello", []int32{1}, nil},
+		{"ServerHello", nil, []int32{1}},
+		{"ClientFinished", []int32{2}, nil},
+		{"ServerFinished", nil, []int32{2}},
+		{"repeated", []int32{1, 2}, []int32{1}},
+		{"first three ClientHellos", []int32{1, 2, 3}, nil},
+		{"first three ServerHellos", nil, []int32{1, 2, 3}},
+		{"first datagrams both ways", []int32{1, 2}, []int32{1, 2}},
+	}
+	shortenRetransmit(t, 20*time.Millisecond, 80*time.Millisecond, 8)
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := packetTunnels(t, tt.clientDrops, tt.serverDrops); err != nil {
+				t.Fatalf("handshake failed despite retransmission: %v", err)
+			}
+		})
+	}
+}
+
+func TestPacketRetransmitBackoff(t *testing.T) {
+	shortenRetransmit(t, 100*time.Millisecond, 500*time.Millisecond, 8)
+
+	want := []time.Duration{100, 200, 400, 500, 500}
+	for attempt, w := range want {
+		if got := packetRetransmitDelay(attempt); got != w*time.Millisecond {
+			t.Errorf("attempt %d: delay %v, want %v", attempt, got, w*time.Millisecond)
+		}
+	}
+}
+
+func TestPacketHandshakeTimeout(t *testing.T) {
+	shortenRetransmit(t, 20*time.Millisecond, 40*time.Millisecond, 3)
+
+	// A socket that never answers
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer func() { _ = pc.Close() }()
+
+	_, err = DialPacket("udp", pc.LocalAddr().String())
+	if !errors.Is(err, qerrors.ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+
+	// The ClientHello was sent once per attempt
+	buf := make([]byte, maxDatagramSize)
+	_ = pc.SetReadDeadline(time.Now().Add(time.Second))
+	for i := 0; i < 3; i++ {
+		if _, _, err := pc.ReadFrom(buf); err != nil {
+			t.Fatalf("expected ClientHello %d: %v", i+1, err)
+		}
+	}
+}
+
+func TestPacketHandshakeCancel(t *testing.T) {
+	// A socket that never answers
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer func() { _ = pc.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	_, err = DialPacketContext(ctx, "udp", pc.LocalAddr().String(), DefaultTransportConfig())
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("cancellation took %v", elapsed)
+	}
+}
+
+func TestPacketResponderDeduplicatesClientHello(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	go func() {
+		session, _ := NewSession(RoleResponder)
+		_, _ = runPacketResponderHandshake(session, serverConn, func(*Handshake) {})
+		_ = serverConn.Close()
+	}()
+
+	newHello := func() []byte {
+		session, _ := NewSession(RoleInitiator)
+		hello, err := NewHandshake(session).CreateClientHello()
+		if err != nil {
+			t.Fatalf("CreateClientHello failed: %v", err)
+		}
+		return hello
+	}
+	exchange := func(hello []byte, timeout time.Duration) ([]byte, error) {
+		if _, err := clientConn.Write(hello); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		_ = clientConn.SetReadDeadline(time.Now().Add(timeout))
+		return readDatagram(clientConn, make([]byte, maxDatagramSize))
+	}
+
+	hello := newHello()
+	serverHello, err := exchange(hello, time.Second)
+	if err != nil || protocol.MessageType(serverHello[0]) != protocol.MessageTypeServerHello {
+		t.Fatalf("expected ServerHello, got %x (err=%v)", serverHello[:1], err)
+	}
+
+	// A ClientHello with another client random is not a retransmission
+	if msg, err := exchange(newHello(), 50*time.Millisecond); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("expected no reply to a foreign ClientHello, got %x (err=%v)", msg, err)
+	}
+
+	// The retransmitted ClientHello is answered with the same ServerHello
+	again, err := exchange(hello, time.Second)
+	if err != nil {
+		t.Fatalf("expected ServerHello again: %v", err)
+	}
+	if !bytes.Equal(again, serverHello) {
+		t.Error("retransmitted ServerHello differs from the original")
+	}
+}
+
+func TestPacketListenerIgnoresStrayDatagrams(t *testing.T) {
+	listener, err := ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ListenPacket failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	// Datagrams other than a ClientHello do not create peers
+	conn, err := net.Dial("udp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+	_, _ = conn.Write([]byte{0x10, 0, 0, 0, 0})
+	time.Sleep(50 * time.Millisecond)
+
+	listener.mu.Lock()
+	peers := len(listener.peers)
+	listener.mu.Unlock()
+	if peers != 0 {
+		t.Errorf("expected no peers, got %d", peers)
+	}
+
+	_ = listener.Close()
+	if _, err := listener.Accept(); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("expected net.ErrClosed after Close, got %v", err)
+	}
+}
+
+// alertInjectingConn delivers a fatal alert before the datagram read at
+// position inject (counting from 1), as an off-path attacker spoofing the
+// peer's address could.
+type alertInjectingConn struct {
+	net.Conn
+	inject int
+	reads  int
+}
+
+func (c *alertInjectingConn) Read(b []byte) (int, error) {
+	c.reads++
+	if c.reads == c.inject {
+		return copy(b, protocol.NewCodec().EncodeAlert(protocol.AlertLevelFatal, protocol.AlertCodeHandshakeFailure, "spoofed")), nil
+	}
+	return c.Conn.Read(b)
+}
+
+func TestPacketHandshakeIgnoresUnvalidatedAlerts(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	// The initiator sees an alert before the ServerHello, the responder one
+	// before the ClientFinished
+	serverErr := make(chan error, 1)
+	go func() {
+		session, _ := NewSession(RoleResponder)
+		_, err := runPacketResponderHandshake(session, &alertInjectingConn{Conn: serverConn, inject: 2}, func(*Handshake) {})
+		serverErr <- err
+	}()
+
+	session, _ := NewSession(RoleInitiator)
+	err := runPacketInitiatorHandshake(context.Background(), session, &alertInjectingConn{Conn: clientConn, inject: 1}, func(*Handshake) {})
+	if err != nil {
+		t.Fatalf("initiator handshake failed: %v", err)
+	}
+	if err := <-serverErr; err != nil {
+		t.Fatalf("responder handshake failed: %v", err)
+	}
+}
+
+func TestPacketHandshakeHonorsAlertAfterRoundTrip(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+
+	// A responder that answers the ClientHello, then rejects the handshake
+	go func() {
+		defer func() { _ = serverConn.Close() }()
+		session, _ := NewSession(RoleResponder)
+		h := NewHandshake(session)
+		buf := make([]byte, maxDatagramSize)
+		clientHello, err := readDatagram(serverConn, buf)
+		if err != nil || h.ProcessClientHello(clientHello) != nil {
+			return
+		}
+		serverHello, err := h.CreateServerHello()
+		if err != nil {
+			return
+		}
+		_, _ = serverConn.Write(serverHello)
+		if _, err := readDatagram(serverConn, buf); err != nil {
+			return
+		}
+		sendHandshakeAlert(serverConn, h.codec, protocol.AlertCodeHandshakeFailure, "rejected")
+	}()
+
+	session, _ := NewSession(RoleInitiator)
+	err := runPacketInitiatorHandshake(context.Background(), session, clientConn, func(*Handshake) {})
+	var alert *AlertError
+	if !errors.As(err, &alert) {
+		t.Fatalf("expected AlertError, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package tunnel
+
+import (
+	"encoding/binary"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+// paddingLengthSize is the size of the real-length prefix inside padded plaintext.
+const paddingLengthSize = 2
+
+type paddingMode int
+
+const (
+	paddingModeNone paddingMode = iota
+	paddingModeBlock
+	paddingModeRandom
+)
+
+// Padding describes how data records are padded before encryption to hide
+// the size of the plaintext from passive observers.
+//
+// When padding is enabled, each record's plaintext is encoded as
+// RealLength(2B) || Data || Zeros and the whole frame is encrypted, so the
+// padding is covered by the AEAD tag. Both peers must use the same setting.
+type Padding struct {
+	mode paddingMode
+	size int
+}
+
+// PaddingNone disables padding. Record sizes reveal plaintext sizes.
+var PaddingNone = Padding{}
+
+// PaddingBlock pads each record up to the next multiple of n bytes.
+// Values of n below 2 disable padding.
+func PaddingBlock(n int) Padding {
+	if n < 2 {
+		return PaddingNone
+	}
+	return Padding{mode: paddingModeBlock, size: n}
+}
+
+// PaddingRandom appends a uniformly random amount of padding between 0 and
+// max bytes to each record. Values of max below 1 disable padding.
+func PaddingRandom(max int) Padding {
+	if max < 1 {
+		return PaddingNone
+	}
+	return Padding{mode: paddingModeRandom, size: max}
+}
+
+// Enabled reports whether records are padded.
+func (p Padding) Enabled() bool {
+	return p.mode != paddingModeNone
+}
+
+// Overhead returns the fixed per-record overhead added by padding.
+func (p Padding) Overhead() int {
+	if !p.Enabled() {
+		return 0
+	}
+	return paddingLengthSize
+}
+
+// pad frames data with its real length and padding. The padded frame never
+// exceeds limit bytes.
+func (p Padding) pad(data []byte, limit int) ([]byte, error) {
+	if !p.Enabled() {
+		return data, nil
+	}
+
+	framedLen := paddingLengthSize + len(data)
+	if framedLen > limit {
+		return nil, qerrors.ErrMessageTooLarge
+	}
+
+	var padLen int
+	switch p.mode {
+	case paddingModeBlock:
+		if rem := framedLen % p.size; rem != 0 {
+			padLen = p.size - rem
+		}
+	case paddingModeRandom:
+		//nolint:gosec // G115: size is a positive int well below 2^32
+		n, err := uniformRandom(uint32(p.size + 1))
+		if err != nil {
+			return nil, err
+		}
+		padLen = int(n)
+	}
+	if framedLen+padLen > limit {
+		padLen = limit - framedLen
+	}
+
+	frame := make([]byte, framedLen+padLen)
+	//nolint:gosec // G115: len(data) is bounded by limit < 2^16
+	binary.BigEndian.PutUint16(frame, uint16(len(data)))
+	copy(frame[paddingLengthSize:], data)
+	return frame, nil
+}
+
+// uniformRandom returns a uniformly distributed random value in [0, n).
+// Reducing a 32-bit draw modulo n would favor the smallest results, so the
+// first 2^32 mod n values are rejected and redrawn, leaving a multiple of n.
+func uniformRandom(n uint32) (uint32, error) {
+	threshold := -n % n // 2^32 mod n
+	var buf [4]byte
+	for {
+		if err := crypto.SecureRandom(buf[:]); err != nil {
+			return 0, err
+		}
+		if v := binary.BigEndian.Uint32(buf[:]); v >= threshold {
+			return v % n, nil
+		}
+	}
+}
+
+// unpad strips the length prefix and padding from a decrypted frame.
+func (p Padding) unpad(frame []byte) ([]byte, error) {
+	if !p.Enabled() {
+		return frame, nil
+	}
+
+	if len(frame) < paddingLengthSize {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	realLen := int(binary.BigEndian.Uint16(frame))
+	if paddingLengthSize+realLen > len(frame) {
+		return nil, qerrors.ErrInvalidMessage
+	}
+	return frame[paddingLengthSize : paddingLengthSize+realLen], nil
+}
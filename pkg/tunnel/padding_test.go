@@ -0,0 +1,128 @@
+package tunnel
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPaddingBlockHidesSize(t *testing.T) {
+	client, server := newPipeTransports(t)
+	client.padding = PaddingBlock(256)
+	server.padding = PaddingBlock(256)
+
+	small := []byte("hello")
+	large := bytes.Repeat([]byte{'x'}, 200)
+
+	var sizes []int
+	for _, msg := range [][]byte{small, large} {
+		go func(msg []byte) { _ = client.Send(msg) }(msg)
+
+		raw, err := server.codec.ReadMessage(server.conn)
+		if err != nil {
+			t.Fatalf("ReadMessage failed: %v", err)
+		}
+		sizes = append(sizes, len(raw))
+
+		got, err := server.handleData(raw)
+		if err != nil {
+			t.Fatalf("handleData failed: %v", err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Errorf("got %d bytes after unpad, want %d", len(got), len(msg))
+		}
+	}
+
+	if sizes[0] != sizes[1] {
+		t.Errorf("record sizes differ under PaddingBlock(256): %d vs %d", sizes[0], sizes[1])
+	}
+}
+
+func TestPaddingRandomRoundTrip(t *testing.T) {
+	client, server := newPipeTransports(t)
+	client.padding = PaddingRandom(64)
+	server.padding = PaddingRandom(64)
+
+	for i := 0; i < 20; i++ {
+		msg := bytes.Repeat([]byte{byte(i)}, i*7)
+		go func() { _ = client.Send(msg) }()
+
+		got, err := server.Receive()
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if !bytes.Equal(got, msg) {
+			t.Fatalf("message %d mismatch", i)
+		}
+	}
+}
+
+func TestPaddingFrame(t *testing.T) {
+	tests := []struct {
+		name    string
+		padding Padding
+		dataLen int
+		wantLen int
+	}{
+		{"none", PaddingNone, 5, 5},
+		{"block exact", PaddingBlock(16), 14, 16},
+		{"block round up", PaddingBlock(16), 15, 32},
+		{"block empty", PaddingBlock(16), 0, 16},
+		{"block capped", PaddingBlock(1024), 90, 100},
+		{"invalid block", PaddingBlock(1), 5, 5},
+		{"invalid random", PaddingRandom(0), 5, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := bytes.Repeat([]byte{'a'}, tt.dataLen)
+			frame, err := tt.padding.pad(data, 100)
+			if err != nil {
+				t.Fatalf("pad failed: %v", err)
+			}
+			if len(frame) != tt.wantLen {
+				t.Errorf("frame length = %d, want %d", len(frame), tt.wantLen)
+			}
+			got, err := tt.padding.unpad(frame)
+			if err != nil {
+				t.Fatalf("unpad failed: %v", err)
+			}
+			if !bytes.Equal(got, data) {
+				t.Error("unpad did not recover data")
+			}
+		})
+	}
+
+	if _, err := PaddingBlock(16).pad(make([]byte, 99), 100); err == nil {
+		t.Error("expected error when frame exceeds limit")
+	}
+	if _, err := PaddingBlock(16).unpad([]byte{0xFF, 0xFF, 0}); err == nil {
+		t.Error("expected error for length prefix beyond frame")
+	}
+}
+
+func TestUniformRandom(t *testing.T) {
+	// 2^32 is not a multiple of 3, so a plain modulo would be biased
+	const n, draws = 3, 3000
+	var counts [n]int
+	for i := 0; i < draws; i++ {
+		v, err := uniformRandom(n)
+		if err != nil {
+			t.Fatalf("uniformRandom failed: %v", err)
+		}
+		if v >= n {
+			t.Fatalf("uniformRandom(%d) = %d, out of range", n, v)
+		}
+		counts[v]++
+	}
+	for v, c := range counts {
+		// Each count is within about eight standard deviations of 1000
+		if c < 800 || c > 1200 {
+			t.Errorf("value %d drawn %d times out of %d", v, c, draws)
+		}
+	}
+
+	// With n = 1 every draw is accepted and maps to 0
+	if v, err := uniformRandom(1); err != nil || v != 0 {
+		t.Errorf("uniformRandom(1) = %d, %v", v, err)
+	}
+}
@@ -2,8 +2,11 @@ package tunnel
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
@@ -13,17 +16,21 @@ import (
 // It reduces the overhead of establishing new connections by reusing
 // existing ones with established sessions.
 type Pool struct {
-	network string
-	address string
-	config  PoolConfig
+	network  string
+	backends []*poolBackend
+	config   PoolConfig
 
 	mu      sync.Mutex
 	conns   []*pooledConn // All connections (idle + in-use)
 	idle    []*pooledConn // Available connections (LIFO for cache locality)
-	waiters []chan *pooledConn
+	waiters waitQueue     // Acquire calls waiting for a connection
 	closed  bool
 	stats   *PoolStats
 
+	nextBackend int // Round-robin cursor into backends
+
+	breaker *circuitBreaker // nil when FailureThreshold is 0
+
 	healthCtx    context.Context
 	healthCancel context.CancelFunc
 	healthWg     sync.WaitGroup
@@ -32,24 +39,48 @@ type Pool struct {
 // NewPool creates a new connection pool for the given network address.
 // The pool is not started until Start is called.
 func NewPool(network, address string, config PoolConfig) (*Pool, error) {
+	return NewMultiPool(network, []string{address}, config)
+}
+
+// NewMultiPool creates a connection pool that spreads connections across
+// several backend addresses according to config.BalancePolicy.
+//
+// An address that fails to dial or complete a handshake
+// config.QuarantineThreshold times in a row is skipped for an exponentially
+// growing backoff period, and new connections go to the remaining addresses.
+// The pool is not started until Start is called.
+func NewMultiPool(network string, addresses []string, config PoolConfig) (*Pool, error) {
 	config.applyDefaults()
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
+	if len(addresses) == 0 {
+		return nil, errors.New("pool: at least one address is required")
+	}
+
+	backends := make([]*poolBackend, len(addresses))
+	for i, address := range addresses {
+		backends[i] = newPoolBackend(address)
+	}
 
-	return &Pool{
-		network: network,
-		address: address,
-		config:  config,
-		conns:   make([]*pooledConn, 0, config.MaxConns),
-		idle:    make([]*pooledConn, 0, config.MaxConns),
-		waiters: make([]chan *pooledConn, 0),
-		stats:   newPoolStats(),
-	}, nil
+	p := &Pool{
+		network:  network,
+		backends: backends,
+		config:   config,
+		conns:    make([]*pooledConn, 0, config.MaxConns),
+		idle:     make([]*pooledConn, 0, config.MaxConns),
+		stats:    newPoolStats(),
+	}
+	p.breaker = newCircuitBreaker(config.FailureThreshold, config.FailureWindow, config.CircuitCooldown, p.notifyCircuitStateChange)
+	return p, nil
 }
 
-// Start initializes the pool and establishes minimum connections.
-// It also starts background health checking if configured.
+// Start initializes the pool and establishes minimum connections, dialing
+// up to poolStartConcurrency of them at once. It also starts background
+// health checking if configured.
+//
+// If ctx is cancelled before the minimum connections are established, Start
+// closes the connections it opened and returns the context's error.
 func (p *Pool) Start(ctx context.Context) error {
 	p.mu.Lock()
 	if p.closed {
@@ -59,32 +90,112 @@ func (p *Pool) Start(ctx context.Context) error {
 	p.mu.Unlock()
 
 	// Pre-create minimum connections
-	for i := 0; i < p.config.MinConns; i++ {
-		pc, err := p.createConn(ctx)
-		if err != nil {
-			// Log but continue - we'll try again later
-			continue
-		}
-		p.mu.Lock()
-		p.conns = append(p.conns, pc)
-		p.idle = append(p.idle, pc)
-		p.stats.setTotalCount(int64(len(p.conns)))
-		p.stats.setIdleCount(int64(len(p.idle)))
+	conns, err := p.prewarm(ctx, p.config.MinConns)
+	if err != nil {
+		return err
+	}
+	p.mu.Lock()
+	if p.closed {
 		p.mu.Unlock()
+		for _, pc := range conns {
+			_ = pc.tunnel.Close()
+			p.stats.recordConnectionClosed(false)
+			p.notifyConnectionClosed("pool_closed")
+		}
+		return qerrors.ErrPoolClosed
 	}
+	p.conns = append(p.conns, conns...)
+	p.idle = append(p.idle, conns...)
+	p.stats.setTotalCount(int64(len(p.conns)))
+	p.stats.setIdleCount(int64(len(p.idle)))
+	p.mu.Unlock()
 
-	// Start health checker if configured
-	if p.config.HealthCheckInterval > 0 {
+	// Start health checker and idle reaper if configured
+	if p.config.HealthCheckInterval > 0 || p.config.IdleReaperInterval > 0 {
 		p.healthCtx, p.healthCancel = context.WithCancel(context.Background())
+	}
+	if p.config.HealthCheckInterval > 0 {
 		p.healthWg.Add(1)
 		go p.healthChecker()
 	}
+	if p.config.IdleReaperInterval > 0 && (p.config.IdleTimeout > 0 || p.config.MaxLifetime > 0) {
+		p.healthWg.Add(1)
+		go p.idleReaper()
+	}
 
 	return nil
 }
 
-// Close closes all connections in the pool and prevents new acquires.
+// poolStartConcurrency bounds the number of concurrent dials in Start.
+const poolStartConcurrency = 8
+
+// prewarm creates up to n connections concurrently. Dial failures are
+// skipped, since the health checker retries them later, but if ctx is
+// cancelled the connections created so far are closed and ctx's error is
+// returned.
+func (p *Pool) prewarm(ctx context.Context, n int) ([]*pooledConn, error) {
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		conns []*pooledConn
+	)
+	sem := make(chan struct{}, poolStartConcurrency)
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			pc, err := p.createConn(ctx)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			conns = append(conns, pc)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		for _, pc := range conns {
+			_ = pc.tunnel.Close()
+			p.stats.recordConnectionClosed(false)
+			p.notifyConnectionClosed("start_cancelled")
+		}
+		return nil, err
+	}
+	return conns, nil
+}
+
+// poolCloseTimeout bounds Close.
+const poolCloseTimeout = 10 * time.Second
+
+// Close closes all connections in the pool and prevents new acquires, like
+// CloseContext with a 10 second deadline.
 func (p *Pool) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), poolCloseTimeout)
+	defer cancel()
+	return p.CloseContext(ctx)
+}
+
+// CloseContext closes all connections in the pool concurrently and prevents
+// new acquires. Connections that do not close within the pool's
+// CloseTimeout are abandoned, as are those still closing when ctx expires,
+// at which point CloseContext returns without waiting for them. It always
+// waits for the health checker and idle reaper to stop. Abandoned
+// connections are reported by a *PoolCloseError.
+//
+// Calling CloseContext on a closed pool does nothing and returns nil.
+func (p *Pool) CloseContext(ctx context.Context) error {
 	p.mu.Lock()
 	if p.closed {
 		p.mu.Unlock()
@@ -97,11 +208,8 @@ func (p *Pool) Close() error {
 		p.healthCancel()
 	}
 
-	// Close all waiting channels
-	for _, ch := range p.waiters {
-		close(ch)
-	}
-	p.waiters = nil
+	// Wake all waiters
+	p.waiters.closeAll()
 
 	// Collect connections to close
 	connsToClose := make([]*pooledConn, len(p.conns))
@@ -110,21 +218,99 @@ func (p *Pool) Close() error {
 	p.idle = nil
 	p.mu.Unlock()
 
-	// Wait for health checker to stop
-	p.healthWg.Wait()
-
 	// Close all connections outside the lock
+	var (
+		wg     sync.WaitGroup
+		closed atomic.Int64
+	)
 	for _, pc := range connsToClose {
-		_ = pc.tunnel.Close()
-		p.notifyConnectionClosed("pool_closed")
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if p.closeConnWithin(ctx, pc) {
+				closed.Add(1)
+				p.notifyConnectionClosed("pool_closed")
+			} else {
+				p.notifyConnectionClosed("close_abandoned")
+			}
+		}()
 	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
 
+	var err error
+	select {
+	case <-done:
+		err = qerrors.ErrPoolCloseTimeout
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	// Wait for the cancelled health checker and idle reaper, so neither
+	// touches the pool after CloseContext returns. Their dials and rekeys
+	// stop with the cancellation, and one blocked on a stalled connection
+	// is freed once that connection has been abandoned above.
+	p.healthWg.Wait()
+
+	if abandoned := len(connsToClose) - int(closed.Load()); abandoned > 0 {
+		return &PoolCloseError{Abandoned: abandoned, Err: err}
+	}
 	return nil
 }
 
+// closeConnWithin closes pc, abandoning it if it has not closed after the
+// pool's CloseTimeout or when ctx expires. An abandoned connection has its
+// underlying connection closed, which unblocks a stalled close-notify write
+// on most connections. It reports whether pc closed before being abandoned.
+func (p *Pool) closeConnWithin(ctx context.Context, pc *pooledConn) bool {
+	done := make(chan struct{})
+	go func() {
+		_ = pc.tunnel.Close()
+		close(done)
+	}()
+
+	timer := time.NewTimer(p.config.CloseTimeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	_ = pc.tunnel.conn.Close()
+	return false
+}
+
+// PoolCloseError is returned by Pool.Close and Pool.CloseContext when
+// connections were abandoned instead of closing gracefully.
+type PoolCloseError struct {
+	Abandoned int   // Connections that had not closed
+	Err       error // ctx's error if it expired, otherwise ErrPoolCloseTimeout
+}
+
+func (e *PoolCloseError) Error() string {
+	return fmt.Sprintf("pool: %d connections abandoned on close: %v", e.Abandoned, e.Err)
+}
+
+// Unwrap returns the underlying error for errors.Is/As support.
+func (e *PoolCloseError) Unwrap() error {
+	return e.Err
+}
+
 // Acquire gets a connection from the pool, waiting up to WaitTimeout if necessary.
 // The returned PoolConn must be released with Release() or closed with Close().
+// It waits with priority 0; see AcquireWithPriority.
 func (p *Pool) Acquire(ctx context.Context) (*PoolConn, error) {
+	return p.AcquireWithPriority(ctx, 0)
+}
+
+// AcquireWithPriority gets a connection like Acquire, but if the pool is
+// exhausted, released connections go to waiters with a higher priority
+// first. Waiters with equal priority are served in arrival order.
+func (p *Pool) AcquireWithPriority(ctx context.Context, priority int) (*PoolConn, error) {
 	startTime := time.Now()
 
 	p.mu.Lock()
@@ -133,10 +319,14 @@ func (p *Pool) Acquire(ctx context.Context) (*PoolConn, error) {
 		return nil, qerrors.ErrPoolClosed
 	}
 
-	// Try to get an idle connection
-	if pc := p.tryGetIdleLocked(); pc != nil {
-		p.mu.Unlock()
-		return p.finishAcquire(pc, startTime, true), nil
+	// Try to get an idle connection, unless callers are already queued:
+	// released connections go to them first, so a new caller must not
+	// take one ahead of a long-waiting goroutine
+	if p.waiters.len() == 0 {
+		if pc := p.tryGetIdleLocked(); pc != nil {
+			p.mu.Unlock()
+			return p.finishAcquire(pc, startTime, true), nil
+		}
 	}
 
 	// Check if we can create a new connection
@@ -146,16 +336,18 @@ func (p *Pool) Acquire(ctx context.Context) (*PoolConn, error) {
 	}
 
 	// Pool is exhausted, wait for a connection or return error
-	return p.waitForConnection(ctx, startTime)
+	return p.waitForConnection(ctx, priority, startTime)
 }
 
 // tryGetIdleLocked attempts to get a healthy idle connection. Must hold lock.
 // Returns nil if no healthy connection available.
 func (p *Pool) tryGetIdleLocked() *pooledConn {
 	for len(p.idle) > 0 {
-		// Pop from end (LIFO for better cache locality)
-		pc := p.idle[len(p.idle)-1]
-		p.idle = p.idle[:len(p.idle)-1]
+		// Pop from end (LIFO for better cache locality), preferring
+		// connections to backends that are not quarantined
+		i := p.preferredIdleIndexLocked()
+		pc := p.idle[i]
+		p.idle = append(p.idle[:i], p.idle[i+1:]...)
 
 		if p.isHealthy(pc) {
 			pc.inUse.Store(true)
@@ -164,13 +356,28 @@ func (p *Pool) tryGetIdleLocked() *pooledConn {
 
 		// Connection is unhealthy, close it
 		p.removeConnLocked(pc)
+		p.stats.recordConnectionRemoved()
 		p.closeConnAsync(pc, "unhealthy")
 	}
 	return nil
 }
 
+// preferredIdleIndexLocked returns the index of the most recently used idle
+// connection whose backend is not quarantined, or the last index if every
+// idle connection's backend is. Must hold lock.
+func (p *Pool) preferredIdleIndexLocked() int {
+	now := time.Now()
+	for i := len(p.idle) - 1; i >= 0; i-- {
+		if p.idle[i].backend.available(now) {
+			return i
+		}
+	}
+	return len(p.idle) - 1
+}
+
 // finishAcquire completes the acquire and returns a PoolConn.
 func (p *Pool) finishAcquire(pc *pooledConn, startTime time.Time, fromPool bool) *PoolConn {
+	pc.uses.Add(1)
 	duration := time.Since(startTime)
 	p.stats.recordAcquire(duration, fromPool)
 	p.notifyAcquire(duration, fromPool)
@@ -178,16 +385,15 @@ func (p *Pool) finishAcquire(pc *pooledConn, startTime time.Time, fromPool bool)
 }
 
 // waitForConnection waits for a connection to become available or times out.
-func (p *Pool) waitForConnection(ctx context.Context, startTime time.Time) (*PoolConn, error) {
+func (p *Pool) waitForConnection(ctx context.Context, priority int, startTime time.Time) (*PoolConn, error) {
 	if p.config.WaitTimeout == 0 {
 		p.mu.Unlock()
 		p.recordTimeout()
 		return nil, qerrors.ErrPoolExhausted
 	}
 
-	// Create wait channel
-	ch := make(chan *pooledConn, 1)
-	p.waiters = append(p.waiters, ch)
+	// Queue a waiter
+	w := p.waiters.push(priority)
 	p.stats.incrementWaiting()
 	p.mu.Unlock()
 
@@ -196,12 +402,12 @@ func (p *Pool) waitForConnection(ctx context.Context, startTime time.Time) (*Poo
 	defer timer.Stop()
 
 	select {
-	case pc := <-ch:
-		return p.handleWaitResult(ctx, pc, startTime)
+	case pc := <-w.ch:
+		return p.handleWaitResult(ctx, pc, priority, startTime)
 	case <-timer.C:
-		return p.handleWaitTimeout(ch, qerrors.ErrPoolTimeout)
+		return p.handleWaitTimeout(w, qerrors.ErrPoolTimeout)
 	case <-ctx.Done():
-		return p.handleWaitTimeout(ch, ctx.Err())
+		return p.handleWaitTimeout(w, ctx.Err())
 	}
 }
 
@@ -217,7 +423,7 @@ func (p *Pool) effectiveTimeout(ctx context.Context) time.Duration {
 }
 
 // handleWaitResult processes a connection received from wait channel.
-func (p *Pool) handleWaitResult(ctx context.Context, pc *pooledConn, startTime time.Time) (*PoolConn, error) {
+func (p *Pool) handleWaitResult(ctx context.Context, pc *pooledConn, priority int, startTime time.Time) (*PoolConn, error) {
 	p.stats.decrementWaiting()
 	if pc == nil {
 		return nil, qerrors.ErrPoolClosed
@@ -226,9 +432,10 @@ func (p *Pool) handleWaitResult(ctx context.Context, pc *pooledConn, startTime t
 	if !p.isHealthy(pc) {
 		p.mu.Lock()
 		p.removeConnLocked(pc)
+		p.stats.recordConnectionRemoved()
 		p.mu.Unlock()
 		p.closeConnAsync(pc, "unhealthy")
-		return p.Acquire(ctx)
+		return p.AcquireWithPriority(ctx, priority)
 	}
 
 	pc.inUse.Store(true)
@@ -236,10 +443,16 @@ func (p *Pool) handleWaitResult(ctx context.Context, pc *pooledConn, startTime t
 }
 
 // handleWaitTimeout cleans up after a timeout or context cancellation.
-func (p *Pool) handleWaitTimeout(ch chan *pooledConn, err error) (*PoolConn, error) {
+func (p *Pool) handleWaitTimeout(w *poolWaiter, err error) (*PoolConn, error) {
 	p.mu.Lock()
-	p.removeWaiter(ch)
+	removed := p.waiters.remove(w)
 	p.mu.Unlock()
+	if !removed {
+		// A connection was handed off as the wait ended; pass it on
+		if pc := <-w.ch; pc != nil {
+			_ = p.release(pc)
+		}
+	}
 	p.stats.decrementWaiting()
 	p.recordTimeout()
 	return nil, err
@@ -296,13 +509,35 @@ func (p *Pool) notifyHealthCheck(healthy bool) {
 	}
 }
 
+// notifyCircuitStateChange notifies observer of a circuit breaker transition.
+func (p *Pool) notifyCircuitStateChange(state CircuitState) {
+	if p.config.Observer != nil {
+		p.config.Observer.OnCircuitStateChange(state)
+	}
+}
+
 // notifyPoolStats notifies observer of pool statistics.
 func (p *Pool) notifyPoolStats() {
 	if p.config.Observer != nil {
-		p.config.Observer.OnPoolStats(p.stats.Snapshot())
+		p.config.Observer.OnPoolStats(p.Stats())
 	}
 }
 
+// AcquireFresh acquires a connection like Acquire and rekeys it (see
+// PoolConn.Rekey), so it carries no traffic under keys used before. If the
+// rekey fails, the connection is closed and the error returned.
+func (p *Pool) AcquireFresh(ctx context.Context) (*PoolConn, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := conn.rekey(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
 // TryAcquire attempts to get a connection without waiting.
 // Returns ErrPoolExhausted if no connection is available.
 func (p *Pool) TryAcquire() (*PoolConn, error) {
@@ -324,9 +559,20 @@ func (p *Pool) TryAcquire() (*PoolConn, error) {
 	return conn, err
 }
 
-// Stats returns the current pool statistics.
+// Stats returns the current pool statistics, including per-address counts.
 func (p *Pool) Stats() PoolStatsSnapshot {
-	return p.stats.Snapshot()
+	snap := p.stats.Snapshot()
+	snap.Backends = make([]BackendStatsSnapshot, len(p.backends))
+	for i, b := range p.backends {
+		snap.Backends[i] = b.snapshot(snap.Timestamp)
+	}
+	return snap
+}
+
+// CircuitState returns the current state of the pool's circuit breaker.
+// It is always CircuitClosed when FailureThreshold is 0.
+func (p *Pool) CircuitState() CircuitState {
+	return p.breaker.currentState()
 }
 
 // Size returns the current total number of connections (idle + in-use).
@@ -368,17 +614,21 @@ func (p *Pool) release(pc *pooledConn) error {
 	// If unhealthy, close it
 	if pc.unhealthy.Load() {
 		p.removeConnLocked(pc)
-		p.stats.recordConnectionClosed(false)
+		p.stats.recordConnectionRemoved()
 		p.closeConnAsync(pc, "marked_unhealthy")
 		return nil
 	}
 
-	// Check if there are waiters
-	if len(p.waiters) > 0 {
-		ch := p.waiters[0]
-		p.waiters = p.waiters[1:]
-		pc.inUse.Store(true) // Mark as in use before handing off
-		ch <- pc
+	// Retire connections that have reached their use limit
+	if p.config.MaxConnUses > 0 && pc.uses.Load() >= int64(p.config.MaxConnUses) {
+		p.removeConnLocked(pc)
+		p.stats.recordConnectionRemoved()
+		p.stats.recordRetirement()
+		p.closeConnAsync(pc, "max_uses")
+		return nil
+	}
+
+	if p.handOffLocked(pc) {
 		return nil
 	}
 
@@ -390,6 +640,18 @@ func (p *Pool) release(pc *pooledConn) error {
 	return nil
 }
 
+// handOffLocked hands pc to the highest-priority waiter, reporting whether
+// there was one. Must hold lock.
+func (p *Pool) handOffLocked(pc *pooledConn) bool {
+	w := p.waiters.pop()
+	if w == nil {
+		return false
+	}
+	pc.inUse.Store(true) // Mark as in use before handing off
+	w.ch <- pc
+	return true
+}
+
 // createAndAcquire creates a new connection and returns it.
 func (p *Pool) createAndAcquire(ctx context.Context, startTime time.Time) (*PoolConn, error) {
 	pc, err := p.createConn(ctx)
@@ -412,8 +674,56 @@ func (p *Pool) createAndAcquire(ctx context.Context, startTime time.Time) (*Pool
 	return p.finishAcquire(pc, startTime, false), nil
 }
 
-// createConn creates a new tunnel connection.
+// createConn creates a new tunnel connection, trying each backend in
+// balance order until one succeeds. It fails fast with ErrPoolCircuitOpen
+// while the circuit breaker is open.
 func (p *Pool) createConn(ctx context.Context) (*pooledConn, error) {
+	if !p.breaker.allow() {
+		return nil, qerrors.ErrPoolCircuitOpen
+	}
+
+	pc, err := p.dialBackends(ctx)
+	if err != nil {
+		p.breaker.failure()
+		return nil, err
+	}
+	p.breaker.success()
+	return pc, nil
+}
+
+// dialBackends tries each backend in balance order until one succeeds.
+func (p *Pool) dialBackends(ctx context.Context) (*pooledConn, error) {
+	p.mu.Lock()
+	backends := p.backendOrderLocked()
+	p.mu.Unlock()
+
+	var lastErr error
+	for _, b := range backends {
+		pc, err := p.dialBackend(ctx, b)
+		if err == nil {
+			return pc, nil
+		}
+		lastErr = err
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	return nil, lastErr
+}
+
+// dialBackend dials and handshakes with a single backend, updating its health.
+func (p *Pool) dialBackend(ctx context.Context, b *poolBackend) (*pooledConn, error) {
+	pc, err := p.dialAddress(ctx, b)
+	if err != nil {
+		b.recordFailure(p.config.QuarantineThreshold, p.config.QuarantineBackoff, p.config.MaxQuarantineBackoff)
+		return nil, err
+	}
+	b.recordSuccess()
+	return pc, nil
+}
+
+// dialAddress establishes a tunnel to the backend's address.
+func (p *Pool) dialAddress(ctx context.Context, b *poolBackend) (*pooledConn, error) {
 	dialStart := time.Now()
 
 	// Create dialer with timeout
@@ -422,10 +732,11 @@ func (p *Pool) createConn(ctx context.Context) (*pooledConn, error) {
 		d.Timeout = p.config.DialTimeout
 	}
 
-	conn, err := d.DialContext(ctx, p.network, p.address)
+	conn, err := d.DialContext(ctx, p.network, b.address)
 	if err != nil {
 		return nil, err
 	}
+	conn = p.config.TransportConfig.recordConn(conn)
 
 	// Create session as initiator
 	session, err := NewSession(RoleInitiator)
@@ -434,21 +745,29 @@ func (p *Pool) createConn(ctx context.Context) (*pooledConn, error) {
 		return nil, err
 	}
 
-	// Perform handshake
-	if err := InitiatorHandshake(session, conn); err != nil {
+	// Perform handshake, aborting it if ctx is cancelled or it times out
+	hsCtx, cancel := p.config.TransportConfig.handshakeContext(ctx)
+	err = withHandshakeDeadline(hsCtx, conn, func() error {
+		return runInitiatorHandshake(session, conn, p.config.TransportConfig.configureInitiator)
+	})
+	cancel()
+	if err != nil {
 		_ = conn.Close()
 		return nil, err
 	}
 
-	// Create transport
-	transport, err := NewTransport(session, conn, p.config.TransportConfig)
+	// Create transport, tracking open connections per backend
+	transport, err := newTransport(session, conn, p.config.TransportConfig, func(*Transport) {
+		b.active.Add(-1)
+	})
 	if err != nil {
 		_ = conn.Close()
 		return nil, err
 	}
+	b.active.Add(1)
 
 	tunnel := &Tunnel{Transport: transport}
-	pc := newPooledConn(tunnel, p)
+	pc := newPooledConn(tunnel, p, b)
 
 	dialDuration := time.Since(dialStart)
 	p.stats.recordConnectionCreated(dialDuration)
@@ -464,13 +783,8 @@ func (p *Pool) isHealthy(pc *pooledConn) bool {
 		return false
 	}
 
-	// Check max lifetime
-	if p.config.MaxLifetime > 0 && pc.age() > p.config.MaxLifetime {
-		return false
-	}
-
-	// Check idle timeout
-	if p.config.IdleTimeout > 0 && pc.idleTime() > p.config.IdleTimeout {
+	// Check max lifetime and idle timeout
+	if p.expiry(pc) != "" {
 		return false
 	}
 
@@ -506,21 +820,40 @@ func (p *Pool) removeConnLocked(pc *pooledConn) {
 	p.stats.setIdleCount(int64(len(p.idle)))
 }
 
-// removeWaiter removes a wait channel from the waiters list.
-func (p *Pool) removeWaiter(ch chan *pooledConn) {
-	for i, w := range p.waiters {
-		if w == ch {
-			p.waiters = append(p.waiters[:i], p.waiters[i+1:]...)
+// healthChecker runs periodic health checks on idle connections.
+func (p *Pool) healthChecker() {
+	defer p.healthWg.Done()
+
+	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.healthCtx.Done():
 			return
+		case <-ticker.C:
+			p.runHealthCheck()
 		}
 	}
 }
 
-// healthChecker runs periodic health checks on idle connections.
-func (p *Pool) healthChecker() {
+// expiry returns why pc has outlived MaxLifetime or IdleTimeout, or "" if
+// it has not.
+func (p *Pool) expiry(pc *pooledConn) string {
+	if p.config.MaxLifetime > 0 && pc.age() > p.config.MaxLifetime {
+		return "max_lifetime"
+	}
+	if p.config.IdleTimeout > 0 && pc.idleTime() > p.config.IdleTimeout {
+		return "idle_timeout"
+	}
+	return ""
+}
+
+// idleReaper periodically closes expired idle connections.
+func (p *Pool) idleReaper() {
 	defer p.healthWg.Done()
 
-	ticker := time.NewTicker(p.config.HealthCheckInterval)
+	ticker := time.NewTicker(p.config.IdleReaperInterval)
 	defer ticker.Stop()
 
 	for {
@@ -528,11 +861,51 @@ func (p *Pool) healthChecker() {
 		case <-p.healthCtx.Done():
 			return
 		case <-ticker.C:
-			p.runHealthCheck()
+			p.reapIdle()
 		}
 	}
 }
 
+// reapIdle closes idle connections past IdleTimeout or MaxLifetime, so
+// connections that are never acquired again do not linger.
+func (p *Pool) reapIdle() {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+
+	var expired []*pooledConn
+	var reasons []string
+	newIdle := make([]*pooledConn, 0, len(p.idle))
+	for _, pc := range p.idle {
+		if reason := p.expiry(pc); reason != "" {
+			expired = append(expired, pc)
+			reasons = append(reasons, reason)
+		} else {
+			newIdle = append(newIdle, pc)
+		}
+	}
+	if len(expired) == 0 {
+		p.mu.Unlock()
+		return
+	}
+
+	p.idle = newIdle
+	for _, pc := range expired {
+		p.removeConnLocked(pc)
+		p.stats.recordConnectionRemoved()
+	}
+	p.stats.setIdleCount(int64(len(p.idle)))
+	p.mu.Unlock()
+
+	// Close expired connections outside the lock
+	for i, pc := range expired {
+		_ = pc.tunnel.Close()
+		p.notifyConnectionClosed(reasons[i])
+	}
+}
+
 // runHealthCheck checks all idle connections and removes unhealthy ones.
 func (p *Pool) runHealthCheck() {
 	p.mu.Lock()
@@ -560,6 +933,7 @@ func (p *Pool) runHealthCheck() {
 	p.idle = newIdle
 	for _, pc := range unhealthy {
 		p.removeConnLocked(pc)
+		p.stats.recordConnectionRemoved()
 	}
 
 	p.stats.setIdleCount(int64(len(p.idle)))
@@ -571,13 +945,15 @@ func (p *Pool) runHealthCheck() {
 		p.notifyConnectionClosed("health_check_failed")
 	}
 
+	p.preRekey()
+
 	// Try to maintain minimum connections
 	p.mu.Lock()
 	deficit := p.config.MinConns - len(p.conns)
 	p.mu.Unlock()
 
 	if deficit > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), p.config.DialTimeout)
+		ctx, cancel := context.WithTimeout(p.healthCtx, p.config.DialTimeout)
 		defer cancel()
 
 		for i := 0; i < deficit; i++ {
@@ -592,9 +968,11 @@ func (p *Pool) runHealthCheck() {
 				return
 			}
 			p.conns = append(p.conns, pc)
-			p.idle = append(p.idle, pc)
 			p.stats.setTotalCount(int64(len(p.conns)))
-			p.stats.setIdleCount(int64(len(p.idle)))
+			if !p.handOffLocked(pc) {
+				p.idle = append(p.idle, pc)
+				p.stats.setIdleCount(int64(len(p.idle)))
+			}
 			p.mu.Unlock()
 		}
 	}
@@ -602,3 +980,67 @@ func (p *Pool) runHealthCheck() {
 	// Report stats to observer
 	p.notifyPoolStats()
 }
+
+// preRekey rekeys idle connections whose keys have used up
+// PreRekeyThreshold of their limits. They leave the idle list while the
+// exchange runs, so Acquire cannot hand them out halfway through it.
+func (p *Pool) preRekey() {
+	if p.config.PreRekeyThreshold == 0 {
+		return
+	}
+
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return
+	}
+	var due []*pooledConn
+	newIdle := make([]*pooledConn, 0, len(p.idle))
+	for _, pc := range p.idle {
+		session := pc.tunnel.Session()
+		if !session.IsRekeyInProgress() && session.nearRekey(p.config.PreRekeyThreshold) {
+			pc.inUse.Store(true)
+			due = append(due, pc)
+		} else {
+			newIdle = append(newIdle, pc)
+		}
+	}
+	p.idle = newIdle
+	p.stats.setIdleCount(int64(len(p.idle)))
+	p.mu.Unlock()
+
+	for _, pc := range due {
+		ctx, cancel := context.WithTimeout(p.healthCtx, p.config.RekeyTimeout)
+		err := pc.tunnel.rekeyIdle(ctx)
+		cancel()
+		if err != nil {
+			p.mu.Lock()
+			p.removeConnLocked(pc)
+			p.stats.recordConnectionRemoved()
+			p.mu.Unlock()
+			_ = pc.tunnel.Close()
+			p.notifyConnectionClosed("rekey_failed")
+			continue
+		}
+		p.stats.recordRekey()
+		p.returnIdle(pc)
+	}
+}
+
+// returnIdle puts a connection the pool took out of the idle list back,
+// handing it to a waiter if there is one.
+func (p *Pool) returnIdle(pc *pooledConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.closed {
+		// Close has already closed or is closing the connection
+		return
+	}
+	if p.handOffLocked(pc) {
+		return
+	}
+	pc.inUse.Store(false)
+	p.idle = append(p.idle, pc)
+	p.stats.setIdleCount(int64(len(p.idle)))
+}
@@ -0,0 +1,176 @@
+package tunnel
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// BalancePolicy selects which backend address a pool dials next.
+type BalancePolicy int
+
+const (
+	// BalanceRoundRobin cycles through backend addresses in order.
+	BalanceRoundRobin BalancePolicy = iota
+
+	// BalanceLeastConns dials the backend with the fewest open connections.
+	BalanceLeastConns
+)
+
+// String returns the policy name.
+func (b BalancePolicy) String() string {
+	switch b {
+	case BalanceRoundRobin:
+		return "round-robin"
+	case BalanceLeastConns:
+		return "least-conns"
+	default:
+		return "unknown"
+	}
+}
+
+// poolBackend tracks connection counts and health for one pool address.
+type poolBackend struct {
+	address string
+
+	active       atomic.Int64  // Open connections to this backend
+	dialsTotal   atomic.Uint64 // Dial attempts, including handshakes
+	dialFailures atomic.Uint64 // Failed dial attempts
+
+	mu                  sync.Mutex
+	consecutiveFailures int
+	quarantinedUntil    time.Time
+}
+
+// newPoolBackend creates a backend for the given address.
+func newPoolBackend(address string) *poolBackend {
+	return &poolBackend{address: address}
+}
+
+// available reports whether the backend is outside its quarantine period.
+func (b *poolBackend) available(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return !now.Before(b.quarantinedUntil)
+}
+
+// quarantineEnd returns when the current quarantine expires.
+func (b *poolBackend) quarantineEnd() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.quarantinedUntil
+}
+
+// recordSuccess clears the failure streak after a successful dial.
+func (b *poolBackend) recordSuccess() {
+	b.dialsTotal.Add(1)
+	b.mu.Lock()
+	b.consecutiveFailures = 0
+	b.quarantinedUntil = time.Time{}
+	b.mu.Unlock()
+}
+
+// recordFailure counts a failed dial and quarantines the backend once
+// threshold consecutive failures are reached. The quarantine doubles with
+// every further failure, up to maxBackoff.
+func (b *poolBackend) recordFailure(threshold int, backoff, maxBackoff time.Duration) {
+	b.dialsTotal.Add(1)
+	b.dialFailures.Add(1)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.consecutiveFailures++
+	if b.consecutiveFailures < threshold {
+		return
+	}
+
+	for i := threshold; i < b.consecutiveFailures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	b.quarantinedUntil = time.Now().Add(backoff)
+}
+
+// snapshot returns the backend's current statistics.
+func (b *poolBackend) snapshot(now time.Time) BackendStatsSnapshot {
+	b.mu.Lock()
+	quarantinedUntil := b.quarantinedUntil
+	failures := b.consecutiveFailures
+	b.mu.Unlock()
+
+	snap := BackendStatsSnapshot{
+		Address:             b.address,
+		Connections:         b.active.Load(),
+		DialsTotal:          b.dialsTotal.Load(),
+		DialFailures:        b.dialFailures.Load(),
+		ConsecutiveFailures: failures,
+	}
+	if now.Before(quarantinedUntil) {
+		snap.Quarantined = true
+		snap.QuarantinedUntil = quarantinedUntil
+	}
+	return snap
+}
+
+// BackendStatsSnapshot is a snapshot of statistics for one pool address.
+type BackendStatsSnapshot struct {
+	// Address is the backend's network address
+	Address string
+
+	// Connections is the number of open connections to this backend
+	Connections int64
+
+	// DialsTotal and DialFailures count dial and handshake attempts
+	DialsTotal   uint64
+	DialFailures uint64
+
+	// ConsecutiveFailures is the current run of failed dials
+	ConsecutiveFailures int
+
+	// Quarantined is true while the backend is skipped after repeated failures
+	Quarantined      bool
+	QuarantinedUntil time.Time
+}
+
+// backendOrderLocked returns the backends in the order they should be tried.
+// Backends outside quarantine come first, ordered by the balance policy;
+// quarantined backends follow, soonest-expiring first, so that a pool whose
+// backends are all failing still retries them. Must hold p.mu.
+func (p *Pool) backendOrderLocked() []*poolBackend {
+	now := time.Now()
+	n := len(p.backends)
+	start := p.nextBackend % n
+	p.nextBackend++
+
+	healthy := make([]*poolBackend, 0, n)
+	var quarantined []*poolBackend
+	for i := 0; i < n; i++ {
+		b := p.backends[(start+i)%n]
+		if b.available(now) {
+			healthy = append(healthy, b)
+		} else {
+			quarantined = append(quarantined, b)
+		}
+	}
+
+	if p.config.BalancePolicy == BalanceLeastConns {
+		// Stable sort keeps round-robin order among ties
+		active := make(map[*poolBackend]int64, len(healthy))
+		for _, b := range healthy {
+			active[b] = b.active.Load()
+		}
+		sort.SliceStable(healthy, func(i, j int) bool {
+			return active[healthy[i]] < active[healthy[j]]
+		})
+	}
+
+	sort.SliceStable(quarantined, func(i, j int) bool {
+		return quarantined[i].quarantineEnd().Before(quarantined[j].quarantineEnd())
+	})
+
+	return append(healthy, quarantined...)
+}
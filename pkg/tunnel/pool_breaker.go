@@ -0,0 +1,174 @@
+package tunnel
+
+import (
+	"sync"
+	"time"
+)
+
+// CircuitState is the state of a pool's circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed allows new connections to be dialed.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen fails new dials fast until the cooldown elapses.
+	CircuitOpen
+
+	// CircuitHalfOpen allows a single probe dial to test recovery.
+	CircuitHalfOpen
+)
+
+// String returns the state name.
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// circuitBreaker stops dialing after repeated connection failures.
+//
+// It opens after threshold consecutive failures within window, rejects
+// dials for cooldown, then lets one probe through. A successful probe
+// closes the circuit; a failed probe reopens it.
+type circuitBreaker struct {
+	threshold int
+	window    time.Duration
+	cooldown  time.Duration
+	onChange  func(CircuitState)
+
+	mu           sync.Mutex
+	state        CircuitState
+	failures     int
+	firstFailure time.Time
+	openedAt     time.Time
+	probing      bool
+}
+
+// newCircuitBreaker creates a breaker, or returns nil if threshold is 0.
+func newCircuitBreaker(threshold int, window, cooldown time.Duration, onChange func(CircuitState)) *circuitBreaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &circuitBreaker{
+		threshold: threshold,
+		window:    window,
+		cooldown:  cooldown,
+		onChange:  onChange,
+	}
+}
+
+// allow reports whether a dial may proceed. When the cooldown has elapsed,
+// the first caller becomes the half-open probe.
+func (b *circuitBreaker) allow() bool {
+	if b == nil {
+		return true
+	}
+
+	b.mu.Lock()
+	switch b.state {
+	case CircuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			b.mu.Unlock()
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		b.mu.Unlock()
+		b.notify(CircuitHalfOpen)
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			b.mu.Unlock()
+			return false
+		}
+		b.probing = true
+		b.mu.Unlock()
+		return true
+	default:
+		b.mu.Unlock()
+		return true
+	}
+}
+
+// success records a successful dial, closing the circuit.
+func (b *circuitBreaker) success() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	changed := b.state != CircuitClosed
+	b.state = CircuitClosed
+	b.failures = 0
+	b.probing = false
+	b.mu.Unlock()
+
+	if changed {
+		b.notify(CircuitClosed)
+	}
+}
+
+// failure records a failed dial, opening the circuit once the threshold is
+// reached or when a half-open probe fails.
+func (b *circuitBreaker) failure() {
+	if b == nil {
+		return
+	}
+
+	now := time.Now()
+	b.mu.Lock()
+	switch b.state {
+	case CircuitHalfOpen:
+		b.open(now)
+	case CircuitClosed:
+		if b.failures == 0 || (b.window > 0 && now.Sub(b.firstFailure) > b.window) {
+			b.failures = 0
+			b.firstFailure = now
+		}
+		b.failures++
+		if b.failures < b.threshold {
+			b.mu.Unlock()
+			return
+		}
+		b.open(now)
+	default:
+		b.mu.Unlock()
+		return
+	}
+	b.mu.Unlock()
+
+	b.notify(CircuitOpen)
+}
+
+// open moves the breaker to the open state. Must hold b.mu.
+func (b *circuitBreaker) open(now time.Time) {
+	b.state = CircuitOpen
+	b.openedAt = now
+	b.failures = 0
+	b.probing = false
+}
+
+// currentState returns the current breaker state.
+func (b *circuitBreaker) currentState() CircuitState {
+	if b == nil {
+		return CircuitClosed
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state
+}
+
+// notify reports a state change outside the lock.
+func (b *circuitBreaker) notify(state CircuitState) {
+	if b.onChange != nil {
+		b.onChange(state)
+	}
+}
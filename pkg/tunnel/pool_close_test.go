@@ -0,0 +1,126 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// stalledConn is a connection whose writes block, ignoring deadlines and
+// Close, until the test ends.
+type stalledConn struct {
+	net.Conn
+	release chan struct{}
+}
+
+func (c *stalledConn) Write([]byte) (int, error) {
+	<-c.release
+	return 0, net.ErrClosed
+}
+
+// newClosingPool returns a started pool holding a connection that closes
+// normally and one whose close-notify write stalls.
+func newClosingPool(t *testing.T, closeTimeout time.Duration) *Pool {
+	t.Helper()
+
+	config := DefaultPoolConfig()
+	config.MinConns = 0
+	config.HealthCheckInterval = time.Hour
+	config.CloseTimeout = closeTimeout
+	p, err := NewPool("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+
+	healthy, peer := newPipeTransports(t)
+	go func() { _, _ = io.Copy(io.Discard, peer.conn) }()
+
+	stalled, _ := newPipeTransports(t)
+	release := make(chan struct{})
+	t.Cleanup(func() { close(release) })
+	stalled.conn = &stalledConn{Conn: stalled.conn, release: release}
+
+	for _, transport := range []*Transport{healthy, stalled} {
+		pc := newPooledConn(&Tunnel{Transport: transport}, p, p.backends[0])
+		p.conns = append(p.conns, pc)
+		p.idle = append(p.idle, pc)
+	}
+	return p
+}
+
+func TestPoolCloseContextDeadline(t *testing.T) {
+	p := newClosingPool(t, time.Minute)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := p.CloseContext(ctx)
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CloseContext took %v, want it to return at the 100ms deadline", elapsed)
+	}
+
+	var closeErr *PoolCloseError
+	if !errors.As(err, &closeErr) || closeErr.Abandoned != 1 {
+		t.Fatalf("expected PoolCloseError with 1 abandoned connection, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected context.DeadlineExceeded, got %v", err)
+	}
+
+	if _, err := p.Acquire(context.Background()); !errors.Is(err, qerrors.ErrPoolClosed) {
+		t.Errorf("Acquire after close: expected ErrPoolClosed, got %v", err)
+	}
+	if err := p.CloseContext(context.Background()); err != nil {
+		t.Errorf("second CloseContext: expected nil, got %v", err)
+	}
+}
+
+func TestPoolCloseContextConnTimeout(t *testing.T) {
+	p := newClosingPool(t, 100*time.Millisecond)
+
+	start := time.Now()
+	err := p.CloseContext(context.Background())
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("CloseContext took %v, want it to abandon the stalled connection after 100ms", elapsed)
+	}
+
+	var closeErr *PoolCloseError
+	if !errors.As(err, &closeErr) || closeErr.Abandoned != 1 {
+		t.Fatalf("expected PoolCloseError with 1 abandoned connection, got %v", err)
+	}
+	if !errors.Is(err, qerrors.ErrPoolCloseTimeout) {
+		t.Errorf("expected ErrPoolCloseTimeout, got %v", err)
+	}
+}
+
+func TestPoolCloseContextWaitsForHealthChecker(t *testing.T) {
+	p := newClosingPool(t, time.Minute)
+
+	// A background task that takes a while to notice the cancellation
+	p.healthCtx, p.healthCancel = context.WithCancel(context.Background())
+	var stopped atomic.Bool
+	p.healthWg.Add(1)
+	go func() {
+		defer p.healthWg.Done()
+		<-p.healthCtx.Done()
+		time.Sleep(50 * time.Millisecond)
+		stopped.Store(true)
+	}()
+
+	// Even with ctx already expired, CloseContext returns only once the
+	// background tasks have stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := p.CloseContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if !stopped.Load() {
+		t.Error("CloseContext returned before the health checker stopped")
+	}
+}
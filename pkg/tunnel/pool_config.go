@@ -28,12 +28,34 @@ type PoolConfig struct {
 	// Default: 30 minutes
 	MaxLifetime time.Duration
 
+	// MaxConnUses retires a connection after it has been acquired this many
+	// times, so long-lived connections do not keep approaching rekey limits.
+	// Retired connections are closed on release instead of returning to idle.
+	// 0 disables the limit.
+	MaxConnUses int
+
 	// HealthCheckInterval is the interval between health checks.
 	// Health checks verify pooled connections are still valid.
 	// 0 disables periodic health checks (on-acquire checks still run).
 	// Default: 30 seconds
 	HealthCheckInterval time.Duration
 
+	// PreRekeyThreshold makes the health checker rekey idle connections
+	// whose keys have used up this fraction of their session's rekey policy
+	// limits, so that acquired connections do not need a rekey round trip
+	// in the middle of a request. For example, 0.9 rekeys a connection once
+	// 90% of its key lifetime, byte or packet budget is spent. Connections
+	// are unavailable to Acquire while they are rekeyed, and are closed if
+	// the rekey fails. The peer must be receiving to answer the rekey.
+	// 0 disables proactive rekeying; otherwise it must be at most 1.
+	PreRekeyThreshold float64
+
+	// IdleReaperInterval is the interval between sweeps that close idle
+	// connections past IdleTimeout or MaxLifetime. The sweep is cheaper than
+	// a health check: it does not inspect sessions or replenish MinConns.
+	// Default: 1 minute
+	IdleReaperInterval time.Duration
+
 	// WaitTimeout is how long Acquire waits for a connection when pool is exhausted.
 	// 0 means return immediately with ErrPoolExhausted.
 	// Default: 30 seconds
@@ -43,6 +65,53 @@ type PoolConfig struct {
 	// Default: 10 seconds
 	DialTimeout time.Duration
 
+	// RekeyTimeout bounds how long a rekey started by the pool, or by
+	// PoolConn.Rekey and AcquireFresh, waits for the peer's response.
+	// Default: 5 seconds
+	RekeyTimeout time.Duration
+
+	// BalancePolicy selects how new connections are spread across addresses
+	// when the pool has more than one.
+	// Default: BalanceRoundRobin
+	BalancePolicy BalancePolicy
+
+	// QuarantineThreshold is the number of consecutive dial or handshake
+	// failures after which an address is skipped.
+	// Default: 3
+	QuarantineThreshold int
+
+	// QuarantineBackoff is how long an address is skipped once quarantined.
+	// It doubles with each further failure up to MaxQuarantineBackoff.
+	// Default: 1 second
+	QuarantineBackoff time.Duration
+
+	// MaxQuarantineBackoff caps the quarantine period.
+	// Default: 1 minute
+	MaxQuarantineBackoff time.Duration
+
+	// FailureThreshold opens the pool's circuit breaker after this many
+	// consecutive connection failures within FailureWindow. While open,
+	// Acquire fails fast with ErrPoolCircuitOpen instead of dialing.
+	// 0 disables the circuit breaker.
+	FailureThreshold int
+
+	// FailureWindow is the period within which consecutive failures count
+	// toward FailureThreshold.
+	// Default: 30 seconds (when FailureThreshold is set)
+	FailureWindow time.Duration
+
+	// CircuitCooldown is how long the circuit stays open before a single
+	// probe dial is allowed through.
+	// Default: 5 seconds (when FailureThreshold is set)
+	CircuitCooldown time.Duration
+
+	// CloseTimeout bounds how long Close and CloseContext wait for each
+	// connection to close gracefully, which includes writing the close
+	// notification to the peer. A connection still closing after it is
+	// abandoned: its underlying connection is closed without waiting.
+	// Default: 5 seconds
+	CloseTimeout time.Duration
+
 	// TransportConfig is the configuration for new tunnel connections.
 	TransportConfig TransportConfig
 
@@ -54,19 +123,28 @@ type PoolConfig struct {
 // DefaultPoolConfig returns a PoolConfig with sensible defaults.
 func DefaultPoolConfig() PoolConfig {
 	return PoolConfig{
-		MinConns:            1,
-		MaxConns:            10,
-		IdleTimeout:         5 * time.Minute,
-		MaxLifetime:         30 * time.Minute,
-		HealthCheckInterval: 30 * time.Second,
-		WaitTimeout:         30 * time.Second,
-		DialTimeout:         10 * time.Second,
-		TransportConfig:     DefaultTransportConfig(),
+		MinConns:             1,
+		MaxConns:             10,
+		IdleTimeout:          5 * time.Minute,
+		MaxLifetime:          30 * time.Minute,
+		HealthCheckInterval:  30 * time.Second,
+		IdleReaperInterval:   time.Minute,
+		WaitTimeout:          30 * time.Second,
+		DialTimeout:          10 * time.Second,
+		RekeyTimeout:         5 * time.Second,
+		QuarantineThreshold:  3,
+		QuarantineBackoff:    time.Second,
+		MaxQuarantineBackoff: time.Minute,
+		CloseTimeout:         5 * time.Second,
+		TransportConfig:      DefaultTransportConfig(),
 	}
 }
 
 // Validate checks the configuration for errors.
 func (c *PoolConfig) Validate() error {
+	if err := c.TransportConfig.Validate(); err != nil {
+		return err
+	}
 	if c.MinConns < 0 {
 		return errors.New("pool: MinConns cannot be negative")
 	}
@@ -82,15 +160,51 @@ func (c *PoolConfig) Validate() error {
 	if c.MaxLifetime < 0 {
 		return errors.New("pool: MaxLifetime cannot be negative")
 	}
+	if c.MaxConnUses < 0 {
+		return errors.New("pool: MaxConnUses cannot be negative")
+	}
 	if c.HealthCheckInterval < 0 {
 		return errors.New("pool: HealthCheckInterval cannot be negative")
 	}
+	if c.PreRekeyThreshold < 0 || c.PreRekeyThreshold > 1 {
+		return errors.New("pool: PreRekeyThreshold must be between 0 and 1")
+	}
+	if c.IdleReaperInterval < 0 {
+		return errors.New("pool: IdleReaperInterval cannot be negative")
+	}
 	if c.WaitTimeout < 0 {
 		return errors.New("pool: WaitTimeout cannot be negative")
 	}
 	if c.DialTimeout < 0 {
 		return errors.New("pool: DialTimeout cannot be negative")
 	}
+	if c.RekeyTimeout < 0 {
+		return errors.New("pool: RekeyTimeout cannot be negative")
+	}
+	if c.BalancePolicy != BalanceRoundRobin && c.BalancePolicy != BalanceLeastConns {
+		return errors.New("pool: unknown BalancePolicy")
+	}
+	if c.QuarantineThreshold < 0 {
+		return errors.New("pool: QuarantineThreshold cannot be negative")
+	}
+	if c.QuarantineBackoff < 0 {
+		return errors.New("pool: QuarantineBackoff cannot be negative")
+	}
+	if c.MaxQuarantineBackoff < 0 {
+		return errors.New("pool: MaxQuarantineBackoff cannot be negative")
+	}
+	if c.CloseTimeout < 0 {
+		return errors.New("pool: CloseTimeout cannot be negative")
+	}
+	if c.FailureThreshold < 0 {
+		return errors.New("pool: FailureThreshold cannot be negative")
+	}
+	if c.FailureWindow < 0 {
+		return errors.New("pool: FailureWindow cannot be negative")
+	}
+	if c.CircuitCooldown < 0 {
+		return errors.New("pool: CircuitCooldown cannot be negative")
+	}
 	return nil
 }
 
@@ -113,10 +227,36 @@ func (c *PoolConfig) applyDefaults() {
 	if c.HealthCheckInterval == 0 {
 		c.HealthCheckInterval = defaults.HealthCheckInterval
 	}
+	if c.IdleReaperInterval == 0 {
+		c.IdleReaperInterval = defaults.IdleReaperInterval
+	}
 	if c.WaitTimeout == 0 {
 		c.WaitTimeout = defaults.WaitTimeout
 	}
 	if c.DialTimeout == 0 {
 		c.DialTimeout = defaults.DialTimeout
 	}
+	if c.RekeyTimeout == 0 {
+		c.RekeyTimeout = defaults.RekeyTimeout
+	}
+	if c.QuarantineThreshold == 0 {
+		c.QuarantineThreshold = defaults.QuarantineThreshold
+	}
+	if c.QuarantineBackoff == 0 {
+		c.QuarantineBackoff = defaults.QuarantineBackoff
+	}
+	if c.MaxQuarantineBackoff == 0 {
+		c.MaxQuarantineBackoff = defaults.MaxQuarantineBackoff
+	}
+	if c.CloseTimeout == 0 {
+		c.CloseTimeout = defaults.CloseTimeout
+	}
+	if c.FailureThreshold > 0 {
+		if c.FailureWindow == 0 {
+			c.FailureWindow = 30 * time.Second
+		}
+		if c.CircuitCooldown == 0 {
+			c.CircuitCooldown = 5 * time.Second
+		}
+	}
 }
@@ -1,6 +1,7 @@
 package tunnel
 
 import (
+	"context"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -11,19 +12,22 @@ import (
 type pooledConn struct {
 	tunnel    *Tunnel
 	pool      *Pool
+	backend   *poolBackend
 	createdAt time.Time
 	lastUsed  time.Time
 	useMu     sync.Mutex // Protects lastUsed updates
 	inUse     atomic.Bool
 	unhealthy atomic.Bool
+	uses      atomic.Int64 // Number of times the connection has been acquired
 }
 
 // newPooledConn creates a new pooled connection wrapper.
-func newPooledConn(tunnel *Tunnel, pool *Pool) *pooledConn {
+func newPooledConn(tunnel *Tunnel, pool *Pool, backend *poolBackend) *pooledConn {
 	now := time.Now()
 	return &pooledConn{
 		tunnel:    tunnel,
 		pool:      pool,
+		backend:   backend,
 		createdAt: now,
 		lastUsed:  now,
 	}
@@ -100,6 +104,37 @@ func (c *PoolConn) SendPing() error {
 	return c.pc.tunnel.SendPing()
 }
 
+// Rekey replaces the connection's traffic keys with fresh ones and returns
+// once both peers have switched to them, for instance before a privileged
+// request that must not share keys with earlier traffic. It reads from the
+// tunnel until the peer answers, so it must not run concurrently with
+// Receive, and a data record arriving meanwhile fails it. The exchange is
+// bounded by PoolConfig.RekeyTimeout. On failure the connection is marked
+// unhealthy and should be closed.
+func (c *PoolConn) Rekey() error {
+	return c.rekey(context.Background())
+}
+
+// rekey rekeys the connection, bounded by ctx and the pool's RekeyTimeout.
+func (c *PoolConn) rekey(ctx context.Context) error {
+	if c.released.Load() {
+		return ErrConnReleased
+	}
+
+	pool := c.pc.pool
+	if pool.config.RekeyTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, pool.config.RekeyTimeout)
+		defer cancel()
+	}
+	if err := c.pc.tunnel.rekeyIdle(ctx); err != nil {
+		c.pc.unhealthy.Store(true)
+		return err
+	}
+	pool.stats.recordRekey()
+	return nil
+}
+
 // Release returns the connection to the pool for reuse.
 // The connection should be in a healthy state when released.
 // After calling Release, the PoolConn should not be used.
@@ -26,6 +26,10 @@ type PoolObserver interface {
 	// OnPoolStats is called periodically with current pool statistics.
 	// This can be used for monitoring and alerting.
 	OnPoolStats(stats PoolStatsSnapshot)
+
+	// OnCircuitStateChange is called when the pool's circuit breaker
+	// changes state.
+	OnCircuitStateChange(state CircuitState)
 }
 
 // NoOpPoolObserver is a no-op implementation of PoolObserver.
@@ -54,3 +58,6 @@ func (NoOpPoolObserver) OnHealthCheck(bool) {}
 
 // OnPoolStats implements PoolObserver.
 func (NoOpPoolObserver) OnPoolStats(PoolStatsSnapshot) {}
+
+// OnCircuitStateChange implements PoolObserver.
+func (NoOpPoolObserver) OnCircuitStateChange(CircuitState) {}
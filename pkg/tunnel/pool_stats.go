@@ -19,6 +19,8 @@ type PoolStats struct {
 	acquireTimeoutsTotal atomic.Uint64
 	connectionsCreated   atomic.Uint64
 	connectionsClosed    atomic.Uint64
+	connectionsRetired   atomic.Uint64
+	connectionsRekeyed   atomic.Uint64
 	healthChecksTotal    atomic.Uint64
 	healthChecksFailed   atomic.Uint64
 
@@ -85,7 +87,8 @@ func (s *PoolStats) recordConnectionCreated(dialDuration time.Duration) {
 	s.updatePeakConnections(total)
 }
 
-// recordConnectionClosed records a connection being closed.
+// recordConnectionClosed records a connection being closed that was never
+// added to the pool's connection list.
 func (s *PoolStats) recordConnectionClosed(wasIdle bool) {
 	s.connectionsClosed.Add(1)
 	s.connectionsTotal.Add(-1)
@@ -94,6 +97,22 @@ func (s *PoolStats) recordConnectionClosed(wasIdle bool) {
 	}
 }
 
+// recordConnectionRemoved records the close of a connection that
+// removeConnLocked already took out of the total and idle gauges.
+func (s *PoolStats) recordConnectionRemoved() {
+	s.connectionsClosed.Add(1)
+}
+
+// recordRetirement records a connection retired after reaching MaxConnUses.
+func (s *PoolStats) recordRetirement() {
+	s.connectionsRetired.Add(1)
+}
+
+// recordRekey records a connection rekeyed ahead of its limits or on demand.
+func (s *PoolStats) recordRekey() {
+	s.connectionsRekeyed.Add(1)
+}
+
 // recordHealthCheck records a health check result.
 func (s *PoolStats) recordHealthCheck(healthy bool) {
 	s.healthChecksTotal.Add(1)
@@ -169,6 +188,8 @@ type PoolStatsSnapshot struct {
 	AcquireTimeoutsTotal uint64
 	ConnectionsCreated   uint64
 	ConnectionsClosed    uint64
+	ConnectionsRetired   uint64
+	ConnectionsRekeyed   uint64 // Rekeyed by PreRekeyThreshold or PoolConn.Rekey
 	HealthChecksTotal    uint64
 	HealthChecksFailed   uint64
 
@@ -179,6 +200,10 @@ type PoolStatsSnapshot struct {
 	// Peak values
 	PeakConnections int64
 	PeakWaiting     int64
+
+	// Per-address statistics, in the order the addresses were configured.
+	// Only populated by Pool.Stats.
+	Backends []BackendStatsSnapshot
 }
 
 // Snapshot returns an immutable snapshot of current statistics.
@@ -213,6 +238,8 @@ func (s *PoolStats) Snapshot() PoolStatsSnapshot {
 		AcquireTimeoutsTotal: s.acquireTimeoutsTotal.Load(),
 		ConnectionsCreated:   s.connectionsCreated.Load(),
 		ConnectionsClosed:    s.connectionsClosed.Load(),
+		ConnectionsRetired:   s.connectionsRetired.Load(),
+		ConnectionsRekeyed:   s.connectionsRekeyed.Load(),
 		HealthChecksTotal:    s.healthChecksTotal.Load(),
 		HealthChecksFailed:   s.healthChecksFailed.Load(),
 		AvgAcquireWaitMs:     avgAcquireWait,
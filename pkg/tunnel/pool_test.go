@@ -2,6 +2,11 @@ package tunnel_test
 
 import (
 	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"slices"
 	"sync"
 	"sync/atomic"
 	"testing"
@@ -51,6 +56,32 @@ func TestPoolConfig(t *testing.T) {
 		}
 	})
 
+	t.Run("InvalidPreRekeyThreshold", func(t *testing.T) {
+		for _, threshold := range []float64{-0.5, 1.5} {
+			cfg := tunnel.DefaultPoolConfig()
+			cfg.PreRekeyThreshold = threshold
+			if err := cfg.Validate(); err == nil {
+				t.Errorf("Expected error for PreRekeyThreshold %v", threshold)
+			}
+		}
+	})
+
+	t.Run("InvalidRekeyTimeout", func(t *testing.T) {
+		cfg := tunnel.DefaultPoolConfig()
+		cfg.RekeyTimeout = -time.Second
+		if err := cfg.Validate(); err == nil {
+			t.Error("Expected error for negative RekeyTimeout")
+		}
+	})
+
+	t.Run("InvalidPSK", func(t *testing.T) {
+		cfg := tunnel.DefaultPoolConfig()
+		cfg.TransportConfig.PSK = make([]byte, 16)
+		if err := cfg.Validate(); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+			t.Errorf("Expected ErrInvalidKeySize for a short PSK, got %v", err)
+		}
+	})
+
 	t.Run("ZeroMaxAllowed", func(t *testing.T) {
 		cfg := tunnel.DefaultPoolConfig()
 		cfg.MaxConns = 0 // Unlimited
@@ -909,6 +940,295 @@ func TestPoolContextCancellation(t *testing.T) {
 	_ = conn.Release()
 }
 
+// TestPoolStartCancellation cancels Start while its pre-warm dials are
+// stalled in the handshake.
+func TestPoolStartCancellation(t *testing.T) {
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	// Complete two handshakes; the remaining dials stall waiting for a
+	// ServerHello
+	const completed = 2
+	accepted := make(chan *tunnel.Tunnel, completed)
+	go func() {
+		for range completed {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.MinConns = 20
+	cfg.MaxConns = 20
+	cfg.HealthCheckInterval = 0
+
+	pool, err := tunnel.NewPool("tcp", listener.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	startErr := make(chan error, 1)
+	go func() {
+		startErr <- pool.Start(ctx)
+	}()
+
+	servers := make([]*tunnel.Tunnel, 0, completed)
+	for range completed {
+		select {
+		case conn := <-accepted:
+			servers = append(servers, conn)
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for pre-warm handshakes")
+		}
+	}
+	cancel()
+
+	select {
+	case err := <-startErr:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Start did not return promptly after cancellation")
+	}
+
+	stats := pool.Stats()
+	if stats.ConnectionsTotal != 0 || stats.ConnectionsIdle != 0 {
+		t.Errorf("expected empty pool, got %d total, %d idle", stats.ConnectionsTotal, stats.ConnectionsIdle)
+	}
+	if open := stats.Backends[0].Connections; open != 0 {
+		t.Errorf("expected no open backend connections, got %d", open)
+	}
+
+	// The connections that completed their handshake were closed
+	for _, server := range servers {
+		server.SetReadTimeout(2 * time.Second)
+		if _, err := server.Receive(); err == nil || errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Errorf("expected pre-warmed connection to be closed, got %v", err)
+		}
+		_ = server.Close()
+	}
+}
+
+// TestPoolAcquireWithPriority tests that released connections go to
+// higher-priority waiters first, and to equal-priority waiters in order.
+func TestPoolAcquireWithPriority(t *testing.T) {
+	addr, cleanup := startEchoServer(t)
+	defer cleanup()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.MinConns = 0
+	cfg.MaxConns = 1
+	cfg.WaitTimeout = 10 * time.Second
+	cfg.HealthCheckInterval = 0
+
+	pool, err := tunnel.NewPool("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	ctx := context.Background()
+	held, err := pool.Acquire(ctx)
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+
+	type result struct {
+		name string
+		conn *tunnel.PoolConn
+	}
+	served := make(chan result, 3)
+	enqueue := func(name string, priority int) {
+		waiting := pool.Stats().WaitingCount
+		go func() {
+			conn, err := pool.AcquireWithPriority(ctx, priority)
+			if err != nil {
+				t.Errorf("%s: AcquireWithPriority failed: %v", name, err)
+				return
+			}
+			served <- result{name, conn}
+		}()
+		deadline := time.Now().Add(5 * time.Second)
+		for pool.Stats().WaitingCount == waiting {
+			if time.Now().After(deadline) {
+				t.Fatalf("%s waiter was not queued", name)
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	// Queued in this order, served high, then the two lows in arrival order
+	enqueue("low-1", 0)
+	enqueue("low-2", 0)
+	enqueue("high", 10)
+
+	mustRelease(t, held)
+	for _, want := range []string{"high", "low-1", "low-2"} {
+		select {
+		case got := <-served:
+			if got.name != want {
+				t.Fatalf("expected %s waiter to be served, got %s", want, got.name)
+			}
+			mustRelease(t, got.conn)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for %s waiter", want)
+		}
+	}
+}
+
+// TestPoolAcquireFairness tests that under contention no caller waits
+// much longer than the others: released connections go to queued waiters
+// before new callers can take them.
+func TestPoolAcquireFairness(t *testing.T) {
+	addr, cleanup := startEchoServer(t)
+	defer cleanup()
+
+	// Start with every connection open, so callers only wait for releases
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.MinConns = 2
+	cfg.MaxConns = 2
+	cfg.WaitTimeout = 30 * time.Second
+	cfg.HealthCheckInterval = 0
+
+	pool, err := tunnel.NewPool("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	const (
+		workers = 16
+		rounds  = 10
+		hold    = 2 * time.Millisecond
+	)
+	ctx := context.Background()
+	var (
+		mu    sync.Mutex
+		waits []time.Duration
+		wg    sync.WaitGroup
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range rounds {
+				start := time.Now()
+				conn, err := pool.Acquire(ctx)
+				if err != nil {
+					t.Errorf("Acquire failed: %v", err)
+					return
+				}
+				wait := time.Since(start)
+				time.Sleep(hold)
+				_ = conn.Release()
+
+				mu.Lock()
+				waits = append(waits, wait)
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if t.Failed() {
+		return
+	}
+
+	slices.Sort(waits)
+	var total time.Duration
+	for _, w := range waits {
+		total += w
+	}
+	mean := total / time.Duration(len(waits))
+	p99 := waits[len(waits)*99/100]
+	t.Logf("acquire wait: mean %v, p99 %v, max %v", mean, p99, waits[len(waits)-1])
+
+	// Served in arrival order, every caller waits for roughly the same
+	// number of holds; a starved caller would wait many times the mean
+	if p99 > 4*mean+10*hold {
+		t.Errorf("p99 acquire wait %v is unbounded relative to the mean %v", p99, mean)
+	}
+}
+
+// TestPoolIdleReaper tests that idle connections past IdleTimeout are
+// closed without waiting for a health check or an acquire.
+func TestPoolIdleReaper(t *testing.T) {
+	addr, cleanup := startEchoServer(t)
+	defer cleanup()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.MinConns = 1
+	cfg.MaxConns = 5
+	cfg.IdleTimeout = 100 * time.Millisecond
+	cfg.IdleReaperInterval = 20 * time.Millisecond
+	cfg.HealthCheckInterval = time.Hour // No health check during the test
+
+	pool, err := tunnel.NewPool("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	ctx := context.Background()
+	conns := make([]*tunnel.PoolConn, 3)
+	for i := range conns {
+		conns[i] = acquireAndVerify(ctx, t, pool, "reap me")
+	}
+	for _, conn := range conns {
+		mustRelease(t, conn)
+	}
+	if idle := pool.Stats().ConnectionsIdle; idle != 3 {
+		t.Fatalf("expected 3 idle connections, got %d", idle)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Stats().ConnectionsIdle != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected idle connections to be reaped, %d remain", pool.Stats().ConnectionsIdle)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if total := pool.Stats().ConnectionsTotal; total != 0 {
+		t.Errorf("expected no connections after reaping, got %d", total)
+	}
+	if closed := pool.Stats().ConnectionsClosed; closed != 3 {
+		t.Errorf("ConnectionsClosed = %d, want 3", closed)
+	}
+	if open := pool.Stats().Backends[0].Connections; open != 0 {
+		t.Errorf("expected reaped connections to be closed, %d open", open)
+	}
+
+	// A connection closed by its user is counted the same way
+	conn := acquireAndVerify(ctx, t, pool, "close me")
+	if err := conn.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	stats := pool.Stats()
+	if stats.ConnectionsTotal != 0 || stats.ConnectionsClosed != 4 {
+		t.Errorf("after Close: ConnectionsTotal = %d, ConnectionsClosed = %d, want 0 and 4",
+			stats.ConnectionsTotal, stats.ConnectionsClosed)
+	}
+}
+
 // TestPoolObserver tests observer notifications.
 func TestPoolObserver(t *testing.T) {
 	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
@@ -986,6 +1306,9 @@ type testPoolObserver struct {
 	connCreatedCount atomic.Int32
 	connClosedCount  atomic.Int32
 	healthCheckCount atomic.Int32
+
+	circuitMu     sync.Mutex
+	circuitStates []tunnel.CircuitState
 }
 
 func (o *testPoolObserver) OnAcquire(_ time.Duration, _ bool) {
@@ -1013,3 +1336,527 @@ func (o *testPoolObserver) OnHealthCheck(_ bool) {
 }
 
 func (o *testPoolObserver) OnPoolStats(_ tunnel.PoolStatsSnapshot) {}
+
+func (o *testPoolObserver) OnCircuitStateChange(state tunnel.CircuitState) {
+	o.circuitMu.Lock()
+	o.circuitStates = append(o.circuitStates, state)
+	o.circuitMu.Unlock()
+}
+
+func (o *testPoolObserver) circuitTransitions() []tunnel.CircuitState {
+	o.circuitMu.Lock()
+	defer o.circuitMu.Unlock()
+	return append([]tunnel.CircuitState(nil), o.circuitStates...)
+}
+
+// TestMultiPoolFailover tests that a pool spread over two backends keeps
+// serving from the survivor when one backend dies.
+func TestMultiPoolFailover(t *testing.T) {
+	listenerA, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listenerA.Close() }()
+	listenerB, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go runEchoServer(listenerA)
+	go runEchoServer(listenerB)
+
+	addrA, addrB := listenerA.Addr().String(), listenerB.Addr().String()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.HealthCheckInterval = 0
+	cfg.QuarantineThreshold = 1
+	cfg.QuarantineBackoff = time.Minute
+
+	pool, err := tunnel.NewMultiPool("tcp", []string{addrA, addrB}, cfg)
+	if err != nil {
+		t.Fatalf("NewMultiPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Pool.Start failed: %v", err)
+	}
+
+	ctx := context.Background()
+
+	// Round-robin spreads connections evenly across both backends
+	var held []*tunnel.PoolConn
+	for i := 0; i < 4; i++ {
+		held = append(held, acquireAndVerify(ctx, t, pool, "spread"))
+	}
+	stats := pool.Stats()
+	if len(stats.Backends) != 2 {
+		t.Fatalf("got %d backend stats, want 2", len(stats.Backends))
+	}
+	for _, b := range stats.Backends {
+		if b.Connections != 2 {
+			t.Errorf("backend %s has %d connections, want 2", b.Address, b.Connections)
+		}
+	}
+	for _, conn := range held {
+		_ = conn.Close()
+	}
+
+	// Kill backend B, closing its listener and every tunnel it accepted
+	drainCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_ = listenerB.Drain(drainCtx)
+
+	held = held[:0]
+	for i := 0; i < 6; i++ {
+		conn := acquireAndVerify(ctx, t, pool, "survivor")
+		if conn.RemoteAddr() != addrA {
+			t.Errorf("connection %d went to %s, want survivor %s", i, conn.RemoteAddr(), addrA)
+		}
+		held = append(held, conn)
+	}
+	for _, conn := range held {
+		mustRelease(t, conn)
+	}
+
+	stats = pool.Stats()
+	dead := stats.Backends[1]
+	if dead.Address != addrB {
+		t.Fatalf("backend order changed: got %s, want %s", dead.Address, addrB)
+	}
+	if !dead.Quarantined {
+		t.Error("dead backend should be quarantined")
+	}
+	if dead.DialFailures == 0 {
+		t.Error("dead backend should record dial failures")
+	}
+	if stats.Backends[0].Quarantined {
+		t.Error("surviving backend should not be quarantined")
+	}
+}
+
+// TestMultiPoolLeastConns tests that least-connections balancing fills the
+// emptier backend first.
+func TestMultiPoolLeastConns(t *testing.T) {
+	addrA, cleanupA := startEchoServer(t)
+	defer cleanupA()
+	addrB, cleanupB := startEchoServer(t)
+	defer cleanupB()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.HealthCheckInterval = 0
+	cfg.BalancePolicy = tunnel.BalanceLeastConns
+
+	pool, err := tunnel.NewMultiPool("tcp", []string{addrA, addrB}, cfg)
+	if err != nil {
+		t.Fatalf("NewMultiPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+
+	ctx := context.Background()
+	var held []*tunnel.PoolConn
+	for i := 0; i < 6; i++ {
+		held = append(held, acquireAndVerify(ctx, t, pool, "balance"))
+	}
+	defer func() {
+		for _, conn := range held {
+			mustRelease(t, conn)
+		}
+	}()
+
+	for _, b := range pool.Stats().Backends {
+		if b.Connections != 3 {
+			t.Errorf("backend %s has %d connections, want 3", b.Address, b.Connections)
+		}
+	}
+}
+
+// TestNewMultiPoolValidation tests address and policy validation.
+func TestNewMultiPoolValidation(t *testing.T) {
+	if _, err := tunnel.NewMultiPool("tcp", nil, tunnel.DefaultPoolConfig()); err == nil {
+		t.Error("expected error for empty address list")
+	}
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.BalancePolicy = tunnel.BalancePolicy(99)
+	if _, err := tunnel.NewMultiPool("tcp", []string{"127.0.0.1:1"}, cfg); err == nil {
+		t.Error("expected error for unknown balance policy")
+	}
+}
+
+// startRawEchoServer starts a plain TCP echo server that never completes a
+// tunnel handshake. It returns the listener so tests can stop it.
+func startRawEchoServer(t *testing.T, addr string) net.Listener {
+	t.Helper()
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				_, _ = io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return ln
+}
+
+// newBreakerTestPool creates a pool with a circuit breaker that trips after
+// two failures.
+func newBreakerTestPool(t *testing.T, addr string, observer tunnel.PoolObserver) *tunnel.Pool {
+	t.Helper()
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.HealthCheckInterval = 0
+	cfg.DialTimeout = time.Second
+	cfg.FailureThreshold = 2
+	cfg.CircuitCooldown = 200 * time.Millisecond
+	cfg.Observer = observer
+
+	pool, err := tunnel.NewPool("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	return pool
+}
+
+// TestPoolCircuitBreaker tests fast-fail while the backend cannot complete
+// handshakes and recovery once a real listener is available.
+func TestPoolCircuitBreaker(t *testing.T) {
+	raw := startRawEchoServer(t, "127.0.0.1:0")
+	addr := raw.Addr().String()
+
+	observer := &testPoolObserver{}
+	pool := newBreakerTestPool(t, addr, observer)
+	defer func() { _ = pool.Close() }()
+
+	ctx := context.Background()
+
+	// Handshakes fail until the threshold trips the breaker
+	for i := 0; i < 2; i++ {
+		if _, err := pool.Acquire(ctx); err == nil || errors.Is(err, qerrors.ErrPoolCircuitOpen) {
+			t.Fatalf("attempt %d: expected handshake failure, got %v", i, err)
+		}
+	}
+	if pool.CircuitState() != tunnel.CircuitOpen {
+		t.Fatalf("circuit state = %v, want open", pool.CircuitState())
+	}
+
+	// Open circuit fails fast without dialing
+	start := time.Now()
+	if _, err := pool.Acquire(ctx); !errors.Is(err, qerrors.ErrPoolCircuitOpen) {
+		t.Fatalf("expected ErrPoolCircuitOpen, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 50*time.Millisecond {
+		t.Errorf("open circuit took %v to fail", elapsed)
+	}
+
+	// Replace the raw server with a real tunnel listener on the same address
+	_ = raw.Close()
+	listener, err := tunnel.Listen("tcp", addr)
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	go runEchoServer(listener)
+
+	time.Sleep(250 * time.Millisecond)
+
+	conn := acquireAndVerify(ctx, t, pool, "recovered")
+	mustRelease(t, conn)
+
+	if pool.CircuitState() != tunnel.CircuitClosed {
+		t.Errorf("circuit state = %v, want closed", pool.CircuitState())
+	}
+
+	want := []tunnel.CircuitState{tunnel.CircuitOpen, tunnel.CircuitHalfOpen, tunnel.CircuitClosed}
+	got := observer.circuitTransitions()
+	if len(got) != len(want) {
+		t.Fatalf("circuit transitions = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("transition %d = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+// TestPoolCircuitBreakerFailedProbe tests that a failed half-open probe
+// reopens the circuit.
+func TestPoolCircuitBreakerFailedProbe(t *testing.T) {
+	raw := startRawEchoServer(t, "127.0.0.1:0")
+	defer func() { _ = raw.Close() }()
+
+	pool := newBreakerTestPool(t, raw.Addr().String(), nil)
+	defer func() { _ = pool.Close() }()
+
+	ctx := context.Background()
+	for i := 0; i < 2; i++ {
+		_, _ = pool.Acquire(ctx)
+	}
+
+	time.Sleep(250 * time.Millisecond)
+
+	// The probe is allowed through and fails the handshake
+	if _, err := pool.Acquire(ctx); err == nil || errors.Is(err, qerrors.ErrPoolCircuitOpen) {
+		t.Fatalf("expected probe handshake failure, got %v", err)
+	}
+	if pool.CircuitState() != tunnel.CircuitOpen {
+		t.Fatalf("circuit state = %v, want open after failed probe", pool.CircuitState())
+	}
+	if _, err := pool.Acquire(ctx); !errors.Is(err, qerrors.ErrPoolCircuitOpen) {
+		t.Errorf("expected ErrPoolCircuitOpen after failed probe, got %v", err)
+	}
+}
+
+// TestPoolCircuitBreakerDisabled tests that the breaker never opens by default.
+func TestPoolCircuitBreakerDisabled(t *testing.T) {
+	raw := startRawEchoServer(t, "127.0.0.1:0")
+	defer func() { _ = raw.Close() }()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.HealthCheckInterval = 0
+	pool, err := tunnel.NewPool("tcp", raw.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+
+	for i := 0; i < 5; i++ {
+		if _, err := pool.Acquire(context.Background()); errors.Is(err, qerrors.ErrPoolCircuitOpen) {
+			t.Fatal("circuit opened although FailureThreshold is 0")
+		}
+	}
+}
+
+// TestPoolMaxConnUses tests that connections are retired after MaxConnUses acquires.
+func TestPoolMaxConnUses(t *testing.T) {
+	addr, cleanup := startEchoServer(t)
+	defer cleanup()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.MinConns = 1
+	cfg.HealthCheckInterval = 0
+	cfg.MaxConnUses = 3
+
+	pool, err := tunnel.NewPool("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Pool.Start failed: %v", err)
+	}
+
+	ctx := context.Background()
+	var first *tunnel.Session
+	for i := 0; i < 3; i++ {
+		conn := acquireAndVerify(ctx, t, pool, "reuse")
+		if i == 0 {
+			first = conn.Session()
+		} else if conn.Session() != first {
+			t.Errorf("acquire %d got a different connection before reaching MaxConnUses", i+1)
+		}
+		mustRelease(t, conn)
+	}
+
+	stats := pool.Stats()
+	if stats.ConnectionsCreated != 1 {
+		t.Errorf("ConnectionsCreated = %d after 3 uses, want 1", stats.ConnectionsCreated)
+	}
+	if stats.ConnectionsRetired != 1 {
+		t.Errorf("ConnectionsRetired = %d, want 1", stats.ConnectionsRetired)
+	}
+	if pool.IdleCount() != 0 {
+		t.Errorf("IdleCount = %d, want 0 after retirement", pool.IdleCount())
+	}
+
+	conn := acquireAndVerify(ctx, t, pool, "fresh")
+	defer mustRelease(t, conn)
+	if conn.Session() == first {
+		t.Error("fourth acquire reused the retired connection")
+	}
+	if created := pool.Stats().ConnectionsCreated; created != 2 {
+		t.Errorf("ConnectionsCreated = %d after fourth acquire, want 2", created)
+	}
+}
+
+func TestPoolPreRekey(t *testing.T) {
+	addr, cleanup := startEchoServer(t)
+	defer cleanup()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.MinConns = 1
+	cfg.MaxConns = 1
+	cfg.HealthCheckInterval = 20 * time.Millisecond
+	cfg.PreRekeyThreshold = 0.9
+
+	pool, err := tunnel.NewPool("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+	if err := pool.Start(context.Background()); err != nil {
+		t.Fatalf("Pool.Start failed: %v", err)
+	}
+
+	// Age the keys to 95% of the default one-hour lifetime, past the
+	// threshold but short of needing a rekey
+	ctx := context.Background()
+	conn := acquireAndVerify(ctx, t, pool, "before")
+	session := conn.Session()
+	session.EstablishedAt = time.Now().Add(-57 * time.Minute)
+	if session.NeedsRekey() {
+		t.Fatal("aged keys already need a rekey")
+	}
+	mustRelease(t, conn)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for pool.Stats().ConnectionsRekeyed == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("idle connection was not rekeyed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// The same connection comes back with fresh keys, and the echo shows
+	// both peers switched to them
+	conn = acquireAndVerify(ctx, t, pool, "after")
+	defer mustRelease(t, conn)
+	if conn.Session() != session {
+		t.Fatal("Acquire returned a different connection")
+	}
+	if age := time.Since(session.EstablishedAt); age > time.Minute {
+		t.Errorf("keys are %v old after the rekey", age)
+	}
+	if closed := pool.Stats().ConnectionsClosed; closed != 0 {
+		t.Errorf("ConnectionsClosed = %d, want 0", closed)
+	}
+}
+
+func TestPoolConnRekey(t *testing.T) {
+	addr, cleanup := startEchoServer(t)
+	defer cleanup()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.MaxConns = 1
+
+	pool, err := tunnel.NewPool("tcp", addr, cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+
+	// Use the connection, then acquire it again
+	ctx := context.Background()
+	mustRelease(t, acquireAndVerify(ctx, t, pool, "before"))
+	conn := acquireAndVerify(ctx, t, pool, "reused")
+	session := conn.Session()
+	session.EstablishedAt = time.Now().Add(-30 * time.Minute)
+
+	if err := conn.Rekey(); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	if rekeyed := pool.Stats().ConnectionsRekeyed; rekeyed != 1 {
+		t.Errorf("ConnectionsRekeyed = %d, want 1", rekeyed)
+	}
+	if age := time.Since(session.EstablishedAt); age > time.Minute {
+		t.Errorf("keys are %v old after the rekey", age)
+	}
+
+	// The echo shows both peers switched to the new keys
+	if err := conn.Send([]byte("after")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if response, err := conn.Receive(); err != nil || string(response) != "after" {
+		t.Fatalf("Receive = %q, %v, want %q", response, err, "after")
+	}
+	mustRelease(t, conn)
+
+	// AcquireFresh hands out the same connection rekeyed again
+	conn, err = pool.AcquireFresh(ctx)
+	if err != nil {
+		t.Fatalf("AcquireFresh failed: %v", err)
+	}
+	defer mustRelease(t, conn)
+	if conn.Session() != session {
+		t.Fatal("AcquireFresh returned a different connection")
+	}
+	if rekeyed := pool.Stats().ConnectionsRekeyed; rekeyed != 2 {
+		t.Errorf("ConnectionsRekeyed = %d, want 2", rekeyed)
+	}
+	if err := conn.Send([]byte("fresh")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if response, err := conn.Receive(); err != nil || string(response) != "fresh" {
+		t.Fatalf("Receive = %q, %v, want %q", response, err, "fresh")
+	}
+}
+
+func TestPoolConnRekeyTimeout(t *testing.T) {
+	// The peer completes handshakes but never reads, so rekeys go unanswered
+	listener, err := tunnel.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	go func() {
+		var conns []*tunnel.Tunnel
+		defer func() {
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+		}()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			conns = append(conns, conn)
+		}
+	}()
+
+	cfg := tunnel.DefaultPoolConfig()
+	cfg.DialTimeout = time.Minute
+	cfg.RekeyTimeout = 200 * time.Millisecond
+	pool, err := tunnel.NewPool("tcp", listener.Addr().String(), cfg)
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+
+	conn, err := pool.Acquire(context.Background())
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	// The rekey gives up after RekeyTimeout, not DialTimeout
+	start := time.Now()
+	if err := conn.Rekey(); err == nil {
+		t.Fatal("Rekey succeeded without a response")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Rekey took %v to time out", elapsed)
+	}
+}
+
+func TestPoolConnRekeyReleased(t *testing.T) {
+	addr, cleanup := startEchoServer(t)
+	defer cleanup()
+
+	pool, err := tunnel.NewPool("tcp", addr, tunnel.DefaultPoolConfig())
+	if err != nil {
+		t.Fatalf("NewPool failed: %v", err)
+	}
+	defer func() { _ = pool.Close() }()
+
+	conn := acquireAndVerify(context.Background(), t, pool, "hello")
+	mustRelease(t, conn)
+	if err := conn.Rekey(); !errors.Is(err, tunnel.ErrConnReleased) {
+		t.Errorf("Rekey after Release = %v, want ErrConnReleased", err)
+	}
+}
@@ -0,0 +1,95 @@
+package tunnel
+
+import "container/heap"
+
+// poolWaiter is an Acquire call waiting for a released connection.
+type poolWaiter struct {
+	ch       chan *pooledConn
+	priority int
+	seq      uint64 // Arrival order, for FIFO within a priority
+	index    int    // Position in the heap, or -1 once removed
+}
+
+// waitQueue orders waiters by descending priority, then by arrival.
+// It is not safe for concurrent use; the pool guards it with its mutex.
+type waitQueue struct {
+	items   []*poolWaiter
+	nextSeq uint64
+}
+
+// push queues a waiter with the given priority.
+func (q *waitQueue) push(priority int) *poolWaiter {
+	w := &poolWaiter{
+		ch:       make(chan *pooledConn, 1),
+		priority: priority,
+		seq:      q.nextSeq,
+	}
+	q.nextSeq++
+	heap.Push((*waitHeap)(q), w)
+	return w
+}
+
+// pop removes and returns the waiter to serve next, or nil if none.
+func (q *waitQueue) pop() *poolWaiter {
+	if len(q.items) == 0 {
+		return nil
+	}
+	return heap.Pop((*waitHeap)(q)).(*poolWaiter)
+}
+
+// remove removes w if it is still queued, reporting whether it was.
+func (q *waitQueue) remove(w *poolWaiter) bool {
+	if w.index < 0 || w.index >= len(q.items) || q.items[w.index] != w {
+		return false
+	}
+	heap.Remove((*waitHeap)(q), w.index)
+	return true
+}
+
+// len returns the number of queued waiters.
+func (q *waitQueue) len() int {
+	return len(q.items)
+}
+
+// closeAll wakes every waiter with a nil connection and empties the queue.
+func (q *waitQueue) closeAll() {
+	for _, w := range q.items {
+		w.index = -1
+		close(w.ch)
+	}
+	q.items = nil
+}
+
+// waitHeap implements heap.Interface for waitQueue.
+type waitHeap waitQueue
+
+func (h *waitHeap) Len() int { return len(h.items) }
+
+func (h *waitHeap) Less(i, j int) bool {
+	a, b := h.items[i], h.items[j]
+	if a.priority != b.priority {
+		return a.priority > b.priority
+	}
+	return a.seq < b.seq
+}
+
+func (h *waitHeap) Swap(i, j int) {
+	h.items[i], h.items[j] = h.items[j], h.items[i]
+	h.items[i].index = i
+	h.items[j].index = j
+}
+
+func (h *waitHeap) Push(x any) {
+	w := x.(*poolWaiter)
+	w.index = len(h.items)
+	h.items = append(h.items, w)
+}
+
+func (h *waitHeap) Pop() any {
+	n := len(h.items) - 1
+	w := h.items[n]
+	h.items[n] = nil
+	h.items = h.items[:n]
+	w.index = -1
+	return w
+}
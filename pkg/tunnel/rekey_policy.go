@@ -0,0 +1,96 @@
+package tunnel
+
+import (
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+// RekeyPolicy sets the thresholds at which a session asks for a rekey.
+// Counts restart whenever new keys are installed. Zero fields select the
+// defaults from internal/constants.
+//
+// Regardless of the policy, a session needs a rekey once its send cipher
+// approaches nonce exhaustion, since it cannot send any further records.
+type RekeyPolicy struct {
+	// MaxBytes is the number of plaintext bytes sent under one key.
+	// Default: 1 GiB (constants.MaxBytesBeforeRekey)
+	MaxBytes uint64
+
+	// MaxPackets is the number of records sent under one key. It may not
+	// exceed the cipher suite's nonce limit (see crypto.NonceLimit).
+	// Default: 2^28 (constants.MaxPacketsBeforeRekey)
+	MaxPackets uint64
+
+	// MaxDuration is how long one key is used.
+	// Default: 1 hour (constants.MaxSessionDurationSeconds)
+	MaxDuration time.Duration
+
+	// Disabled turns off the byte, packet and duration thresholds.
+	Disabled bool
+}
+
+// DefaultRekeyPolicy returns a RekeyPolicy with the default thresholds.
+func DefaultRekeyPolicy() RekeyPolicy {
+	return RekeyPolicy{
+		MaxBytes:    constants.MaxBytesBeforeRekey,
+		MaxPackets:  constants.MaxPacketsBeforeRekey,
+		MaxDuration: constants.MaxSessionDurationSeconds * time.Second,
+	}
+}
+
+// withDefaults fills in zero thresholds with their defaults.
+func (p RekeyPolicy) withDefaults() RekeyPolicy {
+	defaults := DefaultRekeyPolicy()
+	if p.MaxBytes == 0 {
+		p.MaxBytes = defaults.MaxBytes
+	}
+	if p.MaxPackets == 0 {
+		p.MaxPackets = defaults.MaxPackets
+	}
+	if p.MaxDuration == 0 {
+		p.MaxDuration = defaults.MaxDuration
+	}
+	return p
+}
+
+// Validate checks that the policy rekeys before a cipher of the given suite
+// exhausts its nonces. It returns ErrInvalidRekeyPolicy if MaxPackets
+// exceeds the suite's nonce limit or MaxDuration is negative.
+func (p RekeyPolicy) Validate(suite constants.CipherSuite) error {
+	limit := crypto.NonceLimit(suite)
+	if limit == 0 {
+		return qerrors.ErrUnsupportedCipherSuite
+	}
+
+	p = p.withDefaults()
+	if p.MaxDuration < 0 {
+		return qerrors.ErrInvalidRekeyPolicy
+	}
+	if !p.Disabled && p.MaxPackets > limit {
+		return qerrors.ErrInvalidRekeyPolicy
+	}
+	return nil
+}
+
+// scaled returns the policy with its thresholds reduced to fraction of
+// their values, for rekeying ahead of the limits.
+func (p RekeyPolicy) scaled(fraction float64) RekeyPolicy {
+	p.MaxBytes = uint64(float64(p.MaxBytes) * fraction)
+	p.MaxPackets = uint64(float64(p.MaxPackets) * fraction)
+	p.MaxDuration = time.Duration(float64(p.MaxDuration) * fraction)
+	return p
+}
+
+// exceeded reports whether keys that have sent bytes and packets since
+// installedAt should be replaced.
+func (p RekeyPolicy) exceeded(bytes, packets uint64, installedAt time.Time) bool {
+	if p.Disabled {
+		return false
+	}
+	return bytes >= p.MaxBytes ||
+		packets >= p.MaxPackets ||
+		time.Since(installedAt) >= p.MaxDuration
+}
@@ -0,0 +1,144 @@
+package tunnel
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+func TestRekeyPolicyByteThreshold(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	})
+
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+
+	clientSession, err := NewSessionWithConfig(RoleInitiator, SessionConfig{
+		RekeyPolicy: RekeyPolicy{MaxBytes: 1000},
+	})
+	if err != nil {
+		t.Fatalf("NewSessionWithConfig failed: %v", err)
+	}
+	_ = clientSession.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	serverSession, _ := NewSession(RoleResponder)
+	_ = serverSession.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	client := &Transport{session: clientSession, conn: clientConn, codec: protocol.NewCodec()}
+	server := &Transport{session: serverSession, conn: serverConn, codec: protocol.NewCodec()}
+	clientInbox, serverInbox := readMessages(client), readMessages(server)
+
+	// 3 x 300 bytes stays under the threshold, the fourth send crosses it
+	for i := 1; i <= 4; i++ {
+		if err := client.Send(make([]byte, 300)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		<-serverInbox
+
+		go func() { _ = client.CheckAndRekey() }()
+		select {
+		case msg := <-serverInbox:
+			if i < 4 {
+				t.Fatalf("rekey requested after %d bytes", i*300)
+			}
+			if protocol.MessageType(msg[0]) != protocol.MessageTypeRekey {
+				t.Fatalf("expected a rekey request, got message type %v", protocol.MessageType(msg[0]))
+			}
+			if err := server.handleRekey(msg); err != nil {
+				t.Fatalf("server handleRekey failed: %v", err)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if i == 4 {
+				t.Fatal("no rekey requested after crossing the byte threshold")
+			}
+		}
+	}
+
+	if err := client.handleRekey(<-clientInbox); err != nil {
+		t.Fatalf("client handleRekey failed: %v", err)
+	}
+	clientSession.ActivatePendingKeys()
+
+	// The byte count restarts for the new keys
+	if clientSession.NeedsRekey() {
+		t.Error("fresh keys should not need a rekey")
+	}
+}
+
+func TestRekeyPolicyPacketsAndDisabled(t *testing.T) {
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+
+	newSession := func(policy RekeyPolicy) *Session {
+		t.Helper()
+		session, err := NewSessionWithConfig(RoleInitiator, SessionConfig{RekeyPolicy: policy})
+		if err != nil {
+			t.Fatalf("NewSessionWithConfig failed: %v", err)
+		}
+		if err := session.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM); err != nil {
+			t.Fatalf("InitializeKeys failed: %v", err)
+		}
+		return session
+	}
+
+	session := newSession(RekeyPolicy{MaxPackets: 3})
+	for i := 0; i < 3; i++ {
+		if session.NeedsRekey() {
+			t.Fatalf("rekey needed after %d packets", i)
+		}
+		_, _, _ = session.Encrypt([]byte("x"))
+	}
+	if !session.NeedsRekey() {
+		t.Error("expected rekey after 3 packets")
+	}
+
+	session = newSession(RekeyPolicy{MaxDuration: time.Minute})
+	session.EstablishedAt = time.Now().Add(-2 * time.Minute)
+	if !session.NeedsRekey() {
+		t.Error("expected rekey after MaxDuration")
+	}
+
+	session = newSession(RekeyPolicy{MaxBytes: 1, MaxPackets: 1, MaxDuration: time.Nanosecond, Disabled: true})
+	_, _, _ = session.Encrypt([]byte("x"))
+	if session.NeedsRekey() {
+		t.Error("disabled policy should not request a rekey")
+	}
+}
+
+func TestRekeyPolicyValidate(t *testing.T) {
+	limit := crypto.NonceLimit(constants.CipherSuiteAES256GCM)
+
+	tests := []struct {
+		name   string
+		policy RekeyPolicy
+		want   error
+	}{
+		{"zero value", RekeyPolicy{}, nil},
+		{"defaults", DefaultRekeyPolicy(), nil},
+		{"at nonce limit", RekeyPolicy{MaxPackets: limit}, nil},
+		{"beyond nonce limit", RekeyPolicy{MaxPackets: limit + 1}, qerrors.ErrInvalidRekeyPolicy},
+		{"negative duration", RekeyPolicy{MaxDuration: -time.Second}, qerrors.ErrInvalidRekeyPolicy},
+		{"disabled", RekeyPolicy{MaxPackets: limit + 1, Disabled: true}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewSessionWithConfig(RoleInitiator, SessionConfig{RekeyPolicy: tt.policy})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+
+	if err := DefaultRekeyPolicy().Validate(constants.CipherSuite(0xFF)); !errors.Is(err, qerrors.ErrUnsupportedCipherSuite) {
+		t.Errorf("expected ErrUnsupportedCipherSuite, got %v", err)
+	}
+}
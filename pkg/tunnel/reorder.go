@@ -0,0 +1,100 @@
+package tunnel
+
+import qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+
+// reorderEntry is a record held until all earlier sequence numbers arrive.
+type reorderEntry struct {
+	header    []byte // Application header of a DataHeader record
+	data      []byte
+	control   bool // Sequence number consumed by a control record, nothing to deliver
+	endOfData bool // The peer's close-write, reached once earlier records are delivered
+}
+
+// reorderBuffer delivers data records in sequence-number order.
+//
+// Records that arrive ahead of a gap are held until the missing sequence
+// numbers arrive. Control records that consume sequence numbers, such as
+// rekey messages, fill their slot without being delivered. The buffer is
+// only used by the receiving goroutine.
+type reorderBuffer struct {
+	next    uint64 // Next sequence number to deliver
+	limit   int
+	pending map[uint64]reorderEntry
+	eof     bool // The peer's close-write was reached
+}
+
+// newReorderBuffer creates a buffer holding up to limit out-of-order
+// records, or returns nil if limit is 0.
+func newReorderBuffer(limit int) *reorderBuffer {
+	if limit <= 0 {
+		return nil
+	}
+	return &reorderBuffer{
+		limit:   limit,
+		pending: make(map[uint64]reorderEntry),
+	}
+}
+
+// push adds a decrypted data record. It returns ErrReorderBufferFull if the
+// record would exceed the buffer while waiting for a gap to fill.
+func (b *reorderBuffer) push(seq uint64, header, data []byte) error {
+	return b.add(seq, reorderEntry{header: header, data: data})
+}
+
+// skip fills seq's slot for a control record.
+func (b *reorderBuffer) skip(seq uint64) error {
+	if b == nil {
+		return nil
+	}
+	return b.add(seq, reorderEntry{control: true})
+}
+
+// closeWrite fills seq's slot with the peer's close-write, which is reached
+// once every earlier record has been delivered.
+func (b *reorderBuffer) closeWrite(seq uint64) error {
+	if b == nil {
+		return nil
+	}
+	return b.add(seq, reorderEntry{control: true, endOfData: true})
+}
+
+// reachedEOF reports whether the peer's close-write was reached. A nil
+// buffer never holds a close-write.
+func (b *reorderBuffer) reachedEOF() bool {
+	return b != nil && b.eof
+}
+
+// add stores an entry under seq.
+func (b *reorderBuffer) add(seq uint64, entry reorderEntry) error {
+	if _, ok := b.pending[seq]; ok || seq < b.next {
+		return qerrors.ErrReplayDetected
+	}
+	if seq != b.next && len(b.pending) >= b.limit {
+		return qerrors.ErrReorderBufferFull
+	}
+	b.pending[seq] = entry
+	return nil
+}
+
+// pop returns the header and data of the next in-order data record, if it
+// has arrived.
+func (b *reorderBuffer) pop() (header, data []byte, ok bool) {
+	if b == nil {
+		return nil, nil, false
+	}
+	for !b.eof {
+		entry, ok := b.pending[b.next]
+		if !ok {
+			return nil, nil, false
+		}
+		delete(b.pending, b.next)
+		b.next++
+		if entry.endOfData {
+			b.eof = true
+		}
+		if !entry.control {
+			return entry.header, entry.data, true
+		}
+	}
+	return nil, nil, false
+}
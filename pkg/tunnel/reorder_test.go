@@ -0,0 +1,145 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// sealRecords encrypts count data records on tr, numbered by payload.
+func sealRecords(t *testing.T, tr *Transport, count int) [][]byte {
+	t.Helper()
+
+	records := make([][]byte, count)
+	for i := range records {
+		ciphertext, seq, err := tr.session.Encrypt([]byte(fmt.Sprint(i)))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		records[i], err = tr.codec.EncodeData(seq, ciphertext)
+		if err != nil {
+			t.Fatalf("EncodeData failed: %v", err)
+		}
+	}
+	return records
+}
+
+// writeRecords writes records to tr's connection in the given order.
+func writeRecords(tr *Transport, records [][]byte, order []int) {
+	go func() {
+		for _, i := range order {
+			if _, err := tr.conn.Write(records[i]); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// receiveAll receives count records and returns their payloads.
+func receiveAll(t *testing.T, tr *Transport, count int) []string {
+	t.Helper()
+
+	got := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		data, err := tr.Receive()
+		if err != nil {
+			t.Fatalf("Receive %d failed: %v", i, err)
+		}
+		got = append(got, string(data))
+	}
+	return got
+}
+
+func TestReorderInOrderDelivery(t *testing.T) {
+	client, server := newPipeTransports(t)
+	server.reorder = newReorderBuffer(4)
+
+	writeRecords(client, sealRecords(t, client, 4), []int{0, 2, 1, 3})
+
+	got := receiveAll(t, server, 4)
+	if fmt.Sprint(got) != "[0 1 2 3]" {
+		t.Errorf("expected delivery order [0 1 2 3], got %v", got)
+	}
+}
+
+func TestReorderDisabledByDefault(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	writeRecords(client, sealRecords(t, client, 4), []int{0, 2, 1, 3})
+
+	got := receiveAll(t, server, 4)
+	if fmt.Sprint(got) != "[0 2 1 3]" {
+		t.Errorf("expected arrival order [0 2 1 3], got %v", got)
+	}
+}
+
+func TestReorderBufferOverflow(t *testing.T) {
+	client, server := newPipeTransports(t)
+	server.reorder = newReorderBuffer(1)
+
+	// Record 0 never arrives; record 1 fills the buffer and 2 overflows it
+	writeRecords(client, sealRecords(t, client, 3), []int{1, 2})
+
+	if _, err := server.Receive(); !errors.Is(err, qerrors.ErrReorderBufferFull) {
+		t.Errorf("expected ErrReorderBufferFull, got %v", err)
+	}
+}
+
+func TestReorderBufferControlRecords(t *testing.T) {
+	b := newReorderBuffer(4)
+
+	// A rekey message at sequence 1 fills its slot without being delivered
+	if err := b.push(2, nil, []byte("two")); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if err := b.skip(1); err != nil {
+		t.Fatalf("skip failed: %v", err)
+	}
+	if _, _, ok := b.pop(); ok {
+		t.Fatal("pop should wait for sequence 0")
+	}
+
+	if err := b.push(0, nil, []byte("zero")); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	for _, want := range []string{"zero", "two"} {
+		_, data, ok := b.pop()
+		if !ok || string(data) != want {
+			t.Errorf("expected %q, got %q (ok=%v)", want, data, ok)
+		}
+	}
+
+	if err := b.push(1, nil, nil); !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("expected ErrReplayDetected for a delivered sequence, got %v", err)
+	}
+	if newReorderBuffer(0) != nil {
+		t.Error("zero limit should disable the reorder buffer")
+	}
+}
+
+func TestReorderCloseWrite(t *testing.T) {
+	client, server := newPipeTransports(t)
+	server.reorder = newReorderBuffer(4)
+
+	records := sealRecords(t, client, 2)
+	ciphertext, seq, err := client.session.Encrypt([]byte{byte(protocol.MessageTypeCloseWrite)})
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	closeWrite, _ := client.codec.EncodeCloseWrite(seq, ciphertext)
+	records = append(records, closeWrite)
+
+	// The close-write overtakes the last record but must not cut it off
+	writeRecords(client, records, []int{0, 2, 1})
+
+	if got := receiveAll(t, server, 2); got[0] != "0" || got[1] != "1" {
+		t.Errorf("unexpected delivery order %v", got)
+	}
+	if _, err := server.Receive(); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
@@ -0,0 +1,198 @@
+// Package tunnel implements server-side session resumption for the CH-KEM VPN.
+//
+// This file (resumption.go) provides the ResumptionStore used by responders
+// to recognize returning clients and run an abbreviated handshake:
+//
+//	Initiator                              Responder
+//	    |                                      |
+//	    | -------- ClientHello --------------> |
+//	    |   - SessionID from earlier session   |
+//	    |                                      |
+//	    | <------- ServerHello --------------- |
+//	    |   - SessionID echoed, resumed flag   |
+//	    |   - no CH-KEM ciphertext             |
+//	    |                                      |
+//	    |   [Both derive secret from stored    |
+//	    |    resumption secret and randoms]    |
+//	    |                                      |
+//	    | -------- ClientFinished -----------> |
+//	    | <------- ServerFinished ------------ |
+//
+// Security Properties:
+//   - Fresh traffic keys: both hello randoms are mixed into the secret
+//   - Key confirmation: Finished messages are still exchanged, so a client
+//     without the matching resumption secret is rejected
+//   - No fresh KEM: a leaked resumption secret exposes sessions resumed from
+//     it, so entries expire and are replaced after every resumption
+//
+// If the SessionID is unknown or expired, the responder silently falls back
+// to a full handshake and assigns a new SessionID.
+//
+// 0-RTT early data: with TransportConfig.Allow0RTT, a resuming client may
+// send one early data record right after its ClientHello, encrypted under a
+// key derived from the resumption secret (see crypto.DeriveEarlyDataKey).
+// The responder accepts it only on an abbreviated handshake whose store
+// implements EarlyDataStore, and confirms acceptance in the ServerHello.
+// Early data lacks forward secrecy and may be replayed by an attacker who
+// captured the first flight: Take consumes the store entry, so a listener
+// accepts a given first flight at most once, but applications should still
+// only act on early data that is safe to repeat. Rejected early data is sent
+// again as ordinary data once the handshake completes.
+package tunnel
+
+import (
+	"sync"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+// ResumptionStore holds the state responders need to resume sessions.
+//
+// Entries are keyed by session ID. Implementations must be safe for
+// concurrent use and may be shared between listeners.
+type ResumptionStore interface {
+	// Put stores the resumption state for a session ID, replacing any
+	// existing entry.
+	Put(sessionID []byte, ticket *SessionTicket) error
+
+	// Get returns the resumption state for a session ID. It returns
+	// ErrInvalidTicket if the ID is unknown and ErrExpiredTicket if the
+	// entry has expired.
+	Get(sessionID []byte) (*SessionTicket, error)
+
+	// Delete removes the entry for a session ID, if any.
+	Delete(sessionID []byte)
+}
+
+// MaxEarlyDataSize is the maximum size of 0-RTT early data. Larger early
+// data is sent after the handshake instead.
+const MaxEarlyDataSize = 16 * 1024
+
+// EarlyDataStore is a ResumptionStore that can reject replayed 0-RTT early
+// data. Listeners only accept early data when their ResumptionStore
+// implements it.
+type EarlyDataStore interface {
+	ResumptionStore
+
+	// Take atomically returns and removes the resumption state for a
+	// session ID, so concurrent resumptions of one entry cannot both
+	// succeed. It returns the same errors as Get.
+	Take(sessionID []byte) (*SessionTicket, error)
+}
+
+// MemoryResumptionStore is an in-memory ResumptionStore with a fixed entry
+// lifetime.
+type MemoryResumptionStore struct {
+	mu        sync.Mutex
+	entries   map[string]*SessionTicket
+	ttl       time.Duration
+	lastSweep time.Time
+}
+
+// NewMemoryResumptionStore creates an in-memory store whose entries expire
+// ttl after the session they resume was first established.
+func NewMemoryResumptionStore(ttl time.Duration) *MemoryResumptionStore {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour // Default 24 hours, matching TicketManager
+	}
+	return &MemoryResumptionStore{
+		entries:   make(map[string]*SessionTicket),
+		ttl:       ttl,
+		lastSweep: time.Now(),
+	}
+}
+
+// Put stores a copy of the ticket under sessionID.
+func (m *MemoryResumptionStore) Put(sessionID []byte, ticket *SessionTicket) error {
+	if len(sessionID) == 0 || ticket == nil {
+		return qerrors.ErrInvalidTicket
+	}
+
+	entry := *ticket
+	entry.MasterSecret = append([]byte(nil), ticket.MasterSecret...)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(m.lastSweep) > m.ttl {
+		m.sweepLocked(now)
+	}
+
+	if old, ok := m.entries[string(sessionID)]; ok {
+		crypto.Zeroize(old.MasterSecret)
+	}
+	m.entries[string(sessionID)] = &entry
+	return nil
+}
+
+// Get returns a copy of the ticket stored under sessionID.
+func (m *MemoryResumptionStore) Get(sessionID []byte) (*SessionTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[string(sessionID)]
+	if !ok {
+		return nil, qerrors.ErrInvalidTicket
+	}
+	if time.Since(entry.CreatedAt) > m.ttl {
+		m.deleteLocked(string(sessionID))
+		return nil, qerrors.ErrExpiredTicket
+	}
+
+	ticket := *entry
+	ticket.MasterSecret = append([]byte(nil), entry.MasterSecret...)
+	return &ticket, nil
+}
+
+// Take returns the ticket stored under sessionID and removes it.
+func (m *MemoryResumptionStore) Take(sessionID []byte) (*SessionTicket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	entry, ok := m.entries[string(sessionID)]
+	if !ok {
+		return nil, qerrors.ErrInvalidTicket
+	}
+	delete(m.entries, string(sessionID))
+	if time.Since(entry.CreatedAt) > m.ttl {
+		crypto.Zeroize(entry.MasterSecret)
+		return nil, qerrors.ErrExpiredTicket
+	}
+	return entry, nil
+}
+
+// Delete removes the entry for sessionID.
+func (m *MemoryResumptionStore) Delete(sessionID []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.deleteLocked(string(sessionID))
+}
+
+// Len returns the number of stored entries, including expired ones not yet
+// swept.
+func (m *MemoryResumptionStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.entries)
+}
+
+// sweepLocked drops expired entries. Must hold m.mu.
+func (m *MemoryResumptionStore) sweepLocked(now time.Time) {
+	for id, entry := range m.entries {
+		if now.Sub(entry.CreatedAt) > m.ttl {
+			m.deleteLocked(id)
+		}
+	}
+	m.lastSweep = now
+}
+
+// deleteLocked zeroizes and removes an entry. Must hold m.mu.
+func (m *MemoryResumptionStore) deleteLocked(id string) {
+	if entry, ok := m.entries[id]; ok {
+		crypto.Zeroize(entry.MasterSecret)
+		delete(m.entries, id)
+	}
+}
@@ -2,9 +2,14 @@ package tunnel
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"net"
 	"testing"
 	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 )
 
 func TestSessionResumption(t *testing.T) {
@@ -299,3 +304,384 @@ func TestResumptionForwardSecrecy(t *testing.T) {
 		t.Error("two resumptions with the same ticket should produce different secrets (fresh KEM)")
 	}
 }
+
+// storeHandshake runs a responder with store against an initiator that
+// resumes from (id, secret), or performs a full handshake if id is nil.
+func storeHandshake(t *testing.T, store ResumptionStore, id, secret []byte) (client, server *Session, clientErr, serverErr error) {
+	t.Helper()
+
+	client, _ = NewSession(RoleInitiator)
+	server, _ = NewSession(RoleResponder)
+	c, s := net.Pipe()
+	defer c.Close()
+
+	errChan := make(chan error, 1)
+	go func() {
+		err := ResponderHandshakeWithStore(server, s, store)
+		s.Close()
+		errChan <- err
+	}()
+
+	if id == nil {
+		clientErr = InitiatorHandshake(client, c)
+	} else {
+		clientErr = InitiatorResumptionHandshake(client, c, id, secret)
+	}
+	c.Close()
+	serverErr = <-errChan
+	return client, server, clientErr, serverErr
+}
+
+// fullStoreHandshake performs a full handshake against a responder with store
+// and returns the client session's resumption ID and secret.
+func fullStoreHandshake(t *testing.T, store ResumptionStore) (id, secret []byte) {
+	t.Helper()
+
+	client, server, clientErr, serverErr := storeHandshake(t, store, nil, nil)
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("full handshake failed: client=%v server=%v", clientErr, serverErr)
+	}
+	if client.Resumed() || server.Resumed() {
+		t.Fatal("full handshake reported as resumed")
+	}
+	if len(client.ID) != constants.SessionIDSize || !bytes.Equal(client.ID, server.ID) {
+		t.Fatalf("expected matching %d-byte session IDs, got %x and %x", constants.SessionIDSize, client.ID, server.ID)
+	}
+
+	secret, err := client.ResumptionSecret()
+	if err != nil {
+		t.Fatalf("ResumptionSecret failed: %v", err)
+	}
+	return client.ID, secret
+}
+
+func TestAbbreviatedResumption(t *testing.T) {
+	store := NewMemoryResumptionStore(time.Hour)
+	id, secret := fullStoreHandshake(t, store)
+	if store.Len() != 1 {
+		t.Fatalf("expected 1 stored session, got %d", store.Len())
+	}
+
+	client, server, clientErr, serverErr := storeHandshake(t, store, id, secret)
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("resumed handshake failed: client=%v server=%v", clientErr, serverErr)
+	}
+	if !client.Resumed() || !server.Resumed() {
+		t.Fatal("expected both sides to report an abbreviated handshake")
+	}
+	if !bytes.Equal(server.ID, id) {
+		t.Error("resumed session should keep its session ID")
+	}
+
+	// Traffic keys work in both directions
+	ct, seq, err := client.Encrypt([]byte("resumed"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if pt, err := server.Decrypt(ct, seq); err != nil || string(pt) != "resumed" {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+	ct, seq, err = server.Encrypt([]byte("reply"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if pt, err := client.Decrypt(ct, seq); err != nil || string(pt) != "reply" {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+
+	// The resumed session can itself be resumed, and each resumption gets
+	// fresh keys from the new randoms
+	next, err := client.ResumptionSecret()
+	if err != nil {
+		t.Fatalf("ResumptionSecret failed: %v", err)
+	}
+	client2, _, clientErr, serverErr := storeHandshake(t, store, id, next)
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("second resumption failed: client=%v server=%v", clientErr, serverErr)
+	}
+	if !client2.Resumed() {
+		t.Error("expected second resumption to be abbreviated")
+	}
+	if bytes.Equal(client.masterSecret, client2.masterSecret) {
+		t.Error("resumptions should derive different master secrets")
+	}
+
+	// The replaced entry no longer accepts the first secret
+	_, _, clientErr, serverErr = storeHandshake(t, store, id, secret)
+	if clientErr == nil || !errors.Is(serverErr, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("stale secret should be rejected: client=%v server=%v", clientErr, serverErr)
+	}
+}
+
+func TestAbbreviatedResumptionExpiredFallback(t *testing.T) {
+	store := NewMemoryResumptionStore(time.Minute)
+	id, secret := fullStoreHandshake(t, store)
+
+	// Backdate the entry past its lifetime
+	store.mu.Lock()
+	store.entries[string(id)].CreatedAt = time.Now().Add(-2 * time.Minute)
+	store.mu.Unlock()
+
+	client, server, clientErr, serverErr := storeHandshake(t, store, id, secret)
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("fallback handshake failed: client=%v server=%v", clientErr, serverErr)
+	}
+	if client.Resumed() || server.Resumed() {
+		t.Error("expired entry should fall back to a full handshake")
+	}
+	if bytes.Equal(client.ID, id) {
+		t.Error("full handshake should assign a new session ID")
+	}
+	if _, err := store.Get(id); !errors.Is(err, qerrors.ErrInvalidTicket) {
+		t.Errorf("expired entry should be removed, got %v", err)
+	}
+}
+
+func TestAbbreviatedResumptionTampered(t *testing.T) {
+	store := NewMemoryResumptionStore(time.Hour)
+	id, secret := fullStoreHandshake(t, store)
+
+	t.Run("session ID", func(t *testing.T) {
+		tampered := append([]byte(nil), id...)
+		tampered[0] ^= 0x01
+
+		client, _, clientErr, serverErr := storeHandshake(t, store, tampered, secret)
+		if clientErr != nil || serverErr != nil {
+			t.Fatalf("fallback handshake failed: client=%v server=%v", clientErr, serverErr)
+		}
+		if client.Resumed() {
+			t.Error("unknown session ID should not be resumed")
+		}
+	})
+
+	t.Run("secret", func(t *testing.T) {
+		tampered := append([]byte(nil), secret...)
+		tampered[0] ^= 0x01
+
+		_, _, clientErr, serverErr := storeHandshake(t, store, id, tampered)
+		if !errors.Is(serverErr, qerrors.ErrAuthenticationFailed) {
+			t.Errorf("expected responder ErrAuthenticationFailed, got %v", serverErr)
+		}
+		if clientErr == nil {
+			t.Error("expected initiator to fail")
+		}
+	})
+}
+
+func TestListenerResumptionStore(t *testing.T) {
+	config := DefaultTransportConfig()
+	config.ResumptionStore = NewMemoryResumptionStore(time.Hour)
+
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer listener.Close()
+	listener.SetConfig(config)
+
+	accepted := make(chan *Session, 2)
+	go func() {
+		for {
+			tunnel, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			accepted <- tunnel.Session()
+			tunnel.Close()
+		}
+	}()
+
+	dial := func(id, secret []byte) *Session {
+		t.Helper()
+		conn, err := net.Dial("tcp", listener.Addr().String())
+		if err != nil {
+			t.Fatalf("Dial failed: %v", err)
+		}
+		defer conn.Close()
+
+		session, _ := NewSession(RoleInitiator)
+		if id == nil {
+			err = InitiatorHandshake(session, conn)
+		} else {
+			err = InitiatorResumptionHandshake(session, conn, id, secret)
+		}
+		if err != nil {
+			t.Fatalf("handshake failed: %v", err)
+		}
+		return session
+	}
+
+	first := dial(nil, nil)
+	if server := <-accepted; server.Resumed() {
+		t.Error("first connection should use a full handshake")
+	}
+	secret, err := first.ResumptionSecret()
+	if err != nil {
+		t.Fatalf("ResumptionSecret failed: %v", err)
+	}
+
+	second := dial(first.ID, secret)
+	if !second.Resumed() {
+		t.Error("second connection should be resumed")
+	}
+	if server := <-accepted; !server.Resumed() {
+		t.Error("listener should report the resumed session")
+	}
+}
+
+// startResumptionListener starts a listener with a memory resumption store
+// and returns it with a channel of accepted tunnels.
+func startResumptionListener(t *testing.T, allow0RTT bool) (*Listener, <-chan *Tunnel) {
+	t.Helper()
+
+	config := DefaultTransportConfig()
+	config.ResumptionStore = NewMemoryResumptionStore(time.Hour)
+	config.Allow0RTT = allow0RTT
+
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	listener.SetConfig(config)
+
+	accepted := make(chan *Tunnel, 2)
+	go func() {
+		for {
+			tunnel, err := listener.Accept()
+			if err != nil {
+				close(accepted)
+				return
+			}
+			t.Cleanup(func() { _ = tunnel.Close() })
+			accepted <- tunnel
+		}
+	}()
+	return listener, accepted
+}
+
+// dialResumable performs a full handshake with the listener and returns the
+// resumption ID and secret of the client session.
+func dialResumable(t *testing.T, listener *Listener, accepted <-chan *Tunnel) (id, secret []byte) {
+	t.Helper()
+
+	client, err := Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	<-accepted
+
+	secret, err = client.Session().ResumptionSecret()
+	if err != nil {
+		t.Fatalf("ResumptionSecret failed: %v", err)
+	}
+	return client.Session().ID, secret
+}
+
+func TestDialResumeEarlyData(t *testing.T) {
+	listener, accepted := startResumptionListener(t, true)
+	id, secret := dialResumable(t, listener, accepted)
+
+	config := DefaultTransportConfig()
+	config.Allow0RTT = true
+	earlyData := []byte("early request")
+	client, err := DialResume("tcp", listener.Addr().String(), config, id, secret, earlyData)
+	if err != nil {
+		t.Fatalf("DialResume failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	server := <-accepted
+
+	if !client.Session().Resumed() {
+		t.Fatal("expected an abbreviated handshake")
+	}
+	if !client.Session().EarlyDataAccepted() || !server.Session().EarlyDataAccepted() {
+		t.Fatal("expected early data to be accepted")
+	}
+	if got := server.Session().EarlyData(); !bytes.Equal(got, earlyData) {
+		t.Errorf("server early data = %q, want %q", got, earlyData)
+	}
+	if client.Session().EarlyData() != nil {
+		t.Error("initiator should not report early data")
+	}
+
+	// Accepted early data is not delivered again through Receive
+	if err := client.Send([]byte("after handshake")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if got, err := server.Receive(); err != nil || string(got) != "after handshake" {
+		t.Errorf("Receive = %q, %v; want %q", got, err, "after handshake")
+	}
+}
+
+func TestDialResumeEarlyDataRejected(t *testing.T) {
+	listener, accepted := startResumptionListener(t, false)
+	id, secret := dialResumable(t, listener, accepted)
+
+	config := DefaultTransportConfig()
+	config.Allow0RTT = true
+	earlyData := []byte("early request")
+	client, err := DialResume("tcp", listener.Addr().String(), config, id, secret, earlyData)
+	if err != nil {
+		t.Fatalf("DialResume failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	server := <-accepted
+
+	if !client.Session().Resumed() {
+		t.Fatal("expected an abbreviated handshake")
+	}
+	if client.Session().EarlyDataAccepted() || server.Session().EarlyData() != nil {
+		t.Fatal("expected early data to be rejected")
+	}
+
+	// The rejected early data arrives as ordinary data instead
+	if got, err := server.Receive(); err != nil || !bytes.Equal(got, earlyData) {
+		t.Errorf("Receive = %q, %v; want %q", got, err, earlyData)
+	}
+}
+
+func TestEarlyDataReplay(t *testing.T) {
+	store := NewMemoryResumptionStore(time.Hour)
+	id, secret := fullStoreHandshake(t, store)
+
+	// Capture a client's first flight: ClientHello and early data record
+	client, _ := NewSession(RoleInitiator)
+	h := NewHandshake(client)
+	h.SetTicket(id, secret)
+	h.SetEarlyData([]byte("transfer 100"))
+	clientHello, err := h.CreateClientHello()
+	if err != nil {
+		t.Fatalf("CreateClientHello failed: %v", err)
+	}
+	record, err := h.sealEarlyData()
+	if err != nil {
+		t.Fatalf("sealEarlyData failed: %v", err)
+	}
+	var flight bytes.Buffer
+	flight.Write(clientHello)
+	if err := writeEncryptedRecord(&flight, record); err != nil {
+		t.Fatalf("writeEncryptedRecord failed: %v", err)
+	}
+
+	// Deliver the same flight twice; only the first may carry early data
+	for i, wantAccepted := range []bool{true, false} {
+		server, _ := NewSession(RoleResponder)
+		sh := NewHandshake(server)
+		sh.SetResumptionStore(store)
+		sh.SetAllowEarlyData(true)
+
+		rw := struct {
+			io.Reader
+			io.Writer
+		}{bytes.NewReader(flight.Bytes()), io.Discard}
+		if err := sh.receiveClientHello(rw); err != nil {
+			t.Fatalf("delivery %d: receiveClientHello failed: %v", i, err)
+		}
+		if sh.earlyDataAccepted != wantAccepted {
+			t.Errorf("delivery %d: early data accepted = %v, want %v", i, sh.earlyDataAccepted, wantAccepted)
+		}
+	}
+}
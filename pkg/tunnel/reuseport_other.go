@@ -0,0 +1,15 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd && !dragonfly
+// +build !linux,!darwin,!freebsd,!netbsd,!openbsd,!dragonfly
+
+package tunnel
+
+import "syscall"
+
+// reusePortSupported reports whether reusePortControl can set SO_REUSEPORT.
+const reusePortSupported = false
+
+// reusePortControl is never called: ListenWithConfig fails with
+// ErrReusePortUnsupported first.
+func reusePortControl(_, _ string, _ syscall.RawConn) error {
+	return nil
+}
@@ -0,0 +1,239 @@
+package tunnel
+
+import (
+	"context"
+	"sync"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+)
+
+// AsyncSendConfig configures asynchronous sending.
+type AsyncSendConfig struct {
+	// Enabled makes Send queue messages for a writer goroutine instead of
+	// writing them itself.
+	Enabled bool
+
+	// QueueSize is how many messages may wait to be written. Send blocks
+	// while the queue is full.
+	// Default: 256
+	QueueSize int
+
+	// OnError is called once, on its own goroutine, with the first error
+	// that stopped the writer, so it may close the transport. The same
+	// error is returned by later calls to Send and Flush.
+	// nil only reports errors through Send and Flush.
+	OnError func(error)
+}
+
+// defaultSendQueueSize is the default number of queued messages.
+const defaultSendQueueSize = 256
+
+// maxSendBatch is the size in bytes beyond which the writer stops
+// coalescing queued records into one write.
+const maxSendBatch = 64 * 1024
+
+// queuedSend is a message waiting in the send queue, or a Flush marker.
+type queuedSend struct {
+	header  []byte
+	data    []byte
+	flushed chan struct{} // Closed once everything queued before it is written
+}
+
+// sendQueue feeds messages from Send to a single writer goroutine, which
+// seals them in queue order and coalesces the records that are ready into
+// one write. A full queue blocks Send, pushing back on fast senders.
+type sendQueue struct {
+	t        *Transport
+	messages chan queuedSend
+	onError  func(error)
+
+	mu        sync.RWMutex // Held for reading while enqueueing, so close waits for enqueuers
+	closed    bool
+	closing   chan struct{} // Closed first to unblock enqueuers waiting on a full queue
+	closeOnce sync.Once
+	done      chan struct{} // Closed when the writer exits
+
+	errMu sync.Mutex
+	err   error
+
+	records int // Records in the batch being built (writer goroutine only)
+}
+
+// newSendQueue starts the writer goroutine for t.
+func newSendQueue(t *Transport, config AsyncSendConfig) *sendQueue {
+	size := config.QueueSize
+	if size <= 0 {
+		size = defaultSendQueueSize
+	}
+	q := &sendQueue{
+		t:        t,
+		messages: make(chan queuedSend, size),
+		onError:  config.OnError,
+		closing:  make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go q.run()
+	return q
+}
+
+// enqueue adds m to the queue, blocking while it is full. It fails once
+// the writer has failed or the queue is closed.
+func (q *sendQueue) enqueue(ctx context.Context, m queuedSend) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return qerrors.ErrTunnelClosed
+	}
+	if err := q.failure(); err != nil {
+		return err
+	}
+	select {
+	case q.messages <- m:
+		return nil
+	case <-q.closing:
+		return qerrors.ErrTunnelClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flush waits until everything queued so far has been written.
+func (q *sendQueue) flush() error {
+	flushed := make(chan struct{})
+	if err := q.enqueue(context.Background(), queuedSend{flushed: flushed}); err != nil {
+		return err
+	}
+	<-flushed
+	return q.failure()
+}
+
+// close stops accepting messages and waits for the writer to write those
+// already queued. Enqueuers blocked on a full queue fail with
+// ErrTunnelClosed.
+func (q *sendQueue) close() {
+	q.closeOnce.Do(func() {
+		close(q.closing)
+		q.mu.Lock()
+		q.closed = true
+		close(q.messages)
+		q.mu.Unlock()
+	})
+	<-q.done
+}
+
+// failure returns the error that stopped the writer, if any.
+func (q *sendQueue) failure() error {
+	q.errMu.Lock()
+	defer q.errMu.Unlock()
+	return q.err
+}
+
+// fail records the first error that stops the writer and reports it.
+func (q *sendQueue) fail(err error) {
+	q.errMu.Lock()
+	first := q.err == nil
+	if first {
+		q.err = err
+	}
+	q.errMu.Unlock()
+
+	if first && q.onError != nil {
+		go q.onError(err)
+	}
+}
+
+// run is the writer goroutine. It exits once the queue is closed and
+// drained.
+func (q *sendQueue) run() {
+	defer close(q.done)
+
+	var batch []byte
+	var flushed []chan struct{}
+	for m := range q.messages {
+		batch, flushed = q.add(batch[:0], flushed[:0], m)
+
+		// Coalesce whatever else is already queued
+	drain:
+		for len(batch) < maxSendBatch {
+			select {
+			case m, ok := <-q.messages:
+				if !ok {
+					break drain
+				}
+				batch, flushed = q.add(batch, flushed, m)
+			default:
+				break drain
+			}
+		}
+
+		_ = q.write(batch)
+		for _, c := range flushed {
+			close(c)
+		}
+	}
+}
+
+// add appends the records for m to batch. Once the writer has failed,
+// messages are discarded so blocked senders still make progress.
+func (q *sendQueue) add(batch []byte, flushed []chan struct{}, m queuedSend) ([]byte, []chan struct{}) {
+	if m.flushed != nil {
+		return batch, append(flushed, m.flushed)
+	}
+	if q.failure() != nil {
+		return batch, flushed
+	}
+
+	err := q.t.fragment(m.header, m.data, func(frame []byte) error {
+		start := len(batch)
+		var err error
+		if batch, err = q.t.appendRecord(batch, m.header, frame); err != nil {
+			return err
+		}
+		q.records++
+		if q.t.packet {
+			// Each datagram carries exactly one record
+			err = q.write(batch[start:])
+			batch = batch[:start]
+		}
+		return err
+	})
+	if err != nil {
+		q.fail(err)
+	}
+	return batch, flushed
+}
+
+// write sends batch in one write.
+func (q *sendQueue) write(batch []byte) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	records := q.records
+	q.records = 0
+	if err := q.t.writeMessages(context.Background(), batch, records); err != nil {
+		q.fail(err)
+		return err
+	}
+	q.t.recordSent()
+	return nil
+}
+
+// stopSendQueue writes the messages already queued and stops the writer. It
+// is a no-op without AsyncSend.
+func (t *Transport) stopSendQueue() {
+	if t.sendQueue != nil {
+		t.sendQueue.close()
+	}
+}
+
+// Flush waits until every message queued by Send has been written, and
+// returns the error that stopped the writer, if any. Without
+// TransportConfig.AsyncSend, Send writes before returning and Flush
+// returns nil immediately.
+func (t *Transport) Flush() error {
+	if t.sendQueue == nil {
+		return nil
+	}
+	return t.sendQueue.flush()
+}
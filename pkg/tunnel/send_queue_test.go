@@ -0,0 +1,241 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// newAsyncPipeTransports returns pipe transports whose client sends through
+// a send queue.
+func newAsyncPipeTransports(t *testing.T, config AsyncSendConfig) (*Transport, *Transport) {
+	t.Helper()
+	client, server := newPipeTransports(t)
+	client.sendQueue = newSendQueue(client, config)
+	t.Cleanup(func() {
+		_ = client.conn.Close()
+		client.stopSendQueue()
+	})
+	return client, server
+}
+
+// failingConn fails every write with err.
+type failingConn struct {
+	net.Conn
+	err error
+}
+
+func (c *failingConn) Write([]byte) (int, error) {
+	return 0, c.err
+}
+
+func TestAsyncSendBackpressure(t *testing.T) {
+	client, server := newAsyncPipeTransports(t, AsyncSendConfig{QueueSize: 2})
+
+	// Nothing reads the pipe, so the writer blocks on its first write and
+	// the queue fills up
+	accepted := 0
+	for ; accepted < 100; accepted++ {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		err := client.SendContext(ctx, []byte(fmt.Sprint(accepted)))
+		cancel()
+		if errors.Is(err, context.DeadlineExceeded) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Send %d failed: %v", accepted, err)
+		}
+	}
+	if accepted == 100 {
+		t.Fatal("Send never blocked on a full queue")
+	}
+
+	sent := make(chan error, 1)
+	go func() { sent <- client.Send([]byte("last")) }()
+	select {
+	case err := <-sent:
+		t.Fatalf("Send returned %v with the queue full", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// Reading unblocks the writer, and every queued message arrives in order
+	inbox := readMessages(server)
+	for i := range accepted {
+		_, data, err := server.openData(<-inbox)
+		if err != nil {
+			t.Fatalf("openData %d failed: %v", i, err)
+		}
+		if string(data) != fmt.Sprint(i) {
+			t.Fatalf("message %d = %q", i, data)
+		}
+	}
+	if err := <-sent; err != nil {
+		t.Fatalf("blocked Send failed: %v", err)
+	}
+	if _, data, err := server.openData(<-inbox); err != nil || string(data) != "last" {
+		t.Fatalf("last message = %q, %v", data, err)
+	}
+}
+
+func TestAsyncSendWriteError(t *testing.T) {
+	writeErr := errors.New("write failed")
+	reported := make(chan error, 2)
+	client, _ := newPipeTransports(t)
+	client.conn = &failingConn{Conn: client.conn, err: writeErr}
+	client.sendQueue = newSendQueue(client, AsyncSendConfig{OnError: func(err error) { reported <- err }})
+	defer client.stopSendQueue()
+
+	// The failure is only seen by the writer
+	if err := client.Send([]byte("lost")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	select {
+	case err := <-reported:
+		if !errors.Is(err, writeErr) {
+			t.Fatalf("OnError got %v, want %v", err, writeErr)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("OnError was not called")
+	}
+
+	if err := client.Send([]byte("next")); !errors.Is(err, writeErr) {
+		t.Errorf("Send after failure = %v, want %v", err, writeErr)
+	}
+	if err := client.Flush(); !errors.Is(err, writeErr) {
+		t.Errorf("Flush after failure = %v, want %v", err, writeErr)
+	}
+	select {
+	case err := <-reported:
+		t.Errorf("OnError called again with %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func TestAsyncSendCloseDrainsQueue(t *testing.T) {
+	client, server := newAsyncPipeTransports(t, AsyncSendConfig{QueueSize: 64})
+
+	// Collect everything the client writes until it closes the pipe
+	var mu sync.Mutex
+	var received [][]byte
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for {
+			msg, err := server.codec.ReadMessage(server.conn)
+			if err != nil {
+				return
+			}
+			mu.Lock()
+			received = append(received, msg)
+			mu.Unlock()
+		}
+	}()
+
+	const count = 50
+	for i := range count {
+		if err := client.Send([]byte(fmt.Sprint(i))); err != nil {
+			t.Fatalf("Send %d failed: %v", i, err)
+		}
+	}
+	if err := client.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	<-done
+
+	if err := client.Send([]byte("late")); !errors.Is(err, qerrors.ErrTunnelClosed) {
+		t.Errorf("Send after Close = %v, want ErrTunnelClosed", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != count+1 {
+		t.Fatalf("received %d messages, want %d data records and a close notification", len(received), count)
+	}
+	for i, msg := range received[:count] {
+		_, data, err := server.openData(msg)
+		if err != nil {
+			t.Fatalf("openData %d failed: %v", i, err)
+		}
+		if string(data) != fmt.Sprint(i) {
+			t.Fatalf("message %d = %q", i, data)
+		}
+	}
+	if msgType := protocol.MessageType(received[count][0]); msgType != protocol.MessageTypeAlert {
+		t.Errorf("last message is %s, want the close notification", msgType)
+	}
+}
+
+func TestAsyncSendOverTunnel(t *testing.T) {
+	config := DefaultTransportConfig()
+	config.AsyncSend = AsyncSendConfig{Enabled: true, QueueSize: 8}
+	config.MaxMessageSize = 256 * 1024
+
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	listener.SetConfig(config)
+
+	accepted := make(chan *Tunnel, 1)
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			t.Errorf("Accept failed: %v", err)
+		}
+		accepted <- server
+	}()
+	client, err := DialWithConfig("tcp", listener.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	server := <-accepted
+	if server == nil {
+		t.FailNow()
+	}
+	defer func() { _ = server.Close() }()
+
+	// Concurrent senders, with messages large enough to be fragmented
+	const senders, perSender = 4, 25
+	var wg sync.WaitGroup
+	for s := range senders {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perSender {
+				msg := make([]byte, 1+(i%3)*70000)
+				msg[0] = byte(s)
+				if err := client.Send(msg); err != nil {
+					t.Errorf("Send failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+
+	counts := make([]int, senders)
+	for range senders * perSender {
+		data, err := server.Receive()
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		counts[data[0]]++
+	}
+	wg.Wait()
+	if err := client.Flush(); err != nil {
+		t.Fatalf("Flush failed: %v", err)
+	}
+	for s, n := range counts {
+		if n != perSender {
+			t.Errorf("sender %d: received %d messages, want %d", s, n, perSender)
+		}
+	}
+}
@@ -10,7 +10,10 @@
 package tunnel
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
+	"encoding/binary"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -71,6 +74,18 @@ const (
 	RoleResponder
 )
 
+// String returns the role name.
+func (r Role) String() string {
+	switch r {
+	case RoleInitiator:
+		return "initiator"
+	case RoleResponder:
+		return "responder"
+	default:
+		return "unknown"
+	}
+}
+
 // Session represents a CH-KEM VPN tunnel session.
 type Session struct {
 	// Unique session identifier
@@ -88,33 +103,68 @@ type Session struct {
 	// Selected cipher suite
 	CipherSuite constants.CipherSuite
 
-	// Local key pair for this session
+	// CH-KEM parameter set used for the handshake and rekeys
+	KEMParameters chkem.Parameters
+
+	// Cipher suites offered in ClientHello, nil for protocol.DefaultCipherSuites
+	cipherSuites []constants.CipherSuite
+
+	// Local key pair for this session, nil for responders
 	LocalKeyPair *chkem.KeyPair
 
 	// Remote public key
 	RemotePublicKey *chkem.PublicKey
 
-	// Master secret derived from CH-KEM
-	masterSecret []byte
+	// Responder identity key verified during the handshake (initiator only)
+	remoteIdentity ed25519.PublicKey
+
+	// Master secret derived from CH-KEM, backed by masterSecretBuf when
+	// memory locking is enabled
+	masterSecret    []byte
+	masterSecretBuf *crypto.SecureBytes
+	lockMemory      bool
 
 	// Traffic encryption ciphers
 	sendCipher *crypto.AEAD
 	recvCipher *crypto.AEAD
 
+	// Receive cipher replaced by the last rekey, kept until the peer is seen
+	// using the new keys since its records under the old keys may still be
+	// in flight
+	prevRecvCipher *crypto.AEAD
+
+	// Lowest sequence number the current receive keys may authenticate:
+	// one past the highest record accepted under the keys they replaced
+	recvEpochSeq uint64
+
+	// Whether traffic ciphers commit to their keys (see crypto.NewCommittingAEAD)
+	keyCommitment bool
+
 	// Sequence numbers
 	sendSeq atomic.Uint64
-	recvSeq atomic.Uint64 //nolint:unused // Reserved for future bidirectional validation
+	recvSeq atomic.Uint64 // One past the highest sequence number received
 
 	// Replay protection window
 	replayWindow *ReplayWindow
 
 	// Timestamps
 	CreatedAt     time.Time
-	EstablishedAt time.Time
+	EstablishedAt time.Time // Reset by rekeys
 	LastActivity  time.Time
 
+	// Time the handshake completed
+	handshakeAt time.Time
+
 	// Observability hooks
 	observer Observer
+	logger   Logger
+	events   eventBus
+
+	// Handshake span context and its end callback. The responder defers
+	// starting the span until the ClientHello carries the parent.
+	traceCtx      context.Context
+	traceDone     func(error)
+	traceDeferred bool
 
 	// Statistics
 	BytesSent     atomic.Int64
@@ -122,97 +172,343 @@ type Session struct {
 	PacketsSent   atomic.Int64
 	PacketsRecv   atomic.Int64
 
-	// Handshake transcript for key derivation
-	transcriptHash []byte //nolint:unused // Reserved for future session verification
+	// Handshake transcript hash, bound into exported keying material
+	transcriptHash []byte
+
+	// Exporter secret derived once at handshake completion; unaffected by rekeys
+	exporterSecret []byte
+
+	// Secret a later abbreviated handshake can resume this session from
+	resumptionSecret []byte
+
+	// Whether the session was established by an abbreviated handshake
+	resumed bool
+
+	// 0-RTT early data: the data the responder accepted (nil on initiators)
+	// and whether the responder accepted the initiator's early data
+	earlyData         []byte
+	earlyDataAccepted bool
+
+	// Record compression and padding agreed in the handshake, valid if
+	// recordsNegotiated is set
+	recordCompression Compression
+	recordPadding     bool
+	recordsNegotiated bool
 
 	// Rekey state
+	rekeyPolicy         RekeyPolicy
+	keyBytesBase        int64 // BytesSent when the current keys were installed
+	keyPacketsBase      int64 // PacketsSent when the current keys were installed
 	rekeyInProgress     bool
-	pendingRekeyKeyPair *chkem.KeyPair // New keypair for initiator
-	pendingRekeySecret  []byte         // Pending shared secret for responder
-	rekeyActivationSeq  uint64         // Sequence number when new keys activate
+	pendingRekeyKeyPair *chkem.KeyPair // New keypair while awaiting a rekey response
+	pendingRekeySecret  []byte         // Pending shared secret until activation
+	rekeyActivationSeq  uint64         // Send sequence number when the new send keys activate
+	rekeyDirection      RekeyDirection // Local directions the pending rekey rotates
 	pendingRecvCipher   *crypto.AEAD   // New receive cipher waiting for activation
-	pendingSendCipher   *crypto.AEAD   // New send cipher waiting for activation (initiator)
+	pendingSendCipher   *crypto.AEAD   // New send cipher waiting for activation
+	ratchetPending      bool           // The pending keys come from a ratchet
+	abandonedRekeySeq   uint64         // Activation sequence of the last request abandoned unanswered
 
 	// Mutex for state changes
 	mu sync.RWMutex
 }
 
 // ReplayWindow implements a sliding window for replay attack protection.
+//
+// The bitmap is a ring of 64-bit words indexed by sequence number. One word
+// more than the window size is allocated so that advancing the window only
+// has to clear the words that the new high sequence number moves into,
+// without disturbing bits that are still inside the window.
 type ReplayWindow struct {
 	mu         sync.Mutex
 	highSeq    uint64
-	bitmap     uint64 // Bitmap for last 64 sequence numbers
+	bitmap     []uint64 // Ring bitmap covering windowSize+64 sequence numbers
 	windowSize uint64
+	stats      ReplayStats
+}
+
+// ReplayStats counts the sequence numbers checked by a ReplayWindow.
+type ReplayStats struct {
+	// Accepted counts sequence numbers that were not replays
+	Accepted uint64
+
+	// RejectedOld counts sequence numbers too far behind the window. Like
+	// RejectedDuplicate, it includes records turned away before they were
+	// authenticated; the window itself only moves for authenticated records
+	RejectedOld uint64
+
+	// RejectedDuplicate counts sequence numbers already seen within the window
+	RejectedDuplicate uint64
+
+	// MaxForwardJump is the largest advance of the highest sequence number
+	// by a single packet, a measure of loss and reordering on the path
+	MaxForwardJump uint64
 }
 
-// NewReplayWindow creates a new replay protection window.
+// Replay rejection reasons reported to ReplayRejectionObserver.
+const (
+	// ReplayRejectedOld means the sequence number was behind the window.
+	ReplayRejectedOld = "old"
+
+	// ReplayRejectedDuplicate means the sequence number was already seen.
+	ReplayRejectedDuplicate = "duplicate"
+
+	// ReplayRejectedKeyEpoch means the sequence number does not belong to
+	// the keys the record was sealed under: a record under the new keys of
+	// a rekey numbered below one accepted under the old keys, or a record
+	// under the old keys after the peer switched.
+	ReplayRejectedKeyEpoch = "key_epoch"
+)
+
+// NewReplayWindow creates a new replay protection window of the default size.
 func NewReplayWindow() *ReplayWindow {
+	return NewReplayWindowWithSize(constants.DefaultReplayWindowSize)
+}
+
+// NewReplayWindowWithSize creates a replay protection window tracking the
+// given number of sequence numbers. The size is rounded up to a multiple
+// of 64; zero selects the default size.
+func NewReplayWindowWithSize(size uint64) *ReplayWindow {
+	if size == 0 {
+		size = constants.DefaultReplayWindowSize
+	}
+	words := (size + 63) / 64
 	return &ReplayWindow{
 		highSeq:    0,
-		bitmap:     0,
-		windowSize: 64,
+		bitmap:     make([]uint64, words+1),
+		windowSize: words * 64,
 	}
 }
 
-// Check validates a sequence number against the replay window.
-// Returns true if the sequence number is valid (not a replay).
+// Size returns the number of sequence numbers tracked by the window.
+func (rw *ReplayWindow) Size() uint64 {
+	return rw.windowSize
+}
+
+// Stats returns the window's counters.
+func (rw *ReplayWindow) Stats() ReplayStats {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	return rw.stats
+}
+
+// Check validates a sequence number against the replay window and records
+// it as seen. Returns true if the sequence number is valid (not a replay).
 func (rw *ReplayWindow) Check(seq uint64) bool {
+	return rw.commit(seq) == ""
+}
+
+// check reports whether seq would be accepted, returning "" or the
+// ReplayRejected reason, without recording it or counting it in the stats.
+// Records are checked before they are authenticated and only committed
+// after, so a forged record cannot move the window.
+func (rw *ReplayWindow) check(seq uint64) string {
 	rw.mu.Lock()
 	defer rw.mu.Unlock()
+	return rw.rejectLocked(seq)
+}
 
-	// Sequence number is too old
-	if seq+rw.windowSize <= rw.highSeq {
-		return false
+// reject counts a sequence number turned away by check for reason.
+func (rw *ReplayWindow) reject(reason string) {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+	rw.countLocked(reason)
+}
+
+// commit records seq as seen, returning "" or, if seq was committed
+// meanwhile or has fallen behind the window, the ReplayRejected reason.
+func (rw *ReplayWindow) commit(seq uint64) string {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	if reason := rw.rejectLocked(seq); reason != "" {
+		rw.countLocked(reason)
+		return reason
 	}
 
+	numWords := uint64(len(rw.bitmap))
+	word, bit := rw.position(seq)
+
 	// Sequence number is within the window
 	if seq <= rw.highSeq {
-		diff := rw.highSeq - seq
-		var bit uint64 = 1
-		bit <<= diff
-		if rw.bitmap&bit != 0 {
-			return false // Already received
-		}
-		rw.bitmap |= bit
-		return true
+		rw.bitmap[word] |= bit
+		rw.stats.Accepted++
+		return ""
 	}
 
-	// New highest sequence number
-	if seq > rw.highSeq {
-		diff := seq - rw.highSeq
-		if diff >= rw.windowSize {
-			rw.bitmap = 0
-		} else {
-			rw.bitmap <<= diff
-		}
-		rw.bitmap |= 1
-		rw.highSeq = seq
+	// New highest sequence number: clear only the words being entered
+	current := rw.highSeq / 64
+	diff := seq/64 - current
+	if diff > numWords {
+		diff = numWords
+	}
+	for i := uint64(1); i <= diff; i++ {
+		rw.bitmap[(current+i)%numWords] = 0
 	}
+	rw.bitmap[word] |= bit
+	rw.stats.MaxForwardJump = max(rw.stats.MaxForwardJump, seq-rw.highSeq)
+	rw.stats.Accepted++
+	rw.highSeq = seq
 
-	return true
+	return ""
+}
+
+// rejectLocked returns the ReplayRejected reason if seq is behind the
+// window or already seen, or "". Must hold rw.mu.
+func (rw *ReplayWindow) rejectLocked(seq uint64) string {
+	// Sequence number is too old
+	if rw.highSeq >= rw.windowSize && seq <= rw.highSeq-rw.windowSize {
+		return ReplayRejectedOld
+	}
+
+	// Sequence number is within the window and already seen
+	word, bit := rw.position(seq)
+	if seq <= rw.highSeq && rw.bitmap[word]&bit != 0 {
+		return ReplayRejectedDuplicate
+	}
+	return ""
+}
+
+// countLocked counts a rejection for reason. Must hold rw.mu.
+func (rw *ReplayWindow) countLocked(reason string) {
+	switch reason {
+	case ReplayRejectedOld:
+		rw.stats.RejectedOld++
+	case ReplayRejectedDuplicate:
+		rw.stats.RejectedDuplicate++
+	}
+}
+
+// position returns the bitmap word and bit of seq.
+func (rw *ReplayWindow) position(seq uint64) (uint64, uint64) {
+	index := seq % (uint64(len(rw.bitmap)) * 64)
+	return index / 64, 1 << (index % 64)
+}
+
+// SessionConfig holds optional parameters for a new session.
+type SessionConfig struct {
+	// ReplayWindowSize is the number of sequence numbers tracked for replay
+	// protection. Larger windows tolerate more reordering on high-latency
+	// links. Rounded up to a multiple of 64.
+	// Default: 64
+	ReplayWindowSize uint64
+
+	// KEMParameters selects the CH-KEM parameter set for the local key pair.
+	// The initiator's choice is carried in ClientHello and accepted by the
+	// responder, so this only has an effect on initiators.
+	// Default: chkem.CHKEM1024
+	KEMParameters chkem.Parameters
+
+	// CipherSuites lists the cipher suites offered in ClientHello, in order
+	// of preference. Like KEMParameters, this only has an effect on
+	// initiators; responders select from protocol.SupportedCipherSuites.
+	// Default: protocol.DefaultCipherSuites()
+	CipherSuites []constants.CipherSuite
+
+	// RekeyPolicy sets when NeedsRekey reports that the session's keys
+	// should be replaced. It must be valid for every offered cipher suite.
+	// Default: DefaultRekeyPolicy()
+	RekeyPolicy RekeyPolicy
+
+	// EventBufferSize is the number of events queued for each OnEvent
+	// subscriber. Events arriving while a subscriber's queue is full are
+	// dropped.
+	// Default: 64
+	EventBufferSize int
+}
+
+// DefaultSessionConfig returns a SessionConfig with sensible defaults.
+func DefaultSessionConfig() SessionConfig {
+	return SessionConfig{
+		ReplayWindowSize: constants.DefaultReplayWindowSize,
+		KEMParameters:    chkem.DefaultParameters,
+		RekeyPolicy:      DefaultRekeyPolicy(),
+	}
 }
 
 // NewSession creates a new session with the given role.
 func NewSession(role Role) (*Session, error) {
+	return NewSessionWithConfig(role, DefaultSessionConfig())
+}
+
+// NewSessionWithConfig creates a new session with the given role and configuration.
+func NewSessionWithConfig(role Role, cfg SessionConfig) (*Session, error) {
+	return newSession(role, cfg, nil)
+}
+
+// NewSessionWithKeyPair creates a new session that uses keyPair as its
+// ephemeral key pair instead of generating one, so that latency-sensitive
+// clients can generate key pairs ahead of time. The session's CH-KEM
+// parameter set is that of keyPair.
+//
+// keyPair is consumed: reusing it across sessions would break forward
+// secrecy, so a key pair that was already used fails with
+// ErrKeyPairConsumed. The session zeroizes it when closed.
+func NewSessionWithKeyPair(role Role, keyPair *chkem.KeyPair) (*Session, error) {
+	return newSession(role, DefaultSessionConfig(), keyPair)
+}
+
+// newSession creates a session using keyPair, or a fresh key pair if nil.
+func newSession(role Role, cfg SessionConfig, keyPair *chkem.KeyPair) (*Session, error) {
 	// Generate session ID
 	sessionID, err := crypto.SecureRandomBytes(constants.SessionIDSize)
 	if err != nil {
 		return nil, err
 	}
 
-	// Generate local key pair
-	keyPair, err := chkem.GenerateKeyPair()
-	if err != nil {
-		return nil, err
+	kemParams := cfg.KEMParameters
+	if keyPair != nil {
+		kemParams = keyPair.Parameters()
+	}
+	if kemParams == 0 {
+		kemParams = chkem.DefaultParameters
 	}
 
-	s := &Session{
-		ID:           sessionID,
-		Role:         role,
-		LocalKeyPair: keyPair,
-		replayWindow: NewReplayWindow(),
-		CreatedAt:    time.Now(),
+	for _, suite := range cfg.CipherSuites {
+		if !containsCipherSuite(protocol.SupportedCipherSuites(), suite) {
+			return nil, qerrors.ErrUnsupportedCipherSuite
+		}
+	}
+
+	// The cipher suite is negotiated later, so the policy must suit all
+	// of those that may be chosen
+	suites := cfg.CipherSuites
+	if len(suites) == 0 {
+		suites = protocol.SupportedCipherSuites()
 	}
+	for _, suite := range suites {
+		if err := cfg.RekeyPolicy.Validate(suite); err != nil {
+			return nil, err
+		}
+	}
+
+	// Generate local key pair, unless one was pre-generated. The responder
+	// only encapsulates to the initiator's key, so it skips the key
+	// generation that would otherwise run for every connection before the
+	// cookie and authorizer checks
+	switch {
+	case keyPair != nil:
+		if !keyPair.Consume() {
+			return nil, qerrors.ErrKeyPairConsumed
+		}
+	case role == RoleInitiator:
+		keyPair, err = chkem.GenerateKeyPairWithParameters(kemParams)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Session{
+		ID:            sessionID,
+		Role:          role,
+		KEMParameters: kemParams,
+		LocalKeyPair:  keyPair,
+		cipherSuites:  append([]constants.CipherSuite(nil), cfg.CipherSuites...),
+		replayWindow:  NewReplayWindowWithSize(cfg.ReplayWindowSize),
+		rekeyPolicy:   cfg.RekeyPolicy.withDefaults(),
+		CreatedAt:     time.Now(),
+	}
+	s.events.bufferSize = cfg.EventBufferSize
 	s.state.Store(int32(SessionStateNew))
 
 	return s, nil
@@ -234,8 +530,60 @@ func (s *Session) SetObserver(observer Observer) {
 	s.observer = observer
 }
 
+// SetKeyCommitment makes the session's traffic ciphers key-committing.
+// Both peers must use the same setting, or the handshake fails with
+// ErrKeyCommitmentMismatch. Should be called before the handshake.
+func (s *Session) SetKeyCommitment(enabled bool) {
+	s.keyCommitment = enabled
+}
+
+// SetLockMemory makes the session keep its master secret in memory locked
+// against swapping (see crypto.SecureBytes). Where locking is unavailable the
+// secret is kept unlocked and a warning is logged. Should be called before
+// the handshake.
+func (s *Session) SetLockMemory(enabled bool) {
+	s.lockMemory = enabled
+}
+
+// setMasterSecret replaces the master secret with a copy of secret, in
+// locked memory if enabled by SetLockMemory. A failure to lock is logged
+// for the first secret only, so rekeys do not repeat the warning. Must hold
+// s.mu.
+func (s *Session) setMasterSecret(secret []byte) {
+	first := s.masterSecret == nil
+	s.clearMasterSecret()
+	if !s.lockMemory {
+		s.masterSecret = append([]byte(nil), secret...)
+		return
+	}
+
+	buf := crypto.NewSecureBytes(len(secret))
+	copy(buf.Bytes(), secret)
+	s.masterSecretBuf = buf
+	s.masterSecret = buf.Bytes()
+	if err := buf.LockErr(); err != nil && first && s.logger != nil {
+		s.logger.Named("memory").Warn("memory lock unavailable, master secret may be swapped to disk", s.logFields(map[string]any{
+			"error": err.Error(),
+		}))
+	}
+}
+
+// clearMasterSecret zeroizes and releases the master secret. Must hold s.mu.
+func (s *Session) clearMasterSecret() {
+	crypto.Zeroize(s.masterSecret)
+	s.masterSecretBuf.Free()
+	s.masterSecret = nil
+	s.masterSecretBuf = nil
+}
+
 // InitializeKeys derives and sets up encryption keys from the master secret.
 func (s *Session) InitializeKeys(masterSecret []byte, cipherSuite constants.CipherSuite) error {
+	return s.initializeKeys(masterSecret, cipherSuite, nil)
+}
+
+// initializeKeys sets up encryption keys and records the handshake
+// transcript hash for later use by ExporterSecret.
+func (s *Session) initializeKeys(masterSecret []byte, cipherSuite constants.CipherSuite, transcriptHash []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -248,57 +596,52 @@ func (s *Session) InitializeKeys(masterSecret []byte, cipherSuite constants.Ciph
 	}
 
 	// Store master secret
-	s.masterSecret = make([]byte, len(masterSecret))
-	copy(s.masterSecret, masterSecret)
+	s.setMasterSecret(masterSecret)
 	s.CipherSuite = cipherSuite
 
-	// Derive traffic keys
-	initiatorKey, responderKey, err := crypto.DeriveTrafficKeys(masterSecret)
+	// Derive the exporter secret from the handshake master secret and
+	// transcript so exported values stay stable across rekeys
+	s.transcriptHash = append([]byte(nil), transcriptHash...)
+	exporterInput := make([]byte, 0, len(masterSecret)+len(transcriptHash))
+	exporterInput = append(exporterInput, masterSecret...)
+	exporterInput = append(exporterInput, transcriptHash...)
+	exporterSecret, err := crypto.DeriveKey(constants.DomainSeparatorExporter, exporterInput, constants.KDFOutputSize)
+	crypto.Zeroize(exporterInput)
 	if err != nil {
 		return err
 	}
-
-	// Set up ciphers based on role
-	var sendKey, recvKey []byte
-	if s.Role == RoleInitiator {
-		sendKey = initiatorKey
-		recvKey = responderKey
-	} else {
-		sendKey = responderKey
-		recvKey = initiatorKey
-	}
-
-	s.sendCipher, err = crypto.NewAEAD(cipherSuite, sendKey)
-	if err != nil {
-		return err
+	if s.exporterSecret != nil {
+		crypto.Zeroize(s.exporterSecret)
 	}
+	s.exporterSecret = exporterSecret
 
-	s.recvCipher, err = crypto.NewAEAD(cipherSuite, recvKey)
+	// Derive traffic keys and set up ciphers based on role
+	s.sendCipher, s.recvCipher, err = s.trafficCiphers(masterSecret)
 	if err != nil {
 		return err
 	}
 
-	// Zeroize key material (sendKey/recvKey are aliases to initiatorKey/responderKey)
-	crypto.ZeroizeMultiple(initiatorKey, responderKey)
-
-	s.EstablishedAt = time.Now()
+	s.resetRekeyLimits()
+	s.handshakeAt = s.EstablishedAt
 	s.SetState(SessionStateEstablished)
 
 	return nil
 }
 
-// Encrypt encrypts data for sending.
+// Encrypt encrypts data for sending. Each call uses up a sequence number;
+// once constants.MaxSequenceNumber are used, Encrypt fails with
+// ErrSequenceExhausted. Rekeying does not reset sequence numbers, so the
+// session must then be replaced. Rekeys triggered by the RekeyPolicy keep
+// each key well within its own nonce limit long before that.
 func (s *Session) Encrypt(plaintext []byte) ([]byte, uint64, error) {
-	// Get the sequence number first
-	seq := s.sendSeq.Add(1) - 1
-
-	// Check if we need to activate pending send cipher at this sequence
-	s.checkAndActivateSendCipher(seq)
-
-	// Now get the current send cipher (potentially just activated)
-	s.mu.RLock()
-	cipher := s.sendCipher
-	s.mu.RUnlock()
+	// Get the sequence number and the cipher sealing it
+	seq, cipher, err := s.reserveSendSeq()
+	if err != nil {
+		if s.observer != nil {
+			s.observer.OnProtocolError(err)
+		}
+		return nil, 0, err
+	}
 
 	observer := s.observer
 	var done func(error)
@@ -317,14 +660,9 @@ func (s *Session) Encrypt(plaintext []byte) ([]byte, uint64, error) {
 	}
 
 	// Use sequence number as additional authenticated data
-	aad := make([]byte, 8)
-	seqCopy := seq
-	for i := 7; i >= 0; i-- {
-		aad[i] = byte(seqCopy)
-		seqCopy >>= 8
-	}
+	aad := recordAAD(seq)
 
-	ciphertext, err := cipher.Seal(plaintext, aad)
+	ciphertext, err := cipher.SealSeq(seq, plaintext, aad)
 	if err != nil {
 		if done != nil {
 			done(err)
@@ -344,10 +682,67 @@ func (s *Session) Encrypt(plaintext []byte) ([]byte, uint64, error) {
 	return ciphertext, seq, nil
 }
 
+// nextSendSeq reserves the next send sequence number. It never wraps
+// around: once MaxSequenceNumber is reached it fails with
+// ErrSequenceExhausted.
+func (s *Session) nextSendSeq() (uint64, error) {
+	for {
+		seq := s.sendSeq.Load()
+		if seq >= constants.MaxSequenceNumber {
+			return 0, qerrors.ErrSequenceExhausted
+		}
+		if s.sendSeq.CompareAndSwap(seq, seq+1) {
+			return seq, nil
+		}
+	}
+}
+
+// reserveSendSeq reserves the next send sequence number and returns the
+// cipher to seal it with, activating pending send keys once the activation
+// sequence is reached. The number is reserved under s.mu, so keys only
+// change between reservations: every record sealed under new keys is
+// numbered above every record sealed under the keys they replaced, which
+// the peer's decrypt relies on.
+func (s *Session) reserveSendSeq() (uint64, *crypto.AEAD, error) {
+	s.mu.RLock()
+	seq, err := s.nextSendSeq()
+	cipher := s.sendCipher
+	activate := s.rekeyInProgress && s.pendingSendCipher != nil && seq >= s.rekeyActivationSeq
+	s.mu.RUnlock()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if activate {
+		s.checkAndActivateSendCipher(seq)
+		s.mu.RLock()
+		cipher = s.sendCipher
+		s.mu.RUnlock()
+	}
+	return seq, cipher, nil
+}
+
+// recordAAD returns the additional authenticated data of a record: its
+// sequence number.
+func recordAAD(seq uint64) []byte {
+	var aad [8]byte
+	binary.BigEndian.PutUint64(aad[:], seq)
+	return aad[:]
+}
+
 // Decrypt decrypts received data.
 func (s *Session) Decrypt(ciphertext []byte, seq uint64) ([]byte, error) {
+	return s.decrypt(nil, ciphertext, seq)
+}
+
+// decrypt decrypts received data and appends the plaintext to dst.
+func (s *Session) decrypt(dst, ciphertext []byte, seq uint64) ([]byte, error) {
+	// Check if the peer has switched to the pending keys
+	s.checkAndActivateRecvCipher(ciphertext, seq)
+
 	s.mu.RLock()
 	cipher := s.recvCipher
+	prev := s.prevRecvCipher
 	s.mu.RUnlock()
 
 	if cipher == nil {
@@ -357,12 +752,11 @@ func (s *Session) Decrypt(ciphertext []byte, seq uint64) ([]byte, error) {
 		return nil, qerrors.ErrInvalidState
 	}
 
-	// Check replay window
-	if !s.replayWindow.Check(seq) {
-		if s.observer != nil {
-			s.observer.OnReplayDetected()
-		}
-		return nil, qerrors.ErrReplayDetected
+	// Check replay window; the sequence number is committed once the
+	// record authenticates
+	if reason := s.replayWindow.check(seq); reason != "" {
+		s.replayWindow.reject(reason)
+		return nil, s.rejectReplay(seq, reason)
 	}
 
 	observer := s.observer
@@ -372,14 +766,18 @@ func (s *Session) Decrypt(ciphertext []byte, seq uint64) ([]byte, error) {
 	}
 
 	// Use sequence number as additional authenticated data
-	aad := make([]byte, 8)
-	seqCopy := seq
-	for i := 7; i >= 0; i-- {
-		aad[i] = byte(seqCopy)
-		seqCopy >>= 8
+	aad := recordAAD(seq)
+
+	plaintext, err := cipher.OpenSeqTo(dst, seq, ciphertext, aad)
+	if err != nil && prev != nil {
+		// The peer may not have switched to the new keys yet
+		plaintext, err = prev.OpenSeqTo(dst, seq, ciphertext, aad)
+		if err == nil && !s.acceptKeyEpoch(prev, seq) {
+			return nil, s.rejectReplay(seq, ReplayRejectedKeyEpoch)
+		}
+	} else if err == nil && !s.acceptKeyEpoch(cipher, seq) {
+		return nil, s.rejectReplay(seq, ReplayRejectedKeyEpoch)
 	}
-
-	plaintext, err := cipher.Open(ciphertext, aad)
 	if err != nil {
 		if observer != nil {
 			if qerrors.Is(err, qerrors.ErrAuthenticationFailed) {
@@ -395,8 +793,19 @@ func (s *Session) Decrypt(ciphertext []byte, seq uint64) ([]byte, error) {
 		done(nil)
 	}
 
+	// Only an authenticated record moves the replay window. A copy that
+	// raced this one through the check is rejected here
+	if reason := s.replayWindow.commit(seq); reason != "" {
+		return nil, s.rejectReplay(seq, reason)
+	}
+
 	s.BytesReceived.Add(int64(len(plaintext)))
 	s.PacketsRecv.Add(1)
+	for next := s.recvSeq.Load(); seq >= next; next = s.recvSeq.Load() {
+		if s.recvSeq.CompareAndSwap(next, seq+1) {
+			break
+		}
+	}
 	s.mu.Lock()
 	s.LastActivity = time.Now()
 	s.mu.Unlock()
@@ -404,36 +813,96 @@ func (s *Session) Decrypt(ciphertext []byte, seq uint64) ([]byte, error) {
 	return plaintext, nil
 }
 
+// rejectReplay reports a record rejected for reason and returns
+// ErrReplayDetected.
+func (s *Session) rejectReplay(seq uint64, reason string) error {
+	s.emit(Event{Type: EventReplayBlocked, Seq: seq, Reason: reason})
+	if s.observer != nil {
+		s.observer.OnReplayDetected()
+		if o, ok := s.observer.(ReplayRejectionObserver); ok {
+			o.OnReplayRejected(reason)
+		}
+	}
+	return qerrors.ErrReplayDetected
+}
+
+// acceptKeyEpoch reports whether a record numbered seq that authenticated
+// under cipher belongs to that cipher's key epoch. Sequence numbers
+// continue across rekeys and the peer numbers every record under new keys
+// above those under the old, so an epoch is a contiguous range: records
+// under the current keys must be numbered at or above recvEpochSeq, and
+// records under the replaced keys are only accepted until the peer is seen
+// using the new ones. The first record under the new keys retires the old.
+func (s *Session) acceptKeyEpoch(cipher *crypto.AEAD, seq uint64) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case cipher == s.recvCipher:
+		if seq < s.recvEpochSeq {
+			return false
+		}
+		s.prevRecvCipher = nil
+		return true
+	case cipher == s.prevRecvCipher:
+		s.recvEpochSeq = max(s.recvEpochSeq, seq+1)
+		return true
+	default:
+		// Retired, or replaced by another rekey meanwhile
+		return false
+	}
+}
+
 // NeedsRekey returns true if the session should initiate rekeying.
 func (s *Session) NeedsRekey() bool {
+	return s.needsRekey(s.rekeyPolicy)
+}
+
+// RemainingCapacity returns how many more records the current send keys
+// can encrypt before nonce exhaustion forces a rekey, or 0 before keys are
+// installed. It restarts from the full capacity after each rekey.
+func (s *Session) RemainingCapacity() uint64 {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	if s.sendCipher == nil {
-		return false
+		return 0
 	}
+	return s.sendCipher.RemainingCapacity()
+}
 
-	// Check nonce exhaustion
-	if s.sendCipher.NeedsRekey() {
-		return true
-	}
+// nearRekey reports whether the session's keys have used up fraction of
+// the rekey policy's limits, or need a rekey regardless of the policy.
+func (s *Session) nearRekey(fraction float64) bool {
+	return s.needsRekey(s.rekeyPolicy.scaled(fraction))
+}
 
-	// Check byte limit
-	if s.BytesSent.Load() >= constants.MaxBytesBeforeRekey {
-		return true
-	}
+// needsRekey implements NeedsRekey against the given policy.
+func (s *Session) needsRekey(policy RekeyPolicy) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
-	// Check packet limit
-	if s.PacketsSent.Load() >= constants.MaxPacketsBeforeRekey {
-		return true
+	if s.sendCipher == nil {
+		return false
 	}
 
-	// Check time limit
-	if time.Since(s.EstablishedAt).Seconds() >= float64(constants.MaxSessionDurationSeconds) {
+	// Check nonce exhaustion
+	if s.sendCipher.NeedsRekey() {
 		return true
 	}
 
-	return false
+	// Check the policy's volume and time limits
+	//nolint:gosec // G115: counters only grow, so the differences are non-negative
+	bytesSent, packetsSent := uint64(s.BytesSent.Load()-s.keyBytesBase), uint64(s.PacketsSent.Load()-s.keyPacketsBase)
+	return policy.exceeded(bytesSent, packetsSent, s.EstablishedAt)
+}
+
+// resetRekeyLimits restarts the rekey policy's limits for newly installed
+// keys. The caller must hold s.mu.
+func (s *Session) resetRekeyLimits() {
+	s.EstablishedAt = time.Now()
+	s.keyBytesBase = s.BytesSent.Load()
+	s.keyPacketsBase = s.PacketsSent.Load()
 }
 
 // Rekey performs a session rekey operation.
@@ -445,47 +914,24 @@ func (s *Session) Rekey(newMasterSecret []byte) error {
 		return qerrors.ErrInvalidKeySize
 	}
 
-	// Derive new traffic keys
-	initiatorKey, responderKey, err := crypto.DeriveTrafficKeys(newMasterSecret)
-	if err != nil {
-		return err
-	}
-
-	// Set up new ciphers
-	var sendKey, recvKey []byte
-	if s.Role == RoleInitiator {
-		sendKey = initiatorKey
-		recvKey = responderKey
-	} else {
-		sendKey = responderKey
-		recvKey = initiatorKey
-	}
-
-	newSendCipher, err := crypto.NewAEAD(s.CipherSuite, sendKey)
-	if err != nil {
-		return err
-	}
-
-	newRecvCipher, err := crypto.NewAEAD(s.CipherSuite, recvKey)
+	// Derive new traffic keys and set up new ciphers
+	newSendCipher, newRecvCipher, err := s.trafficCiphers(newMasterSecret)
 	if err != nil {
 		return err
 	}
 
-	// Atomically swap ciphers
+	// Atomically swap ciphers. Sequence numbers continue, so the replay
+	// window carries over
 	s.sendCipher = newSendCipher
 	s.recvCipher = newRecvCipher
+	s.prevRecvCipher = nil
+	s.recvEpochSeq = s.recvSeq.Load()
 
 	// Update master secret
-	crypto.Zeroize(s.masterSecret)
-	s.masterSecret = make([]byte, len(newMasterSecret))
-	copy(s.masterSecret, newMasterSecret)
-
-	// Zeroize key material (sendKey/recvKey are aliases to initiatorKey/responderKey)
-	crypto.ZeroizeMultiple(initiatorKey, responderKey)
+	s.setMasterSecret(newMasterSecret)
 
 	// Reset counters
-	s.replayWindow = NewReplayWindow()
-	s.EstablishedAt = time.Now()
+	s.resetRekeyLimits()
 
 	return nil
 }
@@ -531,17 +977,148 @@ func (s *Session) Resume(ticketBytes []byte, tm *TicketManager) ([]byte, error)
 	return ticket.MasterSecret, nil
 }
 
+// ExporterSecret derives length bytes of keying material bound to this
+// session, for use in channel binding by higher-level protocols.
+//
+// Both peers derive the same value for the same label. The result depends on
+// the handshake master secret and transcript hash, so it is unique per
+// session and does not change when the session rekeys.
+func (s *Session) ExporterSecret(label string, length int) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.exporterSecret == nil {
+		return nil, qerrors.ErrInvalidState
+	}
+
+	input := make([]byte, 0, len(s.exporterSecret)+len(s.transcriptHash)+len(label))
+	input = append(input, s.exporterSecret...)
+	input = append(input, s.transcriptHash...)
+	input = append(input, label...)
+	defer crypto.Zeroize(input)
+
+	return crypto.DeriveKey(constants.DomainSeparatorExporter, input, length)
+}
+
+// ResumptionSecret returns the secret an initiator passes to
+// InitiatorResumptionHandshake, together with the session ID, to resume this
+// session against a responder with a ResumptionStore.
+func (s *Session) ResumptionSecret() ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.resumptionSecret == nil {
+		return nil, qerrors.ErrInvalidState
+	}
+	return append([]byte(nil), s.resumptionSecret...), nil
+}
+
+// RemoteFingerprint returns the fingerprint of the peer's CH-KEM public key,
+// for comparison against a pinned value. It returns false if the peer has
+// not sent a public key: in the current handshake only the initiator sends
+// one, so responders see the initiator's ephemeral key and initiators see
+// nothing.
+func (s *Session) RemoteFingerprint() ([chkem.FingerprintSize]byte, bool) {
+	if s.RemotePublicKey == nil {
+		return [chkem.FingerprintSize]byte{}, false
+	}
+	return s.RemotePublicKey.Fingerprint(), true
+}
+
+// RemoteIdentity returns the responder identity key that signed the
+// handshake transcript. It returns nil on responders and when the responder
+// did not authenticate.
+func (s *Session) RemoteIdentity() ed25519.PublicKey {
+	return s.remoteIdentity
+}
+
+// Resumed reports whether the session was established by an abbreviated
+// handshake from a ResumptionStore entry.
+func (s *Session) Resumed() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resumed
+}
+
+// EarlyDataAccepted reports whether the responder accepted the 0-RTT early
+// data sent with the ClientHello.
+func (s *Session) EarlyDataAccepted() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.earlyDataAccepted
+}
+
+// EarlyData returns the 0-RTT early data a responder accepted, or nil. It is
+// always nil on initiators. Early data may be a replay of a first flight
+// sent to another listener (see resumption.go), so only act on it if doing
+// so twice is harmless.
+func (s *Session) EarlyData() []byte {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.earlyData
+}
+
+// setEarlyData records the outcome of 0-RTT early data.
+func (s *Session) setEarlyData(data []byte, accepted bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.earlyData = data
+	s.earlyDataAccepted = accepted
+}
+
+// setRecordOptions records the record compression and padding agreed in
+// the handshake.
+func (s *Session) setRecordOptions(compression Compression, padding bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.recordCompression = compression
+	s.recordPadding = padding
+	s.recordsNegotiated = true
+}
+
+// recordOptions returns the record compression and padding agreed in the
+// handshake. ok is false if the handshake did not negotiate them.
+func (s *Session) recordOptions() (compression Compression, padding, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.recordCompression, s.recordPadding, s.recordsNegotiated
+}
+
+// setResumption records the session's resumption secret and whether it was
+// itself resumed.
+func (s *Session) setResumption(secret []byte, resumed bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.resumptionSecret != nil {
+		crypto.Zeroize(s.resumptionSecret)
+	}
+	s.resumptionSecret = secret
+	s.resumed = resumed
+}
+
 // Close securely closes the session and zeroizes sensitive data.
 func (s *Session) Close() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	s.closeLocked()
+}
 
+// closeLocked implements Close. Must hold s.mu.
+func (s *Session) closeLocked() {
 	s.SetState(SessionStateClosed)
 
 	// Zeroize sensitive data
-	if s.masterSecret != nil {
-		crypto.Zeroize(s.masterSecret)
-		s.masterSecret = nil
+	s.clearMasterSecret()
+
+	if s.exporterSecret != nil {
+		crypto.Zeroize(s.exporterSecret)
+		s.exporterSecret = nil
+	}
+
+	if s.resumptionSecret != nil {
+		crypto.Zeroize(s.resumptionSecret)
+		s.resumptionSecret = nil
 	}
 
 	if s.LocalKeyPair != nil {
@@ -551,6 +1128,9 @@ func (s *Session) Close() {
 
 	s.sendCipher = nil
 	s.recvCipher = nil
+	s.prevRecvCipher = nil
+
+	s.emit(Event{Type: EventClosed})
 }
 
 // Stats returns session statistics.
@@ -563,6 +1143,7 @@ type Stats struct {
 	State         SessionState
 	CipherSuite   constants.CipherSuite
 	FIPSMode      bool
+	Replay        ReplayStats // Cumulative across rekeys
 }
 
 // Stats returns current session statistics.
@@ -576,6 +1157,7 @@ func (s *Session) Stats() Stats {
 		State:         s.State(),
 		CipherSuite:   s.CipherSuite,
 		FIPSMode:      crypto.FIPSMode(),
+		Replay:        s.replayWindow.Stats(),
 	}
 }
 
@@ -587,12 +1169,79 @@ func (s *Session) IsFIPSCompliant() bool {
 
 // --- Rekey Protocol Methods ---
 
-// InitiateRekey starts a rekey operation (called by initiator).
-// Returns the new public key to send to the responder and the activation sequence.
+// RekeyDirection selects which traffic keys a rekey rotates, as seen by the
+// peer that requests it. With asymmetric traffic one direction reaches its
+// limits long before the other; rotating only that direction leaves the
+// other's cipher and replay state untouched.
+type RekeyDirection uint8
+
+// Rekey directions.
+const (
+	// RekeyBoth rotates the keys of both directions.
+	RekeyBoth RekeyDirection = iota
+
+	// RekeySend rotates only the keys of the records the requester sends.
+	RekeySend
+
+	// RekeyReceive rotates only the keys of the records the requester
+	// receives.
+	RekeyReceive
+)
+
+// String returns a human-readable representation of the direction.
+func (d RekeyDirection) String() string {
+	switch d {
+	case RekeyBoth:
+		return "Both"
+	case RekeySend:
+		return "Send"
+	case RekeyReceive:
+		return "Receive"
+	default:
+		return "Unknown"
+	}
+}
+
+// reverse returns the direction as seen by the other peer.
+func (d RekeyDirection) reverse() RekeyDirection {
+	switch d {
+	case RekeySend:
+		return RekeyReceive
+	case RekeyReceive:
+		return RekeySend
+	default:
+		return d
+	}
+}
+
+// rotatesSend reports whether the direction includes the local send keys.
+func (d RekeyDirection) rotatesSend() bool {
+	return d != RekeyReceive
+}
+
+// rotatesReceive reports whether the direction includes the local receive
+// keys.
+func (d RekeyDirection) rotatesReceive() bool {
+	return d != RekeySend
+}
+
+// InitiateRekey starts a rekey operation, normally called by the initiator.
+// Returns the new public key to send to the peer and the activation sequence.
 func (s *Session) InitiateRekey() ([]byte, uint64, error) {
+	return s.InitiateRekeyDirection(RekeyBoth)
+}
+
+// InitiateRekeyDirection starts a rekey of the given directions. The new
+// send keys activate at the returned sequence number; new receive keys
+// activate once the peer's records authenticate under them. The peer must
+// answer with PrepareRekeyResponseDirection and the same direction.
+func (s *Session) InitiateRekeyDirection(dir RekeyDirection) ([]byte, uint64, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if dir > RekeyReceive {
+		return nil, 0, qerrors.ErrInvalidMessage
+	}
 	if s.rekeyInProgress {
 		return nil, 0, qerrors.ErrRekeyInProgress
 	}
@@ -601,8 +1250,8 @@ func (s *Session) InitiateRekey() ([]byte, uint64, error) {
 		return nil, 0, qerrors.ErrInvalidState
 	}
 
-	// Generate new keypair for rekey
-	newKeyPair, err := chkem.GenerateKeyPair()
+	// Generate new keypair for rekey with the negotiated parameters
+	newKeyPair, err := chkem.GenerateKeyPairWithParameters(s.KEMParameters)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -613,17 +1262,30 @@ func (s *Session) InitiateRekey() ([]byte, uint64, error) {
 	s.rekeyInProgress = true
 	s.pendingRekeyKeyPair = newKeyPair
 	s.rekeyActivationSeq = activationSeq
+	s.rekeyDirection = dir
 	s.SetState(SessionStateRekeying)
+	s.emit(Event{Type: EventRekeyStarted})
 
 	return newKeyPair.PublicKey().Bytes(), activationSeq, nil
 }
 
-// PrepareRekeyResponse processes an incoming rekey request (called by responder).
-// Returns the ciphertext to send back to the initiator.
+// PrepareRekeyResponse processes an incoming rekey request.
+// Returns the ciphertext to send back to the requesting peer.
 func (s *Session) PrepareRekeyResponse(newPublicKeyBytes []byte, activationSeq uint64) ([]byte, error) {
+	return s.PrepareRekeyResponseDirection(newPublicKeyBytes, activationSeq, RekeyBoth)
+}
+
+// PrepareRekeyResponseDirection processes an incoming rekey request for the
+// given directions, as seen by the requester: a request to rotate the
+// requester's send keys rotates the local receive keys, and vice versa.
+// Returns the ciphertext to send back to the requesting peer.
+func (s *Session) PrepareRekeyResponseDirection(newPublicKeyBytes []byte, activationSeq uint64, dir RekeyDirection) ([]byte, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	if dir > RekeyReceive {
+		return nil, qerrors.ErrInvalidMessage
+	}
 	if s.State() != SessionStateEstablished && s.State() != SessionStateRekeying {
 		return nil, qerrors.ErrInvalidState
 	}
@@ -633,6 +1295,9 @@ func (s *Session) PrepareRekeyResponse(newPublicKeyBytes []byte, activationSeq u
 	if err != nil {
 		return nil, err
 	}
+	if newPublicKey.Parameters() != s.KEMParameters {
+		return nil, qerrors.ErrUnsupportedKEMParameters
+	}
 
 	// Encapsulate to the new public key
 	ciphertext, freshSecret, err := chkem.Encapsulate(newPublicKey)
@@ -641,175 +1306,430 @@ func (s *Session) PrepareRekeyResponse(newPublicKeyBytes []byte, activationSeq u
 	}
 
 	// Ratchet: mix current master secret with fresh KEM secret for forward secrecy
-	newSecret, err := crypto.DeriveRekeySecret(s.masterSecret, freshSecret)
+	newSecret, err := s.nextMasterSecret(freshSecret)
 	if err != nil {
 		return nil, err
 	}
 	crypto.Zeroize(freshSecret)
 
-	// Derive new traffic keys
-	initiatorKey, responderKey, err := crypto.DeriveTrafficKeys(newSecret)
+	// Derive new traffic keys and create new ciphers
+	newSendCipher, newRecvCipher, err := s.trafficCiphers(newSecret)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create new receive cipher (for receiving from initiator after activation)
-	newRecvCipher, err := crypto.NewAEAD(s.CipherSuite, initiatorKey)
-	if err != nil {
-		return nil, err
+	// Store pending state for the directions being rotated
+	s.rekeyInProgress = true
+	s.rekeyActivationSeq = activationSeq
+	s.rekeyDirection = dir.reverse()
+	s.setPendingCiphersLocked(newSendCipher, newRecvCipher)
+	s.pendingRekeySecret = newSecret
+
+	s.SetState(SessionStateRekeying)
+	s.emit(Event{Type: EventRekeyStarted})
+
+	return ciphertext.Bytes(), nil
+}
+
+// RatchetRekey starts a symmetric rekey: the next master secret is derived
+// from the current one alone, without a KEM exchange or round trip. Old
+// traffic keys cannot be recovered from new ones, but unlike a KEM rekey a
+// ratchet does not recover from a compromised master secret, so ratchets
+// should complement periodic KEM rekeys rather than replace them.
+// Returns the activation sequence to send to the peer, which installs the
+// same keys with ProcessRatchet.
+func (s *Session) RatchetRekey() (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.rekeyInProgress {
+		return 0, qerrors.ErrRekeyInProgress
+	}
+	if s.State() != SessionStateEstablished {
+		return 0, qerrors.ErrInvalidState
+	}
+
+	activationSeq := s.sendSeq.Load() + 16
+	if err := s.prepareRatchetLocked(activationSeq); err != nil {
+		return 0, err
+	}
+	return activationSeq, nil
+}
+
+// ProcessRatchet installs the keys of a ratchet started by the peer with
+// RatchetRekey. If both peers ratchet at once they derive the same keys,
+// so the second ratchet is ignored; an unanswered KEM rekey request of our
+// own is abandoned in favor of the peer's ratchet.
+func (s *Session) ProcessRatchet(activationSeq uint64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.State() != SessionStateEstablished && s.State() != SessionStateRekeying {
+		return qerrors.ErrInvalidState
+	}
+	if s.rekeyInProgress {
+		switch {
+		case s.ratchetPending:
+			return nil
+		case s.pendingRekeyKeyPair == nil:
+			// We answered a KEM rekey the peer has not finished
+			return qerrors.ErrRekeyInProgress
+		}
+		s.abortRekeyLocked()
 	}
 
-	// Create new send cipher
-	newSendCipher, err := crypto.NewAEAD(s.CipherSuite, responderKey)
+	return s.prepareRatchetLocked(activationSeq)
+}
+
+// prepareRatchetLocked derives the ratchet keys and stores them pending
+// activation at activationSeq. Must hold s.mu.
+func (s *Session) prepareRatchetLocked(activationSeq uint64) error {
+	newSecret, err := s.nextMasterSecret([]byte(constants.DomainSeparatorRatchet))
 	if err != nil {
-		return nil, err
+		return err
+	}
+	newSendCipher, newRecvCipher, err := s.trafficCiphers(newSecret)
+	if err != nil {
+		crypto.Zeroize(newSecret)
+		return err
 	}
 
-	// Store pending state (both ciphers activate at activation sequence)
 	s.rekeyInProgress = true
+	s.ratchetPending = true
 	s.rekeyActivationSeq = activationSeq
 	s.pendingRecvCipher = newRecvCipher
 	s.pendingSendCipher = newSendCipher
 	s.pendingRekeySecret = newSecret
 
-	// Zeroize temporary keys
-	crypto.ZeroizeMultiple(initiatorKey, responderKey)
-
 	s.SetState(SessionStateRekeying)
-
-	return ciphertext.Bytes(), nil
+	s.emit(Event{Type: EventRekeyStarted})
+	return nil
 }
 
-// ProcessRekeyResponse completes a rekey operation (called by initiator).
+// ProcessRekeyResponse completes a rekey operation started by InitiateRekey.
+// The new send keys activate at the activation sequence; if records up to
+// it were already sent while the response was in flight, they activate at
+// once, so the rekey completes without waiting for more traffic.
 func (s *Session) ProcessRekeyResponse(ciphertextBytes []byte) error {
+	activated, err := s.processRekeyResponse(ciphertextBytes)
+	if activated {
+		s.rekeyCompleted()
+	}
+	return err
+}
+
+// processRekeyResponse performs ProcessRekeyResponse and reports whether
+// the new keys were activated.
+func (s *Session) processRekeyResponse(ciphertextBytes []byte) (bool, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if !s.rekeyInProgress || s.pendingRekeyKeyPair == nil {
-		return qerrors.ErrInvalidState
+		return false, qerrors.ErrInvalidState
 	}
 
 	// Parse ciphertext
 	ciphertext, err := chkem.ParseCiphertext(ciphertextBytes)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Decapsulate using pending keypair
 	freshSecret, err := chkem.Decapsulate(ciphertext, s.pendingRekeyKeyPair)
 	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Ratchet: mix current master secret with fresh KEM secret for forward secrecy
-	newSecret, err := crypto.DeriveRekeySecret(s.masterSecret, freshSecret)
+	newSecret, err := s.nextMasterSecret(freshSecret)
 	if err != nil {
-		return err
+		return false, err
 	}
 	crypto.Zeroize(freshSecret)
 
-	// Derive new traffic keys
-	initiatorKey, responderKey, err := crypto.DeriveTrafficKeys(newSecret)
-	if err != nil {
-		return err
-	}
-
-	// Create new ciphers
-	newSendCipher, err := crypto.NewAEAD(s.CipherSuite, initiatorKey)
+	// Derive new traffic keys and create new ciphers
+	newSendCipher, newRecvCipher, err := s.trafficCiphers(newSecret)
 	if err != nil {
-		return err
-	}
-
-	newRecvCipher, err := crypto.NewAEAD(s.CipherSuite, responderKey)
-	if err != nil {
-		return err
+		return false, err
 	}
 
 	// Store pending ciphers (will activate at activation sequence)
-	s.pendingRecvCipher = newRecvCipher
-	s.pendingSendCipher = newSendCipher
+	s.setPendingCiphersLocked(newSendCipher, newRecvCipher)
 	s.pendingRekeySecret = newSecret
 
 	// Clean up pending keypair
 	s.pendingRekeyKeyPair.Zeroize()
 	s.pendingRekeyKeyPair = nil
 
-	// Zeroize temporary keys
-	crypto.ZeroizeMultiple(initiatorKey, responderKey)
+	// Send sequence numbers are reserved under s.mu, so every record
+	// numbered from here on is sealed under the new keys
+	if s.pendingSendCipher != nil && s.sendSeq.Load() >= s.rekeyActivationSeq {
+		s.installPendingKeysLocked()
+		s.state.Store(int32(SessionStateEstablished))
+		return true, nil
+	}
+	return false, nil
+}
 
-	return nil
+// setPendingCiphersLocked stores the new ciphers of the directions the
+// rekey in progress rotates. Must hold s.mu.
+func (s *Session) setPendingCiphersLocked(sendCipher, recvCipher *crypto.AEAD) {
+	if s.rekeyDirection.rotatesSend() {
+		s.pendingSendCipher = sendCipher
+	}
+	if s.rekeyDirection.rotatesReceive() {
+		s.pendingRecvCipher = recvCipher
+	}
+}
+
+// trafficCiphers derives the traffic keys and nonce IVs for secret and
+// creates the send and receive ciphers. Directions follow the session role,
+// not which peer requested a rekey, so either peer may act as rekey
+// requester. Nonces are derived from the record sequence number.
+// The ciphers are key-committing if enabled by SetKeyCommitment.
+func (s *Session) trafficCiphers(secret []byte) (*crypto.AEAD, *crypto.AEAD, error) {
+	schedule, err := crypto.NewKeySchedule(secret, s.CipherSuite)
+	if err != nil {
+		return nil, nil, err
+	}
+	keys, err := schedule.TrafficKeys()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer keys.Zeroize()
+
+	send, recv := keys.ForRole(s.Role == RoleInitiator)
+
+	newCipher := crypto.NewAEADWithIV
+	if s.keyCommitment {
+		newCipher = crypto.NewCommittingAEADWithIV
+	}
+
+	sendCipher, err := newCipher(s.CipherSuite, send.Key, send.IV)
+	if err != nil {
+		return nil, nil, err
+	}
+	recvCipher, err := newCipher(s.CipherSuite, recv.Key, recv.IV)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sendCipher, recvCipher, nil
+}
+
+// nextMasterSecret derives the master secret that replaces the current one
+// in a rekey, mixing in additionalData. Must hold s.mu.
+func (s *Session) nextMasterSecret(additionalData []byte) ([]byte, error) {
+	schedule, err := crypto.NewKeySchedule(s.masterSecret, s.CipherSuite)
+	if err != nil {
+		return nil, err
+	}
+	next, err := schedule.Rekey(additionalData)
+	if err != nil {
+		return nil, err
+	}
+	return next.Secret(), nil
 }
 
 // ActivatePendingKeys activates pending keys after activation sequence is reached.
 func (s *Session) ActivatePendingKeys() {
+	if s.activatePendingKeys() {
+		s.rekeyCompleted()
+	}
+}
+
+// activatePendingKeys switches to the pending keys and reports whether a
+// rekey was completed.
+func (s *Session) activatePendingKeys() bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if !s.rekeyInProgress {
-		return
+		return false
 	}
 
+	s.installPendingKeysLocked()
+	s.SetState(SessionStateEstablished)
+	return true
+}
+
+// installPendingKeysLocked switches to the pending ciphers and master
+// secret and ends the rekey. Sequence numbers continue across the rekey,
+// so the replay window is kept and covers records under both keys; new
+// receive keys only start a key epoch at the next sequence number (see
+// acceptKeyEpoch). The rekey limits, which count sent traffic, only
+// restart with new send keys. Must hold s.mu.
+func (s *Session) installPendingKeysLocked() {
 	// Switch receive cipher if pending
 	if s.pendingRecvCipher != nil {
+		s.prevRecvCipher = s.recvCipher
 		s.recvCipher = s.pendingRecvCipher
 		s.pendingRecvCipher = nil
+		s.recvEpochSeq = s.recvSeq.Load()
 	}
 
 	// Switch send cipher if pending
 	if s.pendingSendCipher != nil {
 		s.sendCipher = s.pendingSendCipher
 		s.pendingSendCipher = nil
+		s.resetRekeyLimits()
 	}
 
 	// Update master secret
 	if s.pendingRekeySecret != nil {
-		crypto.Zeroize(s.masterSecret)
-		s.masterSecret = s.pendingRekeySecret
+		s.setMasterSecret(s.pendingRekeySecret)
+		crypto.Zeroize(s.pendingRekeySecret)
 		s.pendingRekeySecret = nil
 	}
 
 	// Reset rekey state
 	s.rekeyInProgress = false
+	s.ratchetPending = false
 	s.rekeyActivationSeq = 0
-	s.replayWindow = NewReplayWindow()
-	s.EstablishedAt = time.Now()
+	s.rekeyDirection = RekeyBoth
+}
 
-	s.SetState(SessionStateEstablished)
+// resolveRekeyCollision handles a rekey request from the peer while our own
+// request may still be unanswered. Both peers share the session ID, so the
+// tie-break compares the two fresh rekey public keys instead: the request
+// with the lexicographically smaller key wins. If the local request loses,
+// its pending keypair is discarded so the peer's request can be answered.
+// It reports whether a collision occurred and whether the local request won.
+func (s *Session) resolveRekeyCollision(peerPublicKey []byte) (collided, won bool, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.rekeyInProgress || s.pendingRekeyKeyPair == nil {
+		return false, false, nil
+	}
+
+	switch bytes.Compare(s.pendingRekeyKeyPair.PublicKey().Bytes(), peerPublicKey) {
+	case -1:
+		return true, true, nil
+	case 1:
+		s.abortRekeyLocked()
+		return true, false, nil
+	default:
+		// Our own key reflected back; no honest peer sends this
+		return true, false, qerrors.ErrInvalidMessage
+	}
+}
+
+// abortRekey discards any pending rekey state and returns the session to
+// the established state with its current keys.
+func (s *Session) abortRekey() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.abortRekeyLocked()
+}
+
+// abortRekeyLocked implements abortRekey. The caller must hold s.mu.
+func (s *Session) abortRekeyLocked() {
+	if !s.rekeyInProgress {
+		return
+	}
+
+	if s.pendingRekeyKeyPair != nil {
+		s.pendingRekeyKeyPair.Zeroize()
+		s.pendingRekeyKeyPair = nil
+		s.abandonedRekeySeq = s.rekeyActivationSeq
+	}
+	if s.pendingRekeySecret != nil {
+		crypto.Zeroize(s.pendingRekeySecret)
+		s.pendingRekeySecret = nil
+	}
+	s.pendingRecvCipher = nil
+	s.pendingSendCipher = nil
+	s.rekeyInProgress = false
+	s.ratchetPending = false
+	s.rekeyActivationSeq = 0
+	s.rekeyDirection = RekeyBoth
+
+	if s.State() == SessionStateRekeying {
+		s.SetState(SessionStateEstablished)
+	}
 }
 
 // checkAndActivateSendCipher checks if send cipher should be activated based on sequence number.
 // When activation happens, it also activates pending keys on the receive side if available.
 func (s *Session) checkAndActivateSendCipher(seq uint64) {
+	if s.activateSendCipher(seq) {
+		s.rekeyCompleted()
+	}
+}
+
+// rekeyCompleted reports a completed rekey to the observer and subscribers.
+func (s *Session) rekeyCompleted() {
+	if s.observer != nil {
+		s.observer.OnRekeyCompleted()
+	}
+	s.emit(Event{Type: EventRekeyCompleted})
+}
+
+// activateSendCipher performs the activation for checkAndActivateSendCipher
+// and reports whether a rekey was completed.
+func (s *Session) activateSendCipher(seq uint64) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	if s.rekeyInProgress && s.pendingSendCipher != nil && seq >= s.rekeyActivationSeq {
-		// Switch send cipher
-		s.sendCipher = s.pendingSendCipher
-		s.pendingSendCipher = nil
-
-		// Also switch receive cipher if pending
-		if s.pendingRecvCipher != nil {
-			s.recvCipher = s.pendingRecvCipher
-			s.pendingRecvCipher = nil
-		}
+		// Switch the send cipher, and the receive cipher too if pending
+		s.installPendingKeysLocked()
+		s.state.Store(int32(SessionStateEstablished))
+		return true
+	}
+	return false
+}
 
-		// Update master secret
-		if s.pendingRekeySecret != nil {
-			crypto.Zeroize(s.masterSecret)
-			s.masterSecret = s.pendingRekeySecret
-			s.pendingRekeySecret = nil
-		}
+// checkAndActivateRecvCipher activates pending keys once a record
+// authenticates under the pending receive cipher. The peer keeps sealing
+// with its current keys until it holds the new keys itself, which may be
+// well past the activation sequence, so the sequence number alone does not
+// show which keys a record uses.
+func (s *Session) checkAndActivateRecvCipher(ciphertext []byte, seq uint64) {
+	s.mu.RLock()
+	pending := s.pendingRecvCipher
+	s.mu.RUnlock()
+	if pending == nil {
+		return
+	}
 
-		// Complete the rekey
-		s.rekeyInProgress = false
-		s.rekeyActivationSeq = 0
-		s.replayWindow = NewReplayWindow()
-		s.EstablishedAt = time.Now()
-		s.state.Store(int32(SessionStateEstablished))
+	if _, err := pending.OpenSeq(seq, ciphertext, recordAAD(seq)); err == nil {
+		s.ActivatePendingKeys()
 	}
 }
 
+// rekeyAnswered reports whether a rekey in progress holds the new keys and
+// only waits for them to activate, either because the peer answered the
+// local request or because the local side answered the peer's.
+func (s *Session) rekeyAnswered() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rekeyInProgress && s.pendingRekeyKeyPair == nil && (s.pendingSendCipher != nil || s.pendingRecvCipher != nil)
+}
+
+// awaitingRekeyResponse reports whether a local rekey request is still
+// unanswered.
+func (s *Session) awaitingRekeyResponse() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rekeyInProgress && s.pendingRekeyKeyPair != nil
+}
+
+// abandonedRekey reports whether activationSeq belongs to a local rekey
+// request that was abandoned before the peer's answer arrived.
+func (s *Session) abandonedRekey(activationSeq uint64) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.abandonedRekeySeq != 0 && s.abandonedRekeySeq == activationSeq
+}
+
+// ratchetInProgress reports whether the rekey in progress is a ratchet.
+func (s *Session) ratchetInProgress() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rekeyInProgress && s.ratchetPending
+}
+
 // IsRekeyInProgress returns true if a rekey operation is in progress.
 func (s *Session) IsRekeyInProgress() bool {
 	s.mu.RLock()
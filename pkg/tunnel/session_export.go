@@ -0,0 +1,315 @@
+// Package tunnel implements session export for the CH-KEM VPN.
+//
+// This file (session_export.go) lets an established session move to another
+// process without a new handshake. Export serializes the session's keys and
+// sequence state and encrypts it with AES-256-GCM under a caller-provided key:
+//
+//	Export = Nonce(12) || AES-256-GCM(key, Nonce, State, label)
+//
+// The state carries the master secret, cipher suite, send and receive
+// sequence numbers, the replay window, how long and how much the current
+// keys have been used and the peer's verified identity, so the imported
+// session continues exactly where the exported one stopped, including
+// toward its next rekey. The exported session is closed:
+// each sequence number must only ever be sealed once under a key, so the
+// state may be imported at most once.
+package tunnel
+
+import (
+	"crypto/ed25519"
+	"encoding/binary"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+const (
+	// sessionExportVersion is the version of the exported state layout.
+	sessionExportVersion = 2
+
+	// sessionExportHeaderSize is the size of the exported state before the
+	// optional peer identity and the replay window bitmap: Version(1) ||
+	// Role(1) || CipherSuite(2) || KEMParameters(1) || ProtocolVersion(2) ||
+	// Flags(1) || Compression(1) || SessionID(16) || MasterSecret(32) ||
+	// ExporterSecret(32) || SendSeq(8) || RecvSeq(8) || SendCounter(8) ||
+	// HandshakeAt(8) || KeysInstalledAt(8) || BytesSent(8) ||
+	// PacketsSent(8) || KeyBytesSent(8) || KeyPacketsSent(8) ||
+	// WindowSize(8) || HighSeq(8). With exportFlagRemoteIdentity, the
+	// peer's identity key (32) follows the key usage.
+	sessionExportHeaderSize = 9 + constants.SessionIDSize + constants.CHKEMSharedSecretSize +
+		constants.KDFOutputSize + 11*8
+
+	// maxExportWindowSize bounds the replay window size accepted on import.
+	maxExportWindowSize = 1 << 20
+)
+
+// Flags of the exported state.
+const (
+	exportFlagKeyCommitment     = 1 << 0
+	exportFlagRecordsNegotiated = 1 << 1
+	exportFlagRecordPadding     = 1 << 2
+	exportFlagLockMemory        = 1 << 3
+	exportFlagRemoteIdentity    = 1 << 4
+)
+
+// Export serializes the session for ImportSession in another process and
+// closes it. The state is encrypted and authenticated with key, which must
+// be 32 bytes and is needed again to import it.
+//
+// Traffic must be stopped before calling Export: records sent or received
+// by this session afterwards are not reflected in the exported state. A
+// session with a rekey in progress, or whose peer may still send under the
+// keys a rekey replaced, cannot be exported and fails with
+// ErrRekeyInProgress; retry once the rekey has completed.
+//
+// The exported state holds the session's keys. Import it exactly once:
+// importing it twice would reuse nonces.
+func (s *Session) Export(key []byte) ([]byte, error) {
+	if len(key) != 32 {
+		return nil, qerrors.ErrInvalidKeySize
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.State() == SessionStateRekeying || s.rekeyInProgress ||
+		s.pendingSendCipher != nil || s.pendingRecvCipher != nil || s.prevRecvCipher != nil {
+		return nil, qerrors.ErrRekeyInProgress
+	}
+	if s.State() != SessionStateEstablished || s.masterSecret == nil || s.exporterSecret == nil || s.sendCipher == nil {
+		return nil, qerrors.ErrInvalidState
+	}
+
+	var flags byte
+	if s.keyCommitment {
+		flags |= exportFlagKeyCommitment
+	}
+	if s.recordsNegotiated {
+		flags |= exportFlagRecordsNegotiated
+	}
+	if s.recordPadding {
+		flags |= exportFlagRecordPadding
+	}
+	if s.lockMemory {
+		flags |= exportFlagLockMemory
+	}
+	if s.remoteIdentity != nil {
+		flags |= exportFlagRemoteIdentity
+	}
+
+	state := make([]byte, 0, sessionExportHeaderSize+8*len(s.replayWindow.bitmap))
+	state = append(state, sessionExportVersion, byte(s.Role))
+	state = binary.BigEndian.AppendUint16(state, uint16(s.CipherSuite))
+	state = append(state, byte(s.KEMParameters), s.Version.Major, s.Version.Minor, flags, byte(s.recordCompression))
+	state = append(state, s.ID...)
+	state = append(state, s.masterSecret...)
+	state = append(state, s.exporterSecret...)
+	state = binary.BigEndian.AppendUint64(state, s.sendSeq.Load())
+	state = binary.BigEndian.AppendUint64(state, s.recvSeq.Load())
+	state = binary.BigEndian.AppendUint64(state, s.sendCipher.Counter())
+
+	// The keys keep their age and usage, so the rekey policy is not reset
+	bytesSent, packetsSent := s.BytesSent.Load(), s.PacketsSent.Load()
+	state = binary.BigEndian.AppendUint64(state, uint64(s.handshakeAt.UnixNano()))     //nolint:gosec // G115: restored as int64
+	state = binary.BigEndian.AppendUint64(state, uint64(s.EstablishedAt.UnixNano()))   //nolint:gosec // G115: restored as int64
+	state = binary.BigEndian.AppendUint64(state, uint64(bytesSent))                    //nolint:gosec // G115: counters are non-negative
+	state = binary.BigEndian.AppendUint64(state, uint64(packetsSent))                  //nolint:gosec // G115: counters are non-negative
+	state = binary.BigEndian.AppendUint64(state, uint64(bytesSent-s.keyBytesBase))     //nolint:gosec // G115: counters only grow
+	state = binary.BigEndian.AppendUint64(state, uint64(packetsSent-s.keyPacketsBase)) //nolint:gosec // G115: counters only grow
+	if s.remoteIdentity != nil {
+		state = append(state, s.remoteIdentity...)
+	}
+	state = s.replayWindow.appendState(state)
+	defer crypto.Zeroize(state)
+
+	sealed, err := sealSessionExport(state, key)
+	if err != nil {
+		return nil, err
+	}
+
+	// The sequence numbers now belong to the exported state
+	s.closeLocked()
+	return sealed, nil
+}
+
+// ImportSession restores a session exported by Session.Export, encrypted
+// under key. The session is established and continues the exported
+// session's sequence numbers and replay window, so it can be attached to
+// the peer's connection with NewTransport. Observers, loggers and the
+// rekey policy are not exported and must be set up again. A session that
+// negotiated CipherSuiteNullEncryption imports with it, except in FIPS mode.
+func ImportSession(data, key []byte) (*Session, error) {
+	if len(key) != 32 {
+		return nil, qerrors.ErrInvalidKeySize
+	}
+	state, err := openSessionExport(data, key)
+	if err != nil {
+		return nil, err
+	}
+	defer crypto.Zeroize(state)
+
+	if len(state) < sessionExportHeaderSize || state[0] != sessionExportVersion {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+
+	role := Role(state[1])
+	suite := constants.CipherSuite(binary.BigEndian.Uint16(state[2:4]))
+	kemParams := chkem.Parameters(state[4])
+	version := protocol.Version{Major: state[5], Minor: state[6]}
+	flags := state[7]
+	compression := Compression(state[8])
+	if (role != RoleInitiator && role != RoleResponder) || !kemParams.IsSupported() || !compression.IsSupported() {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+	// A null encryption suite was opted into by both peers at the handshake
+	// and is rejected in FIPS mode when the keys are derived below
+	if !containsCipherSuite(protocol.SupportedCipherSuites(), suite) && suite != constants.CipherSuiteNullEncryption {
+		return nil, qerrors.ErrUnsupportedCipherSuite
+	}
+
+	rest := state[9:]
+	next := func(n int) []byte {
+		b := rest[:n:n]
+		rest = rest[n:]
+		return b
+	}
+	id := next(constants.SessionIDSize)
+	masterSecret := next(constants.CHKEMSharedSecretSize)
+	exporterSecret := next(constants.KDFOutputSize)
+	sendSeq := binary.BigEndian.Uint64(next(8))
+	recvSeq := binary.BigEndian.Uint64(next(8))
+	sendCounter := binary.BigEndian.Uint64(next(8))
+	handshakeAt := time.Unix(0, int64(binary.BigEndian.Uint64(next(8))))     //nolint:gosec // G115: written from int64
+	keysInstalledAt := time.Unix(0, int64(binary.BigEndian.Uint64(next(8)))) //nolint:gosec // G115: written from int64
+	bytesSent := int64(binary.BigEndian.Uint64(next(8)))                     //nolint:gosec // G115: written from int64
+	packetsSent := int64(binary.BigEndian.Uint64(next(8)))                   //nolint:gosec // G115: written from int64
+	keyBytesSent := int64(binary.BigEndian.Uint64(next(8)))                  //nolint:gosec // G115: written from int64
+	keyPacketsSent := int64(binary.BigEndian.Uint64(next(8)))                //nolint:gosec // G115: written from int64
+	if bytesSent < 0 || packetsSent < 0 || keyBytesSent < 0 || keyPacketsSent < 0 ||
+		keyBytesSent > bytesSent || keyPacketsSent > packetsSent {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+	var remoteIdentity ed25519.PublicKey
+	if flags&exportFlagRemoteIdentity != 0 {
+		if len(rest) < ed25519.PublicKeySize+16 {
+			return nil, qerrors.ErrInvalidSessionExport
+		}
+		remoteIdentity = append(ed25519.PublicKey(nil), next(ed25519.PublicKeySize)...)
+	}
+	replayWindow, err := restoreReplayWindow(rest)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Session{
+		ID:                append([]byte(nil), id...),
+		Role:              role,
+		Version:           version,
+		CipherSuite:       suite,
+		KEMParameters:     kemParams,
+		keyCommitment:     flags&exportFlagKeyCommitment != 0,
+		recordsNegotiated: flags&exportFlagRecordsNegotiated != 0,
+		recordPadding:     flags&exportFlagRecordPadding != 0,
+		recordCompression: compression,
+		lockMemory:        flags&exportFlagLockMemory != 0,
+		remoteIdentity:    remoteIdentity,
+		replayWindow:      replayWindow,
+		rekeyPolicy:       DefaultRekeyPolicy().withDefaults(),
+		CreatedAt:         time.Now(),
+		EstablishedAt:     keysInstalledAt,
+		handshakeAt:       handshakeAt,
+		keyBytesBase:      bytesSent - keyBytesSent,
+		keyPacketsBase:    packetsSent - keyPacketsSent,
+	}
+	s.sendSeq.Store(sendSeq)
+	s.recvSeq.Store(recvSeq)
+	s.BytesSent.Store(bytesSent)
+	s.PacketsSent.Store(packetsSent)
+
+	s.sendCipher, s.recvCipher, err = s.trafficCiphers(masterSecret)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sendCipher.SetCounter(sendCounter); err != nil {
+		return nil, err
+	}
+	s.setMasterSecret(masterSecret)
+	s.exporterSecret = append([]byte(nil), exporterSecret...)
+
+	s.SetState(SessionStateEstablished)
+	return s, nil
+}
+
+// sealSessionExport encrypts exported session state under key.
+func sealSessionExport(state, key []byte) ([]byte, error) {
+	aead, err := crypto.NewAEAD(constants.CipherSuiteAES256GCM, key)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := crypto.SecureRandomBytes(constants.AESNonceSize)
+	if err != nil {
+		return nil, err
+	}
+	ciphertext, err := aead.SealWithNonce(nonce, state, []byte(constants.DomainSeparatorSessionExport))
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+// openSessionExport decrypts exported session state sealed under key.
+func openSessionExport(data, key []byte) ([]byte, error) {
+	aead, err := crypto.NewAEAD(constants.CipherSuiteAES256GCM, key)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < constants.AESNonceSize+constants.AESTagSize {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+	state, err := aead.OpenWithNonce(data[:constants.AESNonceSize], data[constants.AESNonceSize:],
+		[]byte(constants.DomainSeparatorSessionExport))
+	if err != nil {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+	return state, nil
+}
+
+// appendState appends the window's size, highest sequence number and
+// bitmap to b.
+func (rw *ReplayWindow) appendState(b []byte) []byte {
+	rw.mu.Lock()
+	defer rw.mu.Unlock()
+
+	b = binary.BigEndian.AppendUint64(b, rw.windowSize)
+	b = binary.BigEndian.AppendUint64(b, rw.highSeq)
+	for _, word := range rw.bitmap {
+		b = binary.BigEndian.AppendUint64(b, word)
+	}
+	return b
+}
+
+// restoreReplayWindow creates a replay window from state written by
+// appendState.
+func restoreReplayWindow(state []byte) (*ReplayWindow, error) {
+	if len(state) < 16 {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+	size := binary.BigEndian.Uint64(state)
+	if size == 0 || size%64 != 0 || size > maxExportWindowSize {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+
+	rw := NewReplayWindowWithSize(size)
+	if uint64(len(state)) != 16+8*uint64(len(rw.bitmap)) {
+		return nil, qerrors.ErrInvalidSessionExport
+	}
+	rw.highSeq = binary.BigEndian.Uint64(state[8:])
+	for i := range rw.bitmap {
+		rw.bitmap[i] = binary.BigEndian.Uint64(state[16+8*i:])
+	}
+	return rw, nil
+}
@@ -0,0 +1,260 @@
+package tunnel
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+)
+
+// newSessionPair returns an initiator and responder sharing a random master secret.
+func newSessionPair(t *testing.T) (*Session, *Session) {
+	t.Helper()
+
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+
+	client, _ := NewSessionWithConfig(RoleInitiator, SessionConfig{ReplayWindowSize: 128})
+	server, _ := NewSession(RoleResponder)
+	if err := client.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM); err != nil {
+		t.Fatalf("InitializeKeys failed: %v", err)
+	}
+	if err := server.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM); err != nil {
+		t.Fatalf("InitializeKeys failed: %v", err)
+	}
+	return client, server
+}
+
+// sealRecord encrypts data with from and checks that to decrypts it.
+func sealRecord(t *testing.T, from, to *Session, data string) ([]byte, uint64) {
+	t.Helper()
+
+	ciphertext, seq, err := from.Encrypt([]byte(data))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	plaintext, err := to.Decrypt(ciphertext, seq)
+	if err != nil || string(plaintext) != data {
+		t.Fatalf("Decrypt(seq %d) = %q, %v; want %q", seq, plaintext, err, data)
+	}
+	return ciphertext, seq
+}
+
+func TestSessionExportImport(t *testing.T) {
+	client, server := newSessionPair(t)
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	// Traffic in both directions, with one server record lost in transit
+	var replayed []byte
+	var replayedSeq uint64
+	for i := range 5 {
+		sealRecord(t, client, server, fmt.Sprintf("up %d", i))
+		if i == 3 {
+			_, _, _ = server.Encrypt([]byte("lost"))
+			continue
+		}
+		replayed, replayedSeq = sealRecord(t, server, client, fmt.Sprintf("down %d", i))
+	}
+	exporter, _ := client.ExporterSecret("test", 32)
+
+	data, err := client.Export(key)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if client.State() != SessionStateClosed {
+		t.Errorf("exported session state = %v, want closed", client.State())
+	}
+	if _, _, err := client.Encrypt([]byte("after export")); err == nil {
+		t.Error("exported session should not encrypt")
+	}
+
+	imported, err := ImportSession(data, key)
+	if err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	defer imported.Close()
+
+	if !bytes.Equal(imported.ID, client.ID) || imported.Role != RoleInitiator ||
+		imported.CipherSuite != constants.CipherSuiteAES256GCM || imported.replayWindow.Size() != 128 {
+		t.Errorf("imported session differs: role %v, suite %v, window %d",
+			imported.Role, imported.CipherSuite, imported.replayWindow.Size())
+	}
+	if got, _ := imported.ExporterSecret("test", 32); !bytes.Equal(got, exporter) {
+		t.Error("exporter secret changed across export")
+	}
+
+	// Sending continues the exported sequence numbers
+	for i := 5; i < 10; i++ {
+		if _, seq := sealRecord(t, imported, server, fmt.Sprintf("up %d", i)); seq != uint64(i) {
+			t.Errorf("imported session sent seq %d, want %d", seq, i)
+		}
+	}
+
+	// Receiving continues the replay window: records seen before the
+	// export are rejected, the one lost in transit is still accepted
+	if _, err := imported.Decrypt(replayed, replayedSeq); !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("replayed record: expected ErrReplayDetected, got %v", err)
+	}
+	sealRecord(t, server, imported, "down 5")
+}
+
+func TestSessionExportKeepsKeyState(t *testing.T) {
+	client, server := newSessionPair(t)
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	identity, _, _ := ed25519.GenerateKey(nil)
+	client.remoteIdentity = identity
+	client.lockMemory = true
+	for i := range 3 {
+		sealRecord(t, client, server, fmt.Sprintf("up %d", i))
+	}
+
+	// Keys installed two hours ago, after some of the traffic
+	client.mu.Lock()
+	client.EstablishedAt = time.Now().Add(-2 * time.Hour)
+	client.keyBytesBase = 4
+	client.keyPacketsBase = 1
+	client.mu.Unlock()
+	handshakeAt := client.handshakeAt
+
+	data, err := client.Export(key)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	imported, err := ImportSession(data, key)
+	if err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	defer imported.Close()
+
+	if !imported.RemoteIdentity().Equal(identity) {
+		t.Error("peer identity lost across export")
+	}
+	if !imported.lockMemory || imported.masterSecretBuf == nil {
+		t.Error("master secret not kept in locked memory")
+	}
+
+	// The keys keep their age and usage instead of a fresh rekey budget
+	if !imported.EstablishedAt.Equal(client.EstablishedAt) || !imported.handshakeAt.Equal(handshakeAt) {
+		t.Errorf("key age reset: established %v, want %v", imported.EstablishedAt, client.EstablishedAt)
+	}
+	if imported.BytesSent.Load() != client.BytesSent.Load() || imported.PacketsSent.Load() != 3 {
+		t.Errorf("sent %d bytes, %d packets; want %d, 3", imported.BytesSent.Load(), imported.PacketsSent.Load(), client.BytesSent.Load())
+	}
+	if imported.keyBytesBase != 4 || imported.keyPacketsBase != 1 {
+		t.Errorf("key usage bases = %d, %d; want 4, 1", imported.keyBytesBase, imported.keyPacketsBase)
+	}
+	if !imported.NeedsRekey() {
+		t.Error("imported keys past the default policy's duration do not need a rekey")
+	}
+}
+
+func TestSessionExportImportTransport(t *testing.T) {
+	client, server := newPipeTransports(t)
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	checkRoundTrip(t, client, server, []byte("before"))
+	checkRoundTrip(t, server, client, []byte("before"))
+
+	data, err := client.session.Export(key)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	imported, err := ImportSession(data, key)
+	if err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	migrated, err := NewTransport(imported, client.conn, DefaultTransportConfig())
+	if err != nil {
+		t.Fatalf("NewTransport failed: %v", err)
+	}
+
+	checkRoundTrip(t, migrated, server, []byte("after"))
+	checkRoundTrip(t, server, migrated, []byte("after"))
+}
+
+func TestSessionExportImportNullEncryption(t *testing.T) {
+	if crypto.FIPSMode() {
+		t.Skip("null encryption is not available in FIPS mode")
+	}
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	_ = client.InitializeKeys(masterSecret, constants.CipherSuiteNullEncryption)
+	_ = server.InitializeKeys(masterSecret, constants.CipherSuiteNullEncryption)
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	sealRecord(t, client, server, "before")
+	data, err := client.Export(key)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	imported, err := ImportSession(data, key)
+	if err != nil {
+		t.Fatalf("ImportSession failed: %v", err)
+	}
+	defer imported.Close()
+
+	if imported.CipherSuite != constants.CipherSuiteNullEncryption {
+		t.Errorf("imported suite = %v, want %v", imported.CipherSuite, constants.CipherSuiteNullEncryption)
+	}
+	sealRecord(t, imported, server, "after")
+	sealRecord(t, server, imported, "after")
+}
+
+func TestSessionExportInvalid(t *testing.T) {
+	key := make([]byte, 32)
+	_ = crypto.SecureRandom(key)
+
+	// Sessions that are not established cannot be exported
+	fresh, _ := NewSession(RoleInitiator)
+	if _, err := fresh.Export(key); !errors.Is(err, qerrors.ErrInvalidState) {
+		t.Errorf("unestablished session: expected ErrInvalidState, got %v", err)
+	}
+
+	client, server := newSessionPair(t)
+	if _, err := client.Export(key[:16]); !errors.Is(err, qerrors.ErrInvalidKeySize) {
+		t.Errorf("short key: expected ErrInvalidKeySize, got %v", err)
+	}
+
+	// Nor can sessions in the middle of a rekey
+	if _, _, err := client.InitiateRekey(); err != nil {
+		t.Fatalf("InitiateRekey failed: %v", err)
+	}
+	if _, err := client.Export(key); !errors.Is(err, qerrors.ErrRekeyInProgress) {
+		t.Errorf("rekeying session: expected ErrRekeyInProgress, got %v", err)
+	}
+
+	data, err := server.Export(key)
+	if err != nil {
+		t.Fatalf("Export failed: %v", err)
+	}
+	if _, err := server.Export(key); !errors.Is(err, qerrors.ErrInvalidState) {
+		t.Errorf("second export: expected ErrInvalidState, got %v", err)
+	}
+
+	wrongKey := bytes.Clone(key)
+	wrongKey[0] ^= 1
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)/2] ^= 1
+	for name, tt := range map[string]struct{ data, key []byte }{
+		"wrong key": {data, wrongKey},
+		"tampered":  {tampered, key},
+		"truncated": {data[:20], key},
+	} {
+		if _, err := ImportSession(tt.data, tt.key); !errors.Is(err, qerrors.ErrInvalidSessionExport) {
+			t.Errorf("%s: expected ErrInvalidSessionExport, got %v", name, err)
+		}
+	}
+}
@@ -2,10 +2,13 @@ package tunnel
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 	"time"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
 	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 )
 
@@ -68,6 +71,87 @@ func TestSessionRekeyErrorPaths(t *testing.T) {
 	}
 }
 
+func TestSessionSequenceExhausted(t *testing.T) {
+	session, _ := NewSession(RoleInitiator)
+	_ = session.InitializeKeys(make([]byte, constants.CHKEMSharedSecretSize), constants.CipherSuiteAES256GCM)
+
+	// The last sequence number is still usable
+	session.sendSeq.Store(constants.MaxSequenceNumber - 1)
+	if _, seq, err := session.Encrypt([]byte("last")); err != nil || seq != constants.MaxSequenceNumber-1 {
+		t.Fatalf("Encrypt = seq %d, %v; want seq %d", seq, err, uint64(constants.MaxSequenceNumber-1))
+	}
+
+	// ...after which Encrypt fails instead of wrapping around
+	for i := 0; i < 2; i++ {
+		if _, _, err := session.Encrypt([]byte("beyond")); !errors.Is(err, qerrors.ErrSequenceExhausted) {
+			t.Fatalf("expected ErrSequenceExhausted, got %v", err)
+		}
+	}
+	if seq := session.sendSeq.Load(); seq != constants.MaxSequenceNumber {
+		t.Errorf("sendSeq = %d after exhaustion, want %d", seq, uint64(constants.MaxSequenceNumber))
+	}
+
+	// A rekey does not reset sequence numbers
+	if _, _, err := session.InitiateRekey(); err != nil {
+		t.Fatalf("InitiateRekey failed: %v", err)
+	}
+	if _, _, err := session.Encrypt([]byte("after rekey")); !errors.Is(err, qerrors.ErrSequenceExhausted) {
+		t.Errorf("expected ErrSequenceExhausted after rekey, got %v", err)
+	}
+}
+
+func TestSessionForgedRecordKeepsReplayWindow(t *testing.T) {
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	_ = client.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	_ = server.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	// A forged record far ahead fails authentication without moving the window
+	if _, err := server.Decrypt(make([]byte, 64), 1<<40); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Fatalf("Decrypt forged record = %v, want ErrAuthenticationFailed", err)
+	}
+	if stats := server.replayWindow.Stats(); stats.Accepted != 0 || stats.MaxForwardJump != 0 {
+		t.Errorf("forged record counted in replay stats: %+v", stats)
+	}
+
+	// Genuine records that follow still decrypt, and are still replay protected
+	for i := 0; i < 3; i++ {
+		ciphertext, seq, err := client.Encrypt([]byte("genuine"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if data, err := server.Decrypt(ciphertext, seq); err != nil || string(data) != "genuine" {
+			t.Fatalf("Decrypt seq %d = %q, %v", seq, data, err)
+		}
+		if _, err := server.Decrypt(ciphertext, seq); !errors.Is(err, qerrors.ErrReplayDetected) {
+			t.Errorf("replayed seq %d = %v, want ErrReplayDetected", seq, err)
+		}
+	}
+}
+
+func TestSessionRemainingCapacity(t *testing.T) {
+	session, _ := NewSession(RoleInitiator)
+	if got := session.RemainingCapacity(); got != 0 {
+		t.Errorf("RemainingCapacity before keys = %d, want 0", got)
+	}
+	_ = session.InitializeKeys(make([]byte, constants.CHKEMSharedSecretSize), constants.CipherSuiteAES256GCM)
+
+	full := session.RemainingCapacity()
+	if full != constants.MaxPacketsBeforeRekey {
+		t.Fatalf("RemainingCapacity = %d, want %d", full, uint64(constants.MaxPacketsBeforeRekey))
+	}
+	for i := 0; i < 3; i++ {
+		if _, _, err := session.Encrypt([]byte("x")); err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+	}
+	if got := session.RemainingCapacity(); got != full-3 {
+		t.Errorf("RemainingCapacity = %d after 3 records, want %d", got, full-3)
+	}
+}
+
 func TestSessionActivatePendingKeysEdgeCases(t *testing.T) {
 	session, _ := NewSession(RoleInitiator)
 
@@ -122,6 +206,57 @@ func TestSessionInitializeAfterClose(t *testing.T) {
 	}
 }
 
+func TestSessionLockMemory(t *testing.T) {
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+
+	client, _ := NewSession(RoleInitiator)
+	client.SetLockMemory(true)
+	server, _ := NewSession(RoleResponder)
+
+	_ = client.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	_ = server.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	if client.masterSecretBuf == nil {
+		t.Fatal("master secret should be held in a SecureBytes")
+	}
+	if server.masterSecretBuf != nil {
+		t.Error("master secret should not be locked without SetLockMemory")
+	}
+	if !bytes.Equal(client.masterSecret, masterSecret) {
+		t.Error("locked master secret does not match")
+	}
+
+	// Rekeying replaces the locked secret and keeps peers interoperable
+	newSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(newSecret)
+	oldBuf := client.masterSecretBuf
+	if err := client.Rekey(newSecret); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	_ = server.Rekey(newSecret)
+	if oldBuf.Bytes() != nil {
+		t.Error("rekey should free the previous secret buffer")
+	}
+	if !bytes.Equal(client.masterSecret, newSecret) {
+		t.Error("locked master secret not updated by rekey")
+	}
+
+	ciphertext, seq, err := client.Encrypt([]byte("locked"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if plaintext, err := server.Decrypt(ciphertext, seq); err != nil || string(plaintext) != "locked" {
+		t.Fatalf("Decrypt failed: %v", err)
+	}
+
+	buf := client.masterSecretBuf
+	client.Close()
+	if client.masterSecret != nil || client.masterSecretBuf != nil || buf.Bytes() != nil {
+		t.Error("Close should free the locked master secret")
+	}
+}
+
 func TestSessionExportTicketResume(t *testing.T) {
 	tmKey := make([]byte, 32)
 	_ = crypto.SecureRandom(tmKey)
@@ -155,3 +290,374 @@ func TestSessionExportTicketResume(t *testing.T) {
 		t.Error("Resume should not set state to Established (keys are initialized later after KEM exchange)")
 	}
 }
+
+func TestSessionRemoteFingerprint(t *testing.T) {
+	client, server, clientErr, serverErr := storeHandshake(t, nil, nil, nil)
+	if clientErr != nil || serverErr != nil {
+		t.Fatalf("handshake failed: client=%v server=%v", clientErr, serverErr)
+	}
+
+	// The responder sees the initiator's key; the initiator has none to pin
+	fp, ok := server.RemoteFingerprint()
+	if !ok {
+		t.Fatal("responder should know the initiator's fingerprint")
+	}
+	if fp != client.LocalKeyPair.PublicKey().Fingerprint() {
+		t.Error("responder fingerprint does not match the initiator's key")
+	}
+	if _, ok := client.RemoteFingerprint(); ok {
+		t.Error("initiator should not report a remote fingerprint")
+	}
+}
+
+func TestNewSessionWithKeyPair(t *testing.T) {
+	keyPair, err := chkem.GenerateKeyPairWithParameters(chkem.CHKEM768)
+	if err != nil {
+		t.Fatalf("GenerateKeyPairWithParameters failed: %v", err)
+	}
+
+	session, err := NewSessionWithKeyPair(RoleInitiator, keyPair)
+	if err != nil {
+		t.Fatalf("NewSessionWithKeyPair failed: %v", err)
+	}
+	defer session.Close()
+	if session.LocalKeyPair != keyPair {
+		t.Error("session does not use the pre-generated key pair")
+	}
+	if session.KEMParameters != chkem.CHKEM768 {
+		t.Errorf("KEMParameters = %v, want the key pair's %v", session.KEMParameters, chkem.CHKEM768)
+	}
+	if !keyPair.Consumed() {
+		t.Error("key pair not marked consumed")
+	}
+
+	// A second session must not reuse it
+	if _, err := NewSessionWithKeyPair(RoleInitiator, keyPair); !errors.Is(err, qerrors.ErrKeyPairConsumed) {
+		t.Errorf("expected ErrKeyPairConsumed on reuse, got %v", err)
+	}
+}
+
+func TestNewResponderSessionSkipsKeyGeneration(t *testing.T) {
+	// Listeners create the responder session before checking the cookie,
+	// so it must not do any CH-KEM work
+	session, err := NewSession(RoleResponder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	defer session.Close()
+	if session.LocalKeyPair != nil {
+		t.Error("responder session generated a local key pair")
+	}
+}
+
+func TestSessionRekeySendDirectionOnly(t *testing.T) {
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	_ = client.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	_ = server.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	// Some server traffic advances the client's receive state
+	var old []byte
+	for range 3 {
+		ciphertext, seq, err := server.Encrypt([]byte("download"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if _, err := client.Decrypt(ciphertext, seq); err != nil {
+			t.Fatalf("Decrypt failed: %v", err)
+		}
+		old = ciphertext
+	}
+	recvCipher, recvSeq := client.recvCipher, client.recvSeq.Load()
+	serverSendCipher := server.sendCipher
+
+	publicKey, activationSeq, err := client.InitiateRekeyDirection(RekeySend)
+	if err != nil {
+		t.Fatalf("InitiateRekeyDirection failed: %v", err)
+	}
+	ciphertext, err := server.PrepareRekeyResponseDirection(publicKey, activationSeq, RekeySend)
+	if err != nil {
+		t.Fatalf("PrepareRekeyResponseDirection failed: %v", err)
+	}
+	if err := client.ProcessRekeyResponse(ciphertext); err != nil {
+		t.Fatalf("ProcessRekeyResponse failed: %v", err)
+	}
+	if client.pendingRecvCipher != nil || server.pendingSendCipher != nil {
+		t.Fatal("send-only rekey prepared keys for the other direction")
+	}
+
+	// Client traffic crosses the activation sequence and keeps flowing
+	for client.IsRekeyInProgress() || server.IsRekeyInProgress() {
+		ciphertext, seq, err := client.Encrypt([]byte("upload"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if seq > activationSeq+1 {
+			t.Fatal("rekey did not complete after the activation sequence")
+		}
+		if data, err := server.Decrypt(ciphertext, seq); err != nil || string(data) != "upload" {
+			t.Fatalf("Decrypt = %q, %v", data, err)
+		}
+	}
+
+	if client.recvCipher != recvCipher || client.prevRecvCipher != nil || server.sendCipher != serverSendCipher {
+		t.Error("send-only rekey replaced the receive direction's cipher")
+	}
+	if client.recvSeq.Load() != recvSeq {
+		t.Errorf("receive sequence = %d, want %d", client.recvSeq.Load(), recvSeq)
+	}
+	if _, err := client.Decrypt(old, recvSeq-1); !errors.Is(err, qerrors.ErrReplayDetected) {
+		t.Errorf("replaying a record from before the rekey = %v, want ErrReplayDetected", err)
+	}
+	if !bytes.Equal(client.masterSecret, server.masterSecret) {
+		t.Error("peers did not converge on the same master secret")
+	}
+
+	// The untouched direction still works
+	ciphertext, seq, err := server.Encrypt([]byte("more download"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	if data, err := client.Decrypt(ciphertext, seq); err != nil || string(data) != "more download" {
+		t.Errorf("Decrypt = %q, %v", data, err)
+	}
+}
+
+// ratchetedPair returns sessions sharing a master secret, with a ratchet
+// started by server and processed by client, and the server's records
+// sealed up to and including the activation sequence. The record at the
+// activation sequence is the first under the new keys. oldSend is the
+// server's send cipher before the ratchet.
+func ratchetedPair(t *testing.T) (client, server *Session, records [][]byte, activationSeq uint64, oldSend *crypto.AEAD) {
+	t.Helper()
+
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	client, _ = NewSession(RoleInitiator)
+	server, _ = NewSession(RoleResponder)
+	_ = client.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	_ = server.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	oldSend = server.sendCipher
+	activationSeq, err := server.RatchetRekey()
+	if err != nil {
+		t.Fatalf("RatchetRekey failed: %v", err)
+	}
+	if err := client.ProcessRatchet(activationSeq); err != nil {
+		t.Fatalf("ProcessRatchet failed: %v", err)
+	}
+	for seq := uint64(0); seq <= activationSeq; seq++ {
+		ciphertext, got, err := server.Encrypt([]byte("record"))
+		if err != nil || got != seq {
+			t.Fatalf("Encrypt = seq %d, %v; want seq %d", got, err, seq)
+		}
+		records = append(records, ciphertext)
+	}
+	if server.sendCipher == oldSend {
+		t.Fatal("server did not switch keys at the activation sequence")
+	}
+	return client, server, records, activationSeq, oldSend
+}
+
+func TestSessionRekeyActivationBoundary(t *testing.T) {
+	seal := func(t *testing.T, cipher *crypto.AEAD, seq uint64) []byte {
+		t.Helper()
+		ciphertext, err := cipher.SealSeq(seq, []byte("record"), recordAAD(seq))
+		if err != nil {
+			t.Fatalf("SealSeq failed: %v", err)
+		}
+		return ciphertext
+	}
+	expect := func(t *testing.T, s *Session, ciphertext []byte, seq uint64, want error) {
+		t.Helper()
+		if _, err := s.Decrypt(ciphertext, seq); !errors.Is(err, want) {
+			t.Errorf("Decrypt seq %d = %v, want %v", seq, err, want)
+		}
+	}
+
+	t.Run("peer switches", func(t *testing.T) {
+		client, server, records, a, oldSend := ratchetedPair(t)
+
+		// The last record under the old keys, then the first under the new
+		expect(t, client, records[a-1], a-1, nil)
+		expect(t, client, records[a], a, nil)
+		if client.IsRekeyInProgress() || client.prevRecvCipher != nil {
+			t.Fatal("new keys did not activate and retire the old ones")
+		}
+
+		// Sequence numbers continue, so replays across the boundary are
+		// still caught by the replay window
+		expect(t, client, records[a-1], a-1, qerrors.ErrReplayDetected)
+		expect(t, client, records[a], a, qerrors.ErrReplayDetected)
+
+		// The old keys are retired, even for a delayed record below the boundary
+		expect(t, client, records[a-2], a-2, qerrors.ErrAuthenticationFailed)
+		expect(t, client, seal(t, oldSend, a+1), a+1, qerrors.ErrAuthenticationFailed)
+
+		// New keys below the last record accepted under the old keys
+		expect(t, client, seal(t, server.sendCipher, a-3), a-3, qerrors.ErrReplayDetected)
+		expect(t, client, seal(t, server.sendCipher, a+2), a+2, nil)
+	})
+
+	t.Run("local switch first", func(t *testing.T) {
+		// The client activates the new keys before any record under them
+		// arrives, so the old keys are kept for records still in flight
+		client, server, records, a, oldSend := ratchetedPair(t)
+		expect(t, client, records[a-2], a-2, nil)
+		client.ActivatePendingKeys()
+		if client.prevRecvCipher == nil {
+			t.Fatal("old receive keys not kept")
+		}
+
+		// The replay window is kept, so replays under the old keys are caught
+		expect(t, client, records[a-2], a-2, qerrors.ErrReplayDetected)
+
+		// Old keys remain valid until the peer is seen using the new ones,
+		// and raise the new keys' epoch past them
+		expect(t, client, records[a-1], a-1, nil)
+		expect(t, client, seal(t, server.sendCipher, a-3), a-3, qerrors.ErrReplayDetected)
+		expect(t, client, records[a], a, nil)
+		expect(t, client, seal(t, oldSend, a+1), a+1, qerrors.ErrAuthenticationFailed)
+	})
+
+	t.Run("forged at boundary", func(t *testing.T) {
+		// Forged records around the boundary neither activate nor retire
+		// keys, nor move the epoch or the replay window
+		client, _, records, a, _ := ratchetedPair(t)
+		expect(t, client, records[a-2], a-2, nil)
+		forged := make([]byte, len(records[a]))
+		for _, seq := range []uint64{a - 1, a, a + 1} {
+			expect(t, client, forged, seq, qerrors.ErrAuthenticationFailed)
+		}
+		if !client.IsRekeyInProgress() {
+			t.Fatal("forged record activated the new keys")
+		}
+
+		client.ActivatePendingKeys()
+		epoch := client.recvEpochSeq
+		stats := client.replayWindow.Stats()
+		for _, seq := range []uint64{a - 1, a, a + 1} {
+			expect(t, client, forged, seq, qerrors.ErrAuthenticationFailed)
+		}
+		if client.prevRecvCipher == nil || client.recvEpochSeq != epoch {
+			t.Fatal("forged record changed the key epoch")
+		}
+		if got := client.replayWindow.Stats(); got != stats {
+			t.Fatalf("forged records changed replay stats: %+v, want %+v", got, stats)
+		}
+
+		// The genuine records at the boundary are still accepted
+		expect(t, client, records[a-1], a-1, nil)
+		expect(t, client, records[a], a, nil)
+	})
+}
+
+func TestSessionRekeyKeysActivateOnAuthentication(t *testing.T) {
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	_ = client.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	_ = server.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	transfer := func(from, to *Session, what string) {
+		t.Helper()
+		ciphertext, seq, err := from.Encrypt([]byte(what))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if data, err := to.Decrypt(ciphertext, seq); err != nil || string(data) != what {
+			t.Fatalf("Decrypt seq %d = %q, %v", seq, data, err)
+		}
+	}
+
+	publicKey, activationSeq, err := client.InitiateRekey()
+	if err != nil {
+		t.Fatalf("InitiateRekey failed: %v", err)
+	}
+	response, err := server.PrepareRekeyResponse(publicKey, activationSeq)
+	if err != nil {
+		t.Fatalf("PrepareRekeyResponse failed: %v", err)
+	}
+
+	// Records past the activation sequence may still use the old keys, so
+	// the sequence number alone must not switch the server's receive keys
+	for client.sendSeq.Load() <= activationSeq+4 {
+		transfer(client, server, "old keys")
+	}
+	if !server.IsRekeyInProgress() {
+		t.Fatal("server switched receive keys on the sequence number alone")
+	}
+
+	// Once the client holds the new keys, the server may still reply under
+	// the old ones, which the client keeps until it sees the new keys used
+	if err := client.ProcessRekeyResponse(response); err != nil {
+		t.Fatalf("ProcessRekeyResponse failed: %v", err)
+	}
+	transfer(server, client, "old keys")
+
+	// The first record under the new keys activates them on the server
+	transfer(client, server, "new keys")
+	if server.IsRekeyInProgress() {
+		t.Error("server did not switch to the new keys")
+	}
+	if !bytes.Equal(client.masterSecret, server.masterSecret) {
+		t.Error("peers did not converge on the same keys")
+	}
+}
+
+func TestSessionRekeyResponseAfterActivationSeq(t *testing.T) {
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	client, _ := NewSession(RoleInitiator)
+	server, _ := NewSession(RoleResponder)
+	_ = client.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	_ = server.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	publicKey, activationSeq, err := client.InitiateRekey()
+	if err != nil {
+		t.Fatalf("InitiateRekey failed: %v", err)
+	}
+	response, err := server.PrepareRekeyResponse(publicKey, activationSeq)
+	if err != nil {
+		t.Fatalf("PrepareRekeyResponse failed: %v", err)
+	}
+
+	// The client streams past the activation sequence before the response
+	// arrives, so those records stay under the old keys
+	transfer := func(what string) {
+		t.Helper()
+		ciphertext, seq, err := client.Encrypt([]byte(what))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		if data, err := server.Decrypt(ciphertext, seq); err != nil || string(data) != what {
+			t.Fatalf("Decrypt seq %d = %q, %v", seq, data, err)
+		}
+	}
+	for client.sendSeq.Load() <= activationSeq {
+		transfer("old keys")
+	}
+	if !client.IsRekeyInProgress() {
+		t.Fatal("client activated keys before the response")
+	}
+
+	// With no traffic left to send, the response completes the rekey
+	if err := client.ProcessRekeyResponse(response); err != nil {
+		t.Fatalf("ProcessRekeyResponse failed: %v", err)
+	}
+	if client.IsRekeyInProgress() {
+		t.Fatal("rekey still in progress after a late response")
+	}
+
+	// The next record is under the new keys and completes the server's side
+	transfer("new keys")
+	if server.IsRekeyInProgress() {
+		t.Error("server did not switch to the new keys")
+	}
+	if !bytes.Equal(client.masterSecret, server.masterSecret) {
+		t.Error("peers did not converge on the same keys")
+	}
+}
@@ -0,0 +1,65 @@
+package tunnel
+
+import (
+	"context"
+
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+// TraceContext returns the context of the session's handshake span, for
+// parenting application spans under it. It returns context.Background() if
+// no observer traced the handshake.
+func (s *Session) TraceContext() context.Context {
+	if s.traceCtx == nil {
+		return context.Background()
+	}
+	return s.traceCtx
+}
+
+// startHandshakeSpan starts the handshake span as a child of parent. Calls
+// after the first are no-ops.
+func (s *Session) startHandshakeSpan(parent context.Context) {
+	if s.traceCtx != nil || s.observer == nil {
+		return
+	}
+	s.traceCtx, s.traceDone = s.observer.OnHandshakeStart(parent)
+	if s.traceCtx == nil {
+		s.traceCtx = parent
+	}
+}
+
+// continueTrace starts the responder's deferred handshake span as a child of
+// the initiator's traceparent, if the observer can propagate it.
+func (s *Session) continueTrace(traceparent string) {
+	if !s.traceDeferred {
+		return
+	}
+	parent := context.Background()
+	if p, ok := s.observer.(TracePropagator); ok && traceparent != "" {
+		parent = p.ContextWithTraceParent(parent, traceparent)
+	}
+	s.startHandshakeSpan(parent)
+}
+
+// traceParent returns the traceparent to propagate to the responder, or ""
+// if the observer does not support propagation.
+func (s *Session) traceParent() string {
+	p, ok := s.observer.(TracePropagator)
+	if !ok || s.traceCtx == nil {
+		return ""
+	}
+	traceparent := p.TraceParent(s.traceCtx)
+	if len(traceparent) > protocol.MaxTraceParentSize {
+		return ""
+	}
+	return traceparent
+}
+
+// endHandshakeSpan ends the handshake span with the handshake's result.
+func (s *Session) endHandshakeSpan(err error) {
+	s.traceDeferred = false
+	if s.traceDone != nil {
+		s.traceDone(err)
+		s.traceDone = nil
+	}
+}
@@ -9,19 +9,29 @@
 package tunnel
 
 import (
+	"bytes"
 	"context"
+	"crypto/ed25519"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
 	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
 	"github.com/sara-star-quant/quantum-go/pkg/protocol"
 )
 
+// maxRecordPlaintext is the largest plaintext whose sealed form
+// (nonce || ciphertext || tag) fits within MaxPayloadSize.
+const maxRecordPlaintext = constants.MaxPayloadSize - constants.AESNonceSize - constants.AESTagSize
+
 // Transport provides encrypted communication over an established session.
 type Transport struct {
 	session *Session
@@ -32,12 +42,66 @@ type Transport struct {
 	readTimeout  time.Duration
 	writeTimeout time.Duration
 
+	// Record padding and compression applied to data messages
+	padding     Padding
+	compression Compression
+
+	// In-order delivery buffer (nil delivers records in arrival order)
+	reorder *reorderBuffer
+
+	// Message fragmentation (nil sends each message as a single record)
+	reassembly *reassembler
+	fragmentMu sync.Mutex // Keeps the fragments of concurrent sends apart
+
+	// Datagram mode (see packet.go): each message is one datagram
+	packet       bool
+	datagramBuf  []byte
+	packetFlight *packetFlight
+
 	// Mutex for write operations
 	writeMu sync.Mutex
 
+	// Queue feeding data messages to the writer goroutine (nil writes them
+	// from Send)
+	sendQueue *sendQueue
+
+	// Egress rate limit applied by Send (nil sends without delay)
+	sendLimiter *sendLimiter
+
+	// Read buffer reused across Receive calls, grown to the largest message
+	// seen. Received messages alias it only until the next read; data
+	// returned by Receive is decrypted into fresh memory.
+	readBuf []byte
+
+	// Plaintext buffer reused by ReceiveInto, grown to the largest record
+	// seen, and the message a short buffer could not hold. The pending
+	// message aliases recvBuf and is delivered before the next read.
+	recvBuf       []byte
+	pendingHeader []byte
+	pendingData   []byte
+
+	// The peer's close-write was received (only used by the receiving
+	// goroutine; with reordering, the reorder buffer tracks it instead)
+	peerWriteClosed bool
+
 	// Close state
-	closed   bool
-	closedMu sync.RWMutex
+	closed      bool
+	writeClosed bool // CloseWrite was called
+	closedMu    sync.RWMutex
+
+	// Keepalive state
+	lastSend         atomic.Int64 // Unix nanoseconds of the last data send
+	pingsOutstanding atomic.Int32 // Pings sent since the last pong
+	keepaliveStop    chan struct{}
+	keepaliveDone    chan struct{}
+	keepaliveOnce    sync.Once
+
+	// Connection-level traffic counters reported by WireStats
+	wire wireCounters
+
+	// Invoked once when the transport closes (used by Listener to track tunnels)
+	onClose     func(*Transport)
+	onCloseOnce sync.Once
 }
 
 // TransportConfig holds configuration for the transport layer.
@@ -45,6 +109,22 @@ type TransportConfig struct {
 	ReadTimeout  time.Duration
 	WriteTimeout time.Duration
 	RateLimit    RateLimitConfig
+
+	// HandshakeTimeout bounds the whole handshake performed by Dial and
+	// Listener.Accept, so a peer that stalls mid-handshake cannot block
+	// them indefinitely. The handshake fails with context.DeadlineExceeded.
+	// 0 disables the timeout.
+	HandshakeTimeout time.Duration
+
+	// MaxConcurrentHandshakes limits how many handshakes Listener.Serve
+	// runs at once. Connections beyond the limit wait in the accept queue.
+	// 0 means no limit.
+	MaxConcurrentHandshakes int
+
+	// Session configures sessions created by Dial and Listener.Accept.
+	// The zero value selects the defaults.
+	Session SessionConfig
+
 	// Observer is a shared observer for all sessions (ignored if ObserverFactory is set).
 	Observer Observer
 
@@ -53,6 +133,180 @@ type TransportConfig struct {
 
 	// RateLimitObserver receives notifications when rate limits are hit.
 	RateLimitObserver RateLimitObserver
+
+	// Logger receives structured handshake events: each message sent or
+	// received at debug level, establishment at info level and failures at
+	// warn or error level. Use metrics.NewTunnelLogger to log through a
+	// *metrics.Logger.
+	// nil disables logging.
+	Logger Logger
+
+	// Recorder receives the type, length and direction of every message
+	// sent or received, handshake included, for debugging at the wire
+	// level. Payloads are never recorded. See protocol.NewJSONRecorder and
+	// protocol.NewPcapRecorder.
+	// nil disables recording.
+	Recorder protocol.Recorder
+
+	// Padding pads data records before encryption to hide plaintext sizes.
+	// Dial and Listener negotiate padding in the handshake (see
+	// negotiation.go): records are padded only if both peers enable it,
+	// each with its own mode. Over NewTransport, both peers must use the
+	// same setting.
+	// Default: PaddingNone
+	Padding Padding
+
+	// Compression compresses data records before encryption. It must not be
+	// used when records mix attacker-controlled and secret data (see
+	// Compression). Dial and Listener negotiate compression in the
+	// handshake: records are compressed only if both peers enable the same
+	// algorithm. Over NewTransport, both peers must use the same setting.
+	// Default: CompressionNone
+	Compression Compression
+
+	// RequireCompression makes Dial fail with ErrUnsupportedCompression
+	// when the responder declines Compression, instead of falling back to
+	// uncompressed records.
+	// Default: false
+	RequireCompression bool
+
+	// KeepaliveInterval sends a ping whenever no data has been sent for this
+	// long, keeping idle tunnels alive through NAT and firewalls.
+	// 0 disables keepalives.
+	KeepaliveInterval time.Duration
+
+	// KeepaliveMaxMissed closes the tunnel after this many consecutive pings
+	// go unanswered. Pongs are only processed while Receive is being called.
+	// 0 never closes the tunnel for missed pongs.
+	KeepaliveMaxMissed int
+
+	// ResumptionStore lets a Listener resume returning sessions with an
+	// abbreviated handshake. Clients resume with DialResume or
+	// InitiatorResumptionHandshake using a previous session's ID and
+	// ResumptionSecret.
+	// nil disables abbreviated resumption.
+	ResumptionStore ResumptionStore
+
+	// Allow0RTT enables 0-RTT early data on resumed sessions: DialResume
+	// sends its early data with the ClientHello, and a Listener whose
+	// ResumptionStore implements EarlyDataStore accepts it, exposing it
+	// through Session.EarlyData. Early data has no forward secrecy and may
+	// be replayed to other listeners (see resumption.go), so only enable
+	// this for requests that are safe to repeat.
+	// Default: false
+	Allow0RTT bool
+
+	// HelloRetry makes a Listener demand a stateless cookie before doing
+	// CH-KEM work for a client, limiting the CPU unauthenticated clients
+	// can consume. Disabled by default.
+	HelloRetry HelloRetryConfig
+
+	// HelloReplay makes a Listener reject ClientHellos whose random it has
+	// seen recently. Disabled by default.
+	HelloReplay HelloReplayConfig
+
+	// MaxReorderBuffer enables in-order delivery for transports that may
+	// reorder records: Receive returns data in sequence-number order,
+	// holding up to this many records that arrive ahead of a gap. Receive
+	// fails with ErrReorderBufferFull when a gap outlasts the buffer.
+	// 0 delivers records in arrival order.
+	MaxReorderBuffer int
+
+	// AsyncSend makes Send queue messages for a single writer goroutine,
+	// which coalesces queued records into fewer writes, instead of having
+	// concurrent senders take turns writing. Send blocks while the queue
+	// is full and returns once its message is queued; write errors are
+	// reported to AsyncSendConfig.OnError and by the next Send or Flush.
+	// Disabled by default.
+	AsyncSend AsyncSendConfig
+
+	// SendRateLimit caps the rate at which Send accepts data, to share a
+	// gateway's bandwidth fairly between tunnels. Send blocks until the
+	// token bucket holds enough bytes, or fails if its context is cancelled
+	// or its deadline would pass first. Delays are reported to observers
+	// implementing SendRateLimitObserver.
+	// Disabled by default.
+	SendRateLimit SendRateLimitConfig
+
+	// MaxMessageSize enables fragmentation: Send splits messages that do
+	// not fit in one record across several, and Receive reassembles them,
+	// so each Send yields exactly one Receive. Messages may be up to this
+	// many bytes; Send rejects larger ones and Receive fails with
+	// ErrMessageTooLarge when a peer exceeds the limit. Both peers must
+	// enable fragmentation.
+	// 0 disables fragmentation: Send rejects payloads over MaxPayloadSize.
+	MaxMessageSize int
+
+	// RequireKeyCommitment makes traffic ciphers key-committing (see
+	// crypto.NewCommittingAEAD), so no record can be crafted to decrypt
+	// validly under two different keys. Each record grows by 32 bytes.
+	// The handshake fails with ErrKeyCommitmentMismatch unless both peers
+	// use the same setting.
+	// Default: false
+	RequireKeyCommitment bool
+
+	// AllowNullEncryption lets the handshake negotiate
+	// constants.CipherSuiteNullEncryption, which authenticates records but
+	// sends them UNENCRYPTED, so that traffic can be inspected on the wire.
+	// Initiators allowing it offer it first, and responders allowing it
+	// select it when offered; if either peer does not allow it, an
+	// encrypting suite is negotiated. Records are still authenticated and
+	// replay protected. It is never negotiated in FIPS mode. Only enable
+	// this for lab and compliance deployments.
+	// Default: false
+	AllowNullEncryption bool
+
+	// LockMemory keeps each session's master secret in memory locked
+	// against swapping (mlock on Linux and macOS). Where locking fails, e.g.
+	// beyond RLIMIT_MEMLOCK, the secret is kept unlocked and a warning is
+	// logged through Logger.
+	// Default: false
+	LockMemory bool
+
+	// IdentityKey makes a Listener authenticate to clients by signing each
+	// handshake transcript with this long-term key (see identity.go).
+	// nil leaves the responder unauthenticated.
+	IdentityKey *IdentityKey
+
+	// PinnedServerKey makes Dial require the responder to authenticate with
+	// the identity key with this public key. Handshakes with any other or no
+	// identity fail with ErrAuthenticationFailed.
+	// nil accepts any responder.
+	PinnedServerKey ed25519.PublicKey
+
+	// PSK is an external pre-shared key of at least 32 bytes mixed into the
+	// handshake secret. Peers must share the same PSK to complete the
+	// handshake, which authenticates both sides and keeps traffic
+	// confidential even if both KEMs were broken. Validate, and so Dial
+	// and SetConfig, reject shorter keys.
+	// nil disables PSK authentication.
+	PSK []byte
+
+	// AuthToken is an application-defined token Dial sends to the
+	// responder in the ClientHello, for its Authorizer to check. The token
+	// is sent in the clear, so an eavesdropper can read and replay it: use
+	// short-lived or single-use tokens.
+	// nil sends no token.
+	AuthToken []byte
+
+	// Authorizer makes a Listener check each client before doing any
+	// CH-KEM work for it. It is called with the decoded ClientHello, whose
+	// Extensions hold the client's AuthToken under
+	// protocol.ExtensionAuthToken, and the handshake's context. A non-nil
+	// error aborts the handshake with a HandshakeFailure alert, reported
+	// to observers as HandshakeFailureUnauthorized. It runs before
+	// replayed ClientHellos are refused (see HelloReplay), so that refused
+	// clients do not fill the replay cache, and may see a replayed hello.
+	// nil accepts every client.
+	Authorizer Authorizer
+
+	// EphemeralKeyPair is a pre-generated CH-KEM key pair for the next
+	// Dial to use instead of generating one (see NewSessionWithKeyPair).
+	// It is consumed by that Dial: a config still holding a used key pair
+	// fails to dial with ErrKeyPairConsumed, since reusing a key pair across
+	// sessions breaks forward secrecy. Ignored by listeners.
+	// nil generates a fresh key pair for each session.
+	EphemeralKeyPair *chkem.KeyPair
 }
 
 // RateLimitConfig holds configuration for rate limiting.
@@ -70,19 +324,94 @@ type RateLimitConfig struct {
 	HandshakeBurst int
 }
 
+// SendRateLimitConfig configures per-tunnel egress rate limiting. Only
+// application data passed to Send is counted, not framing, padding or AEAD
+// overhead.
+type SendRateLimitConfig struct {
+	// Rate is the maximum average number of bytes sent per second.
+	// 0 means no limit.
+	Rate float64
+
+	// Burst is the number of bytes that may be sent at once after the
+	// tunnel has been idle. A single Send larger than Burst is allowed and
+	// delays the sends after it.
+	// If 0, defaults to DefaultSendBurst when Rate is set.
+	Burst int
+}
+
+// Defaults set by DefaultTransportConfig.
+const (
+	DefaultHandshakeTimeout        = 10 * time.Second
+	DefaultMaxConcurrentHandshakes = 64
+)
+
 // DefaultTransportConfig returns sensible defaults.
 func DefaultTransportConfig() TransportConfig {
 	return TransportConfig{
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
+		ReadTimeout:             30 * time.Second,
+		WriteTimeout:            30 * time.Second,
+		HandshakeTimeout:        DefaultHandshakeTimeout,
+		MaxConcurrentHandshakes: DefaultMaxConcurrentHandshakes,
+	}
+}
+
+// Validate checks the configuration for errors that would otherwise only
+// surface once a handshake is under way. Dial and SetConfig call it.
+func (c TransportConfig) Validate() error {
+	if len(c.PSK) > 0 && len(c.PSK) < constants.MinPSKSize {
+		return fmt.Errorf("tunnel: PSK must be at least %d bytes, got %d: %w", constants.MinPSKSize, len(c.PSK), qerrors.ErrInvalidKeySize)
+	}
+	return nil
+}
+
+// handshakeContext returns a context bounded by the config's HandshakeTimeout.
+func (c TransportConfig) handshakeContext(parent context.Context) (context.Context, context.CancelFunc) {
+	if c.HandshakeTimeout > 0 {
+		return context.WithTimeout(parent, c.HandshakeTimeout)
 	}
+	return context.WithCancel(parent)
+}
+
+// recordConn wraps conn with the config's Recorder, if any.
+func (c TransportConfig) recordConn(conn net.Conn) net.Conn {
+	if c.Recorder == nil {
+		return conn
+	}
+	return protocol.RecordConn(conn, c.Recorder)
+}
+
+// recordDatagramConn wraps a datagram conn with the config's Recorder, if
+// any.
+func (c TransportConfig) recordDatagramConn(conn net.Conn) net.Conn {
+	if c.Recorder == nil {
+		return conn
+	}
+	return protocol.RecordDatagramConn(conn, c.Recorder)
+}
+
+// configureInitiator applies the config's initiator handshake options.
+func (c TransportConfig) configureInitiator(h *Handshake) {
+	h.SetPinnedServerKey(c.PinnedServerKey)
+	h.SetPSK(c.PSK)
+	h.SetAuthToken(c.AuthToken)
+	h.SetAllowNullEncryption(c.AllowNullEncryption)
+	h.SetCompression(c.Compression, c.RequireCompression)
+	h.SetPadding(c.Padding.Enabled())
 }
 
 // NewTransport creates a new transport over an established session.
 func NewTransport(session *Session, conn net.Conn, config TransportConfig) (*Transport, error) {
+	return newTransport(session, conn, config, nil)
+}
+
+// newTransport creates a transport that calls onClose once when it closes.
+func newTransport(session *Session, conn net.Conn, config TransportConfig, onClose func(*Transport)) (*Transport, error) {
 	if session.State() != SessionStateEstablished {
 		return nil, qerrors.ErrInvalidState
 	}
+	if !config.Compression.IsSupported() {
+		return nil, qerrors.ErrUnsupportedCompression
+	}
 
 	if session.observer == nil {
 		if observer := observerFromConfig(config, session); observer != nil {
@@ -90,105 +419,421 @@ func NewTransport(session *Session, conn net.Conn, config TransportConfig) (*Tra
 		}
 	}
 
-	return &Transport{
+	// Options negotiated in the handshake override the config
+	padding, compression := config.Padding, config.Compression
+	if c, padded, ok := session.recordOptions(); ok {
+		compression = c
+		if !padded {
+			padding = PaddingNone
+		}
+	}
+
+	t := &Transport{
 		session:      session,
 		conn:         conn,
 		codec:        protocol.NewCodec(),
 		readTimeout:  config.ReadTimeout,
 		writeTimeout: config.WriteTimeout,
-	}, nil
+		padding:      padding,
+		compression:  compression,
+		reorder:      newReorderBuffer(config.MaxReorderBuffer),
+		reassembly:   newReassembler(config.MaxMessageSize),
+		sendLimiter:  newSendLimiter(config.SendRateLimit),
+		onClose:      onClose,
+	}
+
+	if config.AsyncSend.Enabled {
+		t.sendQueue = newSendQueue(t, config.AsyncSend)
+	}
+	if config.KeepaliveInterval > 0 {
+		t.startKeepalive(config.KeepaliveInterval, config.KeepaliveMaxMissed)
+	}
+
+	return t, nil
 }
 
 // Send encrypts and sends data over the tunnel.
 func (t *Transport) Send(data []byte) error {
+	return t.SendContext(context.Background(), data)
+}
+
+// SendContext encrypts and sends data over the tunnel, aborting the write
+// if ctx is cancelled or its deadline passes. The effective write deadline
+// is the earlier of ctx's deadline and the configured write timeout.
+//
+// With fragmentation enabled, if ctx is cancelled after part of a message
+// has been sent, the peer fails to reassemble it and the transport should
+// be closed.
+//
+// With TransportConfig.AsyncSend, SendContext copies data into the send
+// queue and ctx only bounds the wait for room in the queue.
+func (t *Transport) SendContext(ctx context.Context, data []byte) error {
+	return t.send(ctx, nil, data)
+}
+
+// SendWithHeader encrypts and sends data like Send, together with a small
+// application header, such as a stream ID, that is kept apart from the data.
+// The peer reads both with ReceiveWithHeader.
+//
+// The header is length-framed inside the record plaintext, so it is
+// encrypted and authenticated along with the data. It is limited to
+// protocol.MaxDataHeaderSize bytes and taken from the record budget, so
+// larger headers leave less room for data. With fragmentation
+// enabled, every fragment of data carries the header. An empty header sends
+// the same record as Send.
+func (t *Transport) SendWithHeader(header, data []byte) error {
+	if len(header) > protocol.MaxDataHeaderSize {
+		return qerrors.ErrMessageTooLarge
+	}
+	return t.send(context.Background(), header, data)
+}
+
+// send encrypts and sends data with an optional application header.
+func (t *Transport) send(ctx context.Context, header, data []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	t.closedMu.RLock()
 	if t.closed {
 		t.closedMu.RUnlock()
 		return qerrors.ErrTunnelClosed
 	}
+	if t.writeClosed {
+		t.closedMu.RUnlock()
+		return qerrors.ErrWriteClosed
+	}
 	t.closedMu.RUnlock()
 
-	if len(data) > constants.MaxPayloadSize {
+	if t.reassembly != nil {
+		if len(data) > t.reassembly.limit {
+			return qerrors.ErrMessageTooLarge
+		}
+	} else if len(data) > constants.MaxPayloadSize {
 		return qerrors.ErrMessageTooLarge
 	}
 
-	// Encrypt data
-	ciphertext, seq, err := t.session.Encrypt(data)
+	if t.sendLimiter != nil {
+		delay, err := t.sendLimiter.wait(ctx, len(data))
+		if err != nil {
+			return err
+		}
+		if o, ok := t.session.observer.(SendRateLimitObserver); ok && delay > 0 {
+			o.OnSendRateLimited(len(data), delay)
+		}
+	}
+
+	if t.sendQueue != nil {
+		return t.sendQueue.enqueue(ctx, queuedSend{header: bytes.Clone(header), data: bytes.Clone(data)})
+	}
+	if t.reassembly != nil {
+		t.fragmentMu.Lock()
+		defer t.fragmentMu.Unlock()
+	}
+	return t.fragment(header, data, func(frame []byte) error {
+		return t.sendRecord(ctx, header, frame)
+	})
+}
+
+// fragment passes data to send as a sequence of fragment records, each
+// sized to leave room for header, or as a single record when fragmentation
+// is disabled.
+func (t *Transport) fragment(header, data []byte, send func(frame []byte) error) error {
+	if t.reassembly == nil {
+		return send(data)
+	}
+
+	size := t.maxRecordData() - dataHeaderOverhead(header)
+	count := fragmentCount(len(data), size)
+	frame := make([]byte, 0, fragmentHeaderSize+min(size, len(data)))
+	for i := 0; i < count; i++ {
+		chunk := data[i*size : min((i+1)*size, len(data))]
+		if err := send(appendFragment(frame[:0], i, count, chunk)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// maxRecordData returns the largest message a single data record can carry
+// after padding, compression and fragment overheads.
+func (t *Transport) maxRecordData() int {
+	size := t.maxPlaintext() - t.padding.Overhead() - t.compression.Overhead()
+	if t.reassembly != nil {
+		size -= fragmentHeaderSize
+	}
+	return size
+}
+
+// sendRecord compresses, pads, encrypts and sends data as one data record.
+func (t *Transport) sendRecord(ctx context.Context, header, data []byte) error {
+	ciphertext, seq, err := t.sealRecord(header, data)
 	if err != nil {
 		return err
 	}
 
-	// Encode as data message
-	msg, err := t.codec.EncodeData(seq, ciphertext)
+	// Encode as data message into a pooled buffer
+	buf := protocol.GetGlobal(protocol.HeaderSize + 8 + len(ciphertext))
+	defer protocol.PutGlobal(buf)
+	msg, err := t.encodeRecord(buf[:0], seq, header, ciphertext)
 	if err != nil {
-		t.recordProtocolError(err)
 		return err
 	}
 
 	// Send with timeout
-	t.writeMu.Lock()
-	defer t.writeMu.Unlock()
+	if err := t.writeMessage(ctx, msg); err != nil {
+		return err
+	}
+	t.recordSent()
 
-	if t.writeTimeout > 0 {
-		_ = t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+	return nil
+}
+
+// appendRecord compresses, pads and encrypts data, and appends it to buf
+// as one encoded data record, carrying header if non-empty.
+func (t *Transport) appendRecord(buf []byte, header, data []byte) ([]byte, error) {
+	ciphertext, seq, err := t.sealRecord(header, data)
+	if err != nil {
+		return buf, err
 	}
+	return t.encodeRecord(buf, seq, header, ciphertext)
+}
 
-	_, err = t.conn.Write(msg)
+// encodeRecord appends a sealed data record to buf, as a DataHeader message
+// if header is non-empty.
+func (t *Transport) encodeRecord(buf []byte, seq uint64, header, ciphertext []byte) ([]byte, error) {
+	var msg []byte
+	var err error
+	if len(header) == 0 {
+		msg, err = t.codec.EncodeDataInto(buf, seq, ciphertext)
+	} else {
+		msg, err = t.codec.EncodeDataHeaderInto(buf, seq, ciphertext)
+	}
 	if err != nil {
-		return err
+		t.recordProtocolError(err)
+		return buf, err
 	}
+	return msg, nil
+}
+
+// sealRecord compresses, pads and encrypts data for one data record,
+// framing header ahead of it when non-empty.
+func (t *Transport) sealRecord(header, data []byte) ([]byte, uint64, error) {
+	limit := t.maxPlaintext()
+
+	// Compress and pad before encryption so both are authenticated
+	frame, err := t.compression.compress(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(header) > 0 {
+		// The header is framed ahead of the compressed data, so it is
+		// encrypted but never compressed
+		framed := make([]byte, 0, dataHeaderOverhead(header)+len(frame))
+		framed = append(framed, byte(len(header)))
+		framed = append(framed, header...)
+		frame = append(framed, frame...)
+	}
+	frame, err = t.padding.pad(frame, limit)
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(frame) > limit {
+		return nil, 0, qerrors.ErrMessageTooLarge
+	}
+	return t.session.Encrypt(frame)
+}
+
+// dataHeaderOverhead returns the bytes a data record spends on header.
+func dataHeaderOverhead(header []byte) int {
+	if len(header) == 0 {
+		return 0
+	}
+	return 1 + len(header)
+}
+
+// splitDataHeader splits the length-framed application header off the
+// unpadded plaintext of a DataHeader record.
+func splitDataHeader(frame []byte) (header, rest []byte, err error) {
+	if len(frame) < 1 {
+		return nil, nil, qerrors.ErrInvalidMessage
+	}
+	headerLen := int(frame[0])
+	if headerLen == 0 || len(frame) < 1+headerLen {
+		return nil, nil, qerrors.ErrInvalidMessage
+	}
+	return frame[1 : 1+headerLen], frame[1+headerLen:], nil
+}
+
+// recordSent notes a data write for keepalives and starts a rekey if one
+// is due.
+func (t *Transport) recordSent() {
+	t.lastSend.Store(time.Now().UnixNano())
 
 	// Check if rekey is needed and initiate if so
 	if err := t.CheckAndRekey(); err != nil {
 		// Log but don't fail the send - rekey errors are non-fatal
 		_ = err
 	}
+}
 
-	return nil
+// Receive reads and decrypts data from the tunnel. The returned slice is
+// owned by the caller and stays valid across later calls. Receive must not
+// be called concurrently. Application headers sent with SendWithHeader are
+// discarded; use ReceiveWithHeader to read them.
+func (t *Transport) Receive() ([]byte, error) {
+	return t.ReceiveContext(context.Background())
 }
 
-// Receive reads and decrypts data from the tunnel.
+// ReceiveContext reads and decrypts data from the tunnel, aborting the read
+// with ctx.Err() if ctx is cancelled or its deadline passes. Once the peer
+// has called CloseWrite and all its data was delivered, it returns io.EOF.
+// A peer that closes the tunnel yields an error matching ErrTunnelClosed,
+// while a connection that ends without a close_notify, such as a TCP reset
+// or a dropped network, yields ErrConnectionReset.
 // Uses an iterative loop instead of recursion to prevent stack overflow
 // from malicious peers sending unbounded control messages (e.g. ping floods).
-func (t *Transport) Receive() ([]byte, error) {
+//
+// If ctx is cancelled after part of a message has been read, the message
+// framing on the underlying connection is lost and the transport should be
+// closed.
+func (t *Transport) ReceiveContext(ctx context.Context) ([]byte, error) {
+	_, data, err := t.receive(ctx, false)
+	return data, err
+}
+
+// ReceiveWithHeader reads and decrypts data from the tunnel like Receive,
+// returning the application header the peer sent it with SendWithHeader.
+// The header is empty for data sent with Send. Both slices are owned by
+// the caller.
+func (t *Transport) ReceiveWithHeader() (header, data []byte, err error) {
+	return t.receive(context.Background(), false)
+}
+
+// ReceiveInto reads and decrypts the next message from the tunnel into buf,
+// returning the number of bytes written. Records are decrypted into a
+// buffer the transport reuses, so steady-state receives do not allocate;
+// buf is never retained. If buf is too small, ReceiveInto returns the
+// message size and io.ErrShortBuffer, and keeps the message for the next
+// receive call. Application headers are discarded, as with Receive.
+func (t *Transport) ReceiveInto(buf []byte) (int, error) {
+	header, data, err := t.receive(context.Background(), true)
+	if err != nil {
+		return 0, err
+	}
+	if len(data) > len(buf) {
+		t.pendingHeader, t.pendingData = header, data
+		return len(data), io.ErrShortBuffer
+	}
+	return copy(buf, data), nil
+}
+
+// receive reads and decrypts the next message and its application header.
+// With into set, the message may alias recvBuf until the next receive;
+// otherwise it is owned by the caller.
+func (t *Transport) receive(ctx context.Context, into bool) ([]byte, []byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	// Deliver a message left behind by ReceiveInto's short buffer
+	if data := t.pendingData; data != nil {
+		header := t.pendingHeader
+		t.pendingHeader, t.pendingData = nil, nil
+		if !into {
+			data = bytes.Clone(data)
+		}
+		return header, data, nil
+	}
+
 	for {
 		if err := t.checkClosed(); err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		msg, msgType, err := t.readMessage()
+		// Deliver records already buffered behind a filled gap
+		if header, data, ok := t.reorder.pop(); ok {
+			msg, complete, err := t.reassembly.add(data)
+			if err != nil {
+				t.recordProtocolError(err)
+				return nil, nil, err
+			}
+			if complete {
+				return header, msg, nil
+			}
+			continue
+		}
+		if t.peerWriteClosed || t.reorder.reachedEOF() {
+			if t.reassembly.pending() {
+				return nil, nil, io.ErrUnexpectedEOF
+			}
+			return nil, nil, io.EOF
+		}
+
+		msg, msgType, err := t.readMessage(ctx)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
 		switch msgType {
-		case protocol.MessageTypeData:
-			data, err := t.handleData(msg)
+		case protocol.MessageTypeData, protocol.MessageTypeDataHeader:
+			if t.reorder != nil {
+				if err := t.bufferData(msg); err != nil {
+					t.recordProtocolError(err)
+					return nil, nil, err
+				}
+				continue
+			}
+			_, header, data, err := t.openRecord(msg, into)
+			if err == nil {
+				var complete bool
+				data, complete, err = t.reassembly.add(data)
+				if err == nil && !complete {
+					continue
+				}
+			}
 			if err != nil {
 				t.recordProtocolError(err)
+				return nil, nil, err
 			}
-			return data, err
+			return header, data, nil
 		case protocol.MessageTypePing:
 			if err := t.sendPong(); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			continue
 		case protocol.MessageTypePong:
+			t.pingsOutstanding.Store(0)
 			continue
 		case protocol.MessageTypeClose:
 			t.markClosed()
-			return nil, qerrors.ErrTunnelClosed
+			return nil, nil, qerrors.ErrTunnelClosed
+		case protocol.MessageTypeCloseWrite:
+			if err := t.handleCloseWrite(msg); err != nil {
+				t.recordProtocolError(err)
+				return nil, nil, err
+			}
+			continue
 		case protocol.MessageTypeRekey:
 			if err := t.handleRekey(msg); err != nil {
 				t.recordProtocolError(err)
-				return nil, err
+				t.failRekey(err)
+				return nil, nil, err
+			}
+			continue
+		case protocol.MessageTypeRatchet:
+			if err := t.handleRatchet(msg); err != nil {
+				t.recordProtocolError(err)
+				t.failRekey(err)
+				return nil, nil, err
 			}
 			continue
 		case protocol.MessageTypeAlert:
-			return t.handleAlert(msg)
+			_, err := t.handleAlert(msg)
+			return nil, nil, err
 		default:
 			t.recordProtocolError(qerrors.ErrInvalidMessage)
-			return nil, qerrors.ErrInvalidMessage
+			return nil, nil, qerrors.ErrInvalidMessage
 		}
 	}
 }
@@ -204,19 +849,45 @@ func (t *Transport) checkClosed() error {
 }
 
 // readMessage reads and validates a message from the connection.
-func (t *Transport) readMessage() ([]byte, protocol.MessageType, error) {
-	if t.readTimeout > 0 {
-		_ = t.conn.SetReadDeadline(time.Now().Add(t.readTimeout))
+func (t *Transport) readMessage(ctx context.Context) ([]byte, protocol.MessageType, error) {
+	if deadline := contextDeadline(ctx, t.readTimeout); !deadline.IsZero() {
+		_ = t.conn.SetReadDeadline(deadline)
 	}
 
-	msg, err := t.codec.ReadMessage(t.conn)
+	var done func(error)
+	if o, ok := t.session.observer.(NetworkObserver); ok {
+		_, done = o.OnNetworkRead(ctx)
+	}
+	stop := interruptOnCancel(ctx, t.conn.SetReadDeadline)
+	var msg []byte
+	var err error
+	if t.packet {
+		msg, err = t.readPacket(ctx)
+	} else {
+		msg, err = t.codec.ReadMessageInto(t.conn, t.readBuf)
+		if cap(msg) > cap(t.readBuf) {
+			t.readBuf = msg[:0]
+		}
+	}
+	stop()
+	if done != nil {
+		done(err)
+	}
 	if err != nil {
-		if err == io.EOF {
-			return nil, 0, qerrors.ErrTunnelClosed
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return nil, 0, ctxErr
+		}
+		if connectionLost(err) {
+			// Our own Close also interrupts the read
+			if closedErr := t.checkClosed(); closedErr != nil {
+				return nil, 0, closedErr
+			}
+			return nil, 0, qerrors.ErrConnectionReset
 		}
 		t.recordProtocolError(err)
 		return nil, 0, err
 	}
+	t.recordRead(msg)
 
 	msgType, err := t.codec.GetMessageType(msg)
 	if err != nil {
@@ -227,14 +898,119 @@ func (t *Transport) readMessage() ([]byte, protocol.MessageType, error) {
 	return msg, msgType, nil
 }
 
+// connectionLost reports whether a read error means the underlying
+// connection ended. A peer that closes the tunnel sends a close_notify
+// alert first, so reaching this without one means the connection was reset
+// or dropped.
+func connectionLost(err error) bool {
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.ErrClosedPipe) ||
+		errors.Is(err, net.ErrClosed) ||
+		errors.Is(err, syscall.ECONNRESET)
+}
+
+// maxPlaintext returns the largest padded plaintext a single data record
+// can carry on this transport.
+func (t *Transport) maxPlaintext() int {
+	size := maxRecordPlaintext
+	if t.packet {
+		size = maxDatagramPlaintext
+	}
+	if t.session.keyCommitment {
+		size -= constants.KeyCommitmentSize
+	}
+	return size
+}
+
+// writeMessage writes an encoded message to the connection, honoring ctx
+// cancellation and the configured write timeout.
+func (t *Transport) writeMessage(ctx context.Context, msg []byte) error {
+	return t.writeMessages(ctx, msg, 1)
+}
+
+// writeMessages is writeMessage for a buffer holding count encoded messages
+// back to back.
+func (t *Transport) writeMessages(ctx context.Context, msg []byte, count int) error {
+	t.writeMu.Lock()
+	defer t.writeMu.Unlock()
+
+	if deadline := contextDeadline(ctx, t.writeTimeout); !deadline.IsZero() {
+		_ = t.conn.SetWriteDeadline(deadline)
+	}
+
+	stop := interruptOnCancel(ctx, t.conn.SetWriteDeadline)
+	err := t.write(msg, count)
+	stop()
+	if err != nil {
+		if ctxErr := contextError(ctx); ctxErr != nil {
+			return ctxErr
+		}
+		return err
+	}
+	return nil
+}
+
+// interruptOnCancel unblocks pending I/O when ctx is cancelled by moving the
+// deadline set by setDeadline into the past. The returned stop function must
+// be called once the I/O completes; if the interrupt fired it clears the
+// expired deadline so later operations are not affected.
+func interruptOnCancel(ctx context.Context, setDeadline func(time.Time) error) (stop func()) {
+	if ctx.Done() == nil {
+		return func() {}
+	}
+
+	fired := make(chan struct{})
+	stopFunc := context.AfterFunc(ctx, func() {
+		_ = setDeadline(aLongTimeAgo)
+		close(fired)
+	})
+	return func() {
+		if !stopFunc() {
+			<-fired
+			_ = setDeadline(time.Time{})
+		}
+	}
+}
+
+// contextError returns ctx's error, or context.DeadlineExceeded if ctx's
+// deadline has passed but its timer has not fired yet. The connection
+// deadline is set to the same instant and may expire first.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if d, ok := ctx.Deadline(); ok && !time.Now().Before(d) {
+		return context.DeadlineExceeded
+	}
+	return nil
+}
+
+// aLongTimeAgo is a non-zero time in the past used to interrupt blocked I/O.
+var aLongTimeAgo = time.Unix(1, 0)
+
+// contextDeadline returns the earlier of ctx's deadline and now+timeout.
+// A zero time means neither is set.
+func contextDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
+
 // handleAlert processes an alert message.
 func (t *Transport) handleAlert(msg []byte) ([]byte, error) {
 	level, code, desc, _ := t.codec.DecodeAlert(msg)
-	if code == protocol.AlertCodeCloseNotify {
+	alert := &AlertError{level: level, code: code, desc: desc}
+	if alert.closed() {
 		t.markClosed()
-		return nil, qerrors.ErrTunnelClosed
+		return nil, alert
 	}
-	err := qerrors.NewProtocolError("alert", &alertError{level: level, code: code, desc: desc})
+	err := qerrors.NewProtocolError("alert", alert)
 	t.recordProtocolError(err)
 	return nil, err
 }
@@ -244,28 +1020,111 @@ func (t *Transport) markClosed() {
 	t.closedMu.Lock()
 	t.closed = true
 	t.closedMu.Unlock()
+	if t.sendLimiter != nil {
+		t.sendLimiter.close()
+	}
+	t.notifyClosed()
+}
+
+// notifyClosed runs the close callback, if any, exactly once.
+func (t *Transport) notifyClosed() {
+	if t.onClose != nil {
+		t.onCloseOnce.Do(func() { t.onClose(t) })
+	}
+}
+
+// handleCloseWrite processes the peer's close-write. With reordering, the
+// end of data is reached once every earlier record has been delivered.
+func (t *Transport) handleCloseWrite(msg []byte) error {
+	seq, ciphertext, err := t.codec.DecodeCloseWrite(msg)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := t.session.Decrypt(ciphertext, seq)
+	if err != nil {
+		return err
+	}
+	if len(plaintext) != 1 || protocol.MessageType(plaintext[0]) != protocol.MessageTypeCloseWrite {
+		return qerrors.ErrInvalidMessage
+	}
+
+	if t.reorder != nil {
+		return t.reorder.closeWrite(seq)
+	}
+	t.peerWriteClosed = true
+	return nil
 }
 
 // handleData processes an encrypted data message.
 func (t *Transport) handleData(msg []byte) ([]byte, error) {
-	// Decode data message
-	seq, ciphertext, err := t.codec.DecodeData(msg)
+	_, data, err := t.openData(msg)
+	return data, err
+}
+
+// bufferData processes an encrypted data message into the reorder buffer.
+func (t *Transport) bufferData(msg []byte) error {
+	seq, header, data, err := t.openRecord(msg, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return t.reorder.push(seq, header, data)
+}
 
-	// Check if we've reached the activation sequence for pending keys
-	if t.session.IsRekeyInProgress() && seq >= t.session.GetRekeyActivationSeq() {
-		t.session.ActivatePendingKeys()
+// openData decrypts a data message, returning its sequence number and
+// unpadded, decompressed payload.
+func (t *Transport) openData(msg []byte) (uint64, []byte, error) {
+	seq, _, data, err := t.openRecord(msg, false)
+	return seq, data, err
+}
+
+// openRecord decrypts a Data or DataHeader message, returning its sequence
+// number, application header and unpadded, decompressed payload. With
+// scratch set, the payload is decrypted into recvBuf and may alias it.
+func (t *Transport) openRecord(msg []byte, scratch bool) (uint64, []byte, []byte, error) {
+	// Decode data message
+	var seq uint64
+	var ciphertext []byte
+	var err error
+	hasHeader := protocol.MessageType(msg[0]) == protocol.MessageTypeDataHeader
+	if hasHeader {
+		seq, ciphertext, err = t.codec.DecodeDataHeader(msg)
+	} else {
+		seq, ciphertext, err = t.codec.DecodeData(msg)
+	}
+	if err != nil {
+		return 0, nil, nil, err
 	}
 
 	// Decrypt
-	plaintext, err := t.session.Decrypt(ciphertext, seq)
+	var dst []byte
+	if scratch {
+		dst = t.recvBuf[:0]
+	}
+	plaintext, err := t.session.decrypt(dst, ciphertext, seq)
 	if err != nil {
-		return nil, err
+		return 0, nil, nil, err
+	}
+	if scratch && cap(plaintext) > cap(t.recvBuf) {
+		t.recvBuf = plaintext[:0]
 	}
 
-	return plaintext, nil
+	frame, err := t.padding.unpad(plaintext)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	var header []byte
+	if hasHeader {
+		if header, frame, err = splitDataHeader(frame); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	data, err := t.compression.decompress(frame)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	// The header aliases the decrypted plaintext
+	return seq, bytes.Clone(header), data, nil
 }
 
 // SendPing sends a keepalive ping.
@@ -286,8 +1145,7 @@ func (t *Transport) SendPing() error {
 		_ = t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
 	}
 
-	_, err := t.conn.Write(msg)
-	return err
+	return t.write(msg, 1)
 }
 
 // sendPong sends a keepalive pong response.
@@ -301,8 +1159,7 @@ func (t *Transport) sendPong() error {
 		_ = t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
 	}
 
-	_, err := t.conn.Write(msg)
-	return err
+	return t.write(msg, 1)
 }
 
 // encodePing creates a ping message.
@@ -330,20 +1187,73 @@ func (t *Transport) sendAlert(level protocol.AlertLevel, code protocol.AlertCode
 
 	// Use a short timeout for alerts
 	_ = t.conn.SetWriteDeadline(time.Now().Add(5 * time.Second))
-	_, err := t.conn.Write(msg)
-	return err
+	return t.write(msg, 1)
+}
+
+// CloseWrite shuts down the sending side of the tunnel. The peer's Receive
+// returns io.EOF after the data sent before it, and later calls to Send fail
+// with ErrWriteClosed. Receiving is unaffected, and rekeys and keepalives
+// continue in both directions until Close. CloseWrite must not be called
+// concurrently with Send; calling it again has no effect.
+func (t *Transport) CloseWrite() error {
+	t.closedMu.Lock()
+	if t.closed {
+		t.closedMu.Unlock()
+		return qerrors.ErrTunnelClosed
+	}
+	if t.writeClosed {
+		t.closedMu.Unlock()
+		return nil
+	}
+	t.writeClosed = true
+	t.closedMu.Unlock()
+
+	// The close-write must follow all queued data
+	if err := t.Flush(); err != nil {
+		return err
+	}
+
+	// Sealed like data so an attacker cannot truncate the stream
+	ciphertext, seq, err := t.session.Encrypt([]byte{byte(protocol.MessageTypeCloseWrite)})
+	if err != nil {
+		return err
+	}
+	msg, err := t.codec.EncodeCloseWrite(seq, ciphertext)
+	if err != nil {
+		return err
+	}
+	return t.writeMessage(context.Background(), msg)
 }
 
-// Close gracefully closes the transport.
+// Close gracefully closes the transport. With TransportConfig.AsyncSend,
+// messages already queued by Send are written first, each write bounded by
+// the write timeout.
 func (t *Transport) Close() error {
+	return t.CloseWithReason(protocol.AlertCodeCloseNotify, "connection closed")
+}
+
+// CloseWithReason gracefully closes the transport like Close, but tells the
+// peer why with an application-chosen alert code and description, which the
+// peer reads from the *AlertError its pending and later calls return.
+// Descriptions longer than 255 bytes are truncated.
+func (t *Transport) CloseWithReason(code protocol.AlertCode, desc string) error {
 	t.closedMu.Lock()
 	if t.closed {
 		t.closedMu.Unlock()
+		t.stopSendQueue()
+		t.stopKeepalive()
 		return nil
 	}
 	t.closed = true
 	t.closedMu.Unlock()
 
+	if t.sendLimiter != nil {
+		t.sendLimiter.close()
+	}
+
+	// Write what Send already queued before the close notification
+	t.stopSendQueue()
+
 	// Send close notification alert with short timeout (best effort)
 	t.closedMu.RLock()
 	isEstablished := t.session.State() == SessionStateEstablished
@@ -352,9 +1262,9 @@ func (t *Transport) Close() error {
 	if isEstablished {
 		// Use a very short timeout for close notification to avoid blocking
 		_ = t.conn.SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
-		msg := t.codec.EncodeAlert(protocol.AlertLevelWarning, protocol.AlertCodeCloseNotify, "connection closed")
+		msg := t.codec.EncodeAlert(protocol.AlertLevelWarning, code, desc)
 		t.writeMu.Lock()
-		_, _ = t.conn.Write(msg)
+		_ = t.write(msg, 1)
 		t.writeMu.Unlock()
 	}
 
@@ -367,6 +1277,9 @@ func (t *Transport) Close() error {
 	// Close the underlying connection
 	_ = t.conn.Close()
 
+	t.stopKeepalive()
+	t.notifyClosed()
+
 	return nil
 }
 
@@ -380,43 +1293,110 @@ func (t *Transport) handleRekey(msg []byte) error {
 		return err
 	}
 
-	// Decrypt inner payload using current session keys
-	plaintext, err := t.session.Decrypt(ciphertext, seq)
+	// Decrypt inner payload using current session keys
+	plaintext, err := t.session.Decrypt(ciphertext, seq)
+	if err != nil {
+		return err
+	}
+
+	// Rekey messages share the data sequence space
+	if err := t.reorder.skip(seq); err != nil {
+		return err
+	}
+
+	// Decode inner payload
+	kind, kemData, activationSeq, err := t.codec.DecodeRekeyPayload(plaintext)
+	if err != nil {
+		return err
+	}
+
+	// A rekey response (ciphertext) completes our own request. The answer
+	// to a request we abandoned is stale; any other response is illegal.
+	if kind == protocol.RekeyKindResponse {
+		if !t.session.awaitingRekeyResponse() {
+			return t.unexpectedRekey(kind, t.session.abandonedRekey(activationSeq))
+		}
+		return t.session.ProcessRekeyResponse(kemData)
+	}
+
+	// Both peers may have sent a request before seeing the other's. The
+	// winner of the tie-break waits for the loser to answer its request.
+	collided, won, err := t.session.resolveRekeyCollision(kemData)
 	if err != nil {
 		return err
 	}
+	if won {
+		return nil
+	}
 
-	// Decode inner payload
-	newPublicKey, activationSeq, err := t.codec.DecodeRekeyPayload(plaintext)
+	// A request that crossed our ratchet is abandoned by the peer when the
+	// ratchet arrives. The peer may not request again before finishing the
+	// rekey we answered, so any other request during a rekey is illegal.
+	if !collided && t.session.IsRekeyInProgress() {
+		return t.unexpectedRekey(kind, t.session.ratchetInProgress())
+	}
+
+	// Prepare response (encapsulate to new key)
+	responseCT, err := t.session.PrepareRekeyResponseDirection(kemData, activationSeq, rekeyDirectionOf(kind))
 	if err != nil {
 		return err
 	}
 
-	// If we're the responder and receive a rekey request
-	if t.session.Role == RoleResponder && !t.session.IsRekeyInProgress() {
-		// Prepare response (encapsulate to new key)
-		responseCT, err := t.session.PrepareRekeyResponse(newPublicKey, activationSeq)
-		if err != nil {
-			return err
-		}
+	// Send encrypted rekey response back
+	return t.sendRekeyResponse(responseCT, activationSeq)
+}
 
-		// Send encrypted rekey response back
-		return t.sendRekeyResponse(responseCT, activationSeq)
+// unexpectedRekey handles a rekey message of the given kind that the rekey
+// state does not expect. A stale message is logged and ignored; otherwise
+// the peer is sent a fatal unexpected-message alert and the returned error
+// closes the tunnel.
+func (t *Transport) unexpectedRekey(kind protocol.RekeyKind, stale bool) error {
+	s := t.session
+	if o, ok := s.observer.(UnexpectedRekeyObserver); ok {
+		o.OnUnexpectedRekey(stale)
+	}
+	if s.logger != nil {
+		s.logger.Named("rekey").Warn("unexpected rekey message", s.logFields(map[string]any{
+			"response": kind == protocol.RekeyKindResponse,
+			"stale":    stale,
+		}))
+	}
+	if stale {
+		return nil
 	}
 
-	// If we're the initiator and receive a rekey response (ciphertext)
-	if t.session.Role == RoleInitiator && t.session.IsRekeyInProgress() {
-		// Process the response
-		if err := t.session.ProcessRekeyResponse(newPublicKey); err != nil {
-			return err
+	_ = t.sendAlert(protocol.AlertLevelFatal, protocol.AlertCodeUnexpectedMessage, "unexpected rekey message")
+	return qerrors.NewProtocolError("rekey", qerrors.ErrUnexpectedRekey)
+}
+
+// rekeyRequestKinds maps each rekey direction to the kind of its request.
+var rekeyRequestKinds = map[RekeyDirection]protocol.RekeyKind{
+	RekeyBoth:    protocol.RekeyKindRequest,
+	RekeySend:    protocol.RekeyKindRequestSend,
+	RekeyReceive: protocol.RekeyKindRequestReceive,
+}
+
+// rekeyDirectionOf returns the direction of a rekey request of the given
+// kind.
+func rekeyDirectionOf(kind protocol.RekeyKind) RekeyDirection {
+	for dir, k := range rekeyRequestKinds {
+		if k == kind {
+			return dir
 		}
 	}
-
-	return nil
+	return RekeyBoth
 }
 
 // SendRekey initiates a rekey operation (called by initiator).
 func (t *Transport) SendRekey() error {
+	return t.SendRekeyDirection(RekeyBoth)
+}
+
+// SendRekeyDirection initiates a rekey of only the given directions (see
+// RekeyDirection), for instance to rotate the send keys of a bulk upload
+// without touching the keys of the lightly used receive direction. Either
+// peer may call it.
+func (t *Transport) SendRekeyDirection(dir RekeyDirection) error {
 	t.closedMu.RLock()
 	if t.closed {
 		t.closedMu.RUnlock()
@@ -430,15 +1410,17 @@ func (t *Transport) SendRekey() error {
 		_, done = observer.OnRekeyStart(context.Background())
 	}
 
+	started := false
 	err := func() error {
 		// Initiate rekey in session
-		newPublicKey, activationSeq, err := t.session.InitiateRekey()
+		newPublicKey, activationSeq, err := t.session.InitiateRekeyDirection(dir)
 		if err != nil {
 			return err
 		}
+		started = true
 
 		// Build inner payload
-		innerPayload, err := t.codec.EncodeRekeyPayload(newPublicKey, activationSeq)
+		innerPayload, err := t.codec.EncodeRekeyPayload(rekeyRequestKinds[dir], newPublicKey, activationSeq)
 		if err != nil {
 			return err
 		}
@@ -463,10 +1445,78 @@ func (t *Transport) SendRekey() error {
 			_ = t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
 		}
 
-		_, err = t.conn.Write(msg)
+		err = t.write(msg, 1)
+		return err
+	}()
+
+	// A request that never reached the peer leaves both sides on the
+	// current keys, so the session stays usable
+	if err != nil && started {
+		t.session.abortRekey()
+		if observer != nil {
+			observer.OnRekeyFailed(err)
+		}
+	}
+
+	if done != nil {
+		done(err)
+	}
+
+	return err
+}
+
+// SendRatchet performs a symmetric rekey (see Session.RatchetRekey) and
+// tells the peer when the new keys activate. Either peer may ratchet.
+func (t *Transport) SendRatchet() error {
+	t.closedMu.RLock()
+	if t.closed {
+		t.closedMu.RUnlock()
+		return qerrors.ErrTunnelClosed
+	}
+	t.closedMu.RUnlock()
+
+	observer := t.session.observer
+	var done func(error)
+	if observer != nil {
+		_, done = observer.OnRekeyStart(context.Background())
+	}
+
+	started := false
+	err := func() error {
+		activationSeq, err := t.session.RatchetRekey()
+		if err != nil {
+			return err
+		}
+		started = true
+
+		ciphertext, seq, err := t.session.Encrypt(t.codec.EncodeRatchetPayload(activationSeq))
+		if err != nil {
+			return err
+		}
+		msg, err := t.codec.EncodeRatchet(seq, ciphertext)
+		if err != nil {
+			return err
+		}
+
+		t.writeMu.Lock()
+		defer t.writeMu.Unlock()
+
+		if t.writeTimeout > 0 {
+			_ = t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
+		}
+
+		err = t.write(msg, 1)
 		return err
 	}()
 
+	// As with SendRekey, a ratchet the peer never saw is abandoned
+	if err != nil && started {
+		t.session.abortRekey()
+		if observer != nil {
+			observer.OnRekeyFailed(err)
+		}
+	}
+
 	if done != nil {
 		done(err)
 	}
@@ -474,10 +1524,45 @@ func (t *Transport) SendRekey() error {
 	return err
 }
 
-// sendRekeyResponse sends an encrypted rekey response (called by responder).
+// handleRatchet processes an incoming encrypted ratchet message.
+func (t *Transport) handleRatchet(msg []byte) error {
+	seq, ciphertext, err := t.codec.DecodeRatchet(msg)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := t.session.Decrypt(ciphertext, seq)
+	if err != nil {
+		return err
+	}
+
+	// Ratchet messages share the data sequence space
+	if err := t.reorder.skip(seq); err != nil {
+		return err
+	}
+
+	activationSeq, err := t.codec.DecodeRatchetPayload(plaintext)
+	if err != nil {
+		return err
+	}
+	return t.session.ProcessRatchet(activationSeq)
+}
+
+// failRekey handles a rekey message that could not be processed. The peers
+// may now disagree on when new keys activate, so the transport is closed
+// rather than risk undecryptable traffic.
+func (t *Transport) failRekey(err error) {
+	if observer := t.session.observer; observer != nil {
+		observer.OnRekeyFailed(err)
+	}
+	t.session.abortRekey()
+	_ = t.Close()
+}
+
+// sendRekeyResponse sends an encrypted rekey response to the requesting peer.
 func (t *Transport) sendRekeyResponse(responseCT []byte, activationSeq uint64) error {
 	// Build inner payload (ciphertext in place of public key for response)
-	innerPayload, err := t.codec.EncodeRekeyPayload(responseCT, activationSeq)
+	innerPayload, err := t.codec.EncodeRekeyPayload(protocol.RekeyKindResponse, responseCT, activationSeq)
 	if err != nil {
 		return err
 	}
@@ -501,8 +1586,7 @@ func (t *Transport) sendRekeyResponse(responseCT []byte, activationSeq uint64) e
 		_ = t.conn.SetWriteDeadline(time.Now().Add(t.writeTimeout))
 	}
 
-	_, err = t.conn.Write(msg)
-	return err
+	return t.write(msg, 1)
 }
 
 // CheckAndRekey checks if rekey is needed and initiates it if so.
@@ -523,11 +1607,122 @@ func (t *Transport) CheckAndRekey() error {
 	return nil
 }
 
+// rekeyIdle rekeys a transport that nobody is receiving on, such as an
+// idle pooled connection. It sends a rekey request, reads control messages
+// until the peer answers, and then activates the new keys at once: the
+// peer switches to them when the next record authenticates under them.
+// A data record arriving meanwhile has no reader, so it fails with
+// ErrInvalidMessage. On failure the transport should be closed, since
+// ctx may have interrupted a read mid-message.
+func (t *Transport) rekeyIdle(ctx context.Context) error {
+	if err := t.SendRekey(); err != nil {
+		return err
+	}
+
+	for !t.session.rekeyAnswered() {
+		msg, msgType, err := t.readMessage(ctx)
+		if err != nil {
+			return err
+		}
+
+		switch msgType {
+		case protocol.MessageTypeRekey:
+			if err := t.handleRekey(msg); err != nil {
+				t.recordProtocolError(err)
+				t.failRekey(err)
+				return err
+			}
+		case protocol.MessageTypeRatchet:
+			if err := t.handleRatchet(msg); err != nil {
+				t.recordProtocolError(err)
+				t.failRekey(err)
+				return err
+			}
+		case protocol.MessageTypePing:
+			if err := t.sendPong(); err != nil {
+				return err
+			}
+		case protocol.MessageTypePong:
+			t.pingsOutstanding.Store(0)
+		case protocol.MessageTypeClose:
+			t.markClosed()
+			return qerrors.ErrTunnelClosed
+		case protocol.MessageTypeAlert:
+			_, err := t.handleAlert(msg)
+			return err
+		default:
+			t.recordProtocolError(qerrors.ErrInvalidMessage)
+			return qerrors.ErrInvalidMessage
+		}
+	}
+
+	t.session.ActivatePendingKeys()
+	return nil
+}
+
 // Session returns the underlying session.
 func (t *Transport) Session() *Session {
 	return t.session
 }
 
+// ConnectionState describes the parameters negotiated for a tunnel.
+type ConnectionState struct {
+	// Version is the negotiated protocol version.
+	Version protocol.Version
+
+	// CipherSuite is the negotiated cipher suite.
+	CipherSuite constants.CipherSuite
+
+	// Resumed reports whether the session was established by an
+	// abbreviated handshake.
+	Resumed bool
+
+	// PeerFingerprint is the IdentityFingerprint of the identity key the
+	// responder authenticated with, and is only set on initiators talking
+	// to an authenticated responder.
+	PeerFingerprint [chkem.FingerprintSize]byte
+
+	// PeerAuthenticated reports whether PeerFingerprint is set.
+	PeerAuthenticated bool
+
+	// Compression is the record compression in use.
+	Compression Compression
+
+	// Padding reports whether records are padded.
+	Padding bool
+
+	// EstablishedAt is when the handshake completed.
+	EstablishedAt time.Time
+
+	// SendSeq is the sequence number of the next record sent.
+	SendSeq uint64
+
+	// RecvSeq is one past the highest sequence number received, or 0 if
+	// nothing has been received.
+	RecvSeq uint64
+}
+
+// ConnectionState returns the tunnel's negotiated parameters, for logging
+// and diagnostics.
+func (t *Transport) ConnectionState() ConnectionState {
+	s := t.session
+	state := ConnectionState{
+		Version:       s.Version,
+		CipherSuite:   s.CipherSuite,
+		Resumed:       s.Resumed(),
+		Compression:   t.compression,
+		Padding:       t.padding.Enabled(),
+		EstablishedAt: s.handshakeAt,
+		SendSeq:       s.sendSeq.Load(),
+		RecvSeq:       s.recvSeq.Load(),
+	}
+	if identity := s.RemoteIdentity(); identity != nil {
+		state.PeerFingerprint = IdentityFingerprint(identity)
+		state.PeerAuthenticated = true
+	}
+	return state
+}
+
 // LocalAddr returns the local network address.
 func (t *Transport) LocalAddr() net.Addr {
 	return t.conn.LocalAddr()
@@ -557,14 +1752,51 @@ func (t *Transport) recordProtocolError(err error) {
 	}
 }
 
-// alertError represents an alert received from the peer.
-type alertError struct {
+// AlertError represents an alert received from the peer. Use errors.As to
+// inspect the alert code:
+//
+//	var alert *tunnel.AlertError
+//	if errors.As(err, &alert) && alert.Code() == protocol.AlertCodeBadCiphertext {
+//		// ...
+//	}
+//
+// A close_notify alert, or any warning alert such as the one sent by
+// CloseWithReason, closes the tunnel gracefully and also matches
+// ErrTunnelClosed with errors.Is.
+type AlertError struct {
 	level protocol.AlertLevel
 	code  protocol.AlertCode
 	desc  string
 }
 
-func (e *alertError) Error() string {
+// Code returns the alert code sent by the peer.
+func (e *AlertError) Code() protocol.AlertCode {
+	return e.code
+}
+
+// Level returns the alert severity sent by the peer.
+func (e *AlertError) Level() protocol.AlertLevel {
+	return e.level
+}
+
+// Description returns the peer's description of the alert, if any.
+func (e *AlertError) Description() string {
+	return e.desc
+}
+
+// Is reports whether a graceful close is being matched against
+// ErrTunnelClosed, so callers checking for a closed tunnel keep working.
+func (e *AlertError) Is(target error) bool {
+	return e.closed() && target == qerrors.ErrTunnelClosed
+}
+
+// closed reports whether the alert closes the tunnel gracefully: only
+// Close and CloseWithReason send warning alerts.
+func (e *AlertError) closed() bool {
+	return e.code == protocol.AlertCodeCloseNotify || e.level == protocol.AlertLevelWarning
+}
+
+func (e *AlertError) Error() string {
 	prefix := "alert (warning): "
 	if e.level == protocol.AlertLevelFatal {
 		prefix = "alert (fatal): "
@@ -590,25 +1822,86 @@ func Dial(network, address string) (*Tunnel, error) {
 
 // DialWithConfig establishes a new tunnel with custom configuration.
 func DialWithConfig(network, address string, config TransportConfig) (*Tunnel, error) {
+	transport, err := dialInitiator(network, address, config, config.configureInitiator)
+	if err != nil {
+		return nil, err
+	}
+	return &Tunnel{Transport: transport}, nil
+}
+
+// DialResume establishes a tunnel by resuming an earlier session from its ID
+// and ResumptionSecret, against a Listener with a ResumptionStore. Unknown or
+// expired sessions fall back to a full handshake.
+//
+// A non-empty earlyData is delivered to the responder as the tunnel's first
+// data. With config.Allow0RTT it is sent as 0-RTT early data in the first
+// flight; if the responder rejects it, or Allow0RTT is false, it is sent
+// with Send once the handshake completes. Session().EarlyDataAccepted
+// reports which happened.
+func DialResume(network, address string, config TransportConfig, sessionID, secret, earlyData []byte) (*Tunnel, error) {
+	transport, err := dialInitiator(network, address, config, func(h *Handshake) {
+		config.configureInitiator(h)
+		h.SetTicket(sessionID, secret)
+		if config.Allow0RTT {
+			h.SetEarlyData(earlyData)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel := &Tunnel{Transport: transport}
+	if len(earlyData) > 0 && !transport.session.EarlyDataAccepted() {
+		if err := tunnel.Send(earlyData); err != nil {
+			_ = tunnel.Close()
+			return nil, err
+		}
+	}
+	return tunnel, nil
+}
+
+// dialInitiator connects to address and runs the initiator handshake set up
+// by configure, bounded by the config's HandshakeTimeout.
+func dialInitiator(network, address string, config TransportConfig, configure func(*Handshake)) (*Transport, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	// Connect
 	conn, err := net.Dial(network, address)
 	if err != nil {
 		return nil, err
 	}
+	conn = config.recordConn(conn)
+
+	return establishInitiator(conn, config, func(session *Session) error {
+		ctx, cancel := config.handshakeContext(context.Background())
+		defer cancel()
+		return withHandshakeDeadline(ctx, conn, func() error {
+			return runInitiatorHandshake(session, conn, configure)
+		})
+	})
+}
 
+// establishInitiator creates an initiator session, runs handshake on it and
+// returns a transport over conn. conn is closed on failure.
+func establishInitiator(conn net.Conn, config TransportConfig, handshake func(*Session) error) (*Transport, error) {
 	// Create session as initiator
-	session, err := NewSession(RoleInitiator)
+	session, err := newSession(RoleInitiator, config.Session, config.EphemeralKeyPair)
 	if err != nil {
 		_ = conn.Close()
 		return nil, err
 	}
+	session.SetLogger(config.Logger)
+	session.SetKeyCommitment(config.RequireKeyCommitment)
+	session.SetLockMemory(config.LockMemory)
 	if observer := observerFromConfig(config, session); observer != nil {
 		session.SetObserver(observer)
 		observer.OnSessionStart()
 	}
 
 	// Perform handshake
-	if err := InitiatorHandshake(session, conn); err != nil {
+	if err := handshake(session); err != nil {
 		if session.observer != nil {
 			session.observer.OnSessionFailed(err)
 			session.observer.OnSessionEnd()
@@ -628,7 +1921,7 @@ func DialWithConfig(network, address string, config TransportConfig) (*Tunnel, e
 		return nil, err
 	}
 
-	return &Tunnel{Transport: transport}, nil
+	return transport, nil
 }
 
 // Listen creates a listener for incoming tunnel connections.
@@ -637,11 +1930,19 @@ func Listen(network, address string) (*Listener, error) {
 	if err != nil {
 		return nil, err
 	}
+	return newListener(ln), nil
+}
 
+// newListener creates a tunnel listener accepting connections from ln.
+func newListener(ln net.Listener) *Listener {
+	config := DefaultTransportConfig()
 	return &Listener{
-		listener: ln,
-		config:   DefaultTransportConfig(),
-	}, nil
+		listener:    ln,
+		config:      config,
+		limits:      newRateLimiters(config.RateLimit),
+		cookies:     newCookieJar(config.HelloRetry),
+		helloReplay: newHelloReplayCache(config.HelloReplay),
+	}
 }
 
 // Listener accepts incoming tunnel connections.
@@ -649,21 +1950,129 @@ type Listener struct {
 	listener net.Listener
 	config   TransportConfig
 
-	ipLimiter        *IPRateLimiter
-	handshakeLimiter *HandshakeLimiter
+	limits      *rateLimiters
+	cookies     *cookieJar
+	helloReplay *helloReplayCache
+
+	// Chain applied to accepted tunnels (see Use)
+	middleware []Middleware
+
+	// Registry of accepted tunnels that have not yet closed
+	mu        sync.Mutex
+	tunnels   map[*Transport]*Tunnel
+	draining  bool
+	drainDone chan struct{} // Closed when the registry empties during Drain
 }
 
-// Accept waits for and returns the next tunnel connection.
+// Accept waits for and returns the next tunnel connection. Tunnels
+// rejected by the middleware chain (see Use) are closed and skipped.
 func (l *Listener) Accept() (*Tunnel, error) {
-	conn, err := l.listener.Accept()
-	if err != nil {
-		return nil, err
+	for {
+		conn, err := l.listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		tunnel, err := l.establish(context.Background(), conn)
+		if errors.Is(err, errTunnelRejected) {
+			continue
+		}
+		return tunnel, err
+	}
+}
+
+// Bounds of the delay before Serve retries a failed Accept, which doubles
+// with each consecutive failure.
+const (
+	minAcceptRetryDelay = 5 * time.Millisecond
+	maxAcceptRetryDelay = time.Second
+)
+
+// Serve accepts connections and performs each handshake in its own
+// goroutine, so a slow client cannot hold up the others, then calls handler
+// with each established tunnel. At most MaxConcurrentHandshakes handshakes
+// run at once; further connections wait in the accept queue. Connections
+// whose handshake fails or times out, or that the middleware chain rejects
+// (see Use), are closed and dropped.
+//
+// Serve returns when the listener is closed or drained, or when ctx is
+// cancelled, which closes the listener and aborts in-flight handshakes. It
+// waits for in-flight handshakes but not for running handlers. Other accept
+// errors, such as running out of file descriptors, may clear up, so like
+// net/http's Server, Serve logs them and retries with a growing delay.
+func (l *Listener) Serve(ctx context.Context, handler func(*Tunnel)) error {
+	stop := context.AfterFunc(ctx, func() { _ = l.listener.Close() })
+	defer stop()
+
+	var sem chan struct{}
+	if l.config.MaxConcurrentHandshakes > 0 {
+		sem = make(chan struct{}, l.config.MaxConcurrentHandshakes)
+	}
+	var wg sync.WaitGroup
+	defer wg.Wait()
+
+	var retryDelay time.Duration
+	for {
+		if sem != nil {
+			select {
+			case sem <- struct{}{}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		conn, err := l.listener.Accept()
+		if err != nil {
+			if sem != nil {
+				<-sem
+			}
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+
+			retryDelay = min(max(2*retryDelay, minAcceptRetryDelay), maxAcceptRetryDelay)
+			if l.config.Logger != nil {
+				l.config.Logger.Named("listener").Warn("accept failed, retrying", map[string]any{
+					"error": err.Error(),
+					"delay": retryDelay.String(),
+				})
+			}
+			timer := time.NewTimer(retryDelay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			}
+			continue
+		}
+		retryDelay = 0
+
+		wg.Add(1)
+		go func() {
+			tunnel, err := l.establish(ctx, conn)
+			if sem != nil {
+				<-sem
+			}
+			wg.Done()
+			if err == nil {
+				handler(tunnel)
+			}
+		}()
 	}
+}
 
+// establish performs the responder handshake on an accepted connection,
+// applies the middleware chain and registers the resulting tunnel. conn is
+// closed on failure.
+func (l *Listener) establish(ctx context.Context, conn net.Conn) (*Tunnel, error) {
 	remoteIP := extractRemoteIP(conn)
+	conn = l.config.recordConn(conn)
 
 	// Check IP rate limit
-	conn, err = l.checkIPRateLimit(conn, remoteIP)
+	conn, err := l.checkIPRateLimit(conn, remoteIP)
 	if err != nil {
 		return nil, err
 	}
@@ -676,19 +2085,98 @@ func (l *Listener) Accept() (*Tunnel, error) {
 	}
 
 	// Check handshake rate limit and perform handshake
-	if err := l.performHandshake(session, conn, remoteIP); err != nil {
+	if err := l.performHandshake(ctx, session, conn, remoteIP); err != nil {
 		return nil, err
 	}
 
 	// Create transport
-	transport, err := NewTransport(session, conn, l.config)
+	transport, err := newTransport(session, conn, l.config, l.untrack)
 	if err != nil {
 		l.failSession(session, err)
 		_ = conn.Close()
 		return nil, err
 	}
 
-	return &Tunnel{Transport: transport}, nil
+	tunnel, err := l.applyMiddleware(&Tunnel{Transport: transport})
+	if err != nil {
+		return nil, err
+	}
+	if !l.track(tunnel) {
+		// Drain started while the handshake was in progress
+		_ = tunnel.Close()
+		return nil, net.ErrClosed
+	}
+	return tunnel, nil
+}
+
+// track registers an accepted tunnel. It returns false if the listener is draining.
+func (l *Listener) track(tunnel *Tunnel) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.draining {
+		return false
+	}
+	if l.tunnels == nil {
+		l.tunnels = make(map[*Transport]*Tunnel)
+	}
+	l.tunnels[tunnel.Transport] = tunnel
+	return true
+}
+
+// untrack removes a closed tunnel from the registry.
+func (l *Listener) untrack(t *Transport) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.tunnels, t)
+	if l.drainDone != nil && len(l.tunnels) == 0 {
+		close(l.drainDone)
+		l.drainDone = nil
+	}
+}
+
+// Drain stops accepting new tunnels and waits for all tunnels returned by
+// Accept or passed to a Serve handler to close.
+//
+// If ctx expires first, the remaining tunnels are closed and ctx's error is
+// returned. Accept and Serve return an error once Drain has been called.
+func (l *Listener) Drain(ctx context.Context) error {
+	l.mu.Lock()
+	l.draining = true
+	var done chan struct{}
+	if len(l.tunnels) > 0 {
+		if l.drainDone == nil {
+			l.drainDone = make(chan struct{})
+		}
+		done = l.drainDone
+	}
+	l.mu.Unlock()
+
+	_ = l.listener.Close()
+
+	if done == nil {
+		return nil
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+	}
+
+	// Force-close stragglers outside the lock; Close calls back into untrack
+	l.mu.Lock()
+	remaining := make([]*Tunnel, 0, len(l.tunnels))
+	for _, tunnel := range l.tunnels {
+		remaining = append(remaining, tunnel)
+	}
+	l.mu.Unlock()
+
+	for _, tunnel := range remaining {
+		_ = tunnel.Close()
+	}
+	return ctx.Err()
 }
 
 // extractRemoteIP extracts the IP address from a connection.
@@ -703,16 +2191,10 @@ func extractRemoteIP(conn net.Conn) string {
 	return conn.RemoteAddr().String()
 }
 
-// checkIPRateLimit checks IP rate limiting and wraps the connection if needed.
+// checkIPRateLimit checks IP rate limiting and wraps the connection to
+// release its slot on close.
 func (l *Listener) checkIPRateLimit(conn net.Conn, remoteIP string) (net.Conn, error) {
-	if l.ipLimiter == nil {
-		return conn, nil
-	}
-
-	if !l.ipLimiter.AllowConnection(remoteIP) {
-		if l.config.RateLimitObserver != nil {
-			l.config.RateLimitObserver.OnConnectionRateLimit(remoteIP)
-		}
+	if !l.limits.allowConnection(remoteIP, l.config.RateLimitObserver) {
 		_ = conn.Close()
 		return nil, newRateLimitError("connection rate limit exceeded")
 	}
@@ -720,7 +2202,7 @@ func (l *Listener) checkIPRateLimit(conn net.Conn, remoteIP string) (net.Conn, e
 	// Wrap connection to release IP limit on close
 	return &rateLimitedConn{
 		Conn:      conn,
-		limiter:   l.ipLimiter,
+		limiter:   l.limits.ip,
 		ip:        remoteIP,
 		closeOnce: sync.Once{},
 	}, nil
@@ -728,10 +2210,13 @@ func (l *Listener) checkIPRateLimit(conn net.Conn, remoteIP string) (net.Conn, e
 
 // createSession creates a new responder session with observer.
 func (l *Listener) createSession() (*Session, error) {
-	session, err := NewSession(RoleResponder)
+	session, err := NewSessionWithConfig(RoleResponder, l.config.Session)
 	if err != nil {
 		return nil, err
 	}
+	session.SetLogger(l.config.Logger)
+	session.SetKeyCommitment(l.config.RequireKeyCommitment)
+	session.SetLockMemory(l.config.LockMemory)
 	if observer := observerFromConfig(l.config, session); observer != nil {
 		session.SetObserver(observer)
 		observer.OnSessionStart()
@@ -740,18 +2225,31 @@ func (l *Listener) createSession() (*Session, error) {
 }
 
 // performHandshake checks handshake rate limit and performs the handshake.
-func (l *Listener) performHandshake(session *Session, conn net.Conn, remoteIP string) error {
-	if l.handshakeLimiter != nil && !l.handshakeLimiter.AllowHandshake() {
-		if l.config.RateLimitObserver != nil {
-			l.config.RateLimitObserver.OnHandshakeRateLimit(remoteIP)
-		}
+func (l *Listener) performHandshake(ctx context.Context, session *Session, conn net.Conn, remoteIP string) error {
+	if !l.limits.allowHandshake(remoteIP, l.config.RateLimitObserver) {
 		_ = conn.Close()
 		err := newRateLimitError("handshake rate limit exceeded")
 		l.failSession(session, err)
 		return err
 	}
 
-	if err := ResponderHandshake(session, conn); err != nil {
+	ctx, cancel := l.config.handshakeContext(ctx)
+	defer cancel()
+	err := withHandshakeDeadline(ctx, conn, func() error {
+		return runResponderHandshake(session, conn, func(h *Handshake) {
+			h.SetResumptionStore(l.config.ResumptionStore)
+			h.SetAllowEarlyData(l.config.Allow0RTT)
+			h.SetIdentityKey(l.config.IdentityKey)
+			h.SetPSK(l.config.PSK)
+			h.SetAuthorizer(ctx, l.config.Authorizer)
+			h.SetAllowNullEncryption(l.config.AllowNullEncryption)
+			h.SetCompression(l.config.Compression, false)
+			h.SetPadding(l.config.Padding.Enabled())
+			h.requireCookie(l.cookies, conn.RemoteAddr())
+			h.rejectReplayedHellos(l.helloReplay)
+		})
+	})
+	if err != nil {
 		l.failSession(session, err)
 		_ = conn.Close()
 		return err
@@ -769,7 +2267,7 @@ func (l *Listener) failSession(session *Session, err error) {
 
 // newRateLimitError creates a protocol error for rate limiting.
 func newRateLimitError(desc string) error {
-	return qerrors.NewProtocolError("rate limit", &alertError{
+	return qerrors.NewProtocolError("rate limit", &AlertError{
 		level: protocol.AlertLevelFatal,
 		code:  protocol.AlertCodeInternalError,
 		desc:  desc,
@@ -786,21 +2284,32 @@ func (l *Listener) Addr() net.Addr {
 	return l.listener.Addr()
 }
 
-// SetConfig sets the transport configuration for new connections.
-func (l *Listener) SetConfig(config TransportConfig) {
+// SetConfig sets the transport configuration for new connections. An
+// invalid configuration is rejected and the previous one kept.
+func (l *Listener) SetConfig(config TransportConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
 	l.config = config
 	// Re-initialize limiters based on new config
-	if config.RateLimit.MaxConnectionsPerIP > 0 {
-		l.ipLimiter = NewIPRateLimiter(config.RateLimit.MaxConnectionsPerIP)
-	} else {
-		l.ipLimiter = nil
-	}
+	l.limits = newRateLimiters(config.RateLimit)
+	l.cookies = newCookieJar(config.HelloRetry)
+	l.helloReplay = newHelloReplayCache(config.HelloReplay)
+	return nil
+}
 
-	if config.RateLimit.HandshakeRateLimit > 0 {
-		l.handshakeLimiter = NewHandshakeLimiter(config.RateLimit.HandshakeRateLimit, config.RateLimit.HandshakeBurst)
-	} else {
-		l.handshakeLimiter = nil
-	}
+// UpdateRateLimits changes the rate limits while the listener is running.
+// Unlike SetConfig, it keeps the per-IP counts of open connections, so a
+// lowered MaxConnectionsPerIP refuses new connections from an IP until
+// enough of its open ones close; none are closed.
+func (l *Listener) UpdateRateLimits(limits RateLimitConfig) {
+	l.limits.update(limits)
+}
+
+// RateLimitStats returns the rate limits in force, the open connections
+// per IP and the handshake token bucket state.
+func (l *Listener) RateLimitStats() RateLimitStats {
+	return l.limits.stats()
 }
 
 // rateLimitedConn wraps a net.Conn to release the IP rate limit on close.
@@ -2,12 +2,20 @@ package tunnel
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
 	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
 	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 	"github.com/sara-star-quant/quantum-go/pkg/protocol"
 )
@@ -60,6 +68,86 @@ func TestTransportAlerts(t *testing.T) {
 	wg.Wait()
 }
 
+func TestTransportAlertCodes(t *testing.T) {
+	codes := []protocol.AlertCode{
+		protocol.AlertCodeUnexpectedMessage,
+		protocol.AlertCodeBadCiphertext,
+		protocol.AlertCodeHandshakeFailure,
+		protocol.AlertCodeUnsupportedVersion,
+		protocol.AlertCodeUnsupportedCipher,
+		protocol.AlertCodeDecryptionFailed,
+		protocol.AlertCodeInternalError,
+		protocol.AlertCodeCloseNotify,
+	}
+
+	for _, code := range codes {
+		t.Run(fmt.Sprintf("code_%d", code), func(t *testing.T) {
+			client, server := newPipeTransports(t)
+
+			go func() {
+				_ = client.sendAlert(protocol.AlertLevelFatal, code, "")
+			}()
+
+			_, err := server.Receive()
+			var alert *AlertError
+			if !errors.As(err, &alert) {
+				t.Fatalf("expected *AlertError, got %v", err)
+			}
+			if alert.Code() != code {
+				t.Errorf("Code() = %d, want %d", alert.Code(), code)
+			}
+			if alert.Level() != protocol.AlertLevelFatal {
+				t.Errorf("Level() = %d, want fatal", alert.Level())
+			}
+
+			closed := errors.Is(err, qerrors.ErrTunnelClosed)
+			if want := code == protocol.AlertCodeCloseNotify; closed != want {
+				t.Errorf("errors.Is(err, ErrTunnelClosed) = %v, want %v", closed, want)
+			}
+		})
+	}
+}
+
+func TestTransportCloseWithReason(t *testing.T) {
+	const draining = protocol.AlertCode(2)
+	tests := []struct {
+		name string
+		desc string
+		want string
+	}{
+		{"short", "server draining", "server draining"},
+		{"truncated", strings.Repeat("d", 300), strings.Repeat("d", 255)},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			client, server := newPipeTransports(t)
+
+			go func() {
+				_ = server.CloseWithReason(draining, tc.desc)
+			}()
+
+			_, err := client.Receive()
+			var alert *AlertError
+			if !errors.As(err, &alert) {
+				t.Fatalf("expected *AlertError, got %v", err)
+			}
+			if alert.Code() != draining || alert.Level() != protocol.AlertLevelWarning {
+				t.Errorf("alert code %d level %d, want code %d level warning", alert.Code(), alert.Level(), draining)
+			}
+			if alert.Description() != tc.want {
+				t.Errorf("Description() = %q, want %q", alert.Description(), tc.want)
+			}
+			if !errors.Is(err, qerrors.ErrTunnelClosed) {
+				t.Error("a close with a reason should match ErrTunnelClosed")
+			}
+			if err := client.Send([]byte("late")); !errors.Is(err, qerrors.ErrTunnelClosed) {
+				t.Errorf("Send after the peer closed = %v, want ErrTunnelClosed", err)
+			}
+		})
+	}
+}
+
 func TestTransportPingPong(t *testing.T) {
 	clientConn, serverConn := net.Pipe()
 	defer func() { _ = clientConn.Close() }()
@@ -187,8 +275,52 @@ func TestTransportGracefulClose(t *testing.T) {
 	}()
 
 	_, err := server.Receive()
-	if err == nil {
-		t.Fatal("expected error from close, got nil")
+	if !errors.Is(err, qerrors.ErrTunnelClosed) || errors.Is(err, qerrors.ErrConnectionReset) {
+		t.Fatalf("expected a graceful ErrTunnelClosed, got %v", err)
+	}
+}
+
+func TestReceiveConnectionReset(t *testing.T) {
+	tests := []struct {
+		name string
+		drop func(client *Transport)
+	}{
+		{"between messages", func(client *Transport) {
+			_ = client.conn.Close()
+		}},
+		{"mid-message", func(client *Transport) {
+			// Half a message header, then the connection drops
+			_, _ = client.conn.Write([]byte{byte(protocol.MessageTypeData), 0})
+			_ = client.conn.Close()
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := newPipeTransports(t)
+
+			go func() {
+				_ = client.Send([]byte("before the drop"))
+				tt.drop(client)
+			}()
+
+			if data, err := server.Receive(); err != nil || string(data) != "before the drop" {
+				t.Fatalf("Receive = %q, %v; want the data sent before the drop", data, err)
+			}
+			_, err := server.Receive()
+			if !errors.Is(err, qerrors.ErrConnectionReset) || errors.Is(err, qerrors.ErrTunnelClosed) {
+				t.Fatalf("expected ErrConnectionReset, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReceiveAfterLocalClose(t *testing.T) {
+	_, server := newPipeTransports(t)
+
+	// Closing our own transport interrupts a pending Receive gracefully
+	time.AfterFunc(50*time.Millisecond, func() { _ = server.Close() })
+	if _, err := server.Receive(); !errors.Is(err, qerrors.ErrTunnelClosed) {
+		t.Fatalf("expected ErrTunnelClosed, got %v", err)
 	}
 }
 
@@ -526,3 +658,1577 @@ func TestRekeyThenDataExchange(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+// newPipeTransports returns a connected client/server transport pair over net.Pipe.
+func newPipeTransports(t *testing.T) (*Transport, *Transport) {
+	t.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	t.Cleanup(func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	})
+
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+
+	clientSession, _ := NewSession(RoleInitiator)
+	_ = clientSession.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	serverSession, _ := NewSession(RoleResponder)
+	_ = serverSession.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	client := &Transport{session: clientSession, conn: clientConn, codec: protocol.NewCodec()}
+	server := &Transport{session: serverSession, conn: serverConn, codec: protocol.NewCodec()}
+	return client, server
+}
+
+func TestReceiveContextCancel(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := server.ReceiveContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	// The expired deadline must not leak into later calls
+	go func() { _ = client.Send([]byte("after cancel")) }()
+	data, err := server.Receive()
+	if err != nil {
+		t.Fatalf("Receive after cancel failed: %v", err)
+	}
+	if string(data) != "after cancel" {
+		t.Errorf("got %q, want %q", data, "after cancel")
+	}
+}
+
+func TestReceiveContextCancelPartialRead(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// Write only part of a header, then cancel while the reader waits for the rest
+		_, _ = client.conn.Write([]byte{byte(protocol.MessageTypeData), 0})
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := server.ReceiveContext(ctx)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestReceiveContextDeadline(t *testing.T) {
+	_, server := newPipeTransports(t)
+	server.readTimeout = time.Minute
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := server.ReceiveContext(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("context deadline not honored, took %v", elapsed)
+	}
+}
+
+func TestSendContextCancel(t *testing.T) {
+	client, _ := newPipeTransports(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := client.SendContext(ctx, []byte("x")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled for cancelled context, got %v", err)
+	}
+
+	// Nobody reads from the pipe, so the write blocks until cancellation
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if err := client.SendContext(ctx, []byte("blocked")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+}
+
+// rekeyObserver records rekey outcomes and ignores all other events.
+type rekeyObserver struct {
+	mu         sync.Mutex
+	completed  int
+	failures   []error
+	unexpected []bool
+}
+
+func (o *rekeyObserver) OnSessionStart()       {}
+func (o *rekeyObserver) OnSessionEnd()         {}
+func (o *rekeyObserver) OnSessionFailed(error) {}
+func (o *rekeyObserver) OnHandshakeStart(ctx context.Context) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+func (o *rekeyObserver) OnEncrypt(ctx context.Context, _ int) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+func (o *rekeyObserver) OnDecrypt(ctx context.Context, _ int) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+func (o *rekeyObserver) OnHandshakeFailed(string, error) {}
+func (o *rekeyObserver) OnReplayDetected()               {}
+func (o *rekeyObserver) OnAuthFailure()                  {}
+func (o *rekeyObserver) OnRekeyStart(ctx context.Context) (context.Context, func(error)) {
+	return ctx, func(error) {}
+}
+func (o *rekeyObserver) OnProtocolError(error) {}
+
+func (o *rekeyObserver) OnRekeyCompleted() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.completed++
+}
+
+func (o *rekeyObserver) OnRekeyFailed(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.failures = append(o.failures, err)
+}
+
+func (o *rekeyObserver) OnUnexpectedRekey(stale bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.unexpected = append(o.unexpected, stale)
+}
+
+func (o *rekeyObserver) counts() (int, []error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.completed, append([]error(nil), o.failures...)
+}
+
+func (o *rekeyObserver) unexpectedRekeys() []bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]bool(nil), o.unexpected...)
+}
+
+// exchangeRekey sends a rekey request from client and lets server answer it,
+// returning the server's raw response for the client to process.
+func exchangeRekey(t *testing.T, client, server *Transport) []byte {
+	t.Helper()
+
+	serverDone := make(chan error, 1)
+	go func() {
+		msg, err := server.codec.ReadMessage(server.conn)
+		if err != nil {
+			serverDone <- err
+			return
+		}
+		serverDone <- server.handleRekey(msg)
+	}()
+
+	if err := client.SendRekey(); err != nil {
+		t.Fatalf("SendRekey failed: %v", err)
+	}
+	response, err := client.codec.ReadMessage(client.conn)
+	if err != nil {
+		t.Fatalf("reading rekey response failed: %v", err)
+	}
+	if err := <-serverDone; err != nil {
+		t.Fatalf("server handleRekey failed: %v", err)
+	}
+	return response
+}
+
+func TestRekeyObserverCompleted(t *testing.T) {
+	client, server := newPipeTransports(t)
+	clientObs, serverObs := &rekeyObserver{}, &rekeyObserver{}
+	client.session.SetObserver(clientObs)
+	server.session.SetObserver(serverObs)
+
+	response := exchangeRekey(t, client, server)
+	if err := client.handleRekey(response); err != nil {
+		t.Fatalf("client handleRekey failed: %v", err)
+	}
+
+	client.session.ActivatePendingKeys()
+	server.session.ActivatePendingKeys()
+
+	for name, obs := range map[string]*rekeyObserver{"client": clientObs, "server": serverObs} {
+		completed, failures := obs.counts()
+		if completed != 1 || len(failures) != 0 {
+			t.Errorf("%s: got %d completed and %d failed rekeys, want 1 and 0", name, completed, len(failures))
+		}
+	}
+}
+
+func TestRekeyObserverCorruptedResponse(t *testing.T) {
+	client, server := newPipeTransports(t)
+	obs := &rekeyObserver{}
+	client.session.SetObserver(obs)
+
+	response := exchangeRekey(t, client, server)
+
+	// Flip a bit in the encrypted response and deliver it through Receive
+	response[len(response)-1] ^= 0x01
+	feed, conn := net.Pipe()
+	t.Cleanup(func() { _ = feed.Close() })
+	client.conn = conn
+	go func() { _, _ = feed.Write(response) }()
+
+	if _, err := client.Receive(); err == nil {
+		t.Fatal("expected Receive to fail on a corrupted rekey response")
+	}
+
+	completed, failures := obs.counts()
+	if completed != 0 {
+		t.Errorf("expected no completed rekeys, got %d", completed)
+	}
+	if len(failures) != 1 || failures[0] == nil {
+		t.Fatalf("expected one OnRekeyFailed with an error, got %v", failures)
+	}
+
+	// The peers may disagree on the keys, so the transport closes
+	if client.session.IsRekeyInProgress() {
+		t.Error("pending rekey should be discarded")
+	}
+	if state := client.session.State(); state != SessionStateClosed {
+		t.Errorf("expected session to be closed, got %v", state)
+	}
+	if err := client.Send([]byte("after failure")); !errors.Is(err, qerrors.ErrTunnelClosed) {
+		t.Errorf("expected ErrTunnelClosed, got %v", err)
+	}
+}
+
+func TestRekeyObserverSendFailure(t *testing.T) {
+	client, _ := newPipeTransports(t)
+	obs := &rekeyObserver{}
+	client.session.SetObserver(obs)
+
+	// A request that cannot be written leaves the session on its old keys
+	_ = client.conn.Close()
+	if err := client.SendRekey(); err == nil {
+		t.Fatal("expected SendRekey to fail on a closed connection")
+	}
+
+	if _, failures := obs.counts(); len(failures) != 1 || failures[0] == nil {
+		t.Fatalf("expected one OnRekeyFailed with an error, got %v", failures)
+	}
+	if client.session.IsRekeyInProgress() {
+		t.Error("failed rekey should not stay in progress")
+	}
+	if state := client.session.State(); state != SessionStateEstablished {
+		t.Errorf("expected session to remain established, got %v", state)
+	}
+}
+
+func TestUnexpectedRekeyResponse(t *testing.T) {
+	for _, role := range []Role{RoleInitiator, RoleResponder} {
+		t.Run(role.String(), func(t *testing.T) {
+			client, server := newPipeTransports(t)
+			receiver, sender := client, server
+			if role == RoleResponder {
+				receiver, sender = server, client
+			}
+			obs := &rekeyObserver{}
+			receiver.session.SetObserver(obs)
+
+			// A response although the receiver never requested a rekey
+			senderErr := make(chan error, 1)
+			go func() {
+				if err := sender.sendRekeyResponse(make([]byte, constants.CHKEMCiphertextSize), 100); err != nil {
+					senderErr <- err
+					return
+				}
+				_, err := sender.Receive()
+				senderErr <- err
+			}()
+
+			if _, err := receiver.Receive(); !errors.Is(err, qerrors.ErrUnexpectedRekey) {
+				t.Fatalf("expected ErrUnexpectedRekey, got %v", err)
+			}
+			var alert *AlertError
+			if err := <-senderErr; !errors.As(err, &alert) {
+				t.Fatalf("expected *AlertError at the sender, got %v", err)
+			}
+			if alert.Code() != protocol.AlertCodeUnexpectedMessage || alert.Level() != protocol.AlertLevelFatal {
+				t.Errorf("got alert %d at level %d, want fatal unexpected_message", alert.Code(), alert.Level())
+			}
+
+			if got := obs.unexpectedRekeys(); len(got) != 1 || got[0] {
+				t.Errorf("expected one non-stale unexpected rekey, got %v", got)
+			}
+			if state := receiver.session.State(); state != SessionStateClosed {
+				t.Errorf("expected session to be closed, got %v", state)
+			}
+		})
+	}
+}
+
+func TestStaleRekeyResponseIgnored(t *testing.T) {
+	client, server := newPipeTransports(t)
+	obs := &rekeyObserver{}
+	client.session.SetObserver(obs)
+	clientInbox, serverInbox := readMessages(client), readMessages(server)
+
+	// The client gives up on its request after the server received it
+	if err := client.SendRekey(); err != nil {
+		t.Fatalf("SendRekey failed: %v", err)
+	}
+	client.session.abortRekey()
+	if err := server.handleRekey(<-serverInbox); err != nil {
+		t.Fatalf("server handleRekey failed: %v", err)
+	}
+
+	// The late answer is logged and dropped
+	if err := client.handleRekey(<-clientInbox); err != nil {
+		t.Fatalf("expected the stale response to be ignored, got %v", err)
+	}
+	if got := obs.unexpectedRekeys(); len(got) != 1 || !got[0] {
+		t.Errorf("expected one stale unexpected rekey, got %v", got)
+	}
+	if client.session.IsRekeyInProgress() {
+		t.Error("stale response should not start a rekey")
+	}
+	exchangeData(t, client, serverInbox, server, "still usable")
+}
+
+// readMessages reads messages from tr in the background.
+func readMessages(tr *Transport) <-chan []byte {
+	msgs := make(chan []byte, 1)
+	go func() {
+		defer close(msgs)
+		for {
+			msg, err := tr.codec.ReadMessage(tr.conn)
+			if err != nil {
+				return
+			}
+			msgs <- msg
+		}
+	}()
+	return msgs
+}
+
+func TestRekeyCollision(t *testing.T) {
+	client, server := newPipeTransports(t)
+	oldSecret := append([]byte(nil), client.session.masterSecret...)
+
+	clientInbox, serverInbox := readMessages(client), readMessages(server)
+
+	// Both peers send a request before processing the other's
+	if err := client.SendRekey(); err != nil {
+		t.Fatalf("client SendRekey failed: %v", err)
+	}
+	if err := server.SendRekey(); err != nil {
+		t.Fatalf("server SendRekey failed: %v", err)
+	}
+	clientRequest, serverRequest := <-serverInbox, <-clientInbox
+
+	if err := client.handleRekey(serverRequest); err != nil {
+		t.Fatalf("client handleRekey failed: %v", err)
+	}
+	if err := server.handleRekey(clientRequest); err != nil {
+		t.Fatalf("server handleRekey failed: %v", err)
+	}
+
+	// Exactly one side lost the tie-break and answered the other's request
+	select {
+	case response := <-clientInbox:
+		if err := client.handleRekey(response); err != nil {
+			t.Fatalf("client handleRekey(response) failed: %v", err)
+		}
+	case response := <-serverInbox:
+		if err := server.handleRekey(response); err != nil {
+			t.Fatalf("server handleRekey(response) failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no rekey response after collision")
+	}
+	select {
+	case msg := <-clientInbox:
+		t.Fatalf("unexpected second rekey message to client: %d bytes", len(msg))
+	case msg := <-serverInbox:
+		t.Fatalf("unexpected second rekey message to server: %d bytes", len(msg))
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	client.session.ActivatePendingKeys()
+	server.session.ActivatePendingKeys()
+
+	if !bytes.Equal(client.session.masterSecret, server.session.masterSecret) {
+		t.Fatal("peers did not converge on the same keys")
+	}
+	if bytes.Equal(client.session.masterSecret, oldSecret) {
+		t.Fatal("keys were not rotated")
+	}
+	for name, s := range map[string]*Session{"client": client.session, "server": server.session} {
+		if s.IsRekeyInProgress() || s.pendingRekeyKeyPair != nil {
+			t.Errorf("%s: rekey state not cleared", name)
+		}
+	}
+
+	// Traffic flows both ways on the new keys
+	for _, pair := range []struct {
+		from  *Transport
+		inbox <-chan []byte
+		to    *Transport
+	}{{client, serverInbox, server}, {server, clientInbox, client}} {
+		if err := pair.from.Send([]byte("after collision")); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		_, data, err := pair.to.openData(<-pair.inbox)
+		if err != nil {
+			t.Fatalf("openData failed: %v", err)
+		}
+		if string(data) != "after collision" {
+			t.Errorf("expected %q, got %q", "after collision", data)
+		}
+	}
+}
+
+func TestRekeyActivationWithTrafficInFlight(t *testing.T) {
+	client, server := newPipeTransports(t)
+	oldSecret := append([]byte(nil), client.session.masterSecret...)
+	clientInbox, serverInbox := readMessages(client), readMessages(server)
+
+	send := func(from *Transport, inbox <-chan []byte, to *Transport, msg string) {
+		t.Helper()
+		if err := from.Send([]byte(msg)); err != nil {
+			t.Fatalf("Send(%q) failed: %v", msg, err)
+		}
+		_, data, err := to.openData(<-inbox)
+		if err != nil {
+			t.Fatalf("openData(%q) failed: %v", msg, err)
+		}
+		if string(data) != msg {
+			t.Fatalf("expected %q, got %q", msg, data)
+		}
+	}
+
+	if err := client.SendRekey(); err != nil {
+		t.Fatalf("SendRekey failed: %v", err)
+	}
+	if err := server.handleRekey(<-serverInbox); err != nil {
+		t.Fatalf("server handleRekey failed: %v", err)
+	}
+	response := <-clientInbox
+
+	// The client streams past the activation sequence before the response
+	// arrives, so it is still sealing with the old keys
+	for seq := client.session.sendSeq.Load(); seq <= client.session.GetRekeyActivationSeq()+4; seq++ {
+		send(client, serverInbox, server, "before response")
+	}
+
+	if err := client.handleRekey(response); err != nil {
+		t.Fatalf("client handleRekey failed: %v", err)
+	}
+	// The client switches to the new keys with its next record, while the
+	// server has yet to see it and still replies under the old keys
+	if err := client.Send([]byte("new keys")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	newKeysRecord := <-serverInbox
+	send(server, clientInbox, client, "old keys")
+
+	_, data, err := server.openData(newKeysRecord)
+	if err != nil {
+		t.Fatalf("openData on new keys failed: %v", err)
+	}
+	if string(data) != "new keys" {
+		t.Fatalf("expected %q, got %q", "new keys", data)
+	}
+	send(server, clientInbox, client, "after rekey")
+
+	if !bytes.Equal(client.session.masterSecret, server.session.masterSecret) {
+		t.Fatal("peers did not converge on the same keys")
+	}
+	if bytes.Equal(client.session.masterSecret, oldSecret) {
+		t.Fatal("keys were not rotated")
+	}
+	if client.session.prevRecvCipher != nil {
+		t.Error("old receive cipher kept after the peer switched")
+	}
+}
+
+func TestReceiveBufferReuse(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	// Varying sizes make the read buffer grow and be reused; every message
+	// has distinct contents so aliasing would corrupt earlier results
+	const count = 200
+	messages := make([][]byte, count)
+	for i := range messages {
+		messages[i] = bytes.Repeat([]byte{byte(i)}, (i*37)%2000+1)
+	}
+
+	go func() {
+		for _, msg := range messages {
+			if err := client.Send(msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	received := make([][]byte, count)
+	for i := range received {
+		data, err := server.Receive()
+		if err != nil {
+			t.Fatalf("Receive %d failed: %v", i, err)
+		}
+		received[i] = data
+	}
+
+	for i := range messages {
+		if !bytes.Equal(received[i], messages[i]) {
+			t.Fatalf("message %d corrupted after later receives", i)
+		}
+	}
+	if cap(server.readBuf) == 0 {
+		t.Error("Receive should keep its read buffer for reuse")
+	}
+}
+
+func TestReceiveInto(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	messages := [][]byte{[]byte("first"), bytes.Repeat([]byte{'x'}, 1500), []byte("third")}
+	go func() {
+		for _, msg := range messages {
+			if err := client.Send(msg); err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 2048)
+	for i, msg := range messages {
+		n, err := server.ReceiveInto(buf)
+		if err != nil {
+			t.Fatalf("ReceiveInto %d failed: %v", i, err)
+		}
+		if !bytes.Equal(buf[:n], msg) {
+			t.Fatalf("message %d: got %d bytes, want %d", i, n, len(msg))
+		}
+	}
+	if cap(server.recvBuf) == 0 {
+		t.Error("ReceiveInto should keep its plaintext buffer for reuse")
+	}
+}
+
+func TestReceiveIntoShortBuffer(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	data := bytes.Repeat([]byte("short buffer "), 10)
+	go func() {
+		_ = client.Send(data)
+		_ = client.Send([]byte("next"))
+	}()
+
+	n, err := server.ReceiveInto(make([]byte, 16))
+	if !errors.Is(err, io.ErrShortBuffer) {
+		t.Fatalf("expected io.ErrShortBuffer, got %v", err)
+	}
+	if n != len(data) {
+		t.Fatalf("short buffer reported %d bytes needed, want %d", n, len(data))
+	}
+
+	// The message is kept for the next call
+	buf := make([]byte, n)
+	n, err = server.ReceiveInto(buf)
+	if err != nil || !bytes.Equal(buf[:n], data) {
+		t.Fatalf("retry = %d, %v; want the kept message", n, err)
+	}
+
+	// Receive delivers a kept message too, as memory the caller owns
+	if _, err := server.ReceiveInto(nil); !errors.Is(err, io.ErrShortBuffer) {
+		t.Fatalf("expected io.ErrShortBuffer, got %v", err)
+	}
+	got, err := server.Receive()
+	if err != nil || string(got) != "next" {
+		t.Fatalf("Receive = %q, %v; want the kept message", got, err)
+	}
+	clear(server.recvBuf[:cap(server.recvBuf)])
+	if string(got) != "next" {
+		t.Error("Receive returned memory aliasing the plaintext buffer")
+	}
+}
+
+func TestReceiveIntoExactFit(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	data := []byte("exactly this long")
+	go func() { _ = client.Send(data) }()
+
+	buf := make([]byte, len(data))
+	n, err := server.ReceiveInto(buf)
+	if err != nil {
+		t.Fatalf("ReceiveInto failed: %v", err)
+	}
+	if n != len(data) || !bytes.Equal(buf, data) {
+		t.Fatalf("got %q, want %q", buf[:n], data)
+	}
+}
+
+func TestTransportCloseWrite(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	// The client sends two records, closes its write side, and keeps
+	// receiving until the server closes its own
+	clientGot := make(chan []string, 1)
+	clientErr := make(chan error, 1)
+	go func() {
+		for _, msg := range []string{"a", "b"} {
+			if err := client.Send([]byte(msg)); err != nil {
+				clientErr <- err
+				return
+			}
+		}
+		if err := client.CloseWrite(); err != nil {
+			clientErr <- err
+			return
+		}
+		if err := client.Send([]byte("late")); !errors.Is(err, qerrors.ErrWriteClosed) {
+			clientErr <- fmt.Errorf("expected ErrWriteClosed, got %v", err)
+			return
+		}
+
+		var got []string
+		for {
+			data, err := client.Receive()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				clientErr <- err
+				return
+			}
+			got = append(got, string(data))
+		}
+		clientGot <- got
+	}()
+
+	for _, want := range []string{"a", "b"} {
+		data, err := server.Receive()
+		if err != nil {
+			t.Fatalf("Receive failed: %v", err)
+		}
+		if string(data) != want {
+			t.Fatalf("expected %q, got %q", want, data)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := server.Receive(); err != io.EOF {
+			t.Fatalf("expected io.EOF after the peer's CloseWrite, got %v", err)
+		}
+	}
+
+	// The server can still send after the client's write side closed
+	for _, msg := range []string{"x", "y", "z"} {
+		if err := server.Send([]byte(msg)); err != nil {
+			t.Fatalf("Send after peer CloseWrite failed: %v", err)
+		}
+	}
+	if err := server.CloseWrite(); err != nil {
+		t.Fatalf("CloseWrite failed: %v", err)
+	}
+
+	select {
+	case got := <-clientGot:
+		if strings.Join(got, "") != "xyz" {
+			t.Errorf("client received %q, want %q", got, []string{"x", "y", "z"})
+		}
+	case err := <-clientErr:
+		t.Fatal(err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("client did not see io.EOF")
+	}
+
+	if err := client.Close(); err != nil {
+		t.Errorf("client Close failed: %v", err)
+	}
+	if err := server.Close(); err != nil {
+		t.Errorf("server Close failed: %v", err)
+	}
+	if err := client.CloseWrite(); !errors.Is(err, qerrors.ErrTunnelClosed) {
+		t.Errorf("expected ErrTunnelClosed from CloseWrite after Close, got %v", err)
+	}
+}
+
+func TestTransportCloseWriteForged(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	// A close-write sealed with the wrong keys must not end the stream
+	other, _ := newPipeTransports(t)
+	ciphertext, seq, _ := other.session.Encrypt([]byte{byte(protocol.MessageTypeCloseWrite)})
+	forged, _ := client.codec.EncodeCloseWrite(seq, ciphertext)
+	go func() { _, _ = client.conn.Write(forged) }()
+
+	if _, err := server.Receive(); err == nil || err == io.EOF {
+		t.Fatalf("expected forged close-write to be rejected, got %v", err)
+	}
+}
+
+func TestTransportKeyCommitment(t *testing.T) {
+	for _, clientCommits := range []bool{true, false} {
+		t.Run(fmt.Sprintf("client=%v", clientCommits), func(t *testing.T) {
+			listener, err := Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen failed: %v", err)
+			}
+			defer func() { _ = listener.Close() }()
+
+			config := DefaultTransportConfig()
+			config.RequireKeyCommitment = true
+			listener.SetConfig(config)
+
+			received := make(chan error, 1)
+			go func() {
+				server, err := listener.Accept()
+				if err != nil {
+					received <- err
+					return
+				}
+				defer func() { _ = server.Close() }()
+				data, err := server.Receive()
+				if err == nil {
+					err = server.Send(data)
+				}
+				received <- err
+			}()
+
+			config.RequireKeyCommitment = clientCommits
+			client, err := DialWithConfig("tcp", listener.Addr().String(), config)
+			if !clientCommits {
+				// The listener refuses a peer with a different setting
+				if err == nil {
+					_ = client.Close()
+					t.Fatal("Dial succeeded despite a key commitment mismatch")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Dial failed: %v", err)
+			}
+			defer func() { _ = client.Close() }()
+
+			if !client.Session().sendCipher.Committing() {
+				t.Fatal("expected committing send cipher")
+			}
+
+			// The largest payload still fits a record with the commitment
+			payload := make([]byte, client.maxRecordData())
+			_ = crypto.SecureRandom(payload)
+			if err := client.Send(payload); err != nil {
+				t.Fatalf("Send failed: %v", err)
+			}
+
+			if err := <-received; err != nil {
+				t.Fatalf("server failed: %v", err)
+			}
+			echo, err := client.Receive()
+			if err != nil {
+				t.Fatalf("Receive failed: %v", err)
+			}
+			if !bytes.Equal(echo, payload) {
+				t.Error("echoed payload does not match")
+			}
+		})
+	}
+}
+
+func TestTransportRecorder(t *testing.T) {
+	type event struct {
+		dir     protocol.Direction
+		msgType protocol.MessageType
+		length  int
+	}
+	newRecorder := func() (protocol.Recorder, func() []event) {
+		var mu sync.Mutex
+		var events []event
+		rec := protocol.RecorderFunc(func(msg protocol.RecordedMessage) {
+			mu.Lock()
+			defer mu.Unlock()
+			events = append(events, event{msg.Direction, msg.Type, msg.Length})
+		})
+		return rec, func() []event {
+			mu.Lock()
+			defer mu.Unlock()
+			return append([]event(nil), events...)
+		}
+	}
+	serverRec, serverEvents := newRecorder()
+	clientRec, clientEvents := newRecorder()
+
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+	config := DefaultTransportConfig()
+	config.Recorder = serverRec
+	listener.SetConfig(config)
+
+	received := make(chan error, 1)
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			received <- err
+			return
+		}
+		defer func() { _ = server.Close() }()
+		_, err = server.Receive()
+		received <- err
+	}()
+
+	config.Recorder = clientRec
+	client, err := DialWithConfig("tcp", listener.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.Send([]byte("recorded")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := <-received; err != nil {
+		t.Fatalf("server Receive failed: %v", err)
+	}
+
+	wantTypes := []protocol.MessageType{
+		protocol.MessageTypeClientHello,
+		protocol.MessageTypeServerHello,
+		protocol.MessageTypeClientFinished,
+		protocol.MessageTypeServerFinished,
+		protocol.MessageTypeData,
+	}
+	for name, events := range map[string][]event{"client": clientEvents(), "server": serverEvents()} {
+		if len(events) < len(wantTypes) {
+			t.Fatalf("%s recorded %d messages, want at least %d: %v", name, len(events), len(wantTypes), events)
+		}
+		for i, want := range wantTypes {
+			got := events[i]
+			// Messages flow client to server except ServerHello and ServerFinished
+			fromClient := want != protocol.MessageTypeServerHello && want != protocol.MessageTypeServerFinished
+			wantDir := protocol.DirectionSent
+			if fromClient != (name == "client") {
+				wantDir = protocol.DirectionReceived
+			}
+			if got.msgType != want || got.dir != wantDir {
+				t.Errorf("%s[%d] = %v %v, want %v %v", name, i, got.dir, got.msgType, wantDir, want)
+			}
+		}
+	}
+
+	// Sizes reflect the CH-KEM material each message carries
+	events := clientEvents()
+	if events[0].length < protocol.HeaderSize+constants.CHKEMPublicKeySize {
+		t.Errorf("ClientHello length %d too small for a public key", events[0].length)
+	}
+	if events[1].length < protocol.HeaderSize+constants.CHKEMCiphertextSize {
+		t.Errorf("ServerHello length %d too small for a ciphertext", events[1].length)
+	}
+	for _, e := range events[2:4] {
+		if e.length <= protocol.HeaderSize || e.length > 1024 {
+			t.Errorf("%v length %d is implausible", e.msgType, e.length)
+		}
+	}
+	if events[4].length <= protocol.HeaderSize+len("recorded") {
+		t.Errorf("Data length %d too small for its payload", events[4].length)
+	}
+	server := serverEvents()
+	for i := range wantTypes {
+		if server[i].length != events[i].length {
+			t.Errorf("%v: client recorded %d bytes, server %d", wantTypes[i], events[i].length, server[i].length)
+		}
+	}
+}
+
+// exchangeData sends msg from one transport and opens it on the other.
+func exchangeData(t *testing.T, from *Transport, inbox <-chan []byte, to *Transport, msg string) {
+	t.Helper()
+	if err := from.Send([]byte(msg)); err != nil {
+		t.Fatalf("Send(%q) failed: %v", msg, err)
+	}
+	_, data, err := to.openData(<-inbox)
+	if err != nil {
+		t.Fatalf("openData(%q) failed: %v", msg, err)
+	}
+	if string(data) != msg {
+		t.Fatalf("expected %q, got %q", msg, data)
+	}
+}
+
+func TestRatchetRekey(t *testing.T) {
+	client, server := newPipeTransports(t)
+	oldSecret := append([]byte(nil), client.session.masterSecret...)
+	clientInbox, serverInbox := readMessages(client), readMessages(server)
+
+	exchangeData(t, client, serverInbox, server, "before ratchet")
+	if err := client.SendRatchet(); err != nil {
+		t.Fatalf("SendRatchet failed: %v", err)
+	}
+	if err := server.handleRatchet(<-serverInbox); err != nil {
+		t.Fatalf("handleRatchet failed: %v", err)
+	}
+	if !server.session.IsRekeyInProgress() {
+		t.Fatal("server did not install the ratchet keys")
+	}
+
+	// Records on both sides before the activation sequence use the old keys
+	for client.session.sendSeq.Load() < client.session.GetRekeyActivationSeq() {
+		exchangeData(t, client, serverInbox, server, "old keys")
+	}
+	exchangeData(t, server, clientInbox, client, "old keys reply")
+
+	// From the activation sequence on, both use the ratcheted keys
+	exchangeData(t, client, serverInbox, server, "new keys")
+	exchangeData(t, server, clientInbox, client, "new keys reply")
+
+	for name, session := range map[string]*Session{"client": client.session, "server": server.session} {
+		if session.IsRekeyInProgress() || session.State() != SessionStateEstablished {
+			t.Errorf("%s still rekeying", name)
+		}
+	}
+	want, _ := crypto.DeriveRekeySecret(oldSecret, []byte(constants.DomainSeparatorRatchet))
+	if !bytes.Equal(client.session.masterSecret, want) || !bytes.Equal(server.session.masterSecret, want) {
+		t.Fatal("peers did not ratchet to the expected secret")
+	}
+}
+
+func TestRatchetRekeySimultaneous(t *testing.T) {
+	client, server := newPipeTransports(t)
+	clientInbox, serverInbox := readMessages(client), readMessages(server)
+
+	// Both peers ratchet before seeing the other's ratchet
+	if err := client.SendRatchet(); err != nil {
+		t.Fatalf("client SendRatchet failed: %v", err)
+	}
+	if err := server.SendRatchet(); err != nil {
+		t.Fatalf("server SendRatchet failed: %v", err)
+	}
+	clientRatchet, serverRatchet := <-serverInbox, <-clientInbox
+	if err := server.handleRatchet(clientRatchet); err != nil {
+		t.Fatalf("server handleRatchet failed: %v", err)
+	}
+	if err := client.handleRatchet(serverRatchet); err != nil {
+		t.Fatalf("client handleRatchet failed: %v", err)
+	}
+
+	for range 20 {
+		exchangeData(t, client, serverInbox, server, "ping")
+		exchangeData(t, server, clientInbox, client, "pong")
+	}
+	if !bytes.Equal(client.session.masterSecret, server.session.masterSecret) {
+		t.Fatal("peers did not converge on the same keys")
+	}
+	if client.session.IsRekeyInProgress() || server.session.IsRekeyInProgress() {
+		t.Error("ratchet did not complete")
+	}
+}
+
+func TestRatchetRekeyOverridesUnansweredRekey(t *testing.T) {
+	client, server := newPipeTransports(t)
+	oldSecret := append([]byte(nil), client.session.masterSecret...)
+	clientInbox, serverInbox := readMessages(client), readMessages(server)
+
+	// The client's KEM rekey request crosses the server's ratchet
+	if err := client.SendRekey(); err != nil {
+		t.Fatalf("SendRekey failed: %v", err)
+	}
+	if err := server.SendRatchet(); err != nil {
+		t.Fatalf("SendRatchet failed: %v", err)
+	}
+	rekeyRequest, ratchet := <-serverInbox, <-clientInbox
+
+	// The server is already rekeying and leaves the request unanswered,
+	// while the client abandons it for the ratchet
+	if err := server.handleRekey(rekeyRequest); err != nil {
+		t.Fatalf("server handleRekey failed: %v", err)
+	}
+	if err := client.handleRatchet(ratchet); err != nil {
+		t.Fatalf("client handleRatchet failed: %v", err)
+	}
+
+	for range 20 {
+		exchangeData(t, server, clientInbox, client, "ping")
+		exchangeData(t, client, serverInbox, server, "pong")
+	}
+	want, _ := crypto.DeriveRekeySecret(oldSecret, []byte(constants.DomainSeparatorRatchet))
+	if !bytes.Equal(client.session.masterSecret, want) || !bytes.Equal(server.session.masterSecret, want) {
+		t.Fatal("peers did not converge on the ratchet keys")
+	}
+}
+
+func TestRatchetRekeyErrors(t *testing.T) {
+	session, _ := NewSession(RoleInitiator)
+	if _, err := session.RatchetRekey(); !errors.Is(err, qerrors.ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState before the handshake, got %v", err)
+	}
+
+	client, _ := newPipeTransports(t)
+	if _, err := client.session.RatchetRekey(); err != nil {
+		t.Fatalf("RatchetRekey failed: %v", err)
+	}
+	if _, err := client.session.RatchetRekey(); !errors.Is(err, qerrors.ErrRekeyInProgress) {
+		t.Errorf("expected ErrRekeyInProgress, got %v", err)
+	}
+}
+
+func TestRatchetRekeyOverTunnel(t *testing.T) {
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	accepted := make(chan *Tunnel, 1)
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			t.Errorf("Accept failed: %v", err)
+		}
+		accepted <- server
+	}()
+	client, err := Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	server := <-accepted
+	if server == nil {
+		t.FailNow()
+	}
+	defer func() { _ = server.Close() }()
+
+	const count = 40
+	go func() {
+		if err := client.SendRatchet(); err != nil {
+			t.Errorf("SendRatchet failed: %v", err)
+			return
+		}
+		for i := range count {
+			if err := client.Send([]byte{byte(i)}); err != nil {
+				t.Errorf("Send failed: %v", err)
+				return
+			}
+		}
+	}()
+	for i := range count {
+		data, err := server.Receive()
+		if err != nil {
+			t.Fatalf("Receive %d failed: %v", i, err)
+		}
+		if len(data) != 1 || data[0] != byte(i) {
+			t.Fatalf("Receive %d = %v", i, data)
+		}
+	}
+
+	if err := server.Send([]byte("reply")); err != nil {
+		t.Fatalf("server Send failed: %v", err)
+	}
+	if data, err := client.Receive(); err != nil || string(data) != "reply" {
+		t.Fatalf("client Receive = %q, %v", data, err)
+	}
+	if server.Session().IsRekeyInProgress() || client.Session().IsRekeyInProgress() {
+		t.Error("ratchet did not complete")
+	}
+	if !bytes.Equal(server.Session().masterSecret, client.Session().masterSecret) {
+		t.Error("peers did not converge on the same keys")
+	}
+}
+
+func TestSendRekeyDirectionOverTunnel(t *testing.T) {
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	accepted := make(chan *Tunnel, 1)
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			t.Errorf("Accept failed: %v", err)
+		}
+		accepted <- server
+	}()
+	client, err := Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	server := <-accepted
+	if server == nil {
+		t.FailNow()
+	}
+	defer func() { _ = server.Close() }()
+
+	clientSend, clientRecv := client.session.sendCipher, client.session.recvCipher
+	serverSend := server.session.sendCipher
+
+	const count = 20
+	go func() {
+		if err := client.SendRekeyDirection(RekeySend); err != nil {
+			t.Errorf("SendRekeyDirection failed: %v", err)
+			return
+		}
+		for i := range count {
+			if err := client.Send([]byte{byte(i)}); err != nil {
+				t.Errorf("Send failed: %v", err)
+				return
+			}
+		}
+	}()
+	for i := range count {
+		if data, err := server.Receive(); err != nil || len(data) != 1 || data[0] != byte(i) {
+			t.Fatalf("Receive %d = %v, %v", i, data, err)
+		}
+	}
+
+	// The reply carries the rekey response back to the client, whose next
+	// record uses the new send keys; the reply direction keeps its keys
+	exchange := func(from, to *Tunnel, message string) {
+		t.Helper()
+		if err := from.Send([]byte(message)); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+		if data, err := to.Receive(); err != nil || string(data) != message {
+			t.Fatalf("Receive = %q, %v, want %q", data, err, message)
+		}
+	}
+	exchange(server, client, "reply")
+	exchange(client, server, "under new keys")
+	exchange(server, client, "under old keys")
+
+	if client.session.IsRekeyInProgress() || server.session.IsRekeyInProgress() {
+		t.Fatal("rekey did not complete")
+	}
+	if client.session.sendCipher == clientSend {
+		t.Error("client send keys were not rotated")
+	}
+	if client.session.recvCipher != clientRecv || server.session.sendCipher != serverSend {
+		t.Error("server-to-client keys were rotated")
+	}
+	if !bytes.Equal(client.session.masterSecret, server.session.masterSecret) {
+		t.Error("peers did not converge on the same master secret")
+	}
+}
+
+func TestDialWithEphemeralKeyPair(t *testing.T) {
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	received := make(chan []byte, 1)
+	go func() {
+		server, err := listener.Accept()
+		if err != nil {
+			t.Errorf("Accept failed: %v", err)
+			close(received)
+			return
+		}
+		defer func() { _ = server.Close() }()
+		data, err := server.Receive()
+		if err != nil {
+			t.Errorf("Receive failed: %v", err)
+		}
+		received <- data
+	}()
+
+	// Generated ahead of the dial
+	keyPair, err := chkem.GenerateKeyPair()
+	if err != nil {
+		t.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	config := DefaultTransportConfig()
+	config.EphemeralKeyPair = keyPair
+
+	client, err := DialWithConfig("tcp", listener.Addr().String(), config)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer func() { _ = client.Close() }()
+	if client.Session().LocalKeyPair != keyPair {
+		t.Error("session does not use the pre-generated key pair")
+	}
+	if !keyPair.Consumed() {
+		t.Error("key pair not marked consumed by Dial")
+	}
+	if err := client.Send([]byte("pre-generated")); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if data := <-received; string(data) != "pre-generated" {
+		t.Errorf("server received %q", data)
+	}
+
+	// Dialing again with the same config must not reuse the key pair
+	if _, err := DialWithConfig("tcp", listener.Addr().String(), config); !errors.Is(err, qerrors.ErrKeyPairConsumed) {
+		t.Errorf("expected ErrKeyPairConsumed on reuse, got %v", err)
+	}
+}
+
+func TestSendWithHeaderRoundTrip(t *testing.T) {
+	large := make([]byte, 3*maxRecordPlaintext)
+	_ = crypto.SecureRandom(large)
+	messages := []struct {
+		header, data []byte
+	}{
+		{[]byte{0, 0, 0, 1}, []byte("stream one")},
+		{[]byte{0, 0, 0, 2}, nil},
+		{bytes.Repeat([]byte{0xAB}, protocol.MaxDataHeaderSize), []byte("largest header")},
+		{[]byte("big"), large},
+	}
+
+	client, server := newFragmentingTransports(t, 1<<20)
+	sendErr := make(chan error, 1)
+	go func() {
+		for _, m := range messages {
+			if err := client.SendWithHeader(m.header, m.data); err != nil {
+				sendErr <- err
+				return
+			}
+		}
+		sendErr <- nil
+	}()
+
+	for i, want := range messages {
+		header, data, err := server.ReceiveWithHeader()
+		if err != nil {
+			t.Fatalf("ReceiveWithHeader %d failed: %v", i, err)
+		}
+		if !bytes.Equal(header, want.header) {
+			t.Errorf("message %d: header = %x, want %x", i, header, want.header)
+		}
+		if !bytes.Equal(data, want.data) {
+			t.Errorf("message %d: got %d bytes of data, want %d", i, len(data), len(want.data))
+		}
+	}
+	if err := <-sendErr; err != nil {
+		t.Fatalf("SendWithHeader failed: %v", err)
+	}
+
+	if err := client.SendWithHeader(make([]byte, protocol.MaxDataHeaderSize+1), nil); !errors.Is(err, qerrors.ErrMessageTooLarge) {
+		t.Errorf("expected ErrMessageTooLarge for an oversized header, got %v", err)
+	}
+}
+
+func TestSendWithHeaderTampering(t *testing.T) {
+	client, server := newPipeTransports(t)
+	inbox := readMessages(server)
+	codec := protocol.NewCodec()
+
+	send := func() []byte {
+		t.Helper()
+		if err := client.SendWithHeader([]byte("stream-7"), []byte("payload")); err != nil {
+			t.Fatalf("SendWithHeader failed: %v", err)
+		}
+		return <-inbox
+	}
+
+	// The header travels encrypted, and tampering with it fails
+	// authentication
+	msg := send()
+	if protocol.MessageType(msg[0]) != protocol.MessageTypeDataHeader {
+		t.Fatalf("message type = %v, want DataHeader", protocol.MessageType(msg[0]))
+	}
+	if bytes.Contains(msg, []byte("stream-7")) {
+		t.Error("header sent in the clear")
+	}
+	seq, ciphertext, err := codec.DecodeDataHeader(msg)
+	if err != nil {
+		t.Fatalf("DecodeDataHeader failed: %v", err)
+	}
+	ciphertext[1] ^= 0x01
+	if _, _, _, err := server.openRecord(msg, false); !errors.Is(err, qerrors.ErrAuthenticationFailed) {
+		t.Errorf("expected ErrAuthenticationFailed for a modified header, got %v", err)
+	}
+
+	// A record whose plaintext does not frame a header is rejected
+	for _, frame := range [][]byte{{0, 'a'}, {9, 'a'}} {
+		ciphertext, seq, err = client.session.Encrypt(frame)
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		forged, _ := codec.EncodeDataHeaderInto(nil, seq, ciphertext)
+		if _, _, _, err := server.openRecord(forged, false); !errors.Is(err, qerrors.ErrInvalidMessage) {
+			t.Errorf("frame %x: expected ErrInvalidMessage, got %v", frame, err)
+		}
+	}
+
+	// The untouched record opens
+	_, header, data, err := server.openRecord(send(), false)
+	if err != nil {
+		t.Fatalf("openRecord failed: %v", err)
+	}
+	if string(header) != "stream-7" || string(data) != "payload" {
+		t.Errorf("got header %q and data %q", header, data)
+	}
+}
+
+func TestSendWithHeaderEmptyHeader(t *testing.T) {
+	client, server := newPipeTransports(t)
+	inbox := readMessages(server)
+
+	// An empty header sends the same record as Send
+	if err := client.SendWithHeader(nil, []byte("no header")); err != nil {
+		t.Fatalf("SendWithHeader failed: %v", err)
+	}
+	msg := <-inbox
+	if protocol.MessageType(msg[0]) != protocol.MessageTypeData {
+		t.Errorf("message type = %v, want Data", protocol.MessageType(msg[0]))
+	}
+	if _, data, err := server.openData(msg); err != nil || string(data) != "no header" {
+		t.Errorf("openData = %q, %v", data, err)
+	}
+
+	// Plain records carry no header, and Receive drops headers
+	client, server = newPipeTransports(t)
+	go func() {
+		_ = client.Send([]byte("plain"))
+		_ = client.SendWithHeader([]byte{1}, []byte("with header"))
+	}()
+	header, data, err := server.ReceiveWithHeader()
+	if err != nil {
+		t.Fatalf("ReceiveWithHeader failed: %v", err)
+	}
+	if len(header) != 0 || string(data) != "plain" {
+		t.Errorf("ReceiveWithHeader = %x, %q, want no header and %q", header, data, "plain")
+	}
+	if data, err := server.Receive(); err != nil || string(data) != "with header" {
+		t.Errorf("Receive = %q, %v, want %q", data, err, "with header")
+	}
+}
+
+func TestWireStats(t *testing.T) {
+	client, server := newPipeTransports(t)
+
+	payload := bytes.Repeat([]byte{'w'}, 100)
+	sendErr := make(chan error, 1)
+	go func() { sendErr <- client.Send(payload) }()
+	if _, err := server.Receive(); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if err := <-sendErr; err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	// Header, sequence number, nonce, plaintext and tag
+	want := uint64(protocol.HeaderSize + 8 + constants.AESNonceSize + len(payload) + constants.AESTagSize)
+	sent := client.WireStats()
+	if sent.BytesWritten != want || sent.MessagesWritten != 1 {
+		t.Errorf("client WireStats = %+v, want %d bytes in 1 message", sent, want)
+	}
+	recv := server.WireStats()
+	if recv.BytesRead != want || recv.MessagesRead != 1 {
+		t.Errorf("server WireStats = %+v, want %d bytes in 1 message", recv, want)
+	}
+	if sent.BytesRead != 0 || recv.BytesWritten != 0 {
+		t.Errorf("unexpected reverse traffic: client %+v, server %+v", sent, recv)
+	}
+}
+
+// delayConn delays each write by delay.
+type delayConn struct {
+	net.Conn
+	delay time.Duration
+}
+
+func (c *delayConn) Write(b []byte) (int, error) {
+	time.Sleep(c.delay)
+	return c.Conn.Write(b)
+}
+
+// timingObserver records the durations of encrypt, decrypt and network
+// operations by kind.
+type timingObserver struct {
+	rekeyObserver
+	timings map[string][]time.Duration
+}
+
+func (o *timingObserver) timed(ctx context.Context, kind string) (context.Context, func(error)) {
+	start := time.Now()
+	return ctx, func(error) {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.timings == nil {
+			o.timings = make(map[string][]time.Duration)
+		}
+		o.timings[kind] = append(o.timings[kind], time.Since(start))
+	}
+}
+
+func (o *timingObserver) OnEncrypt(ctx context.Context, _ int) (context.Context, func(error)) {
+	return o.timed(ctx, "encrypt")
+}
+func (o *timingObserver) OnDecrypt(ctx context.Context, _ int) (context.Context, func(error)) {
+	return o.timed(ctx, "decrypt")
+}
+func (o *timingObserver) OnNetworkWrite(ctx context.Context, _ int) (context.Context, func(error)) {
+	return o.timed(ctx, "write")
+}
+func (o *timingObserver) OnNetworkRead(ctx context.Context) (context.Context, func(error)) {
+	return o.timed(ctx, "read")
+}
+
+func (o *timingObserver) get(kind string) []time.Duration {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]time.Duration(nil), o.timings[kind]...)
+}
+
+func TestNetworkObserverTiming(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	client, server := newPipeTransports(t)
+	client.conn = &delayConn{Conn: client.conn, delay: delay}
+	clientObserver, serverObserver := &timingObserver{}, &timingObserver{}
+	client.session.SetObserver(clientObserver)
+	server.session.SetObserver(serverObserver)
+
+	sendErr := make(chan error, 1)
+	go func() { sendErr <- client.Send([]byte("delayed")) }()
+	if _, err := server.Receive(); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if err := <-sendErr; err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	// The network hooks see the injected delay, the crypto hooks do not
+	for _, tt := range []struct {
+		observer *timingObserver
+		network  string
+		crypto   string
+	}{
+		{clientObserver, "write", "encrypt"},
+		{serverObserver, "read", "decrypt"},
+	} {
+		network, crypto := tt.observer.get(tt.network), tt.observer.get(tt.crypto)
+		if len(network) != 1 || len(crypto) != 1 {
+			t.Fatalf("recorded %d %s and %d %s timings, want 1 each", len(network), tt.network, len(crypto), tt.crypto)
+		}
+		if network[0] < delay {
+			t.Errorf("%s took %v, want at least the injected %v", tt.network, network[0], delay)
+		}
+		if crypto[0] >= delay/2 {
+			t.Errorf("%s took %v, want well under %v", tt.crypto, crypto[0], delay)
+		}
+	}
+}
+
+// failingListener is a net.Listener whose Accept fails with err a set
+// number of times and then reports that it is closed.
+type failingListener struct {
+	net.Listener
+	err      error
+	failures int
+	calls    int
+}
+
+func (l *failingListener) Accept() (net.Conn, error) {
+	l.calls++
+	if l.calls <= l.failures {
+		return nil, l.err
+	}
+	return nil, net.ErrClosed
+}
+
+func (l *failingListener) Close() error { return nil }
+
+func TestListenerServeRetriesAcceptErrors(t *testing.T) {
+	ln := &failingListener{err: errors.New("accept: too many open files"), failures: 3}
+	listener := newListener(ln)
+	config := DefaultTransportConfig()
+	config.MaxConcurrentHandshakes = 1
+	logger := newCaptureLogger()
+	config.Logger = logger
+	listener.SetConfig(config)
+
+	// Failed accepts are logged and retried; only a closed listener ends Serve
+	err := listener.Serve(context.Background(), func(*Tunnel) {})
+	if !errors.Is(err, net.ErrClosed) {
+		t.Errorf("Serve = %v, want net.ErrClosed", err)
+	}
+	if ln.calls != ln.failures+1 {
+		t.Errorf("Accept called %d times, want %d", ln.calls, ln.failures+1)
+	}
+	if got := len(logger.find("warn", "accept failed, retrying")); got != ln.failures {
+		t.Errorf("logged %d accept failures, want %d", got, ln.failures)
+	}
+
+	// Cancellation ends the wait before a retry
+	ln = &failingListener{err: errors.New("accept: temporary failure"), failures: 1 << 30}
+	listener = newListener(ln)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := listener.Serve(ctx, func(*Tunnel) {}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Serve = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// sessionFailureObserver records session failures.
+type sessionFailureObserver struct {
+	rekeyObserver
+	sessionFailures []error
+}
+
+func (o *sessionFailureObserver) OnSessionFailed(err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.sessionFailures = append(o.sessionFailures, err)
+}
+
+func TestListenerReportsMiddlewareRejections(t *testing.T) {
+	listener, err := Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	defer func() { _ = listener.Close() }()
+
+	logger := newCaptureLogger()
+	obs := &sessionFailureObserver{}
+	config := DefaultTransportConfig()
+	config.Logger = logger
+	config.ObserverFactory = func(*Session) Observer { return obs }
+	_ = listener.SetConfig(config)
+
+	// Reject the first tunnel, accept the second
+	var calls atomic.Int32
+	errDenied := errors.New("remote denied")
+	listener.Use(func(tun *Tunnel) (*Tunnel, error) {
+		if calls.Add(1) == 1 {
+			return nil, errDenied
+		}
+		return tun, nil
+	})
+
+	for range 2 {
+		go func() {
+			if client, err := Dial("tcp", listener.Addr().String()); err == nil {
+				defer func() { _ = client.Close() }()
+				_, _ = client.Receive()
+			}
+		}()
+	}
+	accepted, err := listener.Accept()
+	if err != nil {
+		t.Fatalf("Accept failed: %v", err)
+	}
+	defer func() { _ = accepted.Close() }()
+
+	// The rejection is logged and reported like a failed handshake
+	entries := logger.find("warn", "tunnel rejected by middleware")
+	if len(entries) != 1 {
+		t.Fatalf("logged %d rejections, want 1", len(entries))
+	}
+	if entries[0].name != "listener" || !strings.Contains(fmt.Sprint(entries[0].fields["error"]), errDenied.Error()) {
+		t.Errorf("unexpected rejection log entry: %+v", entries[0])
+	}
+	obs.mu.Lock()
+	defer obs.mu.Unlock()
+	if len(obs.sessionFailures) != 1 || !errors.Is(obs.sessionFailures[0], errDenied) {
+		t.Errorf("observed session failures %v, want one wrapping %v", obs.sessionFailures, errDenied)
+	}
+}
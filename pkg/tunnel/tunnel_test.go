@@ -2,6 +2,8 @@ package tunnel_test
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"net"
 	"sync"
@@ -9,6 +11,8 @@ import (
 	"time"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
 	"github.com/sara-star-quant/quantum-go/pkg/crypto"
 	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
@@ -182,6 +186,190 @@ func TestReplayWindow(t *testing.T) {
 	}
 }
 
+func TestReplayWindowSizeRounding(t *testing.T) {
+	tests := []struct {
+		size uint64
+		want uint64
+	}{
+		{0, 64},
+		{1, 64},
+		{64, 64},
+		{65, 128},
+		{1000, 1024},
+	}
+	for _, tt := range tests {
+		if got := tunnel.NewReplayWindowWithSize(tt.size).Size(); got != tt.want {
+			t.Errorf("NewReplayWindowWithSize(%d).Size() = %d, want %d", tt.size, got, tt.want)
+		}
+	}
+}
+
+func TestReplayWindowLargeSizes(t *testing.T) {
+	for _, size := range []uint64{128, 256, 1024} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			rw := tunnel.NewReplayWindowWithSize(size)
+			high := size * 3
+
+			// Deliver every even sequence number, then the high mark
+			for seq := uint64(0); seq < high; seq += 2 {
+				if !rw.Check(seq) {
+					t.Fatalf("Sequence %d should be valid", seq)
+				}
+			}
+			if !rw.Check(high) {
+				t.Fatalf("Sequence %d should be valid", high)
+			}
+
+			// Out-of-order arrivals inside the window are accepted once
+			for seq := high - size + 1; seq < high; seq += 2 {
+				if !rw.Check(seq) {
+					t.Errorf("Out-of-order sequence %d should be valid", seq)
+				}
+				if rw.Check(seq) {
+					t.Errorf("Sequence %d should be rejected as replay", seq)
+				}
+			}
+
+			// Replays of everything still inside the window are rejected
+			for seq := high - size + 1; seq <= high; seq++ {
+				if rw.Check(seq) {
+					t.Errorf("Sequence %d should be rejected as replay", seq)
+				}
+			}
+
+			// Exactly windowSize behind highSeq is outside the window
+			if rw.Check(high - size) {
+				t.Errorf("Sequence %d (windowSize behind) should be rejected", high-size)
+			}
+		})
+	}
+}
+
+func TestReplayWindowEdge(t *testing.T) {
+	const size = 256
+	rw := tunnel.NewReplayWindowWithSize(size)
+
+	if !rw.Check(1000) {
+		t.Fatal("Sequence 1000 should be valid")
+	}
+	// Oldest sequence still inside the window
+	if !rw.Check(1000 - size + 1) {
+		t.Error("Sequence at trailing window edge should be valid")
+	}
+	if rw.Check(1000 - size + 1) {
+		t.Error("Replay at trailing window edge should be rejected")
+	}
+	// Exactly windowSize behind
+	if rw.Check(1000 - size) {
+		t.Error("Sequence exactly windowSize behind should be rejected")
+	}
+}
+
+func TestReplayWindowForwardJump(t *testing.T) {
+	const size = 1024
+	rw := tunnel.NewReplayWindowWithSize(size)
+
+	for seq := uint64(0); seq < 900; seq++ {
+		if !rw.Check(seq) {
+			t.Fatalf("Sequence %d should be valid", seq)
+		}
+	}
+
+	// A jump smaller than the window keeps the surviving history
+	if !rw.Check(1100) {
+		t.Fatal("Forward jump should be valid")
+	}
+	for seq := uint64(1100 - size + 1); seq < 900; seq++ {
+		if rw.Check(seq) {
+			t.Errorf("Sequence %d should still be rejected as replay after jump", seq)
+		}
+	}
+	for seq := uint64(900); seq < 1100; seq++ {
+		if !rw.Check(seq) {
+			t.Errorf("Skipped sequence %d should be valid after jump", seq)
+		}
+	}
+
+	// A jump larger than the window clears all history
+	if !rw.Check(10000) {
+		t.Fatal("Large forward jump should be valid")
+	}
+	for seq := uint64(10000 - size + 1); seq < 10000; seq++ {
+		if !rw.Check(seq) {
+			t.Errorf("Sequence %d should be valid after large jump", seq)
+		}
+	}
+}
+
+func TestReplayWindowStats(t *testing.T) {
+	rw := tunnel.NewReplayWindowWithSize(64)
+
+	for _, seq := range []uint64{0, 1, 2, 10, 5} {
+		if !rw.Check(seq) {
+			t.Fatalf("Sequence %d should be valid", seq)
+		}
+	}
+	rw.Check(2)  // duplicate
+	rw.Check(10) // duplicate
+	if !rw.Check(1000) {
+		t.Fatal("Forward jump should be valid")
+	}
+	rw.Check(900) // older than the window
+
+	stats := rw.Stats()
+	if stats.Accepted != 6 {
+		t.Errorf("Accepted = %d, want 6", stats.Accepted)
+	}
+	if stats.RejectedDuplicate != 2 {
+		t.Errorf("RejectedDuplicate = %d, want 2", stats.RejectedDuplicate)
+	}
+	if stats.RejectedOld != 1 {
+		t.Errorf("RejectedOld = %d, want 1", stats.RejectedOld)
+	}
+	if stats.MaxForwardJump != 990 {
+		t.Errorf("MaxForwardJump = %d, want 990", stats.MaxForwardJump)
+	}
+}
+
+func TestNewSessionWithConfig(t *testing.T) {
+	cfg := tunnel.DefaultSessionConfig()
+	cfg.ReplayWindowSize = 512
+
+	sender, err := tunnel.NewSession(tunnel.RoleInitiator)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+	receiver, err := tunnel.NewSessionWithConfig(tunnel.RoleResponder, cfg)
+	if err != nil {
+		t.Fatalf("NewSessionWithConfig failed: %v", err)
+	}
+
+	masterSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(masterSecret)
+	_ = sender.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+	_ = receiver.InitializeKeys(masterSecret, constants.CipherSuiteAES256GCM)
+
+	var packets [][]byte
+	var seqs []uint64
+	for i := 0; i < 300; i++ {
+		ct, seq, err := sender.Encrypt([]byte("reordered"))
+		if err != nil {
+			t.Fatalf("Encrypt failed: %v", err)
+		}
+		packets = append(packets, ct)
+		seqs = append(seqs, seq)
+	}
+
+	// Deliver the newest packet first; the rest are 299 behind at most,
+	// which a 64-entry window would reject.
+	if _, err := receiver.Decrypt(packets[299], seqs[299]); err != nil {
+		t.Fatalf("Decrypt newest failed: %v", err)
+	}
+	if _, err := receiver.Decrypt(packets[0], seqs[0]); err != nil {
+		t.Errorf("Decrypt of reordered packet failed: %v", err)
+	}
+}
+
 func TestSessionClose(t *testing.T) {
 	session, err := tunnel.NewSession(tunnel.RoleInitiator)
 	if err != nil {
@@ -353,6 +541,139 @@ func TestHandshake(t *testing.T) {
 	}
 }
 
+func TestHandshakeCHKEM768(t *testing.T) {
+	cfg := tunnel.DefaultSessionConfig()
+	cfg.KEMParameters = chkem.CHKEM768
+
+	initiator, err := tunnel.NewSessionWithConfig(tunnel.RoleInitiator, cfg)
+	if err != nil {
+		t.Fatalf("NewSessionWithConfig failed: %v", err)
+	}
+	responder, err := tunnel.NewSession(tunnel.RoleResponder)
+	if err != nil {
+		t.Fatalf("NewSession failed: %v", err)
+	}
+
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	var wg sync.WaitGroup
+	var initiatorErr, responderErr error
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		initiatorErr = tunnel.InitiatorHandshake(initiator, clientConn)
+	}()
+	go func() {
+		defer wg.Done()
+		responderErr = tunnel.ResponderHandshake(responder, serverConn)
+	}()
+	wg.Wait()
+
+	if initiatorErr != nil {
+		t.Fatalf("Initiator handshake failed: %v", initiatorErr)
+	}
+	if responderErr != nil {
+		t.Fatalf("Responder handshake failed: %v", responderErr)
+	}
+
+	if initiator.KEMParameters != chkem.CHKEM768 || responder.KEMParameters != chkem.CHKEM768 {
+		t.Fatalf("negotiated KEM parameters: initiator %v, responder %v", initiator.KEMParameters, responder.KEMParameters)
+	}
+
+	// Rekeys keep using the negotiated parameter set
+	newPublicKey, activationSeq, err := initiator.InitiateRekey()
+	if err != nil {
+		t.Fatalf("InitiateRekey failed: %v", err)
+	}
+	if len(newPublicKey) != constants.CHKEM768PublicKeySize {
+		t.Errorf("rekey public key size: got %d, want %d", len(newPublicKey), constants.CHKEM768PublicKeySize)
+	}
+	ciphertext, err := responder.PrepareRekeyResponse(newPublicKey, activationSeq)
+	if err != nil {
+		t.Fatalf("PrepareRekeyResponse failed: %v", err)
+	}
+	if err := initiator.ProcessRekeyResponse(ciphertext); err != nil {
+		t.Fatalf("ProcessRekeyResponse failed: %v", err)
+	}
+}
+
+func TestSessionExporterSecret(t *testing.T) {
+	handshake := func() (*tunnel.Session, *tunnel.Session) {
+		initiator, _ := tunnel.NewSession(tunnel.RoleInitiator)
+		responder, _ := tunnel.NewSession(tunnel.RoleResponder)
+
+		clientConn, serverConn := net.Pipe()
+		defer func() { _ = clientConn.Close() }()
+		defer func() { _ = serverConn.Close() }()
+
+		var wg sync.WaitGroup
+		var initiatorErr, responderErr error
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			initiatorErr = tunnel.InitiatorHandshake(initiator, clientConn)
+		}()
+		go func() {
+			defer wg.Done()
+			responderErr = tunnel.ResponderHandshake(responder, serverConn)
+		}()
+		wg.Wait()
+
+		if initiatorErr != nil || responderErr != nil {
+			t.Fatalf("handshake failed: initiator %v, responder %v", initiatorErr, responderErr)
+		}
+		return initiator, responder
+	}
+
+	initiator, responder := handshake()
+
+	clientBinding, err := initiator.ExporterSecret("password-binding", 32)
+	if err != nil {
+		t.Fatalf("initiator ExporterSecret failed: %v", err)
+	}
+	serverBinding, err := responder.ExporterSecret("password-binding", 32)
+	if err != nil {
+		t.Fatalf("responder ExporterSecret failed: %v", err)
+	}
+	if !bytes.Equal(clientBinding, serverBinding) {
+		t.Error("peers derived different exporter values for the same label")
+	}
+
+	other, err := initiator.ExporterSecret("other-label", 32)
+	if err != nil {
+		t.Fatalf("ExporterSecret failed: %v", err)
+	}
+	if bytes.Equal(clientBinding, other) {
+		t.Error("different labels produced the same exporter value")
+	}
+
+	// Exporter values are unique per session
+	otherInitiator, _ := handshake()
+	otherBinding, _ := otherInitiator.ExporterSecret("password-binding", 32)
+	if bytes.Equal(clientBinding, otherBinding) {
+		t.Error("different sessions produced the same exporter value")
+	}
+
+	// Exporter values survive rekeying
+	newSecret := make([]byte, constants.CHKEMSharedSecretSize)
+	_ = crypto.SecureRandom(newSecret)
+	if err := initiator.Rekey(newSecret); err != nil {
+		t.Fatalf("Rekey failed: %v", err)
+	}
+	afterRekey, _ := initiator.ExporterSecret("password-binding", 32)
+	if !bytes.Equal(clientBinding, afterRekey) {
+		t.Error("exporter value changed after rekey")
+	}
+
+	// Sessions without keys cannot export
+	fresh, _ := tunnel.NewSession(tunnel.RoleInitiator)
+	if _, err := fresh.ExporterSecret("password-binding", 32); !errors.Is(err, qerrors.ErrInvalidState) {
+		t.Errorf("expected ErrInvalidState before handshake, got %v", err)
+	}
+}
+
 func TestFullTunnel(t *testing.T) {
 	// Create connected pair
 	clientConn, serverConn := net.Pipe()
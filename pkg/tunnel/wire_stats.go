@@ -0,0 +1,68 @@
+package tunnel
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// WireStats counts the bytes and messages a transport has written to and
+// read from its connection since the handshake completed. Unlike
+// Session.Stats, the byte counts include message headers, sequence
+// numbers, padding, and AEAD overhead.
+type WireStats struct {
+	BytesWritten    uint64
+	BytesRead       uint64
+	MessagesWritten uint64
+	MessagesRead    uint64
+}
+
+// wireCounters holds the live counters behind WireStats.
+type wireCounters struct {
+	bytesWritten    atomic.Uint64
+	bytesRead       atomic.Uint64
+	messagesWritten atomic.Uint64
+	messagesRead    atomic.Uint64
+}
+
+// WireStats returns the transport's connection-level traffic counters.
+func (t *Transport) WireStats() WireStats {
+	return WireStats{
+		BytesWritten:    t.wire.bytesWritten.Load(),
+		BytesRead:       t.wire.bytesRead.Load(),
+		MessagesWritten: t.wire.messagesWritten.Load(),
+		MessagesRead:    t.wire.messagesRead.Load(),
+	}
+}
+
+// write writes msg, which holds count encoded messages, to the connection
+// and counts the bytes and messages written. The caller must hold writeMu.
+func (t *Transport) write(msg []byte, count int) error {
+	var done func(error)
+	if o, ok := t.session.observer.(NetworkObserver); ok {
+		_, done = o.OnNetworkWrite(context.Background(), len(msg))
+	}
+	n, err := t.conn.Write(msg)
+	if done != nil {
+		done(err)
+	}
+	if n > 0 {
+		t.wire.bytesWritten.Add(uint64(n))
+		if o, ok := t.session.observer.(WireObserver); ok {
+			o.OnWireBytesSent(n)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	t.wire.messagesWritten.Add(uint64(count))
+	return nil
+}
+
+// recordRead counts a message read from the connection.
+func (t *Transport) recordRead(msg []byte) {
+	t.wire.bytesRead.Add(uint64(len(msg)))
+	t.wire.messagesRead.Add(1)
+	if o, ok := t.session.observer.(WireObserver); ok {
+		o.OnWireBytesReceived(len(msg))
+	}
+}
@@ -10,13 +10,18 @@
 package benchmark
 
 import (
+	"bytes"
+	"errors"
 	"net"
 	"sync"
 	"testing"
+	"time"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
+	qerrors "github.com/sara-star-quant/quantum-go/internal/errors"
 	"github.com/sara-star-quant/quantum-go/pkg/chkem"
 	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
 	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
 )
 
@@ -431,3 +436,186 @@ func BenchmarkCHKEMEncapsulationAllocs(b *testing.B) {
 		_, _, _ = chkem.Encapsulate(kp.PublicKey())
 	}
 }
+
+func BenchmarkEncodeDataAllocs(b *testing.B) {
+	codec := protocol.NewCodec()
+	payload := make([]byte, 1400)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, _ = codec.EncodeData(uint64(i), payload)
+	}
+}
+
+func BenchmarkEncodeDataIntoAllocs(b *testing.B) {
+	codec := protocol.NewCodec()
+	payload := make([]byte, 1400)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		buf := protocol.GetGlobal(protocol.HeaderSize + 8 + len(payload))
+		_, _ = codec.EncodeDataInto(buf[:0], uint64(i), payload)
+		protocol.PutGlobal(buf)
+	}
+}
+
+func BenchmarkReadMessageAllocs(b *testing.B) {
+	codec := protocol.NewCodec()
+	msg, _ := codec.EncodeData(1, make([]byte, 1400))
+	reader := bytes.NewReader(msg)
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(msg)
+		_, _ = codec.ReadMessage(reader)
+	}
+}
+
+func BenchmarkReadMessageIntoAllocs(b *testing.B) {
+	codec := protocol.NewCodec()
+	msg, _ := codec.EncodeData(1, make([]byte, 1400))
+	reader := bytes.NewReader(msg)
+	var buf []byte
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(msg)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		reader.Reset(msg)
+		read, _ := codec.ReadMessageInto(reader, buf)
+		buf = read[:0]
+	}
+}
+
+// --- Rekey Throughput Benchmarks ---
+
+// rekeyMessageSize is the size of each message sent by the rekey
+// throughput benchmarks.
+const rekeyMessageSize = 16 * 1024
+
+// BenchmarkRekeyThroughput measures sustained throughput over net.Pipe
+// with a rekey started every interval bytes, to show what aggressive
+// RekeyPolicy.MaxBytes settings cost. Besides MB/s it reports the rekeys
+// started per second.
+func BenchmarkRekeyThroughput(b *testing.B) {
+	intervals := []struct {
+		name  string
+		bytes int64
+	}{
+		{"NoRekey", 0},
+		{"Every1MB", 1 << 20},
+		{"Every10MB", 10 << 20},
+		{"Every100MB", 100 << 20},
+	}
+	for _, interval := range intervals {
+		b.Run(interval.name, func(b *testing.B) {
+			benchmarkRekeyThroughput(b, interval.bytes)
+		})
+	}
+}
+
+func benchmarkRekeyThroughput(b *testing.B, interval int64) {
+	client, server := rekeyTransports(b)
+	payload := make([]byte, rekeyMessageSize)
+	total := int64(b.N) * rekeyMessageSize
+
+	// The server drains the data and answers rekey requests; the client
+	// pump processes the responses
+	received := make(chan error, 1)
+	go func() {
+		var n int64
+		for n < total {
+			data, err := server.Receive()
+			if err != nil {
+				received <- err
+				return
+			}
+			n += int64(len(data))
+		}
+		received <- nil
+	}()
+	go func() {
+		for {
+			if _, err := client.Receive(); err != nil {
+				return
+			}
+		}
+	}()
+
+	b.SetBytes(rekeyMessageSize)
+	b.ResetTimer()
+	start := time.Now()
+
+	rekeys := 0
+	var sinceRekey int64
+	for i := 0; i < b.N; i++ {
+		if err := client.Send(payload); err != nil {
+			b.Fatalf("Send failed: %v", err)
+		}
+		sinceRekey += rekeyMessageSize
+		if interval == 0 || sinceRekey < interval {
+			continue
+		}
+		// A rekey still in progress is retried after the next message
+		switch err := client.SendRekey(); {
+		case err == nil:
+			rekeys++
+			sinceRekey = 0
+		case !errors.Is(err, qerrors.ErrRekeyInProgress):
+			b.Fatalf("SendRekey failed: %v", err)
+		}
+	}
+	if err := <-received; err != nil {
+		b.Fatalf("Receive failed: %v", err)
+	}
+
+	elapsed := time.Since(start)
+	b.StopTimer()
+	b.ReportMetric(float64(rekeys)/elapsed.Seconds(), "rekeys/s")
+}
+
+// rekeyTransports returns a client and server transport connected over
+// net.Pipe whose sessions only rekey when asked to.
+func rekeyTransports(b *testing.B) (client, server *tunnel.Transport) {
+	b.Helper()
+
+	clientConn, serverConn := net.Pipe()
+	b.Cleanup(func() {
+		_ = clientConn.Close()
+		_ = serverConn.Close()
+	})
+
+	sessionConfig := tunnel.SessionConfig{RekeyPolicy: tunnel.RekeyPolicy{Disabled: true}}
+	initiator, err := tunnel.NewSessionWithConfig(tunnel.RoleInitiator, sessionConfig)
+	if err != nil {
+		b.Fatal(err)
+	}
+	responder, err := tunnel.NewSessionWithConfig(tunnel.RoleResponder, sessionConfig)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	responderErr := make(chan error, 1)
+	go func() { responderErr <- tunnel.ResponderHandshake(responder, serverConn) }()
+	if err := tunnel.InitiatorHandshake(initiator, clientConn); err != nil {
+		b.Fatalf("initiator handshake failed: %v", err)
+	}
+	if err := <-responderErr; err != nil {
+		b.Fatalf("responder handshake failed: %v", err)
+	}
+
+	config := tunnel.DefaultTransportConfig()
+	if client, err = tunnel.NewTransport(initiator, clientConn, config); err != nil {
+		b.Fatal(err)
+	}
+	if server, err = tunnel.NewTransport(responder, serverConn, config); err != nil {
+		b.Fatal(err)
+	}
+	return client, server
+}
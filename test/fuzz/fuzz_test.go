@@ -6,6 +6,7 @@
 //	go test -fuzz=FuzzParseCiphertext -fuzztime=30s ./test/fuzz/
 //	go test -fuzz=FuzzDecodeClientHello -fuzztime=30s ./test/fuzz/
 //	go test -fuzz=FuzzDecodeServerHello -fuzztime=30s ./test/fuzz/
+//	go test -fuzz=FuzzDecodeMessage -fuzztime=30s ./test/fuzz/
 //	go test -fuzz=FuzzAEADOpen -fuzztime=30s ./test/fuzz/
 //
 // Run all fuzz tests sequentially:
@@ -14,6 +15,10 @@
 package fuzz
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/binary"
+	"fmt"
 	"testing"
 
 	"github.com/sara-star-quant/quantum-go/internal/constants"
@@ -106,6 +111,14 @@ func FuzzDecodeClientHello(f *testing.F) {
 	f.Add([]byte{0x01, 0, 0, 0, 0})             // Header only
 	f.Add([]byte{0x01, 0xff, 0xff, 0xff, 0xff}) // Huge length
 
+	// Valid hello claiming the maximum cipher suite count
+	if len(encoded) > 0 {
+		huge := append([]byte(nil), encoded...)
+		countOffset := protocol.HeaderSize + 2 + 32 + 1 + 1 + len(validHello.CHKEMPublicKey)
+		huge[countOffset], huge[countOffset+1] = 0xff, 0xff
+		f.Add(huge)
+	}
+
 	f.Fuzz(func(t *testing.T, data []byte) {
 		// Should not panic regardless of input
 		msg, err := codec.DecodeClientHello(data)
@@ -216,6 +229,212 @@ func FuzzDecodeAlert(f *testing.F) {
 	})
 }
 
+// FuzzDecodeMessage fuzzes message framing and the per-type decoder
+// dispatch, which see every message read from the network. Any message that
+// decodes must re-encode to a subset of its input.
+func FuzzDecodeMessage(f *testing.F) {
+	codec := protocol.NewCodec()
+
+	for _, seed := range messageSeeds(f, codec) {
+		f.Add(seed)
+		// Truncated copies, with the header length fixed up to match
+		for _, n := range []int{protocol.HeaderSize, protocol.HeaderSize + 1, (len(seed) + protocol.HeaderSize) / 2, len(seed) - 1} {
+			if n < protocol.HeaderSize || n >= len(seed) {
+				continue
+			}
+			truncated := append([]byte(nil), seed[:n]...)
+			//nolint:gosec // G115: seeds are far below 4 GiB
+			binary.BigEndian.PutUint32(truncated[1:], uint32(n-protocol.HeaderSize))
+			f.Add(truncated)
+		}
+	}
+
+	// Edge cases
+	f.Add([]byte{})
+	f.Add([]byte{0x00, 0, 0, 0, 0})
+	f.Add([]byte{0xFF, 0, 0, 0, 0})
+	f.Add([]byte{0x11, 0xff, 0xff, 0xff, 0xff})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		// Should not panic regardless of input
+		msg, err := codec.ReadMessage(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		msgType, err := codec.GetMessageType(msg)
+		if err != nil {
+			t.Fatalf("GetMessageType failed on a framed message: %v", err)
+		}
+
+		encoded, err := decodeAndReencode(codec, msgType, msg)
+		if err != nil || encoded == nil {
+			return
+		}
+
+		if encoded[0] != msg[0] {
+			t.Fatalf("%s re-encoded as type %#02x", msgType, encoded[0])
+		}
+		switch msgType {
+		case protocol.MessageTypeClientHello, protocol.MessageTypeServerHello:
+			// Extensions are re-encoded in sorted order, so only the size is
+			// preserved
+			if len(encoded) != len(msg) {
+				t.Errorf("%s re-encoded to %d bytes, input was %d", msgType, len(encoded), len(msg))
+			}
+		default:
+			if !bytes.HasPrefix(msg[protocol.HeaderSize:], encoded[protocol.HeaderSize:]) {
+				t.Errorf("%s re-encoding is not a prefix of the input payload", msgType)
+			}
+		}
+	})
+}
+
+// decodeAndReencode decodes msg with the decoder for msgType and encodes
+// the result again. It returns nil for types without a decoder.
+func decodeAndReencode(codec *protocol.Codec, msgType protocol.MessageType, msg []byte) ([]byte, error) {
+	switch msgType {
+	case protocol.MessageTypeClientHello:
+		m, err := codec.DecodeClientHello(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeClientHello(m)
+	case protocol.MessageTypeServerHello:
+		m, err := codec.DecodeServerHello(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeServerHello(m)
+	case protocol.MessageTypeHelloRetryRequest:
+		m, err := codec.DecodeHelloRetryRequest(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeHelloRetryRequest(m)
+	case protocol.MessageTypeClientFinished:
+		verifyData, err := codec.DecodeFinished(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeFinished(msgType, verifyData)
+	case protocol.MessageTypeServerFinished:
+		m, err := codec.DecodeServerFinished(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeServerFinished(m)
+	case protocol.MessageTypeData:
+		seq, payload, err := codec.DecodeData(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeData(seq, payload)
+	case protocol.MessageTypeAlert:
+		level, code, desc, err := codec.DecodeAlert(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeAlert(level, code, desc), nil
+	case protocol.MessageTypeRekey:
+		seq, ciphertext, err := codec.DecodeRekey(msg)
+		if err != nil {
+			return nil, err
+		}
+		// Also treat the ciphertext as a decrypted inner payload
+		if kind, kemData, activationSeq, err := codec.DecodeRekeyPayload(ciphertext); err == nil {
+			payload, err := codec.EncodeRekeyPayload(kind, kemData, activationSeq)
+			if err != nil || !bytes.Equal(payload, ciphertext) {
+				return nil, fmt.Errorf("rekey payload does not round-trip: %v", err)
+			}
+		}
+		return codec.EncodeRekey(seq, ciphertext)
+	case protocol.MessageTypeCloseWrite:
+		seq, ciphertext, err := codec.DecodeCloseWrite(msg)
+		if err != nil {
+			return nil, err
+		}
+		return codec.EncodeCloseWrite(seq, ciphertext)
+	case protocol.MessageTypeRatchet:
+		seq, ciphertext, err := codec.DecodeRatchet(msg)
+		if err != nil {
+			return nil, err
+		}
+		if activationSeq, err := codec.DecodeRatchetPayload(ciphertext); err == nil {
+			if !bytes.Equal(codec.EncodeRatchetPayload(activationSeq), ciphertext) {
+				return nil, fmt.Errorf("ratchet payload does not round-trip")
+			}
+		}
+		return codec.EncodeRatchet(seq, ciphertext)
+	default:
+		return nil, nil
+	}
+}
+
+// messageSeeds returns a valid encoding of every message type with a
+// decoder. Rekey and Ratchet messages carry a plaintext inner payload in
+// place of the ciphertext so the payload decoders are reached too.
+func messageSeeds(f *testing.F, codec *protocol.Codec) [][]byte {
+	kp, err := chkem.GenerateKeyPair()
+	if err != nil {
+		f.Fatalf("GenerateKeyPair failed: %v", err)
+	}
+	ct, _, err := chkem.Encapsulate(kp.PublicKey())
+	if err != nil {
+		f.Fatalf("Encapsulate failed: %v", err)
+	}
+	identity := ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize))
+	verifyData := make([]byte, 32)
+
+	var seeds [][]byte
+	add := func(encoded []byte, err error) {
+		if err != nil {
+			f.Fatalf("encoding seed failed: %v", err)
+		}
+		seeds = append(seeds, encoded)
+	}
+	add(codec.EncodeClientHello(&protocol.ClientHello{
+		Version:        protocol.Current,
+		Random:         make([]byte, 32),
+		CHKEMPublicKey: kp.PublicKey().Bytes(),
+		CipherSuites:   []constants.CipherSuite{constants.CipherSuiteAES256GCM, constants.CipherSuiteChaCha20Poly1305},
+		Cookie:         []byte("cookie"),
+		Extensions:     map[uint16][]byte{protocol.ExtensionEarlyData: {}, 0x7F00: []byte("unknown")},
+	}))
+	add(codec.EncodeServerHello(&protocol.ServerHello{
+		Version:         protocol.Current,
+		Random:          make([]byte, 32),
+		SessionID:       make([]byte, constants.SessionIDSize),
+		CHKEMCiphertext: ct.Bytes(),
+		CipherSuite:     constants.CipherSuiteAES256GCM,
+	}))
+	add(codec.EncodeServerHello(&protocol.ServerHello{
+		Version:     protocol.Current,
+		Random:      make([]byte, 32),
+		SessionID:   make([]byte, constants.SessionIDSize),
+		Resumed:     true,
+		CipherSuite: constants.CipherSuiteAES256GCM,
+		Extensions:  map[uint16][]byte{protocol.ExtensionEarlyData: {}},
+	}))
+	add(codec.EncodeHelloRetryRequest(&protocol.HelloRetryRequest{Version: protocol.Current, Cookie: []byte("cookie")}))
+	add(codec.EncodeFinished(protocol.MessageTypeClientFinished, verifyData))
+	add(codec.EncodeServerFinished(&protocol.ServerFinished{
+		VerifyData:  verifyData,
+		IdentityKey: identity.Public().(ed25519.PublicKey),
+		Signature:   ed25519.Sign(identity, verifyData),
+	}))
+	add(codec.EncodeData(1, []byte("test payload")))
+	add(codec.EncodeAlert(protocol.AlertLevelFatal, protocol.AlertCodeHandshakeFailure, "test error"), nil)
+
+	rekeyPayload, err := codec.EncodeRekeyPayload(protocol.RekeyKindRequest, kp.PublicKey().Bytes(), 1<<20)
+	if err != nil {
+		f.Fatalf("EncodeRekeyPayload failed: %v", err)
+	}
+	add(codec.EncodeRekey(2, rekeyPayload))
+	add(codec.EncodeCloseWrite(3, []byte{byte(protocol.MessageTypeCloseWrite)}))
+	add(codec.EncodeRatchet(4, codec.EncodeRatchetPayload(1<<20)))
+	return seeds
+}
+
 // FuzzAEADOpen fuzzes the AEAD decryption path.
 // This is critical as it processes potentially malicious ciphertext.
 func FuzzAEADOpen(f *testing.F) {
@@ -551,6 +551,47 @@ func TestDifferentCipherSuites(t *testing.T) {
 	}
 }
 
+// TestAES128GCMTunnel verifies a tunnel negotiated with AES-128-GCM end to end,
+// including a rekey under the 16-byte traffic keys.
+func TestAES128GCMTunnel(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer func() { _ = clientConn.Close() }()
+	defer func() { _ = serverConn.Close() }()
+
+	cfg := tunnel.DefaultSessionConfig()
+	cfg.CipherSuites = []constants.CipherSuite{constants.CipherSuiteAES128GCM}
+	clientSession, err := tunnel.NewSessionWithConfig(tunnel.RoleInitiator, cfg)
+	if err != nil {
+		t.Fatalf("NewSessionWithConfig failed: %v", err)
+	}
+	serverSession, _ := tunnel.NewSession(tunnel.RoleResponder)
+
+	performHandshake(clientSession, serverSession, clientConn, serverConn)
+
+	config := tunnel.DefaultTransportConfig()
+	clientTransport, _ := tunnel.NewTransport(clientSession, clientConn, config)
+	serverTransport, _ := tunnel.NewTransport(serverSession, serverConn, config)
+	defer func() { _ = clientTransport.Close() }()
+	defer func() { _ = serverTransport.Close() }()
+
+	for _, transport := range []*tunnel.Transport{clientTransport, serverTransport} {
+		if suite := transport.ConnectionState().CipherSuite; suite != constants.CipherSuiteAES128GCM {
+			t.Fatalf("negotiated %s, want AES-128-GCM", suite)
+		}
+	}
+
+	serverRecv := make(chan []byte, 20)
+	clientRecv := make(chan []byte, 20)
+	startReceiver(serverTransport, serverRecv)
+	startReceiver(clientTransport, clientRecv)
+
+	sendMessagesWithRekey(t, clientTransport, 20, 5, "aes128-")
+	receiveMessages(t, serverRecv, 20, "AES-128-GCM")
+
+	sendMessages(t, serverTransport, 20, "reply-")
+	receiveMessages(t, clientRecv, 20, "AES-128-GCM reply")
+}
+
 // TestTunnelTimeout verifies timeout handling.
 func TestTunnelTimeout(t *testing.T) {
 	clientConn, serverConn := net.Pipe()
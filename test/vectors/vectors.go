@@ -0,0 +1,306 @@
+// Package vectors generates interoperability test vectors for the wire
+// format and key schedule.
+//
+// Each vector runs a full handshake between an initiator and a responder
+// with the random source seeded by crypto.DeterministicReader, and records
+// the encoded hellos, the Finished messages and their encrypted records,
+// the derived handshake and traffic keys, and a sample data record. Another
+// implementation can replay a vector by drawing the same random bytes in
+// the same order, or check its own decoders and key schedule against the
+// recorded values.
+//
+// The vectors are committed in testdata/vectors.json. Regenerate them with:
+//
+//	go test ./test/vectors/ -update
+//
+// Regenerating changes the file only if the wire format or key schedule
+// changed, which breaks interoperability with existing peers.
+package vectors
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	"github.com/sara-star-quant/quantum-go/pkg/chkem"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+	"github.com/sara-star-quant/quantum-go/pkg/tunnel"
+)
+
+// ExporterLabel is the label of the exported keying material in each
+// vector.
+const ExporterLabel = "quantum-go interop vector"
+
+// DataPlaintext is the plaintext of the sample data record in each vector.
+const DataPlaintext = "quantum-go interop data record"
+
+// Case holds the inputs of one vector.
+type Case struct {
+	Name          string
+	Seed          string
+	KEMParameters chkem.Parameters
+	CipherSuite   constants.CipherSuite
+}
+
+// Cases are the committed vectors, one per KEM parameter set and cipher
+// suite pairing.
+var Cases = []Case{
+	{"chkem1024-aes256gcm", "quantum-go interop vector 1", chkem.CHKEM1024, constants.CipherSuiteAES256GCM},
+	{"chkem768-aes128gcm", "quantum-go interop vector 2", chkem.CHKEM768, constants.CipherSuiteAES128GCM},
+	{"chkem1024-chacha20poly1305", "quantum-go interop vector 3", chkem.CHKEM1024, constants.CipherSuiteChaCha20Poly1305},
+}
+
+// Hex is a byte string encoded as lowercase hex in JSON.
+type Hex []byte
+
+// MarshalText encodes h as hex.
+func (h Hex) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(h)), nil
+}
+
+// UnmarshalText decodes hex into h.
+func (h *Hex) UnmarshalText(text []byte) error {
+	b, err := hex.DecodeString(string(text))
+	if err != nil {
+		return err
+	}
+	*h = b
+	return nil
+}
+
+// Keys holds the keys derived for each direction.
+type Keys struct {
+	Initiator Hex `json:"initiator"`
+	Responder Hex `json:"responder"`
+}
+
+// DataRecord is an encrypted data record sent by the initiator.
+type DataRecord struct {
+	Seq       uint64 `json:"seq"`
+	Plaintext Hex    `json:"plaintext"`
+	Message   Hex    `json:"message"` // Encoded Data message
+}
+
+// Vector is one handshake and its derived values.
+type Vector struct {
+	Name          string `json:"name"`
+	Seed          string `json:"seed"`
+	KEMParameters uint8  `json:"kem_parameters"`
+	CipherSuite   uint16 `json:"cipher_suite"`
+
+	// Encoded handshake messages. The Finished messages are sent as
+	// length-prefixed records sealed with the handshake keys; both the
+	// plaintext message and the sealed record are recorded.
+	ClientHello          Hex `json:"client_hello"`
+	ServerHello          Hex `json:"server_hello"`
+	ClientFinished       Hex `json:"client_finished"`
+	ClientFinishedRecord Hex `json:"client_finished_record"`
+	ServerFinished       Hex `json:"server_finished"`
+	ServerFinishedRecord Hex `json:"server_finished_record"`
+
+	// Key schedule
+	SharedSecret   Hex  `json:"shared_secret"`
+	HandshakeKeys  Keys `json:"handshake_keys"`
+	TranscriptHash Hex  `json:"transcript_hash"` // Over ClientHello, ServerHello and ClientFinished
+	TrafficKeys    Keys `json:"traffic_keys"`
+	TrafficIVs     Keys `json:"traffic_ivs"`
+	Exporter       Hex  `json:"exporter"` // 32 bytes exported with ExporterLabel
+
+	Data DataRecord `json:"data"`
+}
+
+// Generate runs the handshake for c and returns its vector, after checking
+// that both peers and the independently derived keys agree.
+//
+// Generate replaces the process-wide random source while it runs, so it
+// must not be called concurrently with anything that draws random bytes.
+// It fails in FIPS mode, where the random source can only be set once.
+func Generate(c Case) (*Vector, error) {
+	if err := crypto.SetRandReader(crypto.DeterministicReader([]byte(c.Seed))); err != nil {
+		return nil, err
+	}
+	defer func() { _ = crypto.SetRandReader(nil) }()
+
+	config := tunnel.DefaultSessionConfig()
+	config.KEMParameters = c.KEMParameters
+	config.CipherSuites = []constants.CipherSuite{c.CipherSuite}
+	initiator, err := tunnel.NewSessionWithConfig(tunnel.RoleInitiator, config)
+	if err != nil {
+		return nil, err
+	}
+	defer initiator.Close()
+	responder, err := tunnel.NewSession(tunnel.RoleResponder)
+	if err != nil {
+		return nil, err
+	}
+	defer responder.Close()
+
+	v := &Vector{
+		Name:          c.Name,
+		Seed:          c.Seed,
+		KEMParameters: uint8(c.KEMParameters),
+		CipherSuite:   uint16(c.CipherSuite),
+	}
+	if err := v.handshake(initiator, responder); err != nil {
+		return nil, err
+	}
+	if err := v.deriveKeys(initiator, responder); err != nil {
+		return nil, err
+	}
+	if err := v.sealData(initiator, responder); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// handshake runs the handshake step by step, so random bytes are drawn in
+// a fixed order, and records its messages.
+func (v *Vector) handshake(initiator, responder *tunnel.Session) error {
+	client, server := tunnel.NewHandshake(initiator), tunnel.NewHandshake(responder)
+
+	var err error
+	if v.ClientHello, err = client.CreateClientHello(); err != nil {
+		return fmt.Errorf("CreateClientHello: %w", err)
+	}
+	if err := server.ProcessClientHello(v.ClientHello); err != nil {
+		return fmt.Errorf("ProcessClientHello: %w", err)
+	}
+	if v.ServerHello, err = server.CreateServerHello(); err != nil {
+		return fmt.Errorf("CreateServerHello: %w", err)
+	}
+	if err := client.ProcessServerHello(v.ServerHello); err != nil {
+		return fmt.Errorf("ProcessServerHello: %w", err)
+	}
+	if v.ClientFinishedRecord, err = client.CreateClientFinished(); err != nil {
+		return fmt.Errorf("CreateClientFinished: %w", err)
+	}
+	if err := server.ProcessClientFinished(v.ClientFinishedRecord); err != nil {
+		return fmt.Errorf("ProcessClientFinished: %w", err)
+	}
+	if v.ServerFinishedRecord, err = server.CreateServerFinished(); err != nil {
+		return fmt.Errorf("CreateServerFinished: %w", err)
+	}
+	if err := client.ProcessServerFinished(v.ServerFinishedRecord); err != nil {
+		return fmt.Errorf("ProcessServerFinished: %w", err)
+	}
+	if !client.IsComplete() || !server.IsComplete() {
+		return fmt.Errorf("handshake did not complete")
+	}
+	if initiator.CipherSuite != constants.CipherSuite(v.CipherSuite) {
+		return fmt.Errorf("negotiated %s, want %s", initiator.CipherSuite, constants.CipherSuite(v.CipherSuite))
+	}
+	return nil
+}
+
+// deriveKeys derives the key schedule from the ServerHello ciphertext and
+// checks it against the keys the handshake used.
+func (v *Vector) deriveKeys(initiator, responder *tunnel.Session) error {
+	suite := constants.CipherSuite(v.CipherSuite)
+	codec := protocol.NewCodec()
+
+	hello, err := codec.DecodeServerHello(v.ServerHello)
+	if err != nil {
+		return err
+	}
+	ct, err := chkem.ParseCiphertext(hello.CHKEMCiphertext)
+	if err != nil {
+		return err
+	}
+	if v.SharedSecret, err = chkem.Decapsulate(ct, initiator.LocalKeyPair); err != nil {
+		return err
+	}
+
+	// The Finished records must open under the handshake keys
+	v.HandshakeKeys.Initiator, v.HandshakeKeys.Responder, _, _, err = crypto.DeriveHandshakeKeysForSuite(v.SharedSecret, suite)
+	if err != nil {
+		return err
+	}
+	if v.ClientFinished, err = openHandshakeRecord(suite, v.HandshakeKeys.Initiator, v.ClientFinishedRecord); err != nil {
+		return fmt.Errorf("ClientFinished record: %w", err)
+	}
+	if v.ServerFinished, err = openHandshakeRecord(suite, v.HandshakeKeys.Responder, v.ServerFinishedRecord); err != nil {
+		return fmt.Errorf("ServerFinished record: %w", err)
+	}
+
+	// The transcript hash must reproduce both peers' exported keying material
+	if v.TranscriptHash, err = crypto.TranscriptHash(bytes.Join([][]byte{v.ClientHello, v.ServerHello, v.ClientFinished}, nil)); err != nil {
+		return err
+	}
+	if v.Exporter, err = exporter(v.SharedSecret, v.TranscriptHash); err != nil {
+		return err
+	}
+	for _, s := range []*tunnel.Session{initiator, responder} {
+		exported, err := s.ExporterSecret(ExporterLabel, constants.KDFOutputSize)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(exported, v.Exporter) {
+			return fmt.Errorf("%s exporter does not match the transcript hash", s.Role)
+		}
+	}
+
+	if v.TrafficKeys.Initiator, v.TrafficKeys.Responder, err = crypto.DeriveTrafficKeysForSuite(v.SharedSecret, suite); err != nil {
+		return err
+	}
+	v.TrafficIVs.Initiator, v.TrafficIVs.Responder, err = crypto.DeriveTrafficIVs(v.SharedSecret)
+	return err
+}
+
+// sealData records the initiator's first data record and checks that the
+// responder and the derived traffic keys both open it.
+func (v *Vector) sealData(initiator, responder *tunnel.Session) error {
+	suite := constants.CipherSuite(v.CipherSuite)
+	codec := protocol.NewCodec()
+
+	v.Data.Plaintext = []byte(DataPlaintext)
+	ciphertext, seq, err := initiator.Encrypt(v.Data.Plaintext)
+	if err != nil {
+		return err
+	}
+	v.Data.Seq = seq
+	if v.Data.Message, err = codec.EncodeData(seq, ciphertext); err != nil {
+		return err
+	}
+
+	if plaintext, err := responder.Decrypt(ciphertext, seq); err != nil || !bytes.Equal(plaintext, v.Data.Plaintext) {
+		return fmt.Errorf("responder failed to open the data record: %v", err)
+	}
+	cipher, err := crypto.NewAEADWithIV(suite, v.TrafficKeys.Initiator, v.TrafficIVs.Initiator)
+	if err != nil {
+		return err
+	}
+	plaintext, err := cipher.OpenSeq(seq, ciphertext, seqAAD(seq))
+	if err != nil || !bytes.Equal(plaintext, v.Data.Plaintext) {
+		return fmt.Errorf("derived traffic keys failed to open the data record: %v", err)
+	}
+	return nil
+}
+
+// seqAAD returns the additional data of a data record: its sequence
+// number, big-endian.
+func seqAAD(seq uint64) []byte {
+	return binary.BigEndian.AppendUint64(nil, seq)
+}
+
+// openHandshakeRecord opens a sealed Finished record with a handshake key.
+func openHandshakeRecord(suite constants.CipherSuite, key, record []byte) ([]byte, error) {
+	cipher, err := crypto.NewAEAD(suite, key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.Open(record, nil)
+}
+
+// exporter derives the keying material a session exports for
+// ExporterLabel from the handshake shared secret and transcript hash.
+func exporter(sharedSecret, transcriptHash []byte) ([]byte, error) {
+	secret, err := crypto.DeriveKey(constants.DomainSeparatorExporter, bytes.Join([][]byte{sharedSecret, transcriptHash}, nil), constants.KDFOutputSize)
+	if err != nil {
+		return nil, err
+	}
+	input := bytes.Join([][]byte{secret, transcriptHash, []byte(ExporterLabel)}, nil)
+	return crypto.DeriveKey(constants.DomainSeparatorExporter, input, constants.KDFOutputSize)
+}
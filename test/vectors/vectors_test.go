@@ -0,0 +1,145 @@
+package vectors
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sara-star-quant/quantum-go/internal/constants"
+	"github.com/sara-star-quant/quantum-go/pkg/crypto"
+	"github.com/sara-star-quant/quantum-go/pkg/protocol"
+)
+
+var update = flag.Bool("update", false, "regenerate testdata/vectors.json")
+
+var vectorsFile = filepath.Join("testdata", "vectors.json")
+
+// generateAll generates a vector for every case.
+func generateAll(t *testing.T) []*Vector {
+	t.Helper()
+
+	if crypto.FIPSMode() {
+		t.Skip("random source can only be set once in FIPS mode")
+	}
+	vectors := make([]*Vector, 0, len(Cases))
+	for _, c := range Cases {
+		v, err := Generate(c)
+		if err != nil {
+			t.Fatalf("%s: %v", c.Name, err)
+		}
+		vectors = append(vectors, v)
+	}
+	return vectors
+}
+
+func marshalVectors(t *testing.T, vectors []*Vector) []byte {
+	t.Helper()
+
+	data, err := json.MarshalIndent(vectors, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal vectors: %v", err)
+	}
+	return append(data, '\n')
+}
+
+// TestVectorsUpToDate fails if the wire format or key schedule no longer
+// reproduces the committed vectors.
+func TestVectorsUpToDate(t *testing.T) {
+	data := marshalVectors(t, generateAll(t))
+
+	if *update {
+		if err := os.WriteFile(vectorsFile, data, 0o644); err != nil {
+			t.Fatalf("write %s: %v", vectorsFile, err)
+		}
+		return
+	}
+
+	committed, err := os.ReadFile(vectorsFile)
+	if err != nil {
+		t.Fatalf("read %s: %v", vectorsFile, err)
+	}
+	if bytes.Equal(data, committed) {
+		return
+	}
+
+	var want []*Vector
+	if err := json.Unmarshal(committed, &want); err != nil {
+		t.Fatalf("parse %s: %v", vectorsFile, err)
+	}
+	var got []*Vector
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("parse generated vectors: %v", err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("generated %d vectors, %s has %d", len(got), vectorsFile, len(want))
+	}
+	for i := range got {
+		g, _ := json.MarshalIndent(got[i], "", "  ")
+		w, _ := json.MarshalIndent(want[i], "", "  ")
+		if !bytes.Equal(g, w) {
+			t.Errorf("vector %s drifted:\ngot  %s\nwant %s", want[i].Name, g, w)
+		}
+	}
+	t.Fatal("vectors drifted; if the change is intended, regenerate them with -update")
+}
+
+// TestVectorsDecode checks the committed messages against the decoders, so
+// a stale file cannot pass by being regenerated alongside a broken codec.
+func TestVectorsDecode(t *testing.T) {
+	committed, err := os.ReadFile(vectorsFile)
+	if err != nil {
+		t.Fatalf("read %s: %v", vectorsFile, err)
+	}
+	var vectors []*Vector
+	if err := json.Unmarshal(committed, &vectors); err != nil {
+		t.Fatalf("parse %s: %v", vectorsFile, err)
+	}
+	if len(vectors) != len(Cases) {
+		t.Fatalf("%s has %d vectors, want %d", vectorsFile, len(vectors), len(Cases))
+	}
+
+	codec := protocol.NewCodec()
+	for _, v := range vectors {
+		t.Run(v.Name, func(t *testing.T) {
+			if suite := constants.CipherSuite(v.CipherSuite); crypto.FIPSMode() && !suite.IsFIPSApproved() {
+				t.Skipf("%s is not FIPS approved", suite)
+			}
+			ch, err := codec.DecodeClientHello(v.ClientHello)
+			if err != nil {
+				t.Fatalf("DecodeClientHello: %v", err)
+			}
+			if len(ch.CipherSuites) != 1 || uint16(ch.CipherSuites[0]) != v.CipherSuite {
+				t.Errorf("ClientHello offers %v, want %#04x", ch.CipherSuites, v.CipherSuite)
+			}
+			sh, err := codec.DecodeServerHello(v.ServerHello)
+			if err != nil {
+				t.Fatalf("DecodeServerHello: %v", err)
+			}
+			if uint16(sh.CipherSuite) != v.CipherSuite {
+				t.Errorf("ServerHello selects %#04x, want %#04x", uint16(sh.CipherSuite), v.CipherSuite)
+			}
+
+			seq, ciphertext, err := codec.DecodeData(v.Data.Message)
+			if err != nil {
+				t.Fatalf("DecodeData: %v", err)
+			}
+			if seq != v.Data.Seq {
+				t.Errorf("data record seq = %d, want %d", seq, v.Data.Seq)
+			}
+			cipher, err := crypto.NewAEADWithIV(sh.CipherSuite, v.TrafficKeys.Initiator, v.TrafficIVs.Initiator)
+			if err != nil {
+				t.Fatalf("NewAEADWithIV: %v", err)
+			}
+			plaintext, err := cipher.OpenSeq(seq, ciphertext, seqAAD(seq))
+			if err != nil {
+				t.Fatalf("OpenSeq: %v", err)
+			}
+			if !bytes.Equal(plaintext, v.Data.Plaintext) {
+				t.Errorf("data record plaintext = %q, want %q", plaintext, v.Data.Plaintext)
+			}
+		})
+	}
+}